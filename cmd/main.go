@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
@@ -9,94 +10,338 @@ import (
 	"time"
 
 	"github.com/DblMOKRQ/DeNet_test_task/internal/config"
-	"github.com/DblMOKRQ/DeNet_test_task/internal/repository/postgres"
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
 	"github.com/DblMOKRQ/DeNet_test_task/internal/router"
 	"github.com/DblMOKRQ/DeNet_test_task/internal/router/handlers"
+	"github.com/DblMOKRQ/DeNet_test_task/internal/router/middleware"
 	"github.com/DblMOKRQ/DeNet_test_task/internal/service"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/buildinfo"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/inflight"
 	"github.com/DblMOKRQ/DeNet_test_task/pkg/jwt"
 	"github.com/DblMOKRQ/DeNet_test_task/pkg/logger"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/signedtoken"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/webhook"
 	"go.uber.org/zap"
 )
 
 func main() {
+	os.Exit(run())
+}
+
+// run запускает сервер и возвращает код завершения процесса. Выход из процесса выполняется
+// в main, чтобы отложенные вызовы (закрытие репозитория, сброс логов) выполнялись всегда.
+func run() int {
 	// Загрузка конфигурации
 	cfg := config.MustLoad()
 
 	// Инициализация логгера
-	log, err := logger.NewLogger()
+	log, logLevel, err := logger.NewLogger(logger.Options{
+		Level:      cfg.Log.Level,
+		Output:     cfg.Log.Output,
+		MaxSizeMB:  cfg.Log.MaxSizeMB,
+		MaxBackups: cfg.Log.MaxBackups,
+		MaxAgeDays: cfg.Log.MaxAgeDays,
+
+		Development:        cfg.Log.Development,
+		Sampling:           cfg.Log.Sampling.Enabled,
+		SamplingInitial:    cfg.Log.Sampling.Initial,
+		SamplingThereafter: cfg.Log.Sampling.Thereafter,
+	})
 	if err != nil {
 		panic(err)
 	}
 	defer log.Sync()
 
+	// Запуск миграций без старта сервера
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		return runMigrate(cfg, log, os.Args[2:])
+	}
+
+	build := buildinfo.Get()
 	log.Info("Starting application",
-		zap.String("version", "1.0.0"))
+		zap.String("version", build.Version),
+		zap.String("commit", build.Commit),
+		zap.String("build_date", build.BuildDate))
 
 	// Инициализация репозитория
-	log.Info("Initializing repository")
-	repo, err := postgres.NewRepository(
-		cfg.Storage.User,
-		cfg.Storage.Password,
-		cfg.Storage.Host,
-		cfg.Storage.Port,
-		cfg.Storage.DBName,
-		cfg.Storage.Sslmode,
-		log,
-	)
+	log.Info("Initializing repository", zap.String("driver", cfg.Storage.Driver))
+	repo, err := newStorage(cfg, log)
 	if err != nil {
-		log.Fatal("Failed to initialize repository", zap.Error(err))
+		log.Error("Failed to initialize repository", zap.Error(err))
+		return 1
 	}
 	defer repo.Close()
 
 	// Инициализация сервисов
 	log.Info("Initializing services")
 
-	jwtService := jwt.NewService(cfg.JWT.SecretKey, cfg.JWT.TokenDuration, log)
-	userService := service.NewUserService(repo, log)
+	jwtService, err := jwt.NewService(jwt.Options{
+		Algorithm:      cfg.JWT.Algorithm,
+		SecretKey:      cfg.JWT.SecretKey,
+		PrivateKeyPath: cfg.JWT.PrivateKeyPath,
+		PublicKeyPath:  cfg.JWT.PublicKeyPath,
+		TokenDuration:  cfg.JWT.TokenDuration,
+		Issuer:         cfg.JWT.Issuer,
+		Audience:       cfg.JWT.Audience,
+		Leeway:         *cfg.JWT.Leeway,
+	}, log)
+	if err != nil {
+		log.Error("Failed to initialize JWT service", zap.Error(err))
+		return 1
+	}
+	log.Info("JWT service initialized",
+		zap.String("algorithm", cfg.JWT.Algorithm),
+		zap.Duration("token_duration", cfg.JWT.TokenDuration),
+		zap.Duration("leeway", *cfg.JWT.Leeway))
+	background := inflight.New()
+
+	// Уведомления о начислении баллов включаются заданием webhook.url
+	var notifier service.Notifier
+	var dispatcher *webhook.Dispatcher
+	if cfg.Webhook.URL != "" {
+		dispatcher = webhook.New(webhook.Options{
+			URL:           cfg.Webhook.URL,
+			Secret:        cfg.Webhook.Secret,
+			Workers:       cfg.Webhook.Workers,
+			QueueSize:     cfg.Webhook.QueueSize,
+			MaxAttempts:   cfg.Webhook.MaxAttempts,
+			Backoff:       cfg.Webhook.Backoff,
+			Timeout:       cfg.Webhook.Timeout,
+			CorrelationID: *cfg.Webhook.CorrelationID,
+		}, log)
+		notifier = dispatcher
+		log.Info("Webhook notifications enabled", zap.Int("workers", cfg.Webhook.Workers))
+	}
+
+	// Без заданного ключа токены подтверждения подписываются случайным ключом
+	var emailTokens *signedtoken.Signer
+	if cfg.Email.VerificationSecret != "" {
+		emailTokens = service.NewEmailTokenSigner([]byte(cfg.Email.VerificationSecret))
+	} else {
+		log.Warn("email.verification_secret is not set, verification tokens will not survive a restart")
+	}
+
+	// Представление таблицы лидеров поддерживается только хранилищем PostgreSQL (проверяется в config)
+	var leaderboardView service.LeaderboardViewRepository
+	if cfg.Leaderboard.MaterializedView.Enabled {
+		leaderboardView, _ = repo.(service.LeaderboardViewRepository)
+	}
+
+	// Сгоревшие баллы списываются только при заданном сроке действия
+	var pointsExpiry service.PointsExpiryRepository
+	if cfg.PointsExpiry.TTL > 0 {
+		pointsExpiry, _ = repo.(service.PointsExpiryRepository)
+	}
+
+	userService := service.NewUserService(repo, service.Options{
+		ReservedUsernames:               cfg.Auth.ReservedUsernames,
+		PasswordHistory:                 cfg.Auth.PasswordHistory,
+		MinPasswordLength:               cfg.Auth.MinPasswordLength,
+		UsernameCase:                    cfg.Auth.UsernameCase,
+		ImportMaxBatch:                  cfg.Admin.ImportMaxBatch,
+		TaskImportMax:                   cfg.Admin.TaskImportMax,
+		MaxPointsPerTask:                cfg.AntiCheat.MaxPointsPerTask,
+		AntiCheatMaxTasks:               cfg.AntiCheat.MaxTasks,
+		AntiCheatWindow:                 cfg.AntiCheat.Window,
+		AntiCheatReject:                 cfg.AntiCheat.Reject,
+		LeaderboardCacheTTL:             cfg.Leaderboard.CacheTTL,
+		LeaderboardStreamMaxSubscribers: cfg.Leaderboard.StreamMaxSubscribers,
+		PointsToNextRank:                *cfg.Leaderboard.PointsToNextRank,
+		StatsCacheTTL:                   *cfg.Admin.StatsCacheTTL,
+		Background:                      background,
+		Audit:                           repo,
+		Notifier:                        notifier,
+		EmailTokens:                     emailTokens,
+		EmailVerificationTTL:            cfg.Email.VerificationTTL,
+		CorrelationID:                   *cfg.Webhook.CorrelationID,
+		PasswordResetTTL:                cfg.Auth.PasswordResetTTL,
+		SummaryRecentTasks:              cfg.Summary.RecentTasks,
+		LeaderboardView:                 leaderboardView,
+		LeaderboardViewRefreshInterval:  cfg.Leaderboard.MaterializedView.RefreshInterval,
+		LeaderboardViewMaxStaleness:     cfg.Leaderboard.MaterializedView.MaxStaleness,
+		PointsExpiry:                    pointsExpiry,
+		PointsExpiryInterval:            cfg.PointsExpiry.Interval,
+	}, log)
+
+	// Периодический пересчет представления таблицы лидеров и списание сгоревших баллов
+	// до остановки сервера
+	jobs, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	go userService.RunLeaderboardViewRefresh(jobs)
+	go userService.RunPointsExpiry(jobs)
 
 	// Инициализация обработчиков
 	log.Info("Initializing handlers")
-	userHandler := handlers.NewUserHandler(userService, jwtService, log)
+	userHandler := handlers.NewUserHandler(userService, jwtService, handlers.Options{
+		DefaultLeaderboardLimit: cfg.Leaderboard.DefaultLimit,
+		MaxLeaderboardLimit:     cfg.Leaderboard.MaxLimit,
+		MaxReferrerChainDepth:   cfg.Referral.MaxChainDepth,
+		ReturnVerificationToken: cfg.Email.ReturnToken,
+		APIPrefix:               cfg.Rest.BasePath + router.V1,
+	}, log)
+	configHandler := handlers.NewConfigHandler(publicConfig(cfg), log)
+	adminHandler := handlers.NewAdminHandler(userService, log)
+	drain := middleware.NewDrain(cfg.Rest.DrainRetryAfter)
+	healthHandler := handlers.NewHealthHandler(drain, log)
 
 	// Инициализация роутера
 	log.Info("Setting up router")
-	r := router.NewRouter(jwtService, userHandler, log)
+	trustedProxies, err := middleware.ParseTrustedProxies(cfg.Rest.TrustedProxies)
+	if err != nil {
+		log.Error("Invalid trusted proxies", zap.Error(err))
+		return 1
+	}
+	r := router.NewRouter(jwtService, userHandler, configHandler, adminHandler, healthHandler, router.Options{
+		AuthRateLimit:       cfg.Auth.RateLimit,
+		AuthRateLimitWindow: cfg.Auth.RateLimitWindow,
+		TrustedProxies:      trustedProxies,
+		MaxBodyBytes:        cfg.Rest.MaxBodyBytes,
+		Drain:               drain,
+		RequireJSON:         *cfg.Rest.RequireJSON,
+		BasePath:            cfg.Rest.BasePath,
+		LegacyRedirect:      *cfg.Rest.LegacyRedirect,
+		BodyLog: middleware.BodyLogOptions{
+			Enabled:       cfg.Log.Bodies.Enabled,
+			MaxBytes:      cfg.Log.Bodies.MaxBytes,
+			RedactFields:  cfg.Log.Bodies.RedactFields,
+			RedactHeaders: cfg.Log.Bodies.RedactHeaders,
+		},
+	}, log)
 	handler := r.Setup()
 
-	addr := cfg.Rest.Host + ":" + cfg.Rest.Port
-	log.Info("Server address configured", zap.String("addr", addr))
-
 	// Инициализация HTTP сервера
-	server := &http.Server{
-		Addr:         addr,
-		Handler:      handler,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
+	server, err := newServer(cfg.Rest, handler)
+	if err != nil {
+		log.Error("Failed to configure server", zap.Error(err))
+		return 1
+	}
+	addr := server.Addr
+	log.Info("Server address configured",
+		zap.String("addr", addr),
+		zap.Bool("tls", server.TLSConfig != nil),
+		zap.Duration("read_timeout", server.ReadTimeout),
+		zap.Duration("read_header_timeout", server.ReadHeaderTimeout),
+		zap.Duration("write_timeout", server.WriteTimeout),
+		zap.Duration("idle_timeout", server.IdleTimeout))
+
+	// Порт или сокет открывается до запуска горутины, чтобы ошибка остановила запуск сразу
+	listener, err := listen(cfg.Rest)
+	if err != nil {
+		log.Error("Failed to listen", zap.String("addr", addr), zap.Error(err))
+		return 1
 	}
 
+	// Ошибки серверов передаются из горутин, чтобы остановка прошла тем же путем, что и по сигналу
+	serverErrors := make(chan error, 2)
+
 	// Запуск сервера в горутине
 	go func() {
 		log.Info("Starting server", zap.String("addr", addr))
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatal("Failed to start server", zap.Error(err))
+		if err := serve(server, listener); err != nil && err != http.ErrServerClosed {
+			serverErrors <- fmt.Errorf("failed to start server: %w", err)
+		}
+	}()
+
+	// Перенаправление HTTP на HTTPS
+	redirect := newRedirectServer(cfg.Rest)
+	if redirect != nil {
+		go func() {
+			log.Info("Starting HTTP to HTTPS redirect", zap.String("addr", redirect.Addr))
+			if err := redirect.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				serverErrors <- fmt.Errorf("failed to start redirect server: %w", err)
+			}
+		}()
+	}
+
+	// Изменение уровня логирования по SIGHUP без перезапуска
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	go func() {
+		for range reload {
+			reloadLogLevel(log, logLevel, cfg.Log.Level)
 		}
 	}()
 
-	// Ожидание сигнала для graceful shutdown
+	// Ожидание сигнала или ошибки сервера для graceful shutdown
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	sig := <-quit
 
-	log.Info("Shutting down server", zap.String("signal", sig.String()))
+	exitCode := 0
+	select {
+	case sig := <-quit:
+		log.Info("Shutting down server", zap.String("signal", sig.String()))
+	case err := <-serverErrors:
+		log.Error("Server failed, shutting down", zap.Error(err))
+		exitCode = 1
+	}
+
+	// Балансировщик узнает об остановке по /readyz, пока сервер еще принимает соединения
+	drain.Start()
+	server.SetKeepAlivesEnabled(false)
+	// Потоки таблицы лидеров не завершаются сами, поэтому закрываются до ожидания запросов
+	userService.CloseLeaderboardStreams()
+	stopJobs()
+	if cfg.Rest.DrainDelay > 0 {
+		log.Info("Draining connections", zap.Duration("delay", cfg.Rest.DrainDelay))
+		time.Sleep(cfg.Rest.DrainDelay)
+	}
 
 	// Graceful shutdown
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Rest.ShutdownTimeout)
 	defer cancel()
 
-	if err := server.Shutdown(ctx); err != nil {
-		log.Fatal("Server forced to shutdown", zap.Error(err))
+	if redirect != nil {
+		if err := shutdown(ctx, redirect); err != nil {
+			log.Warn("Redirect server forced to shutdown", zap.Error(err))
+		}
+	}
+	if err := shutdown(ctx, server); err != nil {
+		log.Error("Server forced to shutdown, remaining connections closed", zap.Error(err))
+		exitCode = 1
+	}
+
+	// Ожидание фоновых операций до закрытия репозитория
+	log.Info("Draining in-flight operations", zap.Int("active", background.Active()))
+	drained, err := background.Wait(ctx)
+	if err != nil {
+		log.Warn("Shutdown timeout exceeded before in-flight operations finished",
+			zap.Int("drained", drained),
+			zap.Int("remaining", background.Active()),
+			zap.Error(err))
+	} else {
+		log.Info("In-flight operations drained", zap.Int("drained", drained))
+	}
+
+	// Доставка уже поставленных в очередь уведомлений
+	if dispatcher != nil {
+		if err := dispatcher.Close(ctx); err != nil {
+			log.Warn("Shutdown timeout exceeded before webhook queue was flushed", zap.Error(err))
+		}
 	}
 
+	if exitCode != 0 {
+		log.Warn("Server exited with errors")
+		return exitCode
+	}
 	log.Info("Server exited properly")
+	return 0
+}
+
+// publicConfig отбирает из конфигурации параметры, которые можно отдавать клиентам
+func publicConfig(cfg *config.Config) models.PublicConfig {
+	// Бонус прямому рефереру - первый уровень расписания
+	var referralBonus int
+	switch {
+	case len(cfg.Referral.Schedule) > 0:
+		referralBonus = cfg.Referral.Schedule[0]
+	case cfg.Referral.BonusPoints != nil:
+		referralBonus = *cfg.Referral.BonusPoints
+	}
+
+	return models.PublicConfig{
+		ReferralBonus:           referralBonus,
+		ReferralSchedule:        cfg.Referral.Schedule,
+		LeaderboardDefaultLimit: cfg.Leaderboard.DefaultLimit,
+		LeaderboardMaxLimit:     cfg.Leaderboard.MaxLimit,
+	}
 }
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/config"
+)
+
+func TestPublicConfigOmitsSecrets(t *testing.T) {
+	bonusPoints := 25
+	cfg := &config.Config{
+		Storage:     config.Storage{User: "postgres", Password: "db-password-value", Host: "db.internal", Port: "5432", DBName: "user_points"},
+		Rest:        config.Rest{Host: "localhost", Port: "8080"},
+		JWT:         config.JWT{SecretKey: "jwt-secret-value", TokenDuration: time.Hour},
+		Referral:    config.Referral{BonusPoints: &bonusPoints},
+		Leaderboard: config.Leaderboard{DefaultLimit: 20, MaxLimit: 50},
+		Webhook:     config.Webhook{URL: "https://hooks.internal/points", Secret: "webhook-secret-value"},
+	}
+
+	body, err := json.Marshal(publicConfig(cfg))
+	if err != nil {
+		t.Fatalf("marshal public config: %v", err)
+	}
+
+	for _, secret := range []string{"db-password-value", "jwt-secret-value", "db.internal", "webhook-secret-value", "hooks.internal"} {
+		if strings.Contains(string(body), secret) {
+			t.Errorf("public config %s exposes %q", body, secret)
+		}
+	}
+	if got := publicConfig(cfg).ReferralBonus; got != 25 {
+		t.Errorf("ReferralBonus = %d, want 25", got)
+	}
+	if got := publicConfig(cfg); got.LeaderboardDefaultLimit != 20 || got.LeaderboardMaxLimit != 50 {
+		t.Errorf("leaderboard limits = %d and %d, want the configured 20 and 50", got.LeaderboardDefaultLimit, got.LeaderboardMaxLimit)
+	}
+}
+
+func TestPublicConfigReferralSchedule(t *testing.T) {
+	bonusPoints := 25
+	cfg := &config.Config{Referral: config.Referral{BonusPoints: &bonusPoints, Schedule: []int{10, 5, 2}}}
+
+	got := publicConfig(cfg)
+	if got.ReferralBonus != 10 || !slices.Equal(got.ReferralSchedule, []int{10, 5, 2}) {
+		t.Errorf("referral bonus %d, schedule %v, want 10 and [10 5 2]", got.ReferralBonus, got.ReferralSchedule)
+	}
+}
+
+func TestRunListenFailure(t *testing.T) {
+	// Порт уже занят, поэтому сервер не может запуститься
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer busy.Close()
+	_, port, err := net.SplitHostPort(busy.Addr().String())
+	if err != nil {
+		t.Fatalf("split address: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `
+storage:
+  driver: "memory"
+rest:
+  host: "127.0.0.1"
+  port: "` + port + `"
+jwt:
+  secretkey: "run-secret"
+  tokenduration: "1h"
+log:
+  level: "error"
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	t.Setenv("CONFIG_PATH", path)
+
+	// Ошибка запуска возвращается кодом завершения, а не завершает процесс
+	if code := run(); code != 1 {
+		t.Errorf("run() = %d, want 1", code)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/config"
+	"github.com/DblMOKRQ/DeNet_test_task/internal/repository/postgres"
+	"go.uber.org/zap"
+)
+
+const migrateUsage = `Usage: app migrate <command>
+
+Commands:
+  up          apply all pending migrations
+  down        revert all applied migrations
+  steps N     apply (N > 0) or revert (N < 0) N migrations
+  version     print the current schema version
+`
+
+// migrateCommand разобранная подкоманда migrate
+type migrateCommand struct {
+	name string
+	// steps количество миграций для команды steps
+	steps int
+}
+
+// parseMigrateArgs разбирает аргументы подкоманды migrate без подключения к БД
+func parseMigrateArgs(args []string) (migrateCommand, error) {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(args); err != nil {
+		return migrateCommand{}, err
+	}
+	if fs.NArg() == 0 {
+		return migrateCommand{}, errors.New("missing command")
+	}
+
+	cmd := migrateCommand{name: fs.Arg(0)}
+	switch cmd.name {
+	case "up", "down", "version":
+	case "steps":
+		if fs.NArg() < 2 {
+			return migrateCommand{}, errors.New("missing steps value")
+		}
+		n, err := strconv.Atoi(fs.Arg(1))
+		if err != nil || n == 0 {
+			return migrateCommand{}, fmt.Errorf("invalid steps value %q", fs.Arg(1))
+		}
+		cmd.steps = n
+	default:
+		return migrateCommand{}, fmt.Errorf("unknown command %q", cmd.name)
+	}
+	return cmd, nil
+}
+
+// runMigrate выполняет подкоманду migrate и возвращает код завершения
+func runMigrate(cfg *config.Config, log *zap.Logger, args []string) int {
+	cmd, err := parseMigrateArgs(args)
+	if err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			log.Error("Invalid migrate arguments", zap.Error(err))
+		}
+		fmt.Fprint(os.Stderr, migrateUsage)
+		return 2
+	}
+	if cfg.Storage.Driver != config.StorageDriverPostgres {
+		log.Error("Migrations require the postgres storage driver", zap.String("driver", cfg.Storage.Driver))
+		return 1
+	}
+
+	connStr := postgres.ConnString(
+		cfg.Storage.User,
+		cfg.Storage.Password,
+		cfg.Storage.Host,
+		cfg.Storage.Port,
+		cfg.Storage.DBName,
+		cfg.Storage.Sslmode,
+	)
+
+	m, err := postgres.NewMigrator(connStr, log)
+	if err != nil {
+		log.Error("Failed to initialize migrator", zap.Error(err))
+		return 1
+	}
+	defer m.Close()
+
+	switch cmd.name {
+	case "up":
+		err = m.Up()
+	case "down":
+		err = m.Down()
+	case "steps":
+		err = m.Steps(cmd.steps)
+	}
+	if err != nil {
+		log.Error("Migration failed", zap.Error(err))
+		return 1
+	}
+
+	version, dirty, err := m.Version()
+	if err != nil {
+		log.Error("Failed to get schema version", zap.Error(err))
+		return 1
+	}
+
+	log.Info("Schema version", zap.Uint("version", version), zap.Bool("dirty", dirty))
+	fmt.Printf("schema version: %d (dirty: %t)\n", version, dirty)
+	return 0
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"testing"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/config"
+	"go.uber.org/zap"
+)
+
+func TestParseMigrateArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    migrateCommand
+		wantErr bool
+	}{
+		{name: "up", args: []string{"up"}, want: migrateCommand{name: "up"}},
+		{name: "down", args: []string{"down"}, want: migrateCommand{name: "down"}},
+		{name: "version", args: []string{"version"}, want: migrateCommand{name: "version"}},
+		{name: "steps forward", args: []string{"steps", "2"}, want: migrateCommand{name: "steps", steps: 2}},
+		{name: "steps back", args: []string{"steps", "-1"}, want: migrateCommand{name: "steps", steps: -1}},
+		{name: "no command", args: nil, wantErr: true},
+		{name: "unknown command", args: []string{"redo"}, wantErr: true},
+		{name: "steps without value", args: []string{"steps"}, wantErr: true},
+		{name: "steps not a number", args: []string{"steps", "two"}, wantErr: true},
+		{name: "zero steps", args: []string{"steps", "0"}, wantErr: true},
+		{name: "unknown flag", args: []string{"-force", "up"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseMigrateArgs(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseMigrateArgs(%q) error = %v, wantErr %v", tt.args, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseMigrateArgs(%q) = %+v, want %+v", tt.args, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMigrateArgsHelp(t *testing.T) {
+	if _, err := parseMigrateArgs([]string{"-h"}); !errors.Is(err, flag.ErrHelp) {
+		t.Errorf("parseMigrateArgs(-h) error = %v, want %v", err, flag.ErrHelp)
+	}
+}
+
+func TestRunMigrateExitCodes(t *testing.T) {
+	// Ни один из случаев не подключается к БД
+	tests := []struct {
+		name   string
+		driver string
+		args   []string
+		want   int
+	}{
+		{name: "usage error", args: []string{"redo"}, want: 2},
+		{name: "invalid steps", args: []string{"steps", "x"}, want: 2},
+		{name: "no command", args: nil, want: 2},
+		{name: "memory driver", driver: config.StorageDriverMemory, args: []string{"up"}, want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{Storage: config.Storage{Driver: tt.driver}}
+			if got := runMigrate(cfg, zap.NewNop(), tt.args); got != tt.want {
+				t.Errorf("runMigrate(%q) = %d, want %d", tt.args, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"github.com/DblMOKRQ/DeNet_test_task/internal/config"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// reloadLogLevel перечитывает уровень логирования из конфигурации (LOG_LEVEL имеет приоритет)
+// и применяет его к работающему логгеру. Если конфигурацию не удалось загрузить,
+// используется уровень, заданный при запуске.
+func reloadLogLevel(log *zap.Logger, level zap.AtomicLevel, startup string) {
+	configured := startup
+	if cfg, err := config.Load(config.Path()); err != nil {
+		log.Warn("Failed to reload config, using startup log level",
+			zap.String("level", startup),
+			zap.Error(err))
+	} else {
+		configured = cfg.Log.Level
+	}
+
+	previous, current, err := logger.ReloadLevel(level, configured)
+	if err != nil {
+		log.Warn("Log level not changed", zap.Error(err))
+		return
+	}
+
+	// Уровень warn, чтобы изменение было видно и при повышенном уровне логирования
+	log.Warn("Log level reloaded",
+		zap.String("previous", previous.String()),
+		zap.String("level", current.String()))
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// writeTestConfig записывает минимальную корректную конфигурацию с уровнем логирования level
+// и указывает на нее CONFIG_PATH
+func writeTestConfig(t *testing.T, level string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `
+storage: {user: postgres, password: secret, host: localhost, port: "5432", dbname: user_points}
+rest: {host: localhost, port: "8080"}
+jwt: {secretkey: test-secret, tokenduration: 1h}
+log: {level: ` + level + `}
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	t.Setenv("CONFIG_PATH", path)
+}
+
+func TestReloadLogLevel(t *testing.T) {
+	t.Setenv("LOG_LEVEL", "")
+
+	tests := []struct {
+		name string
+		// config уровень в файле конфигурации; пусто - файл отсутствует
+		config  string
+		startup string
+		want    zapcore.Level
+		wantLog string
+	}{
+		{name: "level from reloaded config", config: "debug", startup: "info", want: zapcore.DebugLevel, wantLog: "Log level reloaded"},
+		{name: "missing config falls back to startup level", startup: "warn", want: zapcore.WarnLevel, wantLog: "Failed to reload config, using startup log level"},
+		{name: "unknown level is ignored", config: "verbose", startup: "info", want: zapcore.InfoLevel, wantLog: "Log level not changed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.config != "" {
+				writeTestConfig(t, tt.config)
+			} else {
+				t.Setenv("CONFIG_PATH", filepath.Join(t.TempDir(), "missing.yaml"))
+			}
+
+			core, logs := observer.New(zapcore.WarnLevel)
+			level := zap.NewAtomicLevelAt(zapcore.InfoLevel)
+			reloadLogLevel(zap.New(core), level, tt.startup)
+
+			if level.Level() != tt.want {
+				t.Errorf("level = %s, want %s", level.Level(), tt.want)
+			}
+			if logs.FilterMessage(tt.wantLog).Len() == 0 {
+				t.Errorf("log entries = %v, want %q", logs.All(), tt.wantLog)
+			}
+		})
+	}
+}
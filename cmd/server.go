@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/config"
+)
+
+// newServer создает HTTP сервер с таймаутами из конфигурации. Если TLS включен,
+// сертификат загружается сразу, чтобы ошибка в нем остановила запуск.
+func newServer(rest config.Rest, handler http.Handler) (*http.Server, error) {
+	server := &http.Server{
+		Addr:              rest.Addr(),
+		Handler:           handler,
+		ReadTimeout:       rest.ReadTimeout,
+		ReadHeaderTimeout: rest.ReadHeaderTimeout,
+		WriteTimeout:      rest.WriteTimeout,
+		IdleTimeout:       rest.IdleTimeout,
+	}
+	if rest.TLS.Enabled() {
+		tlsConfig, err := newTLSConfig(rest.TLS)
+		if err != nil {
+			return nil, err
+		}
+		server.TLSConfig = tlsConfig
+	}
+	return server, nil
+}
+
+// newTLSConfig создает консервативную конфигурацию TLS: не ниже TLS 1.2, для TLS 1.2
+// только наборы шифров ECDHE с AEAD (прямая секретность), кривые X25519 и P-256.
+// Наборы шифров TLS 1.3 не настраиваются и выбираются стандартной библиотекой.
+func newTLSConfig(cfg config.TLS) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+
+	minVersion := uint16(tls.VersionTLS12)
+	if cfg.MinVersion == "1.3" {
+		minVersion = tls.VersionTLS13
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   minVersion,
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+		},
+		CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256},
+	}, nil
+}
+
+// serve обслуживает соединения listener по HTTPS, если TLS включен, иначе по HTTP
+func serve(server *http.Server, listener net.Listener) error {
+	if server.TLSConfig != nil {
+		// Сертификат уже загружен в TLSConfig
+		return server.ServeTLS(listener, "", "")
+	}
+	return server.Serve(listener)
+}
+
+// shutdown останавливает server, дожидаясь завершения активных запросов до истечения ctx.
+// Если запросы не завершились в срок, оставшиеся соединения закрываются принудительно;
+// возвращается ошибка ожидания, чтобы процесс завершился с ненулевым кодом.
+func shutdown(ctx context.Context, server *http.Server) error {
+	err := server.Shutdown(ctx)
+	if err == nil {
+		return nil
+	}
+	if closeErr := server.Close(); closeErr != nil {
+		return errors.Join(err, closeErr)
+	}
+	return err
+}
+
+// newRedirectServer создает HTTP сервер на порту rest.tls.redirect_port, перенаправляющий
+// запросы на тот же путь по HTTPS; возвращает nil, если перенаправление не настроено
+func newRedirectServer(rest config.Rest) *http.Server {
+	if rest.TLS.RedirectPort == "" {
+		return nil
+	}
+	return &http.Server{
+		Addr: net.JoinHostPort(rest.Host, rest.TLS.RedirectPort),
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			host, _, err := net.SplitHostPort(r.Host)
+			if err != nil {
+				host = r.Host
+			}
+			if rest.Port != "443" {
+				host = net.JoinHostPort(host, rest.Port)
+			}
+			http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+		}),
+		ReadTimeout:       rest.ReadTimeout,
+		ReadHeaderTimeout: rest.ReadHeaderTimeout,
+		WriteTimeout:      rest.WriteTimeout,
+		IdleTimeout:       rest.IdleTimeout,
+	}
+}
+
+// listen открывает TCP порт host:port или, если rest.host задает путь, Unix сокет.
+// Файл сокета, оставшийся от предыдущего запуска, удаляется; файл сокета, созданный
+// здесь, удаляется при закрытии слушателя во время остановки сервера.
+func listen(rest config.Rest) (net.Listener, error) {
+	if !rest.UnixSocket() {
+		return net.Listen("tcp", rest.Addr())
+	}
+
+	mode, err := rest.SocketFileMode()
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Lstat(rest.Host); err == nil {
+		// Удаляется только сокет, чтобы опечатка в пути не стерла обычный файл
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", rest.Host)
+		}
+		if err := os.Remove(rest.Host); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket %s: %w", rest.Host, err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	listener, err := net.Listen("unix", rest.Host)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(rest.Host, mode); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to set socket permissions: %w", err)
+	}
+	return listener, nil
+}
@@ -0,0 +1,394 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/config"
+)
+
+func TestNewServerTimeouts(t *testing.T) {
+	rest := config.Rest{
+		Host:              "localhost",
+		Port:              "8080",
+		ReadTimeout:       11 * time.Second,
+		ReadHeaderTimeout: 3 * time.Second,
+		WriteTimeout:      12 * time.Second,
+		IdleTimeout:       time.Minute,
+	}
+
+	server, err := newServer(rest, http.NotFoundHandler())
+	if err != nil {
+		t.Fatalf("newServer() error = %v", err)
+	}
+
+	if server.TLSConfig != nil {
+		t.Error("TLSConfig set without a certificate, want plain HTTP")
+	}
+	if server.Addr != "localhost:8080" {
+		t.Errorf("Addr = %q, want %q", server.Addr, "localhost:8080")
+	}
+	if server.ReadTimeout != rest.ReadTimeout || server.ReadHeaderTimeout != rest.ReadHeaderTimeout ||
+		server.WriteTimeout != rest.WriteTimeout || server.IdleTimeout != rest.IdleTimeout {
+		t.Errorf("timeouts = %s/%s/%s/%s, want %s/%s/%s/%s",
+			server.ReadTimeout, server.ReadHeaderTimeout, server.WriteTimeout, server.IdleTimeout,
+			rest.ReadTimeout, rest.ReadHeaderTimeout, rest.WriteTimeout, rest.IdleTimeout)
+	}
+}
+
+func TestNewServerClosesSlowHeaders(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	server, err := newServer(config.Rest{ReadHeaderTimeout: 100 * time.Millisecond}, http.NotFoundHandler())
+	if err != nil {
+		t.Fatalf("newServer() error = %v", err)
+	}
+	go server.Serve(listener)
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer conn.Close()
+
+	// Клиент начинает запрос, но не завершает заголовки
+	if _, err := conn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := conn.SetReadDeadline(time.Now().Add(2 * time.Second)); err != nil {
+		t.Fatalf("SetReadDeadline() error = %v", err)
+	}
+	// Сервер может ответить ошибкой или просто закрыть соединение, но не должен ждать дольше таймаута
+	_, err = bufio.NewReader(conn).ReadString('\n')
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		t.Fatal("server kept the connection with incomplete headers open past read_header_timeout")
+	}
+}
+
+func TestShutdownClosesHungConnections(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	started, released := make(chan struct{}), make(chan struct{})
+	// Обработчик не завершается сам и освобождается только при закрытии соединения
+	hung := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+		close(released)
+	})
+	server, err := newServer(config.Rest{}, hung)
+	if err != nil {
+		t.Fatalf("newServer() error = %v", err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- serve(server, listener) }()
+
+	clientErr := make(chan error, 1)
+	go func() {
+		resp, err := http.Get("http://" + listener.Addr().String())
+		if err == nil {
+			resp.Body.Close()
+		}
+		clientErr <- err
+	}()
+	select {
+	case <-started:
+	case <-time.After(2 * time.Second):
+		t.Fatal("request did not reach the handler")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := shutdown(ctx, server); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("shutdown() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	// После истечения срока соединение закрыто принудительно: обработчик и клиент освобождены
+	select {
+	case <-released:
+	case <-time.After(2 * time.Second):
+		t.Fatal("hung handler was not released after shutdown")
+	}
+	select {
+	case err := <-clientErr:
+		if err == nil {
+			t.Error("client got a response, want the connection closed")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("client request still pending after shutdown")
+	}
+	if err := <-served; !errors.Is(err, http.ErrServerClosed) {
+		t.Errorf("serve() error = %v, want %v", err, http.ErrServerClosed)
+	}
+}
+
+func TestShutdownIdle(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	server, err := newServer(config.Rest{}, http.NotFoundHandler())
+	if err != nil {
+		t.Fatalf("newServer() error = %v", err)
+	}
+	go serve(server, listener)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := shutdown(ctx, server); err != nil {
+		t.Errorf("shutdown() error = %v, want nil without active requests", err)
+	}
+}
+
+// socketPath возвращает путь к сокету в короткой временной директории:
+// длина пути Unix сокета ограничена примерно 100 байтами
+func socketPath(t *testing.T) string {
+	t.Helper()
+
+	dir, err := os.MkdirTemp("", "sock")
+	if err != nil {
+		t.Fatalf("MkdirTemp() error = %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return filepath.Join(dir, "app.sock")
+}
+
+func TestListenUnixSocket(t *testing.T) {
+	path := socketPath(t)
+
+	// Сокет, оставшийся после аварийного завершения предыдущего процесса
+	stale, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		t.Fatalf("ListenUnix() error = %v", err)
+	}
+	stale.SetUnlinkOnClose(false)
+	stale.Close()
+
+	listener, err := listen(config.Rest{Host: path, SocketMode: "0600"})
+	if err != nil {
+		t.Fatalf("listen() error = %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+	if info.Mode()&os.ModeSocket == 0 || info.Mode().Perm() != 0o600 {
+		t.Errorf("socket mode = %s, want socket with 0600", info.Mode())
+	}
+
+	server, err := newServer(config.Rest{Host: path}, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	if err != nil {
+		t.Fatalf("newServer() error = %v", err)
+	}
+	go server.Serve(listener)
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+	resp, err := client.Get("http://unix/")
+	if err != nil {
+		t.Fatalf("GET over socket error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTeapot {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusTeapot)
+	}
+
+	server.Close()
+	if _, err := os.Lstat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("socket file after shutdown: %v, want removed", err)
+	}
+}
+
+func TestListenUnixSocketErrors(t *testing.T) {
+	t.Run("regular file kept", func(t *testing.T) {
+		path := socketPath(t)
+		if err := os.WriteFile(path, []byte("data"), 0o600); err != nil {
+			t.Fatalf("WriteFile() error = %v", err)
+		}
+
+		if _, err := listen(config.Rest{Host: path, SocketMode: "0660"}); err == nil || !strings.Contains(err.Error(), "not a socket") {
+			t.Errorf("listen() error = %v, want not a socket", err)
+		}
+		if data, err := os.ReadFile(path); err != nil || string(data) != "data" {
+			t.Errorf("regular file = %q, %v, want it untouched", data, err)
+		}
+	})
+
+	t.Run("invalid mode", func(t *testing.T) {
+		if _, err := listen(config.Rest{Host: socketPath(t), SocketMode: "0999"}); err == nil {
+			t.Error("listen() error = nil, want invalid socket mode")
+		}
+	})
+}
+
+// writeTestCertificate создает самоподписанный сертификат для localhost и возвращает пути
+// к файлам сертификата и ключа в формате PEM
+func writeTestCertificate(t *testing.T) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate() error = %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("MarshalECPrivateKey() error = %v", err)
+	}
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("write certificate: %v", err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+	return certFile, keyFile
+}
+
+func TestNewServerTLS(t *testing.T) {
+	certFile, keyFile := writeTestCertificate(t)
+
+	tests := []struct {
+		name       string
+		minVersion string
+		// client настройки клиента, кроме проверки сертификата
+		client  *tls.Config
+		wantErr bool
+	}{
+		{name: "TLS 1.3", minVersion: "1.2", client: &tls.Config{MinVersion: tls.VersionTLS13}},
+		{
+			name:       "TLS 1.2 with AEAD suite",
+			minVersion: "1.2",
+			client:     &tls.Config{MaxVersion: tls.VersionTLS12, CipherSuites: []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256}},
+		},
+		{
+			name:       "TLS 1.2 with CBC suite",
+			minVersion: "1.2",
+			client:     &tls.Config{MaxVersion: tls.VersionTLS12, CipherSuites: []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA}},
+			wantErr:    true,
+		},
+		{name: "TLS 1.1", minVersion: "1.2", client: &tls.Config{MinVersion: tls.VersionTLS10, MaxVersion: tls.VersionTLS11}, wantErr: true},
+		{name: "TLS 1.2 below minimum 1.3", minVersion: "1.3", client: &tls.Config{MaxVersion: tls.VersionTLS12}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rest := config.Rest{Host: "127.0.0.1", TLS: config.TLS{CertFile: certFile, KeyFile: keyFile, MinVersion: tt.minVersion}}
+			server, err := newServer(rest, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			if err != nil {
+				t.Fatalf("newServer() error = %v", err)
+			}
+			// Ожидаемые ошибки рукопожатия не выводятся в вывод тестов
+			server.ErrorLog = log.New(io.Discard, "", 0)
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("Listen() error = %v", err)
+			}
+			go serve(server, listener)
+			defer server.Close()
+
+			clientConfig := tt.client.Clone()
+			clientConfig.InsecureSkipVerify = true
+			client := &http.Client{Transport: &http.Transport{TLSClientConfig: clientConfig}}
+			resp, err := client.Get("https://" + listener.Addr().String() + "/")
+			if tt.wantErr {
+				if err == nil {
+					resp.Body.Close()
+					t.Fatal("request succeeded, want handshake failure")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GET error = %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusNoContent {
+				t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusNoContent)
+			}
+		})
+	}
+}
+
+func TestNewServerTLSInvalidCertificate(t *testing.T) {
+	rest := config.Rest{TLS: config.TLS{CertFile: filepath.Join(t.TempDir(), "missing.pem"), KeyFile: "key.pem", MinVersion: "1.2"}}
+
+	if _, err := newServer(rest, http.NotFoundHandler()); err == nil || !strings.Contains(err.Error(), "failed to load TLS certificate") {
+		t.Errorf("newServer() error = %v, want certificate load error", err)
+	}
+}
+
+func TestNewRedirectServer(t *testing.T) {
+	if newRedirectServer(config.Rest{Port: "8443"}) != nil {
+		t.Error("newRedirectServer() without redirect_port != nil, want no redirect server")
+	}
+
+	tests := []struct {
+		name string
+		port string
+		want string
+	}{
+		{name: "custom port", port: "8443", want: "https://example.com:8443/api/v1/users?limit=5"},
+		{name: "default https port", port: "443", want: "https://example.com/api/v1/users?limit=5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redirect := newRedirectServer(config.Rest{Host: "localhost", Port: tt.port, TLS: config.TLS{RedirectPort: "8080"}})
+			if redirect.Addr != "localhost:8080" {
+				t.Errorf("Addr = %q, want localhost:8080", redirect.Addr)
+			}
+
+			rec := httptest.NewRecorder()
+			redirect.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "http://example.com:8080/api/v1/users?limit=5", nil))
+			if rec.Code != http.StatusPermanentRedirect || rec.Header().Get("Location") != tt.want {
+				t.Errorf("redirect = %d %q, want %d %q", rec.Code, rec.Header().Get("Location"), http.StatusPermanentRedirect, tt.want)
+			}
+		})
+	}
+}
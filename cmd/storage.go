@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/config"
+	"github.com/DblMOKRQ/DeNet_test_task/internal/repository/memory"
+	"github.com/DblMOKRQ/DeNet_test_task/internal/repository/postgres"
+	"github.com/DblMOKRQ/DeNet_test_task/internal/service"
+	"go.uber.org/zap"
+)
+
+// newStorage создает хранилище, выбранное параметром storage.driver
+func newStorage(cfg *config.Config, log *zap.Logger) (service.StorageRepository, error) {
+	switch cfg.Storage.Driver {
+	case config.StorageDriverPostgres:
+		return postgres.NewRepository(
+			cfg.Storage.User,
+			cfg.Storage.Password,
+			cfg.Storage.Host,
+			cfg.Storage.Port,
+			cfg.Storage.DBName,
+			cfg.Storage.Sslmode,
+			postgres.Options{
+				ConsistentLeaderboard: cfg.Leaderboard.ConsistentSnapshot,
+				LeaderboardTiebreaker: cfg.Leaderboard.Tiebreaker,
+				ReferralSchedule:      cfg.Referral.Schedule,
+				StreakBonus:           cfg.Streak.BonusPoints,
+				QueryTimeout:          *cfg.Storage.QueryTimeout,
+				PointsTTL:             cfg.PointsExpiry.TTL,
+			},
+			log,
+		)
+	case config.StorageDriverMemory:
+		return memory.NewRepository(memory.Options{
+			LeaderboardTiebreaker: cfg.Leaderboard.Tiebreaker,
+			ReferralSchedule:      cfg.Referral.Schedule,
+			StreakBonus:           cfg.Streak.BonusPoints,
+			PointsTTL:             cfg.PointsExpiry.TTL,
+		}, log), nil
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.Storage.Driver)
+	}
+}
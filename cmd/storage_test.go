@@ -0,0 +1,23 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/config"
+	"github.com/DblMOKRQ/DeNet_test_task/internal/repository/memory"
+	"go.uber.org/zap"
+)
+
+func TestNewStorage(t *testing.T) {
+	repo, err := newStorage(&config.Config{Storage: config.Storage{Driver: config.StorageDriverMemory}}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("newStorage(memory) error = %v", err)
+	}
+	if _, ok := repo.(*memory.Repository); !ok {
+		t.Errorf("newStorage(memory) = %T, want *memory.Repository", repo)
+	}
+
+	if _, err := newStorage(&config.Config{Storage: config.Storage{Driver: "sqlite"}}, zap.NewNop()); err == nil {
+		t.Error("newStorage(sqlite) error = nil, want error")
+	}
+}
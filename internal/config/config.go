@@ -1,53 +1,724 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
-	"gopkg.in/yaml.v2"
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/validate"
 )
 
 type Config struct {
-	Storage `yaml:"storage" env-required:"true"`
-	Rest    `yaml:"rest" env-required:"true"`
-	JWT     `yaml:"jwt" env-required:"true"`
+	Storage      `yaml:"storage" env-required:"true"`
+	Rest         `yaml:"rest" env-required:"true"`
+	JWT          `yaml:"jwt" env-required:"true"`
+	Auth         `yaml:"auth"`
+	Leaderboard  `yaml:"leaderboard"`
+	Referral     `yaml:"referral"`
+	Streak       `yaml:"streak"`
+	PointsExpiry `yaml:"points_expiry"`
+	Admin        `yaml:"admin"`
+	AntiCheat    `yaml:"anti_cheat"`
+	Webhook      `yaml:"webhook"`
+	Email        `yaml:"email"`
+	Summary      `yaml:"summary"`
+	Log          `yaml:"log"`
 }
 
 type Storage struct {
+	// Driver хранилище данных: postgres или memory (данные в памяти процесса, без сохранения)
+	Driver   string `yaml:"driver" env-default:"postgres"`
 	User     string `yaml:"user" env-required:"true"`
 	Password string `yaml:"password" env-required:"true"`
 	Host     string `yaml:"host" env-required:"true"`
 	Port     string `yaml:"port" env-required:"true"`
 	DBName   string `yaml:"dbname" env-required:"true"`
-	Sslmode  string `yaml:"sslmode" env-default:"false"`
+	Sslmode  string `yaml:"sslmode" env-default:"disable"`
+	// QueryTimeout ограничивает время выполнения вызова репозитория, если у контекста
+	// запроса нет собственного срока (0 - без ограничения)
+	QueryTimeout *time.Duration `yaml:"query_timeout" env-default:"5s"`
 }
 type Rest struct {
+	// Host адрес интерфейса для TCP или путь к Unix сокету, если начинается с "/";
+	// для сокета Port не используется
 	Host string `yaml:"host" env-required:"true"`
 	Port string `yaml:"port" env-required:"true"`
+	// TrustedProxies адреса и сети (CIDR) прокси, которым разрешено передавать адрес клиента
+	// в X-Forwarded-For; пустой список означает, что заголовок игнорируется
+	TrustedProxies []string `yaml:"trusted_proxies"`
+	// SocketMode права доступа к файлу Unix сокета в восьмеричной записи
+	SocketMode string `yaml:"socket_mode" env-default:"0660"`
+	// MaxBodyBytes максимальный размер тела запроса в байтах
+	MaxBodyBytes int64 `yaml:"max_body_bytes" env-default:"1048576"`
+
+	ReadTimeout time.Duration `yaml:"read_timeout" env-default:"15s"`
+	// ReadHeaderTimeout ограничивает чтение заголовков и защищает от медленных клиентов (Slowloris)
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout" env-default:"5s"`
+	WriteTimeout      time.Duration `yaml:"write_timeout" env-default:"15s"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout" env-default:"60s"`
+	// ShutdownTimeout время ожидания завершения запросов и фоновых операций при остановке
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env-default:"10s"`
+	// DrainDelay время между началом остановки и закрытием сервера: /readyz уже отвечает 503,
+	// а новые запросы отклоняются с Retry-After, пока балансировщик не перестанет их направлять
+	DrainDelay time.Duration `yaml:"drain_delay" env-default:"0s"`
+	// DrainRetryAfter значение Retry-After для запросов, отклоненных при остановке
+	DrainRetryAfter time.Duration `yaml:"drain_retry_after" env-default:"5s"`
+	// RequireJSON отклонять запросы с телом не в формате application/json (415); по умолчанию включено
+	RequireJSON *bool `yaml:"require_json" env-default:"true"`
+	// BasePath префикс маршрутов API; маршруты версии v1 доступны под BasePath + "/v1"
+	BasePath string `yaml:"base_path" env-default:"/api"`
+	// LegacyRedirect перенаправлять (308) пути без префикса на v1; переходная настройка,
+	// по умолчанию включена на время перехода клиентов на версионированные пути
+	LegacyRedirect *bool `yaml:"legacy_redirect" env-default:"true"`
+	// TLS настройки HTTPS; по умолчанию сервер работает по HTTP
+	TLS TLS `yaml:"tls"`
+}
+
+// TLS содержит настройки HTTPS. TLS включается, если заданы CertFile и KeyFile.
+type TLS struct {
+	// CertFile и KeyFile пути к сертификату (с цепочкой) и закрытому ключу в формате PEM
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+	// MinVersion минимальная версия протокола: 1.2 или 1.3
+	MinVersion string `yaml:"min_version" env-default:"1.2"`
+	// RedirectPort порт, на котором HTTP запросы перенаправляются на HTTPS (пусто - не слушать HTTP)
+	RedirectPort string `yaml:"redirect_port"`
+}
+
+// Enabled возвращает true, если заданы сертификат и ключ
+func (t TLS) Enabled() bool {
+	return t.CertFile != "" && t.KeyFile != ""
+}
+
+// tlsVersions допустимые значения rest.tls.min_version
+var tlsVersions = []string{"1.2", "1.3"}
+
+// UnixSocket возвращает true, если сервер слушает Unix сокет по пути Host
+func (r Rest) UnixSocket() bool {
+	return strings.HasPrefix(r.Host, "/")
+}
+
+// Addr возвращает адрес для вывода в лог: путь к сокету или host:port
+func (r Rest) Addr() string {
+	if r.UnixSocket() {
+		return r.Host
+	}
+	return r.Host + ":" + r.Port
 }
+
+// SocketFileMode разбирает SocketMode
+func (r Rest) SocketFileMode() (os.FileMode, error) {
+	mode, err := strconv.ParseUint(r.SocketMode, 8, 32)
+	if err != nil || mode > 0o777 {
+		return 0, fmt.Errorf("rest.socket_mode must be an octal permission between 0000 and 0777, got %q", r.SocketMode)
+	}
+	return os.FileMode(mode), nil
+}
+
 type JWT struct {
-	SecretKey     string        `yaml:"secretkey" env-required:"true"`
+	// Algorithm алгоритм подписи токенов: HS256, RS256 или ES256
+	Algorithm     string        `yaml:"algorithm" env-default:"HS256"`
+	SecretKey     string        `yaml:"secretkey"`
 	TokenDuration time.Duration `yaml:"tokenduration" env-required:"true"`
+	// MinTokenDuration и MaxTokenDuration допустимый диапазон TokenDuration: защищают от опечаток,
+	// при которых выдаются почти бессрочные или сразу истекающие токены
+	MinTokenDuration time.Duration `yaml:"min_token_duration" env-default:"1m"`
+	MaxTokenDuration time.Duration `yaml:"max_token_duration" env-default:"720h"`
+	// PrivateKeyPath и PublicKeyPath пути к PEM ключам для RS256/ES256
+	PrivateKeyPath string `yaml:"private_key_path"`
+	PublicKeyPath  string `yaml:"public_key_path"`
+	// Issuer и Audience значения claims iss и aud; пустое значение отключает проверку
+	Issuer   string `yaml:"issuer"`
+	Audience string `yaml:"audience"`
+	// Leeway допустимое расхождение часов при проверке срока действия токена
+	Leeway *time.Duration `yaml:"leeway" env-default:"30s"`
+}
+
+// Log содержит параметры вывода логов
+type Log struct {
+	Level string `yaml:"level" env-default:"info"`
+	// Output назначение вывода: stdout, stderr или путь к файлу с ротацией
+	Output     string `yaml:"output" env-default:"stdout"`
+	MaxSizeMB  int    `yaml:"max_size_mb" env-default:"100"`
+	MaxBackups int    `yaml:"max_backups" env-default:"3"`
+	MaxAgeDays int    `yaml:"max_age_days" env-default:"28"`
+	// Development режим разработки: сэмплирование по умолчанию отключено
+	Development bool        `yaml:"development" env-default:"false"`
+	Sampling    LogSampling `yaml:"sampling"`
+	// Bodies отладочное логирование тел запросов и ответов
+	Bodies LogBodies `yaml:"bodies"`
+}
+
+// LogBodies содержит настройки логирования тел запросов и ответов.
+// Тела пишутся, только если включено Enabled и уровень логирования debug.
+type LogBodies struct {
+	Enabled bool `yaml:"enabled" env-default:"false"`
+	// MaxBytes максимальный размер тела в записи лога
+	MaxBytes int `yaml:"max_bytes" env-default:"4096"`
+	// RedactFields поля JSON, значения которых скрываются
+	RedactFields []string `yaml:"redact_fields"`
+	// RedactHeaders заголовки, значения которых скрываются
+	RedactHeaders []string `yaml:"redact_headers"`
+}
+
+// LogSampling содержит настройки сэмплирования логов
+type LogSampling struct {
+	// Enabled включает сэмплирование; если не задано - включено вне режима разработки
+	Enabled    *bool `yaml:"enabled"`
+	Initial    int   `yaml:"initial" env-default:"100"`
+	Thereafter int   `yaml:"thereafter" env-default:"100"`
+}
+
+// Auth содержит настройки публичных эндпоинтов аутентификации
+type Auth struct {
+	ReservedUsernames []string      `yaml:"reserved_usernames"`
+	RateLimit         int           `yaml:"rate_limit" env-default:"10"`
+	RateLimitWindow   time.Duration `yaml:"rate_limit_window" env-default:"1m"`
+	// PasswordHistory количество предыдущих паролей, запрещенных к повторному использованию (0 - без ограничений)
+	PasswordHistory int `yaml:"password_history" env-default:"0"`
+	// MinPasswordLength минимальная длина пароля
+	MinPasswordLength int `yaml:"min_password_length" env-default:"8"`
+	// PasswordResetTTL срок действия одноразового токена сброса пароля
+	PasswordResetTTL time.Duration `yaml:"password_reset_ttl" env-default:"1h"`
+	// UsernameCase форма сохраняемого имени: preserve (как указано) или lower (нижний регистр);
+	// имена уникальны без учета регистра в обоих случаях
+	UsernameCase string `yaml:"username_case" env-default:"preserve"`
+}
+
+// Leaderboard содержит настройки таблицы лидеров
+type Leaderboard struct {
+	// ConsistentSnapshot выполняет подсчет и выборку страницы в одной транзакции
+	// REPEATABLE READ: итог и страница согласованы ценой удержания снимка на время запроса
+	ConsistentSnapshot bool `yaml:"consistent_snapshot" env-default:"false"`
+	// CacheTTL время жизни кеша страниц таблицы лидеров (0 - без кеширования)
+	CacheTTL time.Duration `yaml:"cache_ttl" env-default:"0"`
+	// DefaultLimit размер страницы таблицы лидеров, если limit не указан в запросе
+	DefaultLimit int `yaml:"default_limit" env-default:"10"`
+	// MaxLimit максимальный размер страницы таблицы лидеров
+	MaxLimit int `yaml:"max_limit" env-default:"100"`
+	// Tiebreaker порядок пользователей с равным балансом: created_at (раньше зарегистрированные
+	// выше) или username (по имени)
+	Tiebreaker string `yaml:"tiebreaker" env-default:"created_at"`
+	// StreamMaxSubscribers максимальное количество одновременных подписчиков
+	// потока изменений таблицы лидеров
+	StreamMaxSubscribers int `yaml:"stream_max_subscribers" env-default:"100"`
+	// PointsToNextRank добавляет в статус пользователя points_to_next_rank - баллы,
+	// недостающие до места выше
+	PointsToNextRank *bool `yaml:"points_to_next_rank" env-default:"true"`
+	// MaterializedView настройки чтения таблицы лидеров из материализованного представления
+	MaterializedView LeaderboardView `yaml:"materialized_view"`
+}
+
+// LeaderboardView содержит настройки материализованного представления таблицы лидеров
+type LeaderboardView struct {
+	// Enabled включает чтение таблицы лидеров из представления leaderboard_mv (только storage.driver: postgres)
+	Enabled bool `yaml:"enabled" env-default:"false"`
+	// RefreshInterval период пересчета представления
+	RefreshInterval time.Duration `yaml:"refresh_interval" env-default:"1m"`
+	// MaxStaleness возраст представления, после которого таблица лидеров читается из таблицы пользователей
+	MaxStaleness time.Duration `yaml:"max_staleness" env-default:"5m"`
+}
+
+// Referral содержит настройки реферальной программы
+type Referral struct {
+	// BonusPoints бонус прямому рефереру, если Schedule не задан (0 отключает бонус)
+	BonusPoints *int `yaml:"bonus_points" env-default:"10"`
+	// Schedule бонусы по уровням цепочки рефереров, начиная с прямого реферера
+	Schedule []int `yaml:"schedule"`
+	// MaxChainDepth максимальная глубина цепочки рефереров
+	MaxChainDepth int `yaml:"max_chain_depth" env-default:"5"`
+}
+
+// Admin содержит настройки административных операций
+type Admin struct {
+	// ImportMaxBatch максимальное количество пользователей в одном запросе импорта
+	ImportMaxBatch int `yaml:"import_max_batch" env-default:"1000"`
+	// TaskImportMax максимальное количество записей в одном запросе потокового импорта заданий
+	TaskImportMax int `yaml:"task_import_max" env-default:"10000"`
+	// StatsCacheTTL время жизни кеша общих показателей GET /admin/stats (0 - без кеширования)
+	StatsCacheTTL *time.Duration `yaml:"stats_cache_ttl" env-default:"30s"`
+}
+
+// AntiCheat содержит настройки обнаружения подозрительной активности при выполнении заданий
+type AntiCheat struct {
+	// MaxPointsPerTask максимальное количество баллов за одно задание (0 - без ограничения)
+	MaxPointsPerTask int `yaml:"max_points_per_task" env-default:"0"`
+	// MaxTasks количество заданий за окно, после которого активность считается подозрительной (0 - отключено)
+	MaxTasks int           `yaml:"max_tasks" env-default:"0"`
+	Window   time.Duration `yaml:"window" env-default:"1m"`
+	// Reject отклонять задания сверх лимита с 429, а не только записывать в лог
+	Reject bool `yaml:"reject" env-default:"false"`
+}
+
+// Webhook содержит настройки уведомлений о начислении баллов
+type Webhook struct {
+	// URL адрес получателя уведомлений (пустое значение отключает уведомления)
+	URL string `yaml:"url"`
+	// Secret ключ HMAC-SHA256 подписи тела запроса, обязателен при заданном URL
+	Secret      string        `yaml:"secret"`
+	Workers     int           `yaml:"workers" env-default:"2"`
+	QueueSize   int           `yaml:"queue_size" env-default:"1000"`
+	MaxAttempts int           `yaml:"max_attempts" env-default:"5"`
+	Backoff     time.Duration `yaml:"backoff" env-default:"500ms"`
+	Timeout     time.Duration `yaml:"timeout" env-default:"5s"`
+	// CorrelationID передавать идентификатор исходного запроса в поле correlation_id событий
+	// и заголовке X-Correlation-ID; по умолчанию включено
+	CorrelationID *bool `yaml:"correlation_id" env-default:"true"`
+}
+
+// Email содержит настройки подтверждения адреса электронной почты
+type Email struct {
+	// VerificationSecret ключ подписи токенов подтверждения; пустое значение - случайный ключ,
+	// и выданные токены не переживают перезапуск сервиса
+	VerificationSecret string        `yaml:"verification_secret"`
+	VerificationTTL    time.Duration `yaml:"verification_ttl" env-default:"24h"`
+	// ReturnToken возвращать токен в ответе на запрос подтверждения (только для локальной разработки)
+	ReturnToken bool `yaml:"return_token" env-default:"false"`
+}
+
+// Summary содержит настройки сводки профиля GET /users/me/summary
+type Summary struct {
+	// RecentTasks количество последних выполненных заданий в сводке
+	RecentTasks int `yaml:"recent_tasks" env-default:"5"`
+}
+
+// Streak содержит настройки бонуса за ежедневные входы
+type Streak struct {
+	// BonusPoints баллы, начисляемые за вход на следующий календарный день (UTC) после предыдущего
+	BonusPoints int `yaml:"bonus_points" env-default:"0"`
+}
+
+// PointsExpiry содержит настройки сгорания баллов
+type PointsExpiry struct {
+	// TTL срок действия баллов за задания и реферальных бонусов (0 - баллы не сгорают)
+	TTL time.Duration `yaml:"ttl" env-default:"0"`
+	// Interval период списания сгоревших баллов с балансов
+	Interval time.Duration `yaml:"interval" env-default:"1m"`
 }
 
-// MustLoad загружает конфигурацию из файла YAML.
+// MustLoad загружает конфигурацию из файла Path() в формате YAML, JSON или TOML.
 // Паникует при возникновении ошибок загрузки или парсинга.
 func MustLoad() *Config {
-	configPath := os.Getenv("CONFIG_PATH")
-	if configPath == "" {
-		configPath = "../config/config.yaml"
+	config, err := Load(Path())
+	if err != nil {
+		panic(err)
 	}
+
+	return config
+}
+
+// Path возвращает путь к файлу конфигурации из переменной окружения CONFIG_PATH
+// или путь по умолчанию
+func Path() string {
+	if configPath := os.Getenv("CONFIG_PATH"); configPath != "" {
+		return configPath
+	}
+	return "../config/config.yaml"
+}
+
+// Load загружает конфигурацию из файла configPath и проверяет ее, возвращая ошибку
+// вместо паники. Формат определяется расширением файла (см. decode).
+// Ошибка открытия файла оборачивается, поэтому отсутствие файла проверяется через os.ErrNotExist.
+func Load(configPath string) (*Config, error) {
 	file, err := os.Open(configPath)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("failed to open config %s: %w", configPath, err)
 	}
 	defer file.Close()
-	decoder := yaml.NewDecoder(file)
 	config := &Config{}
-	err = decoder.Decode(config)
-	if err != nil {
-		panic(err)
+	if err := decode(configPath, file, config); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", configPath, err)
+	}
+	config.applyDefaults()
+	if err := config.validate(); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", configPath, err)
 	}
 
-	return config
+	return config, nil
+}
+
+// Драйверы хранилища данных
+const (
+	StorageDriverPostgres = "postgres"
+	StorageDriverMemory   = "memory"
+)
+
+// usernameCases допустимые значения параметра auth.username_case
+var usernameCases = []string{models.UsernameCasePreserve, models.UsernameCaseLower}
+
+// leaderboardTiebreakers допустимые значения параметра leaderboard.tiebreaker
+var leaderboardTiebreakers = []string{models.TiebreakerCreatedAt, models.TiebreakerUsername}
+
+// sslModes допустимые значения параметра sslmode PostgreSQL
+var sslModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+
+// applyDefaults подставляет значения по умолчанию для незаданных параметров
+func (c *Config) applyDefaults() {
+	if c.Storage.Driver == "" {
+		c.Storage.Driver = StorageDriverPostgres
+	}
+	if c.Storage.Sslmode == "" {
+		c.Storage.Sslmode = "disable"
+	}
+	if c.Storage.QueryTimeout == nil {
+		queryTimeout := 5 * time.Second
+		c.Storage.QueryTimeout = &queryTimeout
+	}
+	if c.Rest.MaxBodyBytes <= 0 {
+		c.Rest.MaxBodyBytes = 1 << 20
+	}
+	if c.Rest.ReadTimeout <= 0 {
+		c.Rest.ReadTimeout = 15 * time.Second
+	}
+	if c.Rest.ReadHeaderTimeout <= 0 {
+		c.Rest.ReadHeaderTimeout = 5 * time.Second
+	}
+	if c.Rest.WriteTimeout <= 0 {
+		c.Rest.WriteTimeout = 15 * time.Second
+	}
+	if c.Rest.IdleTimeout <= 0 {
+		c.Rest.IdleTimeout = 60 * time.Second
+	}
+	if c.Rest.ShutdownTimeout <= 0 {
+		c.Rest.ShutdownTimeout = 10 * time.Second
+	}
+	if c.Rest.DrainRetryAfter <= 0 {
+		c.Rest.DrainRetryAfter = 5 * time.Second
+	}
+	if c.Auth.RateLimit <= 0 {
+		c.Auth.RateLimit = 10
+	}
+	if c.Auth.RateLimitWindow <= 0 {
+		c.Auth.RateLimitWindow = time.Minute
+	}
+	if c.Auth.MinPasswordLength <= 0 {
+		c.Auth.MinPasswordLength = validate.DefaultMinPasswordLength
+	}
+	if c.JWT.Algorithm == "" {
+		c.JWT.Algorithm = "HS256"
+	}
+	if c.JWT.MinTokenDuration <= 0 {
+		c.JWT.MinTokenDuration = time.Minute
+	}
+	if c.JWT.MaxTokenDuration <= 0 {
+		c.JWT.MaxTokenDuration = 720 * time.Hour
+	}
+	if c.Rest.TLS.MinVersion == "" {
+		c.Rest.TLS.MinVersion = "1.2"
+	}
+	if c.Rest.SocketMode == "" {
+		c.Rest.SocketMode = "0660"
+	}
+	if c.Rest.BasePath == "" {
+		c.Rest.BasePath = "/api"
+	}
+	if c.Rest.LegacyRedirect == nil {
+		legacyRedirect := true
+		c.Rest.LegacyRedirect = &legacyRedirect
+	}
+	if c.Rest.RequireJSON == nil {
+		requireJSON := true
+		c.Rest.RequireJSON = &requireJSON
+	}
+	if c.JWT.Leeway == nil {
+		leeway := 30 * time.Second
+		c.JWT.Leeway = &leeway
+	}
+	if c.Log.Output == "" {
+		c.Log.Output = "stdout"
+	}
+	if c.Log.MaxSizeMB <= 0 {
+		c.Log.MaxSizeMB = 100
+	}
+	if c.Leaderboard.Tiebreaker == "" {
+		c.Leaderboard.Tiebreaker = models.TiebreakerCreatedAt
+	}
+	if c.Auth.UsernameCase == "" {
+		c.Auth.UsernameCase = models.UsernameCasePreserve
+	}
+	if c.Leaderboard.DefaultLimit <= 0 {
+		c.Leaderboard.DefaultLimit = 10
+	}
+	if c.Leaderboard.MaxLimit <= 0 {
+		c.Leaderboard.MaxLimit = 100
+	}
+	if c.Summary.RecentTasks <= 0 {
+		c.Summary.RecentTasks = 5
+	}
+	if c.Log.Bodies.MaxBytes <= 0 {
+		c.Log.Bodies.MaxBytes = 4096
+	}
+	if len(c.Log.Bodies.RedactFields) == 0 {
+		c.Log.Bodies.RedactFields = []string{"password", "current_password", "new_password", "token"}
+	}
+	if len(c.Log.Bodies.RedactHeaders) == 0 {
+		c.Log.Bodies.RedactHeaders = []string{"Authorization", "Cookie", "Set-Cookie"}
+	}
+	if c.Leaderboard.StreamMaxSubscribers <= 0 {
+		c.Leaderboard.StreamMaxSubscribers = 100
+	}
+	if c.Leaderboard.PointsToNextRank == nil {
+		pointsToNextRank := true
+		c.Leaderboard.PointsToNextRank = &pointsToNextRank
+	}
+	if c.Leaderboard.MaterializedView.RefreshInterval <= 0 {
+		c.Leaderboard.MaterializedView.RefreshInterval = time.Minute
+	}
+	if c.Leaderboard.MaterializedView.MaxStaleness <= 0 {
+		c.Leaderboard.MaterializedView.MaxStaleness = 5 * time.Minute
+	}
+	if c.PointsExpiry.Interval <= 0 {
+		c.PointsExpiry.Interval = time.Minute
+	}
+	if c.Admin.ImportMaxBatch <= 0 {
+		c.Admin.ImportMaxBatch = 1000
+	}
+	if c.Admin.TaskImportMax <= 0 {
+		c.Admin.TaskImportMax = 10000
+	}
+	if c.Admin.StatsCacheTTL == nil {
+		statsCacheTTL := 30 * time.Second
+		c.Admin.StatsCacheTTL = &statsCacheTTL
+	}
+	if c.Referral.BonusPoints == nil {
+		bonusPoints := 10
+		c.Referral.BonusPoints = &bonusPoints
+	}
+	if len(c.Referral.Schedule) == 0 {
+		c.Referral.Schedule = []int{*c.Referral.BonusPoints}
+	}
+	if c.Referral.MaxChainDepth <= 0 {
+		c.Referral.MaxChainDepth = 5
+	}
+	if c.AntiCheat.Window <= 0 {
+		c.AntiCheat.Window = time.Minute
+	}
+	if c.Webhook.Workers <= 0 {
+		c.Webhook.Workers = 2
+	}
+	if c.Webhook.QueueSize <= 0 {
+		c.Webhook.QueueSize = 1000
+	}
+	if c.Webhook.MaxAttempts <= 0 {
+		c.Webhook.MaxAttempts = 5
+	}
+	if c.Webhook.Backoff <= 0 {
+		c.Webhook.Backoff = 500 * time.Millisecond
+	}
+	if c.Webhook.Timeout <= 0 {
+		c.Webhook.Timeout = 5 * time.Second
+	}
+	if c.Webhook.CorrelationID == nil {
+		correlationID := true
+		c.Webhook.CorrelationID = &correlationID
+	}
+	if c.Auth.PasswordResetTTL == 0 {
+		c.Auth.PasswordResetTTL = time.Hour
+	}
+	if c.Email.VerificationTTL == 0 {
+		c.Email.VerificationTTL = 24 * time.Hour
+	}
+}
+
+// validate проверяет обязательные параметры и корректность значений конфигурации
+func (c *Config) validate() error {
+	var errs []error
+
+	postgres := c.Storage.Driver == StorageDriverPostgres
+	if !postgres && c.Storage.Driver != StorageDriverMemory {
+		errs = append(errs, fmt.Errorf("storage.driver must be one of %s, %s, got %q",
+			StorageDriverPostgres, StorageDriverMemory, c.Storage.Driver))
+	}
+
+	type field struct {
+		name  string
+		value string
+	}
+
+	required := []field{
+		{"rest.host", c.Rest.Host},
+	}
+	var ports []field
+	// Порт не нужен при прослушивании Unix сокета
+	if c.Rest.UnixSocket() {
+		if _, err := c.Rest.SocketFileMode(); err != nil {
+			errs = append(errs, err)
+		}
+	} else {
+		required = append(required, field{"rest.port", c.Rest.Port})
+		ports = append(ports, field{"rest.port", c.Rest.Port})
+	}
+
+	tlsConfig := c.Rest.TLS
+	if (tlsConfig.CertFile == "") != (tlsConfig.KeyFile == "") {
+		errs = append(errs, errors.New("rest.tls.cert_file and rest.tls.key_file must be set together"))
+	}
+	if !slices.Contains(tlsVersions, tlsConfig.MinVersion) {
+		errs = append(errs, fmt.Errorf("rest.tls.min_version must be one of %s, got %q",
+			strings.Join(tlsVersions, ", "), tlsConfig.MinVersion))
+	}
+	if tlsConfig.RedirectPort != "" {
+		switch {
+		case !tlsConfig.Enabled():
+			errs = append(errs, errors.New("rest.tls.redirect_port requires rest.tls.cert_file and rest.tls.key_file"))
+		case c.Rest.UnixSocket():
+			errs = append(errs, errors.New("rest.tls.redirect_port is not supported with a Unix socket"))
+		case tlsConfig.RedirectPort == c.Rest.Port:
+			errs = append(errs, errors.New("rest.tls.redirect_port must differ from rest.port"))
+		}
+		ports = append(ports, field{"rest.tls.redirect_port", tlsConfig.RedirectPort})
+	}
+	// Параметры подключения нужны только хранилищу PostgreSQL
+	if postgres {
+		required = append(required,
+			field{"storage.user", c.Storage.User},
+			field{"storage.password", c.Storage.Password},
+			field{"storage.host", c.Storage.Host},
+			field{"storage.port", c.Storage.Port},
+			field{"storage.dbname", c.Storage.DBName},
+		)
+		ports = append(ports, field{"storage.port", c.Storage.Port})
+	}
+	for _, field := range required {
+		if field.value == "" {
+			errs = append(errs, fmt.Errorf("%s is required", field.name))
+		}
+	}
+
+	for _, field := range ports {
+		if field.value == "" {
+			continue
+		}
+		if port, err := strconv.Atoi(field.value); err != nil || port < 1 || port > 65535 {
+			errs = append(errs, fmt.Errorf("%s must be a number between 1 and 65535, got %q", field.name, field.value))
+		}
+	}
+
+	if postgres && !slices.Contains(sslModes, c.Storage.Sslmode) {
+		errs = append(errs, fmt.Errorf("storage.sslmode must be one of %s, got %q",
+			strings.Join(sslModes, ", "), c.Storage.Sslmode))
+	}
+
+	if c.Log.MaxBackups < 0 {
+		errs = append(errs, errors.New("log.max_backups must not be negative"))
+	}
+	if c.Log.Sampling.Initial < 0 || c.Log.Sampling.Thereafter < 0 {
+		errs = append(errs, errors.New("log.sampling.initial and log.sampling.thereafter must not be negative"))
+	}
+	if c.Log.MaxAgeDays < 0 {
+		errs = append(errs, errors.New("log.max_age_days must not be negative"))
+	}
+	if c.Auth.PasswordHistory < 0 {
+		errs = append(errs, errors.New("auth.password_history must not be negative"))
+	}
+
+	if c.Referral.BonusPoints != nil && *c.Referral.BonusPoints < 0 {
+		errs = append(errs, errors.New("referral.bonus_points must not be negative"))
+	}
+	for i, bonus := range c.Referral.Schedule {
+		if bonus < 0 {
+			errs = append(errs, fmt.Errorf("referral.schedule[%d] must not be negative", i))
+		}
+	}
+	if c.Streak.BonusPoints < 0 {
+		errs = append(errs, errors.New("streak.bonus_points must not be negative"))
+	}
+	if c.PointsExpiry.TTL < 0 {
+		errs = append(errs, errors.New("points_expiry.ttl must not be negative"))
+	}
+	if c.AntiCheat.MaxPointsPerTask < 0 || c.AntiCheat.MaxTasks < 0 {
+		errs = append(errs, errors.New("anti_cheat.max_points_per_task and anti_cheat.max_tasks must not be negative"))
+	}
+
+	switch c.JWT.Algorithm {
+	case "HS256":
+		if c.JWT.SecretKey == "" {
+			errs = append(errs, errors.New("jwt.secretkey is required for HS256"))
+		}
+	case "RS256", "ES256":
+		if c.JWT.PublicKeyPath == "" {
+			errs = append(errs, fmt.Errorf("jwt.public_key_path is required for %s", c.JWT.Algorithm))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("jwt.algorithm must be one of HS256, RS256, ES256, got %q", c.JWT.Algorithm))
+	}
+
+	if c.Rest.DrainDelay < 0 {
+		errs = append(errs, errors.New("rest.drain_delay must not be negative"))
+	}
+	if !strings.HasPrefix(c.Rest.BasePath, "/") || strings.HasSuffix(c.Rest.BasePath, "/") {
+		errs = append(errs, fmt.Errorf("rest.base_path must start with / and must not end with /, got %q", c.Rest.BasePath))
+	}
+	for _, proxy := range c.Rest.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			errs = append(errs, fmt.Errorf("rest.trusted_proxies must contain IP addresses or CIDR networks, got %q", proxy))
+		}
+	}
+
+	if c.Webhook.URL != "" {
+		if u, err := url.Parse(c.Webhook.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("webhook.url must be an absolute http(s) URL, got %q", c.Webhook.URL))
+		}
+		if c.Webhook.Secret == "" {
+			errs = append(errs, errors.New("webhook.secret is required when webhook.url is set"))
+		}
+	}
+
+	if c.Auth.PasswordResetTTL < 0 {
+		errs = append(errs, errors.New("auth.password_reset_ttl must not be negative"))
+	}
+	if c.Email.VerificationTTL < 0 {
+		errs = append(errs, errors.New("email.verification_ttl must not be negative"))
+	}
+
+	if !slices.Contains(usernameCases, c.Auth.UsernameCase) {
+		errs = append(errs, fmt.Errorf("auth.username_case must be one of %s, got %q",
+			strings.Join(usernameCases, ", "), c.Auth.UsernameCase))
+	}
+	if view := c.Leaderboard.MaterializedView; view.Enabled {
+		if c.Storage.Driver != StorageDriverPostgres {
+			errs = append(errs, fmt.Errorf("leaderboard.materialized_view requires storage.driver %q", StorageDriverPostgres))
+		}
+		if view.MaxStaleness < view.RefreshInterval {
+			errs = append(errs, fmt.Errorf("leaderboard.materialized_view.max_staleness (%s) must not be less than refresh_interval (%s)",
+				view.MaxStaleness, view.RefreshInterval))
+		}
+	}
+	if !slices.Contains(leaderboardTiebreakers, c.Leaderboard.Tiebreaker) {
+		errs = append(errs, fmt.Errorf("leaderboard.tiebreaker must be one of %s, got %q",
+			strings.Join(leaderboardTiebreakers, ", "), c.Leaderboard.Tiebreaker))
+	}
+	if c.Leaderboard.DefaultLimit > c.Leaderboard.MaxLimit {
+		errs = append(errs, fmt.Errorf("leaderboard.default_limit (%d) must not exceed leaderboard.max_limit (%d)",
+			c.Leaderboard.DefaultLimit, c.Leaderboard.MaxLimit))
+	}
+	if c.Leaderboard.CacheTTL < 0 {
+		errs = append(errs, errors.New("leaderboard.cache_ttl must not be negative"))
+	}
+	if *c.Storage.QueryTimeout < 0 {
+		errs = append(errs, errors.New("storage.query_timeout must not be negative"))
+	}
+	if *c.Admin.StatsCacheTTL < 0 {
+		errs = append(errs, errors.New("admin.stats_cache_ttl must not be negative"))
+	}
+
+	if c.JWT.MinTokenDuration > c.JWT.MaxTokenDuration {
+		errs = append(errs, fmt.Errorf("jwt.min_token_duration (%s) must not exceed jwt.max_token_duration (%s)",
+			c.JWT.MinTokenDuration, c.JWT.MaxTokenDuration))
+	} else if c.JWT.TokenDuration < c.JWT.MinTokenDuration || c.JWT.TokenDuration > c.JWT.MaxTokenDuration {
+		errs = append(errs, fmt.Errorf("jwt.tokenduration must be between %s and %s, got %s",
+			c.JWT.MinTokenDuration, c.JWT.MaxTokenDuration, c.JWT.TokenDuration))
+	}
+	if c.JWT.Leeway != nil && *c.JWT.Leeway < 0 {
+		errs = append(errs, errors.New("jwt.leeway must not be negative"))
+	}
+
+	return errors.Join(errs...)
 }
@@ -0,0 +1,741 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// validConfig возвращает конфигурацию, проходящую проверку validate
+func validConfig() *Config {
+	cfg := &Config{
+		Storage: Storage{
+			User:     "postgres",
+			Password: "secret",
+			Host:     "localhost",
+			Port:     "5432",
+			DBName:   "user_points",
+			Sslmode:  "disable",
+		},
+		Rest: Rest{Host: "localhost", Port: "8080"},
+		JWT:  JWT{SecretKey: "test-secret", TokenDuration: time.Hour},
+	}
+	cfg.applyDefaults()
+	return cfg
+}
+
+func TestValidate(t *testing.T) {
+	if err := validConfig().validate(); err != nil {
+		t.Fatalf("validate() of valid config error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		// wantErr фрагмент текста ошибки, указывающий на поле и причину
+		wantErr string
+	}{
+		{
+			name:    "negative drain delay",
+			modify:  func(c *Config) { c.Rest.DrainDelay = -time.Second },
+			wantErr: "rest.drain_delay must not be negative",
+		},
+		{
+			name:    "invalid trusted proxy",
+			modify:  func(c *Config) { c.Rest.TrustedProxies = []string{"10.0.0.1", "10.0.0.0/8", "proxy.local"} },
+			wantErr: `rest.trusted_proxies must contain IP addresses or CIDR networks, got "proxy.local"`,
+		},
+		{
+			name:    "trusted proxy with invalid mask",
+			modify:  func(c *Config) { c.Rest.TrustedProxies = []string{"10.0.0.0/33"} },
+			wantErr: `got "10.0.0.0/33"`,
+		},
+		{
+			name:    "invalid socket mode",
+			modify:  func(c *Config) { c.Rest.Host, c.Rest.SocketMode = "/run/app.sock", "rw-rw----" },
+			wantErr: `rest.socket_mode must be an octal permission between 0000 and 0777, got "rw-rw----"`,
+		},
+		{
+			name:    "socket mode out of range",
+			modify:  func(c *Config) { c.Rest.Host, c.Rest.SocketMode = "/run/app.sock", "1777" },
+			wantErr: `got "1777"`,
+		},
+		{
+			name:    "tls certificate without key",
+			modify:  func(c *Config) { c.Rest.TLS.CertFile = "cert.pem" },
+			wantErr: "rest.tls.cert_file and rest.tls.key_file must be set together",
+		},
+		{
+			name:    "unsupported tls version",
+			modify:  func(c *Config) { c.Rest.TLS.MinVersion = "1.1" },
+			wantErr: `rest.tls.min_version must be one of 1.2, 1.3, got "1.1"`,
+		},
+		{
+			name:    "redirect without tls",
+			modify:  func(c *Config) { c.Rest.TLS.RedirectPort = "8081" },
+			wantErr: "rest.tls.redirect_port requires rest.tls.cert_file and rest.tls.key_file",
+		},
+		{
+			name: "redirect on server port",
+			modify: func(c *Config) {
+				c.Rest.TLS = TLS{CertFile: "cert.pem", KeyFile: "key.pem", MinVersion: "1.2", RedirectPort: c.Rest.Port}
+			},
+			wantErr: "rest.tls.redirect_port must differ from rest.port",
+		},
+		{
+			name: "redirect port out of range",
+			modify: func(c *Config) {
+				c.Rest.TLS = TLS{CertFile: "cert.pem", KeyFile: "key.pem", MinVersion: "1.2", RedirectPort: "0"}
+			},
+			wantErr: `rest.tls.redirect_port must be a number between 1 and 65535, got "0"`,
+		},
+		{
+			name: "redirect with unix socket",
+			modify: func(c *Config) {
+				c.Rest.Host, c.Rest.SocketMode = "/run/app.sock", "0660"
+				c.Rest.TLS = TLS{CertFile: "cert.pem", KeyFile: "key.pem", MinVersion: "1.2", RedirectPort: "8081"}
+			},
+			wantErr: "rest.tls.redirect_port is not supported with a Unix socket",
+		},
+		{
+			name: "negative query timeout",
+			modify: func(c *Config) {
+				timeout := -time.Second
+				c.Storage.QueryTimeout = &timeout
+			},
+			wantErr: "storage.query_timeout must not be negative",
+		},
+		{
+			name:    "unknown storage driver",
+			modify:  func(c *Config) { c.Storage.Driver = "sqlite" },
+			wantErr: `storage.driver must be one of postgres, memory, got "sqlite"`,
+		},
+		{
+			name:    "missing storage user",
+			modify:  func(c *Config) { c.Storage.User = "" },
+			wantErr: "storage.user is required",
+		},
+		{
+			name:    "missing storage host",
+			modify:  func(c *Config) { c.Storage.Host = "" },
+			wantErr: "storage.host is required",
+		},
+		{
+			name:    "legacy false sslmode",
+			modify:  func(c *Config) { c.Storage.Sslmode = "false" },
+			wantErr: `storage.sslmode must be one of disable, allow, prefer, require, verify-ca, verify-full, got "false"`,
+		},
+		{
+			name:    "boolean true sslmode",
+			modify:  func(c *Config) { c.Storage.Sslmode = "true" },
+			wantErr: `storage.sslmode must be one of disable, allow, prefer, require, verify-ca, verify-full, got "true"`,
+		},
+		{
+			name:    "missing jwt secret",
+			modify:  func(c *Config) { c.JWT.SecretKey = "" },
+			wantErr: "jwt.secretkey is required for HS256",
+		},
+		{
+			name:    "unsupported jwt algorithm",
+			modify:  func(c *Config) { c.JWT.Algorithm = "none" },
+			wantErr: `jwt.algorithm must be one of HS256, RS256, ES256, got "none"`,
+		},
+		{
+			name:    "asymmetric jwt algorithm without public key",
+			modify:  func(c *Config) { c.JWT.Algorithm = "RS256" },
+			wantErr: "jwt.public_key_path is required for RS256",
+		},
+		{
+			name:    "non-numeric storage port",
+			modify:  func(c *Config) { c.Storage.Port = "postgres" },
+			wantErr: `storage.port must be a number between 1 and 65535, got "postgres"`,
+		},
+		{
+			name:    "rest port out of range",
+			modify:  func(c *Config) { c.Rest.Port = "70000" },
+			wantErr: `rest.port must be a number between 1 and 65535, got "70000"`,
+		},
+		{
+			name:    "zero token duration",
+			modify:  func(c *Config) { c.JWT.TokenDuration = 0 },
+			wantErr: "jwt.tokenduration must be between 1m0s and 720h0m0s, got 0s",
+		},
+		{
+			name:    "token duration below minimum",
+			modify:  func(c *Config) { c.JWT.TokenDuration = 30 * time.Second },
+			wantErr: "jwt.tokenduration must be between 1m0s and 720h0m0s, got 30s",
+		},
+		{
+			name:    "token duration above configured maximum",
+			modify:  func(c *Config) { c.JWT.MaxTokenDuration = 30 * time.Minute },
+			wantErr: "jwt.tokenduration must be between 1m0s and 30m0s, got 1h0m0s",
+		},
+		{
+			name:    "inverted token duration range",
+			modify:  func(c *Config) { c.JWT.MinTokenDuration, c.JWT.MaxTokenDuration = 2*time.Hour, time.Hour },
+			wantErr: "jwt.min_token_duration (2h0m0s) must not exceed jwt.max_token_duration (1h0m0s)",
+		},
+		{
+			name:    "base path without leading slash",
+			modify:  func(c *Config) { c.Rest.BasePath = "api" },
+			wantErr: `rest.base_path must start with / and must not end with /, got "api"`,
+		},
+		{
+			name:    "base path with trailing slash",
+			modify:  func(c *Config) { c.Rest.BasePath = "/api/" },
+			wantErr: `rest.base_path must start with / and must not end with /, got "/api/"`,
+		},
+		{
+			name:    "negative stats cache ttl",
+			modify:  func(c *Config) { ttl := -time.Second; c.Admin.StatsCacheTTL = &ttl },
+			wantErr: "admin.stats_cache_ttl must not be negative",
+		},
+		{
+			name:    "negative password reset ttl",
+			modify:  func(c *Config) { c.Auth.PasswordResetTTL = -time.Hour },
+			wantErr: "auth.password_reset_ttl must not be negative",
+		},
+		{
+			name:    "negative email verification ttl",
+			modify:  func(c *Config) { c.Email.VerificationTTL = -time.Hour },
+			wantErr: "email.verification_ttl must not be negative",
+		},
+		{
+			name:    "negative jwt leeway",
+			modify:  func(c *Config) { leeway := -time.Second; c.JWT.Leeway = &leeway },
+			wantErr: "jwt.leeway must not be negative",
+		},
+		{
+			name:    "negative log backups",
+			modify:  func(c *Config) { c.Log.MaxBackups = -1 },
+			wantErr: "log.max_backups must not be negative",
+		},
+		{
+			name:    "negative log sampling",
+			modify:  func(c *Config) { c.Log.Sampling.Thereafter = -1 },
+			wantErr: "log.sampling.initial and log.sampling.thereafter must not be negative",
+		},
+		{
+			name:    "negative log age",
+			modify:  func(c *Config) { c.Log.MaxAgeDays = -1 },
+			wantErr: "log.max_age_days must not be negative",
+		},
+		{
+			name:    "negative password history",
+			modify:  func(c *Config) { c.Auth.PasswordHistory = -1 },
+			wantErr: "auth.password_history must not be negative",
+		},
+		{
+			name:    "negative leaderboard cache ttl",
+			modify:  func(c *Config) { c.Leaderboard.CacheTTL = -time.Second },
+			wantErr: "leaderboard.cache_ttl must not be negative",
+		},
+		{
+			name:    "negative points ttl",
+			modify:  func(c *Config) { c.PointsExpiry.TTL = -time.Hour },
+			wantErr: "points_expiry.ttl must not be negative",
+		},
+		{
+			name:    "negative streak bonus",
+			modify:  func(c *Config) { c.Streak.BonusPoints = -1 },
+			wantErr: "streak.bonus_points must not be negative",
+		},
+		{
+			name:    "negative referral schedule level",
+			modify:  func(c *Config) { c.Referral.Schedule = []int{10, -5} },
+			wantErr: "referral.schedule[1] must not be negative",
+		},
+		{
+			name:    "negative anti-cheat task limit",
+			modify:  func(c *Config) { c.AntiCheat.MaxTasks = -1 },
+			wantErr: "anti_cheat.max_points_per_task and anti_cheat.max_tasks must not be negative",
+		},
+		{
+			name:    "negative points per task cap",
+			modify:  func(c *Config) { c.AntiCheat.MaxPointsPerTask = -1 },
+			wantErr: "anti_cheat.max_points_per_task and anti_cheat.max_tasks must not be negative",
+		},
+		{
+			name:    "relative webhook url",
+			modify:  func(c *Config) { c.Webhook = Webhook{URL: "/hooks/points", Secret: "hook-secret"} },
+			wantErr: `webhook.url must be an absolute http(s) URL, got "/hooks/points"`,
+		},
+		{
+			name:    "non-http webhook url",
+			modify:  func(c *Config) { c.Webhook = Webhook{URL: "ftp://hooks.example.com", Secret: "hook-secret"} },
+			wantErr: `webhook.url must be an absolute http(s) URL, got "ftp://hooks.example.com"`,
+		},
+		{
+			name:    "webhook without secret",
+			modify:  func(c *Config) { c.Webhook.URL = "https://hooks.example.com/points" },
+			wantErr: "webhook.secret is required when webhook.url is set",
+		},
+		{
+			name:    "default limit above max limit",
+			modify:  func(c *Config) { c.Leaderboard.DefaultLimit, c.Leaderboard.MaxLimit = 50, 20 },
+			wantErr: "leaderboard.default_limit (50) must not exceed leaderboard.max_limit (20)",
+		},
+		{
+			name:    "unknown username case",
+			modify:  func(c *Config) { c.Auth.UsernameCase = "upper" },
+			wantErr: `auth.username_case must be one of preserve, lower, got "upper"`,
+		},
+		{
+			name: "materialized view with memory storage",
+			modify: func(c *Config) {
+				c.Storage.Driver, c.Leaderboard.MaterializedView.Enabled = StorageDriverMemory, true
+			},
+			wantErr: `leaderboard.materialized_view requires storage.driver "postgres"`,
+		},
+		{
+			name: "materialized view staleness below refresh interval",
+			modify: func(c *Config) {
+				c.Leaderboard.MaterializedView = LeaderboardView{Enabled: true, RefreshInterval: time.Minute, MaxStaleness: 30 * time.Second}
+			},
+			wantErr: "leaderboard.materialized_view.max_staleness (30s) must not be less than refresh_interval (1m0s)",
+		},
+		{
+			name:    "unknown leaderboard tiebreaker",
+			modify:  func(c *Config) { c.Leaderboard.Tiebreaker = "points" },
+			wantErr: `leaderboard.tiebreaker must be one of created_at, username, got "points"`,
+		},
+		{
+			name:    "negative referral bonus",
+			modify:  func(c *Config) { bonus := -1; c.Referral.BonusPoints = &bonus },
+			wantErr: "referral.bonus_points must not be negative",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			tt.modify(cfg)
+
+			err := cfg.validate()
+			if err == nil {
+				t.Fatalf("validate() error = nil, want %q", tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validate() error = %q, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateReportsEveryError(t *testing.T) {
+	cfg := validConfig()
+	cfg.Storage.User = ""
+	cfg.Rest.Port = "0"
+
+	err := cfg.validate()
+	if err == nil {
+		t.Fatal("validate() error = nil, want errors for storage.user and rest.port")
+	}
+	for _, want := range []string{"storage.user", "rest.port"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("validate() error = %q, want it to mention %s", err, want)
+		}
+	}
+}
+
+func TestValidateUnixSocketWithoutPort(t *testing.T) {
+	cfg := validConfig()
+	cfg.Rest.Host, cfg.Rest.Port, cfg.Rest.SocketMode = "/run/app.sock", "", "0660"
+
+	if err := cfg.validate(); err != nil {
+		t.Errorf("validate() error = %v, want port not required for a Unix socket", err)
+	}
+	if !cfg.Rest.UnixSocket() || cfg.Rest.Addr() != "/run/app.sock" {
+		t.Errorf("UnixSocket() = %v, Addr() = %q, want socket at /run/app.sock", cfg.Rest.UnixSocket(), cfg.Rest.Addr())
+	}
+}
+
+func TestValidateMemoryStorage(t *testing.T) {
+	cfg := validConfig()
+	cfg.Storage = Storage{Driver: StorageDriverMemory, QueryTimeout: cfg.Storage.QueryTimeout}
+
+	if err := cfg.validate(); err != nil {
+		t.Errorf("validate() error = %v, want the memory driver valid without connection settings", err)
+	}
+}
+
+func TestValidateAsymmetricJWTWithoutSecret(t *testing.T) {
+	cfg := validConfig()
+	cfg.JWT.Algorithm, cfg.JWT.SecretKey, cfg.JWT.PublicKeyPath = "ES256", "", "keys/public.pem"
+
+	if err := cfg.validate(); err != nil {
+		t.Errorf("validate() error = %v, want ES256 without secretkey to be valid", err)
+	}
+}
+
+func TestApplyDefaults(t *testing.T) {
+	cfg := &Config{}
+	cfg.applyDefaults()
+
+	if cfg.Storage.Driver != StorageDriverPostgres {
+		t.Errorf("Storage.Driver = %q, want %s", cfg.Storage.Driver, StorageDriverPostgres)
+	}
+	if cfg.Storage.Sslmode != "disable" {
+		t.Errorf("Storage.Sslmode = %q, want disable", cfg.Storage.Sslmode)
+	}
+	if cfg.JWT.Algorithm != "HS256" {
+		t.Errorf("JWT.Algorithm = %q, want HS256", cfg.JWT.Algorithm)
+	}
+	if cfg.JWT.MinTokenDuration != time.Minute || cfg.JWT.MaxTokenDuration != 720*time.Hour {
+		t.Errorf("JWT token duration range = %s..%s, want 1m..720h", cfg.JWT.MinTokenDuration, cfg.JWT.MaxTokenDuration)
+	}
+	if cfg.JWT.Leeway == nil || *cfg.JWT.Leeway != 30*time.Second {
+		t.Errorf("JWT.Leeway = %v, want 30s", cfg.JWT.Leeway)
+	}
+	if cfg.Rest.ReadTimeout != 15*time.Second || cfg.Rest.ReadHeaderTimeout != 5*time.Second ||
+		cfg.Rest.WriteTimeout != 15*time.Second || cfg.Rest.IdleTimeout != time.Minute || cfg.Rest.ShutdownTimeout != 10*time.Second ||
+		cfg.Rest.DrainDelay != 0 || cfg.Rest.DrainRetryAfter != 5*time.Second {
+		t.Errorf("Rest timeouts = %+v, want 15s read, 5s header, 15s write, 60s idle, 10s shutdown, no drain delay and 5s retry after", cfg.Rest)
+	}
+	if cfg.Rest.RequireJSON == nil || !*cfg.Rest.RequireJSON {
+		t.Errorf("Rest.RequireJSON = %v, want true", cfg.Rest.RequireJSON)
+	}
+	if cfg.Rest.BasePath != "/api" || cfg.Rest.LegacyRedirect == nil || !*cfg.Rest.LegacyRedirect {
+		t.Errorf("Rest.BasePath = %q, LegacyRedirect = %v, want /api with legacy redirects", cfg.Rest.BasePath, cfg.Rest.LegacyRedirect)
+	}
+	if cfg.Storage.QueryTimeout == nil || *cfg.Storage.QueryTimeout != 5*time.Second {
+		t.Errorf("Storage.QueryTimeout = %v, want 5s", cfg.Storage.QueryTimeout)
+	}
+	if cfg.Rest.SocketMode != "0660" {
+		t.Errorf("Rest.SocketMode = %q, want 0660", cfg.Rest.SocketMode)
+	}
+	if cfg.Rest.TLS.Enabled() || cfg.Rest.TLS.MinVersion != "1.2" {
+		t.Errorf("Rest.TLS = %+v, want disabled with minimum version 1.2", cfg.Rest.TLS)
+	}
+	if cfg.Rest.MaxBodyBytes != 1<<20 {
+		t.Errorf("Rest.MaxBodyBytes = %d, want %d", cfg.Rest.MaxBodyBytes, 1<<20)
+	}
+	if cfg.Log.Output != "stdout" || cfg.Log.MaxSizeMB != 100 {
+		t.Errorf("Log = %+v, want stdout output rotated at 100 MB", cfg.Log)
+	}
+	if cfg.Log.Bodies.Enabled || cfg.Log.Bodies.MaxBytes != 4096 ||
+		!slices.Equal(cfg.Log.Bodies.RedactFields, []string{"password", "current_password", "new_password", "token"}) ||
+		!slices.Equal(cfg.Log.Bodies.RedactHeaders, []string{"Authorization", "Cookie", "Set-Cookie"}) {
+		t.Errorf("Log.Bodies = %+v, want disabled, 4096 bytes and default redaction lists", cfg.Log.Bodies)
+	}
+	if cfg.Leaderboard.DefaultLimit != 10 || cfg.Leaderboard.MaxLimit != 100 || cfg.Leaderboard.Tiebreaker != "created_at" ||
+		cfg.Leaderboard.StreamMaxSubscribers != 100 {
+		t.Errorf("Leaderboard = %+v, want default limit 10, max limit 100, created_at tiebreaker and 100 stream subscribers", cfg.Leaderboard)
+	}
+	if cfg.Leaderboard.PointsToNextRank == nil || !*cfg.Leaderboard.PointsToNextRank {
+		t.Errorf("Leaderboard.PointsToNextRank = %v, want enabled", cfg.Leaderboard.PointsToNextRank)
+	}
+	if view := cfg.Leaderboard.MaterializedView; view.Enabled || view.RefreshInterval != time.Minute || view.MaxStaleness != 5*time.Minute {
+		t.Errorf("Leaderboard.MaterializedView = %+v, want disabled, refreshed every 1m, stale after 5m", view)
+	}
+	if cfg.Admin.ImportMaxBatch != 1000 || cfg.Admin.TaskImportMax != 10000 || cfg.Admin.StatsCacheTTL == nil || *cfg.Admin.StatsCacheTTL != 30*time.Second {
+		t.Errorf("Admin = %+v, want import batch 1000, task import 10000 and 30s stats cache ttl", cfg.Admin)
+	}
+	if cfg.PointsExpiry.TTL != 0 || cfg.PointsExpiry.Interval != time.Minute {
+		t.Errorf("PointsExpiry = %+v, want no ttl and 1m interval", cfg.PointsExpiry)
+	}
+	if cfg.Summary.RecentTasks != 5 {
+		t.Errorf("Summary.RecentTasks = %d, want 5", cfg.Summary.RecentTasks)
+	}
+	if cfg.Referral.MaxChainDepth != 5 {
+		t.Errorf("Referral.MaxChainDepth = %d, want 5", cfg.Referral.MaxChainDepth)
+	}
+	if cfg.AntiCheat.Window != time.Minute {
+		t.Errorf("AntiCheat.Window = %s, want 1m", cfg.AntiCheat.Window)
+	}
+	if cfg.Webhook.Workers != 2 || cfg.Webhook.QueueSize != 1000 || cfg.Webhook.MaxAttempts != 5 ||
+		cfg.Webhook.Backoff != 500*time.Millisecond || cfg.Webhook.Timeout != 5*time.Second {
+		t.Errorf("Webhook = %+v, want 2 workers, queue 1000, 5 attempts, 500ms backoff, 5s timeout", cfg.Webhook)
+	}
+	if cfg.Auth.MinPasswordLength != 8 || cfg.Auth.PasswordResetTTL != time.Hour || cfg.Auth.UsernameCase != "preserve" {
+		t.Errorf("Auth = %+v, want minimum password length 8, 1h password reset ttl and preserved username case", cfg.Auth)
+	}
+	if cfg.Email.VerificationTTL != 24*time.Hour {
+		t.Errorf("Email.VerificationTTL = %s, want 24h", cfg.Email.VerificationTTL)
+	}
+}
+
+func TestApplyDefaultsKeepsZeroLeeway(t *testing.T) {
+	leeway := time.Duration(0)
+	cfg := &Config{JWT: JWT{Leeway: &leeway}}
+	cfg.applyDefaults()
+
+	if *cfg.JWT.Leeway != 0 {
+		t.Errorf("JWT.Leeway = %s, want an explicit 0s to disable the leeway", *cfg.JWT.Leeway)
+	}
+}
+
+func TestApplyDefaultsKeepsZeroQueryTimeout(t *testing.T) {
+	disabled := time.Duration(0)
+	cfg := &Config{Storage: Storage{QueryTimeout: &disabled}}
+	cfg.applyDefaults()
+	if *cfg.Storage.QueryTimeout != 0 {
+		t.Errorf("Storage.QueryTimeout = %s, want the explicit 0 kept", *cfg.Storage.QueryTimeout)
+	}
+}
+
+func TestApplyDefaultsKeepsDisabledPointsToNextRank(t *testing.T) {
+	disabled := false
+	cfg := &Config{Leaderboard: Leaderboard{PointsToNextRank: &disabled}}
+	cfg.applyDefaults()
+	if *cfg.Leaderboard.PointsToNextRank {
+		t.Error("Leaderboard.PointsToNextRank = true, want the explicit false kept")
+	}
+}
+
+func TestApplyDefaultsKeepsZeroStatsCacheTTL(t *testing.T) {
+	ttl := time.Duration(0)
+	cfg := &Config{Admin: Admin{StatsCacheTTL: &ttl}}
+	cfg.applyDefaults()
+
+	if *cfg.Admin.StatsCacheTTL != 0 {
+		t.Errorf("Admin.StatsCacheTTL = %s, want an explicit 0s to disable the cache", *cfg.Admin.StatsCacheTTL)
+	}
+}
+
+func TestApplyDefaultsReferralSchedule(t *testing.T) {
+	cfg := &Config{}
+	cfg.applyDefaults()
+	if !slices.Equal(cfg.Referral.Schedule, []int{10}) {
+		t.Errorf("Referral.Schedule = %v, want the default [10]", cfg.Referral.Schedule)
+	}
+
+	bonusPoints := 25
+	cfg = &Config{Referral: Referral{BonusPoints: &bonusPoints}}
+	cfg.applyDefaults()
+	if !slices.Equal(cfg.Referral.Schedule, []int{25}) {
+		t.Errorf("Referral.Schedule = %v, want [25] from bonus_points", cfg.Referral.Schedule)
+	}
+
+	// Явный 0 отключает бонус, а не заменяется значением по умолчанию
+	bonusPoints = 0
+	cfg = &Config{Referral: Referral{BonusPoints: &bonusPoints}}
+	cfg.applyDefaults()
+	if !slices.Equal(cfg.Referral.Schedule, []int{0}) {
+		t.Errorf("Referral.Schedule = %v, want [0] from bonus_points: 0", cfg.Referral.Schedule)
+	}
+
+	cfg = &Config{Referral: Referral{Schedule: []int{10, 5, 2}}}
+	cfg.applyDefaults()
+	if !slices.Equal(cfg.Referral.Schedule, []int{10, 5, 2}) {
+		t.Errorf("Referral.Schedule = %v, want the configured [10 5 2]", cfg.Referral.Schedule)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+		return path
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		wantErr string
+	}{
+		{name: "missing file", path: filepath.Join(dir, "missing.yaml"), wantErr: "failed to open config"},
+		{name: "malformed file", path: write("malformed.yaml", "storage: [unclosed"), wantErr: "failed to parse config"},
+		{name: "invalid values", path: write("invalid.yaml", "rest: {port: \"0\"}"), wantErr: "invalid config"},
+		{name: "malformed json", path: write("malformed.json", `{"storage": {"driver": "memory"`), wantErr: "invalid JSON"},
+		{name: "malformed toml", path: write("malformed.toml", "[storage\ndriver = \"memory\""), wantErr: "failed to parse config"},
+		{name: "unsupported extension", path: write("config.ini", "driver=memory"), wantErr: "unsupported config file extension"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := Load(tt.path)
+			if err == nil || cfg != nil {
+				t.Fatalf("Load() = %v, %v, want an error", cfg, err)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Load() error = %q, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadFormats(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"config.yaml": `
+storage:
+  driver: "memory"
+rest:
+  host: "localhost"
+  port: "9090"
+  read_timeout: "20s"
+  require_json: false
+jwt:
+  secretkey: "format-secret"
+  tokenduration: "2h"
+auth:
+  reserved_usernames: ["admin", "support"]
+referral:
+  schedule: [10, 5, 2]
+log:
+  bodies:
+    redact_fields: ["password", "pin"]
+`,
+		"config.json": `{
+  "storage": {"driver": "memory"},
+  "rest": {"host": "localhost", "port": "9090", "read_timeout": "20s", "require_json": false},
+  "jwt": {"secretkey": "format-secret", "tokenduration": "2h"},
+  "auth": {"reserved_usernames": ["admin", "support"]},
+  "referral": {"schedule": [10, 5, 2]},
+  "log": {"bodies": {"redact_fields": ["password", "pin"]}}
+}`,
+		"config.toml": `
+[storage]
+driver = "memory"
+
+[rest]
+host = "localhost"
+port = "9090"
+read_timeout = "20s"
+require_json = false
+
+[jwt]
+secretkey = "format-secret"
+tokenduration = "2h"
+
+[auth]
+reserved_usernames = ["admin", "support"]
+
+[referral]
+schedule = [10, 5, 2]
+
+[log.bodies]
+redact_fields = ["password", "pin"]
+`,
+	}
+
+	loaded := make(map[string]*Config, len(files))
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+		cfg, err := Load(path)
+		if err != nil {
+			t.Fatalf("Load(%s) error = %v", name, err)
+		}
+		loaded[name] = cfg
+	}
+
+	want := loaded["config.yaml"]
+	if want.Rest.Port != "9090" || want.Rest.ReadTimeout != 20*time.Second || *want.Rest.RequireJSON ||
+		want.JWT.TokenDuration != 2*time.Hour || !slices.Equal(want.Log.Bodies.RedactFields, []string{"password", "pin"}) ||
+		!slices.Equal(want.Auth.ReservedUsernames, []string{"admin", "support"}) {
+		t.Fatalf("config.yaml loaded as %+v, want values from the file", want)
+	}
+	for _, name := range []string{"config.json", "config.toml"} {
+		if !reflect.DeepEqual(loaded[name], want) {
+			t.Errorf("%s = %+v, want the same config as config.yaml %+v", name, loaded[name], want)
+		}
+	}
+}
+
+func TestLoadReferralBonusPoints(t *testing.T) {
+	const base = `
+storage:
+  driver: "memory"
+rest:
+  host: "localhost"
+  port: "9090"
+jwt:
+  secretkey: "bonus-secret"
+  tokenduration: "1h"
+`
+	tests := []struct {
+		name         string
+		referral     string
+		wantSchedule []int
+	}{
+		{name: "default", wantSchedule: []int{10}},
+		{name: "explicit", referral: "referral:\n  bonus_points: 3\n", wantSchedule: []int{3}},
+		{name: "disabled", referral: "referral:\n  bonus_points: 0\n", wantSchedule: []int{0}},
+		{name: "schedule wins", referral: "referral:\n  bonus_points: 0\n  schedule: [4, 2]\n", wantSchedule: []int{4, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(base+tt.referral), 0o600); err != nil {
+				t.Fatalf("write config: %v", err)
+			}
+			cfg, err := Load(path)
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if !slices.Equal(cfg.Referral.Schedule, tt.wantSchedule) {
+				t.Errorf("Referral.Schedule = %v, want %v", cfg.Referral.Schedule, tt.wantSchedule)
+			}
+		})
+	}
+}
+
+func TestLoadMissingFileIsNotExist(t *testing.T) {
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.yaml")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Load() error = %v, want os.ErrNotExist", err)
+	}
+}
+
+func TestPath(t *testing.T) {
+	t.Setenv("CONFIG_PATH", "")
+	if got := Path(); got != "../config/config.yaml" {
+		t.Errorf("Path() = %q, want default ../config/config.yaml", got)
+	}
+
+	t.Setenv("CONFIG_PATH", "/etc/app/config.toml")
+	if got := Path(); got != "/etc/app/config.toml" {
+		t.Errorf("Path() = %q, want CONFIG_PATH value", got)
+	}
+}
+
+func TestMustLoadPanics(t *testing.T) {
+	t.Setenv("CONFIG_PATH", filepath.Join(t.TempDir(), "missing.yaml"))
+
+	defer func() {
+		if recover() == nil {
+			t.Error("MustLoad() did not panic on a missing file")
+		}
+	}()
+	MustLoad()
+}
+
+func TestApplyDefaultsWebhookCorrelationID(t *testing.T) {
+	cfg := &Config{}
+	cfg.applyDefaults()
+	if cfg.Webhook.CorrelationID == nil || !*cfg.Webhook.CorrelationID {
+		t.Errorf("Webhook.CorrelationID = %v, want enabled by default", cfg.Webhook.CorrelationID)
+	}
+
+	disabled := false
+	cfg = &Config{Webhook: Webhook{CorrelationID: &disabled}}
+	cfg.applyDefaults()
+	if *cfg.Webhook.CorrelationID {
+		t.Error("Webhook.CorrelationID = true, want the explicit false kept")
+	}
+}
+
+func TestLoadLocalConfig(t *testing.T) {
+	cfg, err := Load(filepath.Join("..", "..", "config", "config.local.yaml"))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.Storage.Driver != StorageDriverMemory {
+		t.Errorf("Storage.Driver = %q, want %s", cfg.Storage.Driver, StorageDriverMemory)
+	}
+	if !slices.Equal(cfg.Referral.Schedule, []int{10, 5, 2}) {
+		t.Errorf("Referral.Schedule = %v, want [10 5 2]", cfg.Referral.Schedule)
+	}
+}
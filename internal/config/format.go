@@ -0,0 +1,45 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+// decode разбирает содержимое файла конфигурации в формате, определяемом расширением пути:
+// .yaml/.yml, .json или .toml; файл без расширения считается YAML. Все форматы разбираются
+// по тегам yaml структуры Config, поэтому имена параметров одинаковы во всех форматах.
+func decode(path string, r io.Reader, config *Config) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case "", ".yaml", ".yml":
+	case ".json":
+		// JSON является подмножеством YAML и разбирается тем же декодером после проверки синтаксиса
+		if !json.Valid(data) {
+			var v any
+			return fmt.Errorf("invalid JSON: %w", json.Unmarshal(data, &v))
+		}
+	case ".toml":
+		// Разобранный TOML приводится к YAML, чтобы применить теги yaml и разбор длительностей
+		var v map[string]any
+		if _, err := toml.Decode(string(data), &v); err != nil {
+			return err
+		}
+		if data, err = yaml.Marshal(v); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported config file extension %q, expected .yaml, .yml, .json or .toml", ext)
+	}
+
+	return yaml.Unmarshal(data, config)
+}
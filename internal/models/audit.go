@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Действия, записываемые в журнал аудита
+const (
+	AuditPasswordReset   = "password.reset"
+	AuditPointsAdjusted  = "points.adjusted"
+	AuditReferrerAdded   = "referrer.added"
+	AuditReferrerRemoved = "referrer.removed"
+	AuditReferralRotated = "referral_code.rotated"
+	AuditTaskReverted    = "task.reverted"
+	AuditTasksImported   = "tasks.imported"
+	AuditUserDeleted     = "user.deleted"
+)
+
+// AuditEntry представляет запись журнала аудита
+type AuditEntry struct {
+	ID uuid.UUID `json:"id"`
+	// ActorID пользователь, выполнивший действие
+	ActorID uuid.UUID `json:"actor_id"`
+	Action  string    `json:"action"`
+	// TargetID объект действия: пользователь, задание или корректировка
+	TargetID  uuid.UUID      `json:"target_id"`
+	Metadata  map[string]any `json:"metadata"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// AuditFilter задает необязательные условия выборки журнала аудита
+type AuditFilter struct {
+	ActorID *uuid.UUID
+	Action  string
+	Since   *time.Time
+}
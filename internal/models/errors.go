@@ -0,0 +1,85 @@
+package models
+
+import "errors"
+
+// Ошибки предметной области
+var (
+	ErrUserNotFound       = errors.New("user not found")
+	ErrInvalidPassword    = errors.New("invalid password")
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrPasswordReused     = errors.New("password was used recently")
+	ErrInvalidResetToken  = errors.New("invalid or expired password reset token")
+
+	ErrUsernameTaken     = errors.New("username already taken")
+	ErrUsernameReserved  = errors.New("username is reserved")
+	ErrUsernameUnchanged = errors.New("new username equals the current one")
+	ErrConflict          = errors.New("profile was modified concurrently")
+	ErrQueryTimeout      = errors.New("database query timed out")
+
+	ErrEmailTaken               = errors.New("email already taken")
+	ErrEmailNotSet              = errors.New("email is not set")
+	ErrEmailAlreadyVerified     = errors.New("email already verified")
+	ErrInvalidVerificationToken = errors.New("invalid or expired verification token")
+
+	ErrReferrerNotFound   = errors.New("referrer not found")
+	ErrReferrerAlreadySet = errors.New("user already has a referrer")
+	ErrReferrerUnchanged  = errors.New("referrer is already set to the requested user")
+	ErrReferrerNotSet     = errors.New("user has no referrer")
+	ErrSelfReferral       = errors.New("user cannot be their own referrer")
+	ErrReferrerRemoved    = errors.New("referrer was removed and cannot be added again")
+
+	ErrTaskNotFound        = errors.New("task not found")
+	ErrTaskAlreadyReverted = errors.New("task already reverted")
+	ErrTooManyTasks        = errors.New("too many tasks completed in a short period")
+
+	ErrInvalidPeriod = errors.New("invalid leaderboard period")
+	ErrBatchTooLarge = errors.New("batch too large")
+
+	ErrInsufficientPoints = errors.New("insufficient points")
+	ErrPointsOutOfRange   = errors.New("points balance out of range")
+)
+
+// Коды ошибок ErrorResponse.Code. Коды стабильны: клиенты различают ошибки по ним,
+// а не по тексту ErrorResponse.Error, который может меняться
+const (
+	CodeUserNotFound             = "USER_NOT_FOUND"
+	CodeInvalidPassword          = "INVALID_PASSWORD"
+	CodeInvalidCredentials       = "INVALID_CREDENTIALS"
+	CodePasswordReused           = "PASSWORD_REUSED"
+	CodeInvalidResetToken        = "INVALID_RESET_TOKEN"
+	CodeUsernameTaken            = "USERNAME_TAKEN"
+	CodeUsernameReserved         = "USERNAME_RESERVED"
+	CodeUsernameUnchanged        = "USERNAME_UNCHANGED"
+	CodeConflict                 = "CONFLICT"
+	CodeEmailTaken               = "EMAIL_TAKEN"
+	CodeEmailNotSet              = "EMAIL_NOT_SET"
+	CodeEmailAlreadyVerified     = "EMAIL_ALREADY_VERIFIED"
+	CodeInvalidVerificationToken = "INVALID_VERIFICATION_TOKEN"
+	CodeReferrerNotFound         = "REFERRER_NOT_FOUND"
+	CodeReferrerAlreadySet       = "REFERRER_ALREADY_SET"
+	CodeReferrerNotSet           = "REFERRER_NOT_SET"
+	CodeReferralCycle            = "REFERRAL_CYCLE"
+	CodeReferrerRemoved          = "REFERRER_REMOVED"
+	CodeTaskNotFound             = "TASK_NOT_FOUND"
+	CodeTaskAlreadyReverted      = "TASK_ALREADY_REVERTED"
+	CodeTooManyTasks             = "TOO_MANY_TASKS"
+	CodeInvalidPeriod            = "INVALID_PERIOD"
+	CodeBatchTooLarge            = "BATCH_TOO_LARGE"
+	CodeInsufficientPoints       = "INSUFFICIENT_POINTS"
+	CodePointsOutOfRange         = "POINTS_OUT_OF_RANGE"
+	CodeSelfTransfer             = "SELF_TRANSFER"
+	CodeTokenNotRevocable        = "TOKEN_NOT_REVOCABLE"
+	CodeValidationFailed         = "VALIDATION_FAILED"
+	CodeInvalidRequestBody       = "INVALID_REQUEST_BODY"
+	CodeInvalidParameter         = "INVALID_PARAMETER"
+	CodeRequestTooLarge          = "REQUEST_TOO_LARGE"
+	CodeUnsupportedMediaType     = "UNSUPPORTED_MEDIA_TYPE"
+	CodeMethodNotAllowed         = "METHOD_NOT_ALLOWED"
+	CodeUnauthorized             = "UNAUTHORIZED"
+	CodeForbidden                = "FORBIDDEN"
+	CodeRateLimited              = "RATE_LIMITED"
+	CodeTooManyStreamSubscribers = "TOO_MANY_STREAM_SUBSCRIBERS"
+	CodeShuttingDown             = "SHUTTING_DOWN"
+	CodeQueryTimeout             = "QUERY_TIMEOUT"
+	CodeInternal                 = "INTERNAL_ERROR"
+)
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// События о начислении баллов для внешних уведомлений
+const (
+	EventTaskCompleted    = "task.completed"
+	EventReferralCredited = "referral.credited"
+)
+
+// EventEmailVerificationRequested событие запроса подтверждения адреса электронной почты;
+// получатель уведомлений отправляет пользователю письмо со ссылкой подтверждения
+const EventEmailVerificationRequested = "email.verification_requested"
+
+// EventPasswordResetRequested событие запроса сброса пароля;
+// получатель уведомлений отправляет пользователю ссылку с токеном сброса
+const EventPasswordResetRequested = "password.reset_requested"
+
+// PointsEvent представляет уведомление о начислении баллов пользователю
+type PointsEvent struct {
+	Event  string    `json:"event"`
+	UserID uuid.UUID `json:"user_id"`
+	// TaskID и TaskType заполняются для task.completed
+	TaskID   *uuid.UUID `json:"task_id,omitempty"`
+	TaskType string     `json:"task_type,omitempty"`
+	// ReferredUserID и Level заполняются для referral.credited
+	ReferredUserID *uuid.UUID `json:"referred_user_id,omitempty"`
+	Level          int        `json:"level,omitempty"`
+	PointsDelta    int        `json:"points_delta"`
+	Balance        int        `json:"balance"`
+	OccurredAt     time.Time  `json:"occurred_at"`
+	// CorrelationID идентификатор HTTP запроса, вызвавшего начисление
+	CorrelationID string `json:"correlation_id,omitempty"`
+}
+
+// EmailVerificationEvent представляет уведомление о запросе подтверждения адреса электронной почты
+type EmailVerificationEvent struct {
+	Event      string    `json:"event"`
+	UserID     uuid.UUID `json:"user_id"`
+	Email      string    `json:"email"`
+	Token      string    `json:"token"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// PasswordResetEvent представляет уведомление о запросе сброса пароля.
+// Email заполняется, если у пользователя указан адрес.
+type PasswordResetEvent struct {
+	Event      string    `json:"event"`
+	UserID     uuid.UUID `json:"user_id"`
+	Username   string    `json:"username"`
+	Email      *string   `json:"email,omitempty"`
+	Token      string    `json:"token"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
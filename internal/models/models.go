@@ -7,42 +7,370 @@ import (
 )
 
 type UserRequest struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
+	Username string `json:"username" validate:"required,min=3,max=32"`
+	Password string `json:"password" validate:"required"`
+}
+
+// RegisterRequest представляет запрос на регистрацию пользователя; email необязателен
+type RegisterRequest struct {
+	Username string `json:"username" validate:"required,min=3,max=32"`
+	Password string `json:"password" validate:"required"`
+	Email    string `json:"email" validate:"max=254"`
 }
 
+// Роли пользователей
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 // User представляет модель пользователя
 type User struct {
 	ID         uuid.UUID  `json:"id"`
 	Username   string     `json:"username"`
-	Password   string     `json:"password"`
+	Password   string     `json:"-"`
 	Points     int        `json:"points"`
 	ReferrerID *uuid.UUID `json:"referrer_id,omitempty"`
-	CreatedAt  time.Time  `json:"created_at"`
-	UpdatedAt  time.Time  `json:"updated_at"`
+	Role       string     `json:"role,omitempty"`
+	// ReferralCode короткий код, по которому другие пользователи указывают этого пользователя реферером
+	ReferralCode string `json:"referral_code,omitempty"`
+	// Email адрес электронной почты; nil, если не указан
+	Email *string `json:"email,omitempty"`
+	// EmailVerifiedAt время подтверждения адреса; nil, если адрес не подтвержден
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	// LastLoginAt время последнего входа (UTC)
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+	// StreakDays количество дней подряд, в которые пользователь входил
+	StreakDays int `json:"streak_days"`
+	// Version версия профиля, увеличивается при каждом изменении профиля;
+	// передается в запросе изменения для защиты от потерянных обновлений
+	Version   int       `json:"version,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// PointsToNextRank баллы, недостающие до обгона пользователя на месте выше (0 для первого места);
+	// заполняется только в статусе пользователя
+	PointsToNextRank *int `json:"points_to_next_rank,omitempty"`
 }
 
 // Task представляет модель задания
 type Task struct {
-	ID          uuid.UUID `json:"id"`
-	UserID      uuid.UUID `json:"user_id"`
-	TaskType    string    `json:"task_type"`
-	Points      int       `json:"points"`
-	CompletedAt time.Time `json:"completed_at"`
+	ID          uuid.UUID  `json:"id"`
+	UserID      uuid.UUID  `json:"user_id"`
+	TaskType    string     `json:"task_type"`
+	Points      int        `json:"points"`
+	CompletedAt time.Time  `json:"completed_at"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+	// Balance баланс пользователя после начисления; заполняется только при выполнении задания
+	Balance int `json:"-"`
+}
+
+// TaskStats представляет агрегированную статистику по типу задания
+type TaskStats struct {
+	TaskType    string `json:"task_type"`
+	Count       int    `json:"count"`
+	TotalPoints int    `json:"total_points"`
+}
+
+// GlobalStats представляет общие показатели сервиса.
+// CalculatedAt время подсчета: ответ может быть взят из кеша.
+type GlobalStats struct {
+	TotalUsers int `json:"total_users"`
+	// TotalPoints сумма текущих балансов всех пользователей
+	TotalPoints int64 `json:"total_points"`
+	// TotalTasks количество выполненных и не отмененных заданий
+	TotalTasks        int       `json:"total_tasks"`
+	UsersWithReferrer int       `json:"users_with_referrer"`
+	CalculatedAt      time.Time `json:"calculated_at"`
 }
 
 // TaskRequest представляет запрос на выполнение задания
 type TaskRequest struct {
-	TaskType string `json:"task_type"`
-	Points   int    `json:"points"`
+	TaskType string `json:"task_type" validate:"required,max=255"`
+	Points   int    `json:"points" validate:"min=1"`
 }
 
-// ReferrerRequest представляет запрос на добавление реферального кода
+// TaskActivityLimit проверка количества заданий пользователя за окно, которую репозиторий
+// выполняет в транзакции выполнения задания под блокировкой строки пользователя, чтобы
+// одновременные запросы не обошли ограничение
+type TaskActivityLimit struct {
+	// Since начало окна
+	Since time.Time
+	// Check получает количество заданий пользователя, выполненных начиная с Since (включая
+	// отмененные, без текущего), и возвращает ошибку, чтобы отклонить задание. nil отключает проверку.
+	Check func(count int) error
+}
+
+// ReferrerRequest представляет запрос на добавление реферера.
+// Реферер указывается либо ID, либо реферальным кодом.
 type ReferrerRequest struct {
-	ReferrerID string `json:"referrer_id"`
+	ReferrerID   string `json:"referrer_id" validate:"uuid"`
+	ReferralCode string `json:"referral_code" validate:"max=8"`
+}
+
+// Порядок пользователей с равным балансом в таблице лидеров
+const (
+	// TiebreakerCreatedAt выше пользователь, зарегистрированный раньше
+	TiebreakerCreatedAt = "created_at"
+	// TiebreakerUsername пользователи упорядочиваются по имени
+	TiebreakerUsername = "username"
+)
+
+// Форма, в которой сохраняется имя пользователя. Уникальность и поиск по имени
+// не зависят от регистра при любом значении.
+const (
+	// UsernameCasePreserve имя сохраняется в том регистре, в котором указано
+	UsernameCasePreserve = "preserve"
+	// UsernameCaseLower имя сохраняется в нижнем регистре
+	UsernameCaseLower = "lower"
+)
+
+// LeaderboardFilter необязательные условия отбора пользователей в таблицу лидеров;
+// nil поле не ограничивает выборку
+type LeaderboardFilter struct {
+	MinPoints    *int
+	MaxPoints    *int
+	CreatedAfter *time.Time
+}
+
+// Периоды таблицы лидеров по заработанным баллам
+const (
+	PeriodWeekly  = "weekly"
+	PeriodMonthly = "monthly"
+)
+
+// PeriodLeaderboardEntry представляет позицию пользователя в таблице лидеров за период
+type PeriodLeaderboardEntry struct {
+	UserID   uuid.UUID `json:"user_id"`
+	Username string    `json:"username"`
+	Points   int       `json:"points"`
+	Rank     int       `json:"rank"`
+}
+
+// ImportUserRequest представляет пользователя для массового импорта
+type ImportUserRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Points   int    `json:"points"`
+}
+
+// ImportUsersResponse представляет результат массового импорта пользователей
+type ImportUsersResponse struct {
+	Inserted int `json:"inserted"`
+	Skipped  int `json:"skipped"`
+}
+
+// ImportTaskRequest представляет запись потокового импорта выполненных заданий
+type ImportTaskRequest struct {
+	UserID   string `json:"user_id" validate:"required,uuid"`
+	TaskType string `json:"task_type" validate:"required,max=255"`
+	Points   int    `json:"points" validate:"min=1"`
+}
+
+// Результаты обработки записи импорта заданий
+const (
+	ImportStatusImported = "imported"
+	ImportStatusFailed   = "failed"
+)
+
+// ImportTaskResult представляет результат обработки записи импорта заданий.
+// Line номер записи во входном потоке (с 1); Fields нарушения правил валидации по полям.
+type ImportTaskResult struct {
+	Line   int               `json:"line"`
+	Status string            `json:"status"`
+	TaskID *uuid.UUID        `json:"task_id,omitempty"`
+	Error  string            `json:"error,omitempty"`
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+// ImportTasksSummary представляет итог импорта заданий
+type ImportTasksSummary struct {
+	Imported int `json:"imported"`
+	Failed   int `json:"failed"`
+}
+
+// Поля сортировки списка пользователей
+const (
+	UserSortCreatedAt = "created_at"
+	UserSortPoints    = "points"
+)
+
+// UserListFilter задает необязательные условия и порядок выборки списка пользователей
+type UserListFilter struct {
+	// Search подстрока имени пользователя без учета регистра
+	Search string
+	// HasReferrer отбирает пользователей с реферером (true) или без него (false)
+	HasReferrer *bool
+	// Sort поле сортировки: UserSortCreatedAt (по умолчанию) или UserSortPoints
+	Sort string
+	// Asc сортировка по возрастанию; по умолчанию по убыванию
+	Asc bool
+}
+
+// UserSummary представляет сводку профиля пользователя для экрана профиля.
+// Rank место в таблице лидеров без фильтров, ReferralCount количество прямых рефералов.
+// Percentile доля пользователей в процентах, входящих в верхнюю часть таблицы лидеров вместе
+// с пользователем ("в топ-5%"): пользователи с равным балансом получают одинаковое значение,
+// единственный пользователь - 100.
+type UserSummary struct {
+	User          *User   `json:"user"`
+	Rank          int     `json:"rank"`
+	Percentile    float64 `json:"percentile"`
+	ReferralCount int     `json:"referral_count"`
+	RecentTasks   []*Task `json:"recent_tasks"`
+}
+
+// PublicProfile представляет публичные данные пользователя
+type PublicProfile struct {
+	ID       uuid.UUID `json:"id"`
+	Username string    `json:"username"`
+	Points   int       `json:"points"`
+}
+
+// ReferralCredit представляет бонус, начисленный рефереру уровня Level при добавлении реферала,
+// или его отмену при удалении реферера (тогда Points отрицательно)
+type ReferralCredit struct {
+	UserID  uuid.UUID
+	Level   int
+	Points  int
+	Balance int
+}
+
+// Источники начислений со сроком действия (points_expiry.ttl)
+const (
+	PointSourceTask     = "task"
+	PointSourceReferral = "referral"
+)
+
+// ReferrerChainEntry представляет реферера в цепочке; Level 1 - прямой реферер пользователя
+type ReferrerChainEntry struct {
+	ID       uuid.UUID `json:"id"`
+	Username string    `json:"username"`
+	Points   int       `json:"points"`
+	Level    int       `json:"level"`
+}
+
+// AdjustPointsRequest представляет запрос администратора на изменение баланса пользователя
+type AdjustPointsRequest struct {
+	Delta  int    `json:"delta" validate:"required"`
+	Reason string `json:"reason" validate:"required,max=500"`
+}
+
+// PointAdjustment представляет ручную корректировку баланса администратором
+type PointAdjustment struct {
+	ID      uuid.UUID `json:"id"`
+	UserID  uuid.UUID `json:"user_id"`
+	AdminID uuid.UUID `json:"admin_id"`
+	// Delta запрошенное изменение, AppliedDelta - фактическое с учетом того, что баланс не опускается ниже нуля
+	Delta        int       `json:"delta"`
+	AppliedDelta int       `json:"applied_delta"`
+	Reason       string    `json:"reason"`
+	Balance      int       `json:"balance"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// RegisterResponse представляет ответ на регистрацию пользователя
+type RegisterResponse struct {
+	User  *User  `json:"user"`
+	Token string `json:"token"`
+}
+
+// LoginResponse представляет ответ на вход пользователя
+type LoginResponse struct {
+	User        *User  `json:"user"`
+	Token       string `json:"token"`
+	StreakDays  int    `json:"streak_days"`
+	StreakBonus int    `json:"streak_bonus"`
+}
+
+// UpdateProfileRequest представляет запрос на изменение профиля пользователя
+// Version - версия профиля, на основе которой сделано изменение; 0 или отсутствие - без проверки.
+type UpdateProfileRequest struct {
+	Username string `json:"username" validate:"required"`
+	Version  int    `json:"version" validate:"min=0"`
+}
+
+// ChangePasswordRequest представляет запрос на смену пароля
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required"`
+}
+
+// PasswordResetRequest представляет запрос на сброс пароля по имени пользователя или адресу электронной почты
+type PasswordResetRequest struct {
+	Login string `json:"login" validate:"required,max=254"`
+}
+
+// PasswordResetRequestedResponse представляет ответ на запрос сброса пароля.
+// Ответ одинаков для существующих и несуществующих пользователей.
+type PasswordResetRequestedResponse struct {
+	Message string `json:"message"`
+}
+
+// PasswordResetConfirmRequest представляет запрос на установку нового пароля по токену сброса
+type PasswordResetConfirmRequest struct {
+	Token       string `json:"token" validate:"required,max=128"`
+	NewPassword string `json:"new_password" validate:"required"`
+}
+
+// TransferRequest представляет запрос на перевод баллов другому пользователю
+type TransferRequest struct {
+	To     string `json:"to" validate:"required,uuid"`
+	Amount int    `json:"amount" validate:"min=1"`
+}
+
+// TransferResponse представляет результат перевода баллов
+type TransferResponse struct {
+	From    uuid.UUID `json:"from"`
+	To      uuid.UUID `json:"to"`
+	Amount  int       `json:"amount"`
+	Balance int       `json:"balance"`
+}
+
+// ReferralCodeResponse представляет ответ с новым реферальным кодом пользователя
+type ReferralCodeResponse struct {
+	ReferralCode string `json:"referral_code"`
+}
+
+// UsernameAvailabilityResponse представляет ответ на проверку доступности имени пользователя
+type UsernameAvailabilityResponse struct {
+	Username  string `json:"username"`
+	Available bool   `json:"available"`
+}
+
+// PublicConfig представляет несекретные параметры сервера, необходимые клиентам
+type PublicConfig struct {
+	ReferralBonus int `json:"referral_bonus"`
+	// ReferralSchedule бонусы по уровням цепочки рефереров, начиная с прямого реферера
+	ReferralSchedule        []int `json:"referral_schedule"`
+	LeaderboardDefaultLimit int   `json:"leaderboard_default_limit"`
+	LeaderboardMaxLimit     int   `json:"leaderboard_max_limit"`
 }
 
 // ErrorResponse представляет ответ с ошибкой
 type ErrorResponse struct {
 	Error string `json:"error"`
+	// Code стабильный код ошибки (models.Code*)
+	Code  string `json:"code"`
+	Field string `json:"field,omitempty"`
+	// Fields описания нарушений по всем полям запроса (поле -> сообщение)
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+// Значения HealthResponse.Status
+const (
+	HealthStatusOK       = "ok"
+	HealthStatusDraining = "draining"
+)
+
+// HealthResponse представляет ответ проверки состояния сервера
+type HealthResponse struct {
+	Status string `json:"status"`
+}
+
+// EmailVerificationResponse представляет ответ на запрос подтверждения адреса электронной почты.
+// Token заполняется, только если сервер настроен возвращать его клиенту (локальная разработка).
+type EmailVerificationResponse struct {
+	Email     string    `json:"email"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Token     string    `json:"token,omitempty"`
 }
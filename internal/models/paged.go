@@ -0,0 +1,25 @@
+package models
+
+// PagedResponse представляет страницу списка с метаданными пагинации
+type PagedResponse[T any] struct {
+	Items   []T  `json:"items"`
+	Total   int  `json:"total"`
+	Limit   int  `json:"limit"`
+	Offset  int  `json:"offset"`
+	HasMore bool `json:"has_more"`
+}
+
+// NewPagedResponse создает страницу списка; nil-срез элементов заменяется пустым,
+// чтобы items сериализовался как [], а не null
+func NewPagedResponse[T any](items []T, total, limit, offset int) PagedResponse[T] {
+	if items == nil {
+		items = []T{}
+	}
+	return PagedResponse[T]{
+		Items:   items,
+		Total:   total,
+		Limit:   limit,
+		Offset:  offset,
+		HasMore: offset+len(items) < total,
+	}
+}
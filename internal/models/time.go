@@ -0,0 +1,118 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// TimeLayout формат временных меток в JSON ответах и событиях: UTC, RFC3339 с миллисекундами.
+// Значения в этом формате разбираются стандартным time.Time.UnmarshalJSON, поэтому отдельная
+// десериализация моделей не требуется.
+const TimeLayout = "2006-01-02T15:04:05.000Z07:00"
+
+// jsonTime сериализует время в формате TimeLayout
+type jsonTime time.Time
+
+// MarshalJSON реализует json.Marshaler
+func (t jsonTime) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + time.Time(t).UTC().Format(TimeLayout) + `"`), nil
+}
+
+// optionalTime преобразует необязательное время для сериализации, сохраняя nil
+func optionalTime(t *time.Time) *jsonTime {
+	if t == nil {
+		return nil
+	}
+	jt := jsonTime(*t)
+	return &jt
+}
+
+// Методы MarshalJSON ниже подменяют поля времени одноименными полями jsonTime;
+// остальные поля сериализуются как обычно через встроенный псевдоним типа.
+
+// MarshalJSON реализует json.Marshaler
+func (u User) MarshalJSON() ([]byte, error) {
+	type user User
+	return json.Marshal(struct {
+		user
+		EmailVerifiedAt *jsonTime `json:"email_verified_at,omitempty"`
+		LastLoginAt     *jsonTime `json:"last_login_at,omitempty"`
+		CreatedAt       jsonTime  `json:"created_at"`
+		UpdatedAt       jsonTime  `json:"updated_at"`
+	}{user(u), optionalTime(u.EmailVerifiedAt), optionalTime(u.LastLoginAt), jsonTime(u.CreatedAt), jsonTime(u.UpdatedAt)})
+}
+
+// MarshalJSON реализует json.Marshaler
+func (t Task) MarshalJSON() ([]byte, error) {
+	type task Task
+	return json.Marshal(struct {
+		task
+		CompletedAt jsonTime  `json:"completed_at"`
+		DeletedAt   *jsonTime `json:"deleted_at,omitempty"`
+	}{task(t), jsonTime(t.CompletedAt), optionalTime(t.DeletedAt)})
+}
+
+// MarshalJSON реализует json.Marshaler
+func (s GlobalStats) MarshalJSON() ([]byte, error) {
+	type globalStats GlobalStats
+	return json.Marshal(struct {
+		globalStats
+		CalculatedAt jsonTime `json:"calculated_at"`
+	}{globalStats(s), jsonTime(s.CalculatedAt)})
+}
+
+// MarshalJSON реализует json.Marshaler
+func (a PointAdjustment) MarshalJSON() ([]byte, error) {
+	type pointAdjustment PointAdjustment
+	return json.Marshal(struct {
+		pointAdjustment
+		CreatedAt jsonTime `json:"created_at"`
+	}{pointAdjustment(a), jsonTime(a.CreatedAt)})
+}
+
+// MarshalJSON реализует json.Marshaler
+func (r EmailVerificationResponse) MarshalJSON() ([]byte, error) {
+	type emailVerificationResponse EmailVerificationResponse
+	return json.Marshal(struct {
+		emailVerificationResponse
+		ExpiresAt jsonTime `json:"expires_at"`
+	}{emailVerificationResponse(r), jsonTime(r.ExpiresAt)})
+}
+
+// MarshalJSON реализует json.Marshaler
+func (e AuditEntry) MarshalJSON() ([]byte, error) {
+	type auditEntry AuditEntry
+	return json.Marshal(struct {
+		auditEntry
+		CreatedAt jsonTime `json:"created_at"`
+	}{auditEntry(e), jsonTime(e.CreatedAt)})
+}
+
+// MarshalJSON реализует json.Marshaler
+func (e PointsEvent) MarshalJSON() ([]byte, error) {
+	type pointsEvent PointsEvent
+	return json.Marshal(struct {
+		pointsEvent
+		OccurredAt jsonTime `json:"occurred_at"`
+	}{pointsEvent(e), jsonTime(e.OccurredAt)})
+}
+
+// MarshalJSON реализует json.Marshaler
+func (e EmailVerificationEvent) MarshalJSON() ([]byte, error) {
+	type emailVerificationEvent EmailVerificationEvent
+	return json.Marshal(struct {
+		emailVerificationEvent
+		ExpiresAt  jsonTime `json:"expires_at"`
+		OccurredAt jsonTime `json:"occurred_at"`
+	}{emailVerificationEvent(e), jsonTime(e.ExpiresAt), jsonTime(e.OccurredAt)})
+}
+
+// MarshalJSON реализует json.Marshaler
+func (e PasswordResetEvent) MarshalJSON() ([]byte, error) {
+	type passwordResetEvent PasswordResetEvent
+	return json.Marshal(struct {
+		passwordResetEvent
+		ExpiresAt  jsonTime `json:"expires_at"`
+		OccurredAt jsonTime `json:"occurred_at"`
+	}{passwordResetEvent(e), jsonTime(e.ExpiresAt), jsonTime(e.OccurredAt)})
+}
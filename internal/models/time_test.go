@@ -0,0 +1,96 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestTimeJSONFormat(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 30, 45, 123456789, time.FixedZone("MSK", 3*60*60))
+	const want = "2024-05-01T09:30:45.123Z"
+
+	tests := []struct {
+		name   string
+		value  any
+		fields []string
+	}{
+		{
+			name:   "user",
+			value:  &User{ID: uuid.New(), Username: "alice", EmailVerifiedAt: &at, LastLoginAt: &at, CreatedAt: at, UpdatedAt: at},
+			fields: []string{"email_verified_at", "last_login_at", "created_at", "updated_at"},
+		},
+		{name: "task", value: &Task{ID: uuid.New(), TaskType: "daily", CompletedAt: at, DeletedAt: &at}, fields: []string{"completed_at", "deleted_at"}},
+		{name: "global stats", value: &GlobalStats{TotalUsers: 1, CalculatedAt: at}, fields: []string{"calculated_at"}},
+		{name: "point adjustment", value: &PointAdjustment{ID: uuid.New(), Delta: 5, CreatedAt: at}, fields: []string{"created_at"}},
+		{name: "email verification response", value: &EmailVerificationResponse{Email: "alice@example.com", ExpiresAt: at}, fields: []string{"expires_at"}},
+		{name: "audit entry", value: &AuditEntry{ID: uuid.New(), Action: AuditUserDeleted, CreatedAt: at}, fields: []string{"created_at"}},
+		{name: "points event", value: &PointsEvent{Event: EventTaskCompleted, UserID: uuid.New(), OccurredAt: at}, fields: []string{"occurred_at"}},
+		{
+			name:   "email verification event",
+			value:  &EmailVerificationEvent{Event: EventEmailVerificationRequested, UserID: uuid.New(), ExpiresAt: at, OccurredAt: at},
+			fields: []string{"expires_at", "occurred_at"},
+		},
+		{
+			name:   "password reset event",
+			value:  &PasswordResetEvent{Event: EventPasswordResetRequested, UserID: uuid.New(), ExpiresAt: at, OccurredAt: at},
+			fields: []string{"expires_at", "occurred_at"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(tt.value)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+
+			var fields map[string]any
+			if err := json.Unmarshal(data, &fields); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			for _, field := range tt.fields {
+				if fields[field] != want {
+					t.Errorf("%s = %v, want %q", field, fields[field], want)
+				}
+			}
+
+			// Разобранное стандартным time.Time значение сериализуется в те же байты
+			decoded := reflect.New(reflect.TypeOf(tt.value).Elem()).Interface()
+			if err := json.Unmarshal(data, decoded); err != nil {
+				t.Fatalf("Unmarshal() into %T error = %v", decoded, err)
+			}
+			again, err := json.Marshal(decoded)
+			if err != nil {
+				t.Fatalf("Marshal() round trip error = %v", err)
+			}
+			if !bytes.Equal(again, data) {
+				t.Errorf("round trip = %s, want %s", again, data)
+			}
+		})
+	}
+}
+
+func TestTimeJSONKeepsOtherFields(t *testing.T) {
+	data, err := json.Marshal(User{Username: "alice", Points: 10})
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if fields["username"] != "alice" || fields["points"] != float64(10) {
+		t.Errorf("user = %s, want username and points kept", data)
+	}
+	for _, field := range []string{"email_verified_at", "last_login_at"} {
+		if _, ok := fields[field]; ok {
+			t.Errorf("%s present for nil time, want omitted", field)
+		}
+	}
+}
@@ -0,0 +1,403 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/logger"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// DeleteUser удаляет пользователя вместе с его заданиями, историей паролей и корректировками.
+// У приглашенных им пользователей реферер сбрасывается.
+func (r *Repository) DeleteUser(ctx context.Context, userID uuid.UUID) error {
+	log := logger.FromContext(ctx, r.log)
+	log.Info("Deleting user", zap.String("user_id", userID.String()))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.users[userID]; !ok {
+		log.Warn("User not found", zap.String("user_id", userID.String()))
+		return models.ErrUserNotFound
+	}
+
+	now := time.Now()
+	for _, user := range r.users {
+		if user.ReferrerID != nil && *user.ReferrerID == userID {
+			user.ReferrerID = nil
+			user.UpdatedAt = now
+			delete(r.referralCredits, user.ID)
+		}
+	}
+	delete(r.referralCredits, userID)
+	delete(r.pointEntries, userID)
+	for link := range r.removedReferrers {
+		if link.userID == userID || link.referrerID == userID {
+			delete(r.removedReferrers, link)
+		}
+	}
+	for id, credits := range r.referralCredits {
+		r.referralCredits[id] = slices.DeleteFunc(credits, func(credit models.ReferralCredit) bool {
+			return credit.UserID == userID
+		})
+	}
+	for id, task := range r.tasks {
+		if task.UserID == userID {
+			delete(r.tasks, id)
+		}
+	}
+	adjustments := r.adjustments[:0]
+	for _, adjustment := range r.adjustments {
+		if adjustment.UserID != userID {
+			adjustments = append(adjustments, adjustment)
+		}
+	}
+	r.adjustments = adjustments
+	delete(r.passwordHistory, userID)
+	delete(r.users, userID)
+
+	return nil
+}
+
+// UpdateUsername изменяет имя пользователя и увеличивает версию профиля.
+// При expectedVersion > 0 и несовпадении версии возвращает models.ErrConflict.
+// Возвращает models.ErrUsernameUnchanged, если имя совпадает с текущим, и models.ErrUsernameTaken, если имя занято.
+func (r *Repository) UpdateUsername(ctx context.Context, userID uuid.UUID, newUsername string, expectedVersion int) error {
+	log := logger.FromContext(ctx, r.log)
+	log.Info("Updating username",
+		zap.String("user_id", userID.String()),
+		zap.String("username", newUsername))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[userID]
+	if !ok {
+		log.Warn("User not found", zap.String("user_id", userID.String()))
+		return models.ErrUserNotFound
+	}
+	if expectedVersion > 0 && user.Version != expectedVersion {
+		log.Warn("Profile version mismatch",
+			zap.String("user_id", userID.String()),
+			zap.Int("expected_version", expectedVersion),
+			zap.Int("version", user.Version))
+		return models.ErrConflict
+	}
+	if user.Username == newUsername {
+		return models.ErrUsernameUnchanged
+	}
+	// Смена регистра собственного имени не считается конфликтом
+	if found := r.findByUsername(newUsername); found != nil && found.ID != userID {
+		log.Warn("Username already taken", zap.String("username", newUsername))
+		return models.ErrUsernameTaken
+	}
+
+	user.Username = newUsername
+	user.Version++
+	user.UpdatedAt = time.Now()
+	return nil
+}
+
+// GetUserByUsername возвращает публичный профиль пользователя по имени без учета регистра.
+// При совпадении нескольких имен, отличающихся регистром, предпочитается точное совпадение.
+func (r *Repository) GetUserByUsername(ctx context.Context, username string) (*models.PublicProfile, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var found *models.User
+	for _, user := range r.users {
+		if user.Username == username {
+			found = user
+			break
+		}
+		if found == nil && strings.EqualFold(user.Username, username) {
+			found = user
+		}
+	}
+	if found == nil {
+		return nil, models.ErrUserNotFound
+	}
+
+	return &models.PublicProfile{ID: found.ID, Username: found.Username, Points: found.Points}, nil
+}
+
+// GetCredentials возвращает ID и хеш пароля пользователя по имени без учета регистра
+func (r *Repository) GetCredentials(ctx context.Context, username string) (uuid.UUID, string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user := r.findByUsername(username)
+	if user == nil {
+		return uuid.Nil, "", models.ErrUserNotFound
+	}
+	return user.ID, user.Password, nil
+}
+
+// RecordLogin фиксирует вход пользователя и обновляет серию ежедневных входов
+// по тем же правилам, что и хранилище PostgreSQL (сутки по UTC).
+// Возвращает текущую длину серии и начисленный бонус.
+func (r *Repository) RecordLogin(ctx context.Context, userID uuid.UUID, now time.Time) (int, int, error) {
+	log := logger.FromContext(ctx, r.log)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[userID]
+	if !ok {
+		log.Warn("User not found", zap.String("user_id", userID.String()))
+		return 0, 0, models.ErrUserNotFound
+	}
+
+	now = now.UTC()
+	bonus := 0
+	switch days := daysBetween(user.LastLoginAt, now); {
+	case days == 0:
+		// Повторный вход в тот же день
+	case days == 1:
+		user.StreakDays++
+		bonus = r.opts.StreakBonus
+	default:
+		user.StreakDays = 1
+	}
+
+	// Бонус не начисляется, если баланс выйдет за допустимые границы
+	if bonus > 0 && checkPointsRange(user.Points, bonus) != nil {
+		log.Warn("Streak bonus skipped: points out of range", zap.String("user_id", userID.String()))
+		bonus = 0
+	}
+
+	user.LastLoginAt = &now
+	user.Points += bonus
+	user.UpdatedAt = time.Now()
+	return user.StreakDays, bonus, nil
+}
+
+// daysBetween возвращает количество календарных суток UTC между последним входом и now;
+// -1, если вход выполняется впервые
+func daysBetween(last *time.Time, now time.Time) int {
+	if last == nil {
+		return -1
+	}
+	day := func(t time.Time) time.Time {
+		t = t.UTC()
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+	return int(day(now).Sub(day(*last)).Hours() / 24)
+}
+
+// GetPasswordHash возвращает текущий хеш пароля пользователя
+func (r *Repository) GetPasswordHash(ctx context.Context, userID uuid.UUID) (string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user, ok := r.users[userID]
+	if !ok {
+		return "", models.ErrUserNotFound
+	}
+	return user.Password, nil
+}
+
+// GetPasswordHistory возвращает последние limit предыдущих хешей пароля пользователя
+func (r *Repository) GetPasswordHistory(ctx context.Context, userID uuid.UUID, limit int) ([]string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	history := r.passwordHistory[userID]
+	hashes := make([]string, 0, min(limit, len(history)))
+	for i := len(history) - 1; i >= 0 && len(hashes) < limit; i-- {
+		hashes = append(hashes, history[i])
+	}
+	return hashes, nil
+}
+
+// UpdatePassword заменяет хеш пароля пользователя, сохраняя предыдущий в истории.
+// В истории остаются только historyLimit последних хешей; при historyLimit <= 0 история не ведется.
+func (r *Repository) UpdatePassword(ctx context.Context, userID uuid.UUID, newHash string, historyLimit int) error {
+	log := logger.FromContext(ctx, r.log)
+	log.Info("Updating password", zap.String("user_id", userID.String()))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[userID]
+	if !ok {
+		log.Warn("User not found", zap.String("user_id", userID.String()))
+		return models.ErrUserNotFound
+	}
+
+	r.updatePassword(user, newHash, historyLimit)
+	return nil
+}
+
+// updatePassword заменяет хеш пароля пользователя, сохраняя предыдущий в истории.
+// Вызывается под блокировкой r.mu.
+func (r *Repository) updatePassword(user *models.User, newHash string, historyLimit int) {
+	if historyLimit > 0 {
+		history := append(r.passwordHistory[user.ID], user.Password)
+		if len(history) > historyLimit {
+			history = history[len(history)-historyLimit:]
+		}
+		r.passwordHistory[user.ID] = history
+	}
+
+	user.Password = newHash
+	user.UpdatedAt = time.Now()
+}
+
+// TransferPoints переводит баллы от одного пользователя другому и возвращает новый баланс отправителя
+func (r *Repository) TransferPoints(ctx context.Context, fromID, toID uuid.UUID, amount int) (int, error) {
+	log := logger.FromContext(ctx, r.log)
+	log.Info("Transferring points",
+		zap.String("from_id", fromID.String()),
+		zap.String("to_id", toID.String()),
+		zap.Int("amount", amount))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	from, fromOK := r.users[fromID]
+	to, toOK := r.users[toID]
+	if !fromOK || !toOK {
+		log.Warn("User not found",
+			zap.String("from_id", fromID.String()),
+			zap.String("to_id", toID.String()))
+		return 0, models.ErrUserNotFound
+	}
+	if from.Points < amount {
+		return 0, models.ErrInsufficientPoints
+	}
+	if err := checkPointsRange(to.Points, amount); err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	from.Points -= amount
+	from.UpdatedAt = now
+	r.consumePointEntries(fromID, amount, now)
+	to.Points += amount
+	to.UpdatedAt = now
+	return from.Points, nil
+}
+
+// ImportUsers создает пользователей с уже захешированными паролями.
+// Пользователи с занятыми именами (в том числе повторяющимися внутри пакета) пропускаются;
+// баланс вне допустимых границ отменяет импорт целиком.
+// Возвращает количество созданных пользователей.
+func (r *Repository) ImportUsers(ctx context.Context, users []models.ImportUserRequest) (int, error) {
+	log := logger.FromContext(ctx, r.log)
+	log.Info("Importing users", zap.Int("count", len(users)))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, user := range users {
+		if err := checkPointsRange(0, user.Points); err != nil {
+			log.Warn("Imported points out of range", zap.String("username", user.Username))
+			return 0, err
+		}
+	}
+
+	now := time.Now()
+	inserted := 0
+	for _, user := range users {
+		if r.findByUsername(user.Username) != nil {
+			continue
+		}
+		code, err := r.uniqueReferralCode()
+		if err != nil {
+			log.Error("Failed to generate referral code", zap.Error(err))
+			return inserted, err
+		}
+
+		id := uuid.New()
+		r.users[id] = &models.User{
+			ID:           id,
+			Username:     user.Username,
+			Password:     user.Password,
+			Points:       user.Points,
+			Role:         models.RoleUser,
+			ReferralCode: code,
+			Version:      1,
+			CreatedAt:    now,
+			UpdatedAt:    now,
+		}
+		inserted++
+	}
+
+	log.Info("Users imported successfully",
+		zap.Int("inserted", inserted),
+		zap.Int("skipped", len(users)-inserted))
+	return inserted, nil
+}
+
+// AdjustPoints изменяет баланс пользователя на delta по решению администратора adminID
+// и сохраняет корректировку. Баланс не опускается ниже нуля: фактическое изменение
+// возвращается в AppliedDelta.
+func (r *Repository) AdjustPoints(ctx context.Context, adminID, userID uuid.UUID, delta int, reason string) (*models.PointAdjustment, error) {
+	log := logger.FromContext(ctx, r.log)
+	log.Info("Adjusting points",
+		zap.String("admin_id", adminID.String()),
+		zap.String("user_id", userID.String()),
+		zap.Int("delta", delta))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[userID]
+	if !ok {
+		log.Warn("User not found", zap.String("user_id", userID.String()))
+		return nil, models.ErrUserNotFound
+	}
+
+	balance := max(int64(user.Points)+int64(delta), 0)
+	if balance > maxPoints {
+		return nil, models.ErrPointsOutOfRange
+	}
+
+	adjustment := &models.PointAdjustment{
+		ID:           uuid.New(),
+		UserID:       userID,
+		AdminID:      adminID,
+		Delta:        delta,
+		AppliedDelta: int(balance) - user.Points,
+		Reason:       reason,
+		Balance:      int(balance),
+		CreatedAt:    time.Now(),
+	}
+	user.Points = adjustment.Balance
+	user.UpdatedAt = adjustment.CreatedAt
+	r.consumePointEntries(userID, -adjustment.AppliedDelta, adjustment.CreatedAt)
+	r.adjustments = append(r.adjustments, adjustment)
+
+	applied := *adjustment
+	return &applied, nil
+}
+
+// VerifyEmail отмечает адрес пользователя подтвержденным, если он по-прежнему равен email.
+// Возвращает models.ErrInvalidVerificationToken, если пользователь удален или сменил адрес.
+func (r *Repository) VerifyEmail(ctx context.Context, userID uuid.UUID, email string, at time.Time) error {
+	log := logger.FromContext(ctx, r.log)
+	log.Info("Verifying email", zap.String("user_id", userID.String()))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[userID]
+	if !ok || user.Email == nil || !strings.EqualFold(*user.Email, email) {
+		log.Warn("Email does not match the verification token", zap.String("user_id", userID.String()))
+		return models.ErrInvalidVerificationToken
+	}
+
+	// Повторное подтверждение сохраняет время первого
+	if user.EmailVerifiedAt == nil {
+		verifiedAt := at
+		user.EmailVerifiedAt = &verifiedAt
+	}
+	user.UpdatedAt = time.Now()
+	return nil
+}
@@ -0,0 +1,62 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"github.com/google/uuid"
+)
+
+// Record добавляет запись в журнал аудита. metadata сохраняется в виде JSON,
+// чтобы значения читались так же, как из JSONB в PostgreSQL.
+func (r *Repository) Record(ctx context.Context, actorID uuid.UUID, action string, targetID uuid.UUID, metadata map[string]any) error {
+	if metadata == nil {
+		metadata = map[string]any{}
+	}
+	raw, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit metadata: %w", err)
+	}
+	var stored map[string]any
+	if err := json.Unmarshal(raw, &stored); err != nil {
+		return fmt.Errorf("failed to decode audit metadata: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.audit = append(r.audit, &models.AuditEntry{
+		ID:        uuid.New(),
+		ActorID:   actorID,
+		Action:    action,
+		TargetID:  targetID,
+		Metadata:  stored,
+		CreatedAt: time.Now(),
+	})
+	return nil
+}
+
+// ListAudit возвращает страницу журнала аудита, начиная с последних записей,
+// и общее количество записей, подходящих под фильтр
+func (r *Repository) ListAudit(ctx context.Context, filter models.AuditFilter, limit, offset int) ([]*models.AuditEntry, int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var entries []*models.AuditEntry
+	for _, entry := range r.audit {
+		if (filter.ActorID != nil && entry.ActorID != *filter.ActorID) ||
+			(filter.Action != "" && entry.Action != filter.Action) ||
+			(filter.Since != nil && entry.CreatedAt.Before(*filter.Since)) {
+			continue
+		}
+		found := *entry
+		entries = append(entries, &found)
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].CreatedAt.After(entries[j].CreatedAt) })
+
+	return page(entries, limit, offset), len(entries), nil
+}
@@ -0,0 +1,96 @@
+package memory
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/logger"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// passwordReset запись о выданном токене сброса пароля
+type passwordReset struct {
+	userID    uuid.UUID
+	expiresAt time.Time
+	used      bool
+}
+
+// GetUserByLogin возвращает пользователя по имени или адресу электронной почты без учета регистра.
+// Заполняются только ID, Username и Email.
+func (r *Repository) GetUserByLogin(ctx context.Context, login string) (*models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var byEmail *models.User
+	for _, user := range r.users {
+		if strings.EqualFold(user.Username, login) {
+			return &models.User{ID: user.ID, Username: user.Username, Email: user.Email}, nil
+		}
+		if user.Email != nil && strings.EqualFold(*user.Email, login) {
+			byEmail = user
+		}
+	}
+	if byEmail == nil {
+		return nil, models.ErrUserNotFound
+	}
+	return &models.User{ID: byEmail.ID, Username: byEmail.Username, Email: byEmail.Email}, nil
+}
+
+// CreatePasswordResetToken сохраняет хеш токена сброса пароля, действительного до expiresAt
+func (r *Repository) CreatePasswordResetToken(ctx context.Context, userID uuid.UUID, tokenHash string, expiresAt time.Time) error {
+	log := logger.FromContext(ctx, r.log)
+	log.Info("Creating password reset token", zap.String("user_id", userID.String()))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.passwordResets[tokenHash] = &passwordReset{userID: userID, expiresAt: expiresAt}
+	return nil
+}
+
+// GetPasswordResetToken возвращает пользователя, которому выдан неиспользованный
+// и не истекший к моменту now токен. Иначе возвращает models.ErrInvalidResetToken.
+func (r *Repository) GetPasswordResetToken(ctx context.Context, tokenHash string, now time.Time) (uuid.UUID, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	reset, ok := r.passwordResets[tokenHash]
+	if !ok || reset.used || !now.Before(reset.expiresAt) {
+		return uuid.Nil, models.ErrInvalidResetToken
+	}
+	return reset.userID, nil
+}
+
+// ResetPassword помечает токен использованным и заменяет пароль пользователя.
+// Остальные неиспользованные токены пользователя также аннулируются.
+func (r *Repository) ResetPassword(ctx context.Context, tokenHash, newHash string, historyLimit int, now time.Time) (uuid.UUID, error) {
+	log := logger.FromContext(ctx, r.log)
+	log.Info("Resetting password")
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reset, ok := r.passwordResets[tokenHash]
+	if !ok || reset.used || !now.Before(reset.expiresAt) {
+		log.Warn("Password reset token is invalid, expired or already used")
+		return uuid.Nil, models.ErrInvalidResetToken
+	}
+	user, ok := r.users[reset.userID]
+	if !ok {
+		log.Warn("Password reset token belongs to a deleted user")
+		return uuid.Nil, models.ErrInvalidResetToken
+	}
+
+	for _, other := range r.passwordResets {
+		if other.userID == reset.userID {
+			other.used = true
+		}
+	}
+	r.updatePassword(user, newHash, historyLimit)
+
+	log.Info("Password reset successfully", zap.String("user_id", user.ID.String()))
+	return user.ID, nil
+}
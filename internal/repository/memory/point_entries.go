@@ -0,0 +1,83 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/logger"
+	"github.com/google/uuid"
+)
+
+// pointEntry начисление со сроком действия; remaining - еще не потраченная часть
+type pointEntry struct {
+	remaining int
+	expiresAt time.Time
+}
+
+// recordPointEntry записывает начисление points баллов пользователю, которое сгорит через
+// Options.PointsTTL. Без PointsTTL начисления не записываются и не сгорают.
+// Вызывается под блокировкой записи.
+func (r *Repository) recordPointEntry(userID uuid.UUID, points int, now time.Time) {
+	if r.opts.PointsTTL <= 0 || points <= 0 {
+		return
+	}
+	// Срок действия одинаков для всех начислений, поэтому записи упорядочены по expiresAt
+	r.pointEntries[userID] = append(r.pointEntries[userID], &pointEntry{
+		remaining: points,
+		expiresAt: now.Add(r.opts.PointsTTL),
+	})
+}
+
+// consumePointEntries отмечает amount списанных с баланса пользователя баллов как потраченные,
+// уменьшая непотраченные остатки начиная с тех, что сгорают раньше. Вызывается под блокировкой записи.
+func (r *Repository) consumePointEntries(userID uuid.UUID, amount int, now time.Time) {
+	for _, entry := range r.pointEntries[userID] {
+		if amount <= 0 {
+			return
+		}
+		if entry.remaining == 0 || !entry.expiresAt.After(now) {
+			continue
+		}
+		spent := min(entry.remaining, amount)
+		entry.remaining -= spent
+		amount -= spent
+	}
+}
+
+// ExpirePoints списывает с балансов непотраченные остатки начислений, срок которых истек
+// (баланс не опускается ниже нуля), и возвращает количество пользователей, чей баланс уменьшился
+func (r *Repository) ExpirePoints(ctx context.Context) (int, error) {
+	log := logger.FromContext(ctx, r.log)
+	log.Debug("Expiring points")
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	affected := 0
+	for userID, entries := range r.pointEntries {
+		expired := 0
+		live := entries[:0]
+		for _, entry := range entries {
+			if entry.expiresAt.After(now) {
+				live = append(live, entry)
+				continue
+			}
+			expired += entry.remaining
+		}
+		if len(live) == 0 {
+			delete(r.pointEntries, userID)
+		} else {
+			r.pointEntries[userID] = live
+		}
+
+		user, ok := r.users[userID]
+		if !ok || expired == 0 || user.Points == 0 {
+			continue
+		}
+		user.Points -= min(user.Points, expired)
+		user.UpdatedAt = now
+		affected++
+	}
+	return affected, nil
+}
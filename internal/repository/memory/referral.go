@@ -0,0 +1,223 @@
+package memory
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/logger"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// referralCodeAttempts количество попыток генерации кода при коллизии
+const referralCodeAttempts = 5
+
+// referralLink связь пользователя с реферером
+type referralLink struct {
+	userID     uuid.UUID
+	referrerID uuid.UUID
+}
+
+// uniqueReferralCode генерирует реферальный код из 8 символов base32, не занятый другими
+// пользователями. Вызывается под блокировкой r.mu.
+func (r *Repository) uniqueReferralCode() (string, error) {
+	for attempt := 0; attempt < referralCodeAttempts; attempt++ {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return "", fmt.Errorf("failed to generate referral code: %w", err)
+		}
+		code := base32.StdEncoding.EncodeToString(b)
+		if !r.referralCodeTaken(code) {
+			return code, nil
+		}
+	}
+	return "", errors.New("failed to generate unique referral code")
+}
+
+// RegenerateReferralCode выдает пользователю новый реферальный код; прежний код сразу перестает
+// находить пользователя. Возвращает models.ErrUserNotFound, если пользователь не найден.
+func (r *Repository) RegenerateReferralCode(ctx context.Context, userID uuid.UUID) (string, error) {
+	log := logger.FromContext(ctx, r.log)
+	log.Info("Regenerating referral code", zap.String("user_id", userID.String()))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[userID]
+	if !ok {
+		log.Warn("User not found", zap.String("user_id", userID.String()))
+		return "", models.ErrUserNotFound
+	}
+	code, err := r.uniqueReferralCode()
+	if err != nil {
+		log.Error("Failed to regenerate referral code", zap.Error(err))
+		return "", err
+	}
+
+	user.ReferralCode = code
+	user.UpdatedAt = time.Now()
+	return code, nil
+}
+
+func (r *Repository) referralCodeTaken(code string) bool {
+	for _, user := range r.users {
+		if user.ReferralCode == code {
+			return true
+		}
+	}
+	return false
+}
+
+// referrerCredits рассчитывает бонусы цепочке рефереров, начиная с referrerID (уровень 1).
+// Пользователь userID и повторно встреченные в цепочке пользователи бонусов не получают.
+// Вызывается под блокировкой r.mu.
+func (r *Repository) referrerCredits(userID, referrerID uuid.UUID) []models.ReferralCredit {
+	var credits []models.ReferralCredit
+	visited := map[uuid.UUID]bool{userID: true}
+
+	id := &referrerID
+	for level := 1; level <= len(r.opts.ReferralSchedule) && id != nil; level++ {
+		user, ok := r.users[*id]
+		if !ok || visited[user.ID] {
+			break
+		}
+		visited[user.ID] = true
+
+		if bonus := r.opts.ReferralSchedule[level-1]; bonus != 0 {
+			credits = append(credits, models.ReferralCredit{UserID: user.ID, Level: level, Points: bonus})
+		}
+		id = user.ReferrerID
+	}
+	return credits
+}
+
+// GetUserByReferralCode возвращает пользователя по реферальному коду (без учета регистра)
+// или models.ErrUserNotFound, если код никому не принадлежит
+func (r *Repository) GetUserByReferralCode(ctx context.Context, code string) (*models.User, error) {
+	log := logger.FromContext(ctx, r.log)
+	code = strings.ToUpper(strings.TrimSpace(code))
+	log.Debug("Getting user by referral code", zap.String("referral_code", code))
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, user := range r.users {
+		if user.ReferralCode == code {
+			return &models.User{
+				ID:           user.ID,
+				Username:     user.Username,
+				Points:       user.Points,
+				ReferralCode: user.ReferralCode,
+				CreatedAt:    user.CreatedAt,
+				UpdatedAt:    user.UpdatedAt,
+			}, nil
+		}
+	}
+
+	log.Debug("Referral code not found", zap.String("referral_code", code))
+	return nil, models.ErrUserNotFound
+}
+
+// GetReferrerChain возвращает цепочку рефереров пользователя снизу вверх, не более maxDepth уровней.
+// Повторное появление пользователя в цепочке (цикл) прерывает обход.
+func (r *Repository) GetReferrerChain(ctx context.Context, userID uuid.UUID, maxDepth int) ([]*models.ReferrerChainEntry, error) {
+	log := logger.FromContext(ctx, r.log)
+	log.Debug("Getting referrer chain",
+		zap.String("user_id", userID.String()),
+		zap.Int("max_depth", maxDepth))
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user, ok := r.users[userID]
+	if !ok {
+		return nil, nil
+	}
+
+	var chain []*models.ReferrerChainEntry
+	visited := map[uuid.UUID]bool{userID: true}
+	for level, id := 1, user.ReferrerID; level <= maxDepth && id != nil; level++ {
+		referrer, ok := r.users[*id]
+		if !ok || visited[referrer.ID] {
+			break
+		}
+		visited[referrer.ID] = true
+
+		chain = append(chain, &models.ReferrerChainEntry{
+			ID:       referrer.ID,
+			Username: referrer.Username,
+			Points:   referrer.Points,
+			Level:    level,
+		})
+		id = referrer.ReferrerID
+	}
+	return chain, nil
+}
+
+// RemoveReferrer сбрасывает реферера пользователя и отменяет бонусы, начисленные цепочке
+// рефереров при его добавлении; баланс реферера не опускается ниже нуля. Удаленный реферер
+// запоминается, и AddReferrer больше не принимает его для этого пользователя. Возвращает прежнего
+// реферера и отмененные бонусы (Points отрицательны) или models.ErrReferrerNotSet, если реферер не указан.
+func (r *Repository) RemoveReferrer(ctx context.Context, userID uuid.UUID) (uuid.UUID, []models.ReferralCredit, error) {
+	log := logger.FromContext(ctx, r.log)
+	log.Info("Removing referrer", zap.String("user_id", userID.String()))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[userID]
+	if !ok {
+		log.Warn("User not found", zap.String("user_id", userID.String()))
+		return uuid.Nil, nil, models.ErrUserNotFound
+	}
+	if user.ReferrerID == nil {
+		log.Warn("User has no referrer", zap.String("user_id", userID.String()))
+		return uuid.Nil, nil, models.ErrReferrerNotSet
+	}
+
+	// Отмена рассчитывается до изменения данных, чтобы ошибка у любого из рефереров
+	// не оставляла отмену частично примененной
+	reversals := make([]models.ReferralCredit, 0, len(r.referralCredits[userID]))
+	for _, credit := range r.referralCredits[userID] {
+		referrer, ok := r.users[credit.UserID]
+		if !ok {
+			continue
+		}
+		// Списывается не больше текущего баланса
+		delta := -min(credit.Points, referrer.Points)
+		if err := checkPointsRange(referrer.Points, delta); err != nil {
+			log.Warn("Referrer points out of range", zap.String("referrer_id", credit.UserID.String()))
+			return uuid.Nil, nil, err
+		}
+		reversals = append(reversals, models.ReferralCredit{
+			UserID:  credit.UserID,
+			Level:   credit.Level,
+			Points:  delta,
+			Balance: referrer.Points + delta,
+		})
+	}
+
+	now := time.Now()
+	for _, reversal := range reversals {
+		if reversal.Points == 0 {
+			continue
+		}
+		referrer := r.users[reversal.UserID]
+		referrer.Points = reversal.Balance
+		referrer.UpdatedAt = now
+		r.consumePointEntries(reversal.UserID, -reversal.Points, now)
+	}
+	referrerID := *user.ReferrerID
+	user.ReferrerID = nil
+	user.UpdatedAt = now
+	delete(r.referralCredits, userID)
+	r.removedReferrers[referralLink{userID: userID, referrerID: referrerID}] = struct{}{}
+
+	return referrerID, reversals, nil
+}
@@ -0,0 +1,380 @@
+// Package memory реализует хранилище данных в памяти процесса.
+// Данные не сохраняются между перезапусками; хранилище предназначено для локального
+// запуска и демонстрации без PostgreSQL.
+package memory
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/logger"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// Options содержит настраиваемые параметры репозитория
+type Options struct {
+	// LeaderboardTiebreaker порядок пользователей с равным балансом:
+	// models.TiebreakerCreatedAt (по умолчанию) или models.TiebreakerUsername
+	LeaderboardTiebreaker string
+	// ReferralSchedule бонусные баллы по уровням цепочки рефереров:
+	// ReferralSchedule[0] - прямому рефереру, ReferralSchedule[1] - его рефереру и так далее
+	ReferralSchedule []int
+	// StreakBonus бонусные баллы за вход на следующий день после предыдущего
+	StreakBonus int
+	// PointsTTL срок действия баллов за задания и реферальных бонусов, после которого
+	// ExpirePoints списывает их с баланса (0 - не сгорают)
+	PointsTTL time.Duration
+}
+
+// maxPoints максимальный баланс, совпадает с границей столбца INTEGER в PostgreSQL
+const maxPoints = math.MaxInt32
+
+// Repository хранит пользователей, задания и журналы в памяти.
+// Все операции выполняются под одной блокировкой, поэтому каждая из них атомарна
+// так же, как транзакция в PostgreSQL.
+type Repository struct {
+	mu              sync.RWMutex
+	users           map[uuid.UUID]*models.User
+	tasks           map[uuid.UUID]*models.Task
+	passwordHistory map[uuid.UUID][]string
+	passwordResets  map[string]*passwordReset
+	adjustments     []*models.PointAdjustment
+	audit           []*models.AuditEntry
+	// referralCredits бонусы, начисленные цепочке рефереров за приглашение пользователя
+	referralCredits map[uuid.UUID][]models.ReferralCredit
+	// removedReferrers рефереры, удаленные пользователями (RemoveReferrer)
+	removedReferrers map[referralLink]struct{}
+	// pointEntries начисления со сроком действия по пользователям (Options.PointsTTL)
+	pointEntries map[uuid.UUID][]*pointEntry
+
+	opts Options
+	log  *zap.Logger
+}
+
+// NewRepository создает пустой репозиторий в памяти
+func NewRepository(opts Options, log *zap.Logger) *Repository {
+	log.Warn("Using in-memory storage: data will be lost on restart")
+
+	return &Repository{
+		users:            make(map[uuid.UUID]*models.User),
+		tasks:            make(map[uuid.UUID]*models.Task),
+		passwordHistory:  make(map[uuid.UUID][]string),
+		passwordResets:   make(map[string]*passwordReset),
+		referralCredits:  make(map[uuid.UUID][]models.ReferralCredit),
+		removedReferrers: make(map[referralLink]struct{}),
+		pointEntries:     make(map[uuid.UUID][]*pointEntry),
+		opts:             opts,
+		log:              log.Named("memory_repository"),
+	}
+}
+
+// Close освобождает данные репозитория
+func (r *Repository) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.log.Info("Closing in-memory storage")
+	r.users = make(map[uuid.UUID]*models.User)
+	r.tasks = make(map[uuid.UUID]*models.Task)
+	r.passwordHistory = make(map[uuid.UUID][]string)
+	r.adjustments = nil
+	r.audit = nil
+	r.referralCredits = make(map[uuid.UUID][]models.ReferralCredit)
+	r.removedReferrers = make(map[referralLink]struct{})
+	r.pointEntries = make(map[uuid.UUID][]*pointEntry)
+	return nil
+}
+
+// checkPointsRange проверяет, что баланс после изменения на delta останется в допустимых границах
+func checkPointsRange(current, delta int) error {
+	next := int64(current) + int64(delta)
+	if next < 0 || next > maxPoints {
+		return models.ErrPointsOutOfRange
+	}
+	return nil
+}
+
+// page возвращает срез items, соответствующий limit и offset
+func page[T any](items []T, limit, offset int) []T {
+	if offset >= len(items) {
+		return items[:0]
+	}
+	items = items[offset:]
+	if limit >= 0 && limit < len(items) {
+		items = items[:limit]
+	}
+	return items
+}
+
+// findByUsername возвращает пользователя с совпадающим без учета регистра именем;
+// имена уникальны без учета регистра, как и в хранилище PostgreSQL
+func (r *Repository) findByUsername(username string) *models.User {
+	for _, user := range r.users {
+		if strings.EqualFold(user.Username, username) {
+			return user
+		}
+	}
+	return nil
+}
+
+// LoginUser регистрирует пользователя и выдает ему реферальный код; email необязателен.
+// Возвращает models.ErrUsernameTaken, если имя занято, и models.ErrEmailTaken,
+// если адрес уже используется (без учета регистра).
+func (r *Repository) LoginUser(ctx context.Context, username string, password string, email *string) (*models.User, error) {
+	log := logger.FromContext(ctx, r.log)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.findByUsername(username) != nil {
+		log.Warn("Username already taken", zap.String("username", username))
+		return nil, fmt.Errorf("failed to register user: %w", models.ErrUsernameTaken)
+	}
+	if email != nil {
+		for _, user := range r.users {
+			if user.Email != nil && strings.EqualFold(*user.Email, *email) {
+				log.Warn("Email already taken", zap.String("username", username))
+				return nil, models.ErrEmailTaken
+			}
+		}
+		stored := *email
+		email = &stored
+	}
+
+	code, err := r.uniqueReferralCode()
+	if err != nil {
+		log.Error("Failed to register user", zap.Error(err))
+		return nil, err
+	}
+
+	now := time.Now()
+	user := &models.User{
+		ID:           uuid.New(),
+		Username:     username,
+		Password:     password,
+		Role:         models.RoleUser,
+		ReferralCode: code,
+		Email:        email,
+		Version:      1,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	r.users[user.ID] = user
+
+	registered := *user
+	return &registered, nil
+}
+
+// GetUserByID возвращает пользователя по ID или models.ErrUserNotFound, если пользователь не найден
+func (r *Repository) GetUserByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	log := logger.FromContext(ctx, r.log)
+	log.Debug("Getting user by ID", zap.String("user_id", id.String()))
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		log.Warn("User not found", zap.String("user_id", id.String()))
+		return nil, models.ErrUserNotFound
+	}
+
+	found := *user
+	found.Password = ""
+	return &found, nil
+}
+
+// UsernameExists проверяет, занято ли имя пользователя (без учета регистра)
+func (r *Repository) UsernameExists(ctx context.Context, username string) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, user := range r.users {
+		if strings.EqualFold(user.Username, username) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// GetLeaderboard возвращает страницу пользователей с наибольшим балансом, подходящих под filter,
+// и общее количество таких пользователей
+func (r *Repository) GetLeaderboard(ctx context.Context, filter models.LeaderboardFilter, limit, offset int) ([]*models.User, int, error) {
+	log := logger.FromContext(ctx, r.log)
+	log.Debug("Getting leaderboard", zap.Int("limit", limit), zap.Int("offset", offset))
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	users := make([]*models.User, 0, len(r.users))
+	for _, user := range r.users {
+		if (filter.MinPoints != nil && user.Points < *filter.MinPoints) ||
+			(filter.MaxPoints != nil && user.Points > *filter.MaxPoints) ||
+			(filter.CreatedAfter != nil && !user.CreatedAt.After(*filter.CreatedAfter)) {
+			continue
+		}
+		users = append(users, &models.User{
+			ID:         user.ID,
+			Username:   user.Username,
+			Points:     user.Points,
+			ReferrerID: user.ReferrerID,
+			CreatedAt:  user.CreatedAt,
+			UpdatedAt:  user.UpdatedAt,
+		})
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return r.leaderboardLess(users[i], users[j])
+	})
+
+	return page(users, limit, offset), len(users), nil
+}
+
+// leaderboardLess возвращает true, если a стоит в таблице лидеров выше b. Последним
+// ключом всегда идет id, поэтому порядок однозначен.
+func (r *Repository) leaderboardLess(a, b *models.User) bool {
+	if a.Points != b.Points {
+		return a.Points > b.Points
+	}
+	if r.opts.LeaderboardTiebreaker == models.TiebreakerUsername {
+		if a.Username != b.Username {
+			return a.Username < b.Username
+		}
+	} else if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+	return a.ID.String() < b.ID.String()
+}
+
+// CompleteTask отмечает задание как выполненное и начисляет баллы
+func (r *Repository) CompleteTask(ctx context.Context, userID uuid.UUID, taskRequest models.TaskRequest, limit models.TaskActivityLimit) (*models.Task, error) {
+	log := logger.FromContext(ctx, r.log)
+	log.Info("Completing task",
+		zap.String("user_id", userID.String()),
+		zap.String("task_type", taskRequest.TaskType),
+		zap.Int("points", taskRequest.Points))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[userID]
+	if !ok {
+		log.Warn("User not found", zap.String("user_id", userID.String()))
+		return nil, models.ErrUserNotFound
+	}
+	if taskRequest.Points <= 0 {
+		return nil, models.ErrPointsOutOfRange
+	}
+	if err := checkPointsRange(user.Points, taskRequest.Points); err != nil {
+		log.Warn("Points change out of range",
+			zap.String("user_id", userID.String()),
+			zap.Int("balance", user.Points),
+			zap.Int("points", taskRequest.Points))
+		return nil, err
+	}
+	if limit.Check != nil {
+		if err := limit.Check(r.countTasksSince(userID, limit.Since)); err != nil {
+			return nil, err
+		}
+	}
+
+	task := &models.Task{
+		ID:          uuid.New(),
+		UserID:      userID,
+		TaskType:    taskRequest.TaskType,
+		Points:      taskRequest.Points,
+		CompletedAt: time.Now(),
+	}
+	r.tasks[task.ID] = task
+
+	user.Points += task.Points
+	user.UpdatedAt = task.CompletedAt
+	r.recordPointEntry(userID, task.Points, task.CompletedAt)
+
+	completed := *task
+	completed.Balance = user.Points
+	return &completed, nil
+}
+
+// AddReferrer добавляет реферера и возвращает обновленного пользователя
+// и начисленные цепочке рефереров бонусы. Возвращает models.ErrReferrerUnchanged, если
+// у пользователя уже этот реферер, models.ErrReferrerAlreadySet, если указан другой,
+// models.ErrSelfReferral, если пользователь указан собственным реферером, и
+// models.ErrReferrerRemoved, если этот реферер ранее был удален пользователем.
+func (r *Repository) AddReferrer(ctx context.Context, userID, referrerID uuid.UUID) (*models.User, []models.ReferralCredit, error) {
+	log := logger.FromContext(ctx, r.log)
+	log.Info("Adding referrer",
+		zap.String("user_id", userID.String()),
+		zap.String("referrer_id", referrerID.String()))
+
+	if userID == referrerID {
+		log.Warn("User cannot add themselves as referrer", zap.String("user_id", userID.String()))
+		return nil, nil, models.ErrSelfReferral
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.users[referrerID]; !ok {
+		log.Warn("Referrer not found", zap.String("referrer_id", referrerID.String()))
+		return nil, nil, models.ErrReferrerNotFound
+	}
+
+	user, ok := r.users[userID]
+	if !ok {
+		log.Warn("User not found", zap.String("user_id", userID.String()))
+		return nil, nil, models.ErrUserNotFound
+	}
+	if user.ReferrerID != nil {
+		if *user.ReferrerID == referrerID {
+			log.Info("Referrer is already set", zap.String("user_id", userID.String()))
+			return nil, nil, models.ErrReferrerUnchanged
+		}
+		log.Warn("User already has a referrer", zap.String("user_id", userID.String()))
+		return nil, nil, models.ErrReferrerAlreadySet
+	}
+	if _, ok := r.removedReferrers[referralLink{userID: userID, referrerID: referrerID}]; ok {
+		log.Warn("Referrer was removed by user",
+			zap.String("user_id", userID.String()),
+			zap.String("referrer_id", referrerID.String()))
+		return nil, nil, models.ErrReferrerRemoved
+	}
+
+	// Бонусы рассчитываются до изменения данных, чтобы выход баланса за границы
+	// у любого из рефереров отменял операцию целиком
+	credits := r.referrerCredits(userID, referrerID)
+	for _, credit := range credits {
+		if err := checkPointsRange(r.users[credit.UserID].Points, credit.Points); err != nil {
+			log.Warn("Referrer points out of range", zap.String("referrer_id", credit.UserID.String()))
+			return nil, nil, err
+		}
+	}
+
+	now := time.Now()
+	user.ReferrerID = &referrerID
+	user.UpdatedAt = now
+	for i := range credits {
+		referrer := r.users[credits[i].UserID]
+		referrer.Points += credits[i].Points
+		referrer.UpdatedAt = now
+		credits[i].Balance = referrer.Points
+		r.recordPointEntry(referrer.ID, credits[i].Points, now)
+	}
+	r.referralCredits[userID] = slices.Clone(credits)
+
+	return &models.User{
+		ID:         user.ID,
+		Username:   user.Username,
+		Points:     user.Points,
+		ReferrerID: user.ReferrerID,
+		CreatedAt:  user.CreatedAt,
+		UpdatedAt:  user.UpdatedAt,
+	}, credits, nil
+}
@@ -0,0 +1,20 @@
+package memory_test
+
+import (
+	"testing"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/repository/memory"
+	"github.com/DblMOKRQ/DeNet_test_task/internal/repository/repotest"
+	"github.com/DblMOKRQ/DeNet_test_task/internal/service"
+	"go.uber.org/zap"
+)
+
+func TestRepository(t *testing.T) {
+	repotest.Run(t, func(_ *testing.T, opts repotest.Options) service.StorageRepository {
+		return memory.NewRepository(memory.Options{
+			LeaderboardTiebreaker: opts.LeaderboardTiebreaker,
+			ReferralSchedule:      opts.ReferralSchedule,
+			PointsTTL:             opts.PointsTTL,
+		}, zap.NewNop())
+	})
+}
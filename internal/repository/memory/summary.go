@@ -0,0 +1,99 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/logger"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// GetUserRank возвращает место пользователя в таблице лидеров (с 1) в том же порядке,
+// что и GetLeaderboard без фильтров, или models.ErrUserNotFound
+func (r *Repository) GetUserRank(ctx context.Context, userID uuid.UUID) (int, error) {
+	log := logger.FromContext(ctx, r.log)
+	log.Debug("Getting user rank", zap.String("user_id", userID.String()))
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	me, ok := r.users[userID]
+	if !ok {
+		return 0, models.ErrUserNotFound
+	}
+	rank := 1
+	for _, user := range r.users {
+		if r.leaderboardLess(user, me) {
+			rank++
+		}
+	}
+	return rank, nil
+}
+
+// GetPointsToNextRank возвращает количество баллов, которых пользователю не хватает, чтобы
+// обогнать пользователя на месте выше в порядке GetUserRank, 0 для первого места
+// или models.ErrUserNotFound
+func (r *Repository) GetPointsToNextRank(ctx context.Context, userID uuid.UUID) (int, error) {
+	log := logger.FromContext(ctx, r.log)
+	log.Debug("Getting points to next rank", zap.String("user_id", userID.String()))
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	me, ok := r.users[userID]
+	if !ok {
+		return 0, models.ErrUserNotFound
+	}
+	// Место выше занимает пользователь с наименьшим балансом среди стоящих выше
+	var above *models.User
+	for _, user := range r.users {
+		if r.leaderboardLess(user, me) && (above == nil || user.Points < above.Points) {
+			above = user
+		}
+	}
+	if above == nil {
+		return 0, nil
+	}
+	return above.Points - me.Points + 1, nil
+}
+
+// GetUserStanding возвращает количество пользователей с большим балансом, чем у пользователя
+// (пользователи с равным балансом не учитываются), и общее количество пользователей
+// или models.ErrUserNotFound
+func (r *Repository) GetUserStanding(ctx context.Context, userID uuid.UUID) (int, int, error) {
+	log := logger.FromContext(ctx, r.log)
+	log.Debug("Getting user standing", zap.String("user_id", userID.String()))
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	me, ok := r.users[userID]
+	if !ok {
+		return 0, 0, models.ErrUserNotFound
+	}
+	ahead := 0
+	for _, user := range r.users {
+		if user.Points > me.Points {
+			ahead++
+		}
+	}
+	return ahead, len(r.users), nil
+}
+
+// CountReferrals возвращает количество пользователей, указавших userID прямым реферером
+func (r *Repository) CountReferrals(ctx context.Context, userID uuid.UUID) (int, error) {
+	log := logger.FromContext(ctx, r.log)
+	log.Debug("Counting referrals", zap.String("user_id", userID.String()))
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	count := 0
+	for _, user := range r.users {
+		if user.ReferrerID != nil && *user.ReferrerID == userID {
+			count++
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,181 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/logger"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// GetUserTasks возвращает выполненные пользователем задания, начиная с последних.
+// Пустой taskType означает отсутствие фильтра по типу задания.
+func (r *Repository) GetUserTasks(ctx context.Context, userID uuid.UUID, taskType string, limit, offset int) ([]*models.Task, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tasks := make([]*models.Task, 0)
+	for _, task := range r.tasks {
+		if task.UserID != userID || task.DeletedAt != nil || (taskType != "" && task.TaskType != taskType) {
+			continue
+		}
+		tasks = append(tasks, &models.Task{
+			ID:          task.ID,
+			UserID:      task.UserID,
+			TaskType:    task.TaskType,
+			Points:      task.Points,
+			CompletedAt: task.CompletedAt,
+		})
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		if !tasks[i].CompletedAt.Equal(tasks[j].CompletedAt) {
+			return tasks[i].CompletedAt.After(tasks[j].CompletedAt)
+		}
+		return tasks[i].ID.String() < tasks[j].ID.String()
+	})
+
+	return page(tasks, limit, offset), nil
+}
+
+// RevertTask отменяет выполнение задания: списывает начисленные за него баллы
+// (баланс не опускается ниже нуля) и помечает задание удаленным.
+func (r *Repository) RevertTask(ctx context.Context, taskID uuid.UUID) (*models.Task, error) {
+	log := logger.FromContext(ctx, r.log)
+	log.Info("Reverting task", zap.String("task_id", taskID.String()))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	task, ok := r.tasks[taskID]
+	if !ok {
+		log.Warn("Task not found", zap.String("task_id", taskID.String()))
+		return nil, models.ErrTaskNotFound
+	}
+	if task.DeletedAt != nil {
+		log.Warn("Task already reverted", zap.String("task_id", taskID.String()))
+		return nil, models.ErrTaskAlreadyReverted
+	}
+
+	now := time.Now()
+	if user, ok := r.users[task.UserID]; ok {
+		user.Points = max(user.Points-task.Points, 0)
+		user.UpdatedAt = now
+		r.consumePointEntries(user.ID, task.Points, now)
+	}
+	task.DeletedAt = &now
+
+	reverted := *task
+	return &reverted, nil
+}
+
+// countTasksSince возвращает количество заданий пользователя, выполненных начиная с since,
+// включая отмененные. Вызывается под блокировкой r.mu.
+func (r *Repository) countTasksSince(userID uuid.UUID, since time.Time) int {
+	count := 0
+	for _, task := range r.tasks {
+		if task.UserID == userID && !task.CompletedAt.Before(since) {
+			count++
+		}
+	}
+	return count
+}
+
+// GetTaskStats возвращает количество выполнений и сумму баллов по каждому типу задания.
+// Необязательные from и to ограничивают completed_at полуинтервалом [from, to).
+func (r *Repository) GetTaskStats(ctx context.Context, from, to *time.Time) ([]*models.TaskStats, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	byType := make(map[string]*models.TaskStats)
+	for _, task := range r.tasks {
+		if task.DeletedAt != nil ||
+			(from != nil && task.CompletedAt.Before(*from)) ||
+			(to != nil && !task.CompletedAt.Before(*to)) {
+			continue
+		}
+		s, ok := byType[task.TaskType]
+		if !ok {
+			s = &models.TaskStats{TaskType: task.TaskType}
+			byType[task.TaskType] = s
+		}
+		s.Count++
+		s.TotalPoints += task.Points
+	}
+
+	stats := make([]*models.TaskStats, 0, len(byType))
+	for _, s := range byType {
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].TaskType < stats[j].TaskType })
+	return stats, nil
+}
+
+// GetGlobalStats возвращает общие показатели сервиса
+func (r *Repository) GetGlobalStats(ctx context.Context) (*models.GlobalStats, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stats := &models.GlobalStats{TotalUsers: len(r.users), CalculatedAt: time.Now()}
+	for _, user := range r.users {
+		stats.TotalPoints += int64(user.Points)
+		if user.ReferrerID != nil {
+			stats.UsersWithReferrer++
+		}
+	}
+	for _, task := range r.tasks {
+		if task.DeletedAt == nil {
+			stats.TotalTasks++
+		}
+	}
+	return stats, nil
+}
+
+// GetPeriodLeaderboard возвращает страницу пользователей, отсортированных по сумме баллов
+// за задания, выполненные в интервале [from, to), и общее количество пользователей в рейтинге.
+// Отмененные задания не учитываются; равные суммы получают одинаковое место.
+func (r *Repository) GetPeriodLeaderboard(ctx context.Context, from, to time.Time, limit, offset int) ([]*models.PeriodLeaderboardEntry, int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	byUser := make(map[uuid.UUID]*models.PeriodLeaderboardEntry)
+	for _, task := range r.tasks {
+		if task.DeletedAt != nil || task.CompletedAt.Before(from) || !task.CompletedAt.Before(to) {
+			continue
+		}
+		user, ok := r.users[task.UserID]
+		if !ok {
+			continue
+		}
+		entry, ok := byUser[user.ID]
+		if !ok {
+			entry = &models.PeriodLeaderboardEntry{UserID: user.ID, Username: user.Username}
+			byUser[user.ID] = entry
+		}
+		entry.Points += task.Points
+	}
+
+	entries := make([]*models.PeriodLeaderboardEntry, 0, len(byUser))
+	for _, entry := range byUser {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Points != entries[j].Points {
+			return entries[i].Points > entries[j].Points
+		}
+		return entries[i].Username < entries[j].Username
+	})
+
+	// Место как у RANK(): равные суммы получают одно место, следующее место пропускается
+	for i, entry := range entries {
+		if i > 0 && entry.Points == entries[i-1].Points {
+			entry.Rank = entries[i-1].Rank
+		} else {
+			entry.Rank = i + 1
+		}
+	}
+
+	return page(entries, limit, offset), len(entries), nil
+}
@@ -0,0 +1,80 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/logger"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// ListUsers возвращает страницу пользователей, подходящих под filter, и их общее количество.
+// Хеш пароля не возвращается.
+func (r *Repository) ListUsers(ctx context.Context, filter models.UserListFilter, limit, offset int) ([]*models.User, int, error) {
+	log := logger.FromContext(ctx, r.log)
+	log.Debug("Listing users",
+		zap.String("search", filter.Search),
+		zap.String("sort", filter.Sort),
+		zap.Int("limit", limit),
+		zap.Int("offset", offset))
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	search := strings.ToLower(filter.Search)
+	users := make([]*models.User, 0, len(r.users))
+	for _, user := range r.users {
+		if search != "" && !strings.Contains(strings.ToLower(user.Username), search) {
+			continue
+		}
+		if filter.HasReferrer != nil && (user.ReferrerID != nil) != *filter.HasReferrer {
+			continue
+		}
+		found := *user
+		found.Password = ""
+		users = append(users, &found)
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		a, b := users[i], users[j]
+		if filter.Asc {
+			a, b = b, a
+		}
+		if filter.Sort == models.UserSortPoints {
+			if a.Points != b.Points {
+				return a.Points > b.Points
+			}
+		} else if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.After(b.CreatedAt)
+		}
+		// Равные значения упорядочиваются по id независимо от направления, как в PostgreSQL
+		return users[i].ID.String() < users[j].ID.String()
+	})
+
+	return page(users, limit, offset), len(users), nil
+}
+
+// GetUsersByIDs возвращает пользователей с указанными ID, индексируя их по ID.
+// Ненайденные ID в результат не попадают. Хеш пароля не возвращается.
+func (r *Repository) GetUsersByIDs(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]*models.User, error) {
+	log := logger.FromContext(ctx, r.log)
+	log.Debug("Getting users by IDs", zap.Int("count", len(ids)))
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	users := make(map[uuid.UUID]*models.User, len(ids))
+	for _, id := range ids {
+		user, ok := r.users[id]
+		if !ok {
+			continue
+		}
+		found := *user
+		found.Password = ""
+		users[id] = &found
+	}
+	return users, nil
+}
@@ -0,0 +1,237 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/logger"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// DeleteUser удаляет пользователя вместе с его заданиями.
+// У приглашенных им пользователей реферер сбрасывается; баллы, ранее начисленные
+// рефереру удаляемого пользователя за приглашение, не списываются.
+func (r *Repository) DeleteUser(ctx context.Context, userID uuid.UUID) (err error) {
+	ctx, done := r.withTimeout(ctx, &err)
+	defer done()
+
+	log := logger.FromContext(ctx, r.log)
+	log.Info("Deleting user", zap.String("user_id", userID.String()))
+
+	// Начало транзакции
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error("Failed to begin transaction", zap.Error(err))
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Бонусы за приглашенных пользователей больше не отменяются: их реферер сбрасывается ниже
+	_, err = tx.ExecContext(ctx,
+		"DELETE FROM referral_credits WHERE user_id IN (SELECT id FROM users WHERE referrer_id = $1)",
+		userID,
+	)
+	if err != nil {
+		log.Error("Failed to delete referral credits",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return fmt.Errorf("failed to delete referral credits: %w", err)
+	}
+
+	// Сброс реферера у приглашенных пользователей
+	res, err := tx.ExecContext(ctx,
+		"UPDATE users SET referrer_id = NULL, updated_at = NOW() WHERE referrer_id = $1",
+		userID,
+	)
+	if err != nil {
+		log.Error("Failed to detach referrals",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return fmt.Errorf("failed to detach referrals: %w", err)
+	}
+	referrals, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	// Удаление заданий пользователя
+	res, err = tx.ExecContext(ctx, "DELETE FROM tasks WHERE user_id = $1", userID)
+	if err != nil {
+		log.Error("Failed to delete user tasks",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return fmt.Errorf("failed to delete user tasks: %w", err)
+	}
+	tasks, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	// Удаление пользователя (история паролей удаляется каскадно)
+	res, err = tx.ExecContext(ctx, "DELETE FROM users WHERE id = $1", userID)
+	if err != nil {
+		log.Error("Failed to delete user",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if rows != 1 {
+		log.Warn("User not found", zap.String("user_id", userID.String()))
+		return models.ErrUserNotFound
+	}
+
+	// Фиксация транзакции
+	if err = tx.Commit(); err != nil {
+		log.Error("Failed to commit transaction", zap.Error(err))
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.Info("User deleted successfully",
+		zap.String("user_id", userID.String()),
+		zap.Int64("detached_referrals", referrals),
+		zap.Int64("deleted_tasks", tasks))
+	return nil
+}
+
+// UpdateUsername изменяет имя пользователя и увеличивает версию профиля.
+// При expectedVersion > 0 изменение применяется, только если текущая версия равна expectedVersion,
+// иначе возвращается models.ErrConflict.
+// Возвращает models.ErrUsernameUnchanged, если имя совпадает с текущим, и models.ErrUsernameTaken, если имя занято.
+func (r *Repository) UpdateUsername(ctx context.Context, userID uuid.UUID, newUsername string, expectedVersion int) (err error) {
+	ctx, done := r.withTimeout(ctx, &err)
+	defer done()
+
+	log := logger.FromContext(ctx, r.log)
+	log.Info("Updating username",
+		zap.String("user_id", userID.String()),
+		zap.String("username", newUsername))
+
+	// Начало транзакции
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error("Failed to begin transaction", zap.Error(err))
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Блокировка строки пользователя и получение текущего имени и версии
+	var current string
+	var version int
+	err = tx.QueryRowContext(ctx, "SELECT username, version FROM users WHERE id = $1 FOR UPDATE", userID).Scan(&current, &version)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Warn("User not found", zap.String("user_id", userID.String()))
+			return models.ErrUserNotFound
+		}
+		log.Error("Failed to get current username",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return fmt.Errorf("failed to get current username: %w", err)
+	}
+
+	if expectedVersion > 0 && version != expectedVersion {
+		log.Warn("Profile version mismatch",
+			zap.String("user_id", userID.String()),
+			zap.Int("expected_version", expectedVersion),
+			zap.Int("version", version))
+		return models.ErrConflict
+	}
+	if current == newUsername {
+		log.Warn("Username unchanged", zap.String("user_id", userID.String()))
+		return models.ErrUsernameUnchanged
+	}
+
+	res, err := tx.ExecContext(ctx,
+		"UPDATE users SET username = $1, version = version + 1, updated_at = NOW() WHERE id = $2 AND version = $3",
+		newUsername, userID, version,
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			log.Warn("Username already taken", zap.String("username", newUsername))
+			return models.ErrUsernameTaken
+		}
+		log.Error("Failed to update username",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return fmt.Errorf("failed to update username: %w", err)
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to update username: %w", err)
+	}
+	if rows == 0 {
+		log.Warn("Profile modified concurrently", zap.String("user_id", userID.String()))
+		return models.ErrConflict
+	}
+
+	// Фиксация транзакции
+	if err = tx.Commit(); err != nil {
+		log.Error("Failed to commit transaction", zap.Error(err))
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.Info("Username updated successfully", zap.String("user_id", userID.String()))
+	return nil
+}
+
+// pqUniqueViolation код ошибки PostgreSQL при нарушении ограничения уникальности
+const pqUniqueViolation = "23505"
+
+// Ограничения уникальности имени пользователя: исходное из 001 и индекс без учета регистра из 016
+const (
+	usernameConstraint      = "users_username_key"
+	usernameLowerConstraint = "idx_users_username_lower"
+)
+
+// isUsernameConflict проверяет, вызвана ли ошибка совпадением имени пользователя
+func isUsernameConflict(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == pqUniqueViolation &&
+		(pqErr.Constraint == usernameConstraint || pqErr.Constraint == usernameLowerConstraint)
+}
+
+// isUniqueViolation проверяет, вызвана ли ошибка нарушением ограничения уникальности
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == pqUniqueViolation
+}
+
+// GetUserByUsername возвращает публичный профиль пользователя по имени без учета регистра.
+// При совпадении нескольких имен, отличающихся регистром, предпочитается точное совпадение.
+func (r *Repository) GetUserByUsername(ctx context.Context, username string) (_ *models.PublicProfile, err error) {
+	ctx, done := r.withTimeout(ctx, &err)
+	defer done()
+
+	log := logger.FromContext(ctx, r.log)
+	log.Debug("Getting user by username", zap.String("username", username))
+
+	var profile models.PublicProfile
+	err = r.db.QueryRowContext(ctx, `
+		SELECT id, username, points
+		FROM users
+		WHERE lower(username) = lower($1)
+		ORDER BY username = $1 DESC
+		LIMIT 1
+	`, username).Scan(&profile.ID, &profile.Username, &profile.Points)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Debug("User not found", zap.String("username", username))
+			return nil, models.ErrUserNotFound
+		}
+		log.Error("Failed to get user by username",
+			zap.String("username", username),
+			zap.Error(err))
+		return nil, fmt.Errorf("failed to get user by username: %w", err)
+	}
+
+	return &profile, nil
+}
@@ -0,0 +1,104 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/logger"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// AdjustPoints изменяет баланс пользователя на delta по решению администратора adminID
+// и записывает корректировку в point_adjustments. Баланс не опускается ниже нуля:
+// фактическое изменение возвращается в AppliedDelta.
+func (r *Repository) AdjustPoints(ctx context.Context, adminID, userID uuid.UUID, delta int, reason string) (_ *models.PointAdjustment, err error) {
+	ctx, done := r.withTimeout(ctx, &err)
+	defer done()
+
+	log := logger.FromContext(ctx, r.log)
+	log.Info("Adjusting points",
+		zap.String("admin_id", adminID.String()),
+		zap.String("user_id", userID.String()),
+		zap.Int("delta", delta))
+
+	// Начало транзакции
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error("Failed to begin transaction", zap.Error(err))
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Блокировка строки пользователя
+	var previous int
+	err = tx.QueryRowContext(ctx, "SELECT points FROM users WHERE id = $1 FOR UPDATE", userID).Scan(&previous)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Warn("User not found", zap.String("user_id", userID.String()))
+			return nil, models.ErrUserNotFound
+		}
+		log.Error("Failed to get user points",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, fmt.Errorf("failed to get user points: %w", err)
+	}
+
+	adjustment := models.PointAdjustment{
+		UserID:  userID,
+		AdminID: adminID,
+		Delta:   delta,
+		Reason:  reason,
+	}
+
+	// Изменение баланса с ограничением снизу нулем
+	err = tx.QueryRowContext(ctx,
+		"UPDATE users SET points = GREATEST(points + $1, 0), updated_at = NOW() WHERE id = $2 RETURNING points",
+		delta, userID,
+	).Scan(&adjustment.Balance)
+	if err != nil {
+		if isPointsRangeViolation(err) {
+			log.Warn("Adjusted points out of range", zap.String("user_id", userID.String()))
+			return nil, models.ErrPointsOutOfRange
+		}
+		log.Error("Failed to update user points",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, fmt.Errorf("failed to update user points: %w", err)
+	}
+	adjustment.AppliedDelta = adjustment.Balance - previous
+	if err = consumePointEntries(ctx, tx, userID, -adjustment.AppliedDelta); err != nil {
+		log.Error("Failed to consume point entries",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, fmt.Errorf("failed to consume point entries: %w", err)
+	}
+
+	// Запись корректировки
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO point_adjustments (user_id, admin_id, delta, applied_delta, reason)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`, userID, adminID, delta, adjustment.AppliedDelta, reason).Scan(&adjustment.ID, &adjustment.CreatedAt)
+	if err != nil {
+		log.Error("Failed to record point adjustment",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, fmt.Errorf("failed to record point adjustment: %w", err)
+	}
+
+	// Фиксация транзакции
+	if err = tx.Commit(); err != nil {
+		log.Error("Failed to commit transaction", zap.Error(err))
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.Info("Points adjusted successfully",
+		zap.String("user_id", userID.String()),
+		zap.Int("applied_delta", adjustment.AppliedDelta),
+		zap.Int("balance", adjustment.Balance))
+	return &adjustment, nil
+}
@@ -0,0 +1,107 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/logger"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// Record добавляет запись в журнал аудита; metadata сохраняется как JSONB
+func (r *Repository) Record(ctx context.Context, actorID uuid.UUID, action string, targetID uuid.UUID, metadata map[string]any) (err error) {
+	ctx, done := r.withTimeout(ctx, &err)
+	defer done()
+
+	log := logger.FromContext(ctx, r.log)
+	log.Debug("Recording audit entry",
+		zap.String("actor_id", actorID.String()),
+		zap.String("action", action),
+		zap.String("target_id", targetID.String()))
+
+	if metadata == nil {
+		metadata = map[string]any{}
+	}
+	raw, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit metadata: %w", err)
+	}
+
+	_, err = r.db.ExecContext(ctx,
+		"INSERT INTO audit_log (actor_id, action, target_id, metadata) VALUES ($1, $2, $3, $4)",
+		actorID, action, targetID, raw,
+	)
+	if err != nil {
+		log.Error("Failed to record audit entry",
+			zap.String("action", action),
+			zap.Error(err))
+		return fmt.Errorf("failed to record audit entry: %w", err)
+	}
+
+	return nil
+}
+
+// ListAudit возвращает страницу журнала аудита, начиная с последних записей,
+// и общее количество записей, подходящих под фильтр
+func (r *Repository) ListAudit(ctx context.Context, filter models.AuditFilter, limit, offset int) (_ []*models.AuditEntry, _ int, err error) {
+	ctx, done := r.withTimeout(ctx, &err)
+	defer done()
+
+	log := logger.FromContext(ctx, r.log)
+	log.Debug("Listing audit entries",
+		zap.String("action", filter.Action),
+		zap.Int("limit", limit),
+		zap.Int("offset", offset))
+
+	where := `
+		WHERE ($1::uuid IS NULL OR actor_id = $1)
+		  AND ($2 = '' OR action = $2)
+		  AND ($3::timestamptz IS NULL OR created_at >= $3)
+	`
+	args := []any{filter.ActorID, filter.Action, filter.Since}
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM audit_log"+where, args...).Scan(&total); err != nil {
+		log.Error("Failed to count audit entries", zap.Error(err))
+		return nil, 0, fmt.Errorf("failed to count audit entries: %w", err)
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, actor_id, action, target_id, metadata, created_at
+		FROM audit_log`+where+`
+		ORDER BY created_at DESC, id
+		LIMIT $4 OFFSET $5
+	`, append(args, limit, offset)...)
+	if err != nil {
+		log.Error("Failed to query audit entries", zap.Error(err))
+		return nil, 0, fmt.Errorf("failed to query audit entries: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []*models.AuditEntry
+	for rows.Next() {
+		var entry models.AuditEntry
+		var raw []byte
+		if err := rows.Scan(&entry.ID, &entry.ActorID, &entry.Action, &entry.TargetID, &raw, &entry.CreatedAt); err != nil {
+			log.Error("Failed to scan audit entry", zap.Error(err))
+			return nil, 0, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		if err := json.Unmarshal(raw, &entry.Metadata); err != nil {
+			log.Error("Failed to decode audit metadata",
+				zap.String("audit_id", entry.ID.String()),
+				zap.Error(err))
+			return nil, 0, fmt.Errorf("failed to decode audit metadata: %w", err)
+		}
+		entries = append(entries, &entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Error("Error iterating audit rows", zap.Error(err))
+		return nil, 0, fmt.Errorf("error iterating audit rows: %w", err)
+	}
+
+	return entries, total, nil
+}
@@ -0,0 +1,112 @@
+//go:build integration
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+)
+
+// waitFor повторяет проверку cond, пока она не выполнится или не истечет timeout
+func waitFor(t *testing.T, timeout time.Duration, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestIntegrationRequestCancellationAbortsQuery(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t, Options{})
+	user := registerTestUser(t, repo, "alice")
+
+	// Блокировка строки пользователя из отдельного пула задерживает CompleteTask внутри его транзакции
+	var dbname string
+	if err := repo.db.QueryRow("SELECT current_database()").Scan(&dbname); err != nil {
+		t.Fatalf("select current database: %v", err)
+	}
+	blockerDB, err := sql.Open("postgres", testConnString(dbname))
+	if err != nil {
+		t.Fatalf("open blocking connection: %v", err)
+	}
+	defer blockerDB.Close()
+	blocker, err := blockerDB.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("begin blocking transaction: %v", err)
+	}
+	defer blocker.Rollback()
+	if _, err := blocker.Exec("SELECT 1 FROM users WHERE id = $1 FOR UPDATE", user.ID); err != nil {
+		t.Fatalf("lock user row: %v", err)
+	}
+
+	result := make(chan error, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := repo.CompleteTask(r.Context(), user.ID, models.TaskRequest{TaskType: "daily", Points: 10}, models.TaskActivityLimit{})
+		result <- err
+	}))
+	defer server.Close()
+
+	reqCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodPost, server.URL, nil)
+	if err != nil {
+		t.Fatalf("NewRequest() error = %v", err)
+	}
+	go func() {
+		if resp, err := http.DefaultClient.Do(req); err == nil {
+			resp.Body.Close()
+		}
+	}()
+
+	waitFor(t, 5*time.Second, "CompleteTask to wait for the row lock", func() bool {
+		return countRows(t, repo,
+			"SELECT COUNT(*) FROM pg_stat_activity WHERE datname = current_database() AND wait_event_type = 'Lock'") == 1
+	})
+
+	// Клиент разрывает соединение, пока запрос ожидает блокировку
+	cancel()
+
+	select {
+	case err := <-result:
+		if err == nil {
+			t.Fatal("CompleteTask() error = nil after the client disconnected")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("CompleteTask() was not aborted by the cancelled request context")
+	}
+
+	// Транзакция отмененного запроса откачена: соединение возвращено в пул,
+	// и на сервере не осталось ожидающих или незавершенных запросов, кроме блокирующего
+	waitFor(t, 5*time.Second, "the aborted transaction to release its connection", func() bool {
+		return repo.db.Stats().InUse == 0
+	})
+	if n := countRows(t, repo,
+		"SELECT COUNT(*) FROM pg_stat_activity WHERE datname = current_database() AND wait_event_type = 'Lock'"); n != 0 {
+		t.Errorf("%d queries still wait for the row lock", n)
+	}
+
+	if err := blocker.Rollback(); err != nil {
+		t.Fatalf("rollback blocking transaction: %v", err)
+	}
+	if n := countRows(t, repo,
+		"SELECT COUNT(*) FROM pg_stat_activity WHERE datname = current_database() AND state LIKE 'idle in transaction%'"); n != 0 {
+		t.Errorf("%d transactions left open", n)
+	}
+	if n := countRows(t, repo, "SELECT COUNT(*) FROM tasks WHERE user_id = $1", user.ID); n != 0 {
+		t.Errorf("tasks count = %d, want 0 after the aborted request", n)
+	}
+	if got := userPoints(t, repo, user.ID); got != 0 {
+		t.Errorf("users.points = %d, want 0 after the aborted request", got)
+	}
+}
@@ -0,0 +1,52 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/logger"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// emailConstraint имя уникального индекса адреса электронной почты
+const emailConstraint = "idx_users_email_lower"
+
+// isEmailConflict проверяет, вызвана ли ошибка совпадением адреса электронной почты
+func isEmailConflict(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == pqUniqueViolation && pqErr.Constraint == emailConstraint
+}
+
+// VerifyEmail отмечает адрес пользователя подтвержденным, если он по-прежнему равен email.
+// Возвращает models.ErrInvalidVerificationToken, если пользователь удален или сменил адрес.
+func (r *Repository) VerifyEmail(ctx context.Context, userID uuid.UUID, email string, at time.Time) (err error) {
+	ctx, done := r.withTimeout(ctx, &err)
+	defer done()
+
+	log := logger.FromContext(ctx, r.log)
+	log.Info("Verifying email", zap.String("user_id", userID.String()))
+
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE users SET email_verified_at = COALESCE(email_verified_at, $3), updated_at = NOW()
+		WHERE id = $1 AND lower(email) = lower($2)
+	`, userID, email, at)
+	if err != nil {
+		log.Error("Failed to verify email", zap.String("user_id", userID.String()), zap.Error(err))
+		return fmt.Errorf("failed to verify email: %w", err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to verify email: %w", err)
+	}
+	if rows == 0 {
+		log.Warn("Email does not match the verification token", zap.String("user_id", userID.String()))
+		return models.ErrInvalidVerificationToken
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// importChunkSize количество строк в одном многострочном INSERT
+// (ограничено числом параметров запроса PostgreSQL)
+const importChunkSize = 500
+
+// ImportUsers создает пользователей с уже захешированными паролями в одной транзакции.
+// Пользователи с занятыми именами (в том числе повторяющимися внутри пакета) пропускаются.
+// Каждому пользователю выдается реферальный код; совпадение кода прерывает импорт с ошибкой.
+// Возвращает количество созданных пользователей.
+func (r *Repository) ImportUsers(ctx context.Context, users []models.ImportUserRequest) (_ int, err error) {
+	ctx, done := r.withTimeout(ctx, &err)
+	defer done()
+
+	log := logger.FromContext(ctx, r.log)
+	log.Info("Importing users", zap.Int("count", len(users)))
+
+	// Начало транзакции
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error("Failed to begin transaction", zap.Error(err))
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	inserted := 0
+	for start := 0; start < len(users); start += importChunkSize {
+		chunk := users[start:min(start+importChunkSize, len(users))]
+
+		values := make([]string, 0, len(chunk))
+		args := make([]interface{}, 0, len(chunk)*4)
+		for i, user := range chunk {
+			code, err := newReferralCode()
+			if err != nil {
+				log.Error("Failed to generate referral code", zap.Error(err))
+				return 0, err
+			}
+			values = append(values, fmt.Sprintf("($%d, $%d, $%d, $%d)", i*4+1, i*4+2, i*4+3, i*4+4))
+			args = append(args, user.Username, user.Password, user.Points, code)
+		}
+
+		query := "INSERT INTO users (username, passw, points, referral_code) VALUES " +
+			strings.Join(values, ", ") +
+			" ON CONFLICT (lower(username)) DO NOTHING"
+
+		res, err := tx.ExecContext(ctx, query, args...)
+		if err != nil {
+			if isPointsRangeViolation(err) {
+				log.Warn("Imported points out of range", zap.Error(err))
+				return 0, models.ErrPointsOutOfRange
+			}
+			log.Error("Failed to insert users batch",
+				zap.Int("offset", start),
+				zap.Int("batch_size", len(chunk)),
+				zap.Error(err))
+			return 0, fmt.Errorf("failed to insert users batch: %w", err)
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return 0, fmt.Errorf("failed to get affected rows: %w", err)
+		}
+		inserted += int(rows)
+	}
+
+	// Фиксация транзакции
+	if err = tx.Commit(); err != nil {
+		log.Error("Failed to commit transaction", zap.Error(err))
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.Info("Users imported successfully",
+		zap.Int("inserted", inserted),
+		zap.Int("skipped", len(users)-inserted))
+	return inserted, nil
+}
@@ -0,0 +1,1994 @@
+//go:build integration
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"github.com/DblMOKRQ/DeNet_test_task/internal/repository/repotest"
+	"github.com/DblMOKRQ/DeNet_test_task/internal/service"
+	"github.com/google/uuid"
+	"github.com/testcontainers/testcontainers-go"
+	tcpostgres "github.com/testcontainers/testcontainers-go/modules/postgres"
+	"go.uber.org/zap"
+)
+
+// Интеграционные тесты запускаются против PostgreSQL в контейнере:
+//
+//	go test -tags integration ./internal/repository/postgres/
+//
+// Для запуска нужен Docker.
+
+const (
+	testUser     = "test"
+	testPassword = "test"
+)
+
+// testDatabase параметры подключения к контейнеру PostgreSQL, общему для всех тестов пакета
+var testDatabase struct {
+	host string
+	port string
+	// admin подключение к служебной базе для создания баз отдельных тестов
+	admin *sql.DB
+}
+
+// testDatabaseSeq счетчик для имен баз отдельных тестов
+var testDatabaseSeq atomic.Int64
+
+func TestMain(m *testing.M) {
+	os.Exit(runIntegrationTests(m))
+}
+
+func runIntegrationTests(m *testing.M) int {
+	// migrationsSource задан относительно рабочего каталога сервера (cmd)
+	if err := os.Chdir("../../../cmd"); err != nil {
+		fmt.Fprintf(os.Stderr, "change working directory: %v\n", err)
+		return 1
+	}
+
+	ctx := context.Background()
+	container, err := tcpostgres.Run(ctx, "postgres:16-alpine",
+		tcpostgres.WithDatabase("postgres"),
+		tcpostgres.WithUsername(testUser),
+		tcpostgres.WithPassword(testPassword),
+		tcpostgres.BasicWaitStrategies(),
+	)
+	defer func() {
+		if err := testcontainers.TerminateContainer(container); err != nil {
+			fmt.Fprintf(os.Stderr, "terminate postgres container: %v\n", err)
+		}
+	}()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "start postgres container: %v\n", err)
+		return 1
+	}
+
+	host, err := container.Host(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "get container host: %v\n", err)
+		return 1
+	}
+	port, err := container.MappedPort(ctx, "5432/tcp")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "get container port: %v\n", err)
+		return 1
+	}
+	testDatabase.host, testDatabase.port = host, port.Port()
+
+	testDatabase.admin, err = sql.Open("postgres", ConnString(testUser, testPassword, host, port.Port(), "postgres", "disable"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "open admin connection: %v\n", err)
+		return 1
+	}
+	defer testDatabase.admin.Close()
+
+	return m.Run()
+}
+
+// createTestDatabase создает пустую базу для одного теста и возвращает ее имя
+func createTestDatabase(t *testing.T) string {
+	t.Helper()
+
+	name := fmt.Sprintf("test_%d", testDatabaseSeq.Add(1))
+	if _, err := testDatabase.admin.Exec("CREATE DATABASE " + name); err != nil {
+		t.Fatalf("create database %s: %v", name, err)
+	}
+	t.Cleanup(func() {
+		if _, err := testDatabase.admin.Exec("DROP DATABASE IF EXISTS " + name + " WITH (FORCE)"); err != nil {
+			t.Errorf("drop database %s: %v", name, err)
+		}
+	})
+	return name
+}
+
+// testConnString возвращает строку подключения к базе теста
+func testConnString(dbname string) string {
+	return ConnString(testUser, testPassword, testDatabase.host, testDatabase.port, dbname, "disable")
+}
+
+// newTestRepository создает репозиторий над отдельной базой с примененными миграциями
+func newTestRepository(t *testing.T, opts Options) *Repository {
+	t.Helper()
+
+	dbname := createTestDatabase(t)
+	repo, err := NewRepository(testUser, testPassword, testDatabase.host, testDatabase.port, dbname, "disable", opts, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewRepository() error = %v", err)
+	}
+	t.Cleanup(func() { repo.Close() })
+	return repo
+}
+
+// TestIntegrationParity запускает общий набор проверок хранилища, который выполняет и хранилище в памяти
+func TestIntegrationParity(t *testing.T) {
+	repotest.Run(t, func(t *testing.T, opts repotest.Options) service.StorageRepository {
+		return newTestRepository(t, Options{
+			LeaderboardTiebreaker: opts.LeaderboardTiebreaker,
+			ReferralSchedule:      opts.ReferralSchedule,
+			PointsTTL:             opts.PointsTTL,
+		})
+	})
+}
+
+// registerTestUser регистрирует пользователя с фиктивным хешем пароля
+func registerTestUser(t *testing.T, repo *Repository, username string) *models.User {
+	t.Helper()
+
+	user, err := repo.LoginUser(context.Background(), username, "hash-"+username, nil)
+	if err != nil {
+		t.Fatalf("LoginUser(%q) error = %v", username, err)
+	}
+	return user
+}
+
+func TestIntegrationUsernameExists(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t, Options{})
+	registerTestUser(t, repo, "Alice")
+
+	tests := []struct {
+		username string
+		want     bool
+	}{
+		{username: "Alice", want: true},
+		{username: "alice", want: true},
+		{username: "ALICE", want: true},
+		{username: "bob", want: false},
+	}
+
+	for _, tt := range tests {
+		got, err := repo.UsernameExists(ctx, tt.username)
+		if err != nil {
+			t.Fatalf("UsernameExists(%q) error = %v", tt.username, err)
+		}
+		if got != tt.want {
+			t.Errorf("UsernameExists(%q) = %t, want %t", tt.username, got, tt.want)
+		}
+	}
+}
+
+func TestIntegrationRegisterUser(t *testing.T) {
+	repo := newTestRepository(t, Options{})
+	user := registerTestUser(t, repo, "alice")
+
+	var (
+		username, hash, role, referralCode string
+		points                             int
+		referrerID                         sql.NullString
+	)
+	err := repo.db.QueryRow(
+		"SELECT username, passw, points, role, referral_code, referrer_id FROM users WHERE id = $1", user.ID,
+	).Scan(&username, &hash, &points, &role, &referralCode, &referrerID)
+	if err != nil {
+		t.Fatalf("select registered user: %v", err)
+	}
+	if username != "alice" || hash != "hash-alice" || points != 0 || role != models.RoleUser {
+		t.Errorf("row = %q %q %d %q, want alice hash-alice 0 %q", username, hash, points, role, models.RoleUser)
+	}
+	if referralCode == "" || referralCode != user.ReferralCode {
+		t.Errorf("referral_code = %q, returned %q", referralCode, user.ReferralCode)
+	}
+	if referrerID.Valid {
+		t.Errorf("referrer_id = %q, want NULL", referrerID.String)
+	}
+	if n := countRows(t, repo, "SELECT COUNT(*) FROM users"); n != 1 {
+		t.Errorf("users count = %d, want 1", n)
+	}
+}
+
+func TestIntegrationGetUserByID(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t, Options{ReferralSchedule: []int{10}})
+	referrer := registerTestUser(t, repo, "alice")
+	registered := registerTestUser(t, repo, "bob")
+	completeTestTask(t, repo, registered.ID, 15)
+	if _, _, err := repo.AddReferrer(ctx, registered.ID, referrer.ID); err != nil {
+		t.Fatalf("AddReferrer() error = %v", err)
+	}
+
+	user, err := repo.GetUserByID(ctx, registered.ID)
+	if err != nil {
+		t.Fatalf("GetUserByID() error = %v", err)
+	}
+	if user.ID != registered.ID || user.Username != "bob" || user.Points != 15 {
+		t.Errorf("GetUserByID() = %s %q %d, want %s bob 15", user.ID, user.Username, user.Points, registered.ID)
+	}
+	if user.Role != models.RoleUser || user.ReferralCode != registered.ReferralCode {
+		t.Errorf("GetUserByID() role %q, referral code %q, want %q and %q", user.Role, user.ReferralCode, models.RoleUser, registered.ReferralCode)
+	}
+	if user.ReferrerID == nil || *user.ReferrerID != referrer.ID {
+		t.Errorf("GetUserByID() referrer = %v, want %s", user.ReferrerID, referrer.ID)
+	}
+	if user.CreatedAt.IsZero() || user.UpdatedAt.IsZero() {
+		t.Errorf("GetUserByID() timestamps = %s, %s, want them set", user.CreatedAt, user.UpdatedAt)
+	}
+}
+
+func TestIntegrationLoginUserDuplicate(t *testing.T) {
+	repo := newTestRepository(t, Options{})
+	registerTestUser(t, repo, "alice")
+
+	if _, err := repo.LoginUser(context.Background(), "alice", "hash-again", nil); !errors.Is(err, models.ErrUsernameTaken) {
+		t.Errorf("LoginUser() of taken username error = %v, want %v", err, models.ErrUsernameTaken)
+	}
+}
+
+// setTestPoints задает баланс пользователя напрямую в таблице users
+func setTestPoints(t *testing.T, repo *Repository, userID uuid.UUID, points int) {
+	t.Helper()
+
+	if _, err := repo.db.Exec("UPDATE users SET points = $1 WHERE id = $2", points, userID); err != nil {
+		t.Fatalf("set points of %s: %v", userID, err)
+	}
+}
+
+func TestIntegrationGetLeaderboardPagination(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t, Options{})
+
+	for i, username := range []string{"alice", "bob", "carol"} {
+		setTestPoints(t, repo, registerTestUser(t, repo, username).ID, (i+1)*10)
+	}
+
+	tests := []struct {
+		limit, offset int
+		want          []string
+	}{
+		{limit: 10, offset: 0, want: []string{"carol", "bob", "alice"}},
+		{limit: 2, offset: 0, want: []string{"carol", "bob"}},
+		{limit: 2, offset: 2, want: []string{"alice"}},
+		{limit: 2, offset: 5, want: nil},
+	}
+
+	for _, tt := range tests {
+		users, total, err := repo.GetLeaderboard(ctx, models.LeaderboardFilter{}, tt.limit, tt.offset)
+		if err != nil {
+			t.Fatalf("GetLeaderboard(%d, %d) error = %v", tt.limit, tt.offset, err)
+		}
+		if total != 3 {
+			t.Errorf("GetLeaderboard(%d, %d) total = %d, want 3", tt.limit, tt.offset, total)
+		}
+		var got []string
+		for _, user := range users {
+			got = append(got, user.Username)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("GetLeaderboard(%d, %d) = %v, want %v", tt.limit, tt.offset, got, tt.want)
+		}
+	}
+}
+
+func TestIntegrationGetLeaderboardConsistentSnapshot(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t, Options{ConsistentLeaderboard: true})
+	registerTestUser(t, repo, "alice")
+
+	// Параллельная регистрация меняет количество пользователей между подсчетом и выборкой
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if _, err := repo.LoginUser(ctx, fmt.Sprintf("user%d", i), "hash", nil); err != nil {
+				t.Errorf("LoginUser() error = %v", err)
+				return
+			}
+		}
+	}()
+	defer func() {
+		close(done)
+		<-stopped
+	}()
+
+	for i := 0; i < 200; i++ {
+		users, total, err := repo.GetLeaderboard(ctx, models.LeaderboardFilter{}, 1_000_000, 0)
+		if err != nil {
+			t.Fatalf("GetLeaderboard() error = %v", err)
+		}
+		// Страница, покрывающая всех пользователей, должна совпадать с итогом из того же снимка
+		if len(users) != total {
+			t.Fatalf("GetLeaderboard() returned %d users, total = %d", len(users), total)
+		}
+	}
+}
+
+func TestIntegrationLeaderboardView(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t, Options{})
+	alice := registerTestUser(t, repo, "alice")
+	bob := registerTestUser(t, repo, "bob")
+	registerTestUser(t, repo, "carol")
+	setTestPoints(t, repo, alice.ID, 10)
+	setTestPoints(t, repo, bob.ID, 20)
+
+	minPoints := 5
+	filters := []models.LeaderboardFilter{{}, {MinPoints: &minPoints}}
+	// assertSame проверяет, что представление и таблица пользователей дают одинаковые страницы
+	assertSame := func(t *testing.T) {
+		t.Helper()
+
+		for _, filter := range filters {
+			live, liveTotal, err := repo.GetLeaderboard(ctx, filter, 10, 0)
+			if err != nil {
+				t.Fatalf("GetLeaderboard() error = %v", err)
+			}
+			view, viewTotal, err := repo.GetLeaderboardView(ctx, filter, 10, 0)
+			if err != nil {
+				t.Fatalf("GetLeaderboardView() error = %v", err)
+			}
+			if viewTotal != liveTotal || !reflect.DeepEqual(view, live) {
+				t.Errorf("filter %+v: view = %s (total %d), live = %s (total %d)",
+					filter, leaderboardNames(view), viewTotal, leaderboardNames(live), liveTotal)
+			}
+		}
+	}
+
+	if err := repo.RefreshLeaderboardView(ctx); err != nil {
+		t.Fatalf("RefreshLeaderboardView() error = %v", err)
+	}
+	assertSame(t)
+
+	// До пересчета представление хранит прежний снимок
+	setTestPoints(t, repo, alice.ID, 30)
+	view, _, err := repo.GetLeaderboardView(ctx, models.LeaderboardFilter{}, 10, 0)
+	if err != nil {
+		t.Fatalf("GetLeaderboardView() error = %v", err)
+	}
+	if got := leaderboardNames(view); got != "bob,alice,carol" {
+		t.Errorf("view before refresh = %s, want bob,alice,carol", got)
+	}
+
+	if err := repo.RefreshLeaderboardView(ctx); err != nil {
+		t.Fatalf("RefreshLeaderboardView() error = %v", err)
+	}
+	assertSame(t)
+	view, _, _ = repo.GetLeaderboardView(ctx, models.LeaderboardFilter{}, 10, 0)
+	if got := leaderboardNames(view); got != "alice,bob,carol" {
+		t.Errorf("view after refresh = %s, want alice,bob,carol", got)
+	}
+}
+
+// leaderboardNames возвращает имена пользователей страницы через запятую
+func leaderboardNames(users []*models.User) string {
+	names := make([]string, len(users))
+	for i, user := range users {
+		names[i] = user.Username
+	}
+	return strings.Join(names, ",")
+}
+
+func TestIntegrationGetUserByIDOmitsPassword(t *testing.T) {
+	repo := newTestRepository(t, Options{})
+	registered := registerTestUser(t, repo, "alice")
+
+	user, err := repo.GetUserByID(context.Background(), registered.ID)
+	if err != nil {
+		t.Fatalf("GetUserByID() error = %v", err)
+	}
+	if user.Username != "alice" {
+		t.Errorf("username = %q, want %q", user.Username, "alice")
+	}
+	if user.Password != "" {
+		t.Errorf("password = %q, want it to be omitted", user.Password)
+	}
+}
+
+func TestIntegrationGetUserByIDUnknown(t *testing.T) {
+	repo := newTestRepository(t, Options{})
+
+	user, err := repo.GetUserByID(context.Background(), uuid.New())
+	if !errors.Is(err, models.ErrUserNotFound) || user != nil {
+		t.Errorf("GetUserByID() = %v, %v, want nil, %v", user, err, models.ErrUserNotFound)
+	}
+}
+
+func TestIntegrationGetUserByUsername(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t, Options{})
+	lower := registerTestUser(t, repo, "alice")
+	setTestPoints(t, repo, lower.ID, 7)
+
+	profile, err := repo.GetUserByUsername(ctx, "alice")
+	if err != nil {
+		t.Fatalf("GetUserByUsername(alice) error = %v", err)
+	}
+	want := models.PublicProfile{ID: lower.ID, Username: "alice", Points: 7}
+	if *profile != want {
+		t.Errorf("GetUserByUsername(alice) = %+v, want %+v", profile, want)
+	}
+
+	for _, username := range []string{"Alice", "ALICE"} {
+		if profile, err := repo.GetUserByUsername(ctx, username); err != nil || profile.ID != lower.ID {
+			t.Errorf("GetUserByUsername(%s) = %+v, %v, want user %s", username, profile, err, lower.ID)
+		}
+	}
+
+	profile, err = repo.GetUserByUsername(ctx, "bob")
+	if !errors.Is(err, models.ErrUserNotFound) || profile != nil {
+		t.Errorf("GetUserByUsername(bob) = %v, %v, want nil, %v", profile, err, models.ErrUserNotFound)
+	}
+}
+
+func TestIntegrationUsernameUniqueIgnoresCase(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t, Options{})
+	registerTestUser(t, repo, "Alice")
+
+	if _, err := repo.LoginUser(ctx, "alice", "hash-alice", nil); !errors.Is(err, models.ErrUsernameTaken) {
+		t.Errorf("LoginUser(alice) after Alice error = %v, want %v", err, models.ErrUsernameTaken)
+	}
+
+	// Индекс из миграции 016 отклоняет и запись в обход репозитория
+	_, err := repo.db.ExecContext(ctx,
+		"INSERT INTO users (username, passw, referral_code) VALUES ('ALICE', 'hash', 'ZZZZZZZZ')")
+	if !isUsernameConflict(err) {
+		t.Errorf("insert ALICE error = %v, want a unique violation on %s", err, usernameLowerConstraint)
+	}
+}
+
+func TestIntegrationGetLeaderboardEmpty(t *testing.T) {
+	repo := newTestRepository(t, Options{})
+
+	users, total, err := repo.GetLeaderboard(context.Background(), models.LeaderboardFilter{}, 10, 0)
+	if err != nil {
+		t.Fatalf("GetLeaderboard() error = %v", err)
+	}
+	if users == nil || len(users) != 0 || total != 0 {
+		t.Errorf("GetLeaderboard() = %v (nil: %t), total %d, want empty non-nil slice and 0", users, users == nil, total)
+	}
+}
+
+func TestIntegrationUpdatePasswordHistory(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t, Options{})
+	user := registerTestUser(t, repo, "alice")
+
+	// Хеши сменяют друг друга: hash-alice -> h1 -> h2 -> h3
+	for _, hash := range []string{"h1", "h2", "h3"} {
+		if err := repo.UpdatePassword(ctx, user.ID, hash, 2); err != nil {
+			t.Fatalf("UpdatePassword(%q) error = %v", hash, err)
+		}
+	}
+
+	current, err := repo.GetPasswordHash(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("GetPasswordHash() error = %v", err)
+	}
+	if current != "h3" {
+		t.Errorf("current hash = %q, want %q", current, "h3")
+	}
+
+	history, err := repo.GetPasswordHistory(ctx, user.ID, 10)
+	if err != nil {
+		t.Fatalf("GetPasswordHistory() error = %v", err)
+	}
+	// В истории остаются только два последних предыдущих хеша
+	if fmt.Sprint(history) != "[h2 h1]" {
+		t.Errorf("history = %v, want [h2 h1]", history)
+	}
+}
+
+func TestIntegrationPasswordUnknownUser(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t, Options{})
+
+	if _, err := repo.GetPasswordHash(ctx, uuid.New()); !errors.Is(err, models.ErrUserNotFound) {
+		t.Errorf("GetPasswordHash() error = %v, want %v", err, models.ErrUserNotFound)
+	}
+	if err := repo.UpdatePassword(ctx, uuid.New(), "hash", 5); !errors.Is(err, models.ErrUserNotFound) {
+		t.Errorf("UpdatePassword() error = %v, want %v", err, models.ErrUserNotFound)
+	}
+}
+
+// userPoints читает баланс пользователя напрямую из таблицы users
+func userPoints(t *testing.T, repo *Repository, userID uuid.UUID) int {
+	t.Helper()
+
+	var points int
+	if err := repo.db.QueryRow("SELECT points FROM users WHERE id = $1", userID).Scan(&points); err != nil {
+		t.Fatalf("select points of %s: %v", userID, err)
+	}
+	return points
+}
+
+func TestIntegrationAddReferrerBonus(t *testing.T) {
+	repo := newTestRepository(t, Options{ReferralSchedule: []int{7}})
+	referrer := registerTestUser(t, repo, "alice")
+	user := registerTestUser(t, repo, "bob")
+
+	updated, _, err := repo.AddReferrer(context.Background(), user.ID, referrer.ID)
+	if err != nil {
+		t.Fatalf("AddReferrer() error = %v", err)
+	}
+	if updated.ReferrerID == nil || *updated.ReferrerID != referrer.ID {
+		t.Errorf("referrer_id = %v, want %s", updated.ReferrerID, referrer.ID)
+	}
+	if got := userPoints(t, repo, referrer.ID); got != 7 {
+		t.Errorf("referrer points = %d, want configured bonus 7", got)
+	}
+}
+
+func TestIntegrationGetUserByReferralCode(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t, Options{})
+	alice := registerTestUser(t, repo, "alice")
+	registerTestUser(t, repo, "bob")
+
+	if len(alice.ReferralCode) != 8 {
+		t.Fatalf("referral code = %q, want 8 characters", alice.ReferralCode)
+	}
+
+	// Код принимается без учета регистра и окружающих пробелов
+	for _, code := range []string{alice.ReferralCode, " " + strings.ToLower(alice.ReferralCode) + " "} {
+		user, err := repo.GetUserByReferralCode(ctx, code)
+		if err != nil {
+			t.Fatalf("GetUserByReferralCode(%q) error = %v", code, err)
+		}
+		if user.ID != alice.ID || user.ReferralCode != alice.ReferralCode {
+			t.Errorf("GetUserByReferralCode(%q) = %+v, want alice", code, user)
+		}
+	}
+
+	user, err := repo.GetUserByReferralCode(ctx, "ZZZZZZZZ")
+	if !errors.Is(err, models.ErrUserNotFound) || user != nil {
+		t.Errorf("GetUserByReferralCode(unknown) = %v, %v, want nil, %v", user, err, models.ErrUserNotFound)
+	}
+}
+
+func TestIntegrationAddReferrer(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t, Options{ReferralSchedule: []int{10}})
+	alice := registerTestUser(t, repo, "alice")
+	bob := registerTestUser(t, repo, "bob")
+	carol := registerTestUser(t, repo, "carol")
+
+	if _, _, err := repo.AddReferrer(ctx, bob.ID, alice.ID); err != nil {
+		t.Fatalf("AddReferrer(bob, alice) error = %v", err)
+	}
+
+	var referrerID uuid.UUID
+	if err := repo.db.QueryRow("SELECT referrer_id FROM users WHERE id = $1", bob.ID).Scan(&referrerID); err != nil {
+		t.Fatalf("select bob referrer: %v", err)
+	}
+	if referrerID != alice.ID {
+		t.Errorf("users.referrer_id = %s, want %s", referrerID, alice.ID)
+	}
+
+	// Повторное указание реферера отклоняется и не начисляет бонус второй раз
+	if _, _, err := repo.AddReferrer(ctx, bob.ID, carol.ID); err == nil {
+		t.Error("AddReferrer() for a user with a referrer error = nil, want error")
+	}
+	if got := userPoints(t, repo, alice.ID); got != 10 {
+		t.Errorf("alice points = %d, want 10", got)
+	}
+	if got := userPoints(t, repo, carol.ID); got != 0 {
+		t.Errorf("carol points = %d, want 0", got)
+	}
+	if got := userPoints(t, repo, bob.ID); got != 0 {
+		t.Errorf("bob points = %d, want 0: the bonus goes to the referrer", got)
+	}
+}
+
+func TestIntegrationAddReferrerUnknown(t *testing.T) {
+	repo := newTestRepository(t, Options{})
+	user := registerTestUser(t, repo, "bob")
+
+	if _, _, err := repo.AddReferrer(context.Background(), user.ID, uuid.New()); !errors.Is(err, models.ErrReferrerNotFound) {
+		t.Errorf("AddReferrer(unknown referrer) error = %v, want %v", err, models.ErrReferrerNotFound)
+	}
+}
+
+func TestIntegrationAddReferrerCreditsChain(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t, Options{ReferralSchedule: []int{10, 5, 2}})
+
+	// alice <- bob <- carol <- dave: цепочка строится без бонусов, затем балансы обнуляются
+	users := make(map[string]*models.User)
+	for _, name := range []string{"alice", "bob", "carol", "dave", "eve"} {
+		users[name] = registerTestUser(t, repo, name)
+	}
+	for _, link := range [][2]string{{"bob", "alice"}, {"carol", "bob"}, {"dave", "carol"}} {
+		if _, _, err := repo.AddReferrer(ctx, users[link[0]].ID, users[link[1]].ID); err != nil {
+			t.Fatalf("AddReferrer(%s, %s) error = %v", link[0], link[1], err)
+		}
+	}
+	if _, err := repo.db.Exec("UPDATE users SET points = 0"); err != nil {
+		t.Fatalf("reset points: %v", err)
+	}
+
+	_, credits, err := repo.AddReferrer(ctx, users["eve"].ID, users["dave"].ID)
+	if err != nil {
+		t.Fatalf("AddReferrer(eve, dave) error = %v", err)
+	}
+
+	// Начисления возвращаются по уровням цепочки с итоговыми балансами
+	wantCredits := map[int]models.ReferralCredit{
+		1: {UserID: users["dave"].ID, Level: 1, Points: 10, Balance: 10},
+		2: {UserID: users["carol"].ID, Level: 2, Points: 5, Balance: 5},
+		3: {UserID: users["bob"].ID, Level: 3, Points: 2, Balance: 2},
+	}
+	if len(credits) != len(wantCredits) {
+		t.Errorf("credits = %+v, want %d levels", credits, len(wantCredits))
+	}
+	for _, credit := range credits {
+		if credit != wantCredits[credit.Level] {
+			t.Errorf("credit = %+v, want %+v", credit, wantCredits[credit.Level])
+		}
+	}
+
+	// Уровни сверх расписания (alice - четвертый) бонусов не получают
+	for name, want := range map[string]int{"dave": 10, "carol": 5, "bob": 2, "alice": 0, "eve": 0} {
+		if got := userPoints(t, repo, users[name].ID); got != want {
+			t.Errorf("%s points = %d, want %d", name, got, want)
+		}
+	}
+}
+
+func TestIntegrationAddReferrerChainOverflowRollsBack(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t, Options{ReferralSchedule: []int{10, 5}})
+	alice := registerTestUser(t, repo, "alice")
+	bob := registerTestUser(t, repo, "bob")
+	carol := registerTestUser(t, repo, "carol")
+	if _, _, err := repo.AddReferrer(ctx, bob.ID, alice.ID); err != nil {
+		t.Fatalf("AddReferrer(bob, alice) error = %v", err)
+	}
+	setTestPoints(t, repo, alice.ID, math.MaxInt32)
+	setTestPoints(t, repo, bob.ID, 0)
+
+	// Переполнение баланса второго уровня отменяет начисление всей цепочке и привязку реферера
+	if _, _, err := repo.AddReferrer(ctx, carol.ID, bob.ID); !errors.Is(err, models.ErrPointsOutOfRange) {
+		t.Fatalf("AddReferrer() error = %v, want %v", err, models.ErrPointsOutOfRange)
+	}
+	if got := userPoints(t, repo, bob.ID); got != 0 {
+		t.Errorf("bob points = %d, want 0 after rollback", got)
+	}
+	if n := countRows(t, repo, "SELECT COUNT(*) FROM users WHERE id = $1 AND referrer_id IS NOT NULL", carol.ID); n != 0 {
+		t.Error("carol referrer is set after rollback")
+	}
+}
+
+func TestIntegrationRemoveReferrer(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t, Options{ReferralSchedule: []int{10, 5}})
+	alice := registerTestUser(t, repo, "alice")
+	bob := registerTestUser(t, repo, "bob")
+	carol := registerTestUser(t, repo, "carol")
+	if _, _, err := repo.AddReferrer(ctx, bob.ID, alice.ID); err != nil {
+		t.Fatalf("AddReferrer(bob, alice) error = %v", err)
+	}
+	if _, _, err := repo.AddReferrer(ctx, carol.ID, bob.ID); err != nil {
+		t.Fatalf("AddReferrer(carol, bob) error = %v", err)
+	}
+
+	// Начисления за приглашение сохраняются по уровням
+	if n := countRows(t, repo, "SELECT COUNT(*) FROM referral_credits WHERE user_id = $1", carol.ID); n != 2 {
+		t.Fatalf("carol referral credits = %d, want 2", n)
+	}
+
+	// Бонус удаленного реферера не отменяется, остальные уровни отменяются
+	if err := repo.DeleteUser(ctx, alice.ID); err != nil {
+		t.Fatalf("DeleteUser(alice) error = %v", err)
+	}
+	referrerID, reversals, err := repo.RemoveReferrer(ctx, carol.ID)
+	if err != nil {
+		t.Fatalf("RemoveReferrer(carol) error = %v", err)
+	}
+	if referrerID != bob.ID {
+		t.Errorf("RemoveReferrer(carol) referrer = %s, want %s", referrerID, bob.ID)
+	}
+	if len(reversals) != 1 || reversals[0] != (models.ReferralCredit{UserID: bob.ID, Level: 1, Points: -10, Balance: 0}) {
+		t.Errorf("reversals = %+v, want -10 from bob only", reversals)
+	}
+	if n := countRows(t, repo, "SELECT COUNT(*) FROM referral_credits WHERE user_id = $1", carol.ID); n != 0 {
+		t.Errorf("carol referral credits = %d, want none after removal", n)
+	}
+
+	// Удаленный реферер запоминается до удаления любого из пользователей
+	if n := countRows(t, repo, "SELECT COUNT(*) FROM removed_referrers WHERE user_id = $1 AND referrer_id = $2", carol.ID, bob.ID); n != 1 {
+		t.Errorf("carol removed referrers = %d, want 1", n)
+	}
+	if err := repo.DeleteUser(ctx, bob.ID); err != nil {
+		t.Fatalf("DeleteUser(bob) error = %v", err)
+	}
+	if n := countRows(t, repo, "SELECT COUNT(*) FROM removed_referrers WHERE user_id = $1", carol.ID); n != 0 {
+		t.Errorf("carol removed referrers = %d, want none after bob is deleted", n)
+	}
+}
+
+func TestIntegrationPointEntries(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t, Options{ReferralSchedule: []int{10}, PointsTTL: time.Hour})
+	alice := registerTestUser(t, repo, "alice")
+	bob := registerTestUser(t, repo, "bob")
+	if _, err := repo.CompleteTask(ctx, alice.ID, models.TaskRequest{TaskType: "daily", Points: 50}, models.TaskActivityLimit{}); err != nil {
+		t.Fatalf("CompleteTask() error = %v", err)
+	}
+	if _, _, err := repo.AddReferrer(ctx, bob.ID, alice.ID); err != nil {
+		t.Fatalf("AddReferrer(bob, alice) error = %v", err)
+	}
+
+	// Списание тратит остатки начислений в порядке сгорания
+	if _, err := repo.TransferPoints(ctx, alice.ID, bob.ID, 55); err != nil {
+		t.Fatalf("TransferPoints() error = %v", err)
+	}
+	remaining := "SELECT COALESCE(SUM(remaining), 0) FROM point_entries WHERE user_id = $1 AND source = $2"
+	if n := countRows(t, repo, remaining, alice.ID, models.PointSourceTask); n != 0 {
+		t.Errorf("alice task entries remaining = %d, want 0", n)
+	}
+	if n := countRows(t, repo, remaining, alice.ID, models.PointSourceReferral); n != 5 {
+		t.Errorf("alice referral entries remaining = %d, want 5", n)
+	}
+	// Полученный перевод не сгорает
+	if n := countRows(t, repo, "SELECT COUNT(*) FROM point_entries WHERE user_id = $1", bob.ID); n != 0 {
+		t.Errorf("bob point entries = %d, want none", n)
+	}
+
+	if err := repo.DeleteUser(ctx, alice.ID); err != nil {
+		t.Fatalf("DeleteUser(alice) error = %v", err)
+	}
+	if n := countRows(t, repo, "SELECT COUNT(*) FROM point_entries WHERE user_id = $1", alice.ID); n != 0 {
+		t.Errorf("alice point entries = %d, want none after deletion", n)
+	}
+}
+
+// chainUsernames возвращает имена рефереров цепочки по порядку уровней
+func chainUsernames(t *testing.T, chain []*models.ReferrerChainEntry) []string {
+	t.Helper()
+
+	names := []string{}
+	for i, entry := range chain {
+		if entry.Level != i+1 {
+			t.Errorf("entry %q level = %d, want %d", entry.Username, entry.Level, i+1)
+		}
+		names = append(names, entry.Username)
+	}
+	return names
+}
+
+func TestIntegrationGetReferrerChain(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t, Options{})
+	alice := registerTestUser(t, repo, "alice")
+	bob := registerTestUser(t, repo, "bob")
+	carol := registerTestUser(t, repo, "carol")
+	dave := registerTestUser(t, repo, "dave")
+	for _, link := range [][2]uuid.UUID{{bob.ID, alice.ID}, {carol.ID, bob.ID}, {dave.ID, carol.ID}} {
+		if _, _, err := repo.AddReferrer(ctx, link[0], link[1]); err != nil {
+			t.Fatalf("AddReferrer() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		userID   uuid.UUID
+		maxDepth int
+		want     []string
+	}{
+		{name: "three levels", userID: dave.ID, maxDepth: 5, want: []string{"carol", "bob", "alice"}},
+		{name: "bounded depth", userID: dave.ID, maxDepth: 2, want: []string{"carol", "bob"}},
+		{name: "direct referrer only", userID: bob.ID, maxDepth: 5, want: []string{"alice"}},
+		{name: "no referrer", userID: alice.ID, maxDepth: 5, want: []string{}},
+		{name: "unknown user", userID: uuid.New(), maxDepth: 5, want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain, err := repo.GetReferrerChain(ctx, tt.userID, tt.maxDepth)
+			if err != nil {
+				t.Fatalf("GetReferrerChain() error = %v", err)
+			}
+			if got := chainUsernames(t, chain); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("GetReferrerChain() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIntegrationGetReferrerChainCycle(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t, Options{})
+	alice := registerTestUser(t, repo, "alice")
+	bob := registerTestUser(t, repo, "bob")
+	if _, _, err := repo.AddReferrer(ctx, bob.ID, alice.ID); err != nil {
+		t.Fatalf("AddReferrer() error = %v", err)
+	}
+	// Цикл создается напрямую в таблице, минуя проверки AddReferrer
+	if _, err := repo.db.Exec("UPDATE users SET referrer_id = $1 WHERE id = $2", bob.ID, alice.ID); err != nil {
+		t.Fatalf("create referrer cycle: %v", err)
+	}
+
+	chain, err := repo.GetReferrerChain(ctx, bob.ID, 10)
+	if err != nil {
+		t.Fatalf("GetReferrerChain() error = %v", err)
+	}
+	if got := chainUsernames(t, chain); fmt.Sprint(got) != "[alice]" {
+		t.Errorf("GetReferrerChain() in a cycle = %v, want [alice]", got)
+	}
+}
+
+// stubReferralCodes подменяет генератор реферальных кодов последовательностью codes
+func stubReferralCodes(t *testing.T, codes ...string) {
+	t.Helper()
+
+	original := newReferralCode
+	t.Cleanup(func() { newReferralCode = original })
+	newReferralCode = func() (string, error) {
+		code := codes[0]
+		if len(codes) > 1 {
+			codes = codes[1:]
+		}
+		return code, nil
+	}
+}
+
+func TestIntegrationLoginUserReferralCodeCollision(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t, Options{})
+
+	stubReferralCodes(t, "AAAAAAAA", "AAAAAAAA", "AAAAAAAA", "BBBBBBBB")
+	registerTestUser(t, repo, "alice")
+
+	// Два совпадения подряд: код генерируется заново, пока не найдется свободный
+	bob := registerTestUser(t, repo, "bob")
+	if bob.ReferralCode != "BBBBBBBB" {
+		t.Errorf("bob referral code = %q, want BBBBBBBB after retries", bob.ReferralCode)
+	}
+
+	// Совпадение на каждой попытке - ошибка, а не занятое имя
+	stubReferralCodes(t, "AAAAAAAA")
+	_, err := repo.LoginUser(ctx, "carol", "hash-carol", nil)
+	if err == nil || errors.Is(err, models.ErrUsernameTaken) {
+		t.Errorf("LoginUser() with exhausted attempts error = %v, want a non-username error", err)
+	}
+	if n := countRows(t, repo, "SELECT COUNT(*) FROM users WHERE username = $1", "carol"); n != 0 {
+		t.Errorf("carol rows = %d, want 0", n)
+	}
+}
+
+func TestIntegrationTransferPoints(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		amount      int
+		unknownTo   bool
+		wantErr     error
+		wantBalance int
+		wantFrom    int
+		wantTo      int
+	}{
+		{name: "success", amount: 30, wantBalance: 20, wantFrom: 20, wantTo: 35},
+		{name: "whole balance", amount: 50, wantBalance: 0, wantFrom: 0, wantTo: 55},
+		{name: "insufficient balance", amount: 51, wantErr: models.ErrInsufficientPoints, wantFrom: 50, wantTo: 5},
+		{name: "unknown receiver", amount: 10, unknownTo: true, wantErr: models.ErrUserNotFound, wantFrom: 50, wantTo: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newTestRepository(t, Options{})
+			from := registerTestUser(t, repo, "alice")
+			to := registerTestUser(t, repo, "bob")
+			setTestPoints(t, repo, from.ID, 50)
+			setTestPoints(t, repo, to.ID, 5)
+
+			toID := to.ID
+			if tt.unknownTo {
+				toID = uuid.New()
+			}
+
+			balance, err := repo.TransferPoints(ctx, from.ID, toID, tt.amount)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("TransferPoints() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && balance != tt.wantBalance {
+				t.Errorf("TransferPoints() balance = %d, want %d", balance, tt.wantBalance)
+			}
+			if got := userPoints(t, repo, from.ID); got != tt.wantFrom {
+				t.Errorf("sender points = %d, want %d", got, tt.wantFrom)
+			}
+			if got := userPoints(t, repo, to.ID); got != tt.wantTo {
+				t.Errorf("receiver points = %d, want %d", got, tt.wantTo)
+			}
+		})
+	}
+}
+
+func TestIntegrationTransferPointsUnknownSender(t *testing.T) {
+	repo := newTestRepository(t, Options{})
+	to := registerTestUser(t, repo, "bob")
+
+	if _, err := repo.TransferPoints(context.Background(), uuid.New(), to.ID, 1); !errors.Is(err, models.ErrUserNotFound) {
+		t.Errorf("TransferPoints() error = %v, want %v", err, models.ErrUserNotFound)
+	}
+}
+
+func TestIntegrationTransferPointsConcurrent(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t, Options{})
+	alice := registerTestUser(t, repo, "alice")
+	bob := registerTestUser(t, repo, "bob")
+	setTestPoints(t, repo, alice.ID, 100)
+	setTestPoints(t, repo, bob.ID, 100)
+
+	// Встречные переводы в двух горутинах: блокировка строк в порядке id исключает
+	// взаимоблокировку, а проверка баланса под блокировкой - двойное списание
+	const transfers = 50
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*transfers)
+	for _, pair := range [][2]uuid.UUID{{alice.ID, bob.ID}, {bob.ID, alice.ID}} {
+		wg.Add(1)
+		go func(from, to uuid.UUID) {
+			defer wg.Done()
+			for i := 0; i < transfers; i++ {
+				if _, err := repo.TransferPoints(ctx, from, to, 7); err != nil && !errors.Is(err, models.ErrInsufficientPoints) {
+					errs <- err
+				}
+			}
+		}(pair[0], pair[1])
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("TransferPoints() error = %v", err)
+	}
+
+	alicePoints, bobPoints := userPoints(t, repo, alice.ID), userPoints(t, repo, bob.ID)
+	if alicePoints+bobPoints != 200 {
+		t.Errorf("total points = %d + %d = %d, want 200", alicePoints, bobPoints, alicePoints+bobPoints)
+	}
+	if alicePoints < 0 || bobPoints < 0 {
+		t.Errorf("balances went negative: alice %d, bob %d", alicePoints, bobPoints)
+	}
+}
+
+func TestIntegrationTransferPointsNoDoubleSpend(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t, Options{})
+	alice := registerTestUser(t, repo, "alice")
+	bob := registerTestUser(t, repo, "bob")
+	setTestPoints(t, repo, alice.ID, 10)
+
+	// Параллельные попытки перевести весь баланс: успешной должна быть только одна
+	const attempts = 10
+	var (
+		wg        sync.WaitGroup
+		succeeded atomic.Int64
+	)
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := repo.TransferPoints(ctx, alice.ID, bob.ID, 10)
+			switch {
+			case err == nil:
+				succeeded.Add(1)
+			case !errors.Is(err, models.ErrInsufficientPoints):
+				t.Errorf("TransferPoints() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := succeeded.Load(); got != 1 {
+		t.Errorf("successful transfers = %d, want 1", got)
+	}
+	if got := userPoints(t, repo, bob.ID); got != 10 {
+		t.Errorf("receiver points = %d, want 10", got)
+	}
+}
+
+// completeTestTask начисляет пользователю баллы выполненным заданием
+func completeTestTask(t *testing.T, repo *Repository, userID uuid.UUID, points int) *models.Task {
+	t.Helper()
+
+	task, err := repo.CompleteTask(context.Background(), userID, models.TaskRequest{TaskType: "daily", Points: points}, models.TaskActivityLimit{})
+	if err != nil {
+		t.Fatalf("CompleteTask(%s, %d) error = %v", userID, points, err)
+	}
+	return task
+}
+
+// countRows возвращает количество строк, найденных запросом SELECT COUNT(*)
+func countRows(t *testing.T, repo *Repository, query string, args ...any) int {
+	t.Helper()
+
+	var count int
+	if err := repo.db.QueryRow(query, args...).Scan(&count); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	return count
+}
+
+func TestIntegrationCompleteTask(t *testing.T) {
+	repo := newTestRepository(t, Options{})
+	user := registerTestUser(t, repo, "alice")
+
+	task := completeTestTask(t, repo, user.ID, 15)
+	if task.UserID != user.ID || task.Points != 15 {
+		t.Errorf("task = %+v, want user %s and 15 points", task, user.ID)
+	}
+	if task.Balance != 15 {
+		t.Errorf("task balance = %d, want 15", task.Balance)
+	}
+
+	var (
+		taskType string
+		points   int
+	)
+	err := repo.db.QueryRow("SELECT task_type, points FROM tasks WHERE id = $1 AND user_id = $2", task.ID, user.ID).Scan(&taskType, &points)
+	if err != nil {
+		t.Fatalf("select task: %v", err)
+	}
+	if taskType != "daily" || points != 15 {
+		t.Errorf("task row = %q %d, want daily 15", taskType, points)
+	}
+	if got := userPoints(t, repo, user.ID); got != 15 {
+		t.Errorf("points = %d, want 15", got)
+	}
+	if got := countRows(t, repo, "SELECT COUNT(*) FROM tasks WHERE user_id = $1", user.ID); got != 1 {
+		t.Errorf("tasks = %d, want 1", got)
+	}
+}
+
+func TestIntegrationCompleteTaskUnknownUser(t *testing.T) {
+	repo := newTestRepository(t, Options{})
+
+	_, err := repo.CompleteTask(context.Background(), uuid.New(), models.TaskRequest{TaskType: "daily", Points: 5}, models.TaskActivityLimit{})
+	if !errors.Is(err, models.ErrUserNotFound) {
+		t.Errorf("CompleteTask() error = %v, want %v", err, models.ErrUserNotFound)
+	}
+}
+
+func TestIntegrationCompleteTaskUserVanishedBeforeUpdate(t *testing.T) {
+	repo := newTestRepository(t, Options{})
+	user := registerTestUser(t, repo, "alice")
+
+	// Триггер пропускает обновление строки пользователя, как если бы она исчезла
+	// между проверкой существования и начислением баллов
+	_, err := repo.db.Exec(`
+		CREATE FUNCTION skip_update() RETURNS trigger AS $$ BEGIN RETURN NULL; END $$ LANGUAGE plpgsql;
+		CREATE TRIGGER skip_points_update BEFORE UPDATE ON users FOR EACH ROW EXECUTE FUNCTION skip_update();
+	`)
+	if err != nil {
+		t.Fatalf("create trigger: %v", err)
+	}
+
+	_, err = repo.CompleteTask(context.Background(), user.ID, models.TaskRequest{TaskType: "daily", Points: 5}, models.TaskActivityLimit{})
+	if !errors.Is(err, models.ErrUserNotFound) {
+		t.Fatalf("CompleteTask() error = %v, want %v", err, models.ErrUserNotFound)
+	}
+	// Запись о задании откатывается вместе с транзакцией
+	if got := countRows(t, repo, "SELECT COUNT(*) FROM tasks WHERE user_id = $1", user.ID); got != 0 {
+		t.Errorf("tasks = %d, want 0 after rollback", got)
+	}
+}
+
+func TestIntegrationCompleteTaskPointsOutOfRange(t *testing.T) {
+	tests := []struct {
+		name    string
+		balance int
+		points  int
+	}{
+		{name: "overflow", balance: math.MaxInt32 - 5, points: 10},
+		{name: "negative balance", balance: 5, points: -10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newTestRepository(t, Options{})
+			user := registerTestUser(t, repo, "alice")
+			setTestPoints(t, repo, user.ID, tt.balance)
+
+			_, err := repo.CompleteTask(context.Background(), user.ID, models.TaskRequest{TaskType: "daily", Points: tt.points}, models.TaskActivityLimit{})
+			if !errors.Is(err, models.ErrPointsOutOfRange) {
+				t.Fatalf("CompleteTask() error = %v, want %v", err, models.ErrPointsOutOfRange)
+			}
+			if got := userPoints(t, repo, user.ID); got != tt.balance {
+				t.Errorf("points = %d, want unchanged %d", got, tt.balance)
+			}
+			if got := countRows(t, repo, "SELECT COUNT(*) FROM tasks WHERE user_id = $1", user.ID); got != 0 {
+				t.Errorf("tasks = %d, want 0", got)
+			}
+		})
+	}
+}
+
+func TestIntegrationTransferPointsReceiverOverflow(t *testing.T) {
+	repo := newTestRepository(t, Options{})
+	from := registerTestUser(t, repo, "alice")
+	to := registerTestUser(t, repo, "bob")
+	setTestPoints(t, repo, from.ID, 50)
+	setTestPoints(t, repo, to.ID, math.MaxInt32-5)
+
+	if _, err := repo.TransferPoints(context.Background(), from.ID, to.ID, 10); !errors.Is(err, models.ErrPointsOutOfRange) {
+		t.Fatalf("TransferPoints() error = %v, want %v", err, models.ErrPointsOutOfRange)
+	}
+	if got := userPoints(t, repo, from.ID); got != 50 {
+		t.Errorf("sender points = %d, want unchanged 50", got)
+	}
+}
+
+func TestIntegrationNonNegativePointsConstraint(t *testing.T) {
+	repo := newTestRepository(t, Options{})
+	user := registerTestUser(t, repo, "alice")
+
+	_, err := repo.db.Exec("UPDATE users SET points = -1 WHERE id = $1", user.ID)
+	if !isPointsRangeViolation(err) {
+		t.Errorf("negative points update error = %v, want non_negative_points violation", err)
+	}
+}
+
+func TestIntegrationCompleteTaskActivityLimit(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t, Options{})
+	alice := registerTestUser(t, repo, "alice")
+
+	old := completeTestTask(t, repo, alice.ID, 5)
+	completeTestTask(t, repo, alice.ID, 5)
+	if _, err := repo.db.Exec("UPDATE tasks SET completed_at = NOW() - INTERVAL '2 hours' WHERE id = $1", old.ID); err != nil {
+		t.Fatalf("age task: %v", err)
+	}
+
+	// Задания старше окна не учитываются
+	counted := -1
+	limit := models.TaskActivityLimit{Since: time.Now().Add(-time.Hour), Check: func(count int) error {
+		counted = count
+		return nil
+	}}
+	if _, err := repo.CompleteTask(ctx, alice.ID, models.TaskRequest{TaskType: "daily", Points: 5}, limit); err != nil {
+		t.Fatalf("CompleteTask() error = %v", err)
+	}
+	if counted != 1 {
+		t.Errorf("tasks in the window = %d, want 1", counted)
+	}
+	if n := countRows(t, repo, "SELECT COUNT(*) FROM tasks WHERE user_id = $1", alice.ID); n != 3 {
+		t.Errorf("alice tasks = %d, want 3", n)
+	}
+}
+
+func TestIntegrationGetUserTasks(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t, Options{})
+	user := registerTestUser(t, repo, "alice")
+	other := registerTestUser(t, repo, "bob")
+	completeTestTask(t, repo, other.ID, 100)
+
+	// Задания выполнены в разное время: daily 3 дня назад, vk 2 дня назад, daily день назад
+	var ids []uuid.UUID
+	for i, taskType := range []string{"daily", "vk", "daily"} {
+		task, err := repo.CompleteTask(ctx, user.ID, models.TaskRequest{TaskType: taskType, Points: i + 1}, models.TaskActivityLimit{})
+		if err != nil {
+			t.Fatalf("CompleteTask() error = %v", err)
+		}
+		_, err = repo.db.Exec("UPDATE tasks SET completed_at = NOW() - make_interval(days => $1) WHERE id = $2", 3-i, task.ID)
+		if err != nil {
+			t.Fatalf("set completed_at: %v", err)
+		}
+		ids = append(ids, task.ID)
+	}
+
+	tests := []struct {
+		name     string
+		taskType string
+		limit    int
+		offset   int
+		want     []uuid.UUID
+	}{
+		{name: "newest first", limit: 10, want: []uuid.UUID{ids[2], ids[1], ids[0]}},
+		{name: "filter by type", taskType: "daily", limit: 10, want: []uuid.UUID{ids[2], ids[0]}},
+		{name: "first page", limit: 2, want: []uuid.UUID{ids[2], ids[1]}},
+		{name: "second page", limit: 2, offset: 2, want: []uuid.UUID{ids[0]}},
+		{name: "past the end", limit: 2, offset: 5, want: []uuid.UUID{}},
+		{name: "unknown type", taskType: "telegram", limit: 10, want: []uuid.UUID{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tasks, err := repo.GetUserTasks(ctx, user.ID, tt.taskType, tt.limit, tt.offset)
+			if err != nil {
+				t.Fatalf("GetUserTasks() error = %v", err)
+			}
+			if tasks == nil {
+				t.Fatal("GetUserTasks() = nil, want an empty slice")
+			}
+			got := make([]uuid.UUID, 0, len(tasks))
+			for _, task := range tasks {
+				if task.UserID != user.ID {
+					t.Errorf("task %s belongs to %s, want %s", task.ID, task.UserID, user.ID)
+				}
+				got = append(got, task.ID)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("GetUserTasks() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIntegrationGetTaskStats(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t, Options{})
+	user := registerTestUser(t, repo, "alice")
+
+	base := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	for i, tc := range []struct {
+		taskType string
+		points   int
+	}{{"daily", 10}, {"vk", 5}, {"daily", 20}} {
+		task, err := repo.CompleteTask(ctx, user.ID, models.TaskRequest{TaskType: tc.taskType, Points: tc.points}, models.TaskActivityLimit{})
+		if err != nil {
+			t.Fatalf("CompleteTask() error = %v", err)
+		}
+		if _, err := repo.db.Exec("UPDATE tasks SET completed_at = $1 WHERE id = $2", base.AddDate(0, 0, i), task.ID); err != nil {
+			t.Fatalf("set completed_at: %v", err)
+		}
+	}
+
+	day := func(offset int) *time.Time {
+		d := base.AddDate(0, 0, offset)
+		return &d
+	}
+
+	tests := []struct {
+		name     string
+		from, to *time.Time
+		want     []models.TaskStats
+	}{
+		{name: "all time", want: []models.TaskStats{{TaskType: "daily", Count: 2, TotalPoints: 30}, {TaskType: "vk", Count: 1, TotalPoints: 5}}},
+		{name: "from is inclusive", from: day(1), want: []models.TaskStats{{TaskType: "daily", Count: 1, TotalPoints: 20}, {TaskType: "vk", Count: 1, TotalPoints: 5}}},
+		{name: "to is exclusive", to: day(1), want: []models.TaskStats{{TaskType: "daily", Count: 1, TotalPoints: 10}}},
+		{name: "empty range", from: day(5), want: []models.TaskStats{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats, err := repo.GetTaskStats(ctx, tt.from, tt.to)
+			if err != nil {
+				t.Fatalf("GetTaskStats() error = %v", err)
+			}
+			got := make([]models.TaskStats, 0, len(stats))
+			for _, s := range stats {
+				got = append(got, *s)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("GetTaskStats() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIntegrationUserRole(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t, Options{})
+	user := registerTestUser(t, repo, "alice")
+	if user.Role != models.RoleUser {
+		t.Errorf("new user role = %q, want %q", user.Role, models.RoleUser)
+	}
+
+	if _, err := repo.db.Exec("UPDATE users SET role = $1 WHERE id = $2", models.RoleAdmin, user.ID); err != nil {
+		t.Fatalf("set role: %v", err)
+	}
+	loggedIn, err := repo.LoginUser(ctx, "alice", "hash-alice", nil)
+	if err != nil {
+		t.Fatalf("LoginUser() error = %v", err)
+	}
+	if loggedIn.Role != models.RoleAdmin {
+		t.Errorf("LoginUser() role = %q, want %q", loggedIn.Role, models.RoleAdmin)
+	}
+	byID, err := repo.GetUserByID(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("GetUserByID() error = %v", err)
+	}
+	if byID.Role != models.RoleAdmin {
+		t.Errorf("GetUserByID() role = %q, want %q", byID.Role, models.RoleAdmin)
+	}
+}
+
+func TestIntegrationDeleteUser(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t, Options{ReferralSchedule: []int{10}})
+	referrer := registerTestUser(t, repo, "alice")
+	user := registerTestUser(t, repo, "bob")
+	invited := registerTestUser(t, repo, "carol")
+
+	if _, _, err := repo.AddReferrer(ctx, user.ID, referrer.ID); err != nil {
+		t.Fatalf("AddReferrer(bob -> alice) error = %v", err)
+	}
+	if _, _, err := repo.AddReferrer(ctx, invited.ID, user.ID); err != nil {
+		t.Fatalf("AddReferrer(carol -> bob) error = %v", err)
+	}
+	completeTestTask(t, repo, user.ID, 5)
+	if err := repo.UpdatePassword(ctx, user.ID, "new-hash", 5); err != nil {
+		t.Fatalf("UpdatePassword() error = %v", err)
+	}
+
+	if err := repo.DeleteUser(ctx, user.ID); err != nil {
+		t.Fatalf("DeleteUser() error = %v", err)
+	}
+
+	for query, want := range map[string]int{
+		"SELECT COUNT(*) FROM users WHERE id = $1":                 0,
+		"SELECT COUNT(*) FROM tasks WHERE user_id = $1":            0,
+		"SELECT COUNT(*) FROM password_history WHERE user_id = $1": 0,
+		"SELECT COUNT(*) FROM users WHERE referrer_id = $1":        0,
+	} {
+		if got := countRows(t, repo, query, user.ID); got != want {
+			t.Errorf("%s = %d, want %d", query, got, want)
+		}
+	}
+
+	// Приглашенный пользователь остается без реферера, реферер сохраняет бонус
+	carol, err := repo.GetUserByID(ctx, invited.ID)
+	if err != nil {
+		t.Fatalf("GetUserByID(carol) error = %v", err)
+	}
+	if carol.ReferrerID != nil {
+		t.Errorf("carol referrer_id = %s, want NULL", *carol.ReferrerID)
+	}
+	if got := userPoints(t, repo, referrer.ID); got != 10 {
+		t.Errorf("referrer points = %d, want bonus 10 kept", got)
+	}
+}
+
+func TestIntegrationDeleteUserUnknown(t *testing.T) {
+	repo := newTestRepository(t, Options{})
+
+	if err := repo.DeleteUser(context.Background(), uuid.New()); !errors.Is(err, models.ErrUserNotFound) {
+		t.Errorf("DeleteUser() error = %v, want %v", err, models.ErrUserNotFound)
+	}
+}
+
+func TestIntegrationUpdateUsername(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t, Options{})
+	alice := registerTestUser(t, repo, "alice")
+	registerTestUser(t, repo, "bob")
+
+	tests := []struct {
+		name     string
+		userID   uuid.UUID
+		username string
+		version  int
+		wantErr  error
+	}{
+		{name: "unchanged", userID: alice.ID, username: "alice", wantErr: models.ErrUsernameUnchanged},
+		{name: "taken", userID: alice.ID, username: "bob", wantErr: models.ErrUsernameTaken},
+		{name: "unknown user", userID: uuid.New(), username: "dave", wantErr: models.ErrUserNotFound},
+		{name: "stale version", userID: alice.ID, username: "carol", version: 2, wantErr: models.ErrConflict},
+		{name: "success", userID: alice.ID, username: "carol", version: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := repo.UpdateUsername(ctx, tt.userID, tt.username, tt.version); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UpdateUsername(%q) error = %v, want %v", tt.username, err, tt.wantErr)
+			}
+		})
+	}
+
+	user, err := repo.GetUserByID(ctx, alice.ID)
+	if err != nil {
+		t.Fatalf("GetUserByID() error = %v", err)
+	}
+	if user.Username != "carol" || user.Version != 2 {
+		t.Errorf("user = %q version %d, want carol version 2", user.Username, user.Version)
+	}
+	// Старое имя освобождается
+	if exists, err := repo.UsernameExists(ctx, "alice"); err != nil || exists {
+		t.Errorf("UsernameExists(alice) = %t, %v, want false after rename", exists, err)
+	}
+}
+
+func TestIntegrationAdjustPoints(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t, Options{})
+	admin := registerTestUser(t, repo, "admin_user")
+	user := registerTestUser(t, repo, "alice")
+	setTestPoints(t, repo, user.ID, 30)
+
+	tests := []struct {
+		name        string
+		delta       int
+		wantApplied int
+		wantBalance int
+	}{
+		{name: "positive", delta: 50, wantApplied: 50, wantBalance: 80},
+		{name: "negative", delta: -20, wantApplied: -20, wantBalance: 60},
+		{name: "floored at zero", delta: -100, wantApplied: -60, wantBalance: 0},
+	}
+
+	for _, tt := range tests {
+		adjustment, err := repo.AdjustPoints(ctx, admin.ID, user.ID, tt.delta, tt.name)
+		if err != nil {
+			t.Fatalf("%s: AdjustPoints() error = %v", tt.name, err)
+		}
+		if adjustment.AppliedDelta != tt.wantApplied || adjustment.Balance != tt.wantBalance {
+			t.Errorf("%s: applied %d, balance %d, want %d and %d", tt.name, adjustment.AppliedDelta, adjustment.Balance, tt.wantApplied, tt.wantBalance)
+		}
+		if got := userPoints(t, repo, user.ID); got != tt.wantBalance {
+			t.Errorf("%s: users.points = %d, want %d", tt.name, got, tt.wantBalance)
+		}
+
+		var (
+			adminID             uuid.UUID
+			delta, appliedDelta int
+			reason              string
+		)
+		err = repo.db.QueryRow("SELECT admin_id, delta, applied_delta, reason FROM point_adjustments WHERE id = $1", adjustment.ID).
+			Scan(&adminID, &delta, &appliedDelta, &reason)
+		if err != nil {
+			t.Fatalf("%s: select adjustment: %v", tt.name, err)
+		}
+		if adminID != admin.ID || delta != tt.delta || appliedDelta != tt.wantApplied || reason != tt.name {
+			t.Errorf("%s: adjustment row = %s %d %d %q", tt.name, adminID, delta, appliedDelta, reason)
+		}
+	}
+}
+
+func TestIntegrationAdjustPointsErrors(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t, Options{})
+	admin := registerTestUser(t, repo, "admin_user")
+	user := registerTestUser(t, repo, "alice")
+	setTestPoints(t, repo, user.ID, math.MaxInt32-5)
+
+	if _, err := repo.AdjustPoints(ctx, admin.ID, uuid.New(), 5, "bonus"); !errors.Is(err, models.ErrUserNotFound) {
+		t.Errorf("AdjustPoints(unknown user) error = %v, want %v", err, models.ErrUserNotFound)
+	}
+	if _, err := repo.AdjustPoints(ctx, admin.ID, user.ID, 10, "bonus"); !errors.Is(err, models.ErrPointsOutOfRange) {
+		t.Errorf("AdjustPoints() overflow error = %v, want %v", err, models.ErrPointsOutOfRange)
+	}
+	if n := countRows(t, repo, "SELECT COUNT(*) FROM point_adjustments"); n != 0 {
+		t.Errorf("point_adjustments rows = %d, want 0 after failed adjustments", n)
+	}
+}
+
+func TestIntegrationAuditLog(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t, Options{})
+	admin, other := uuid.New(), uuid.New()
+	userID, taskID := uuid.New(), uuid.New()
+
+	records := []struct {
+		actor    uuid.UUID
+		action   string
+		target   uuid.UUID
+		metadata map[string]any
+	}{
+		{actor: admin, action: models.AuditPointsAdjusted, target: userID, metadata: map[string]any{"delta": -50, "reason": "fraud"}},
+		{actor: admin, action: models.AuditTaskReverted, target: taskID},
+		{actor: other, action: models.AuditUserDeleted, target: other},
+	}
+	for _, rec := range records {
+		if err := repo.Record(ctx, rec.actor, rec.action, rec.target, rec.metadata); err != nil {
+			t.Fatalf("Record(%s) error = %v", rec.action, err)
+		}
+	}
+	// Первая запись старше остальных, чтобы проверить фильтр since
+	if _, err := repo.db.Exec("UPDATE audit_log SET created_at = NOW() - INTERVAL '2 days' WHERE action = $1", models.AuditPointsAdjusted); err != nil {
+		t.Fatalf("age audit entry: %v", err)
+	}
+
+	entries, total, err := repo.ListAudit(ctx, models.AuditFilter{}, 10, 0)
+	if err != nil {
+		t.Fatalf("ListAudit() error = %v", err)
+	}
+	if total != 3 || len(entries) != 3 {
+		t.Fatalf("ListAudit() = %d entries of %d, want 3 of 3", len(entries), total)
+	}
+	if entries[2].Action != models.AuditPointsAdjusted {
+		t.Errorf("oldest entry = %s, want %s last", entries[2].Action, models.AuditPointsAdjusted)
+	}
+	if got := entries[2].Metadata; got["reason"] != "fraud" || got["delta"] != float64(-50) {
+		t.Errorf("metadata = %v, want delta -50 and reason fraud", got)
+	}
+	if got := entries[1].Metadata; got == nil || len(got) != 0 {
+		t.Errorf("metadata without values = %v, want an empty object", got)
+	}
+
+	since := time.Now().Add(-time.Hour)
+	tests := []struct {
+		name       string
+		filter     models.AuditFilter
+		wantTotal  int
+		wantAction string
+	}{
+		{name: "actor", filter: models.AuditFilter{ActorID: &other}, wantTotal: 1, wantAction: models.AuditUserDeleted},
+		{name: "action", filter: models.AuditFilter{Action: models.AuditTaskReverted}, wantTotal: 1, wantAction: models.AuditTaskReverted},
+		{name: "since", filter: models.AuditFilter{ActorID: &admin, Since: &since}, wantTotal: 1, wantAction: models.AuditTaskReverted},
+	}
+	for _, tt := range tests {
+		entries, total, err := repo.ListAudit(ctx, tt.filter, 10, 0)
+		if err != nil {
+			t.Fatalf("%s: ListAudit() error = %v", tt.name, err)
+		}
+		if total != tt.wantTotal || len(entries) != 1 || entries[0].Action != tt.wantAction {
+			t.Errorf("%s: ListAudit() = %d entries of %d, want one %s", tt.name, len(entries), total, tt.wantAction)
+		}
+	}
+
+	entries, total, err = repo.ListAudit(ctx, models.AuditFilter{}, 1, 1)
+	if err != nil {
+		t.Fatalf("ListAudit() page error = %v", err)
+	}
+	if total != 3 || len(entries) != 1 {
+		t.Errorf("ListAudit() page = %d entries of %d, want 1 of 3", len(entries), total)
+	}
+}
+
+func TestIntegrationAuditLogOutlivesUser(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t, Options{})
+	user := registerTestUser(t, repo, "alice")
+
+	if err := repo.Record(ctx, user.ID, models.AuditUserDeleted, user.ID, nil); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	if err := repo.DeleteUser(ctx, user.ID); err != nil {
+		t.Fatalf("DeleteUser() error = %v", err)
+	}
+
+	entries, total, err := repo.ListAudit(ctx, models.AuditFilter{ActorID: &user.ID}, 10, 0)
+	if err != nil {
+		t.Fatalf("ListAudit() error = %v", err)
+	}
+	if total != 1 || len(entries) != 1 {
+		t.Errorf("ListAudit() = %d entries of %d, want the entry of the deleted user", len(entries), total)
+	}
+}
+
+func TestIntegrationRevertTask(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t, Options{})
+	user := registerTestUser(t, repo, "alice")
+
+	kept, err := repo.CompleteTask(ctx, user.ID, models.TaskRequest{TaskType: "daily", Points: 10}, models.TaskActivityLimit{})
+	if err != nil {
+		t.Fatalf("CompleteTask() error = %v", err)
+	}
+	reverted, err := repo.CompleteTask(ctx, user.ID, models.TaskRequest{TaskType: "vk", Points: 30}, models.TaskActivityLimit{})
+	if err != nil {
+		t.Fatalf("CompleteTask() error = %v", err)
+	}
+
+	task, err := repo.RevertTask(ctx, reverted.ID)
+	if err != nil {
+		t.Fatalf("RevertTask() error = %v", err)
+	}
+	if task.DeletedAt == nil || task.UserID != user.ID || task.Points != 30 {
+		t.Errorf("RevertTask() = %+v, want the vk task of %s marked deleted", task, user.ID)
+	}
+	if got := userPoints(t, repo, user.ID); got != 10 {
+		t.Errorf("points after revert = %d, want 10", got)
+	}
+
+	tasks, err := repo.GetUserTasks(ctx, user.ID, "", 10, 0)
+	if err != nil {
+		t.Fatalf("GetUserTasks() error = %v", err)
+	}
+	if len(tasks) != 1 || tasks[0].ID != kept.ID {
+		t.Errorf("GetUserTasks() = %v, want only the kept task %s", tasks, kept.ID)
+	}
+	stats, err := repo.GetTaskStats(ctx, nil, nil)
+	if err != nil {
+		t.Fatalf("GetTaskStats() error = %v", err)
+	}
+	if len(stats) != 1 || stats[0].TaskType != "daily" {
+		t.Errorf("GetTaskStats() = %v, want only daily tasks", stats)
+	}
+
+	if _, err := repo.RevertTask(ctx, reverted.ID); !errors.Is(err, models.ErrTaskAlreadyReverted) {
+		t.Errorf("second RevertTask() error = %v, want %v", err, models.ErrTaskAlreadyReverted)
+	}
+	if _, err := repo.RevertTask(ctx, uuid.New()); !errors.Is(err, models.ErrTaskNotFound) {
+		t.Errorf("RevertTask() of unknown task error = %v, want %v", err, models.ErrTaskNotFound)
+	}
+}
+
+func TestIntegrationRevertTaskKeepsBalanceNonNegative(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t, Options{})
+	user := registerTestUser(t, repo, "alice")
+
+	task, err := repo.CompleteTask(ctx, user.ID, models.TaskRequest{TaskType: "daily", Points: 30}, models.TaskActivityLimit{})
+	if err != nil {
+		t.Fatalf("CompleteTask() error = %v", err)
+	}
+	// Часть баллов уже потрачена
+	setTestPoints(t, repo, user.ID, 10)
+
+	if _, err := repo.RevertTask(ctx, task.ID); err != nil {
+		t.Fatalf("RevertTask() error = %v", err)
+	}
+	if got := userPoints(t, repo, user.ID); got != 0 {
+		t.Errorf("points after revert = %d, want 0", got)
+	}
+}
+
+func TestIntegrationGetPeriodLeaderboard(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t, Options{})
+	alice := registerTestUser(t, repo, "alice")
+	bob := registerTestUser(t, repo, "bob")
+	carol := registerTestUser(t, repo, "carol")
+
+	from := time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 7)
+	complete := func(userID uuid.UUID, points int, at time.Time) *models.Task {
+		t.Helper()
+		task, err := repo.CompleteTask(ctx, userID, models.TaskRequest{TaskType: "daily", Points: points}, models.TaskActivityLimit{})
+		if err != nil {
+			t.Fatalf("CompleteTask() error = %v", err)
+		}
+		if _, err := repo.db.Exec("UPDATE tasks SET completed_at = $1 WHERE id = $2", at, task.ID); err != nil {
+			t.Fatalf("set completed_at: %v", err)
+		}
+		return task
+	}
+
+	complete(alice.ID, 20, from)
+	complete(alice.ID, 10, from.AddDate(0, 0, 3))
+	complete(bob.ID, 30, to.Add(-time.Second))
+	complete(carol.ID, 10, from.AddDate(0, 0, 1))
+	// Задания вне периода и отмененные задания не учитываются
+	complete(carol.ID, 100, to)
+	complete(carol.ID, 100, from.Add(-time.Second))
+	reverted := complete(carol.ID, 100, from.AddDate(0, 0, 2))
+	if _, err := repo.RevertTask(ctx, reverted.ID); err != nil {
+		t.Fatalf("RevertTask() error = %v", err)
+	}
+
+	entries, total, err := repo.GetPeriodLeaderboard(ctx, from, to, 10, 0)
+	if err != nil {
+		t.Fatalf("GetPeriodLeaderboard() error = %v", err)
+	}
+	if total != 3 {
+		t.Errorf("total = %d, want 3", total)
+	}
+	want := []models.PeriodLeaderboardEntry{
+		{UserID: alice.ID, Username: "alice", Points: 30, Rank: 1},
+		{UserID: bob.ID, Username: "bob", Points: 30, Rank: 1},
+		{UserID: carol.ID, Username: "carol", Points: 10, Rank: 3},
+	}
+	got := make([]models.PeriodLeaderboardEntry, 0, len(entries))
+	for _, entry := range entries {
+		got = append(got, *entry)
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("GetPeriodLeaderboard() = %v, want %v", got, want)
+	}
+
+	page, _, err := repo.GetPeriodLeaderboard(ctx, from, to, 1, 2)
+	if err != nil {
+		t.Fatalf("GetPeriodLeaderboard() page error = %v", err)
+	}
+	if len(page) != 1 || page[0].UserID != carol.ID {
+		t.Errorf("GetPeriodLeaderboard(limit 1, offset 2) = %v, want carol", page)
+	}
+
+	empty, total, err := repo.GetPeriodLeaderboard(ctx, to.AddDate(0, 0, 7), to.AddDate(0, 0, 14), 10, 0)
+	if err != nil {
+		t.Fatalf("GetPeriodLeaderboard() empty error = %v", err)
+	}
+	if empty == nil || len(empty) != 0 || total != 0 {
+		t.Errorf("GetPeriodLeaderboard() of an empty period = %v, %d, want an empty slice and 0", empty, total)
+	}
+}
+
+func TestIntegrationRecordLoginStreak(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t, Options{StreakBonus: 5})
+	user := registerTestUser(t, repo, "alice")
+
+	day := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	steps := []struct {
+		name       string
+		now        time.Time
+		wantStreak int
+		wantBonus  int
+		wantPoints int
+	}{
+		{name: "first login starts the streak", now: day, wantStreak: 1},
+		{name: "same day keeps the streak", now: day.Add(10 * time.Hour), wantStreak: 1},
+		{name: "next day extends the streak", now: day.AddDate(0, 0, 1), wantStreak: 2, wantBonus: 5, wantPoints: 5},
+		{name: "third day extends the streak", now: day.AddDate(0, 0, 2), wantStreak: 3, wantBonus: 5, wantPoints: 10},
+		{name: "skipped day resets the streak", now: day.AddDate(0, 0, 4), wantStreak: 1, wantPoints: 10},
+	}
+
+	for _, step := range steps {
+		streak, bonus, err := repo.RecordLogin(ctx, user.ID, step.now)
+		if err != nil {
+			t.Fatalf("%s: RecordLogin() error = %v", step.name, err)
+		}
+		if streak != step.wantStreak || bonus != step.wantBonus {
+			t.Errorf("%s: RecordLogin() = %d, %d, want %d, %d", step.name, streak, bonus, step.wantStreak, step.wantBonus)
+		}
+		if got := userPoints(t, repo, user.ID); got != step.wantPoints {
+			t.Errorf("%s: points = %d, want %d", step.name, got, step.wantPoints)
+		}
+	}
+
+	got, err := repo.GetUserByID(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("GetUserByID() error = %v", err)
+	}
+	if got.StreakDays != 1 || got.LastLoginAt == nil || !got.LastLoginAt.Equal(day.AddDate(0, 0, 4)) {
+		t.Errorf("GetUserByID() streak = %d, last login = %v, want 1 and %s", got.StreakDays, got.LastLoginAt, day.AddDate(0, 0, 4))
+	}
+}
+
+func TestIntegrationRecordLoginSkipsBonusOutOfRange(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t, Options{StreakBonus: 10})
+	user := registerTestUser(t, repo, "alice")
+
+	day := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	if _, _, err := repo.RecordLogin(ctx, user.ID, day); err != nil {
+		t.Fatalf("RecordLogin() error = %v", err)
+	}
+	setTestPoints(t, repo, user.ID, math.MaxInt32-5)
+
+	streak, bonus, err := repo.RecordLogin(ctx, user.ID, day.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatalf("RecordLogin() error = %v", err)
+	}
+	if streak != 2 || bonus != 0 {
+		t.Errorf("RecordLogin() = %d, %d, want streak 2 without bonus", streak, bonus)
+	}
+	if got := userPoints(t, repo, user.ID); got != math.MaxInt32-5 {
+		t.Errorf("points = %d, want %d", got, math.MaxInt32-5)
+	}
+}
+
+func TestIntegrationGetCredentials(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t, Options{})
+	user := registerTestUser(t, repo, "alice")
+
+	id, hash, err := repo.GetCredentials(ctx, "alice")
+	if err != nil {
+		t.Fatalf("GetCredentials() error = %v", err)
+	}
+	if id != user.ID || hash != "hash-alice" {
+		t.Errorf("GetCredentials() = %s, %q, want %s, %q", id, hash, user.ID, "hash-alice")
+	}
+	if _, _, err := repo.GetCredentials(ctx, "bob"); !errors.Is(err, models.ErrUserNotFound) {
+		t.Errorf("GetCredentials() of unknown user error = %v, want %v", err, models.ErrUserNotFound)
+	}
+	if _, _, err := repo.RecordLogin(ctx, uuid.New(), time.Now()); !errors.Is(err, models.ErrUserNotFound) {
+		t.Errorf("RecordLogin() of unknown user error = %v, want %v", err, models.ErrUserNotFound)
+	}
+}
+
+func TestIntegrationImportUsersSkipsDuplicates(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t, Options{})
+	registerTestUser(t, repo, "alice")
+
+	inserted, err := repo.ImportUsers(ctx, []models.ImportUserRequest{
+		{Username: "alice", Password: "hash-import", Points: 50},
+		{Username: "ALICE", Password: "hash-import-upper", Points: 40},
+		{Username: "bob", Password: "hash-bob", Points: 20},
+		{Username: "Bob", Password: "hash-bob-again", Points: 30},
+		{Username: "carol", Password: "hash-carol"},
+	})
+	if err != nil {
+		t.Fatalf("ImportUsers() error = %v", err)
+	}
+	if inserted != 2 {
+		t.Errorf("inserted = %d, want 2", inserted)
+	}
+
+	// Существующий пользователь не изменен, из повторов внутри пакета (без учета регистра) сохранен первый
+	var alicePoints int
+	if err := repo.db.QueryRow("SELECT points FROM users WHERE username = 'alice'").Scan(&alicePoints); err != nil {
+		t.Fatalf("select alice: %v", err)
+	}
+	if alicePoints != 0 {
+		t.Errorf("alice points = %d, want 0", alicePoints)
+	}
+	var bobHash string
+	var bobPoints int
+	if err := repo.db.QueryRow("SELECT passw, points FROM users WHERE username = 'bob'").Scan(&bobHash, &bobPoints); err != nil {
+		t.Fatalf("select bob: %v", err)
+	}
+	if bobHash != "hash-bob" || bobPoints != 20 {
+		t.Errorf("bob = %q, %d, want hash-bob, 20", bobHash, bobPoints)
+	}
+}
+
+func TestIntegrationImportUsersManyChunks(t *testing.T) {
+	repo := newTestRepository(t, Options{})
+
+	users := make([]models.ImportUserRequest, importChunkSize*2+1)
+	for i := range users {
+		users[i] = models.ImportUserRequest{Username: fmt.Sprintf("user_%d", i), Password: "hash"}
+	}
+
+	inserted, err := repo.ImportUsers(context.Background(), users)
+	if err != nil {
+		t.Fatalf("ImportUsers() error = %v", err)
+	}
+	if inserted != len(users) {
+		t.Errorf("inserted = %d, want %d", inserted, len(users))
+	}
+	if n := countRows(t, repo, "SELECT COUNT(*) FROM users"); n != len(users) {
+		t.Errorf("users count = %d, want %d", n, len(users))
+	}
+}
+
+func TestIntegrationImportUsersPointsOutOfRangeRollsBack(t *testing.T) {
+	repo := newTestRepository(t, Options{})
+
+	users := make([]models.ImportUserRequest, importChunkSize+1)
+	for i := range users {
+		users[i] = models.ImportUserRequest{Username: fmt.Sprintf("user_%d", i), Password: "hash"}
+	}
+	// Последний пакет не помещается в баланс; первый пакет откатывается вместе с ним
+	users[len(users)-1].Points = math.MaxInt32 + 1
+
+	if _, err := repo.ImportUsers(context.Background(), users); !errors.Is(err, models.ErrPointsOutOfRange) {
+		t.Fatalf("ImportUsers() error = %v, want %v", err, models.ErrPointsOutOfRange)
+	}
+	if n := countRows(t, repo, "SELECT COUNT(*) FROM users"); n != 0 {
+		t.Errorf("users count = %d, want 0 after rollback", n)
+	}
+}
+
+func TestIntegrationCompleteTaskConcurrent(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t, Options{})
+	user := registerTestUser(t, repo, "alice")
+
+	const workers = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := repo.CompleteTask(ctx, user.ID, models.TaskRequest{TaskType: "daily", Points: 5}, models.TaskActivityLimit{})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("CompleteTask() error = %v", err)
+		}
+	}
+	if got := userPoints(t, repo, user.ID); got != workers*5 {
+		t.Errorf("points = %d, want %d", got, workers*5)
+	}
+	if n := countRows(t, repo, "SELECT COUNT(*) FROM tasks WHERE user_id = $1", user.ID); n != workers {
+		t.Errorf("tasks count = %d, want %d", n, workers)
+	}
+}
+
+func TestIntegrationCompleteTaskConcurrentNearLimit(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t, Options{})
+	user := registerTestUser(t, repo, "alice")
+	setTestPoints(t, repo, user.ID, math.MaxInt32-25)
+
+	// Только два начисления по 10 баллов помещаются в баланс
+	const workers = 5
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := repo.CompleteTask(ctx, user.ID, models.TaskRequest{TaskType: "daily", Points: 10}, models.TaskActivityLimit{})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	var succeeded int
+	for err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.Is(err, models.ErrPointsOutOfRange):
+			t.Errorf("CompleteTask() error = %v, want nil or %v", err, models.ErrPointsOutOfRange)
+		}
+	}
+	if succeeded != 2 {
+		t.Errorf("succeeded = %d, want 2", succeeded)
+	}
+	if got := userPoints(t, repo, user.ID); got != math.MaxInt32-5 {
+		t.Errorf("points = %d, want %d", got, math.MaxInt32-5)
+	}
+	if n := countRows(t, repo, "SELECT COUNT(*) FROM tasks WHERE user_id = $1", user.ID); n != 2 {
+		t.Errorf("tasks count = %d, want 2", n)
+	}
+}
+
+func TestIntegrationQueryTimeout(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t, Options{QueryTimeout: 100 * time.Millisecond})
+
+	t.Run("pg_sleep", func(t *testing.T) {
+		start := time.Now()
+		err := func() (err error) {
+			ctx, done := repo.withTimeout(ctx, &err)
+			defer done()
+
+			_, err = repo.db.ExecContext(ctx, "SELECT pg_sleep(5)")
+			return err
+		}()
+		if !errors.Is(err, models.ErrQueryTimeout) {
+			t.Errorf("pg_sleep error = %v, want %v", err, models.ErrQueryTimeout)
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("pg_sleep returned after %s, want it canceled after the query timeout", elapsed)
+		}
+	})
+
+	t.Run("blocked method", func(t *testing.T) {
+		user := registerTestUser(t, repo, "alice")
+		blocker, err := repo.db.BeginTx(ctx, nil)
+		if err != nil {
+			t.Fatalf("begin blocking transaction: %v", err)
+		}
+		defer blocker.Rollback()
+		if _, err := blocker.Exec("SELECT 1 FROM users WHERE id = $1 FOR UPDATE", user.ID); err != nil {
+			t.Fatalf("lock user row: %v", err)
+		}
+
+		if _, err := repo.CompleteTask(ctx, user.ID, models.TaskRequest{TaskType: "daily", Points: 10}, models.TaskActivityLimit{}); !errors.Is(err, models.ErrQueryTimeout) {
+			t.Errorf("CompleteTask() error = %v, want %v", err, models.ErrQueryTimeout)
+		}
+
+		// Собственный срок вызывающего не подменяется и не считается таймаутом репозитория
+		callerCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+		defer cancel()
+		_, err = repo.CompleteTask(callerCtx, user.ID, models.TaskRequest{TaskType: "daily", Points: 10}, models.TaskActivityLimit{})
+		if err == nil || errors.Is(err, models.ErrQueryTimeout) {
+			t.Errorf("CompleteTask() with caller deadline error = %v, want a non-timeout error", err)
+		}
+	})
+}
@@ -0,0 +1,78 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// GetPeriodLeaderboard возвращает страницу пользователей, отсортированных по сумме баллов
+// за задания, выполненные в интервале [from, to), и общее количество пользователей в рейтинге.
+// Отмененные задания не учитываются; равные суммы получают одинаковое место.
+func (r *Repository) GetPeriodLeaderboard(ctx context.Context, from, to time.Time, limit, offset int) (_ []*models.PeriodLeaderboardEntry, _ int, err error) {
+	ctx, done := r.withTimeout(ctx, &err)
+	defer done()
+
+	log := logger.FromContext(ctx, r.log)
+	log.Debug("Getting period leaderboard",
+		zap.Time("from", from),
+		zap.Time("to", to),
+		zap.Int("limit", limit),
+		zap.Int("offset", offset))
+
+	var total int
+	err = r.db.QueryRowContext(ctx, `
+		SELECT COUNT(DISTINCT user_id)
+		FROM tasks
+		WHERE deleted_at IS NULL AND completed_at >= $1 AND completed_at < $2
+	`, from, to).Scan(&total)
+	if err != nil {
+		log.Error("Failed to count period leaderboard users", zap.Error(err))
+		return nil, 0, fmt.Errorf("failed to count period leaderboard users: %w", err)
+	}
+
+	query := `
+		SELECT user_id, username, points, rank
+		FROM (
+			SELECT u.id AS user_id, u.username, SUM(t.points) AS points,
+				RANK() OVER (ORDER BY SUM(t.points) DESC) AS rank
+			FROM tasks t
+			JOIN users u ON u.id = t.user_id
+			WHERE t.deleted_at IS NULL AND t.completed_at >= $1 AND t.completed_at < $2
+			GROUP BY u.id, u.username
+		) ranked
+		ORDER BY rank, username
+		LIMIT $3 OFFSET $4
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, from, to, limit, offset)
+	if err != nil {
+		log.Error("Failed to query period leaderboard", zap.Error(err))
+		return nil, 0, fmt.Errorf("failed to query period leaderboard: %w", err)
+	}
+	defer rows.Close()
+
+	entries := make([]*models.PeriodLeaderboardEntry, 0)
+	for rows.Next() {
+		var entry models.PeriodLeaderboardEntry
+		if err := rows.Scan(&entry.UserID, &entry.Username, &entry.Points, &entry.Rank); err != nil {
+			log.Error("Failed to scan period leaderboard entry", zap.Error(err))
+			return nil, 0, fmt.Errorf("failed to scan period leaderboard entry: %w", err)
+		}
+		entries = append(entries, &entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Error("Error iterating rows", zap.Error(err))
+		return nil, 0, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	log.Debug("Period leaderboard retrieved successfully",
+		zap.Int("entries_count", len(entries)),
+		zap.Int("total", total))
+	return entries, total, nil
+}
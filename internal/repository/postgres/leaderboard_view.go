@@ -0,0 +1,37 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// GetLeaderboardView возвращает страницу таблицы лидеров из материализованного представления
+// leaderboard_mv (миграция 017) с теми же фильтрами и порядком, что и GetLeaderboard.
+// Данные актуальны на момент последнего RefreshLeaderboardView.
+func (r *Repository) GetLeaderboardView(ctx context.Context, filter models.LeaderboardFilter, limit, offset int) (_ []*models.User, _ int, err error) {
+	ctx, done := r.withTimeout(ctx, &err)
+	defer done()
+
+	return r.leaderboard(ctx, "leaderboard_mv", filter, limit, offset)
+}
+
+// RefreshLeaderboardView пересчитывает материализованное представление leaderboard_mv.
+// Обновление выполняется CONCURRENTLY, поэтому чтение представления во время пересчета
+// не блокируется.
+func (r *Repository) RefreshLeaderboardView(ctx context.Context) (err error) {
+	ctx, done := r.withTimeout(ctx, &err)
+	defer done()
+
+	log := logger.FromContext(ctx, r.log)
+	log.Debug("Refreshing leaderboard view")
+
+	if _, err := r.db.ExecContext(ctx, "REFRESH MATERIALIZED VIEW CONCURRENTLY leaderboard_mv"); err != nil {
+		log.Error("Failed to refresh leaderboard view", zap.Error(err))
+		return fmt.Errorf("failed to refresh leaderboard view: %w", err)
+	}
+	return nil
+}
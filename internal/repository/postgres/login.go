@@ -0,0 +1,131 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/logger"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// GetCredentials возвращает ID и хеш пароля пользователя по имени без учета регистра
+func (r *Repository) GetCredentials(ctx context.Context, username string) (_ uuid.UUID, _ string, err error) {
+	ctx, done := r.withTimeout(ctx, &err)
+	defer done()
+
+	log := logger.FromContext(ctx, r.log)
+	log.Debug("Getting credentials", zap.String("username", username))
+
+	var id uuid.UUID
+	var hash string
+	err = r.db.QueryRowContext(ctx, "SELECT id, passw FROM users WHERE lower(username) = lower($1)", username).Scan(&id, &hash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Warn("User not found", zap.String("username", username))
+			return uuid.Nil, "", models.ErrUserNotFound
+		}
+		log.Error("Failed to get credentials",
+			zap.String("username", username),
+			zap.Error(err))
+		return uuid.Nil, "", fmt.Errorf("failed to get credentials: %w", err)
+	}
+
+	return id, hash, nil
+}
+
+// RecordLogin фиксирует вход пользователя и обновляет серию ежедневных входов.
+// Сутки считаются по UTC: вход на следующий день после предыдущего продлевает серию
+// и начисляет бонус, повторный вход в тот же день ничего не меняет, пропуск дня сбрасывает серию.
+// Возвращает текущую длину серии и начисленный бонус.
+func (r *Repository) RecordLogin(ctx context.Context, userID uuid.UUID, now time.Time) (_ int, _ int, err error) {
+	ctx, done := r.withTimeout(ctx, &err)
+	defer done()
+
+	log := logger.FromContext(ctx, r.log)
+	log.Debug("Recording login", zap.String("user_id", userID.String()))
+
+	// Начало транзакции
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error("Failed to begin transaction", zap.Error(err))
+		return 0, 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Блокировка строки пользователя
+	var lastLoginAt sql.NullTime
+	var streak, points int
+	err = tx.QueryRowContext(ctx,
+		"SELECT last_login_at, streak_days, points FROM users WHERE id = $1 FOR UPDATE",
+		userID,
+	).Scan(&lastLoginAt, &streak, &points)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Warn("User not found", zap.String("user_id", userID.String()))
+			return 0, 0, models.ErrUserNotFound
+		}
+		log.Error("Failed to get login streak",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return 0, 0, fmt.Errorf("failed to get login streak: %w", err)
+	}
+
+	now = now.UTC()
+	bonus := 0
+	switch days := daysBetween(lastLoginAt, now); {
+	case days == 0:
+		// Повторный вход в тот же день
+	case days == 1:
+		streak++
+		bonus = r.opts.StreakBonus
+	default:
+		streak = 1
+	}
+
+	// Бонус не начисляется, если баланс выйдет за допустимые границы
+	if bonus > 0 && checkPointsRange(points, bonus) != nil {
+		log.Warn("Streak bonus skipped: points out of range", zap.String("user_id", userID.String()))
+		bonus = 0
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"UPDATE users SET last_login_at = $1, streak_days = $2, points = points + $3, updated_at = NOW() WHERE id = $4",
+		now, streak, bonus, userID,
+	)
+	if err != nil {
+		log.Error("Failed to update login streak",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return 0, 0, fmt.Errorf("failed to update login streak: %w", err)
+	}
+
+	// Фиксация транзакции
+	if err = tx.Commit(); err != nil {
+		log.Error("Failed to commit transaction", zap.Error(err))
+		return 0, 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.Debug("Login recorded",
+		zap.String("user_id", userID.String()),
+		zap.Int("streak_days", streak),
+		zap.Int("bonus", bonus))
+	return streak, bonus, nil
+}
+
+// daysBetween возвращает количество календарных суток UTC между последним входом и now;
+// -1, если вход выполняется впервые
+func daysBetween(last sql.NullTime, now time.Time) int {
+	if !last.Valid {
+		return -1
+	}
+	day := func(t time.Time) time.Time {
+		t = t.UTC()
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+	return int(day(now).Sub(day(last.Time)).Hours() / 24)
+}
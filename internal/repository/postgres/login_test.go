@@ -0,0 +1,38 @@
+package postgres
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestDaysBetween(t *testing.T) {
+	last := time.Date(2024, 1, 1, 23, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		last sql.NullTime
+		now  time.Time
+		want int
+	}{
+		{name: "first login", now: last, want: -1},
+		{name: "same day", last: sql.NullTime{Time: last, Valid: true}, now: last.Add(20 * time.Minute), want: 0},
+		{name: "next day after midnight", last: sql.NullTime{Time: last, Valid: true}, now: last.Add(time.Hour), want: 1},
+		{name: "day skipped", last: sql.NullTime{Time: last, Valid: true}, now: last.Add(48 * time.Hour), want: 2},
+		{
+			name: "days are counted in UTC",
+			last: sql.NullTime{Time: last, Valid: true},
+			// 02:00 по UTC+3 - еще 1 января по UTC
+			now:  time.Date(2024, 1, 2, 2, 0, 0, 0, time.FixedZone("UTC+3", 3*3600)),
+			want: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := daysBetween(tt.last, tt.now); got != tt.want {
+				t.Errorf("daysBetween() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
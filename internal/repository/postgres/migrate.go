@@ -0,0 +1,79 @@
+package postgres
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/golang-migrate/migrate/v4"
+	"go.uber.org/zap"
+)
+
+// migrationsSource путь к каталогу с файлами миграций
+const migrationsSource = "file://../migrations"
+
+// Migrator управляет версиями схемы базы данных
+type Migrator struct {
+	m   *migrate.Migrate
+	log *zap.Logger
+}
+
+// NewMigrator создает новый экземпляр Migrator для указанной базы данных
+func NewMigrator(connStr string, log *zap.Logger) (*Migrator, error) {
+	m, err := migrate.New(migrationsSource, connStr)
+	if err != nil {
+		return nil, fmt.Errorf("start migrations error %v", err)
+	}
+
+	return &Migrator{
+		m:   m,
+		log: log.Named("migrator"),
+	}, nil
+}
+
+// Up применяет все непримененные миграции
+func (m *Migrator) Up() error {
+	m.log.Info("Applying migrations")
+	if err := m.m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("migration up error: %v", err)
+	}
+	return nil
+}
+
+// Down откатывает все примененные миграции
+func (m *Migrator) Down() error {
+	m.log.Info("Reverting migrations")
+	if err := m.m.Down(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("migration down error: %v", err)
+	}
+	return nil
+}
+
+// Steps применяет (n > 0) или откатывает (n < 0) указанное количество миграций
+func (m *Migrator) Steps(n int) error {
+	m.log.Info("Migrating by steps", zap.Int("steps", n))
+	if err := m.m.Steps(n); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("migration steps error: %v", err)
+	}
+	return nil
+}
+
+// Version возвращает текущую версию схемы и признак незавершенной миграции
+func (m *Migrator) Version() (uint, bool, error) {
+	version, dirty, err := m.m.Version()
+	if err != nil {
+		if errors.Is(err, migrate.ErrNilVersion) {
+			return 0, false, nil
+		}
+		return 0, false, fmt.Errorf("migration version error: %v", err)
+	}
+	return version, dirty, nil
+}
+
+// Close освобождает ресурсы мигратора
+func (m *Migrator) Close() error {
+	srcErr, dbErr := m.m.Close()
+	if srcErr != nil {
+		return srcErr
+	}
+	return dbErr
+}
@@ -0,0 +1,152 @@
+//go:build integration
+
+package postgres
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// latestMigration возвращает номер последней миграции в каталоге migrationsSource
+func latestMigration(t *testing.T) uint {
+	t.Helper()
+
+	files, err := filepath.Glob(filepath.Join(strings.TrimPrefix(migrationsSource, "file://"), "*.up.sql"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("list migrations: %v (%d files)", err, len(files))
+	}
+	var latest uint
+	for _, file := range files {
+		prefix, _, _ := strings.Cut(filepath.Base(file), "_")
+		n, err := strconv.ParseUint(prefix, 10, 32)
+		if err != nil {
+			t.Fatalf("parse migration number of %s: %v", file, err)
+		}
+		latest = max(latest, uint(n))
+	}
+	return latest
+}
+
+// tableExists проверяет наличие таблицы в схеме по умолчанию
+func tableExists(t *testing.T, db *sql.DB, table string) bool {
+	t.Helper()
+
+	var exists bool
+	if err := db.QueryRow("SELECT to_regclass($1) IS NOT NULL", table).Scan(&exists); err != nil {
+		t.Fatalf("check table %s: %v", table, err)
+	}
+	return exists
+}
+
+func TestIntegrationMigrator(t *testing.T) {
+	dbname := createTestDatabase(t)
+	db, err := sql.Open("postgres", testConnString(dbname))
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	defer db.Close()
+
+	m, err := NewMigrator(testConnString(dbname), zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewMigrator() error = %v", err)
+	}
+	defer m.Close()
+
+	assertVersion := func(want uint) {
+		t.Helper()
+
+		version, dirty, err := m.Version()
+		if err != nil {
+			t.Fatalf("Version() error = %v", err)
+		}
+		if version != want || dirty {
+			t.Fatalf("Version() = %d (dirty: %t), want %d (dirty: false)", version, dirty, want)
+		}
+	}
+	latest := latestMigration(t)
+
+	// Пустая база без примененных миграций
+	assertVersion(0)
+
+	if err := m.Up(); err != nil {
+		t.Fatalf("Up() error = %v", err)
+	}
+	assertVersion(latest)
+	if !tableExists(t, db, "users") || !tableExists(t, db, "tasks") {
+		t.Fatal("tables are missing after Up()")
+	}
+
+	// Повторный Up без новых миграций не считается ошибкой
+	if err := m.Up(); err != nil {
+		t.Fatalf("Up() without changes error = %v", err)
+	}
+
+	if err := m.Steps(-1); err != nil {
+		t.Fatalf("Steps(-1) error = %v", err)
+	}
+	assertVersion(latest - 1)
+	if !tableExists(t, db, "users") {
+		t.Error("Steps(-1) reverted more than the last migration")
+	}
+
+	if err := m.Steps(1); err != nil {
+		t.Fatalf("Steps(1) error = %v", err)
+	}
+	assertVersion(latest)
+
+	if err := m.Down(); err != nil {
+		t.Fatalf("Down() error = %v", err)
+	}
+	assertVersion(0)
+	if tableExists(t, db, "users") {
+		t.Error("users table exists after Down()")
+	}
+}
+
+func TestIntegrationReferralCodeBackfill(t *testing.T) {
+	const users = 500
+
+	dbname := createTestDatabase(t)
+	db, err := sql.Open("postgres", testConnString(dbname))
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	defer db.Close()
+
+	m, err := NewMigrator(testConnString(dbname), zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewMigrator() error = %v", err)
+	}
+	defer m.Close()
+
+	// Схема до появления реферальных кодов
+	if err := m.Steps(7); err != nil {
+		t.Fatalf("Steps(7) error = %v", err)
+	}
+	if _, err := db.Exec(`
+		INSERT INTO users (username, passw)
+		SELECT 'user_' || n, 'hash' FROM generate_series(1, $1) AS n
+	`, users); err != nil {
+		t.Fatalf("insert users: %v", err)
+	}
+
+	if err := m.Steps(1); err != nil {
+		t.Fatalf("Steps(1) error = %v", err)
+	}
+
+	var total, distinct, valid int
+	if err := db.QueryRow(`
+		SELECT COUNT(*), COUNT(DISTINCT referral_code), COUNT(*) FILTER (WHERE referral_code ~ '^[A-Z2-7]{8}$')
+		FROM users
+	`).Scan(&total, &distinct, &valid); err != nil {
+		t.Fatalf("query referral codes: %v", err)
+	}
+	if total != users || distinct != users || valid != users {
+		t.Errorf("backfilled %d users with %d distinct and %d valid codes, want %d of each", total, distinct, valid, users)
+	}
+}
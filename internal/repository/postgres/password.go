@@ -0,0 +1,169 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/logger"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// GetPasswordHash возвращает текущий хеш пароля пользователя
+func (r *Repository) GetPasswordHash(ctx context.Context, userID uuid.UUID) (_ string, err error) {
+	ctx, done := r.withTimeout(ctx, &err)
+	defer done()
+
+	log := logger.FromContext(ctx, r.log)
+	log.Debug("Getting password hash", zap.String("user_id", userID.String()))
+
+	var hash string
+	err = r.db.QueryRowContext(ctx, "SELECT passw FROM users WHERE id = $1", userID).Scan(&hash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Warn("User not found", zap.String("user_id", userID.String()))
+			return "", models.ErrUserNotFound
+		}
+		log.Error("Failed to get password hash",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return "", fmt.Errorf("failed to get password hash: %w", err)
+	}
+
+	return hash, nil
+}
+
+// GetPasswordHistory возвращает последние limit предыдущих хешей пароля пользователя
+func (r *Repository) GetPasswordHistory(ctx context.Context, userID uuid.UUID, limit int) (_ []string, err error) {
+	ctx, done := r.withTimeout(ctx, &err)
+	defer done()
+
+	log := logger.FromContext(ctx, r.log)
+	log.Debug("Getting password history",
+		zap.String("user_id", userID.String()),
+		zap.Int("limit", limit))
+
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT passw FROM password_history WHERE user_id = $1 ORDER BY created_at DESC, id DESC LIMIT $2",
+		userID, limit,
+	)
+	if err != nil {
+		log.Error("Failed to query password history",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, fmt.Errorf("failed to query password history: %w", err)
+	}
+	defer rows.Close()
+
+	hashes := make([]string, 0)
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			log.Error("Failed to scan password history", zap.Error(err))
+			return nil, fmt.Errorf("failed to scan password history: %w", err)
+		}
+		hashes = append(hashes, hash)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Error("Error iterating rows", zap.Error(err))
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return hashes, nil
+}
+
+// UpdatePassword заменяет хеш пароля пользователя, сохраняя предыдущий в истории.
+// В истории остаются только historyLimit последних хешей; при historyLimit <= 0 история не ведется.
+func (r *Repository) UpdatePassword(ctx context.Context, userID uuid.UUID, newHash string, historyLimit int) (err error) {
+	ctx, done := r.withTimeout(ctx, &err)
+	defer done()
+
+	log := logger.FromContext(ctx, r.log)
+	log.Info("Updating password", zap.String("user_id", userID.String()))
+
+	// Начало транзакции
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error("Failed to begin transaction", zap.Error(err))
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := updatePassword(ctx, tx, log, userID, newHash, historyLimit); err != nil {
+		return err
+	}
+
+	// Фиксация транзакции
+	if err = tx.Commit(); err != nil {
+		log.Error("Failed to commit transaction", zap.Error(err))
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.Info("Password updated successfully", zap.String("user_id", userID.String()))
+	return nil
+}
+
+// updatePassword заменяет хеш пароля в рамках транзакции tx, см. UpdatePassword
+func updatePassword(ctx context.Context, tx *sql.Tx, log *zap.Logger, userID uuid.UUID, newHash string, historyLimit int) error {
+	// Блокировка строки пользователя и получение текущего хеша
+	var oldHash string
+	err := tx.QueryRowContext(ctx, "SELECT passw FROM users WHERE id = $1 FOR UPDATE", userID).Scan(&oldHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Warn("User not found", zap.String("user_id", userID.String()))
+			return models.ErrUserNotFound
+		}
+		log.Error("Failed to get current password",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return fmt.Errorf("failed to get current password: %w", err)
+	}
+
+	if historyLimit > 0 {
+		// Сохранение предыдущего хеша в истории
+		_, err = tx.ExecContext(ctx,
+			"INSERT INTO password_history (user_id, passw) VALUES ($1, $2)",
+			userID, oldHash,
+		)
+		if err != nil {
+			log.Error("Failed to insert password history",
+				zap.String("user_id", userID.String()),
+				zap.Error(err))
+			return fmt.Errorf("failed to insert password history: %w", err)
+		}
+
+		// Удаление записей сверх лимита
+		_, err = tx.ExecContext(ctx, `
+			DELETE FROM password_history
+			WHERE user_id = $1 AND id NOT IN (
+				SELECT id FROM password_history
+				WHERE user_id = $1
+				ORDER BY created_at DESC, id DESC
+				LIMIT $2
+			)`,
+			userID, historyLimit,
+		)
+		if err != nil {
+			log.Error("Failed to rotate password history",
+				zap.String("user_id", userID.String()),
+				zap.Error(err))
+			return fmt.Errorf("failed to rotate password history: %w", err)
+		}
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"UPDATE users SET passw = $1, updated_at = NOW() WHERE id = $2",
+		newHash, userID,
+	)
+	if err != nil {
+		log.Error("Failed to update password",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+	return nil
+}
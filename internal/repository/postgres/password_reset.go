@@ -0,0 +1,147 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/logger"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// GetUserByLogin возвращает пользователя по имени или адресу электронной почты без учета регистра.
+// Заполняются только ID, Username и Email.
+func (r *Repository) GetUserByLogin(ctx context.Context, login string) (_ *models.User, err error) {
+	ctx, done := r.withTimeout(ctx, &err)
+	defer done()
+
+	log := logger.FromContext(ctx, r.log)
+	log.Debug("Getting user by login")
+
+	var user models.User
+	err = r.db.QueryRowContext(ctx, `
+		SELECT id, username, email
+		FROM users
+		WHERE lower(username) = lower($1) OR lower(email) = lower($1)
+		ORDER BY lower(username) = lower($1) DESC
+		LIMIT 1
+	`, login).Scan(&user.ID, &user.Username, &user.Email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrUserNotFound
+		}
+		log.Error("Failed to get user by login", zap.Error(err))
+		return nil, fmt.Errorf("failed to get user by login: %w", err)
+	}
+
+	return &user, nil
+}
+
+// CreatePasswordResetToken сохраняет хеш токена сброса пароля, действительного до expiresAt
+func (r *Repository) CreatePasswordResetToken(ctx context.Context, userID uuid.UUID, tokenHash string, expiresAt time.Time) (err error) {
+	ctx, done := r.withTimeout(ctx, &err)
+	defer done()
+
+	log := logger.FromContext(ctx, r.log)
+	log.Info("Creating password reset token", zap.String("user_id", userID.String()))
+
+	_, err = r.db.ExecContext(ctx,
+		"INSERT INTO password_reset_tokens (token_hash, user_id, expires_at) VALUES ($1, $2, $3)",
+		tokenHash, userID, expiresAt,
+	)
+	if err != nil {
+		log.Error("Failed to create password reset token",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return fmt.Errorf("failed to create password reset token: %w", err)
+	}
+	return nil
+}
+
+// GetPasswordResetToken возвращает пользователя, которому выдан неиспользованный
+// и не истекший к моменту now токен. Иначе возвращает models.ErrInvalidResetToken.
+func (r *Repository) GetPasswordResetToken(ctx context.Context, tokenHash string, now time.Time) (_ uuid.UUID, err error) {
+	ctx, done := r.withTimeout(ctx, &err)
+	defer done()
+
+	log := logger.FromContext(ctx, r.log)
+
+	var userID uuid.UUID
+	err = r.db.QueryRowContext(ctx, `
+		SELECT user_id FROM password_reset_tokens
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > $2
+	`, tokenHash, now).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return uuid.Nil, models.ErrInvalidResetToken
+		}
+		log.Error("Failed to get password reset token", zap.Error(err))
+		return uuid.Nil, fmt.Errorf("failed to get password reset token: %w", err)
+	}
+
+	return userID, nil
+}
+
+// ResetPassword в одной транзакции помечает токен использованным и заменяет пароль пользователя
+// (с сохранением истории, как UpdatePassword). Остальные неиспользованные токены пользователя
+// также аннулируются. Использованный, истекший или неизвестный токен отклоняется с
+// models.ErrInvalidResetToken, поэтому токен срабатывает не более одного раза.
+func (r *Repository) ResetPassword(ctx context.Context, tokenHash, newHash string, historyLimit int, now time.Time) (_ uuid.UUID, err error) {
+	ctx, done := r.withTimeout(ctx, &err)
+	defer done()
+
+	log := logger.FromContext(ctx, r.log)
+	log.Info("Resetting password")
+
+	// Начало транзакции
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error("Failed to begin transaction", zap.Error(err))
+		return uuid.Nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Условное обновление защищает от одновременного использования одного токена
+	var userID uuid.UUID
+	err = tx.QueryRowContext(ctx, `
+		UPDATE password_reset_tokens SET used_at = $2
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > $2
+		RETURNING user_id
+	`, tokenHash, now).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Warn("Password reset token is invalid, expired or already used")
+			return uuid.Nil, models.ErrInvalidResetToken
+		}
+		log.Error("Failed to consume password reset token", zap.Error(err))
+		return uuid.Nil, fmt.Errorf("failed to consume password reset token: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"UPDATE password_reset_tokens SET used_at = $2 WHERE user_id = $1 AND used_at IS NULL",
+		userID, now,
+	)
+	if err != nil {
+		log.Error("Failed to invalidate password reset tokens",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return uuid.Nil, fmt.Errorf("failed to invalidate password reset tokens: %w", err)
+	}
+
+	if err := updatePassword(ctx, tx, log, userID, newHash, historyLimit); err != nil {
+		return uuid.Nil, err
+	}
+
+	// Фиксация транзакции
+	if err = tx.Commit(); err != nil {
+		log.Error("Failed to commit transaction", zap.Error(err))
+		return uuid.Nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.Info("Password reset successfully", zap.String("user_id", userID.String()))
+	return userID, nil
+}
@@ -0,0 +1,124 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/logger"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// recordPointEntries записывает начисления points[i] баллов пользователям userIDs[i] из источника
+// source (models.PointSource*), которые сгорят через Options.PointsTTL. Без PointsTTL начисления
+// не записываются и не сгорают; нулевые начисления пропускаются.
+func (r *Repository) recordPointEntries(ctx context.Context, tx *sql.Tx, source string, userIDs []uuid.UUID, points []int) error {
+	if r.opts.PointsTTL <= 0 || len(userIDs) == 0 {
+		return nil
+	}
+
+	amounts := make([]int64, len(points))
+	for i, p := range points {
+		amounts[i] = int64(p)
+	}
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO point_entries (user_id, source, points, remaining, expires_at)
+		SELECT e.user_id, $1::varchar, e.points, e.points, $4::timestamptz
+		FROM unnest($2::uuid[], $3::int[]) AS e(user_id, points)
+		WHERE e.points > 0
+	`, source, pq.Array(userIDs), pq.Array(amounts), time.Now().Add(r.opts.PointsTTL))
+	return err
+}
+
+// consumePointEntries отмечает amount списанных с баланса пользователя баллов как потраченные:
+// непотраченные остатки начислений уменьшаются начиная с тех, что сгорают раньше, чтобы истекшее
+// начисление не списывалось с баланса повторно. Баллы сверх остатков (начисления без срока
+// действия) не учитываются. Вызывается в транзакции, заблокировавшей строку пользователя.
+func consumePointEntries(ctx context.Context, tx *sql.Tx, userID uuid.UUID, amount int) error {
+	if amount <= 0 {
+		return nil
+	}
+
+	_, err := tx.ExecContext(ctx, `
+		WITH ordered AS (
+			SELECT id, remaining, SUM(remaining) OVER (ORDER BY expires_at, id) AS running
+			FROM point_entries
+			WHERE user_id = $1 AND remaining > 0 AND expires_at > NOW()
+		)
+		UPDATE point_entries e
+		SET remaining = GREATEST(LEAST(o.remaining, o.running - $2), 0)
+		FROM ordered o
+		WHERE e.id = o.id AND o.running - o.remaining < $2
+	`, userID, amount)
+	return err
+}
+
+// ExpirePoints списывает с балансов непотраченные остатки начислений, срок которых истек
+// (баланс не опускается ниже нуля), и возвращает количество пользователей, чей баланс уменьшился.
+// Строки пользователей блокируются до остатков так же, как при списании баллов, чтобы
+// одновременные операции не заблокировали друг друга.
+func (r *Repository) ExpirePoints(ctx context.Context) (_ int, err error) {
+	ctx, done := r.withTimeout(ctx, &err)
+	defer done()
+
+	log := logger.FromContext(ctx, r.log)
+	log.Debug("Expiring points")
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error("Failed to begin transaction", zap.Error(err))
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Общая граница для обоих запросов: начисления, сгоревшие между ними, списываются
+	// при следующем запуске, и блокируются только строки уже заблокированных пользователей
+	cutoff := time.Now()
+	_, err = tx.ExecContext(ctx, `
+		SELECT id FROM users
+		WHERE id IN (SELECT user_id FROM point_entries WHERE remaining > 0 AND expires_at <= $1)
+		ORDER BY id
+		FOR UPDATE
+	`, cutoff)
+	if err != nil {
+		log.Error("Failed to lock users with expired points", zap.Error(err))
+		return 0, fmt.Errorf("failed to lock users with expired points: %w", err)
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		WITH expired AS (
+			UPDATE point_entries e
+			SET remaining = 0
+			FROM (
+				SELECT id, remaining FROM point_entries
+				WHERE remaining > 0 AND expires_at <= $1
+			) due
+			WHERE e.id = due.id
+			RETURNING e.user_id, due.remaining
+		), totals AS (
+			SELECT user_id, SUM(remaining) AS points FROM expired GROUP BY user_id
+		)
+		UPDATE users u
+		SET points = u.points - LEAST(u.points, t.points), updated_at = NOW()
+		FROM totals t
+		WHERE u.id = t.user_id AND u.points > 0
+	`, cutoff)
+	if err != nil {
+		log.Error("Failed to expire points", zap.Error(err))
+		return 0, fmt.Errorf("failed to expire points: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		log.Error("Failed to get affected rows", zap.Error(err))
+		return 0, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Error("Failed to commit transaction", zap.Error(err))
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return int(affected), nil
+}
@@ -0,0 +1,37 @@
+package postgres
+
+import (
+	"errors"
+	"math"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"github.com/lib/pq"
+)
+
+// maxPoints максимальный баланс, помещающийся в столбец INTEGER
+const maxPoints = math.MaxInt32
+
+// Коды ошибок PostgreSQL, означающие выход баланса за допустимые границы
+const (
+	pqNumericValueOutOfRange = "22003"
+	pqCheckViolation         = "23514"
+)
+
+// checkPointsRange проверяет, что баланс после изменения на delta останется в допустимых границах
+func checkPointsRange(current, delta int) error {
+	next := int64(current) + int64(delta)
+	if next < 0 || next > maxPoints {
+		return models.ErrPointsOutOfRange
+	}
+	return nil
+}
+
+// isPointsRangeViolation определяет, что ошибка БД вызвана переполнением или отрицательным балансом
+func isPointsRangeViolation(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	return pqErr.Code == pqNumericValueOutOfRange ||
+		(pqErr.Code == pqCheckViolation && pqErr.Constraint == "non_negative_points")
+}
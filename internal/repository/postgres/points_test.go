@@ -0,0 +1,58 @@
+package postgres
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"github.com/lib/pq"
+)
+
+func TestCheckPointsRange(t *testing.T) {
+	tests := []struct {
+		name    string
+		current int
+		delta   int
+		wantErr bool
+	}{
+		{name: "increase", current: 10, delta: 5},
+		{name: "down to zero", current: 10, delta: -10},
+		{name: "up to max", current: math.MaxInt32 - 1, delta: 1},
+		{name: "negative", current: 10, delta: -11, wantErr: true},
+		{name: "overflow", current: math.MaxInt32, delta: 1, wantErr: true},
+		{name: "overflow of int", current: 1, delta: math.MaxInt, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkPointsRange(tt.current, tt.delta)
+			if tt.wantErr != errors.Is(err, models.ErrPointsOutOfRange) {
+				t.Errorf("checkPointsRange(%d, %d) error = %v, want out of range %t", tt.current, tt.delta, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIsPointsRangeViolation(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "integer out of range", err: &pq.Error{Code: pqNumericValueOutOfRange}, want: true},
+		{name: "non-negative check", err: fmt.Errorf("update: %w", &pq.Error{Code: pqCheckViolation, Constraint: "non_negative_points"}), want: true},
+		{name: "other check", err: &pq.Error{Code: pqCheckViolation, Constraint: "other"}},
+		{name: "unique violation", err: &pq.Error{Code: "23505"}},
+		{name: "not a database error", err: errors.New("boom")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isPointsRangeViolation(tt.err); got != tt.want {
+				t.Errorf("isPointsRangeViolation(%v) = %t, want %t", tt.err, got, tt.want)
+			}
+		})
+	}
+}
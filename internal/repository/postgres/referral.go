@@ -0,0 +1,391 @@
+package postgres
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/logger"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// referralCodeConstraint имя ограничения уникальности реферального кода
+const referralCodeConstraint = "users_referral_code_key"
+
+// referralCodeAttempts количество попыток генерации кода при коллизии
+const referralCodeAttempts = 5
+
+// newReferralCode генерирует реферальный код; в тестах подменяется для проверки коллизий
+var newReferralCode = randomReferralCode
+
+// randomReferralCode генерирует реферальный код из 8 символов base32 (40 случайных бит)
+func randomReferralCode() (string, error) {
+	b := make([]byte, 5)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate referral code: %w", err)
+	}
+	return base32.StdEncoding.EncodeToString(b), nil
+}
+
+// isReferralCodeConflict проверяет, вызвана ли ошибка совпадением реферального кода
+func isReferralCodeConflict(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == pqUniqueViolation && pqErr.Constraint == referralCodeConstraint
+}
+
+// GetUserByReferralCode возвращает пользователя по реферальному коду (без учета регистра)
+// или models.ErrUserNotFound, если код никому не принадлежит
+func (r *Repository) GetUserByReferralCode(ctx context.Context, code string) (_ *models.User, err error) {
+	ctx, done := r.withTimeout(ctx, &err)
+	defer done()
+
+	log := logger.FromContext(ctx, r.log)
+	code = strings.ToUpper(strings.TrimSpace(code))
+	log.Debug("Getting user by referral code", zap.String("referral_code", code))
+
+	var user models.User
+	err = r.db.QueryRowContext(ctx,
+		"SELECT id, username, points, referral_code, created_at, updated_at FROM users WHERE referral_code = $1",
+		code,
+	).Scan(&user.ID, &user.Username, &user.Points, &user.ReferralCode, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Debug("Referral code not found", zap.String("referral_code", code))
+			return nil, models.ErrUserNotFound
+		}
+		log.Error("Failed to get user by referral code",
+			zap.String("referral_code", code),
+			zap.Error(err))
+		return nil, fmt.Errorf("failed to get user by referral code: %w", err)
+	}
+
+	return &user, nil
+}
+
+// RegenerateReferralCode выдает пользователю новый реферальный код; прежний код сразу перестает
+// находить пользователя. При совпадении сгенерированного кода с существующим генерация повторяется.
+// Возвращает models.ErrUserNotFound, если пользователь не найден.
+func (r *Repository) RegenerateReferralCode(ctx context.Context, userID uuid.UUID) (_ string, err error) {
+	ctx, done := r.withTimeout(ctx, &err)
+	defer done()
+
+	log := logger.FromContext(ctx, r.log)
+	log.Info("Regenerating referral code", zap.String("user_id", userID.String()))
+
+	for attempt := 1; ; attempt++ {
+		code, err := newReferralCode()
+		if err != nil {
+			log.Error("Failed to regenerate referral code", zap.Error(err))
+			return "", err
+		}
+
+		res, err := r.db.ExecContext(ctx,
+			"UPDATE users SET referral_code = $1, updated_at = NOW() WHERE id = $2",
+			code, userID,
+		)
+		if err != nil {
+			if isReferralCodeConflict(err) && attempt < referralCodeAttempts {
+				log.Warn("Referral code collision, retrying", zap.Int("attempt", attempt))
+				continue
+			}
+			log.Error("Failed to regenerate referral code",
+				zap.String("user_id", userID.String()),
+				zap.Error(err))
+			return "", fmt.Errorf("failed to regenerate referral code: %w", err)
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil {
+			log.Error("Failed to get affected rows", zap.Error(err))
+			return "", fmt.Errorf("failed to get affected rows: %w", err)
+		}
+		if rows == 0 {
+			log.Warn("User not found", zap.String("user_id", userID.String()))
+			return "", models.ErrUserNotFound
+		}
+		return code, nil
+	}
+}
+
+// GetReferrerChain возвращает цепочку рефереров пользователя снизу вверх: прямой реферер
+// (уровень 1), его реферер и так далее, не более maxDepth уровней. Повторное появление
+// пользователя в цепочке (цикл) прерывает обход.
+func (r *Repository) GetReferrerChain(ctx context.Context, userID uuid.UUID, maxDepth int) (_ []*models.ReferrerChainEntry, err error) {
+	ctx, done := r.withTimeout(ctx, &err)
+	defer done()
+
+	log := logger.FromContext(ctx, r.log)
+	log.Debug("Getting referrer chain",
+		zap.String("user_id", userID.String()),
+		zap.Int("max_depth", maxDepth))
+
+	query := `
+		WITH RECURSIVE chain AS (
+			SELECT u.id, u.username, u.points, u.referrer_id, 1 AS level, ARRAY[$1::uuid, u.id] AS path
+			FROM users u
+			WHERE u.id = (SELECT referrer_id FROM users WHERE id = $1)
+			UNION ALL
+			SELECT u.id, u.username, u.points, u.referrer_id, c.level + 1, c.path || u.id
+			FROM users u
+			JOIN chain c ON u.id = c.referrer_id
+			WHERE c.level < $2 AND NOT u.id = ANY(c.path)
+		)
+		SELECT id, username, points, level
+		FROM chain
+		ORDER BY level
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID, maxDepth)
+	if err != nil {
+		log.Error("Failed to query referrer chain",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, fmt.Errorf("failed to query referrer chain: %w", err)
+	}
+	defer rows.Close()
+
+	var chain []*models.ReferrerChainEntry
+	for rows.Next() {
+		var entry models.ReferrerChainEntry
+		if err := rows.Scan(&entry.ID, &entry.Username, &entry.Points, &entry.Level); err != nil {
+			log.Error("Failed to scan referrer chain entry", zap.Error(err))
+			return nil, fmt.Errorf("failed to scan referrer chain entry: %w", err)
+		}
+		chain = append(chain, &entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Error("Error iterating referrer chain rows", zap.Error(err))
+		return nil, fmt.Errorf("error iterating referrer chain rows: %w", err)
+	}
+
+	return chain, nil
+}
+
+// creditReferrerChain начисляет бонусы цепочке рефереров в рамках транзакции tx:
+// реферер (уровень 1) получает schedule[0], его реферер - schedule[1] и так далее.
+// Пользователь userID и повторно встреченные в цепочке пользователи бонусов не получают.
+// Возвращает начисленные бонусы по уровням цепочки.
+func creditReferrerChain(ctx context.Context, tx *sql.Tx, userID, referrerID uuid.UUID, schedule []int) ([]models.ReferralCredit, error) {
+	if len(schedule) == 0 {
+		return nil, nil
+	}
+
+	bonuses := make([]int64, len(schedule))
+	for i, bonus := range schedule {
+		bonuses[i] = int64(bonus)
+	}
+
+	query := `
+		WITH RECURSIVE chain AS (
+			SELECT id, referrer_id, 1 AS level, ARRAY[$1::uuid, id] AS path
+			FROM users
+			WHERE id = $2
+			UNION ALL
+			SELECT u.id, u.referrer_id, c.level + 1, c.path || u.id
+			FROM users u
+			JOIN chain c ON u.id = c.referrer_id
+			WHERE c.level < $3 AND NOT u.id = ANY(c.path)
+		)
+		UPDATE users u
+		SET points = u.points + s.bonus, updated_at = NOW()
+		FROM chain c
+		JOIN unnest($4::int[]) WITH ORDINALITY AS s(bonus, level) ON s.level = c.level
+		WHERE u.id = c.id AND s.bonus <> 0
+		RETURNING u.id, c.level, s.bonus, u.points
+	`
+
+	rows, err := tx.QueryContext(ctx, query, userID, referrerID, len(schedule), pq.Array(bonuses))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var credits []models.ReferralCredit
+	for rows.Next() {
+		var credit models.ReferralCredit
+		if err := rows.Scan(&credit.UserID, &credit.Level, &credit.Points, &credit.Balance); err != nil {
+			return nil, fmt.Errorf("failed to scan referral credit: %w", err)
+		}
+		credits = append(credits, credit)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return credits, nil
+}
+
+// recordReferralCredits сохраняет в рамках транзакции tx бонусы, начисленные цепочке рефереров
+// за приглашение userID, чтобы RemoveReferrer мог их отменить. Записи, оставшиеся от прежней
+// связи (реферер был сброшен при удалении его учетной записи), заменяются.
+func recordReferralCredits(ctx context.Context, tx *sql.Tx, userID uuid.UUID, credits []models.ReferralCredit) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM referral_credits WHERE user_id = $1", userID); err != nil {
+		return err
+	}
+	if len(credits) == 0 {
+		return nil
+	}
+
+	referrers := make([]uuid.UUID, len(credits))
+	levels := make([]int64, len(credits))
+	points := make([]int64, len(credits))
+	for i, credit := range credits {
+		referrers[i] = credit.UserID
+		levels[i] = int64(credit.Level)
+		points[i] = int64(credit.Points)
+	}
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO referral_credits (user_id, referrer_id, level, points)
+		SELECT $1, referrer_id, level, points
+		FROM unnest($2::uuid[], $3::int[], $4::int[]) AS c(referrer_id, level, points)
+	`, userID, pq.Array(referrers), pq.Array(levels), pq.Array(points))
+	return err
+}
+
+// RemoveReferrer сбрасывает реферера пользователя и в той же транзакции отменяет бонусы,
+// начисленные цепочке рефереров при его добавлении; баланс реферера не опускается ниже нуля.
+// Удаленный реферер запоминается, и AddReferrer больше не принимает его для этого пользователя.
+// Возвращает прежнего реферера и отмененные бонусы (Points отрицательны) или
+// models.ErrReferrerNotSet, если реферер не указан.
+func (r *Repository) RemoveReferrer(ctx context.Context, userID uuid.UUID) (_ uuid.UUID, _ []models.ReferralCredit, err error) {
+	ctx, done := r.withTimeout(ctx, &err)
+	defer done()
+
+	log := logger.FromContext(ctx, r.log)
+	log.Info("Removing referrer", zap.String("user_id", userID.String()))
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error("Failed to begin transaction", zap.Error(err))
+		return uuid.Nil, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Строка пользователя блокируется первой, как и в AddReferrer
+	var referrerID uuid.NullUUID
+	err = tx.QueryRowContext(ctx, "SELECT referrer_id FROM users WHERE id = $1 FOR UPDATE", userID).Scan(&referrerID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Warn("User not found", zap.String("user_id", userID.String()))
+			return uuid.Nil, nil, models.ErrUserNotFound
+		}
+		log.Error("Failed to get user referrer",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return uuid.Nil, nil, fmt.Errorf("failed to get user referrer: %w", err)
+	}
+	if !referrerID.Valid {
+		log.Warn("User has no referrer", zap.String("user_id", userID.String()))
+		return uuid.Nil, nil, models.ErrReferrerNotSet
+	}
+
+	// Балансы получателей бонусов блокируются, чтобы отмена не опустила их ниже нуля
+	// при одновременном списании
+	rows, err := tx.QueryContext(ctx, `
+		SELECT c.referrer_id, c.level, c.points, u.points
+		FROM referral_credits c
+		JOIN users u ON u.id = c.referrer_id
+		WHERE c.user_id = $1
+		ORDER BY c.level
+		FOR UPDATE OF u
+	`, userID)
+	if err != nil {
+		log.Error("Failed to query referral credits",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return uuid.Nil, nil, fmt.Errorf("failed to query referral credits: %w", err)
+	}
+	var reversals []models.ReferralCredit
+	for rows.Next() {
+		var credit models.ReferralCredit
+		if err = rows.Scan(&credit.UserID, &credit.Level, &credit.Points, &credit.Balance); err != nil {
+			rows.Close()
+			log.Error("Failed to scan referral credit", zap.Error(err))
+			return uuid.Nil, nil, fmt.Errorf("failed to scan referral credit: %w", err)
+		}
+		reversals = append(reversals, credit)
+	}
+	if err = rows.Err(); err != nil {
+		rows.Close()
+		log.Error("Error iterating referral credit rows", zap.Error(err))
+		return uuid.Nil, nil, fmt.Errorf("error iterating referral credit rows: %w", err)
+	}
+	rows.Close()
+
+	for i := range reversals {
+		credit := &reversals[i]
+		// Списывается не больше текущего баланса
+		delta := -min(credit.Points, credit.Balance)
+		if delta == 0 {
+			credit.Points = 0
+			continue
+		}
+		if err = checkPointsRange(credit.Balance, delta); err != nil {
+			log.Warn("Referrer points out of range", zap.String("referrer_id", credit.UserID.String()))
+			return uuid.Nil, nil, err
+		}
+		_, err = tx.ExecContext(ctx,
+			"UPDATE users SET points = points + $1, updated_at = NOW() WHERE id = $2",
+			delta, credit.UserID,
+		)
+		if err != nil {
+			log.Error("Failed to reverse referral credit",
+				zap.String("referrer_id", credit.UserID.String()),
+				zap.Error(err))
+			return uuid.Nil, nil, fmt.Errorf("failed to reverse referral credit: %w", err)
+		}
+		if err = consumePointEntries(ctx, tx, credit.UserID, -delta); err != nil {
+			log.Error("Failed to consume point entries",
+				zap.String("referrer_id", credit.UserID.String()),
+				zap.Error(err))
+			return uuid.Nil, nil, fmt.Errorf("failed to consume point entries: %w", err)
+		}
+		credit.Points = delta
+		credit.Balance += delta
+	}
+
+	if _, err = tx.ExecContext(ctx, "DELETE FROM referral_credits WHERE user_id = $1", userID); err != nil {
+		log.Error("Failed to delete referral credits",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return uuid.Nil, nil, fmt.Errorf("failed to delete referral credits: %w", err)
+	}
+	_, err = tx.ExecContext(ctx,
+		"UPDATE users SET referrer_id = NULL, updated_at = NOW() WHERE id = $1",
+		userID,
+	)
+	if err != nil {
+		log.Error("Failed to clear user referrer",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return uuid.Nil, nil, fmt.Errorf("failed to clear user referrer: %w", err)
+	}
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO removed_referrers (user_id, referrer_id) VALUES ($1, $2) ON CONFLICT DO NOTHING",
+		userID, referrerID.UUID,
+	)
+	if err != nil {
+		log.Error("Failed to record removed referrer",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return uuid.Nil, nil, fmt.Errorf("failed to record removed referrer: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Error("Failed to commit transaction", zap.Error(err))
+		return uuid.Nil, nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.Info("Referrer removed",
+		zap.String("user_id", userID.String()),
+		zap.String("referrer_id", referrerID.UUID.String()),
+		zap.Int("reversed_credits", len(reversals)))
+	return referrerID.UUID, reversals, nil
+}
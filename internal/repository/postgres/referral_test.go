@@ -0,0 +1,47 @@
+package postgres
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestRandomReferralCode(t *testing.T) {
+	pattern := regexp.MustCompile(`^[A-Z2-7]{8}$`)
+
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		code, err := randomReferralCode()
+		if err != nil {
+			t.Fatalf("randomReferralCode() error = %v", err)
+		}
+		if !pattern.MatchString(code) {
+			t.Fatalf("randomReferralCode() = %q, want 8 base32 characters", code)
+		}
+		seen[code] = true
+	}
+	if len(seen) < 99 {
+		t.Errorf("randomReferralCode() produced %d distinct codes out of 100", len(seen))
+	}
+}
+
+func TestIsReferralCodeConflict(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "referral code", err: &pq.Error{Code: pqUniqueViolation, Constraint: referralCodeConstraint}, want: true},
+		{name: "username", err: &pq.Error{Code: pqUniqueViolation, Constraint: "users_username_key"}},
+		{name: "other error", err: &pq.Error{Code: "23514", Constraint: referralCodeConstraint}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isReferralCodeConflict(tt.err); got != tt.want {
+				t.Errorf("isReferralCodeConflict() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
@@ -8,24 +8,67 @@ import (
 	"time"
 
 	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/logger"
 	"github.com/google/uuid"
 	_ "github.com/lib/pq"
 	"go.uber.org/zap"
 
-	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
 )
 
+// Options содержит настраиваемые параметры репозитория
+type Options struct {
+	// ConsistentLeaderboard выполняет подсчет и выборку страницы лидеров в одной
+	// транзакции REPEATABLE READ, чтобы итог и страница соответствовали друг другу
+	ConsistentLeaderboard bool
+	// LeaderboardTiebreaker порядок пользователей с равным балансом:
+	// models.TiebreakerCreatedAt (по умолчанию) или models.TiebreakerUsername
+	LeaderboardTiebreaker string
+	// ReferralSchedule бонусные баллы по уровням цепочки рефереров:
+	// ReferralSchedule[0] - прямому рефереру, ReferralSchedule[1] - его рефереру и так далее
+	ReferralSchedule []int
+	// StreakBonus бонусные баллы за вход на следующий день после предыдущего
+	StreakBonus int
+	// QueryTimeout ограничивает время выполнения метода репозитория, если у контекста
+	// вызывающего нет срока; 0 отключает ограничение
+	QueryTimeout time.Duration
+	// PointsTTL срок действия баллов за задания и реферальных бонусов: начисления записываются
+	// в point_entries и списываются с баланса ExpirePoints после истечения срока (0 - не сгорают)
+	PointsTTL time.Duration
+}
+
 // Repository представляет слой доступа к данным PostgreSQL
 type Repository struct {
-	db  *sql.DB
-	log *zap.Logger
+	db   *sql.DB
+	opts Options
+	log  *zap.Logger
+}
+
+// querier общий интерфейс *sql.DB и *sql.Tx для выполнения запросов
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
 }
 
-// NewRepository создает новый экземпляр репозитория
-func NewRepository(user string, password string, host string, port string, dbname string, sslmode string, log *zap.Logger) (*Repository, error) {
-	connStr := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=%s", user, password, host, port, dbname, sslmode)
+// ConnString формирует строку подключения к PostgreSQL.
+// connect_timeout ограничивает установку соединения целиком: драйвер не прерывает
+// рукопожатие с сервером по отмене контекста, поэтому без него зависший сервер
+// задерживает старт дольше pingTimeout.
+func ConnString(user string, password string, host string, port string, dbname string, sslmode string) string {
+	return fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=%s&connect_timeout=%d",
+		user, password, host, port, dbname, sslmode, int(pingTimeout.Seconds()))
+}
+
+// pingTimeout время ожидания проверки соединения с БД при старте
+const pingTimeout = 5 * time.Second
+
+// NewRepository создает новый экземпляр репозитория.
+// Все методы репозитория выполняют запросы с контекстом вызывающего: отмена контекста
+// запроса (например, разрыв соединения клиентом) прерывает запрос к БД, а открытые
+// транзакции откатываются.
+func NewRepository(user string, password string, host string, port string, dbname string, sslmode string, opts Options, log *zap.Logger) (*Repository, error) {
+	connStr := ConnString(user, password, host, port, dbname, sslmode)
 
 	log.Info("Connecting to PostgreSQL database",
 		zap.String("dbname", dbname),
@@ -40,8 +83,11 @@ func NewRepository(user string, password string, host string, port string, dbnam
 
 	// Проверка соединения
 	log.Debug("Testing database connection")
-	if err := db.Ping(); err != nil {
+	ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
 		log.Error("Failed to ping database", zap.Error(err))
+		db.Close()
 		return nil, err
 	}
 
@@ -49,35 +95,27 @@ func NewRepository(user string, password string, host string, port string, dbnam
 
 	log.Info("Starting database migrations")
 
-	if err := migrations(connStr); err != nil {
+	if err := migrations(connStr, log); err != nil {
 		log.Error("Failed to run database migrations", zap.Error(err))
+		db.Close()
 		return nil, err
 	}
 
 	return &Repository{
-		db:  db,
-		log: log.Named("postgres_repository"),
+		db:   db,
+		opts: opts,
+		log:  log.Named("postgres_repository"),
 	}, nil
 }
 
-func migrations(connStr string) error {
-
-	m, err := migrate.New("file://../migrations", connStr)
-
+func migrations(connStr string, log *zap.Logger) error {
+	m, err := NewMigrator(connStr, log)
 	if err != nil {
-		return fmt.Errorf("start migrations error %v", err)
+		return err
 	}
+	defer m.Close()
 
-	if err := m.Up(); err != nil {
-		if err == migrate.ErrNoChange {
-			return nil
-		}
-
-		return fmt.Errorf("migration up error: %v", err)
-	}
-
-	return nil
-
+	return m.Up()
 }
 
 // Close закрывает соединение с базой данных
@@ -86,105 +124,224 @@ func (r *Repository) Close() error {
 	return r.db.Close()
 }
 
-// LoginUser регистрирует пользователя
-func (r *Repository) LoginUser(ctx context.Context, username string, password string) (*models.User, error) {
+// LoginUser регистрирует пользователя и выдает ему реферальный код; email необязателен.
+// При совпадении сгенерированного кода с существующим вставка повторяется с новым кодом.
+// Возвращает models.ErrUsernameTaken, если имя занято без учета регистра,
+// и models.ErrEmailTaken, если адрес уже используется.
+func (r *Repository) LoginUser(ctx context.Context, username string, password string, email *string) (_ *models.User, err error) {
+	ctx, done := r.withTimeout(ctx, &err)
+	defer done()
+
+	log := logger.FromContext(ctx, r.log)
 	query := `
-		INSERT INTO users (username, passw)
-		VALUES ($1, $2)
+		INSERT INTO users (username, passw, referral_code, email)
+		VALUES ($1, $2, $3, $4)
 	`
 	var user models.User
-	_, err := r.db.ExecContext(ctx, query, username, password)
+	for attempt := 1; ; attempt++ {
+		code, err := newReferralCode()
+		if err != nil {
+			log.Error("Failed to register user", zap.Error(err))
+			return nil, err
+		}
 
-	if err != nil {
-		r.log.Error("Failed to register user", zap.Error(err))
+		_, err = r.db.ExecContext(ctx, query, username, password, code, email)
+		if err == nil {
+			break
+		}
+		if isUsernameConflict(err) {
+			log.Warn("Username already taken", zap.String("username", username))
+			return nil, models.ErrUsernameTaken
+		}
+		if isEmailConflict(err) {
+			log.Warn("Email already taken", zap.String("username", username))
+			return nil, models.ErrEmailTaken
+		}
+		if isReferralCodeConflict(err) && attempt < referralCodeAttempts {
+			log.Warn("Referral code collision, retrying", zap.Int("attempt", attempt))
+			continue
+		}
+		if isUniqueViolation(err) && !isReferralCodeConflict(err) {
+			log.Warn("Username already taken", zap.String("username", username))
+			return nil, models.ErrUsernameTaken
+		}
+		log.Error("Failed to register user", zap.Error(err))
 		return nil, fmt.Errorf("failed to register user: %w", err)
 	}
-	res := r.db.QueryRowContext(ctx, "SELECT id, username, passw, created_at, updated_at FROM users WHERE username = $1", username)
-	err = res.Scan(&user.ID, &user.Username, &user.Password, &user.CreatedAt, &user.UpdatedAt)
+	res := r.db.QueryRowContext(ctx, "SELECT id, username, passw, role, referral_code, email, version, created_at, updated_at FROM users WHERE lower(username) = lower($1)", username)
+	var storedEmail sql.NullString
+	err = res.Scan(&user.ID, &user.Username, &user.Password, &user.Role, &user.ReferralCode, &storedEmail, &user.Version, &user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
-		r.log.Error("Failed to register user", zap.Error(err))
+		log.Error("Failed to register user", zap.Error(err))
 		return nil, fmt.Errorf("failed to register user: %w", err)
 	}
+	if storedEmail.Valid {
+		user.Email = &storedEmail.String
+	}
 
 	return &user, nil
 }
 
-// GetUserByID возвращает пользователя по ID
-func (r *Repository) GetUserByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
-	r.log.Debug("Getting user by ID", zap.String("user_id", id.String()))
+// GetUserByID возвращает пользователя по ID или models.ErrUserNotFound, если пользователь не найден
+func (r *Repository) GetUserByID(ctx context.Context, id uuid.UUID) (_ *models.User, err error) {
+	ctx, done := r.withTimeout(ctx, &err)
+	defer done()
+
+	log := logger.FromContext(ctx, r.log)
+	log.Debug("Getting user by ID", zap.String("user_id", id.String()))
 
 	query := `
-		SELECT id, username, points, referrer_id, created_at, updated_at
+		SELECT id, username, points, referrer_id, role, referral_code, email, email_verified_at,
+			last_login_at, streak_days, version, created_at, updated_at
 		FROM users
 		WHERE id = $1
 	`
 
 	var user models.User
 	var referrerID sql.NullString
+	var email sql.NullString
+	var emailVerifiedAt sql.NullTime
+	var lastLoginAt sql.NullTime
 
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
+	err = r.db.QueryRowContext(ctx, query, id).Scan(
 		&user.ID,
 		&user.Username,
-		&user.Password,
 		&user.Points,
 		&referrerID,
+		&user.Role,
+		&user.ReferralCode,
+		&email,
+		&emailVerifiedAt,
+		&lastLoginAt,
+		&user.StreakDays,
+		&user.Version,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			r.log.Warn("User not found", zap.String("user_id", id.String()))
-			return nil, nil
+			log.Warn("User not found", zap.String("user_id", id.String()))
+			return nil, models.ErrUserNotFound
 		}
-		r.log.Error("Failed to get user",
+		log.Error("Failed to get user",
 			zap.String("user_id", id.String()),
 			zap.Error(err))
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
 
+	if email.Valid {
+		user.Email = &email.String
+	}
+	if emailVerifiedAt.Valid {
+		user.EmailVerifiedAt = &emailVerifiedAt.Time
+	}
+	if lastLoginAt.Valid {
+		user.LastLoginAt = &lastLoginAt.Time
+	}
+
 	if referrerID.Valid {
 		refID, err := uuid.Parse(referrerID.String)
 		if err == nil {
 			user.ReferrerID = &refID
-			r.log.Debug("User has referrer",
+			log.Debug("User has referrer",
 				zap.String("user_id", id.String()),
 				zap.String("referrer_id", refID.String()))
 		} else {
-			r.log.Warn("Invalid referrer ID format",
+			log.Warn("Invalid referrer ID format",
 				zap.String("user_id", id.String()),
 				zap.String("raw_referrer_id", referrerID.String),
 				zap.Error(err))
 		}
 	}
 
-	r.log.Debug("User retrieved successfully",
+	log.Debug("User retrieved successfully",
 		zap.String("user_id", id.String()),
 		zap.String("username", user.Username))
 	return &user, nil
 }
 
-// GetLeaderboard возвращает список пользователей с наибольшим балансом
-func (r *Repository) GetLeaderboard(ctx context.Context, limit int) ([]*models.User, error) {
-	r.log.Debug("Getting leaderboard", zap.Int("limit", limit))
+// UsernameExists проверяет, занято ли имя пользователя (без учета регистра)
+func (r *Repository) UsernameExists(ctx context.Context, username string) (_ bool, err error) {
+	ctx, done := r.withTimeout(ctx, &err)
+	defer done()
+
+	log := logger.FromContext(ctx, r.log)
+	log.Debug("Checking username existence", zap.String("username", username))
+
+	var exists bool
+	err = r.db.QueryRowContext(ctx,
+		"SELECT EXISTS(SELECT 1 FROM users WHERE lower(username) = lower($1))",
+		username,
+	).Scan(&exists)
+	if err != nil {
+		log.Error("Failed to check username existence",
+			zap.String("username", username),
+			zap.Error(err))
+		return false, fmt.Errorf("failed to check username existence: %w", err)
+	}
+
+	return exists, nil
+}
+
+// GetLeaderboard возвращает страницу пользователей с наибольшим балансом, подходящих под filter,
+// и общее количество таких пользователей
+func (r *Repository) GetLeaderboard(ctx context.Context, filter models.LeaderboardFilter, limit, offset int) (_ []*models.User, _ int, err error) {
+	ctx, done := r.withTimeout(ctx, &err)
+	defer done()
+
+	return r.leaderboard(ctx, "users", filter, limit, offset)
+}
+
+// leaderboard выбирает страницу таблицы лидеров из source: таблицы users или
+// материализованного представления leaderboard_mv с теми же столбцами
+func (r *Repository) leaderboard(ctx context.Context, source string, filter models.LeaderboardFilter, limit, offset int) ([]*models.User, int, error) {
+	log := logger.FromContext(ctx, r.log)
+	log.Debug("Getting leaderboard", zap.String("source", source), zap.Int("limit", limit), zap.Int("offset", offset))
+
+	var q querier = r.db
+	if r.opts.ConsistentLeaderboard {
+		// Снимок данных, общий для подсчета и выборки страницы
+		tx, err := r.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+		if err != nil {
+			log.Error("Failed to begin transaction", zap.Error(err))
+			return nil, 0, fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		defer tx.Rollback()
+		q = tx
+	}
+
+	where := `
+		WHERE ($1::int IS NULL OR points >= $1)
+		  AND ($2::int IS NULL OR points <= $2)
+		  AND ($3::timestamptz IS NULL OR created_at > $3)
+	`
+	args := []any{filter.MinPoints, filter.MaxPoints, filter.CreatedAfter}
+
+	var total int
+	if err := q.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+source+where, args...).Scan(&total); err != nil {
+		log.Error("Failed to count users", zap.Error(err))
+		return nil, 0, fmt.Errorf("failed to count users: %w", err)
+	}
 
 	query := `
 		SELECT id, username, points, referrer_id, created_at, updated_at
-		FROM users
-		ORDER BY points DESC
-		LIMIT $1
+		FROM ` + source + where + `
+		ORDER BY ` + leaderboardOrder(r.opts.LeaderboardTiebreaker) + `
+		LIMIT $4 OFFSET $5
 	`
 
-	rows, err := r.db.QueryContext(ctx, query, limit)
+	rows, err := q.QueryContext(ctx, query, append(args, limit, offset)...)
 	if err != nil {
-		r.log.Error("Failed to query leaderboard",
+		log.Error("Failed to query leaderboard",
 			zap.Int("limit", limit),
+			zap.Int("offset", offset),
 			zap.Error(err))
-		return nil, fmt.Errorf("failed to query leaderboard: %w", err)
+		return nil, 0, fmt.Errorf("failed to query leaderboard: %w", err)
 	}
 	defer rows.Close()
 
-	var users []*models.User
+	users := make([]*models.User, 0)
 	for rows.Next() {
 		var user models.User
 		var referrerID sql.NullString
@@ -199,8 +356,8 @@ func (r *Repository) GetLeaderboard(ctx context.Context, limit int) ([]*models.U
 		)
 
 		if err != nil {
-			r.log.Error("Failed to scan user", zap.Error(err))
-			return nil, fmt.Errorf("failed to scan user: %w", err)
+			log.Error("Failed to scan user", zap.Error(err))
+			return nil, 0, fmt.Errorf("failed to scan user: %w", err)
 		}
 
 		if referrerID.Valid {
@@ -208,7 +365,7 @@ func (r *Repository) GetLeaderboard(ctx context.Context, limit int) ([]*models.U
 			if err == nil {
 				user.ReferrerID = &refID
 			} else {
-				r.log.Warn("Invalid referrer ID format",
+				log.Warn("Invalid referrer ID format",
 					zap.String("user_id", user.ID.String()),
 					zap.String("raw_referrer_id", referrerID.String),
 					zap.Error(err))
@@ -219,19 +376,34 @@ func (r *Repository) GetLeaderboard(ctx context.Context, limit int) ([]*models.U
 	}
 
 	if err := rows.Err(); err != nil {
-		r.log.Error("Error iterating rows", zap.Error(err))
-		return nil, fmt.Errorf("error iterating rows: %w", err)
+		log.Error("Error iterating rows", zap.Error(err))
+		return nil, 0, fmt.Errorf("error iterating rows: %w", err)
 	}
 
-	r.log.Debug("Leaderboard retrieved successfully",
+	log.Debug("Leaderboard retrieved successfully",
 		zap.Int("limit", limit),
-		zap.Int("users_count", len(users)))
-	return users, nil
+		zap.Int("offset", offset),
+		zap.Int("users_count", len(users)),
+		zap.Int("total", total))
+	return users, total, nil
+}
+
+// leaderboardOrder возвращает порядок сортировки таблицы лидеров. Последним ключом
+// всегда идет id, поэтому порядок однозначен и страницы не пересекаются.
+func leaderboardOrder(tiebreaker string) string {
+	if tiebreaker == models.TiebreakerUsername {
+		return "points DESC, username, id"
+	}
+	return "points DESC, created_at, id"
 }
 
 // CompleteTask отмечает задание как выполненное и начисляет баллы
-func (r *Repository) CompleteTask(ctx context.Context, userID uuid.UUID, taskRequest models.TaskRequest) (*models.Task, error) {
-	r.log.Info("Completing task",
+func (r *Repository) CompleteTask(ctx context.Context, userID uuid.UUID, taskRequest models.TaskRequest, limit models.TaskActivityLimit) (_ *models.Task, err error) {
+	ctx, done := r.withTimeout(ctx, &err)
+	defer done()
+
+	log := logger.FromContext(ctx, r.log)
+	log.Info("Completing task",
 		zap.String("user_id", userID.String()),
 		zap.String("task_type", taskRequest.TaskType),
 		zap.Int("points", taskRequest.Points))
@@ -239,26 +411,54 @@ func (r *Repository) CompleteTask(ctx context.Context, userID uuid.UUID, taskReq
 	// Начало транзакции
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		r.log.Error("Failed to begin transaction", zap.Error(err))
+		log.Error("Failed to begin transaction", zap.Error(err))
 		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
-	// Проверка существования пользователя
-	var exists bool
-	r.log.Debug("Checking user existence", zap.String("user_id", userID.String()))
+	// Проверка существования пользователя и допустимости нового баланса.
+	// Строка блокируется до конца транзакции, поэтому проверка и начисление выполняются
+	// атомарно относительно параллельных переводов и реферальных бонусов.
+	var balance int
+	log.Debug("Locking user row", zap.String("user_id", userID.String()))
 
-	err = tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)", userID).Scan(&exists)
+	err = tx.QueryRowContext(ctx, "SELECT points FROM users WHERE id = $1 FOR UPDATE", userID).Scan(&balance)
 	if err != nil {
-		r.log.Error("Failed to check user existence",
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Warn("User not found", zap.String("user_id", userID.String()))
+			return nil, models.ErrUserNotFound
+		}
+		log.Error("Failed to check user existence",
 			zap.String("user_id", userID.String()),
 			zap.Error(err))
 		return nil, fmt.Errorf("failed to check user existence: %w", err)
 	}
 
-	if !exists {
-		r.log.Warn("User not found", zap.String("user_id", userID.String()))
-		return nil, errors.New("user not found")
+	if err := checkPointsRange(balance, taskRequest.Points); err != nil {
+		log.Warn("Points change out of range",
+			zap.String("user_id", userID.String()),
+			zap.Int("balance", balance),
+			zap.Int("points", taskRequest.Points))
+		return nil, err
+	}
+
+	// Задания считаются под блокировкой строки пользователя, поэтому одновременные запросы
+	// проходят проверку по очереди и видят задания друг друга
+	if limit.Check != nil {
+		var count int
+		err = tx.QueryRowContext(ctx,
+			"SELECT COUNT(*) FROM tasks WHERE user_id = $1 AND completed_at >= $2",
+			userID, limit.Since,
+		).Scan(&count)
+		if err != nil {
+			log.Error("Failed to count recent tasks",
+				zap.String("user_id", userID.String()),
+				zap.Error(err))
+			return nil, fmt.Errorf("failed to count recent tasks: %w", err)
+		}
+		if err = limit.Check(count); err != nil {
+			return nil, err
+		}
 	}
 
 	// Создание задания
@@ -271,7 +471,7 @@ func (r *Repository) CompleteTask(ctx context.Context, userID uuid.UUID, taskReq
 	}
 
 	// Вставка записи о выполненном задании
-	r.log.Debug("Inserting task record",
+	log.Debug("Inserting task record",
 		zap.String("task_id", task.ID.String()),
 		zap.String("user_id", userID.String()))
 
@@ -280,7 +480,11 @@ func (r *Repository) CompleteTask(ctx context.Context, userID uuid.UUID, taskReq
 		task.ID, task.UserID, task.TaskType, task.Points, task.CompletedAt,
 	)
 	if err != nil {
-		r.log.Error("Failed to insert task",
+		if isPointsRangeViolation(err) {
+			log.Warn("Task points out of range", zap.Int("points", taskRequest.Points))
+			return nil, models.ErrPointsOutOfRange
+		}
+		log.Error("Failed to insert task",
 			zap.String("user_id", userID.String()),
 			zap.String("task_type", taskRequest.TaskType),
 			zap.Error(err))
@@ -288,89 +492,153 @@ func (r *Repository) CompleteTask(ctx context.Context, userID uuid.UUID, taskReq
 	}
 
 	// Обновление баланса пользователя
-	r.log.Debug("Updating user points",
+	log.Debug("Updating user points",
 		zap.String("user_id", userID.String()),
 		zap.Int("points_to_add", task.Points))
 
-	_, err = tx.ExecContext(ctx,
+	res, err := tx.ExecContext(ctx,
 		"UPDATE users SET points = points + $1, updated_at = NOW() WHERE id = $2",
 		task.Points, task.UserID,
 	)
 	if err != nil {
-		r.log.Error("Failed to update user points",
+		if isPointsRangeViolation(err) {
+			log.Warn("Points change out of range", zap.String("user_id", userID.String()))
+			return nil, models.ErrPointsOutOfRange
+		}
+		log.Error("Failed to update user points",
 			zap.String("user_id", userID.String()),
 			zap.Int("points_to_add", task.Points),
 			zap.Error(err))
 		return nil, fmt.Errorf("failed to update user points: %w", err)
 	}
 
+	// Пользователь мог быть удален после проверки существования:
+	// без обновленной строки баланса запись о задании не фиксируется
+	affected, err := res.RowsAffected()
+	if err != nil {
+		log.Error("Failed to get affected rows", zap.Error(err))
+		return nil, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if affected != 1 {
+		log.Warn("User disappeared before points update",
+			zap.String("user_id", userID.String()),
+			zap.Int64("affected_rows", affected))
+		return nil, models.ErrUserNotFound
+	}
+	if err = r.recordPointEntries(ctx, tx, models.PointSourceTask, []uuid.UUID{userID}, []int{task.Points}); err != nil {
+		log.Error("Failed to record point entry",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, fmt.Errorf("failed to record point entry: %w", err)
+	}
+
 	// Фиксация транзакции
 	if err = tx.Commit(); err != nil {
-		r.log.Error("Failed to commit transaction", zap.Error(err))
+		log.Error("Failed to commit transaction", zap.Error(err))
 		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
-	r.log.Info("Task completed successfully",
+	task.Balance = balance + task.Points
+
+	log.Info("Task completed successfully",
 		zap.String("task_id", task.ID.String()),
 		zap.String("user_id", userID.String()),
 		zap.Int("points", task.Points))
 	return task, nil
 }
 
-// AddReferrer добавляет реферальный код
-func (r *Repository) AddReferrer(ctx context.Context, userID, referrerID uuid.UUID) (*models.User, error) {
-	r.log.Info("Adding referrer",
+// AddReferrer добавляет реферальный код и возвращает обновленного пользователя
+// и начисленные цепочке рефереров бонусы. Возвращает models.ErrReferrerUnchanged, если
+// у пользователя уже этот реферер, models.ErrReferrerAlreadySet, если указан другой,
+// models.ErrSelfReferral, если пользователь указан собственным реферером, и
+// models.ErrReferrerRemoved, если этот реферер ранее был удален пользователем.
+func (r *Repository) AddReferrer(ctx context.Context, userID, referrerID uuid.UUID) (_ *models.User, _ []models.ReferralCredit, err error) {
+	ctx, done := r.withTimeout(ctx, &err)
+	defer done()
+
+	log := logger.FromContext(ctx, r.log)
+	log.Info("Adding referrer",
 		zap.String("user_id", userID.String()),
 		zap.String("referrer_id", referrerID.String()))
 
+	if userID == referrerID {
+		log.Warn("User cannot add themselves as referrer", zap.String("user_id", userID.String()))
+		return nil, nil, models.ErrSelfReferral
+	}
+
 	// Начало транзакции
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		r.log.Error("Failed to begin transaction", zap.Error(err))
-		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+		log.Error("Failed to begin transaction", zap.Error(err))
+		return nil, nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
 	// Проверка существования реферера
 	var exists bool
-	r.log.Debug("Checking referrer existence", zap.String("referrer_id", referrerID.String()))
+	log.Debug("Checking referrer existence", zap.String("referrer_id", referrerID.String()))
 
 	err = tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)", referrerID).Scan(&exists)
 	if err != nil {
-		r.log.Error("Failed to check referrer existence",
+		log.Error("Failed to check referrer existence",
 			zap.String("referrer_id", referrerID.String()),
 			zap.Error(err))
-		return nil, fmt.Errorf("failed to check referrer existence: %w", err)
+		return nil, nil, fmt.Errorf("failed to check referrer existence: %w", err)
 	}
 
 	if !exists {
-		r.log.Warn("Referrer not found", zap.String("referrer_id", referrerID.String()))
-		return nil, errors.New("referrer not found")
+		log.Warn("Referrer not found", zap.String("referrer_id", referrerID.String()))
+		return nil, nil, models.ErrReferrerNotFound
 	}
 
-	// Проверка, что пользователь не имеет реферера
-	var hasReferrer bool
-	r.log.Debug("Checking if user already has referrer", zap.String("user_id", userID.String()))
+	// Проверка, что пользователь не имеет реферера; строка блокируется, чтобы одновременные
+	// повторы запроса не начислили бонусы дважды
+	var currentReferrer uuid.NullUUID
+	log.Debug("Checking if user already has referrer", zap.String("user_id", userID.String()))
 
-	err = tx.QueryRowContext(ctx, "SELECT referrer_id IS NOT NULL FROM users WHERE id = $1", userID).Scan(&hasReferrer)
+	err = tx.QueryRowContext(ctx, "SELECT referrer_id FROM users WHERE id = $1 FOR UPDATE", userID).Scan(&currentReferrer)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			r.log.Warn("User not found", zap.String("user_id", userID.String()))
-			return nil, errors.New("user not found")
+			log.Warn("User not found", zap.String("user_id", userID.String()))
+			return nil, nil, models.ErrUserNotFound
 		}
-		r.log.Error("Failed to check user referrer",
+		log.Error("Failed to check user referrer",
 			zap.String("user_id", userID.String()),
 			zap.Error(err))
-		return nil, fmt.Errorf("failed to check user referrer: %w", err)
+		return nil, nil, fmt.Errorf("failed to check user referrer: %w", err)
 	}
 
-	if hasReferrer {
-		r.log.Warn("User already has a referrer", zap.String("user_id", userID.String()))
-		return nil, errors.New("user already has a referrer")
+	if currentReferrer.Valid {
+		if currentReferrer.UUID == referrerID {
+			log.Info("Referrer is already set", zap.String("user_id", userID.String()))
+			return nil, nil, models.ErrReferrerUnchanged
+		}
+		log.Warn("User already has a referrer", zap.String("user_id", userID.String()))
+		return nil, nil, models.ErrReferrerAlreadySet
+	}
+
+	// Бонусы удаленному рефереру не начисляются повторно
+	var removed bool
+	err = tx.QueryRowContext(ctx,
+		"SELECT EXISTS(SELECT 1 FROM removed_referrers WHERE user_id = $1 AND referrer_id = $2)",
+		userID, referrerID,
+	).Scan(&removed)
+	if err != nil {
+		log.Error("Failed to check removed referrer",
+			zap.String("user_id", userID.String()),
+			zap.String("referrer_id", referrerID.String()),
+			zap.Error(err))
+		return nil, nil, fmt.Errorf("failed to check removed referrer: %w", err)
+	}
+	if removed {
+		log.Warn("Referrer was removed by user",
+			zap.String("user_id", userID.String()),
+			zap.String("referrer_id", referrerID.String()))
+		return nil, nil, models.ErrReferrerRemoved
 	}
 
 	// Обновление реферального кода пользователя
-	r.log.Debug("Updating user referrer",
+	log.Debug("Updating user referrer",
 		zap.String("user_id", userID.String()),
 		zap.String("referrer_id", referrerID.String()))
 
@@ -379,36 +647,56 @@ func (r *Repository) AddReferrer(ctx context.Context, userID, referrerID uuid.UU
 		referrerID, userID,
 	)
 	if err != nil {
-		r.log.Error("Failed to update user referrer",
+		log.Error("Failed to update user referrer",
 			zap.String("user_id", userID.String()),
 			zap.String("referrer_id", referrerID.String()),
 			zap.Error(err))
-		return nil, fmt.Errorf("failed to update user referrer: %w", err)
+		return nil, nil, fmt.Errorf("failed to update user referrer: %w", err)
 	}
 
-	// Начисление бонусных баллов рефереру
-	bonusPoints := 10 // Бонус за реферала
-	r.log.Debug("Adding bonus points to referrer",
+	// Начисление бонусных баллов цепочке рефереров
+	log.Debug("Adding bonus points to referrer chain",
 		zap.String("referrer_id", referrerID.String()),
-		zap.Int("bonus_points", bonusPoints))
+		zap.Ints("schedule", r.opts.ReferralSchedule))
 
-	_, err = tx.ExecContext(ctx,
-		"UPDATE users SET points = points + $1, updated_at = NOW() WHERE id = $2",
-		bonusPoints, referrerID,
-	)
+	credits, err := creditReferrerChain(ctx, tx, userID, referrerID, r.opts.ReferralSchedule)
 	if err != nil {
-		r.log.Error("Failed to update referrer points",
+		if isPointsRangeViolation(err) {
+			log.Warn("Referrer points out of range", zap.String("referrer_id", referrerID.String()))
+			return nil, nil, models.ErrPointsOutOfRange
+		}
+		log.Error("Failed to update referrer points",
 			zap.String("referrer_id", referrerID.String()),
-			zap.Int("bonus_points", bonusPoints),
 			zap.Error(err))
-		return nil, fmt.Errorf("failed to update referrer points: %w", err)
+		return nil, nil, fmt.Errorf("failed to update referrer points: %w", err)
+	}
+	if err = recordReferralCredits(ctx, tx, userID, credits); err != nil {
+		log.Error("Failed to record referral credits",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, nil, fmt.Errorf("failed to record referral credits: %w", err)
+	}
+	referrers := make([]uuid.UUID, len(credits))
+	bonuses := make([]int, len(credits))
+	for i, credit := range credits {
+		referrers[i] = credit.UserID
+		bonuses[i] = credit.Points
 	}
+	if err = r.recordPointEntries(ctx, tx, models.PointSourceReferral, referrers, bonuses); err != nil {
+		log.Error("Failed to record point entries",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, nil, fmt.Errorf("failed to record point entries: %w", err)
+	}
+	log.Debug("Referrer chain credited",
+		zap.String("referrer_id", referrerID.String()),
+		zap.Int("credited_users", len(credits)))
 
 	// Получение обновленных данных пользователя
 	var user models.User
 	var refID sql.NullString
 
-	r.log.Debug("Getting updated user data", zap.String("user_id", userID.String()))
+	log.Debug("Getting updated user data", zap.String("user_id", userID.String()))
 
 	err = tx.QueryRowContext(ctx,
 		"SELECT id, username, points, referrer_id, created_at, updated_at FROM users WHERE id = $1",
@@ -422,10 +710,10 @@ func (r *Repository) AddReferrer(ctx context.Context, userID, referrerID uuid.UU
 		&user.UpdatedAt,
 	)
 	if err != nil {
-		r.log.Error("Failed to get updated user",
+		log.Error("Failed to get updated user",
 			zap.String("user_id", userID.String()),
 			zap.Error(err))
-		return nil, fmt.Errorf("failed to get updated user: %w", err)
+		return nil, nil, fmt.Errorf("failed to get updated user: %w", err)
 	}
 
 	// Преобразование sql.NullString в *uuid.UUID
@@ -434,7 +722,7 @@ func (r *Repository) AddReferrer(ctx context.Context, userID, referrerID uuid.UU
 		if err == nil {
 			user.ReferrerID = &parsedRefID
 		} else {
-			r.log.Warn("Invalid referrer ID format",
+			log.Warn("Invalid referrer ID format",
 				zap.String("user_id", userID.String()),
 				zap.String("raw_referrer_id", refID.String),
 				zap.Error(err))
@@ -443,12 +731,12 @@ func (r *Repository) AddReferrer(ctx context.Context, userID, referrerID uuid.UU
 
 	// Фиксация транзакции
 	if err = tx.Commit(); err != nil {
-		r.log.Error("Failed to commit transaction", zap.Error(err))
-		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+		log.Error("Failed to commit transaction", zap.Error(err))
+		return nil, nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
-	r.log.Info("Referrer added successfully",
+	log.Info("Referrer added successfully",
 		zap.String("user_id", userID.String()),
 		zap.String("referrer_id", referrerID.String()))
-	return &user, nil
+	return &user, credits, nil
 }
@@ -0,0 +1,128 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"go.uber.org/zap"
+)
+
+func TestNewRepositoryPingTimeout(t *testing.T) {
+	// Сервер принимает соединение, но не отвечает на рукопожатие PostgreSQL
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	host, port, err := net.SplitHostPort(listener.Addr().String())
+	if err != nil {
+		t.Fatalf("SplitHostPort() error = %v", err)
+	}
+
+	start := time.Now()
+	repo, err := NewRepository("postgres", "secret", host, port, "user_points", "disable", Options{}, zap.NewNop())
+	if err == nil {
+		repo.Close()
+		t.Fatal("NewRepository() error = nil, want ping failure")
+	}
+	if elapsed := time.Since(start); elapsed > pingTimeout+2*time.Second {
+		t.Errorf("NewRepository() returned after %s, want the ping to stop after %s", elapsed, pingTimeout)
+	}
+}
+
+func TestNewRepositoryConnectionRefused(t *testing.T) {
+	// Свободный порт, на котором никто не слушает
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	host, port, err := net.SplitHostPort(listener.Addr().String())
+	if err != nil {
+		t.Fatalf("SplitHostPort() error = %v", err)
+	}
+	listener.Close()
+
+	if repo, err := NewRepository("postgres", "secret", host, port, "user_points", "disable", Options{}, zap.NewNop()); err == nil {
+		repo.Close()
+		t.Fatal("NewRepository() error = nil, want connection failure")
+	}
+}
+
+func TestWithTimeout(t *testing.T) {
+	errQuery := errors.New("canceling statement due to user request")
+	deadline, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+
+	tests := []struct {
+		name         string
+		queryTimeout time.Duration
+		ctx          context.Context
+		// wantDeadline добавляется ли срок QueryTimeout к контексту
+		wantDeadline bool
+	}{
+		{name: "no caller deadline", queryTimeout: time.Second, ctx: context.Background(), wantDeadline: true},
+		{name: "caller deadline kept", queryTimeout: time.Second, ctx: deadline},
+		{name: "disabled", ctx: context.Background()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &Repository{opts: Options{QueryTimeout: tt.queryTimeout}}
+			var err error
+			ctx, done := repo.withTimeout(tt.ctx, &err)
+			defer done()
+
+			got, ok := ctx.Deadline()
+			if tt.wantDeadline {
+				if !ok || time.Until(got) > tt.queryTimeout {
+					t.Errorf("deadline = %v, %v, want within %s", got, ok, tt.queryTimeout)
+				}
+				return
+			}
+			if ctx != tt.ctx {
+				t.Error("context replaced, want the caller's context")
+			}
+		})
+	}
+
+	t.Run("expired deadline wraps error", func(t *testing.T) {
+		repo := &Repository{opts: Options{QueryTimeout: time.Millisecond}}
+		err := func() (err error) {
+			ctx, done := repo.withTimeout(context.Background(), &err)
+			defer done()
+
+			<-ctx.Done()
+			return errQuery
+		}()
+		if !errors.Is(err, models.ErrQueryTimeout) || !errors.Is(err, errQuery) {
+			t.Errorf("error = %v, want %v wrapping %v", err, models.ErrQueryTimeout, errQuery)
+		}
+	})
+
+	t.Run("error before deadline not wrapped", func(t *testing.T) {
+		repo := &Repository{opts: Options{QueryTimeout: time.Hour}}
+		err := func() (err error) {
+			_, done := repo.withTimeout(context.Background(), &err)
+			defer done()
+
+			return errQuery
+		}()
+		if err != errQuery {
+			t.Errorf("error = %v, want %v unwrapped", err, errQuery)
+		}
+	})
+}
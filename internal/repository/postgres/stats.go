@@ -0,0 +1,81 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// GetTaskStats возвращает количество выполнений и сумму баллов по каждому типу задания.
+// Необязательные from и to ограничивают completed_at полуинтервалом [from, to).
+func (r *Repository) GetTaskStats(ctx context.Context, from, to *time.Time) (_ []*models.TaskStats, err error) {
+	ctx, done := r.withTimeout(ctx, &err)
+	defer done()
+
+	log := logger.FromContext(ctx, r.log)
+	log.Debug("Getting task stats")
+
+	query := `
+		SELECT task_type, COUNT(*), COALESCE(SUM(points), 0)
+		FROM tasks
+		WHERE deleted_at IS NULL
+		  AND ($1::timestamptz IS NULL OR completed_at >= $1)
+		  AND ($2::timestamptz IS NULL OR completed_at < $2)
+		GROUP BY task_type
+		ORDER BY task_type
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, from, to)
+	if err != nil {
+		log.Error("Failed to query task stats", zap.Error(err))
+		return nil, fmt.Errorf("failed to query task stats: %w", err)
+	}
+	defer rows.Close()
+
+	stats := make([]*models.TaskStats, 0)
+	for rows.Next() {
+		var s models.TaskStats
+		if err := rows.Scan(&s.TaskType, &s.Count, &s.TotalPoints); err != nil {
+			log.Error("Failed to scan task stats", zap.Error(err))
+			return nil, fmt.Errorf("failed to scan task stats: %w", err)
+		}
+		stats = append(stats, &s)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Error("Error iterating rows", zap.Error(err))
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	log.Debug("Task stats retrieved successfully", zap.Int("task_types", len(stats)))
+	return stats, nil
+}
+
+// GetGlobalStats возвращает общие показатели сервиса одним запросом с подзапросами
+func (r *Repository) GetGlobalStats(ctx context.Context) (_ *models.GlobalStats, err error) {
+	ctx, done := r.withTimeout(ctx, &err)
+	defer done()
+
+	log := logger.FromContext(ctx, r.log)
+	log.Debug("Getting global stats")
+
+	var stats models.GlobalStats
+	err = r.db.QueryRowContext(ctx, `
+		SELECT
+			(SELECT COUNT(*) FROM users),
+			(SELECT COALESCE(SUM(points), 0) FROM users),
+			(SELECT COUNT(*) FROM tasks WHERE deleted_at IS NULL),
+			(SELECT COUNT(*) FROM users WHERE referrer_id IS NOT NULL)
+	`).Scan(&stats.TotalUsers, &stats.TotalPoints, &stats.TotalTasks, &stats.UsersWithReferrer)
+	if err != nil {
+		log.Error("Failed to query global stats", zap.Error(err))
+		return nil, fmt.Errorf("failed to query global stats: %w", err)
+	}
+	stats.CalculatedAt = time.Now()
+
+	return &stats, nil
+}
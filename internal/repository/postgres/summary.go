@@ -0,0 +1,141 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/logger"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// GetUserRank возвращает место пользователя в таблице лидеров (с 1) в том же порядке,
+// что и GetLeaderboard без фильтров, или models.ErrUserNotFound
+func (r *Repository) GetUserRank(ctx context.Context, userID uuid.UUID) (_ int, err error) {
+	ctx, done := r.withTimeout(ctx, &err)
+	defer done()
+
+	log := logger.FromContext(ctx, r.log)
+	log.Debug("Getting user rank", zap.String("user_id", userID.String()))
+
+	// Место равно количеству пользователей выше в порядке leaderboardOrder плюс один
+	query := `
+		SELECT (
+			SELECT COUNT(*) FROM users u
+			WHERE ` + r.rankedAhead() + `
+		) + 1
+		FROM users me
+		WHERE me.id = $1
+	`
+
+	var rank int
+	if err := r.db.QueryRowContext(ctx, query, userID).Scan(&rank); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, models.ErrUserNotFound
+		}
+		log.Error("Failed to get user rank",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return 0, fmt.Errorf("failed to get user rank: %w", err)
+	}
+	return rank, nil
+}
+
+// GetPointsToNextRank возвращает количество баллов, которых пользователю не хватает, чтобы
+// обогнать пользователя на месте выше в порядке GetUserRank, 0 для первого места
+// или models.ErrUserNotFound
+func (r *Repository) GetPointsToNextRank(ctx context.Context, userID uuid.UUID) (_ int, err error) {
+	ctx, done := r.withTimeout(ctx, &err)
+	defer done()
+
+	log := logger.FromContext(ctx, r.log)
+	log.Debug("Getting points to next rank", zap.String("user_id", userID.String()))
+
+	// Место выше занимает пользователь с наименьшим балансом среди стоящих выше; при равенстве
+	// баланса его не обогнать, поэтому нужен хотя бы на балл больше
+	query := `
+		SELECT (
+			SELECT MIN(u.points) FROM users u
+			WHERE ` + r.rankedAhead() + `
+		) - me.points + 1
+		FROM users me
+		WHERE me.id = $1
+	`
+
+	var gap sql.NullInt64
+	if err = r.db.QueryRowContext(ctx, query, userID).Scan(&gap); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, models.ErrUserNotFound
+		}
+		log.Error("Failed to get points to next rank",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return 0, fmt.Errorf("failed to get points to next rank: %w", err)
+	}
+	// NULL означает, что выше никого нет
+	return int(gap.Int64), nil
+}
+
+// rankedAhead условие "пользователь u стоит в таблице лидеров выше пользователя me"
+// в порядке leaderboardOrder
+func (r *Repository) rankedAhead() string {
+	ahead := "(u.created_at, u.id) < (me.created_at, me.id)"
+	if r.opts.LeaderboardTiebreaker == models.TiebreakerUsername {
+		ahead = "(u.username, u.id) < (me.username, me.id)"
+	}
+	return "u.points > me.points OR (u.points = me.points AND " + ahead + ")"
+}
+
+// GetUserStanding возвращает количество пользователей с большим балансом, чем у пользователя
+// (пользователи с равным балансом не учитываются), и общее количество пользователей
+// или models.ErrUserNotFound
+func (r *Repository) GetUserStanding(ctx context.Context, userID uuid.UUID) (_ int, _ int, err error) {
+	ctx, done := r.withTimeout(ctx, &err)
+	defer done()
+
+	log := logger.FromContext(ctx, r.log)
+	log.Debug("Getting user standing", zap.String("user_id", userID.String()))
+
+	query := `
+		SELECT
+			(SELECT COUNT(*) FROM users u WHERE u.points > me.points),
+			(SELECT COUNT(*) FROM users)
+		FROM users me
+		WHERE me.id = $1
+	`
+
+	var ahead, total int
+	if err = r.db.QueryRowContext(ctx, query, userID).Scan(&ahead, &total); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, 0, models.ErrUserNotFound
+		}
+		log.Error("Failed to get user standing",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return 0, 0, fmt.Errorf("failed to get user standing: %w", err)
+	}
+	return ahead, total, nil
+}
+
+// CountReferrals возвращает количество пользователей, указавших userID прямым реферером
+func (r *Repository) CountReferrals(ctx context.Context, userID uuid.UUID) (_ int, err error) {
+	ctx, done := r.withTimeout(ctx, &err)
+	defer done()
+
+	log := logger.FromContext(ctx, r.log)
+	log.Debug("Counting referrals", zap.String("user_id", userID.String()))
+
+	var count int
+	if err := r.db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM users WHERE referrer_id = $1", userID,
+	).Scan(&count); err != nil {
+		log.Error("Failed to count referrals",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return 0, fmt.Errorf("failed to count referrals: %w", err)
+	}
+	return count, nil
+}
@@ -0,0 +1,158 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/logger"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// GetUserTasks возвращает выполненные пользователем задания, начиная с последних.
+// Пустой taskType означает отсутствие фильтра по типу задания.
+func (r *Repository) GetUserTasks(ctx context.Context, userID uuid.UUID, taskType string, limit, offset int) (_ []*models.Task, err error) {
+	ctx, done := r.withTimeout(ctx, &err)
+	defer done()
+
+	log := logger.FromContext(ctx, r.log)
+	log.Debug("Getting user tasks",
+		zap.String("user_id", userID.String()),
+		zap.String("task_type", taskType),
+		zap.Int("limit", limit),
+		zap.Int("offset", offset))
+
+	query := `
+		SELECT id, user_id, task_type, points, completed_at
+		FROM tasks
+		WHERE user_id = $1 AND deleted_at IS NULL AND ($2 = '' OR task_type = $2)
+		ORDER BY completed_at DESC, id
+		LIMIT $3 OFFSET $4
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID, taskType, limit, offset)
+	if err != nil {
+		log.Error("Failed to query user tasks",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, fmt.Errorf("failed to query user tasks: %w", err)
+	}
+	defer rows.Close()
+
+	tasks := make([]*models.Task, 0)
+	for rows.Next() {
+		var task models.Task
+		err := rows.Scan(
+			&task.ID,
+			&task.UserID,
+			&task.TaskType,
+			&task.Points,
+			&task.CompletedAt,
+		)
+		if err != nil {
+			log.Error("Failed to scan task", zap.Error(err))
+			return nil, fmt.Errorf("failed to scan task: %w", err)
+		}
+		tasks = append(tasks, &task)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Error("Error iterating rows", zap.Error(err))
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	log.Debug("User tasks retrieved successfully",
+		zap.String("user_id", userID.String()),
+		zap.Int("tasks_count", len(tasks)))
+	return tasks, nil
+}
+
+// RevertTask отменяет выполнение задания: списывает начисленные за него баллы
+// (баланс не опускается ниже нуля) и помечает задание удаленным.
+func (r *Repository) RevertTask(ctx context.Context, taskID uuid.UUID) (_ *models.Task, err error) {
+	ctx, done := r.withTimeout(ctx, &err)
+	defer done()
+
+	log := logger.FromContext(ctx, r.log)
+	log.Info("Reverting task", zap.String("task_id", taskID.String()))
+
+	// Начало транзакции
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error("Failed to begin transaction", zap.Error(err))
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Блокировка строки задания
+	task := models.Task{ID: taskID}
+	var deletedAt sql.NullTime
+	err = tx.QueryRowContext(ctx,
+		"SELECT user_id, task_type, points, completed_at, deleted_at FROM tasks WHERE id = $1 FOR UPDATE",
+		taskID,
+	).Scan(&task.UserID, &task.TaskType, &task.Points, &task.CompletedAt, &deletedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Warn("Task not found", zap.String("task_id", taskID.String()))
+			return nil, models.ErrTaskNotFound
+		}
+		log.Error("Failed to get task",
+			zap.String("task_id", taskID.String()),
+			zap.Error(err))
+		return nil, fmt.Errorf("failed to get task: %w", err)
+	}
+
+	if deletedAt.Valid {
+		log.Warn("Task already reverted", zap.String("task_id", taskID.String()))
+		return nil, models.ErrTaskAlreadyReverted
+	}
+
+	// Списание баллов пользователя
+	_, err = tx.ExecContext(ctx,
+		"UPDATE users SET points = GREATEST(points - $1, 0), updated_at = NOW() WHERE id = $2",
+		task.Points, task.UserID,
+	)
+	if err != nil {
+		log.Error("Failed to deduct task points",
+			zap.String("task_id", taskID.String()),
+			zap.String("user_id", task.UserID.String()),
+			zap.Error(err))
+		return nil, fmt.Errorf("failed to deduct task points: %w", err)
+	}
+	if err = consumePointEntries(ctx, tx, task.UserID, task.Points); err != nil {
+		log.Error("Failed to consume point entries",
+			zap.String("user_id", task.UserID.String()),
+			zap.Error(err))
+		return nil, fmt.Errorf("failed to consume point entries: %w", err)
+	}
+
+	// Пометка задания удаленным
+	var revertedAt time.Time
+	err = tx.QueryRowContext(ctx,
+		"UPDATE tasks SET deleted_at = NOW() WHERE id = $1 RETURNING deleted_at",
+		taskID,
+	).Scan(&revertedAt)
+	if err != nil {
+		log.Error("Failed to mark task deleted",
+			zap.String("task_id", taskID.String()),
+			zap.Error(err))
+		return nil, fmt.Errorf("failed to mark task deleted: %w", err)
+	}
+	task.DeletedAt = &revertedAt
+
+	// Фиксация транзакции
+	if err = tx.Commit(); err != nil {
+		log.Error("Failed to commit transaction", zap.Error(err))
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.Info("Task reverted successfully",
+		zap.String("task_id", taskID.String()),
+		zap.String("user_id", task.UserID.String()),
+		zap.Int("points", task.Points))
+	return &task, nil
+}
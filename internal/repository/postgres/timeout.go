@@ -0,0 +1,34 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+)
+
+// withTimeout ограничивает вызов метода репозитория сроком Options.QueryTimeout, если у ctx
+// нет собственного срока. Возвращаемая функция вызывается через defer: она освобождает
+// производный контекст и, если его срок истек, оборачивает ошибку *errp в models.ErrQueryTimeout.
+// Срок общий для всех запросов и транзакции метода, а вложенные вызовы других методов
+// репозитория наследуют его, не продлевая.
+func (r *Repository) withTimeout(ctx context.Context, errp *error) (context.Context, func()) {
+	if r.opts.QueryTimeout <= 0 {
+		return ctx, func() {}
+	}
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.opts.QueryTimeout)
+	return ctx, func() {
+		// Проверка выполняется до cancel, иначе ctx.Err() всегда вернет context.Canceled.
+		// Драйвер сообщает об отмене запроса по-разному (context.DeadlineExceeded или
+		// ошибкой PostgreSQL 57014), поэтому причина определяется по состоянию контекста.
+		if *errp != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) && !errors.Is(*errp, models.ErrQueryTimeout) {
+			*errp = fmt.Errorf("%w: %w", models.ErrQueryTimeout, *errp)
+		}
+		cancel()
+	}
+}
@@ -0,0 +1,126 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/logger"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// TransferPoints переводит баллы от одного пользователя другому и возвращает новый баланс отправителя
+func (r *Repository) TransferPoints(ctx context.Context, fromID, toID uuid.UUID, amount int) (_ int, err error) {
+	ctx, done := r.withTimeout(ctx, &err)
+	defer done()
+
+	log := logger.FromContext(ctx, r.log)
+	log.Info("Transferring points",
+		zap.String("from_id", fromID.String()),
+		zap.String("to_id", toID.String()),
+		zap.Int("amount", amount))
+
+	// Начало транзакции
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error("Failed to begin transaction", zap.Error(err))
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Блокировка обеих строк в порядке id, чтобы встречные переводы не взаимоблокировались
+	rows, err := tx.QueryContext(ctx,
+		"SELECT id, points FROM users WHERE id IN ($1, $2) ORDER BY id FOR UPDATE",
+		fromID, toID,
+	)
+	if err != nil {
+		log.Error("Failed to lock users", zap.Error(err))
+		return 0, fmt.Errorf("failed to lock users: %w", err)
+	}
+
+	balances := make(map[uuid.UUID]int, 2)
+	for rows.Next() {
+		var id uuid.UUID
+		var points int
+		if err := rows.Scan(&id, &points); err != nil {
+			rows.Close()
+			log.Error("Failed to scan user", zap.Error(err))
+			return 0, fmt.Errorf("failed to scan user: %w", err)
+		}
+		balances[id] = points
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		log.Error("Error iterating rows", zap.Error(err))
+		return 0, fmt.Errorf("error iterating rows: %w", err)
+	}
+	rows.Close()
+
+	fromBalance, fromOK := balances[fromID]
+	toBalance, toOK := balances[toID]
+	if !fromOK || !toOK {
+		log.Warn("User not found",
+			zap.String("from_id", fromID.String()),
+			zap.String("to_id", toID.String()))
+		return 0, models.ErrUserNotFound
+	}
+
+	if fromBalance < amount {
+		log.Warn("Insufficient points",
+			zap.String("from_id", fromID.String()),
+			zap.Int("balance", fromBalance),
+			zap.Int("amount", amount))
+		return 0, models.ErrInsufficientPoints
+	}
+
+	if err := checkPointsRange(toBalance, amount); err != nil {
+		log.Warn("Receiver points out of range",
+			zap.String("to_id", toID.String()),
+			zap.Int("balance", toBalance),
+			zap.Int("amount", amount))
+		return 0, err
+	}
+
+	// Списание у отправителя
+	_, err = tx.ExecContext(ctx,
+		"UPDATE users SET points = points - $1, updated_at = NOW() WHERE id = $2",
+		amount, fromID,
+	)
+	if err != nil {
+		log.Error("Failed to debit sender",
+			zap.String("from_id", fromID.String()),
+			zap.Error(err))
+		return 0, fmt.Errorf("failed to debit sender: %w", err)
+	}
+	if err = consumePointEntries(ctx, tx, fromID, amount); err != nil {
+		log.Error("Failed to consume point entries",
+			zap.String("from_id", fromID.String()),
+			zap.Error(err))
+		return 0, fmt.Errorf("failed to consume point entries: %w", err)
+	}
+
+	// Зачисление получателю
+	_, err = tx.ExecContext(ctx,
+		"UPDATE users SET points = points + $1, updated_at = NOW() WHERE id = $2",
+		amount, toID,
+	)
+	if err != nil {
+		log.Error("Failed to credit receiver",
+			zap.String("to_id", toID.String()),
+			zap.Error(err))
+		return 0, fmt.Errorf("failed to credit receiver: %w", err)
+	}
+
+	// Фиксация транзакции
+	if err = tx.Commit(); err != nil {
+		log.Error("Failed to commit transaction", zap.Error(err))
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.Info("Points transferred successfully",
+		zap.String("from_id", fromID.String()),
+		zap.String("to_id", toID.String()),
+		zap.Int("amount", amount))
+	return fromBalance - amount, nil
+}
@@ -0,0 +1,167 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/logger"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// likeEscaper экранирует специальные символы шаблона LIKE
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// ListUsers возвращает страницу пользователей, подходящих под filter, и их общее количество.
+// Хеш пароля не выбирается.
+func (r *Repository) ListUsers(ctx context.Context, filter models.UserListFilter, limit, offset int) (_ []*models.User, _ int, err error) {
+	ctx, done := r.withTimeout(ctx, &err)
+	defer done()
+
+	log := logger.FromContext(ctx, r.log)
+	log.Debug("Listing users",
+		zap.String("search", filter.Search),
+		zap.String("sort", filter.Sort),
+		zap.Int("limit", limit),
+		zap.Int("offset", offset))
+
+	where := `
+		WHERE ($1 = '' OR username ILIKE '%' || $1 || '%' ESCAPE '\')
+		  AND ($2::boolean IS NULL OR (referrer_id IS NOT NULL) = $2)
+	`
+	args := []any{likeEscaper.Replace(filter.Search), filter.HasReferrer}
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users"+where, args...).Scan(&total); err != nil {
+		log.Error("Failed to count users", zap.Error(err))
+		return nil, 0, fmt.Errorf("failed to count users: %w", err)
+	}
+
+	// Поле и направление сортировки подставляются только из фиксированного набора
+	column := "created_at"
+	if filter.Sort == models.UserSortPoints {
+		column = "points"
+	}
+	direction := "DESC"
+	if filter.Asc {
+		direction = "ASC"
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, username, points, referrer_id, role, referral_code, email, email_verified_at,
+			last_login_at, streak_days, version, created_at, updated_at
+		FROM users`+where+`
+		ORDER BY `+column+` `+direction+`, id
+		LIMIT $3 OFFSET $4
+	`, append(args, limit, offset)...)
+	if err != nil {
+		log.Error("Failed to query users", zap.Error(err))
+		return nil, 0, fmt.Errorf("failed to query users: %w", err)
+	}
+	defer rows.Close()
+
+	users := make([]*models.User, 0)
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			log.Error("Failed to scan user", zap.Error(err))
+			return nil, 0, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Error("Error iterating user rows", zap.Error(err))
+		return nil, 0, fmt.Errorf("error iterating user rows: %w", err)
+	}
+
+	return users, total, nil
+}
+
+// GetUsersByIDs возвращает пользователей с указанными ID одним запросом, индексируя их по ID.
+// Ненайденные ID в результат не попадают. Хеш пароля не выбирается.
+func (r *Repository) GetUsersByIDs(ctx context.Context, ids []uuid.UUID) (_ map[uuid.UUID]*models.User, err error) {
+	users := make(map[uuid.UUID]*models.User, len(ids))
+	if len(ids) == 0 {
+		return users, nil
+	}
+
+	ctx, done := r.withTimeout(ctx, &err)
+	defer done()
+
+	log := logger.FromContext(ctx, r.log)
+	log.Debug("Getting users by IDs", zap.Int("count", len(ids)))
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, username, points, referrer_id, role, referral_code, email, email_verified_at,
+			last_login_at, streak_days, version, created_at, updated_at
+		FROM users
+		WHERE id = ANY($1::uuid[])
+	`, pq.Array(ids))
+	if err != nil {
+		log.Error("Failed to query users by IDs", zap.Error(err))
+		return nil, fmt.Errorf("failed to query users by IDs: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			log.Error("Failed to scan user", zap.Error(err))
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users[user.ID] = user
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Error("Error iterating user rows", zap.Error(err))
+		return nil, fmt.Errorf("error iterating user rows: %w", err)
+	}
+
+	log.Debug("Users retrieved by IDs",
+		zap.Int("requested", len(ids)),
+		zap.Int("found", len(users)))
+	return users, nil
+}
+
+// scanUser читает строку с полями пользователя в порядке выборки ListUsers и GetUsersByIDs
+func scanUser(rows *sql.Rows) (*models.User, error) {
+	var user models.User
+	var referrerID uuid.NullUUID
+	var email sql.NullString
+	var emailVerifiedAt, lastLoginAt sql.NullTime
+	if err := rows.Scan(
+		&user.ID,
+		&user.Username,
+		&user.Points,
+		&referrerID,
+		&user.Role,
+		&user.ReferralCode,
+		&email,
+		&emailVerifiedAt,
+		&lastLoginAt,
+		&user.StreakDays,
+		&user.Version,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	if referrerID.Valid {
+		user.ReferrerID = &referrerID.UUID
+	}
+	if email.Valid {
+		user.Email = &email.String
+	}
+	if emailVerifiedAt.Valid {
+		user.EmailVerifiedAt = &emailVerifiedAt.Time
+	}
+	if lastLoginAt.Valid {
+		user.LastLoginAt = &lastLoginAt.Time
+	}
+	return &user, nil
+}
@@ -0,0 +1,1117 @@
+// Package repotest содержит общий набор проверок поведения хранилищ данных.
+// Набор запускается для каждой реализации service.StorageRepository, чтобы хранилище
+// в памяти и PostgreSQL одинаково выполняли контракт интерфейса.
+package repotest
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"github.com/DblMOKRQ/DeNet_test_task/internal/service"
+	"github.com/google/uuid"
+)
+
+// Options параметры хранилища, от которых зависят проверки
+type Options struct {
+	// LeaderboardTiebreaker порядок пользователей с равным балансом
+	LeaderboardTiebreaker string
+	// ReferralSchedule бонусные баллы по уровням цепочки рефереров
+	ReferralSchedule []int
+	// PointsTTL срок действия баллов за задания и реферальных бонусов
+	PointsTTL time.Duration
+}
+
+// Factory создает пустое хранилище с параметрами opts для одной проверки
+type Factory func(t *testing.T, opts Options) service.StorageRepository
+
+var referralCodePattern = regexp.MustCompile(`^[A-Z2-7]{8}$`)
+
+// Run запускает общий набор проверок для хранилища, создаваемого newRepo
+func Run(t *testing.T, newRepo Factory) {
+	tests := []struct {
+		name string
+		opts Options
+		run  func(t *testing.T, repo service.StorageRepository)
+	}{
+		{name: "Register", run: testRegister},
+		{name: "CompleteTask", run: testCompleteTask},
+		{name: "CompleteTaskActivityLimit", run: testCompleteTaskActivityLimit},
+		{name: "Leaderboard", run: testLeaderboard},
+		{name: "LeaderboardTiebreakerCreatedAt", opts: Options{LeaderboardTiebreaker: models.TiebreakerCreatedAt}, run: testTiebreaker("bob,alice,carol")},
+		{name: "LeaderboardTiebreakerUsername", opts: Options{LeaderboardTiebreaker: models.TiebreakerUsername}, run: testTiebreaker("alice,bob,carol")},
+		{name: "LeaderboardFilter", run: testLeaderboardFilter},
+		{name: "Lookup", run: testLookup},
+		{name: "UsernameCaseInsensitive", run: testUsernameCaseInsensitive},
+		{name: "RegenerateReferralCode", run: testRegenerateReferralCode},
+		{name: "Referrer", opts: Options{ReferralSchedule: []int{10, 5}}, run: testReferrer},
+		{name: "RemoveReferrer", opts: Options{ReferralSchedule: []int{10, 5}}, run: testRemoveReferrer},
+		{name: "TransferPoints", run: testTransferPoints},
+		{name: "AdjustPoints", run: testAdjustPoints},
+		{name: "RevertTask", run: testRevertTask},
+		{name: "DeleteUser", run: testDeleteUser},
+		{name: "Audit", run: testAudit},
+		{name: "GlobalStats", run: testGlobalStats},
+		{name: "ProfileVersion", run: testProfileVersion},
+		{name: "Email", run: testEmail},
+		{name: "PasswordReset", run: testPasswordReset},
+		{name: "UserRankCreatedAt", opts: Options{LeaderboardTiebreaker: models.TiebreakerCreatedAt}, run: testUserRank("bob,alice,carol")},
+		{name: "UserRankUsername", opts: Options{LeaderboardTiebreaker: models.TiebreakerUsername}, run: testUserRank("alice,bob,carol")},
+		{name: "PointsToNextRankCreatedAt", opts: Options{LeaderboardTiebreaker: models.TiebreakerCreatedAt}, run: testPointsToNextRank("bob", "alice")},
+		{name: "PointsToNextRankUsername", opts: Options{LeaderboardTiebreaker: models.TiebreakerUsername}, run: testPointsToNextRank("alice", "bob")},
+		{name: "UserStanding", run: testUserStanding},
+		{name: "CountReferrals", run: testCountReferrals},
+		{name: "ListUsers", run: testListUsers},
+		{name: "GetUsersByIDs", run: testGetUsersByIDs},
+		{name: "PointsExpiry", opts: Options{PointsTTL: 300 * time.Millisecond}, run: testPointsExpiry},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.run(t, newRepo(t, tt.opts))
+		})
+	}
+}
+
+// register регистрирует пользователя с тестовым хешем пароля
+func register(t *testing.T, repo service.StorageRepository, username string) *models.User {
+	t.Helper()
+
+	user, err := repo.LoginUser(context.Background(), username, "hashed-password", nil)
+	if err != nil {
+		t.Fatalf("LoginUser(%q) error = %v", username, err)
+	}
+	return user
+}
+
+// completeTask начисляет пользователю points баллов за задание
+func completeTask(t *testing.T, repo service.StorageRepository, userID uuid.UUID, points int) *models.Task {
+	t.Helper()
+
+	task, err := repo.CompleteTask(context.Background(), userID, models.TaskRequest{TaskType: "daily", Points: points}, models.TaskActivityLimit{})
+	if err != nil {
+		t.Fatalf("CompleteTask(%d) error = %v", points, err)
+	}
+	return task
+}
+
+// points возвращает текущий баланс пользователя
+func points(t *testing.T, repo service.StorageRepository, userID uuid.UUID) int {
+	t.Helper()
+
+	user, err := repo.GetUserByID(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("GetUserByID() error = %v", err)
+	}
+	return user.Points
+}
+
+func testRegister(t *testing.T, repo service.StorageRepository) {
+	ctx := context.Background()
+	alice := register(t, repo, "alice")
+	if alice.ID == uuid.Nil || alice.Points != 0 || !referralCodePattern.MatchString(alice.ReferralCode) {
+		t.Errorf("registered user = %+v, want an ID, 0 points and an 8-character referral code", alice)
+	}
+
+	got, err := repo.GetUserByID(ctx, alice.ID)
+	if err != nil {
+		t.Fatalf("GetUserByID() error = %v", err)
+	}
+	if got.Username != "alice" || got.Password != "" || got.ReferralCode != alice.ReferralCode {
+		t.Errorf("GetUserByID() = %+v, want alice without the password hash", got)
+	}
+
+	if _, err := repo.LoginUser(ctx, "alice", "other-hash", nil); !errors.Is(err, models.ErrUsernameTaken) {
+		t.Errorf("LoginUser(duplicate) error = %v, want %v", err, models.ErrUsernameTaken)
+	}
+	if exists, err := repo.UsernameExists(ctx, "ALICE"); err != nil || !exists {
+		t.Errorf("UsernameExists(ALICE) = %v, %v, want true", exists, err)
+	}
+	if exists, err := repo.UsernameExists(ctx, "bob"); err != nil || exists {
+		t.Errorf("UsernameExists(bob) = %v, %v, want false", exists, err)
+	}
+	if _, err := repo.GetUserByID(ctx, uuid.New()); !errors.Is(err, models.ErrUserNotFound) {
+		t.Errorf("GetUserByID(unknown) error = %v, want %v", err, models.ErrUserNotFound)
+	}
+}
+
+func testCompleteTask(t *testing.T, repo service.StorageRepository) {
+	ctx := context.Background()
+	alice := register(t, repo, "alice")
+
+	if task := completeTask(t, repo, alice.ID, 15); task.Balance != 15 || task.UserID != alice.ID || task.Points != 15 {
+		t.Errorf("first task = %+v, want 15 points for alice and balance 15", task)
+	}
+	if task := completeTask(t, repo, alice.ID, 10); task.Balance != 25 {
+		t.Errorf("second task balance = %d, want 25", task.Balance)
+	}
+	if got := points(t, repo, alice.ID); got != 25 {
+		t.Errorf("points = %d, want 25", got)
+	}
+
+	tasks, err := repo.GetUserTasks(ctx, alice.ID, "", 10, 0)
+	if err != nil {
+		t.Fatalf("GetUserTasks() error = %v", err)
+	}
+	if len(tasks) != 2 {
+		t.Errorf("GetUserTasks() = %d tasks, want 2", len(tasks))
+	}
+
+	if _, err := repo.CompleteTask(ctx, uuid.New(), models.TaskRequest{TaskType: "daily", Points: 5}, models.TaskActivityLimit{}); !errors.Is(err, models.ErrUserNotFound) {
+		t.Errorf("CompleteTask(unknown user) error = %v, want %v", err, models.ErrUserNotFound)
+	}
+}
+
+func testCompleteTaskActivityLimit(t *testing.T, repo service.StorageRepository) {
+	ctx := context.Background()
+	alice := register(t, repo, "alice")
+	bob := register(t, repo, "bob")
+	completeTask(t, repo, alice.ID, 5)
+	reverted := completeTask(t, repo, alice.ID, 5)
+	completeTask(t, repo, bob.ID, 5)
+	if _, err := repo.RevertTask(ctx, reverted.ID); err != nil {
+		t.Fatalf("RevertTask() error = %v", err)
+	}
+	request := models.TaskRequest{TaskType: "daily", Points: 5}
+
+	// Отмененные задания учитываются, задания других пользователей и до начала окна - нет
+	for _, tt := range []struct {
+		since time.Time
+		want  int
+	}{
+		{since: time.Now().Add(-time.Hour), want: 2},
+		{since: time.Now().Add(time.Hour), want: 0},
+	} {
+		counted := -1
+		limit := models.TaskActivityLimit{Since: tt.since, Check: func(count int) error {
+			counted = count
+			return models.ErrTooManyTasks
+		}}
+		if _, err := repo.CompleteTask(ctx, alice.ID, request, limit); !errors.Is(err, models.ErrTooManyTasks) {
+			t.Fatalf("CompleteTask() error = %v, want %v from the check", err, models.ErrTooManyTasks)
+		}
+		if counted != tt.want {
+			t.Errorf("tasks since %s = %d, want %d", tt.since.Format(time.RFC3339), counted, tt.want)
+		}
+	}
+	// Отклоненное задание не сохраняется
+	if got := points(t, repo, alice.ID); got != 5 {
+		t.Errorf("alice points after rejected tasks = %d, want 5", got)
+	}
+
+	// Одновременные задания проходят проверку по очереди и не превышают лимит
+	const maxTasks = 5
+	limit := models.TaskActivityLimit{Since: time.Now().Add(-time.Hour), Check: func(count int) error {
+		if count >= maxTasks {
+			return models.ErrTooManyTasks
+		}
+		return nil
+	}}
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		accepted int
+	)
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := repo.CompleteTask(ctx, alice.ID, models.TaskRequest{TaskType: "daily", Points: 1}, limit)
+			if err != nil {
+				if !errors.Is(err, models.ErrTooManyTasks) {
+					t.Errorf("CompleteTask() error = %v", err)
+				}
+				return
+			}
+			mu.Lock()
+			accepted++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	if accepted != maxTasks-2 {
+		t.Errorf("accepted %d concurrent tasks, want %d", accepted, maxTasks-2)
+	}
+}
+
+func testLeaderboard(t *testing.T, repo service.StorageRepository) {
+	for name, balance := range map[string]int{"alice": 30, "bob": 10, "carol": 20} {
+		completeTask(t, repo, register(t, repo, name).ID, balance)
+	}
+
+	users, total, err := repo.GetLeaderboard(context.Background(), models.LeaderboardFilter{}, 2, 1)
+	if err != nil {
+		t.Fatalf("GetLeaderboard() error = %v", err)
+	}
+	if total != 3 {
+		t.Errorf("total = %d, want 3", total)
+	}
+	var names []string
+	for _, user := range users {
+		names = append(names, user.Username)
+	}
+	if strings.Join(names, ",") != "carol,bob" {
+		t.Errorf("leaderboard page = %v, want [carol bob]", names)
+	}
+}
+
+// testTiebreaker проверяет порядок пользователей с равным балансом
+func testTiebreaker(want string) func(t *testing.T, repo service.StorageRepository) {
+	return func(t *testing.T, repo service.StorageRepository) {
+		// bob зарегистрирован раньше alice, у обоих по 20 баллов
+		for _, name := range []string{"bob", "alice"} {
+			completeTask(t, repo, register(t, repo, name).ID, 20)
+		}
+		completeTask(t, repo, register(t, repo, "carol").ID, 10)
+
+		users, _, err := repo.GetLeaderboard(context.Background(), models.LeaderboardFilter{}, 10, 0)
+		if err != nil {
+			t.Fatalf("GetLeaderboard() error = %v", err)
+		}
+		var names []string
+		for _, user := range users {
+			names = append(names, user.Username)
+		}
+		if got := strings.Join(names, ","); got != want {
+			t.Errorf("leaderboard = %s, want %s", got, want)
+		}
+	}
+}
+
+func testLeaderboardFilter(t *testing.T, repo service.StorageRepository) {
+	completeTask(t, repo, register(t, repo, "alice").ID, 30)
+	bob := register(t, repo, "bob")
+	completeTask(t, repo, bob.ID, 10)
+	completeTask(t, repo, register(t, repo, "carol").ID, 20)
+
+	minPoints, maxPoints := 15, 30
+	tests := []struct {
+		name   string
+		filter models.LeaderboardFilter
+		want   string
+	}{
+		{name: "min points", filter: models.LeaderboardFilter{MinPoints: &minPoints}, want: "alice,carol"},
+		{name: "max points", filter: models.LeaderboardFilter{MaxPoints: &minPoints}, want: "bob"},
+		{name: "points range", filter: models.LeaderboardFilter{MinPoints: &minPoints, MaxPoints: &maxPoints}, want: "alice,carol"},
+		{name: "created after", filter: models.LeaderboardFilter{CreatedAfter: &bob.CreatedAt}, want: "carol"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users, total, err := repo.GetLeaderboard(context.Background(), tt.filter, 10, 0)
+			if err != nil {
+				t.Fatalf("GetLeaderboard() error = %v", err)
+			}
+			var names []string
+			for _, user := range users {
+				names = append(names, user.Username)
+			}
+			if got := strings.Join(names, ","); got != tt.want || total != len(users) {
+				t.Errorf("leaderboard = %s (total %d), want %s", got, total, tt.want)
+			}
+		})
+	}
+}
+
+func testLookup(t *testing.T, repo service.StorageRepository) {
+	ctx := context.Background()
+	alice := register(t, repo, "alice")
+	completeTask(t, repo, alice.ID, 5)
+
+	profile, err := repo.GetUserByUsername(ctx, "ALICE")
+	if err != nil {
+		t.Fatalf("GetUserByUsername(ALICE) error = %v", err)
+	}
+	if profile.ID != alice.ID || profile.Username != "alice" || profile.Points != 5 {
+		t.Errorf("GetUserByUsername(ALICE) = %+v, want alice with 5 points", profile)
+	}
+	if _, err := repo.GetUserByUsername(ctx, "bob"); !errors.Is(err, models.ErrUserNotFound) {
+		t.Errorf("GetUserByUsername(bob) error = %v, want %v", err, models.ErrUserNotFound)
+	}
+
+	byCode, err := repo.GetUserByReferralCode(ctx, strings.ToLower(alice.ReferralCode))
+	if err != nil {
+		t.Fatalf("GetUserByReferralCode() error = %v", err)
+	}
+	if byCode.ID != alice.ID {
+		t.Errorf("GetUserByReferralCode() = %s, want %s", byCode.ID, alice.ID)
+	}
+	if _, err := repo.GetUserByReferralCode(ctx, "ZZZZZZZZ"); !errors.Is(err, models.ErrUserNotFound) {
+		t.Errorf("GetUserByReferralCode(unknown) error = %v, want %v", err, models.ErrUserNotFound)
+	}
+}
+
+func testUsernameCaseInsensitive(t *testing.T, repo service.StorageRepository) {
+	ctx := context.Background()
+	alice := register(t, repo, "Alice")
+	bob := register(t, repo, "bob")
+
+	for _, username := range []string{"alice", "ALICE"} {
+		if _, err := repo.LoginUser(ctx, username, "other-hash", nil); !errors.Is(err, models.ErrUsernameTaken) {
+			t.Errorf("LoginUser(%q) after Alice error = %v, want %v", username, err, models.ErrUsernameTaken)
+		}
+	}
+	if id, _, err := repo.GetCredentials(ctx, "alice"); err != nil || id != alice.ID {
+		t.Errorf("GetCredentials(alice) = %s, %v, want %s", id, err, alice.ID)
+	}
+
+	if err := repo.UpdateUsername(ctx, bob.ID, "aLiCe", 0); !errors.Is(err, models.ErrUsernameTaken) {
+		t.Errorf("UpdateUsername(bob, aLiCe) error = %v, want %v", err, models.ErrUsernameTaken)
+	}
+	// Смена регистра собственного имени разрешена
+	if err := repo.UpdateUsername(ctx, alice.ID, "alice", 0); err != nil {
+		t.Fatalf("UpdateUsername(Alice, alice) error = %v", err)
+	}
+	if got, err := repo.GetUserByID(ctx, alice.ID); err != nil || got.Username != "alice" {
+		t.Errorf("GetUserByID() = %+v, %v, want username alice", got, err)
+	}
+}
+
+func testRegenerateReferralCode(t *testing.T, repo service.StorageRepository) {
+	ctx := context.Background()
+	alice := register(t, repo, "alice")
+	bob := register(t, repo, "bob")
+
+	code, err := repo.RegenerateReferralCode(ctx, alice.ID)
+	if err != nil {
+		t.Fatalf("RegenerateReferralCode() error = %v", err)
+	}
+	if code == alice.ReferralCode || !referralCodePattern.MatchString(code) {
+		t.Errorf("new referral code = %q, want a new 8-character code instead of %q", code, alice.ReferralCode)
+	}
+
+	// Прежний код сразу перестает находить пользователя, новый находит
+	if _, err := repo.GetUserByReferralCode(ctx, alice.ReferralCode); !errors.Is(err, models.ErrUserNotFound) {
+		t.Errorf("GetUserByReferralCode(old) error = %v, want %v", err, models.ErrUserNotFound)
+	}
+	if found, err := repo.GetUserByReferralCode(ctx, code); err != nil || found.ID != alice.ID {
+		t.Errorf("GetUserByReferralCode(new) = %+v, %v, want alice", found, err)
+	}
+	if got, err := repo.GetUserByID(ctx, alice.ID); err != nil || got.ReferralCode != code {
+		t.Errorf("GetUserByID() = %+v, %v, want referral code %q", got, err, code)
+	}
+	// Код другого пользователя не меняется
+	if found, err := repo.GetUserByReferralCode(ctx, bob.ReferralCode); err != nil || found.ID != bob.ID {
+		t.Errorf("GetUserByReferralCode(bob) = %+v, %v, want bob", found, err)
+	}
+
+	if _, err := repo.RegenerateReferralCode(ctx, uuid.New()); !errors.Is(err, models.ErrUserNotFound) {
+		t.Errorf("RegenerateReferralCode(unknown) error = %v, want %v", err, models.ErrUserNotFound)
+	}
+}
+
+func testReferrer(t *testing.T, repo service.StorageRepository) {
+	ctx := context.Background()
+	alice := register(t, repo, "alice")
+	bob := register(t, repo, "bob")
+	carol := register(t, repo, "carol")
+
+	if _, _, err := repo.AddReferrer(ctx, bob.ID, alice.ID); err != nil {
+		t.Fatalf("AddReferrer(bob, alice) error = %v", err)
+	}
+	user, credits, err := repo.AddReferrer(ctx, carol.ID, bob.ID)
+	if err != nil {
+		t.Fatalf("AddReferrer(carol, bob) error = %v", err)
+	}
+	if user.ReferrerID == nil || *user.ReferrerID != bob.ID {
+		t.Errorf("carol referrer = %v, want %s", user.ReferrerID, bob.ID)
+	}
+	if len(credits) != 2 || credits[0] != (models.ReferralCredit{UserID: bob.ID, Level: 1, Points: 10, Balance: 10}) ||
+		credits[1] != (models.ReferralCredit{UserID: alice.ID, Level: 2, Points: 5, Balance: 15}) {
+		t.Errorf("credits = %+v, want 10 to bob and 5 to alice", credits)
+	}
+
+	chain, err := repo.GetReferrerChain(ctx, carol.ID, 5)
+	if err != nil {
+		t.Fatalf("GetReferrerChain() error = %v", err)
+	}
+	if len(chain) != 2 || chain[0].ID != bob.ID || chain[0].Level != 1 || chain[1].ID != alice.ID || chain[1].Level != 2 {
+		t.Errorf("chain = %+v, want bob then alice", chain)
+	}
+
+	// Повтор с тем же реферером и смена реферера не начисляют бонусы повторно
+	if _, _, err := repo.AddReferrer(ctx, carol.ID, bob.ID); !errors.Is(err, models.ErrReferrerUnchanged) {
+		t.Errorf("AddReferrer(carol, bob) again error = %v, want %v", err, models.ErrReferrerUnchanged)
+	}
+	if _, _, err := repo.AddReferrer(ctx, carol.ID, alice.ID); !errors.Is(err, models.ErrReferrerAlreadySet) {
+		t.Errorf("AddReferrer(carol, alice) error = %v, want %v", err, models.ErrReferrerAlreadySet)
+	}
+	if got := points(t, repo, bob.ID); got != 10 {
+		t.Errorf("bob points = %d, want 10", got)
+	}
+	if got := points(t, repo, alice.ID); got != 15 {
+		t.Errorf("alice points = %d, want 15", got)
+	}
+	if _, _, err := repo.AddReferrer(ctx, alice.ID, uuid.New()); !errors.Is(err, models.ErrReferrerNotFound) {
+		t.Errorf("AddReferrer(unknown referrer) error = %v, want %v", err, models.ErrReferrerNotFound)
+	}
+	if _, _, err := repo.AddReferrer(ctx, uuid.New(), alice.ID); !errors.Is(err, models.ErrUserNotFound) {
+		t.Errorf("AddReferrer(unknown user) error = %v, want %v", err, models.ErrUserNotFound)
+	}
+	dave := register(t, repo, "dave")
+	if _, _, err := repo.AddReferrer(ctx, dave.ID, dave.ID); !errors.Is(err, models.ErrSelfReferral) {
+		t.Errorf("AddReferrer(dave, dave) error = %v, want %v", err, models.ErrSelfReferral)
+	}
+	if user, err := repo.GetUserByID(ctx, dave.ID); err != nil || user.ReferrerID != nil {
+		t.Errorf("dave after self-referral = %+v, %v, want no referrer", user, err)
+	}
+}
+
+func testRemoveReferrer(t *testing.T, repo service.StorageRepository) {
+	ctx := context.Background()
+	alice := register(t, repo, "alice")
+	bob := register(t, repo, "bob")
+	carol := register(t, repo, "carol")
+	dave := register(t, repo, "dave")
+
+	if _, _, err := repo.AddReferrer(ctx, bob.ID, alice.ID); err != nil {
+		t.Fatalf("AddReferrer(bob, alice) error = %v", err)
+	}
+	if _, _, err := repo.AddReferrer(ctx, carol.ID, bob.ID); err != nil {
+		t.Fatalf("AddReferrer(carol, bob) error = %v", err)
+	}
+	// Bob тратит часть бонуса, поэтому отмена списывает только остаток
+	if _, err := repo.TransferPoints(ctx, bob.ID, dave.ID, 7); err != nil {
+		t.Fatalf("TransferPoints(bob, dave) error = %v", err)
+	}
+
+	referrerID, reversals, err := repo.RemoveReferrer(ctx, carol.ID)
+	if err != nil {
+		t.Fatalf("RemoveReferrer(carol) error = %v", err)
+	}
+	if referrerID != bob.ID {
+		t.Errorf("RemoveReferrer(carol) referrer = %s, want %s", referrerID, bob.ID)
+	}
+	if len(reversals) != 2 || reversals[0] != (models.ReferralCredit{UserID: bob.ID, Level: 1, Points: -3, Balance: 0}) ||
+		reversals[1] != (models.ReferralCredit{UserID: alice.ID, Level: 2, Points: -5, Balance: 10}) {
+		t.Errorf("reversals = %+v, want -3 from bob and -5 from alice", reversals)
+	}
+	if got := points(t, repo, bob.ID); got != 0 {
+		t.Errorf("bob points = %d, want 0", got)
+	}
+	if got := points(t, repo, alice.ID); got != 10 {
+		t.Errorf("alice points = %d, want 10", got)
+	}
+	if got := points(t, repo, dave.ID); got != 7 {
+		t.Errorf("dave points = %d, want 7", got)
+	}
+
+	got, err := repo.GetUserByID(ctx, carol.ID)
+	if err != nil {
+		t.Fatalf("GetUserByID(carol) error = %v", err)
+	}
+	if got.ReferrerID != nil {
+		t.Errorf("carol referrer = %s, want none", got.ReferrerID)
+	}
+	chain, err := repo.GetReferrerChain(ctx, carol.ID, 5)
+	if err != nil {
+		t.Fatalf("GetReferrerChain(carol) error = %v", err)
+	}
+	if len(chain) != 0 {
+		t.Errorf("carol chain = %+v, want empty", chain)
+	}
+
+	// Связь bob с alice не затрагивается
+	got, err = repo.GetUserByID(ctx, bob.ID)
+	if err != nil {
+		t.Fatalf("GetUserByID(bob) error = %v", err)
+	}
+	if got.ReferrerID == nil || *got.ReferrerID != alice.ID {
+		t.Errorf("bob referrer = %v, want %s", got.ReferrerID, alice.ID)
+	}
+
+	if _, _, err := repo.RemoveReferrer(ctx, carol.ID); !errors.Is(err, models.ErrReferrerNotSet) {
+		t.Errorf("RemoveReferrer(carol) again error = %v, want %v", err, models.ErrReferrerNotSet)
+	}
+	if _, _, err := repo.RemoveReferrer(ctx, uuid.New()); !errors.Is(err, models.ErrUserNotFound) {
+		t.Errorf("RemoveReferrer(unknown) error = %v, want %v", err, models.ErrUserNotFound)
+	}
+	if got := points(t, repo, alice.ID); got != 10 {
+		t.Errorf("alice points after failed removals = %d, want 10", got)
+	}
+
+	// Удаленного реферера нельзя указать снова, другого - можно
+	if _, _, err := repo.AddReferrer(ctx, carol.ID, bob.ID); !errors.Is(err, models.ErrReferrerRemoved) {
+		t.Errorf("AddReferrer(carol, bob) after removal error = %v, want %v", err, models.ErrReferrerRemoved)
+	}
+	if got := points(t, repo, bob.ID); got != 0 {
+		t.Errorf("bob points after rejected relink = %d, want 0", got)
+	}
+	if _, _, err := repo.AddReferrer(ctx, carol.ID, dave.ID); err != nil {
+		t.Fatalf("AddReferrer(carol, dave) error = %v", err)
+	}
+	if got := points(t, repo, dave.ID); got != 17 {
+		t.Errorf("dave points = %d, want 17", got)
+	}
+}
+
+func testTransferPoints(t *testing.T, repo service.StorageRepository) {
+	ctx := context.Background()
+	alice := register(t, repo, "alice")
+	bob := register(t, repo, "bob")
+	completeTask(t, repo, alice.ID, 50)
+
+	balance, err := repo.TransferPoints(ctx, alice.ID, bob.ID, 20)
+	if err != nil {
+		t.Fatalf("TransferPoints() error = %v", err)
+	}
+	if balance != 30 || points(t, repo, bob.ID) != 20 {
+		t.Errorf("balances after transfer = %d and %d, want 30 and 20", balance, points(t, repo, bob.ID))
+	}
+
+	if _, err := repo.TransferPoints(ctx, alice.ID, bob.ID, 31); !errors.Is(err, models.ErrInsufficientPoints) {
+		t.Errorf("TransferPoints(31) error = %v, want %v", err, models.ErrInsufficientPoints)
+	}
+	if _, err := repo.TransferPoints(ctx, alice.ID, uuid.New(), 5); !errors.Is(err, models.ErrUserNotFound) {
+		t.Errorf("TransferPoints(unknown recipient) error = %v, want %v", err, models.ErrUserNotFound)
+	}
+	if got := points(t, repo, alice.ID); got != 30 {
+		t.Errorf("alice points after failed transfers = %d, want 30", got)
+	}
+}
+
+func testAdjustPoints(t *testing.T, repo service.StorageRepository) {
+	ctx := context.Background()
+	admin := register(t, repo, "admin_user")
+	alice := register(t, repo, "alice")
+	completeTask(t, repo, alice.ID, 30)
+
+	adjustment, err := repo.AdjustPoints(ctx, admin.ID, alice.ID, -50, "fraud")
+	if err != nil {
+		t.Fatalf("AdjustPoints() error = %v", err)
+	}
+	if adjustment.AppliedDelta != -30 || adjustment.Balance != 0 || adjustment.AdminID != admin.ID || adjustment.Reason != "fraud" {
+		t.Errorf("adjustment = %+v, want applied -30 and balance floored at 0", adjustment)
+	}
+	if got := points(t, repo, alice.ID); got != 0 {
+		t.Errorf("points = %d, want 0", got)
+	}
+	if _, err := repo.AdjustPoints(ctx, admin.ID, uuid.New(), 5, "bonus"); !errors.Is(err, models.ErrUserNotFound) {
+		t.Errorf("AdjustPoints(unknown user) error = %v, want %v", err, models.ErrUserNotFound)
+	}
+}
+
+func testRevertTask(t *testing.T, repo service.StorageRepository) {
+	ctx := context.Background()
+	alice := register(t, repo, "alice")
+	completeTask(t, repo, alice.ID, 10)
+	task := completeTask(t, repo, alice.ID, 25)
+
+	reverted, err := repo.RevertTask(ctx, task.ID)
+	if err != nil {
+		t.Fatalf("RevertTask() error = %v", err)
+	}
+	if reverted.ID != task.ID || reverted.DeletedAt == nil {
+		t.Errorf("RevertTask() = %+v, want %s marked deleted", reverted, task.ID)
+	}
+	if got := points(t, repo, alice.ID); got != 10 {
+		t.Errorf("points = %d, want 10 after the revert", got)
+	}
+
+	if _, err := repo.RevertTask(ctx, task.ID); !errors.Is(err, models.ErrTaskAlreadyReverted) {
+		t.Errorf("second RevertTask() error = %v, want %v", err, models.ErrTaskAlreadyReverted)
+	}
+	if _, err := repo.RevertTask(ctx, uuid.New()); !errors.Is(err, models.ErrTaskNotFound) {
+		t.Errorf("RevertTask(unknown) error = %v, want %v", err, models.ErrTaskNotFound)
+	}
+}
+
+func testDeleteUser(t *testing.T, repo service.StorageRepository) {
+	ctx := context.Background()
+	alice := register(t, repo, "alice")
+	bob := register(t, repo, "bob")
+	if _, _, err := repo.AddReferrer(ctx, bob.ID, alice.ID); err != nil {
+		t.Fatalf("AddReferrer() error = %v", err)
+	}
+
+	if err := repo.DeleteUser(ctx, alice.ID); err != nil {
+		t.Fatalf("DeleteUser() error = %v", err)
+	}
+	if _, err := repo.GetUserByID(ctx, alice.ID); !errors.Is(err, models.ErrUserNotFound) {
+		t.Errorf("GetUserByID(deleted) error = %v, want %v", err, models.ErrUserNotFound)
+	}
+	if err := repo.DeleteUser(ctx, alice.ID); !errors.Is(err, models.ErrUserNotFound) {
+		t.Errorf("second DeleteUser() error = %v, want %v", err, models.ErrUserNotFound)
+	}
+
+	// Приглашенный пользователь остается без реферера
+	got, err := repo.GetUserByID(ctx, bob.ID)
+	if err != nil {
+		t.Fatalf("GetUserByID(bob) error = %v", err)
+	}
+	if got.ReferrerID != nil {
+		t.Errorf("bob referrer = %s, want none after the referrer is deleted", got.ReferrerID)
+	}
+}
+
+func testAudit(t *testing.T, repo service.StorageRepository) {
+	ctx := context.Background()
+	actor, target := uuid.New(), uuid.New()
+
+	if err := repo.Record(ctx, actor, models.AuditPointsAdjusted, target, map[string]any{"reason": "fraud"}); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	if err := repo.Record(ctx, uuid.New(), models.AuditUserDeleted, target, nil); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+
+	entries, total, err := repo.ListAudit(ctx, models.AuditFilter{ActorID: &actor}, 10, 0)
+	if err != nil {
+		t.Fatalf("ListAudit() error = %v", err)
+	}
+	if total != 1 || len(entries) != 1 {
+		t.Fatalf("ListAudit(actor) = %d entries of %d, want 1 of 1", len(entries), total)
+	}
+	if got := entries[0]; got.Action != models.AuditPointsAdjusted || got.TargetID != target || got.Metadata["reason"] != "fraud" {
+		t.Errorf("audit entry = %+v, want the points adjustment with its reason", got)
+	}
+
+	if _, total, err := repo.ListAudit(ctx, models.AuditFilter{Action: models.AuditUserDeleted}, 10, 0); err != nil || total != 1 {
+		t.Errorf("ListAudit(action) total = %d, %v, want 1", total, err)
+	}
+}
+
+func testGlobalStats(t *testing.T, repo service.StorageRepository) {
+	ctx := context.Background()
+	alice := register(t, repo, "alice")
+	bob := register(t, repo, "bob")
+	carol := register(t, repo, "carol")
+
+	completeTask(t, repo, alice.ID, 15)
+	reverted := completeTask(t, repo, bob.ID, 10)
+	completeTask(t, repo, bob.ID, 5)
+	if _, err := repo.RevertTask(ctx, reverted.ID); err != nil {
+		t.Fatalf("RevertTask() error = %v", err)
+	}
+	if _, _, err := repo.AddReferrer(ctx, carol.ID, alice.ID); err != nil {
+		t.Fatalf("AddReferrer() error = %v", err)
+	}
+
+	stats, err := repo.GetGlobalStats(ctx)
+	if err != nil {
+		t.Fatalf("GetGlobalStats() error = %v", err)
+	}
+	wantPoints := int64(points(t, repo, alice.ID) + points(t, repo, bob.ID) + points(t, repo, carol.ID))
+	if stats.TotalUsers != 3 || stats.TotalPoints != wantPoints || stats.TotalTasks != 2 || stats.UsersWithReferrer != 1 {
+		t.Errorf("GetGlobalStats() = %+v, want 3 users, %d points, 2 tasks without the reverted one and 1 referred user", stats, wantPoints)
+	}
+	if stats.CalculatedAt.IsZero() {
+		t.Error("CalculatedAt is zero")
+	}
+}
+
+func testProfileVersion(t *testing.T, repo service.StorageRepository) {
+	ctx := context.Background()
+	alice := register(t, repo, "alice")
+	if alice.Version != 1 {
+		t.Errorf("registered version = %d, want 1", alice.Version)
+	}
+
+	if err := repo.UpdateUsername(ctx, alice.ID, "alice2", 1); err != nil {
+		t.Fatalf("UpdateUsername(version 1) error = %v", err)
+	}
+	// Изменение на основе устаревшей версии отклоняется и не применяется
+	if err := repo.UpdateUsername(ctx, alice.ID, "alice3", 1); !errors.Is(err, models.ErrConflict) {
+		t.Errorf("UpdateUsername(stale version) error = %v, want %v", err, models.ErrConflict)
+	}
+	// Без версии изменение применяется без проверки
+	if err := repo.UpdateUsername(ctx, alice.ID, "alice4", 0); err != nil {
+		t.Fatalf("UpdateUsername(no version) error = %v", err)
+	}
+
+	got, err := repo.GetUserByID(ctx, alice.ID)
+	if err != nil {
+		t.Fatalf("GetUserByID() error = %v", err)
+	}
+	if got.Username != "alice4" || got.Version != 3 {
+		t.Errorf("user = %q version %d, want alice4 version 3", got.Username, got.Version)
+	}
+}
+
+func testEmail(t *testing.T, repo service.StorageRepository) {
+	ctx := context.Background()
+	email := "Alice@Example.com"
+	alice, err := repo.LoginUser(ctx, "alice", "hashed-password", &email)
+	if err != nil {
+		t.Fatalf("LoginUser(alice) error = %v", err)
+	}
+	if alice.Email == nil || *alice.Email != email {
+		t.Errorf("registered email = %v, want %q", alice.Email, email)
+	}
+
+	// Адрес уникален без учета регистра
+	taken := "alice@example.com"
+	if _, err := repo.LoginUser(ctx, "bob", "hashed-password", &taken); !errors.Is(err, models.ErrEmailTaken) {
+		t.Errorf("LoginUser(taken email) error = %v, want %v", err, models.ErrEmailTaken)
+	}
+	// Пользователи без адреса не конфликтуют друг с другом
+	register(t, repo, "carol")
+	register(t, repo, "dave")
+
+	if err := repo.VerifyEmail(ctx, alice.ID, "other@example.com", time.Now()); !errors.Is(err, models.ErrInvalidVerificationToken) {
+		t.Errorf("VerifyEmail(other address) error = %v, want %v", err, models.ErrInvalidVerificationToken)
+	}
+	if err := repo.VerifyEmail(ctx, uuid.New(), email, time.Now()); !errors.Is(err, models.ErrInvalidVerificationToken) {
+		t.Errorf("VerifyEmail(unknown user) error = %v, want %v", err, models.ErrInvalidVerificationToken)
+	}
+
+	verifiedAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	if err := repo.VerifyEmail(ctx, alice.ID, taken, verifiedAt); err != nil {
+		t.Fatalf("VerifyEmail() error = %v", err)
+	}
+	// Повторное подтверждение сохраняет время первого
+	if err := repo.VerifyEmail(ctx, alice.ID, email, verifiedAt.Add(time.Hour)); err != nil {
+		t.Fatalf("VerifyEmail(again) error = %v", err)
+	}
+	got, err := repo.GetUserByID(ctx, alice.ID)
+	if err != nil {
+		t.Fatalf("GetUserByID() error = %v", err)
+	}
+	if got.Email == nil || *got.Email != email || got.EmailVerifiedAt == nil || !got.EmailVerifiedAt.Equal(verifiedAt) {
+		t.Errorf("GetUserByID() email = %v verified at %v, want %q verified at %s", got.Email, got.EmailVerifiedAt, email, verifiedAt)
+	}
+}
+
+func testPasswordReset(t *testing.T, repo service.StorageRepository) {
+	ctx := context.Background()
+	email := "alice@example.com"
+	alice, err := repo.LoginUser(ctx, "alice", "hashed-password", &email)
+	if err != nil {
+		t.Fatalf("LoginUser(alice) error = %v", err)
+	}
+
+	for _, login := range []string{"ALICE", "Alice@Example.com"} {
+		user, err := repo.GetUserByLogin(ctx, login)
+		if err != nil || user.ID != alice.ID || user.Username != "alice" {
+			t.Errorf("GetUserByLogin(%q) = %+v, %v, want alice", login, user, err)
+		}
+	}
+	if _, err := repo.GetUserByLogin(ctx, "bob"); !errors.Is(err, models.ErrUserNotFound) {
+		t.Errorf("GetUserByLogin(unknown) error = %v, want %v", err, models.ErrUserNotFound)
+	}
+
+	now := time.Now()
+	for _, hash := range []string{"token-1", "token-2"} {
+		if err := repo.CreatePasswordResetToken(ctx, alice.ID, hash, now.Add(time.Hour)); err != nil {
+			t.Fatalf("CreatePasswordResetToken(%s) error = %v", hash, err)
+		}
+	}
+	if err := repo.CreatePasswordResetToken(ctx, alice.ID, "expired", now.Add(-time.Minute)); err != nil {
+		t.Fatalf("CreatePasswordResetToken(expired) error = %v", err)
+	}
+
+	if userID, err := repo.GetPasswordResetToken(ctx, "token-1", now); err != nil || userID != alice.ID {
+		t.Errorf("GetPasswordResetToken() = %s, %v, want %s", userID, err, alice.ID)
+	}
+	for _, hash := range []string{"expired", "unknown"} {
+		if _, err := repo.GetPasswordResetToken(ctx, hash, now); !errors.Is(err, models.ErrInvalidResetToken) {
+			t.Errorf("GetPasswordResetToken(%s) error = %v, want %v", hash, err, models.ErrInvalidResetToken)
+		}
+	}
+
+	if userID, err := repo.ResetPassword(ctx, "token-1", "new-hash", 2, now); err != nil || userID != alice.ID {
+		t.Fatalf("ResetPassword() = %s, %v, want %s", userID, err, alice.ID)
+	}
+	if hash, err := repo.GetPasswordHash(ctx, alice.ID); err != nil || hash != "new-hash" {
+		t.Errorf("GetPasswordHash() = %q, %v, want new-hash", hash, err)
+	}
+	if history, err := repo.GetPasswordHistory(ctx, alice.ID, 2); err != nil || len(history) != 1 || history[0] != "hashed-password" {
+		t.Errorf("GetPasswordHistory() = %v, %v, want the previous hash", history, err)
+	}
+
+	// Использованный токен и остальные токены пользователя больше не действуют
+	for _, hash := range []string{"token-1", "token-2"} {
+		if _, err := repo.ResetPassword(ctx, hash, "other-hash", 2, now); !errors.Is(err, models.ErrInvalidResetToken) {
+			t.Errorf("ResetPassword(%s) after reset error = %v, want %v", hash, err, models.ErrInvalidResetToken)
+		}
+	}
+}
+
+// testUserRank проверяет, что место пользователя совпадает с позицией в таблице лидеров want
+func testUserRank(want string) func(t *testing.T, repo service.StorageRepository) {
+	return func(t *testing.T, repo service.StorageRepository) {
+		ctx := context.Background()
+		// bob зарегистрирован раньше alice, у обоих по 20 баллов
+		users := make(map[string]uuid.UUID)
+		for _, name := range []string{"bob", "alice", "carol"} {
+			users[name] = register(t, repo, name).ID
+		}
+		completeTask(t, repo, users["bob"], 20)
+		completeTask(t, repo, users["alice"], 20)
+		completeTask(t, repo, users["carol"], 10)
+
+		for i, name := range strings.Split(want, ",") {
+			rank, err := repo.GetUserRank(ctx, users[name])
+			if err != nil {
+				t.Fatalf("GetUserRank(%s) error = %v", name, err)
+			}
+			if rank != i+1 {
+				t.Errorf("%s rank = %d, want %d", name, rank, i+1)
+			}
+		}
+		if _, err := repo.GetUserRank(ctx, uuid.New()); !errors.Is(err, models.ErrUserNotFound) {
+			t.Errorf("GetUserRank(unknown) error = %v, want %v", err, models.ErrUserNotFound)
+		}
+	}
+}
+
+func testUserStanding(t *testing.T, repo service.StorageRepository) {
+	ctx := context.Background()
+	users := map[string]uuid.UUID{"alice": register(t, repo, "alice").ID}
+
+	// Единственного пользователя никто не опережает
+	ahead, total, err := repo.GetUserStanding(ctx, users["alice"])
+	if err != nil {
+		t.Fatalf("GetUserStanding(alice) error = %v", err)
+	}
+	if ahead != 0 || total != 1 {
+		t.Errorf("alice standing = %d of %d, want 0 of 1", ahead, total)
+	}
+
+	for _, name := range []string{"bob", "carol", "dave", "eve"} {
+		users[name] = register(t, repo, name).ID
+	}
+	completeTask(t, repo, users["alice"], 50)
+	completeTask(t, repo, users["bob"], 30)
+	completeTask(t, repo, users["carol"], 30)
+	completeTask(t, repo, users["dave"], 10)
+
+	// Пользователи с равным балансом друг друга не опережают
+	for name, want := range map[string]int{"alice": 0, "bob": 1, "carol": 1, "dave": 3, "eve": 4} {
+		ahead, total, err := repo.GetUserStanding(ctx, users[name])
+		if err != nil {
+			t.Fatalf("GetUserStanding(%s) error = %v", name, err)
+		}
+		if ahead != want || total != 5 {
+			t.Errorf("%s standing = %d of %d, want %d of 5", name, ahead, total, want)
+		}
+	}
+	if _, _, err := repo.GetUserStanding(ctx, uuid.New()); !errors.Is(err, models.ErrUserNotFound) {
+		t.Errorf("GetUserStanding(unknown) error = %v, want %v", err, models.ErrUserNotFound)
+	}
+}
+
+// testPointsToNextRank проверяет разрыв до места выше для пользователей с равным балансом:
+// first стоит выше second по правилу разрешения равенства
+func testPointsToNextRank(first, second string) func(t *testing.T, repo service.StorageRepository) {
+	return func(t *testing.T, repo service.StorageRepository) {
+		ctx := context.Background()
+		users := make(map[string]uuid.UUID)
+		for _, name := range []string{"bob", "alice", "carol", "dave"} {
+			users[name] = register(t, repo, name).ID
+		}
+		completeTask(t, repo, users["bob"], 20)
+		completeTask(t, repo, users["alice"], 20)
+		completeTask(t, repo, users["carol"], 10)
+
+		// Первому месту обгонять некого; при равном балансе нужен один балл сверх;
+		// dave без баллов обгоняет carol с 10
+		for name, want := range map[string]int{first: 0, second: 1, "carol": 11, "dave": 11} {
+			got, err := repo.GetPointsToNextRank(ctx, users[name])
+			if err != nil {
+				t.Fatalf("GetPointsToNextRank(%s) error = %v", name, err)
+			}
+			if got != want {
+				t.Errorf("%s points to next rank = %d, want %d", name, got, want)
+			}
+		}
+		if _, err := repo.GetPointsToNextRank(ctx, uuid.New()); !errors.Is(err, models.ErrUserNotFound) {
+			t.Errorf("GetPointsToNextRank(unknown) error = %v, want %v", err, models.ErrUserNotFound)
+		}
+	}
+}
+
+func testCountReferrals(t *testing.T, repo service.StorageRepository) {
+	ctx := context.Background()
+	alice := register(t, repo, "alice")
+	bob := register(t, repo, "bob")
+	carol := register(t, repo, "carol")
+
+	count, err := repo.CountReferrals(ctx, alice.ID)
+	if err != nil || count != 0 {
+		t.Fatalf("CountReferrals() before referrals = %d, %v, want 0", count, err)
+	}
+	for _, user := range []*models.User{bob, carol} {
+		if _, _, err := repo.AddReferrer(ctx, user.ID, alice.ID); err != nil {
+			t.Fatalf("AddReferrer(%s, alice) error = %v", user.Username, err)
+		}
+	}
+
+	// Учитываются только прямые рефералы
+	dave := register(t, repo, "dave")
+	if _, _, err := repo.AddReferrer(ctx, dave.ID, bob.ID); err != nil {
+		t.Fatalf("AddReferrer(dave, bob) error = %v", err)
+	}
+	if count, err := repo.CountReferrals(ctx, alice.ID); err != nil || count != 2 {
+		t.Errorf("CountReferrals(alice) = %d, %v, want 2", count, err)
+	}
+}
+
+func testListUsers(t *testing.T, repo service.StorageRepository) {
+	ctx := context.Background()
+	// Регистрация в порядке alice, Bob_1, carol, bobby; баллы 20, 30, 10, 0
+	alice := register(t, repo, "alice")
+	completeTask(t, repo, alice.ID, 20)
+	bob := register(t, repo, "Bob_1")
+	completeTask(t, repo, bob.ID, 30)
+	carol := register(t, repo, "carol")
+	completeTask(t, repo, carol.ID, 10)
+	register(t, repo, "bobby")
+	if _, _, err := repo.AddReferrer(ctx, carol.ID, alice.ID); err != nil {
+		t.Fatalf("AddReferrer(carol, alice) error = %v", err)
+	}
+
+	yes, no := true, false
+	tests := []struct {
+		name      string
+		filter    models.UserListFilter
+		limit     int
+		offset    int
+		want      string
+		wantTotal int
+	}{
+		{name: "newest first by default", limit: 10, want: "bobby,carol,Bob_1,alice", wantTotal: 4},
+		{name: "oldest first", filter: models.UserListFilter{Asc: true}, limit: 10, want: "alice,Bob_1,carol,bobby", wantTotal: 4},
+		{name: "most points first", filter: models.UserListFilter{Sort: models.UserSortPoints}, limit: 10, want: "Bob_1,alice,carol,bobby", wantTotal: 4},
+		{name: "fewest points first", filter: models.UserListFilter{Sort: models.UserSortPoints, Asc: true}, limit: 10, want: "bobby,carol,alice,Bob_1", wantTotal: 4},
+		{name: "page", filter: models.UserListFilter{Sort: models.UserSortPoints}, limit: 2, offset: 1, want: "alice,carol", wantTotal: 4},
+		{name: "search ignores case", filter: models.UserListFilter{Search: "BOB"}, limit: 10, want: "bobby,Bob_1", wantTotal: 2},
+		{name: "search wildcard is literal", filter: models.UserListFilter{Search: "_"}, limit: 10, want: "Bob_1", wantTotal: 1},
+		{name: "search percent is literal", filter: models.UserListFilter{Search: "%"}, limit: 10, want: "", wantTotal: 0},
+		{name: "with referrer", filter: models.UserListFilter{HasReferrer: &yes}, limit: 10, want: "carol", wantTotal: 1},
+		{name: "without referrer", filter: models.UserListFilter{HasReferrer: &no, Asc: true}, limit: 10, want: "alice,Bob_1,bobby", wantTotal: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users, total, err := repo.ListUsers(ctx, tt.filter, tt.limit, tt.offset)
+			if err != nil {
+				t.Fatalf("ListUsers() error = %v", err)
+			}
+			var names []string
+			for _, user := range users {
+				names = append(names, user.Username)
+				if user.Password != "" {
+					t.Errorf("%s password hash returned", user.Username)
+				}
+			}
+			if got := strings.Join(names, ","); got != tt.want || total != tt.wantTotal {
+				t.Errorf("ListUsers() = [%s] of %d, want [%s] of %d", got, total, tt.want, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func testGetUsersByIDs(t *testing.T, repo service.StorageRepository) {
+	ctx := context.Background()
+	alice := register(t, repo, "alice")
+	bob := register(t, repo, "bob")
+	carol := register(t, repo, "carol")
+	if _, _, err := repo.AddReferrer(ctx, bob.ID, alice.ID); err != nil {
+		t.Fatalf("AddReferrer(bob, alice) error = %v", err)
+	}
+	completeTask(t, repo, alice.ID, 5)
+
+	// Несуществующие и повторяющиеся ID не мешают найти остальных
+	missing := uuid.New()
+	users, err := repo.GetUsersByIDs(ctx, []uuid.UUID{alice.ID, missing, bob.ID, alice.ID})
+	if err != nil {
+		t.Fatalf("GetUsersByIDs() error = %v", err)
+	}
+	if len(users) != 2 || users[alice.ID] == nil || users[bob.ID] == nil {
+		t.Fatalf("GetUsersByIDs() = %v, want alice and bob only", users)
+	}
+	if _, ok := users[missing]; ok {
+		t.Errorf("GetUsersByIDs() contains the missing ID %s", missing)
+	}
+	if _, ok := users[carol.ID]; ok {
+		t.Errorf("GetUsersByIDs() contains carol, who was not requested")
+	}
+
+	got := users[alice.ID]
+	if got.Username != "alice" || got.Points != 5 || got.ReferralCode != alice.ReferralCode || got.Password != "" {
+		t.Errorf("alice = %+v, want alice with 5 points and no password hash", got)
+	}
+	if got := users[bob.ID]; got.ReferrerID == nil || *got.ReferrerID != alice.ID {
+		t.Errorf("bob referrer = %v, want %s", got.ReferrerID, alice.ID)
+	}
+
+	for _, ids := range [][]uuid.UUID{nil, {missing}} {
+		users, err := repo.GetUsersByIDs(ctx, ids)
+		if err != nil || users == nil || len(users) != 0 {
+			t.Errorf("GetUsersByIDs(%v) = %v, %v, want an empty map", ids, users, err)
+		}
+	}
+}
+
+func testPointsExpiry(t *testing.T, repo service.StorageRepository) {
+	ctx := context.Background()
+	expiry, ok := repo.(service.PointsExpiryRepository)
+	if !ok {
+		t.Fatalf("%T does not implement service.PointsExpiryRepository", repo)
+	}
+	alice := register(t, repo, "alice")
+	bob := register(t, repo, "bob")
+	carol := register(t, repo, "carol")
+	completeTask(t, repo, alice.ID, 50)
+	completeTask(t, repo, bob.ID, 30)
+	completeTask(t, repo, carol.ID, 10)
+
+	// Пока срок не истек, списывать нечего
+	affected, err := expiry.ExpirePoints(ctx)
+	if err != nil {
+		t.Fatalf("ExpirePoints() error = %v", err)
+	}
+	if affected != 0 || points(t, repo, alice.ID) != 50 {
+		t.Errorf("early ExpirePoints() = %d, alice has %d, want 0 and 50 points", affected, points(t, repo, alice.ID))
+	}
+
+	// Переведенные баллы тратятся из начислений отправителя и у получателя не сгорают
+	if _, err := repo.TransferPoints(ctx, alice.ID, bob.ID, 20); err != nil {
+		t.Fatalf("TransferPoints() error = %v", err)
+	}
+	// Потраченные баллы carol обнуляют ее начисление
+	if _, err := repo.AdjustPoints(ctx, alice.ID, carol.ID, -10, "cleanup"); err != nil {
+		t.Fatalf("AdjustPoints() error = %v", err)
+	}
+
+	time.Sleep(400 * time.Millisecond)
+	completeTask(t, repo, alice.ID, 5)
+
+	affected, err = expiry.ExpirePoints(ctx)
+	if err != nil {
+		t.Fatalf("ExpirePoints() error = %v", err)
+	}
+	if affected != 2 {
+		t.Errorf("ExpirePoints() = %d, want 2", affected)
+	}
+	// Свежее начисление alice остается, у bob остаются только переведенные баллы
+	want := map[uuid.UUID]int{alice.ID: 5, bob.ID: 20, carol.ID: 0}
+	for id, balance := range want {
+		if got := points(t, repo, id); got != balance {
+			t.Errorf("user %s has %d points, want %d", id, got, balance)
+		}
+	}
+
+	users, _, err := repo.GetLeaderboard(ctx, models.LeaderboardFilter{}, 2, 0)
+	if err != nil {
+		t.Fatalf("GetLeaderboard() error = %v", err)
+	}
+	var names []string
+	for _, user := range users {
+		names = append(names, user.Username)
+	}
+	if strings.Join(names, ",") != "bob,alice" {
+		t.Errorf("leaderboard = %v, want [bob alice]", names)
+	}
+
+	// Истекшие остатки списываются один раз
+	if affected, err = expiry.ExpirePoints(ctx); err != nil || affected != 0 {
+		t.Errorf("repeated ExpirePoints() = %d, %v, want 0", affected, err)
+	}
+}
@@ -0,0 +1,501 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"github.com/DblMOKRQ/DeNet_test_task/internal/service"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/logger"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/validate"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// AdminHandler обрабатывает административные запросы
+type AdminHandler struct {
+	userService *service.UserService
+	log         *zap.Logger
+}
+
+// NewAdminHandler создает новый экземпляр AdminHandler
+func NewAdminHandler(userService *service.UserService, log *zap.Logger) *AdminHandler {
+	return &AdminHandler{
+		userService: userService,
+		log:         log.Named("admin_handler"),
+	}
+}
+
+// GetGlobalStats возвращает общие показатели сервиса
+func (h *AdminHandler) GetGlobalStats(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context(), h.log)
+	log.Info("Handling get global stats request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+
+	stats, err := h.userService.GetGlobalStats(r.Context())
+	if err != nil {
+		log.Error("Failed to get global stats", zap.Error(err))
+		writeInternalError(w)
+		return
+	}
+
+	// Сериализация ответа в JSON
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(stats); err != nil {
+		log.Error("Failed to encode response", zap.Error(err))
+		return
+	}
+
+	log.Info("Successfully retrieved global stats")
+}
+
+// GetTaskStats возвращает статистику выполнения заданий по типам
+func (h *AdminHandler) GetTaskStats(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context(), h.log)
+	if r.Method != http.MethodGet {
+		log.Warn("Invalid request method", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+		writeError(w, http.StatusMethodNotAllowed, models.CodeMethodNotAllowed, "Invalid request method", "")
+		return
+	}
+	log.Info("Handling get task stats request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+
+	// Получение необязательного диапазона дат из query string
+	from, err := parseTimeParam(r, "from")
+	if err != nil {
+		log.Warn("Invalid from parameter", zap.Error(err))
+		writeError(w, http.StatusBadRequest, models.CodeInvalidParameter, "Invalid from parameter, expected RFC3339", "from")
+		return
+	}
+	to, err := parseTimeParam(r, "to")
+	if err != nil {
+		log.Warn("Invalid to parameter", zap.Error(err))
+		writeError(w, http.StatusBadRequest, models.CodeInvalidParameter, "Invalid to parameter, expected RFC3339", "to")
+		return
+	}
+	if from != nil && to != nil && !from.Before(*to) {
+		log.Warn("Invalid date range", zap.Time("from", *from), zap.Time("to", *to))
+		writeError(w, http.StatusBadRequest, models.CodeInvalidParameter, "from must be before to", "from")
+		return
+	}
+
+	stats, err := h.userService.GetTaskStats(r.Context(), from, to)
+	if err != nil {
+		log.Error("Failed to get task stats", zap.Error(err))
+		writeInternalError(w)
+		return
+	}
+
+	// Пустой список сериализуется как [], а не null
+	if stats == nil {
+		stats = []*models.TaskStats{}
+	}
+
+	// Сериализация ответа в JSON
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(stats); err != nil {
+		log.Error("Failed to encode response", zap.Error(err))
+		return
+	}
+
+	log.Info("Successfully returned task stats", zap.Int("task_types", len(stats)))
+}
+
+// RevertTask отменяет выполнение задания и списывает начисленные за него баллы
+func (h *AdminHandler) RevertTask(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context(), h.log)
+	if r.Method != http.MethodPost {
+		log.Warn("Invalid request method", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+		writeError(w, http.StatusMethodNotAllowed, models.CodeMethodNotAllowed, "Invalid request method", "")
+		return
+	}
+	log.Info("Handling revert task request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+
+	admin, ok := currentUser(w, r, log)
+	if !ok {
+		return
+	}
+	adminID := admin.UserID
+
+	taskIDStr := r.PathValue("id")
+	taskID, err := uuid.Parse(taskIDStr)
+	if err != nil {
+		log.Warn("Invalid task ID format", zap.String("task_id", taskIDStr), zap.Error(err))
+		writeError(w, http.StatusBadRequest, models.CodeInvalidParameter, "Invalid task ID format", "id")
+		return
+	}
+
+	task, err := h.userService.RevertTask(r.Context(), adminID, taskID)
+	if err != nil {
+		if writeServiceError(w, err) {
+			return
+		}
+		log.Error("Failed to revert task",
+			zap.String("task_id", taskID.String()),
+			zap.Error(err))
+		writeInternalError(w)
+		return
+	}
+
+	// Сериализация ответа в JSON
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(task); err != nil {
+		log.Error("Failed to encode response", zap.Error(err))
+		return
+	}
+
+	log.Info("Successfully reverted task",
+		zap.String("task_id", taskID.String()),
+		zap.String("user_id", task.UserID.String()))
+}
+
+// ImportUsers создает пользователей пакетом и возвращает количество созданных и пропущенных
+func (h *AdminHandler) ImportUsers(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context(), h.log)
+	if r.Method != http.MethodPost {
+		log.Warn("Invalid request method", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+		writeError(w, http.StatusMethodNotAllowed, models.CodeMethodNotAllowed, "Invalid request method", "")
+		return
+	}
+	log.Info("Handling import users request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+
+	// Десериализация запроса
+	var users []models.ImportUserRequest
+	if !decodeJSON(w, r, &users, log) {
+		return
+	}
+	defer r.Body.Close()
+
+	if len(users) == 0 {
+		log.Warn("Empty import batch")
+		writeError(w, http.StatusBadRequest, models.CodeValidationFailed, "At least one user is required", "users")
+		return
+	}
+
+	result, err := h.userService.ImportUsers(r.Context(), users)
+	if err != nil {
+		var fieldErr *validate.FieldError
+		if errors.As(err, &fieldErr) {
+			writeFieldError(w, fieldErr)
+			return
+		}
+		if writeServiceError(w, err) {
+			return
+		}
+		log.Error("Failed to import users", zap.Error(err))
+		writeInternalError(w)
+		return
+	}
+
+	// Сериализация ответа в JSON
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		log.Error("Failed to encode response", zap.Error(err))
+		return
+	}
+
+	log.Info("Successfully imported users",
+		zap.Int("inserted", result.Inserted),
+		zap.Int("skipped", result.Skipped))
+}
+
+// ndjsonContentType тип содержимого тела и ответа потокового импорта (одно значение JSON на строку)
+const ndjsonContentType = "application/x-ndjson"
+
+// ImportTasks импортирует выполненные задания из тела в формате NDJSON (одна запись на строку)
+// и потоково возвращает результат каждой записи строкой NDJSON сразу после ее обработки;
+// последней строкой передается итог импорта
+func (h *AdminHandler) ImportTasks(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context(), h.log)
+	log.Info("Handling import tasks request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+
+	admin, ok := currentUser(w, r, log)
+	if !ok {
+		return
+	}
+	defer r.Body.Close()
+
+	// Результаты пишутся до окончания чтения тела, что в HTTP/1.x требует полнодуплексного режима;
+	// HTTP/2 поддерживает его всегда
+	rc := http.NewResponseController(w)
+	if err := rc.EnableFullDuplex(); err != nil {
+		log.Debug("Full duplex is not enabled", zap.Error(err))
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	next := func(record *models.ImportTaskRequest) error {
+		err := decoder.Decode(record)
+		if err == nil || errors.Is(err, io.EOF) {
+			return err
+		}
+		message, field := decodeErrorMessage(err)
+		// Ошибка типа или неизвестное поле относятся к одной записи: декодер уже прочитал ее целиком
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) || field != "" {
+			return &validate.FieldError{Field: field, Message: message}
+		}
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			message = "Request body too large"
+		}
+		return errors.New(message)
+	}
+
+	w.Header().Set("Content-Type", ndjsonContentType)
+	w.WriteHeader(http.StatusOK)
+	encoder := json.NewEncoder(w)
+	emit := func(result *models.ImportTaskResult) error {
+		if err := encoder.Encode(result); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+
+	summary, err := h.userService.ImportTasks(r.Context(), admin.UserID, next, emit)
+	if err != nil {
+		log.Error("Task import aborted", zap.Error(err))
+	}
+	if err := encoder.Encode(summary); err != nil {
+		log.Error("Failed to encode response", zap.Error(err))
+		return
+	}
+
+	log.Info("Successfully imported tasks",
+		zap.Int("imported", summary.Imported),
+		zap.Int("failed", summary.Failed))
+}
+
+// parseTimeParam разбирает необязательный параметр запроса в формате RFC3339
+func parseTimeParam(r *http.Request, name string) (*time.Time, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// AdjustPoints вручную изменяет баланс пользователя с указанием причины
+func (h *AdminHandler) AdjustPoints(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context(), h.log)
+	log.Info("Handling adjust points request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+
+	admin, ok := currentUser(w, r, log)
+	if !ok {
+		return
+	}
+	adminID := admin.UserID
+
+	userIDStr := r.PathValue("id")
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		log.Warn("Invalid user ID format", zap.String("user_id", userIDStr), zap.Error(err))
+		writeError(w, http.StatusBadRequest, models.CodeInvalidParameter, "Invalid user ID format", "id")
+		return
+	}
+
+	// Десериализация запроса
+	var req models.AdjustPointsRequest
+	if !decodeJSON(w, r, &req, log) {
+		return
+	}
+	defer r.Body.Close()
+
+	adjustment, err := h.userService.AdjustPoints(r.Context(), adminID, userID, req.Delta, req.Reason)
+	if err != nil {
+		if writeServiceError(w, err) {
+			return
+		}
+		log.Error("Failed to adjust points",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		writeInternalError(w)
+		return
+	}
+
+	// Сериализация ответа в JSON
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(adjustment); err != nil {
+		log.Error("Failed to encode response", zap.Error(err))
+		return
+	}
+
+	log.Info("Successfully adjusted points",
+		zap.String("user_id", userID.String()),
+		zap.Int("applied_delta", adjustment.AppliedDelta))
+}
+
+// Параметры страницы журнала аудита
+const (
+	DefaultAuditLimit = 50
+	MaxAuditLimit     = 200
+)
+
+// ListAudit возвращает журнал аудита с фильтрами actor, action и since
+func (h *AdminHandler) ListAudit(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context(), h.log)
+	log.Info("Handling list audit request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+
+	query := r.URL.Query()
+	filter := models.AuditFilter{Action: query.Get("action")}
+
+	if actorStr := query.Get("actor"); actorStr != "" {
+		actor, err := uuid.Parse(actorStr)
+		if err != nil {
+			log.Warn("Invalid actor parameter", zap.String("actor", actorStr))
+			writeError(w, http.StatusBadRequest, models.CodeInvalidParameter, "Invalid actor parameter", "actor")
+			return
+		}
+		filter.ActorID = &actor
+	}
+
+	since, err := parseTimeParam(r, "since")
+	if err != nil {
+		log.Warn("Invalid since parameter", zap.Error(err))
+		writeError(w, http.StatusBadRequest, models.CodeInvalidParameter, "Invalid since parameter, expected RFC3339", "since")
+		return
+	}
+	filter.Since = since
+
+	limit := DefaultAuditLimit
+	if limitStr := query.Get("limit"); limitStr != "" {
+		parsedLimit, err := strconv.Atoi(limitStr)
+		if err != nil || parsedLimit <= 0 {
+			log.Warn("Invalid limit parameter", zap.String("limit", limitStr))
+			writeError(w, http.StatusBadRequest, models.CodeInvalidParameter, "Invalid limit parameter", "limit")
+			return
+		}
+		limit = min(parsedLimit, MaxAuditLimit)
+	}
+
+	offset := 0
+	if offsetStr := query.Get("offset"); offsetStr != "" {
+		parsedOffset, err := strconv.Atoi(offsetStr)
+		if err != nil || parsedOffset < 0 {
+			log.Warn("Invalid offset parameter", zap.String("offset", offsetStr))
+			writeError(w, http.StatusBadRequest, models.CodeInvalidParameter, "Invalid offset parameter", "offset")
+			return
+		}
+		offset = parsedOffset
+	}
+
+	entries, total, err := h.userService.ListAudit(r.Context(), filter, limit, offset)
+	if err != nil {
+		log.Error("Failed to list audit entries", zap.Error(err))
+		writeInternalError(w)
+		return
+	}
+
+	// Журнал всегда возвращается в конверте с метаданными пагинации
+	if err := writePage(w, r, entries, total, limit, offset, true); err != nil {
+		log.Error("Failed to encode response", zap.Error(err))
+		return
+	}
+
+	log.Info("Successfully returned audit entries", zap.Int("entries_count", len(entries)))
+}
+
+// Параметры страницы списка пользователей
+const (
+	DefaultUsersLimit = 50
+	MaxUsersLimit     = 200
+)
+
+// MaxUserSearchLength максимальная длина подстроки поиска по имени
+const MaxUserSearchLength = 32
+
+// ListUsers возвращает список пользователей с поиском по имени (search), отбором по наличию
+// реферера (has_referrer) и сортировкой по created_at или points (sort, order)
+func (h *AdminHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context(), h.log)
+	log.Info("Handling list users request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+
+	query := r.URL.Query()
+	filter := models.UserListFilter{Search: query.Get("search")}
+	if len(filter.Search) > MaxUserSearchLength {
+		writeError(w, http.StatusBadRequest, models.CodeValidationFailed, "must be at most "+strconv.Itoa(MaxUserSearchLength)+" characters", "search")
+		return
+	}
+
+	if hasReferrerStr := query.Get("has_referrer"); hasReferrerStr != "" {
+		hasReferrer, err := strconv.ParseBool(hasReferrerStr)
+		if err != nil {
+			log.Warn("Invalid has_referrer parameter", zap.String("has_referrer", hasReferrerStr))
+			writeError(w, http.StatusBadRequest, models.CodeInvalidParameter, "Invalid has_referrer parameter, expected true or false", "has_referrer")
+			return
+		}
+		filter.HasReferrer = &hasReferrer
+	}
+
+	switch sortBy := query.Get("sort"); sortBy {
+	case "", models.UserSortCreatedAt, models.UserSortPoints:
+		filter.Sort = sortBy
+	default:
+		log.Warn("Invalid sort parameter", zap.String("sort", sortBy))
+		writeError(w, http.StatusBadRequest, models.CodeInvalidParameter, "Invalid sort parameter, expected created_at or points", "sort")
+		return
+	}
+	switch order := query.Get("order"); order {
+	case "", "desc":
+	case "asc":
+		filter.Asc = true
+	default:
+		log.Warn("Invalid order parameter", zap.String("order", order))
+		writeError(w, http.StatusBadRequest, models.CodeInvalidParameter, "Invalid order parameter, expected asc or desc", "order")
+		return
+	}
+
+	limit := DefaultUsersLimit
+	if limitStr := query.Get("limit"); limitStr != "" {
+		parsedLimit, err := strconv.Atoi(limitStr)
+		if err != nil || parsedLimit <= 0 {
+			log.Warn("Invalid limit parameter", zap.String("limit", limitStr))
+			writeError(w, http.StatusBadRequest, models.CodeInvalidParameter, "Invalid limit parameter", "limit")
+			return
+		}
+		limit = min(parsedLimit, MaxUsersLimit)
+	}
+
+	offset := 0
+	if offsetStr := query.Get("offset"); offsetStr != "" {
+		parsedOffset, err := strconv.Atoi(offsetStr)
+		if err != nil || parsedOffset < 0 {
+			log.Warn("Invalid offset parameter", zap.String("offset", offsetStr))
+			writeError(w, http.StatusBadRequest, models.CodeInvalidParameter, "Invalid offset parameter", "offset")
+			return
+		}
+		offset = parsedOffset
+	}
+
+	users, total, err := h.userService.ListUsers(r.Context(), filter, limit, offset)
+	if err != nil {
+		log.Error("Failed to list users", zap.Error(err))
+		writeInternalError(w)
+		return
+	}
+
+	// Список всегда возвращается в конверте с метаданными пагинации
+	if err := writePage(w, r, users, total, limit, offset, true); err != nil {
+		log.Error("Failed to encode response", zap.Error(err))
+		return
+	}
+
+	log.Info("Successfully returned users", zap.Int("users_count", len(users)), zap.Int("total", total))
+}
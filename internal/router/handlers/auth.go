@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/router/middleware"
+	"go.uber.org/zap"
+)
+
+// currentUser возвращает пользователя, аутентифицированного JWTAuth. Токен повторно не проверяется:
+// защищенные маршруты всегда проходят через JWTAuth, поэтому отсутствие пользователя в контексте
+// означает ошибку регистрации маршрута. В этом случае отправляется 500 и возвращается false.
+func currentUser(w http.ResponseWriter, r *http.Request, log *zap.Logger) (*middleware.AuthUser, bool) {
+	user, ok := middleware.UserFromContext(r.Context())
+	if !ok {
+		log.Error("Authenticated user missing from context, route is not protected by JWTAuth",
+			zap.String("path", r.URL.Path))
+		writeInternalError(w)
+		return nil, false
+	}
+	return user, true
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/router/middleware"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+func TestCurrentUser(t *testing.T) {
+	user := &middleware.AuthUser{UserID: uuid.New(), Role: "user"}
+	req := httptest.NewRequest(http.MethodGet, "/users/me", nil)
+	req = req.WithContext(middleware.WithUser(req.Context(), user))
+
+	rec := httptest.NewRecorder()
+	got, ok := currentUser(rec, req, zap.NewNop())
+	if !ok || got != user {
+		t.Errorf("currentUser() = %v, %t, want the user from the context", got, ok)
+	}
+	if rec.Code != http.StatusOK || rec.Body.Len() != 0 {
+		t.Errorf("currentUser() wrote a response %d %q for an authenticated request", rec.Code, rec.Body)
+	}
+}
+
+func TestCurrentUserMissing(t *testing.T) {
+	// Маршрут, зарегистрированный без JWTAuth, - ошибка сервера, а не клиента
+	rec := httptest.NewRecorder()
+	if _, ok := currentUser(rec, httptest.NewRequest(http.MethodGet, "/users/me", nil), zap.NewNop()); ok {
+		t.Error("currentUser() ok = true without an authenticated user")
+	}
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/buildinfo"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// ConfigHandler отдает клиентам публичные параметры сервера
+type ConfigHandler struct {
+	public models.PublicConfig
+	log    *zap.Logger
+}
+
+// NewConfigHandler создает новый экземпляр ConfigHandler
+func NewConfigHandler(public models.PublicConfig, log *zap.Logger) *ConfigHandler {
+	return &ConfigHandler{
+		public: public,
+		log:    log.Named("config_handler"),
+	}
+}
+
+// GetPublicConfig возвращает несекретные параметры, необходимые клиентам
+func (h *ConfigHandler) GetPublicConfig(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context(), h.log)
+	if r.Method != http.MethodGet {
+		log.Warn("Invalid request method", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+		writeError(w, http.StatusMethodNotAllowed, models.CodeMethodNotAllowed, "Invalid request method", "")
+		return
+	}
+
+	// Сериализация ответа в JSON
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(h.public); err != nil {
+		log.Error("Failed to encode response", zap.Error(err))
+		return
+	}
+}
+
+// GetVersion возвращает версию, коммит и дату сборки сервера
+func (h *ConfigHandler) GetVersion(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context(), h.log)
+
+	// Сериализация ответа в JSON
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(buildinfo.Get()); err != nil {
+		log.Error("Failed to encode response", zap.Error(err))
+		return
+	}
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// FormatParam параметр запроса, выбирающий формат ответа: json (по умолчанию) или csv.
+// Имеет приоритет над заголовком Accept.
+const FormatParam = "format"
+
+// Форматы ответа таблицы лидеров
+const (
+	formatJSON = "json"
+	formatCSV  = "csv"
+)
+
+// csvContentType тип содержимого ответа в формате CSV
+const csvContentType = "text/csv"
+
+// csvFlushRows количество строк, после которого записанное отправляется клиенту
+const csvFlushRows = 100
+
+// leaderboardCSVHeader заголовок CSV выгрузки таблицы лидеров
+var leaderboardCSVHeader = []string{"rank", "id", "username", "points"}
+
+// wantsCSV определяет по параметру format или заголовку Accept, нужен ли ответ в формате CSV
+func wantsCSV(r *http.Request) (bool, error) {
+	switch format := r.URL.Query().Get(FormatParam); format {
+	case formatCSV:
+		return true, nil
+	case formatJSON:
+		return false, nil
+	case "":
+	default:
+		return false, fmt.Errorf("unsupported format %q", format)
+	}
+
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err == nil && mediaType == csvContentType {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// writeCSV построчно записывает n строк, полученных из row, с заголовком header.
+// Общее количество передается в заголовке X-Total-Count, как и для JSON.
+func writeCSV(w http.ResponseWriter, filename string, header []string, total, n int, row func(i int) []string) error {
+	w.Header().Set("Content-Type", csvContentType+"; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	w.WriteHeader(http.StatusOK)
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		if err := cw.Write(row(i)); err != nil {
+			return err
+		}
+		// Периодическая отправка, чтобы клиент получал строки, не дожидаясь конца выгрузки
+		if (i+1)%csvFlushRows == 0 {
+			cw.Flush()
+			if err := cw.Error(); err != nil {
+				return err
+			}
+			if flusher, ok := w.(http.Flusher); ok {
+				flusher.Flush()
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// RequestEmailVerification выдает токен подтверждения адреса электронной почты текущего пользователя.
+// Токен доставляется письмом через уведомления; ответ содержит адрес и срок действия токена.
+func (h *UserHandler) RequestEmailVerification(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context(), h.log)
+	log.Info("Handling email verification request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+
+	// Пользователь аутентифицирован JWTAuth
+	authUser, ok := currentUser(w, r, log)
+	if !ok {
+		return
+	}
+	userID := authUser.UserID
+
+	verification, err := h.userService.RequestEmailVerification(r.Context(), userID)
+	if err != nil {
+		if writeServiceError(w, err) {
+			return
+		}
+		log.Error("Failed to request email verification",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		writeInternalError(w)
+		return
+	}
+	if !h.opts.ReturnVerificationToken {
+		verification.Token = ""
+	}
+
+	// Сериализация ответа в JSON
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+
+	if err := json.NewEncoder(w).Encode(verification); err != nil {
+		log.Error("Failed to encode response", zap.Error(err))
+		return
+	}
+
+	log.Info("Email verification requested", zap.String("user_id", userID.String()))
+}
+
+// VerifyEmail подтверждает адрес электронной почты по токену из параметра token
+func (h *UserHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context(), h.log)
+	log.Info("Handling verify email request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		writeError(w, http.StatusBadRequest, models.CodeValidationFailed, "is required", "token")
+		return
+	}
+
+	if err := h.userService.VerifyEmail(r.Context(), token); err != nil {
+		if writeServiceError(w, err) {
+			return
+		}
+		log.Error("Failed to verify email", zap.Error(err))
+		writeInternalError(w)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+	log.Info("Successfully verified email")
+}
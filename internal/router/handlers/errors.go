@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/jwt"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/validate"
+	"go.uber.org/zap"
+)
+
+// errorEntry описывает ответ на ошибку предметной области
+type errorEntry struct {
+	err     error
+	status  int
+	code    string
+	message string
+	// field поле запроса, к которому относится ошибка, или пустая строка
+	field string
+}
+
+// errorCatalog сопоставляет ошибкам предметной области HTTP-статус и код ответа.
+// Коды перечислены в README; ошибка, которой нет в каталоге, считается внутренней.
+var errorCatalog = []errorEntry{
+	{models.ErrUserNotFound, http.StatusNotFound, models.CodeUserNotFound, "User not found", ""},
+	{models.ErrInvalidPassword, http.StatusForbidden, models.CodeInvalidPassword, "Current password is incorrect", "current_password"},
+	{models.ErrInvalidCredentials, http.StatusUnauthorized, models.CodeInvalidCredentials, "Invalid username or password", ""},
+	{models.ErrPasswordReused, http.StatusBadRequest, models.CodePasswordReused, "New password must differ from recently used passwords", "new_password"},
+	{models.ErrInvalidResetToken, http.StatusBadRequest, models.CodeInvalidResetToken, "Invalid or expired password reset token", "token"},
+
+	{models.ErrUsernameTaken, http.StatusConflict, models.CodeUsernameTaken, "Username already taken", "username"},
+	{models.ErrUsernameReserved, http.StatusBadRequest, models.CodeUsernameReserved, "Username is reserved", "username"},
+	{models.ErrUsernameUnchanged, http.StatusBadRequest, models.CodeUsernameUnchanged, "New username equals the current one", "username"},
+	{models.ErrConflict, http.StatusConflict, models.CodeConflict, "Profile was modified, reload it and retry", "version"},
+
+	{models.ErrEmailTaken, http.StatusConflict, models.CodeEmailTaken, "Email already taken", "email"},
+	{models.ErrEmailNotSet, http.StatusConflict, models.CodeEmailNotSet, "Email is not set", "email"},
+	{models.ErrEmailAlreadyVerified, http.StatusConflict, models.CodeEmailAlreadyVerified, "Email already verified", "email"},
+	{models.ErrInvalidVerificationToken, http.StatusBadRequest, models.CodeInvalidVerificationToken, "Invalid or expired verification token", "token"},
+
+	{models.ErrReferrerNotFound, http.StatusNotFound, models.CodeReferrerNotFound, "Referrer not found", ""},
+	{models.ErrReferrerAlreadySet, http.StatusConflict, models.CodeReferrerAlreadySet, "User already has a referrer", ""},
+	{models.ErrReferrerNotSet, http.StatusNotFound, models.CodeReferrerNotSet, "User has no referrer", ""},
+	{models.ErrReferrerRemoved, http.StatusConflict, models.CodeReferrerRemoved, "Referrer was removed and cannot be added again", "referrer_id"},
+	{models.ErrSelfReferral, http.StatusBadRequest, models.CodeReferralCycle, "User cannot add themselves as referrer", "referrer_id"},
+
+	{models.ErrTaskNotFound, http.StatusNotFound, models.CodeTaskNotFound, "Task not found", ""},
+	{models.ErrTaskAlreadyReverted, http.StatusConflict, models.CodeTaskAlreadyReverted, "Task already reverted", ""},
+	{models.ErrTooManyTasks, http.StatusTooManyRequests, models.CodeTooManyTasks, "Too many tasks completed, try again later", ""},
+
+	{models.ErrInvalidPeriod, http.StatusBadRequest, models.CodeInvalidPeriod, "Invalid period parameter, expected weekly or monthly", "period"},
+	{models.ErrBatchTooLarge, http.StatusBadRequest, models.CodeBatchTooLarge, "Too many users in one batch", "users"},
+
+	{models.ErrInsufficientPoints, http.StatusConflict, models.CodeInsufficientPoints, "Insufficient points", ""},
+	{models.ErrPointsOutOfRange, http.StatusBadRequest, models.CodePointsOutOfRange, "Points balance out of range", ""},
+
+	{models.ErrQueryTimeout, http.StatusServiceUnavailable, models.CodeQueryTimeout, "Database query timed out, try again later", ""},
+}
+
+// errorToResponse возвращает HTTP-статус и тело ответа для ошибки предметной области из
+// errorCatalog (ошибка может быть обернута). Для остальных ошибок возвращает
+// 500 Internal Server Error с кодом models.CodeInternal и false.
+func errorToResponse(err error) (int, models.ErrorResponse, bool) {
+	for _, entry := range errorCatalog {
+		if errors.Is(err, entry.err) {
+			return entry.status, models.ErrorResponse{
+				Error: entry.message,
+				Code:  entry.code,
+				Field: entry.field,
+			}, true
+		}
+	}
+	return http.StatusInternalServerError, models.ErrorResponse{
+		Error: "Internal server error",
+		Code:  models.CodeInternal,
+	}, false
+}
+
+// writeServiceError отправляет ответ на ошибку предметной области по errorToResponse
+// (401 - с заголовком WWW-Authenticate по RFC 6750) и возвращает true. Если ошибки нет в каталоге,
+// ответ не отправляется: обработчик сам логирует ее и отвечает 500.
+func writeServiceError(w http.ResponseWriter, err error) bool {
+	status, resp, ok := errorToResponse(err)
+	if !ok {
+		return false
+	}
+	if status == http.StatusUnauthorized {
+		w.Header().Set(jwt.ChallengeHeader, jwt.Challenge(nil))
+	}
+	writeErrorResponse(w, status, resp)
+	return true
+}
+
+// writeInternalError отправляет 500 Internal Server Error с кодом models.CodeInternal.
+// Текст ошибки клиенту не передается: обработчик логирует его сам.
+func writeInternalError(w http.ResponseWriter) {
+	writeError(w, http.StatusInternalServerError, models.CodeInternal, "Internal server error", "")
+}
+
+// writeFieldError отправляет 400 Bad Request с описанием ошибки валидации поля
+func writeFieldError(w http.ResponseWriter, fieldErr *validate.FieldError) {
+	writeError(w, http.StatusBadRequest, models.CodeValidationFailed, fieldErr.Message, fieldErr.Field)
+}
+
+// writeValidationErrors отправляет 422 Unprocessable Entity со всеми нарушениями из err
+// (validate.FieldErrors или *validate.FieldError) в поле fields; field и error описывают
+// первое нарушение. Возвращает false, не отправляя ответ, если err не ошибка валидации.
+func writeValidationErrors(w http.ResponseWriter, err error) bool {
+	fieldErrs, ok := validate.As(err)
+	if !ok {
+		return false
+	}
+
+	fields := make(map[string]string, len(fieldErrs))
+	for _, fieldErr := range fieldErrs {
+		fields[fieldErr.Field] = fieldErr.Message
+	}
+	writeErrorResponse(w, http.StatusUnprocessableEntity, models.ErrorResponse{
+		Error:  fieldErrs[0].Message,
+		Code:   models.CodeValidationFailed,
+		Field:  fieldErrs[0].Field,
+		Fields: fields,
+	})
+	return true
+}
+
+// writeError отправляет ответ с ошибкой в формате models.ErrorResponse с кодом code (models.Code*);
+// field может быть пустым, если ошибка не относится к конкретному полю
+func writeError(w http.ResponseWriter, status int, code, message, field string) {
+	writeErrorResponse(w, status, models.ErrorResponse{
+		Error: message,
+		Code:  code,
+		Field: field,
+	})
+}
+
+// writeErrorResponse отправляет resp в формате JSON со статусом status
+func writeErrorResponse(w http.ResponseWriter, status int, resp models.ErrorResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// decodeJSON десериализует тело запроса в v, отклоняя неизвестные поля, и проверяет его
+// по правилам тегов validate. При ошибке отправляет ответ клиенту (413 при превышении
+// размера тела, 500 при внутренней ошибке проверки, иначе 400 с описанием причины и,
+// если известно, поля) и возвращает false.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}, log *zap.Logger) bool {
+	if !decodeBody(w, r, v, log) {
+		return false
+	}
+
+	if err := validate.Struct(v); err != nil {
+		var fieldErr *validate.FieldError
+		if errors.As(err, &fieldErr) {
+			log.Warn("Request validation failed", zap.Error(err))
+			writeFieldError(w, fieldErr)
+			return false
+		}
+		log.Error("Failed to validate request", zap.Error(err))
+		writeInternalError(w)
+		return false
+	}
+	return true
+}
+
+// decodeBody десериализует тело запроса в v так же, как decodeJSON, но без проверки
+// по тегам validate: обработчик проверяет поля сам, чтобы сообщить обо всех нарушениях сразу
+func decodeBody(w http.ResponseWriter, r *http.Request, v interface{}, log *zap.Logger) bool {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(v); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			log.Warn("Request body too large", zap.Int64("limit", maxBytesErr.Limit))
+			writeError(w, http.StatusRequestEntityTooLarge, models.CodeRequestTooLarge, "Request body too large", "")
+			return false
+		}
+		log.Warn("Invalid request body", zap.Error(err))
+		message, field := decodeErrorMessage(err)
+		writeError(w, http.StatusBadRequest, models.CodeInvalidRequestBody, message, field)
+		return false
+	}
+	return true
+}
+
+// decodeErrorMessage описывает ошибку десериализации тела запроса для клиента
+// и возвращает имя поля, к которому она относится, если его можно определить
+func decodeErrorMessage(err error) (string, string) {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.Is(err, io.EOF):
+		return "Request body is empty", ""
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return "Request body contains incomplete JSON", ""
+	case errors.As(err, &syntaxErr):
+		return fmt.Sprintf("Request body contains malformed JSON at position %d", syntaxErr.Offset), ""
+	case errors.As(err, &typeErr):
+		if typeErr.Field == "" {
+			return fmt.Sprintf("Request body must be a JSON object, got %s", typeErr.Value), ""
+		}
+		return fmt.Sprintf("Field %q must be of type %s, got %s", typeErr.Field, typeErr.Type, typeErr.Value), typeErr.Field
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json не экспортирует тип этой ошибки
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return fmt.Sprintf("Unknown field %q", field), field
+	default:
+		return "Invalid request body", ""
+	}
+}
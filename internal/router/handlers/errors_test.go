@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/jwt"
+	"go.uber.org/zap"
+)
+
+// decodeErrorResponse разбирает тело ответа с ошибкой, проверяя, что оно отправлено в JSON
+func decodeErrorResponse(t *testing.T, rec *httptest.ResponseRecorder) models.ErrorResponse {
+	t.Helper()
+
+	if contentType := rec.Header().Get("Content-Type"); contentType != "application/json" {
+		t.Fatalf("Content-Type = %q, want application/json", contentType)
+	}
+	var resp models.ErrorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode error response: %v", err)
+	}
+	return resp
+}
+
+func TestErrorToResponse(t *testing.T) {
+	// Статусы, коды и поля совпадают с таблицей ошибок в README
+	tests := []struct {
+		err        error
+		wantStatus int
+		wantCode   string
+		wantField  string
+	}{
+		{models.ErrUserNotFound, http.StatusNotFound, "USER_NOT_FOUND", ""},
+		{models.ErrInvalidCredentials, http.StatusUnauthorized, "INVALID_CREDENTIALS", ""},
+		{models.ErrInvalidPassword, http.StatusForbidden, "INVALID_PASSWORD", "current_password"},
+		{models.ErrPasswordReused, http.StatusBadRequest, "PASSWORD_REUSED", "new_password"},
+		{models.ErrInvalidResetToken, http.StatusBadRequest, "INVALID_RESET_TOKEN", "token"},
+		{models.ErrUsernameTaken, http.StatusConflict, "USERNAME_TAKEN", "username"},
+		{models.ErrUsernameReserved, http.StatusBadRequest, "USERNAME_RESERVED", "username"},
+		{models.ErrUsernameUnchanged, http.StatusBadRequest, "USERNAME_UNCHANGED", "username"},
+		{models.ErrConflict, http.StatusConflict, "CONFLICT", "version"},
+		{models.ErrEmailTaken, http.StatusConflict, "EMAIL_TAKEN", "email"},
+		{models.ErrEmailNotSet, http.StatusConflict, "EMAIL_NOT_SET", "email"},
+		{models.ErrEmailAlreadyVerified, http.StatusConflict, "EMAIL_ALREADY_VERIFIED", "email"},
+		{models.ErrInvalidVerificationToken, http.StatusBadRequest, "INVALID_VERIFICATION_TOKEN", "token"},
+		{models.ErrReferrerNotFound, http.StatusNotFound, "REFERRER_NOT_FOUND", ""},
+		{models.ErrReferrerAlreadySet, http.StatusConflict, "REFERRER_ALREADY_SET", ""},
+		{models.ErrReferrerNotSet, http.StatusNotFound, "REFERRER_NOT_SET", ""},
+		{models.ErrReferrerRemoved, http.StatusConflict, "REFERRER_REMOVED", "referrer_id"},
+		{models.ErrSelfReferral, http.StatusBadRequest, "REFERRAL_CYCLE", "referrer_id"},
+		{models.ErrTaskNotFound, http.StatusNotFound, "TASK_NOT_FOUND", ""},
+		{models.ErrTaskAlreadyReverted, http.StatusConflict, "TASK_ALREADY_REVERTED", ""},
+		{models.ErrTooManyTasks, http.StatusTooManyRequests, "TOO_MANY_TASKS", ""},
+		{models.ErrInvalidPeriod, http.StatusBadRequest, "INVALID_PERIOD", "period"},
+		{models.ErrBatchTooLarge, http.StatusBadRequest, "BATCH_TOO_LARGE", "users"},
+		{models.ErrInsufficientPoints, http.StatusConflict, "INSUFFICIENT_POINTS", ""},
+		{models.ErrPointsOutOfRange, http.StatusBadRequest, "POINTS_OUT_OF_RANGE", ""},
+		{models.ErrQueryTimeout, http.StatusServiceUnavailable, "QUERY_TIMEOUT", ""},
+	}
+
+	if len(tests) != len(errorCatalog) {
+		t.Errorf("errorCatalog has %d entries, the test covers %d", len(errorCatalog), len(tests))
+	}
+	for _, tt := range tests {
+		t.Run(tt.wantCode, func(t *testing.T) {
+			// Ошибка сервиса обычно обернута, сопоставление идет по errors.Is
+			status, resp, ok := errorToResponse(fmt.Errorf("operation failed: %w", tt.err))
+			if !ok {
+				t.Fatalf("errorToResponse(%v) is not in the catalog", tt.err)
+			}
+			if status != tt.wantStatus || resp.Code != tt.wantCode || resp.Field != tt.wantField {
+				t.Errorf("errorToResponse(%v) = %d %s field %q, want %d %s field %q",
+					tt.err, status, resp.Code, resp.Field, tt.wantStatus, tt.wantCode, tt.wantField)
+			}
+			if resp.Error == "" || strings.Contains(resp.Error, "operation failed") {
+				t.Errorf("errorToResponse(%v) message = %q, want a client message without internal details", tt.err, resp.Error)
+			}
+		})
+	}
+}
+
+func TestErrorToResponseUnknown(t *testing.T) {
+	status, resp, ok := errorToResponse(errors.New("connection refused"))
+	if ok || status != http.StatusInternalServerError || resp.Code != models.CodeInternal {
+		t.Errorf("errorToResponse(unknown) = %d %+v %t, want 500 %s and false", status, resp, ok, models.CodeInternal)
+	}
+	if resp.Error == "connection refused" {
+		t.Error("errorToResponse(unknown) exposes the internal error text")
+	}
+}
+
+func TestWriteServiceError(t *testing.T) {
+	rec := httptest.NewRecorder()
+	if !writeServiceError(rec, models.ErrUsernameTaken) {
+		t.Fatal("writeServiceError(ErrUsernameTaken) = false, want the response written")
+	}
+	if rec.Code != http.StatusConflict || rec.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("response = %d %q, want 409 application/json", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	var got models.ErrorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if got.Code != models.CodeUsernameTaken || got.Field != "username" {
+		t.Errorf("response = %+v, want %s for field username", got, models.CodeUsernameTaken)
+	}
+	if rec.Header().Get(jwt.ChallengeHeader) != "" {
+		t.Errorf("%s set on a 409 response", jwt.ChallengeHeader)
+	}
+
+	// 401 сопровождается вызовом аутентификации по RFC 6750
+	rec = httptest.NewRecorder()
+	if !writeServiceError(rec, models.ErrInvalidCredentials) || rec.Code != http.StatusUnauthorized {
+		t.Fatalf("writeServiceError(ErrInvalidCredentials) status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+	if rec.Header().Get(jwt.ChallengeHeader) == "" {
+		t.Errorf("%s missing on a 401 response", jwt.ChallengeHeader)
+	}
+
+	// Ошибка вне каталога оставляется обработчику
+	rec = httptest.NewRecorder()
+	if writeServiceError(rec, errors.New("connection refused")) || rec.Body.Len() != 0 {
+		t.Errorf("writeServiceError(unknown) wrote %d %q, want no response", rec.Code, rec.Body)
+	}
+}
+
+func TestWriteInternalError(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeInternalError(rec)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+	if resp := decodeErrorResponse(t, rec); resp.Code != models.CodeInternal || resp.Error != "Internal server error" {
+		t.Errorf("response = %+v, want generic %s", resp, models.CodeInternal)
+	}
+}
+
+func TestDecodeJSONErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		limit  int64
+		status int
+		code   string
+		field  string
+	}{
+		{name: "too large", body: `{"task_type":"` + strings.Repeat("a", 64) + `","points":1}`, limit: 16, status: http.StatusRequestEntityTooLarge, code: models.CodeRequestTooLarge},
+		{name: "malformed", body: `{"task_type":`, status: http.StatusBadRequest, code: models.CodeInvalidRequestBody},
+		{name: "unknown field", body: `{"task_type":"a","points":1,"extra":1}`, status: http.StatusBadRequest, code: models.CodeInvalidRequestBody, field: "extra"},
+		{name: "invalid field", body: `{"task_type":"a","points":0}`, status: http.StatusBadRequest, code: models.CodeValidationFailed, field: "points"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			if tt.limit > 0 {
+				req.Body = http.MaxBytesReader(rec, req.Body, tt.limit)
+			}
+
+			var task models.TaskRequest
+			if decodeJSON(rec, req, &task, zap.NewNop()) {
+				t.Fatal("decodeJSON() = true, want false")
+			}
+			if rec.Code != tt.status {
+				t.Errorf("status = %d, want %d", rec.Code, tt.status)
+			}
+			resp := decodeErrorResponse(t, rec)
+			if resp.Code != tt.code || resp.Field != tt.field {
+				t.Errorf("response = %+v, want code %s for field %q", resp, tt.code, tt.field)
+			}
+		})
+	}
+}
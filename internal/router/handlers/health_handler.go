@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// DrainState сообщает, начата ли остановка сервера
+type DrainState interface {
+	Draining() bool
+}
+
+// HealthHandler отвечает на проверки состояния от оркестратора и балансировщика нагрузки
+type HealthHandler struct {
+	drain DrainState
+	log   *zap.Logger
+}
+
+// NewHealthHandler создает новый экземпляр HealthHandler
+func NewHealthHandler(drain DrainState, log *zap.Logger) *HealthHandler {
+	return &HealthHandler{
+		drain: drain,
+		log:   log.Named("health_handler"),
+	}
+}
+
+// Healthz сообщает, что процесс запущен и обрабатывает запросы
+func (h *HealthHandler) Healthz(w http.ResponseWriter, r *http.Request) {
+	h.writeStatus(w, r, http.StatusOK, models.HealthStatusOK)
+}
+
+// Readyz сообщает, готов ли сервер принимать новые запросы.
+// После начала остановки отвечает 503, чтобы балансировщик перестал направлять запросы.
+func (h *HealthHandler) Readyz(w http.ResponseWriter, r *http.Request) {
+	if h.drain.Draining() {
+		h.writeStatus(w, r, http.StatusServiceUnavailable, models.HealthStatusDraining)
+		return
+	}
+	h.writeStatus(w, r, http.StatusOK, models.HealthStatusOK)
+}
+
+// writeStatus отправляет ответ проверки состояния
+func (h *HealthHandler) writeStatus(w http.ResponseWriter, r *http.Request, code int, status string) {
+	log := logger.FromContext(r.Context(), h.log)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+
+	if err := json.NewEncoder(w).Encode(models.HealthResponse{Status: status}); err != nil {
+		log.Error("Failed to encode response", zap.Error(err))
+		return
+	}
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/broadcast"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// eventStreamContentType тип содержимого потока server-sent events
+const eventStreamContentType = "text/event-stream"
+
+// leaderboardEvent имя события с актуальной страницей таблицы лидеров
+const leaderboardEvent = "leaderboard"
+
+// leaderboardStreamHeartbeat период отправки комментария keepalive, чтобы прокси
+// не закрывали простаивающее соединение
+const leaderboardStreamHeartbeat = 15 * time.Second
+
+// leaderboardStreamRetryAfter значение Retry-After при превышении лимита подписчиков, в секундах
+const leaderboardStreamRetryAfter = 5
+
+// StreamLeaderboard отправляет первые limit позиций таблицы лидеров как server-sent events:
+// сразу после подключения и затем при каждом изменении баллов. Событие с неизменившейся
+// страницей не отправляется. Поток завершается при отключении клиента или остановке сервера.
+func (h *UserHandler) StreamLeaderboard(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context(), h.log)
+	log.Info("Handling leaderboard stream request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+
+	limit, ok := h.leaderboardLimit(w, r, log)
+	if !ok {
+		return
+	}
+	if r.URL.Query().Get("period") != "" {
+		writeError(w, http.StatusBadRequest, models.CodeInvalidParameter, "period is not supported for leaderboard stream", "period")
+		return
+	}
+	filter, fieldErr := parseLeaderboardFilter(r)
+	if fieldErr != nil {
+		log.Warn("Invalid leaderboard filter", zap.Error(fieldErr))
+		writeFieldError(w, fieldErr)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		log.Error("Response writer does not support flushing")
+		writeInternalError(w)
+		return
+	}
+
+	updates, unsubscribe, err := h.userService.SubscribeLeaderboard()
+	if err != nil {
+		if errors.Is(err, broadcast.ErrTooManySubscribers) {
+			log.Warn("Leaderboard stream subscriber limit reached")
+			w.Header().Set("Retry-After", strconv.Itoa(leaderboardStreamRetryAfter))
+			writeError(w, http.StatusServiceUnavailable, models.CodeTooManyStreamSubscribers, "Too many leaderboard stream subscribers", "")
+			return
+		}
+		log.Warn("Leaderboard stream is closed", zap.Error(err))
+		writeError(w, http.StatusServiceUnavailable, models.CodeShuttingDown, "Server is shutting down", "")
+		return
+	}
+	defer unsubscribe()
+
+	// Поток живет дольше WriteTimeout сервера, поэтому срок записи для него снимается
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		log.Warn("Failed to clear write deadline", zap.Error(err))
+	}
+
+	w.Header().Set("Content-Type", eventStreamContentType)
+	w.Header().Set("Cache-Control", "no-cache")
+	// Отключение буферизации ответа в nginx
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.Header().Set(LimitHeader, strconv.Itoa(limit))
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	var last []byte
+	send := func() error {
+		users, total, err := h.userService.GetLeaderboard(r.Context(), filter, limit, 0)
+		if err != nil {
+			return fmt.Errorf("failed to get leaderboard: %w", err)
+		}
+		data, err := json.Marshal(models.NewPagedResponse(users, total, limit, 0))
+		if err != nil {
+			return fmt.Errorf("failed to encode leaderboard: %w", err)
+		}
+		if bytes.Equal(data, last) {
+			return nil
+		}
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", leaderboardEvent, data); err != nil {
+			return err
+		}
+		flusher.Flush()
+		last = data
+		return nil
+	}
+
+	if err := send(); err != nil {
+		log.Error("Failed to send leaderboard event", zap.Error(err))
+		return
+	}
+
+	heartbeat := time.NewTicker(leaderboardStreamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			log.Debug("Leaderboard stream client disconnected")
+			return
+		case _, ok := <-updates:
+			if !ok {
+				log.Debug("Leaderboard stream closed by server")
+				return
+			}
+			if err := send(); err != nil {
+				if r.Context().Err() == nil {
+					log.Error("Failed to send leaderboard event", zap.Error(err))
+				}
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+)
+
+// EnvelopeParam параметр запроса, включающий ответ списка в формате models.PagedResponse.
+// Без него списки возвращаются массивом для обратной совместимости.
+const EnvelopeParam = "envelope"
+
+// wantsEnvelope определяет по параметру запроса, нужен ли ответ в конверте
+func wantsEnvelope(r *http.Request) (bool, error) {
+	value := r.URL.Query().Get(EnvelopeParam)
+	if value == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(value)
+}
+
+// writePage отправляет страницу списка массивом или в конверте models.PagedResponse
+// с поддержкой условных запросов; общее количество также передается в заголовке X-Total-Count
+func writePage[T any](w http.ResponseWriter, r *http.Request, items []T, total, limit, offset int, envelope bool) error {
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+
+	page := models.NewPagedResponse(items, total, limit, offset)
+	if envelope {
+		return writeJSONWithETag(w, r, http.StatusOK, page)
+	}
+	return writeJSONWithETag(w, r, http.StatusOK, page.Items)
+}
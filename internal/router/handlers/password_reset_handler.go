@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/logger"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/validate"
+	"go.uber.org/zap"
+)
+
+// passwordResetRequestedMessage текст ответа на запрос сброса пароля
+const passwordResetRequestedMessage = "If the account exists, password reset instructions have been sent"
+
+// RequestPasswordReset выдает токен сброса пароля по имени пользователя или адресу электронной почты.
+// Ответ одинаков независимо от существования учетной записи.
+func (h *UserHandler) RequestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context(), h.log)
+	log.Info("Handling password reset request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+
+	var req models.PasswordResetRequest
+	if !decodeJSON(w, r, &req, log) {
+		return
+	}
+	defer r.Body.Close()
+
+	if err := h.userService.RequestPasswordReset(r.Context(), req.Login); err != nil {
+		log.Error("Failed to request password reset", zap.Error(err))
+		writeInternalError(w)
+		return
+	}
+
+	// Сериализация ответа в JSON
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(models.PasswordResetRequestedResponse{Message: passwordResetRequestedMessage}); err != nil {
+		log.Error("Failed to encode response", zap.Error(err))
+		return
+	}
+
+	log.Info("Password reset request handled")
+}
+
+// ConfirmPasswordReset устанавливает новый пароль по токену сброса
+func (h *UserHandler) ConfirmPasswordReset(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context(), h.log)
+	log.Info("Handling password reset confirmation", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+
+	var req models.PasswordResetConfirmRequest
+	if !decodeJSON(w, r, &req, log) {
+		return
+	}
+	defer r.Body.Close()
+
+	if err := h.userService.ConfirmPasswordReset(r.Context(), req.Token, req.NewPassword); err != nil {
+		var fieldErr *validate.FieldError
+		if errors.As(err, &fieldErr) {
+			writeFieldError(w, fieldErr)
+			return
+		}
+		if writeServiceError(w, err) {
+			return
+		}
+		log.Error("Failed to reset password", zap.Error(err))
+		writeInternalError(w)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+	log.Info("Password reset confirmed")
+}
@@ -2,135 +2,203 @@ package handlers
 
 import (
 	"encoding/json"
-	"fmt"
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
 	"github.com/DblMOKRQ/DeNet_test_task/internal/service"
 	"github.com/DblMOKRQ/DeNet_test_task/pkg/jwt"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/logger"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/validate"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
 )
 
+// DefaultLeaderboardLimit количество пользователей в таблице лидеров, если оно не задано в Options
+const DefaultLeaderboardLimit = 10
+
+// DefaultTasksLimit количество заданий в истории по умолчанию
+const DefaultTasksLimit = 20
+
+// LimitHeader заголовок ответа с фактически примененным размером страницы
+const LimitHeader = "X-Limit"
+
+// LeaderboardRefreshedAtHeader заголовок со временем пересчета материализованного представления,
+// из которого построена таблица лидеров; отсутствует, если она построена по таблице пользователей
+const LeaderboardRefreshedAtHeader = "X-Leaderboard-Refreshed-At"
+
+// Options содержит настраиваемые параметры обработчиков
+type Options struct {
+	// DefaultLeaderboardLimit размер страницы таблицы лидеров, если limit не указан
+	DefaultLeaderboardLimit int
+	// MaxLeaderboardLimit максимальный размер страницы таблицы лидеров
+	MaxLeaderboardLimit int
+	// MaxReferrerChainDepth максимальная глубина цепочки рефереров
+	MaxReferrerChainDepth int
+	// APIPrefix префикс версии API для ссылок в заголовках ответа, например "/api/v1"
+	APIPrefix string
+	// ReturnVerificationToken возвращать токен подтверждения адреса в ответе на запрос подтверждения.
+	// Только для локальной разработки: обычно токен доставляется письмом через уведомления.
+	ReturnVerificationToken bool
+}
+
 // UserHandler обрабатывает запросы, связанные с пользователями
 type UserHandler struct {
 	userService *service.UserService
 	jwtService  *jwt.Service
+	opts        Options
 	log         *zap.Logger
 }
 
 // NewUserHandler создает новый экземпляр UserHandler
-func NewUserHandler(userService *service.UserService, jwtService *jwt.Service, log *zap.Logger) *UserHandler {
+func NewUserHandler(userService *service.UserService, jwtService *jwt.Service, opts Options, log *zap.Logger) *UserHandler {
+	if opts.DefaultLeaderboardLimit <= 0 {
+		opts.DefaultLeaderboardLimit = DefaultLeaderboardLimit
+	}
+	if opts.MaxLeaderboardLimit <= 0 {
+		opts.MaxLeaderboardLimit = opts.DefaultLeaderboardLimit
+	}
+
 	return &UserHandler{
 		userService: userService,
 		jwtService:  jwtService,
+		opts:        opts,
 		log:         log.Named("user_handler"),
 	}
 }
 
 // LoginUser регистрирует нового пользователя и возвращает JWT токен
 func (h *UserHandler) LoginUser(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context(), h.log)
 	if r.Method != http.MethodPost {
-		h.log.Warn("Invalid request method", zap.String("path", r.URL.Path), zap.String("method", r.Method))
-		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		log.Warn("Invalid request method", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+		writeError(w, http.StatusMethodNotAllowed, models.CodeMethodNotAllowed, "Invalid request method", "")
 		return
 	}
-	h.log.Info("Handling register user request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+	log.Info("Handling register user request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
 
 	// Извлечение данных из запроса
-	var userReq models.UserRequest
-	if err := json.NewDecoder(r.Body).Decode(&userReq); err != nil {
-		h.log.Warn("Invalid request body", zap.Error(err))
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	var userReq models.RegisterRequest
+	if !decodeBody(w, r, &userReq, log) {
 		return
 	}
 	defer r.Body.Close()
 
-	// Валидация данных
-	if userReq.Username == "" || userReq.Password == "" {
-		h.log.Warn("Username and password are required")
-		http.Error(w, "Username and password are required", http.StatusBadRequest)
-		return
-	}
-
-	// Регистрация пользователя
-	user, err := h.userService.LoginUser(r.Context(), userReq.Username, userReq.Password)
+	// Регистрация пользователя (включая валидацию имени, пароля и адреса);
+	// о всех нарушениях правил сообщается одним ответом 422
+	user, err := h.userService.LoginUser(r.Context(), userReq.Username, userReq.Password, userReq.Email)
 	if err != nil {
-		h.log.Error("Failed to register user",
+		if writeValidationErrors(w, err) {
+			log.Warn("Invalid registration data", zap.Error(err))
+			return
+		}
+		if writeServiceError(w, err) {
+			return
+		}
+		log.Error("Failed to register user",
 			zap.String("username", userReq.Username),
 			zap.Error(err))
-		http.Error(w, fmt.Sprintf("Failed to register user: %v", err), http.StatusInternalServerError)
+		writeInternalError(w)
 		return
 	}
 
 	// Генерация JWT токена
-	token, err := h.jwtService.GenerateToken(user.ID.String())
+	token, err := h.jwtService.GenerateToken(user.ID.String(), user.Role)
 	if err != nil {
-		h.log.Error("Failed to generate token",
+		log.Error("Failed to generate token",
 			zap.String("user_id", user.ID.String()),
 			zap.Error(err))
-		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		writeInternalError(w)
 		return
 	}
 
-	// Установка токена в заголовок
+	// Установка токена и адреса созданного ресурса в заголовки
 	w.Header().Set("Authorization", token)
+	w.Header().Set("Location", h.opts.APIPrefix+"/users/"+user.ID.String())
 
 	// Сериализация ответа в JSON
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 
-	response := map[string]interface{}{
-		"user":  user,
-		"token": token,
+	response := models.RegisterResponse{
+		User:  user,
+		Token: token,
 	}
 
 	if err := json.NewEncoder(w).Encode(response); err != nil {
-		h.log.Error("Failed to encode response", zap.Error(err))
+		log.Error("Failed to encode response", zap.Error(err))
 		return
 	}
 
-	h.log.Info("Successfully registered user",
+	log.Info("Successfully registered user",
 		zap.String("user_id", user.ID.String()),
 		zap.String("username", user.Username))
 }
 
-// GetUserStatus возвращает информацию о пользователе
-func (h *UserHandler) GetUserStatus(w http.ResponseWriter, r *http.Request) {
-	h.log.Info("Handling get user status request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+// CheckUsernameAvailable сообщает, свободно ли имя пользователя для регистрации
+func (h *UserHandler) CheckUsernameAvailable(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context(), h.log)
+	if r.Method != http.MethodGet {
+		log.Warn("Invalid request method", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+		writeError(w, http.StatusMethodNotAllowed, models.CodeMethodNotAllowed, "Invalid request method", "")
+		return
+	}
+	log.Info("Handling username availability request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
 
-	// Извлечение ID пользователя из токена
-	claims, err := h.jwtService.ValidateToken(r.Header.Get("Authorization"))
+	username := r.URL.Query().Get("username")
+	if username == "" {
+		log.Warn("Username is required")
+		writeError(w, http.StatusBadRequest, models.CodeInvalidParameter, "Username is required", "username")
+		return
+	}
+
+	available, err := h.userService.IsUsernameAvailable(r.Context(), username)
 	if err != nil {
-		h.log.Warn("Invalid token", zap.Error(err))
-		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		log.Error("Failed to check username availability",
+			zap.String("username", username),
+			zap.Error(err))
+		writeInternalError(w)
+		return
+	}
+
+	// Сериализация ответа в JSON
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	response := models.UsernameAvailabilityResponse{
+		Username:  service.NormalizeUsername(username),
+		Available: available,
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Error("Failed to encode response", zap.Error(err))
 		return
 	}
+}
 
-	userIDStr := claims.UserID
-	h.log.Debug("Extracted user ID from URL", zap.String("user_id", userIDStr))
+// GetUserStatus возвращает информацию о пользователе
+func (h *UserHandler) GetUserStatus(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context(), h.log)
+	log.Info("Handling get user status request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
 
-	userID, err := uuid.Parse(userIDStr)
-	if err != nil {
-		h.log.Warn("Invalid user ID format", zap.String("user_id", userIDStr), zap.Error(err))
-		http.Error(w, "Invalid user ID format", http.StatusBadRequest)
+	// Пользователь аутентифицирован JWTAuth
+	authUser, ok := currentUser(w, r, log)
+	if !ok {
 		return
 	}
+	userID := authUser.UserID
 
-	h.log.Debug("Getting user by ID", zap.String("user_id", userID.String()))
-	user, err := h.userService.GetUserByID(r.Context(), userID)
+	log.Debug("Getting user status", zap.String("user_id", userID.String()))
+	user, err := h.userService.GetUserStatus(r.Context(), userID)
 	if err != nil {
-		h.log.Error("Failed to get user",
+		if writeServiceError(w, err) {
+			return
+		}
+		log.Error("Failed to get user",
 			zap.String("user_id", userID.String()),
 			zap.Error(err))
-		http.Error(w, fmt.Sprintf("Failed to get user: %v", err), http.StatusInternalServerError)
-		return
-	}
-
-	if user == nil {
-		h.log.Warn("User not found", zap.String("user_id", userID.String()))
-		http.Error(w, "User not found", http.StatusNotFound)
+		writeInternalError(w)
 		return
 	}
 
@@ -139,109 +207,200 @@ func (h *UserHandler) GetUserStatus(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 
 	if err := json.NewEncoder(w).Encode(user); err != nil {
-		h.log.Error("Failed to encode response", zap.Error(err))
+		log.Error("Failed to encode response", zap.Error(err))
 		return
 	}
 
-	h.log.Info("Successfully returned user status", zap.String("user_id", userID.String()))
+	log.Info("Successfully returned user status", zap.String("user_id", userID.String()))
 }
 
 // GetLeaderboard возвращает список пользователей с наибольшим балансом
 func (h *UserHandler) GetLeaderboard(w http.ResponseWriter, r *http.Request) {
-	h.log.Info("Handling get leaderboard request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+	log := logger.FromContext(r.Context(), h.log)
+	log.Info("Handling get leaderboard request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
 
-	// Получение параметра limit из query string
-	limitStr := r.URL.Query().Get("limit")
-	limit := 10 // По умолчанию 10 пользователей
+	// Получение параметров limit и offset из query string
+	limit, ok := h.leaderboardLimit(w, r, log)
+	if !ok {
+		return
+	}
+	w.Header().Set(LimitHeader, strconv.Itoa(limit))
 
-	if limitStr != "" {
-		parsedLimit, err := strconv.Atoi(limitStr)
-		if err == nil && parsedLimit > 0 {
-			limit = parsedLimit
-		} else if err != nil {
-			h.log.Warn("Invalid limit parameter", zap.String("limit", limitStr), zap.Error(err))
+	offset := 0
+	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
+		parsedOffset, err := strconv.Atoi(offsetStr)
+		if err != nil || parsedOffset < 0 {
+			log.Warn("Invalid offset parameter", zap.String("offset", offsetStr))
+			writeError(w, http.StatusBadRequest, models.CodeInvalidParameter, "Invalid offset parameter", "offset")
+			return
 		}
+		offset = parsedOffset
 	}
 
-	h.log.Debug("Getting leaderboard", zap.Int("limit", limit))
-	users, err := h.userService.GetLeaderboard(r.Context(), limit)
+	envelope, err := wantsEnvelope(r)
 	if err != nil {
-		h.log.Error("Failed to get leaderboard", zap.Int("limit", limit), zap.Error(err))
-		http.Error(w, fmt.Sprintf("Failed to get leaderboard: %v", err), http.StatusInternalServerError)
+		log.Warn("Invalid envelope parameter", zap.String("envelope", r.URL.Query().Get(EnvelopeParam)))
+		writeError(w, http.StatusBadRequest, models.CodeInvalidParameter, "Invalid envelope parameter", "envelope")
 		return
 	}
 
-	// Сериализация ответа в JSON
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
+	asCSV, err := wantsCSV(r)
+	if err != nil {
+		log.Warn("Invalid format parameter", zap.String("format", r.URL.Query().Get(FormatParam)))
+		writeError(w, http.StatusBadRequest, models.CodeInvalidParameter, "Invalid format parameter, expected json or csv", "format")
+		return
+	}
+	if asCSV && envelope {
+		writeError(w, http.StatusBadRequest, models.CodeInvalidParameter, "envelope is not supported with CSV format", "envelope")
+		return
+	}
 
-	if err := json.NewEncoder(w).Encode(users); err != nil {
-		h.log.Error("Failed to encode response", zap.Error(err))
+	filter, fieldErr := parseLeaderboardFilter(r)
+	if fieldErr != nil {
+		log.Warn("Invalid leaderboard filter", zap.Error(fieldErr))
+		writeFieldError(w, fieldErr)
 		return
 	}
 
-	h.log.Info("Successfully returned leaderboard", zap.Int("users_count", len(users)))
-}
+	// Таблица лидеров по баллам, заработанным за период
+	if period := r.URL.Query().Get("period"); period != "" {
+		if filter != (models.LeaderboardFilter{}) {
+			log.Warn("Leaderboard filters used with period", zap.String("period", period))
+			writeError(w, http.StatusBadRequest, models.CodeInvalidParameter, "min_points, max_points and created_after are not supported with period", "period")
+			return
+		}
+		h.getPeriodLeaderboard(w, r, period, limit, offset, envelope, asCSV)
+		return
+	}
 
-// CompleteTask отмечает задание как выполненное и начисляет баллы
-func (h *UserHandler) CompleteTask(w http.ResponseWriter, r *http.Request) {
-	h.log.Info("Handling complete task request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+	log.Debug("Getting leaderboard", zap.Int("limit", limit), zap.Int("offset", offset))
+	users, total, err := h.userService.GetLeaderboard(r.Context(), filter, limit, offset)
+	if err != nil {
+		var fieldErr *validate.FieldError
+		if errors.As(err, &fieldErr) {
+			writeFieldError(w, fieldErr)
+			return
+		}
+		log.Error("Failed to get leaderboard", zap.Int("limit", limit), zap.Int("offset", offset), zap.Error(err))
+		writeInternalError(w)
+		return
+	}
+	if refreshedAt, ok := h.userService.LeaderboardViewRefreshedAt(); ok {
+		w.Header().Set(LeaderboardRefreshedAtHeader, refreshedAt.UTC().Format(models.TimeLayout))
+	}
 
-	// Извлечение ID пользователя из токена
-	claims, err := h.jwtService.ValidateToken(r.Header.Get("Authorization"))
+	if asCSV {
+		// Порядок таблицы однозначен, поэтому место равно позиции с учетом offset
+		err = writeCSV(w, "leaderboard.csv", leaderboardCSVHeader, total, len(users), func(i int) []string {
+			return []string{strconv.Itoa(offset + i + 1), users[i].ID.String(), users[i].Username, strconv.Itoa(users[i].Points)}
+		})
+	} else {
+		// Сериализация ответа в JSON с поддержкой условных запросов
+		err = writePage(w, r, users, total, limit, offset, envelope)
+	}
 	if err != nil {
-		h.log.Warn("Invalid token", zap.Error(err))
-		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		log.Error("Failed to encode response", zap.Error(err))
 		return
 	}
 
-	userIDStr := claims.UserID
-	h.log.Debug("Extracted user ID from URL", zap.String("user_id", userIDStr))
+	log.Info("Successfully returned leaderboard", zap.Int("users_count", len(users)))
+}
+
+// leaderboardLimit возвращает размер страницы таблицы лидеров из параметра limit, уменьшенный
+// до MaxLeaderboardLimit. При некорректном значении отвечает 400 и возвращает false.
+func (h *UserHandler) leaderboardLimit(w http.ResponseWriter, r *http.Request, log *zap.Logger) (int, bool) {
+	limitStr := r.URL.Query().Get("limit")
+	if limitStr == "" {
+		return min(h.opts.DefaultLeaderboardLimit, h.opts.MaxLeaderboardLimit), true
+	}
+	parsedLimit, err := strconv.Atoi(limitStr)
+	if err != nil || parsedLimit <= 0 {
+		log.Warn("Invalid limit parameter", zap.String("limit", limitStr))
+		writeError(w, http.StatusBadRequest, models.CodeInvalidParameter, "Invalid limit parameter", "limit")
+		return 0, false
+	}
+	// Ограничение размера страницы на стороне сервера
+	return min(parsedLimit, h.opts.MaxLeaderboardLimit), true
+}
 
-	userID, err := uuid.Parse(userIDStr)
+// parseLeaderboardFilter разбирает необязательные параметры отбора таблицы лидеров:
+// min_points, max_points (целые числа) и created_after (RFC3339)
+func parseLeaderboardFilter(r *http.Request) (models.LeaderboardFilter, *validate.FieldError) {
+	var filter models.LeaderboardFilter
+
+	for _, param := range []struct {
+		name string
+		dst  **int
+	}{
+		{"min_points", &filter.MinPoints},
+		{"max_points", &filter.MaxPoints},
+	} {
+		value := r.URL.Query().Get(param.name)
+		if value == "" {
+			continue
+		}
+		points, err := strconv.Atoi(value)
+		if err != nil {
+			return filter, &validate.FieldError{Field: param.name, Message: "must be an integer"}
+		}
+		*param.dst = &points
+	}
+
+	createdAfter, err := parseTimeParam(r, "created_after")
 	if err != nil {
-		h.log.Warn("Invalid user ID format", zap.String("user_id", userIDStr), zap.Error(err))
-		http.Error(w, "Invalid user ID format", http.StatusBadRequest)
+		return filter, &validate.FieldError{Field: "created_after", Message: "must be an RFC3339 timestamp"}
+	}
+	filter.CreatedAfter = createdAfter
+
+	return filter, nil
+}
+
+// CompleteTask отмечает задание как выполненное и начисляет баллы
+func (h *UserHandler) CompleteTask(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context(), h.log)
+	log.Info("Handling complete task request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+
+	// Пользователь аутентифицирован JWTAuth
+	authUser, ok := currentUser(w, r, log)
+	if !ok {
 		return
 	}
+	userID := authUser.UserID
 
 	// Десериализация запроса
 	var taskRequest models.TaskRequest
-	if err := json.NewDecoder(r.Body).Decode(&taskRequest); err != nil {
-		h.log.Warn("Invalid request body", zap.Error(err))
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if !decodeBody(w, r, &taskRequest, log) {
 		return
 	}
 	defer r.Body.Close()
+	if err := validate.StructAll(&taskRequest); err != nil {
+		log.Warn("Request validation failed", zap.Error(err))
+		writeValidationErrors(w, err)
+		return
+	}
 
-	h.log.Debug("Received task request",
+	log.Debug("Received task request",
 		zap.String("user_id", userID.String()),
 		zap.String("task_type", taskRequest.TaskType),
 		zap.Int("points", taskRequest.Points))
 
-	// Валидация запроса
-	if taskRequest.TaskType == "" {
-		h.log.Warn("Task type is required", zap.String("user_id", userID.String()))
-		http.Error(w, "Task type is required", http.StatusBadRequest)
-		return
-	}
-
-	if taskRequest.Points <= 0 {
-		h.log.Warn("Points must be positive",
-			zap.String("user_id", userID.String()),
-			zap.Int("points", taskRequest.Points))
-		http.Error(w, "Points must be positive", http.StatusBadRequest)
-		return
-	}
-
 	task, err := h.userService.CompleteTask(r.Context(), userID, taskRequest)
 	if err != nil {
-		h.log.Error("Failed to complete task",
+		// Превышение лимита баллов за задание - 400, как и прочие ограничения сервиса
+		var fieldErr *validate.FieldError
+		if errors.As(err, &fieldErr) {
+			writeFieldError(w, fieldErr)
+			return
+		}
+		if writeServiceError(w, err) {
+			return
+		}
+		log.Error("Failed to complete task",
 			zap.String("user_id", userID.String()),
 			zap.String("task_type", taskRequest.TaskType),
 			zap.Int("points", taskRequest.Points),
 			zap.Error(err))
-		http.Error(w, fmt.Sprintf("Failed to complete task: %v", err), http.StatusInternalServerError)
+		writeInternalError(w)
 		return
 	}
 
@@ -250,11 +409,11 @@ func (h *UserHandler) CompleteTask(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 
 	if err := json.NewEncoder(w).Encode(task); err != nil {
-		h.log.Error("Failed to encode response", zap.Error(err))
+		log.Error("Failed to encode response", zap.Error(err))
 		return
 	}
 
-	h.log.Info("Successfully completed task",
+	log.Info("Successfully completed task",
 		zap.String("user_id", userID.String()),
 		zap.String("task_id", task.ID.String()),
 		zap.String("task_type", task.TaskType),
@@ -263,72 +422,535 @@ func (h *UserHandler) CompleteTask(w http.ResponseWriter, r *http.Request) {
 
 // AddReferrer добавляет реферальный код
 func (h *UserHandler) AddReferrer(w http.ResponseWriter, r *http.Request) {
-	h.log.Info("Handling add referrer request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+	log := logger.FromContext(r.Context(), h.log)
+	log.Info("Handling add referrer request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+
+	// Пользователь аутентифицирован JWTAuth
+	authUser, ok := currentUser(w, r, log)
+	if !ok {
+		return
+	}
+	userID := authUser.UserID
+
+	// Десериализация запроса
+	var referrerRequest models.ReferrerRequest
+	if !decodeBody(w, r, &referrerRequest, log) {
+		return
+	}
+	defer r.Body.Close()
+
+	log.Debug("Received referrer request",
+		zap.String("user_id", userID.String()),
+		zap.String("referrer_id", referrerRequest.ReferrerID),
+		zap.String("referral_code", referrerRequest.ReferralCode))
+
+	// Реферер указывается ровно одним способом: ID или реферальным кодом
+	var choiceErr error
+	switch {
+	case referrerRequest.ReferrerID != "" && referrerRequest.ReferralCode != "":
+		choiceErr = &validate.FieldError{Field: "referral_code", Message: "cannot be combined with referrer_id"}
+	case referrerRequest.ReferrerID == "" && referrerRequest.ReferralCode == "":
+		choiceErr = &validate.FieldError{Field: "referrer_id", Message: "referrer_id or referral_code is required"}
+	}
+	if err := validate.Join(validate.StructAll(&referrerRequest), choiceErr); err != nil {
+		log.Warn("Request validation failed", zap.Error(err))
+		writeValidationErrors(w, err)
+		return
+	}
 
-	// Извлечение ID пользователя из токена
-	claims, err := h.jwtService.ValidateToken(r.Header.Get("Authorization"))
+	var (
+		referrerID uuid.UUID
+		err        error
+	)
+	switch {
+	case referrerRequest.ReferralCode != "":
+		referrerID, err = h.userService.ResolveReferralCode(r.Context(), referrerRequest.ReferralCode)
+		if err != nil {
+			if writeServiceError(w, err) {
+				return
+			}
+			log.Error("Failed to resolve referral code",
+				zap.String("referral_code", referrerRequest.ReferralCode),
+				zap.Error(err))
+			writeInternalError(w)
+			return
+		}
+	default:
+		referrerID, err = uuid.Parse(referrerRequest.ReferrerID)
+		if err != nil {
+			log.Warn("Invalid referrer ID format",
+				zap.String("user_id", userID.String()),
+				zap.String("referrer_id", referrerRequest.ReferrerID),
+				zap.Error(err))
+			writeError(w, http.StatusBadRequest, models.CodeValidationFailed, "Invalid referrer ID format", "referrer_id")
+			return
+		}
+	}
+
+	user, err := h.userService.AddReferrer(r.Context(), userID, referrerID)
 	if err != nil {
-		h.log.Warn("Invalid token", zap.Error(err))
-		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		if errors.Is(err, models.ErrReferrerAlreadySet) {
+			log.Warn("User already has a different referrer",
+				zap.String("user_id", userID.String()),
+				zap.String("referrer_id", referrerID.String()))
+		}
+		if writeServiceError(w, err) {
+			return
+		}
+		log.Error("Failed to add referrer",
+			zap.String("user_id", userID.String()),
+			zap.String("referrer_id", referrerID.String()),
+			zap.Error(err))
+		writeInternalError(w)
 		return
 	}
 
-	userIDStr := claims.UserID
-	h.log.Debug("Extracted user ID from URL", zap.String("user_id", userIDStr))
+	// Сериализация ответа в JSON
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(user); err != nil {
+		log.Error("Failed to encode response", zap.Error(err))
+		return
+	}
+
+	log.Info("Successfully added referrer",
+		zap.String("user_id", userID.String()),
+		zap.String("referrer_id", referrerID.String()))
+}
+
+// MeReferrer обрабатывает /users/me/referrer: POST добавляет реферера, DELETE сбрасывает его
+func (h *UserHandler) MeReferrer(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		h.AddReferrer(w, r)
+	case http.MethodDelete:
+		h.RemoveReferrer(w, r)
+	default:
+		log := logger.FromContext(r.Context(), h.log)
+		log.Warn("Invalid request method", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+		writeError(w, http.StatusMethodNotAllowed, models.CodeMethodNotAllowed, "Invalid request method", "")
+	}
+}
+
+// RemoveReferrer сбрасывает реферера текущего пользователя и отменяет начисленные за него бонусы
+func (h *UserHandler) RemoveReferrer(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context(), h.log)
+	log.Info("Handling remove referrer request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
 
-	userID, err := uuid.Parse(userIDStr)
+	// Пользователь аутентифицирован JWTAuth
+	authUser, ok := currentUser(w, r, log)
+	if !ok {
+		return
+	}
+	userID := authUser.UserID
+
+	user, err := h.userService.RemoveReferrer(r.Context(), userID)
 	if err != nil {
-		h.log.Warn("Invalid user ID format", zap.String("user_id", userIDStr), zap.Error(err))
-		http.Error(w, "Invalid user ID format", http.StatusBadRequest)
+		if writeServiceError(w, err) {
+			return
+		}
+		log.Error("Failed to remove referrer",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		writeInternalError(w)
+		return
+	}
+
+	// Сериализация ответа в JSON
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(user); err != nil {
+		log.Error("Failed to encode response", zap.Error(err))
+		return
+	}
+
+	log.Info("Successfully removed referrer", zap.String("user_id", userID.String()))
+}
+
+// ChangePassword меняет пароль текущего пользователя
+func (h *UserHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context(), h.log)
+	if r.Method != http.MethodPost {
+		log.Warn("Invalid request method", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+		writeError(w, http.StatusMethodNotAllowed, models.CodeMethodNotAllowed, "Invalid request method", "")
 		return
 	}
+	log.Info("Handling change password request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+
+	// Пользователь аутентифицирован JWTAuth
+	authUser, ok := currentUser(w, r, log)
+	if !ok {
+		return
+	}
+	userID := authUser.UserID
 
 	// Десериализация запроса
-	var referrerRequest models.ReferrerRequest
-	if err := json.NewDecoder(r.Body).Decode(&referrerRequest); err != nil {
-		h.log.Warn("Invalid request body", zap.Error(err))
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	var req models.ChangePasswordRequest
+	if !decodeJSON(w, r, &req, log) {
 		return
 	}
 	defer r.Body.Close()
 
-	h.log.Debug("Received referrer request",
+	err := h.userService.ChangePassword(r.Context(), userID, req.CurrentPassword, req.NewPassword)
+	if err != nil {
+		var fieldErr *validate.FieldError
+		if errors.As(err, &fieldErr) {
+			writeFieldError(w, fieldErr)
+			return
+		}
+		if writeServiceError(w, err) {
+			return
+		}
+		log.Error("Failed to change password",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		writeInternalError(w)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+	log.Info("Successfully changed password", zap.String("user_id", userID.String()))
+}
+
+// TransferPoints переводит баллы текущего пользователя другому пользователю
+func (h *UserHandler) TransferPoints(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context(), h.log)
+	if r.Method != http.MethodPost {
+		log.Warn("Invalid request method", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+		writeError(w, http.StatusMethodNotAllowed, models.CodeMethodNotAllowed, "Invalid request method", "")
+		return
+	}
+	log.Info("Handling transfer points request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+
+	// Пользователь аутентифицирован JWTAuth
+	authUser, ok := currentUser(w, r, log)
+	if !ok {
+		return
+	}
+	fromID := authUser.UserID
+
+	// Десериализация запроса
+	var req models.TransferRequest
+	if !decodeJSON(w, r, &req, log) {
+		return
+	}
+	defer r.Body.Close()
+
+	toID, err := uuid.Parse(req.To)
+	if err != nil {
+		log.Warn("Invalid receiver ID format", zap.String("to", req.To), zap.Error(err))
+		writeError(w, http.StatusBadRequest, models.CodeValidationFailed, "Invalid receiver ID format", "to")
+		return
+	}
+
+	if toID == fromID {
+		log.Warn("User cannot transfer points to themselves", zap.String("user_id", fromID.String()))
+		writeError(w, http.StatusBadRequest, models.CodeSelfTransfer, "User cannot transfer points to themselves", "to")
+		return
+	}
+
+	balance, err := h.userService.TransferPoints(r.Context(), fromID, toID, req.Amount)
+	if err != nil {
+		if writeServiceError(w, err) {
+			return
+		}
+		log.Error("Failed to transfer points",
+			zap.String("from_id", fromID.String()),
+			zap.String("to_id", toID.String()),
+			zap.Error(err))
+		writeInternalError(w)
+		return
+	}
+
+	// Сериализация ответа в JSON
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	response := models.TransferResponse{
+		From:    fromID,
+		To:      toID,
+		Amount:  req.Amount,
+		Balance: balance,
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Error("Failed to encode response", zap.Error(err))
+		return
+	}
+
+	log.Info("Successfully transferred points",
+		zap.String("from_id", fromID.String()),
+		zap.String("to_id", toID.String()),
+		zap.Int("amount", req.Amount))
+}
+
+// GetUserTasks возвращает историю выполненных текущим пользователем заданий
+func (h *UserHandler) GetUserTasks(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context(), h.log)
+	if r.Method != http.MethodGet {
+		log.Warn("Invalid request method", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+		writeError(w, http.StatusMethodNotAllowed, models.CodeMethodNotAllowed, "Invalid request method", "")
+		return
+	}
+	log.Info("Handling get user tasks request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+
+	// Пользователь аутентифицирован JWTAuth
+	authUser, ok := currentUser(w, r, log)
+	if !ok {
+		return
+	}
+	userID := authUser.UserID
+
+	// Получение параметров фильтрации и пагинации из query string
+	query := r.URL.Query()
+	taskType := query.Get("task_type")
+
+	limit := DefaultTasksLimit
+	if limitStr := query.Get("limit"); limitStr != "" {
+		parsedLimit, err := strconv.Atoi(limitStr)
+		if err != nil || parsedLimit <= 0 {
+			log.Warn("Invalid limit parameter", zap.String("limit", limitStr))
+			writeError(w, http.StatusBadRequest, models.CodeInvalidParameter, "Invalid limit parameter", "limit")
+			return
+		}
+		limit = parsedLimit
+	}
+
+	offset := 0
+	if offsetStr := query.Get("offset"); offsetStr != "" {
+		parsedOffset, err := strconv.Atoi(offsetStr)
+		if err != nil || parsedOffset < 0 {
+			log.Warn("Invalid offset parameter", zap.String("offset", offsetStr))
+			writeError(w, http.StatusBadRequest, models.CodeInvalidParameter, "Invalid offset parameter", "offset")
+			return
+		}
+		offset = parsedOffset
+	}
+
+	tasks, err := h.userService.GetUserTasks(r.Context(), userID, taskType, limit, offset)
+	if err != nil {
+		log.Error("Failed to get user tasks",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		writeInternalError(w)
+		return
+	}
+
+	// Пустой список сериализуется как [], а не null
+	if tasks == nil {
+		tasks = []*models.Task{}
+	}
+
+	// Сериализация ответа в JSON
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(tasks); err != nil {
+		log.Error("Failed to encode response", zap.Error(err))
+		return
+	}
+
+	log.Info("Successfully returned user tasks",
 		zap.String("user_id", userID.String()),
-		zap.String("referrer_id", referrerRequest.ReferrerID))
+		zap.Int("tasks_count", len(tasks)))
+}
+
+// GetUserSummary возвращает сводку профиля текущего пользователя: данные пользователя,
+// место в таблице лидеров, количество рефералов и последние задания
+func (h *UserHandler) GetUserSummary(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context(), h.log)
+	log.Info("Handling get user summary request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
 
-	// Валидация запроса
-	if referrerRequest.ReferrerID == "" {
-		h.log.Warn("Referrer ID is required", zap.String("user_id", userID.String()))
-		http.Error(w, "Referrer ID is required", http.StatusBadRequest)
+	// Пользователь аутентифицирован JWTAuth
+	authUser, ok := currentUser(w, r, log)
+	if !ok {
 		return
 	}
+	userID := authUser.UserID
 
-	referrerID, err := uuid.Parse(referrerRequest.ReferrerID)
+	summary, err := h.userService.GetUserSummary(r.Context(), userID)
 	if err != nil {
-		h.log.Warn("Invalid referrer ID format",
+		if writeServiceError(w, err) {
+			return
+		}
+		log.Error("Failed to get user summary",
 			zap.String("user_id", userID.String()),
-			zap.String("referrer_id", referrerRequest.ReferrerID),
 			zap.Error(err))
-		http.Error(w, "Invalid referrer ID format", http.StatusBadRequest)
+		writeInternalError(w)
+		return
+	}
+
+	// Сериализация ответа в JSON
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(summary); err != nil {
+		log.Error("Failed to encode response", zap.Error(err))
+		return
+	}
+
+	log.Info("Successfully returned user summary", zap.String("user_id", userID.String()))
+}
+
+// RotateReferralCode выдает текущему пользователю новый реферальный код и возвращает его;
+// прежний код перестает разрешаться сразу
+func (h *UserHandler) RotateReferralCode(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context(), h.log)
+	log.Info("Handling rotate referral code request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+
+	// Пользователь аутентифицирован JWTAuth
+	authUser, ok := currentUser(w, r, log)
+	if !ok {
 		return
 	}
+	userID := authUser.UserID
 
-	// Проверка, что пользователь не добавляет сам себя как реферера
-	if userID == referrerID {
-		h.log.Warn("User cannot add themselves as referrer",
+	code, err := h.userService.RegenerateReferralCode(r.Context(), userID)
+	if err != nil {
+		if writeServiceError(w, err) {
+			return
+		}
+		log.Error("Failed to rotate referral code",
 			zap.String("user_id", userID.String()),
-			zap.String("referrer_id", referrerID.String()))
-		http.Error(w, "User cannot add themselves as referrer", http.StatusBadRequest)
+			zap.Error(err))
+		writeInternalError(w)
 		return
 	}
 
-	user, err := h.userService.AddReferrer(r.Context(), userID, referrerID)
+	// Сериализация ответа в JSON
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(models.ReferralCodeResponse{ReferralCode: code}); err != nil {
+		log.Error("Failed to encode response", zap.Error(err))
+		return
+	}
+
+	log.Info("Successfully rotated referral code", zap.String("user_id", userID.String()))
+}
+
+// Logout отзывает токен, с которым выполнен запрос
+func (h *UserHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context(), h.log)
+	if r.Method != http.MethodPost {
+		log.Warn("Invalid request method", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+		writeError(w, http.StatusMethodNotAllowed, models.CodeMethodNotAllowed, "Invalid request method", "")
+		return
+	}
+	log.Info("Handling logout request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+
+	// Пользователь аутентифицирован JWTAuth
+	user, ok := currentUser(w, r, log)
+	if !ok {
+		return
+	}
+
+	if err := h.jwtService.RevokeToken(user.Claims); err != nil {
+		log.Warn("Failed to revoke token", zap.String("user_id", user.UserID.String()), zap.Error(err))
+		writeError(w, http.StatusBadRequest, models.CodeTokenNotRevocable, "Token cannot be revoked", "")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+	log.Info("Successfully logged out", zap.String("user_id", user.UserID.String()))
+}
+
+// Me обрабатывает /users/me: GET возвращает данные пользователя, PATCH изменяет профиль, DELETE удаляет учетную запись
+func (h *UserHandler) Me(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.GetUserStatus(w, r)
+	case http.MethodPatch:
+		h.UpdateProfile(w, r)
+	case http.MethodDelete:
+		h.DeleteUser(w, r)
+	default:
+		log := logger.FromContext(r.Context(), h.log)
+		log.Warn("Invalid request method", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+		writeError(w, http.StatusMethodNotAllowed, models.CodeMethodNotAllowed, "Invalid request method", "")
+	}
+}
+
+// DeleteUser удаляет учетную запись текущего пользователя и отзывает его токен
+func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context(), h.log)
+	if r.Method != http.MethodDelete {
+		log.Warn("Invalid request method", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+		writeError(w, http.StatusMethodNotAllowed, models.CodeMethodNotAllowed, "Invalid request method", "")
+		return
+	}
+	log.Info("Handling delete user request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+
+	// Пользователь аутентифицирован JWTAuth
+	authUser, ok := currentUser(w, r, log)
+	if !ok {
+		return
+	}
+	userID := authUser.UserID
+
+	if err := h.userService.DeleteUser(r.Context(), userID); err != nil {
+		if writeServiceError(w, err) {
+			return
+		}
+		log.Error("Failed to delete user",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		writeInternalError(w)
+		return
+	}
+
+	// Токен удаленного пользователя больше не должен приниматься
+	if err := h.jwtService.RevokeToken(authUser.Claims); err != nil {
+		log.Warn("Failed to revoke token", zap.String("user_id", userID.String()), zap.Error(err))
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+
+	log.Info("Successfully deleted user", zap.String("user_id", userID.String()))
+}
+
+// UpdateProfile изменяет имя текущего пользователя
+func (h *UserHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context(), h.log)
+	if r.Method != http.MethodPatch {
+		log.Warn("Invalid request method", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+		writeError(w, http.StatusMethodNotAllowed, models.CodeMethodNotAllowed, "Invalid request method", "")
+		return
+	}
+	log.Info("Handling update profile request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+
+	// Пользователь аутентифицирован JWTAuth
+	authUser, ok := currentUser(w, r, log)
+	if !ok {
+		return
+	}
+	userID := authUser.UserID
+
+	// Десериализация запроса
+	var req models.UpdateProfileRequest
+	if !decodeJSON(w, r, &req, log) {
+		return
+	}
+	defer r.Body.Close()
+
+	user, err := h.userService.UpdateUsername(r.Context(), userID, req.Username, req.Version)
 	if err != nil {
-		h.log.Error("Failed to add referrer",
+		var fieldErr *validate.FieldError
+		if errors.As(err, &fieldErr) {
+			writeFieldError(w, fieldErr)
+			return
+		}
+		if writeServiceError(w, err) {
+			return
+		}
+		log.Error("Failed to update profile",
 			zap.String("user_id", userID.String()),
-			zap.String("referrer_id", referrerID.String()),
 			zap.Error(err))
-		http.Error(w, fmt.Sprintf("Failed to add referrer: %v", err), http.StatusInternalServerError)
+		writeInternalError(w)
 		return
 	}
 
@@ -337,11 +959,197 @@ func (h *UserHandler) AddReferrer(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 
 	if err := json.NewEncoder(w).Encode(user); err != nil {
-		h.log.Error("Failed to encode response", zap.Error(err))
+		log.Error("Failed to encode response", zap.Error(err))
+		return
+	}
+
+	log.Info("Successfully updated profile", zap.String("user_id", userID.String()))
+}
+
+// getPeriodLeaderboard отправляет таблицу лидеров по баллам, заработанным в текущем периоде
+func (h *UserHandler) getPeriodLeaderboard(w http.ResponseWriter, r *http.Request, period string, limit, offset int, envelope, asCSV bool) {
+	log := logger.FromContext(r.Context(), h.log)
+
+	entries, total, err := h.userService.GetPeriodLeaderboard(r.Context(), period, limit, offset)
+	if err != nil {
+		if writeServiceError(w, err) {
+			return
+		}
+		log.Error("Failed to get period leaderboard", zap.String("period", period), zap.Error(err))
+		writeInternalError(w)
+		return
+	}
+
+	if asCSV {
+		err = writeCSV(w, "leaderboard-"+period+".csv", leaderboardCSVHeader, total, len(entries), func(i int) []string {
+			return []string{strconv.Itoa(entries[i].Rank), entries[i].UserID.String(), entries[i].Username, strconv.Itoa(entries[i].Points)}
+		})
+	} else {
+		// Сериализация ответа в JSON с поддержкой условных запросов
+		err = writePage(w, r, entries, total, limit, offset, envelope)
+	}
+	if err != nil {
+		log.Error("Failed to encode response", zap.Error(err))
+		return
+	}
+
+	log.Info("Successfully returned period leaderboard",
+		zap.String("period", period),
+		zap.Int("entries_count", len(entries)))
+}
+
+// Login проверяет учетные данные пользователя и возвращает JWT токен и текущую серию входов
+func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context(), h.log)
+	if r.Method != http.MethodPost {
+		log.Warn("Invalid request method", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+		writeError(w, http.StatusMethodNotAllowed, models.CodeMethodNotAllowed, "Invalid request method", "")
+		return
+	}
+	log.Info("Handling login request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+
+	// Извлечение данных из запроса
+	var userReq models.UserRequest
+	if !decodeJSON(w, r, &userReq, log) {
+		return
+	}
+	defer r.Body.Close()
+
+	user, bonus, err := h.userService.AuthenticateUser(r.Context(), userReq.Username, userReq.Password)
+	if err != nil {
+		if writeServiceError(w, err) {
+			return
+		}
+		log.Error("Failed to authenticate user",
+			zap.String("username", userReq.Username),
+			zap.Error(err))
+		writeInternalError(w)
+		return
+	}
+
+	// Генерация JWT токена
+	token, err := h.jwtService.GenerateToken(user.ID.String(), user.Role)
+	if err != nil {
+		log.Error("Failed to generate token",
+			zap.String("user_id", user.ID.String()),
+			zap.Error(err))
+		writeInternalError(w)
+		return
+	}
+
+	// Установка токена в заголовок
+	w.Header().Set("Authorization", token)
+
+	// Сериализация ответа в JSON
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	response := models.LoginResponse{
+		User:        user,
+		Token:       token,
+		StreakDays:  user.StreakDays,
+		StreakBonus: bonus,
+	}
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Error("Failed to encode response", zap.Error(err))
+		return
+	}
+
+	log.Info("Successfully logged in user",
+		zap.String("user_id", user.ID.String()),
+		zap.Int("streak_days", user.StreakDays))
+}
+
+// LookupUser возвращает публичный профиль пользователя по имени
+func (h *UserHandler) LookupUser(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context(), h.log)
+	if r.Method != http.MethodGet {
+		log.Warn("Invalid request method", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+		writeError(w, http.StatusMethodNotAllowed, models.CodeMethodNotAllowed, "Invalid request method", "")
+		return
+	}
+	log.Info("Handling lookup user request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+
+	username := r.URL.Query().Get("username")
+	if username == "" {
+		log.Warn("Username is required")
+		writeError(w, http.StatusBadRequest, models.CodeInvalidParameter, "Username is required", "username")
+		return
+	}
+
+	profile, err := h.userService.GetUserByUsername(r.Context(), username)
+	if err != nil {
+		if writeServiceError(w, err) {
+			return
+		}
+		log.Error("Failed to look up user",
+			zap.String("username", username),
+			zap.Error(err))
+		writeInternalError(w)
+		return
+	}
+
+	// Сериализация ответа в JSON
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(profile); err != nil {
+		log.Error("Failed to encode response", zap.Error(err))
 		return
 	}
 
-	h.log.Info("Successfully added referrer",
+	log.Info("Successfully looked up user", zap.String("user_id", profile.ID.String()))
+}
+
+// GetReferrerChain возвращает цепочку рефереров текущего пользователя
+func (h *UserHandler) GetReferrerChain(w http.ResponseWriter, r *http.Request) {
+	log := logger.FromContext(r.Context(), h.log)
+	log.Info("Handling get referrer chain request", zap.String("path", r.URL.Path), zap.String("method", r.Method))
+
+	// Пользователь аутентифицирован JWTAuth
+	authUser, ok := currentUser(w, r, log)
+	if !ok {
+		return
+	}
+	userID := authUser.UserID
+
+	// Глубина по умолчанию максимальная; большие значения уменьшаются до нее
+	depth := h.opts.MaxReferrerChainDepth
+	if depthStr := r.URL.Query().Get("depth"); depthStr != "" {
+		parsedDepth, err := strconv.Atoi(depthStr)
+		if err != nil || parsedDepth <= 0 {
+			log.Warn("Invalid depth parameter", zap.String("depth", depthStr))
+			writeError(w, http.StatusBadRequest, models.CodeInvalidParameter, "Invalid depth parameter", "depth")
+			return
+		}
+		depth = min(parsedDepth, h.opts.MaxReferrerChainDepth)
+	}
+
+	chain, err := h.userService.GetReferrerChain(r.Context(), userID, depth)
+	if err != nil {
+		log.Error("Failed to get referrer chain",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		writeInternalError(w)
+		return
+	}
+
+	// Пустой список сериализуется как [], а не null
+	if chain == nil {
+		chain = []*models.ReferrerChainEntry{}
+	}
+
+	// Сериализация ответа в JSON
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(chain); err != nil {
+		log.Error("Failed to encode response", zap.Error(err))
+		return
+	}
+
+	log.Info("Successfully returned referrer chain",
 		zap.String("user_id", userID.String()),
-		zap.String("referrer_id", referrerID.String()))
+		zap.Int("chain_length", len(chain)))
 }
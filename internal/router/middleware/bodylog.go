@@ -0,0 +1,190 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/logger"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// redactedValue значение, которым заменяются скрытые поля и заголовки
+const redactedValue = "[REDACTED]"
+
+// BodyLogOptions настройки логирования тел запросов и ответов
+type BodyLogOptions struct {
+	// Enabled включает логирование; тела пишутся, только если логгер включен на уровне debug
+	Enabled bool
+	// MaxBytes максимальный размер записываемого в лог тела; сверх него тело обрезается
+	MaxBytes int
+	// RedactFields имена полей JSON, значения которых скрываются на любом уровне вложенности
+	RedactFields []string
+	// RedactHeaders имена заголовков, значения которых скрываются
+	RedactHeaders []string
+}
+
+// BodyLogger логирует на уровне debug заголовки и тела запроса и ответа, скрывая
+// значения полей и заголовков из opts. Тело запроса считывается целиком и подменяется
+// буфером, поэтому обработчик читает его как обычно; ответ перехватывается не более
+// чем на MaxBytes. Уровень проверяется для каждого запроса, поэтому логирование
+// включается и выключается вместе с уровнем логгера без перезапуска.
+func BodyLogger(opts BodyLogOptions, base *zap.Logger) Middleware {
+	fields := make(map[string]struct{}, len(opts.RedactFields))
+	for _, field := range opts.RedactFields {
+		fields[strings.ToLower(field)] = struct{}{}
+	}
+	headers := make(map[string]struct{}, len(opts.RedactHeaders))
+	for _, header := range opts.RedactHeaders {
+		headers[http.CanonicalHeaderKey(header)] = struct{}{}
+	}
+
+	return func(next http.Handler) http.Handler {
+		if !opts.Enabled {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			log := logger.FromContext(r.Context(), base)
+			if !log.Core().Enabled(zapcore.DebugLevel) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var body []byte
+			if r.Body != nil && r.Body != http.NoBody {
+				var err error
+				body, err = io.ReadAll(r.Body)
+				r.Body.Close()
+				// Ошибка чтения (например, превышение MaxBodySize) передается обработчику
+				// после уже прочитанных данных, как если бы он читал тело сам
+				r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), errReader{err}))
+			}
+			log.Debug("Request body",
+				zap.String("method", r.Method),
+				zap.String("path", r.URL.Path),
+				zap.Any("headers", redactHeaders(r.Header, headers)),
+				zap.String("body", redactBody(body, r.Header.Get("Content-Type"), fields, opts.MaxBytes)))
+
+			rw := &bodyRecorder{ResponseWriter: w, status: http.StatusOK, limit: opts.MaxBytes}
+			next.ServeHTTP(rw, r)
+
+			log.Debug("Response body",
+				zap.String("method", r.Method),
+				zap.String("path", r.URL.Path),
+				zap.Int("status", rw.status),
+				zap.Any("headers", redactHeaders(w.Header(), headers)),
+				zap.String("body", redactBody(rw.body.Bytes(), w.Header().Get("Content-Type"), fields, opts.MaxBytes)),
+				zap.Bool("body_truncated", rw.truncated))
+		})
+	}
+}
+
+// errReader возвращает сохраненную ошибку чтения или io.EOF
+type errReader struct {
+	err error
+}
+
+func (e errReader) Read([]byte) (int, error) {
+	if e.err != nil {
+		return 0, e.err
+	}
+	return 0, io.EOF
+}
+
+// redactHeaders возвращает заголовки в виде строк, скрывая значения заголовков из redact
+func redactHeaders(h http.Header, redact map[string]struct{}) map[string]string {
+	out := make(map[string]string, len(h))
+	for name, values := range h {
+		if _, ok := redact[http.CanonicalHeaderKey(name)]; ok {
+			out[name] = redactedValue
+			continue
+		}
+		out[name] = strings.Join(values, ", ")
+	}
+	return out
+}
+
+// redactBody подготавливает тело для записи в лог. Значения полей из redact в JSON
+// заменяются на redactedValue; JSON, который не удалось разобрать (например, обрезанный
+// ответ), не записывается, так как скрыть в нем поля нельзя. Результат обрезается до maxBytes.
+func redactBody(body []byte, contentType string, redact map[string]struct{}, maxBytes int) string {
+	if len(body) == 0 {
+		return ""
+	}
+
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil && mediaType == jsonMediaType {
+		var v any
+		if err := json.Unmarshal(body, &v); err != nil {
+			return "[unparsable JSON body omitted]"
+		}
+		redacted, err := json.Marshal(redactValue(v, redact))
+		if err != nil {
+			return "[unparsable JSON body omitted]"
+		}
+		body = redacted
+	}
+
+	if maxBytes > 0 && len(body) > maxBytes {
+		return string(body[:maxBytes]) + "...(truncated)"
+	}
+	return string(body)
+}
+
+// redactValue рекурсивно скрывает значения полей из redact в разобранном JSON
+func redactValue(v any, redact map[string]struct{}) any {
+	switch value := v.(type) {
+	case map[string]any:
+		for key, field := range value {
+			if _, ok := redact[strings.ToLower(key)]; ok {
+				value[key] = redactedValue
+				continue
+			}
+			value[key] = redactValue(field, redact)
+		}
+	case []any:
+		for i, item := range value {
+			value[i] = redactValue(item, redact)
+		}
+	}
+	return v
+}
+
+// bodyRecorder передает ответ клиенту и сохраняет первые limit байт тела для лога
+type bodyRecorder struct {
+	http.ResponseWriter
+	status    int
+	limit     int
+	body      bytes.Buffer
+	truncated bool
+}
+
+func (rw *bodyRecorder) WriteHeader(status int) {
+	rw.status = status
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *bodyRecorder) Write(b []byte) (int, error) {
+	if rest := rw.limit - rw.body.Len(); rw.limit <= 0 || rest >= len(b) {
+		rw.body.Write(b)
+	} else {
+		rw.body.Write(b[:max(rest, 0)])
+		rw.truncated = true
+	}
+	return rw.ResponseWriter.Write(b)
+}
+
+// Flush отправляет клиенту буферизованные данные, если это поддерживает исходный ResponseWriter
+func (rw *bodyRecorder) Flush() {
+	if flusher, ok := rw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap возвращает исходный ResponseWriter для http.ResponseController
+func (rw *bodyRecorder) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
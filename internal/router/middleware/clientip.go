@@ -0,0 +1,117 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ForwardedForHeader заголовок со списком адресов, через которые прошел запрос
+const ForwardedForHeader = "X-Forwarded-For"
+
+// clientIPKey ключ контекста для IP адреса клиента
+type clientIPKey struct{}
+
+// ParseTrustedProxies разбирает список доверенных прокси в виде CIDR или отдельных IP адресов
+func ParseTrustedProxies(values []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(values))
+	for _, value := range values {
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", value)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", value, err)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// ClientIP определяет IP адрес клиента и сохраняет его в контексте.
+// Если запрос пришел от доверенного прокси, адресом клиента считается самый правый
+// адрес X-Forwarded-For, не принадлежащий доверенным прокси; иначе используется RemoteAddr,
+// поэтому клиент не может подменить свой адрес, передав заголовок напрямую.
+// Должен применяться до Logger и RateLimit.
+func ClientIP(trusted []*net.IPNet) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := resolveClientIP(r, trusted)
+			ctx := context.WithValue(r.Context(), clientIPKey{}, ip)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// ClientIPFromContext возвращает IP адрес клиента, сохраненный ClientIP
+func ClientIPFromContext(ctx context.Context) string {
+	ip, _ := ctx.Value(clientIPKey{}).(string)
+	return ip
+}
+
+// clientIP возвращает IP адрес клиента из контекста, а если ClientIP не применялся - из RemoteAddr
+func clientIP(r *http.Request) string {
+	if ip := ClientIPFromContext(r.Context()); ip != "" {
+		return ip
+	}
+	return remoteIP(r)
+}
+
+// remoteIP возвращает адрес непосредственного отправителя запроса без порта
+func remoteIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
+
+// resolveClientIP проходит цепочку X-Forwarded-For справа налево, пока адреса принадлежат
+// доверенным прокси. Некорректный адрес прерывает проход: клиентом считается последний
+// проверенный прокси, так как левее него значения заголовка не заслуживают доверия.
+func resolveClientIP(r *http.Request, trusted []*net.IPNet) string {
+	ip := remoteIP(r)
+	if !isTrusted(net.ParseIP(ip), trusted) {
+		return ip
+	}
+
+	var hops []string
+	for _, header := range r.Header.Values(ForwardedForHeader) {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := net.ParseIP(strings.TrimSpace(hops[i]))
+		if hop == nil {
+			return ip
+		}
+		ip = hop.String()
+		if !isTrusted(hop, trusted) {
+			return ip
+		}
+	}
+	return ip
+}
+
+// isTrusted проверяет, принадлежит ли адрес одной из доверенных сетей
+func isTrusted(ip net.IP, trusted []*net.IPNet) bool {
+	if ip == nil {
+		return false
+	}
+	for _, network := range trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
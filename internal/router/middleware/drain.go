@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+)
+
+// Drain хранит признак остановки сервера. После Start новые запросы отклоняются
+// с 503 Service Unavailable, а уже выполняющиеся завершаются как обычно.
+type Drain struct {
+	draining   atomic.Bool
+	retryAfter time.Duration
+}
+
+// NewDrain создает новый экземпляр Drain; retryAfter передается клиентам в заголовке Retry-After
+func NewDrain(retryAfter time.Duration) *Drain {
+	return &Drain{retryAfter: retryAfter}
+}
+
+// Start включает режим остановки
+func (d *Drain) Start() {
+	d.draining.Store(true)
+}
+
+// Draining возвращает true после начала остановки
+func (d *Drain) Draining() bool {
+	return d.draining.Load()
+}
+
+// Reject отклоняет запросы, поступившие после начала остановки
+func (d *Drain) Reject(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !d.Draining() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Соединение закрывается, чтобы клиент переподключился к другому экземпляру
+		w.Header().Set("Connection", "close")
+		w.Header().Set("Retry-After", strconv.Itoa(max(int(d.retryAfter.Seconds()), 1)))
+		writeError(w, http.StatusServiceUnavailable, models.CodeShuttingDown, "Server is shutting down")
+	})
+}
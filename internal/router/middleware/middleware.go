@@ -1,14 +1,26 @@
 package middleware
 
 import (
-	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
 	"net/http"
+	"runtime/debug"
 	"time"
 
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
 	"github.com/DblMOKRQ/DeNet_test_task/pkg/jwt"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/logger"
+	"github.com/google/uuid"
 	"go.uber.org/zap"
 )
 
+// RequestIDHeader заголовок с идентификатором запроса
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength максимальная длина принимаемого от клиента идентификатора запроса
+const maxRequestIDLength = 128
+
 // Middleware представляет функцию middleware
 type Middleware func(http.Handler) http.Handler
 
@@ -20,21 +32,37 @@ func Chain(h http.Handler, middlewares ...Middleware) http.Handler {
 	return h
 }
 
+// RequestID присваивает запросу идентификатор из заголовка X-Request-ID или генерирует новый,
+// сохраняет его в контексте и возвращает в заголовке ответа
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+		if requestID == "" || len(requestID) > maxRequestIDLength {
+			requestID = uuid.NewString()
+		}
+
+		w.Header().Set(RequestIDHeader, requestID)
+		ctx := logger.WithRequestID(r.Context(), requestID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
 // JWTAuth проверяет JWT токен в заголовке Authorization
-func JWTAuth(jwtService *jwt.Service, log *zap.Logger) Middleware {
+func JWTAuth(jwtService *jwt.Service, base *zap.Logger) Middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			log := logger.FromContext(r.Context(), base)
 			log.Debug("Checking JWT token",
 				zap.String("path", r.URL.Path),
 				zap.String("method", r.Method))
 
-			// Получение заголовка Authorization
-			authHeader := r.Header.Get("Authorization")
+			// Получение токена из заголовка Authorization
+			authHeader := jwt.BearerToken(r.Header.Get("Authorization"))
 			if authHeader == "" {
 				log.Warn("Missing Authorization header",
 					zap.String("path", r.URL.Path),
-					zap.String("remote_addr", r.RemoteAddr))
-				http.Error(w, "Authorization header is required", http.StatusUnauthorized)
+					zap.String("client_ip", clientIP(r)))
+				unauthorized(w, "Authorization header is required", nil)
 				return
 			}
 
@@ -44,29 +72,99 @@ func JWTAuth(jwtService *jwt.Service, log *zap.Logger) Middleware {
 				if err == jwt.ErrExpiredToken {
 					log.Warn("Token expired",
 						zap.String("path", r.URL.Path),
-						zap.String("remote_addr", r.RemoteAddr))
-					http.Error(w, "Token expired", http.StatusUnauthorized)
+						zap.String("client_ip", clientIP(r)))
+					unauthorized(w, "Token expired", err)
+				} else if err == jwt.ErrRevokedToken {
+					log.Warn("Token revoked",
+						zap.String("path", r.URL.Path),
+						zap.String("client_ip", clientIP(r)))
+					unauthorized(w, "Token revoked", err)
 				} else {
 					log.Warn("Invalid token",
 						zap.String("path", r.URL.Path),
-						zap.String("remote_addr", r.RemoteAddr),
+						zap.String("client_ip", clientIP(r)),
 						zap.Error(err))
-					http.Error(w, "Invalid token", http.StatusUnauthorized)
+					unauthorized(w, "Invalid token", err)
 				}
 				return
 			}
 
+			userID, err := uuid.Parse(claims.UserID)
+			if err != nil {
+				log.Warn("Invalid user ID in token",
+					zap.String("path", r.URL.Path),
+					zap.String("user_id", claims.UserID))
+				unauthorized(w, "Invalid token", jwt.ErrInvalidClaims)
+				return
+			}
+
 			log.Debug("JWT token validated successfully",
 				zap.String("user_id", claims.UserID),
 				zap.String("path", r.URL.Path))
 
 			// Сохранение данных пользователя в контексте
-			ctx := context.WithValue(r.Context(), "userID", claims.UserID)
+			ctx := WithUser(r.Context(), &AuthUser{
+				UserID:  userID,
+				Role:    claims.Role,
+				TokenID: claims.ID,
+				Claims:  claims,
+			})
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
+// unauthorized отправляет 401 Unauthorized с заголовком WWW-Authenticate;
+// err - причина отклонения токена или nil, если токен не передан
+func unauthorized(w http.ResponseWriter, message string, err error) {
+	w.Header().Set(jwt.ChallengeHeader, jwt.Challenge(err))
+	writeError(w, http.StatusUnauthorized, models.CodeUnauthorized, message)
+}
+
+// writeError отправляет ответ с ошибкой в формате models.ErrorResponse с кодом code (models.Code*),
+// как обработчики в пакете handlers
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(models.ErrorResponse{Error: message, Code: code})
+}
+
+// MaxBodySize ограничивает размер тела запроса; при превышении чтение тела завершается ошибкой
+func MaxBodySize(limit int64) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Body != nil {
+				r.Body = http.MaxBytesReader(w, r.Body, limit)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireRole пропускает только пользователей с указанной ролью.
+// Должен применяться после JWTAuth, который сохраняет роль в контексте.
+func RequireRole(role string, base *zap.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var userRole string
+			if user, ok := UserFromContext(r.Context()); ok {
+				userRole = user.Role
+			}
+			if userRole != role {
+				log := logger.FromContext(r.Context(), base)
+				log.Warn("Access denied",
+					zap.String("path", r.URL.Path),
+					zap.String("required_role", role),
+					zap.String("role", userRole))
+				writeError(w, http.StatusForbidden, models.CodeForbidden, "Forbidden")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 // ContentTypeJSON устанавливает Content-Type: application/json
 func ContentTypeJSON(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -75,11 +173,52 @@ func ContentTypeJSON(next http.Handler) http.Handler {
 	})
 }
 
+// jsonMediaType тип содержимого, принимаемый RequireJSON
+const jsonMediaType = "application/json"
+
+// ndjsonMediaType тип содержимого потоковых эндпоинтов (JSON Lines), также принимаемый RequireJSON
+const ndjsonMediaType = "application/x-ndjson"
+
+// RequireJSON отклоняет запросы с телом, Content-Type которых не application/json
+// и не application/x-ndjson, с 415 Unsupported Media Type. Параметры типа (например, charset) допускаются.
+// Запросы GET, HEAD и OPTIONS, а также запросы без тела не проверяются.
+func RequireJSON(base *zap.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !hasBody(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			contentType := r.Header.Get("Content-Type")
+			mediaType, _, err := mime.ParseMediaType(contentType)
+			if err != nil || (mediaType != jsonMediaType && mediaType != ndjsonMediaType) {
+				logger.FromContext(r.Context(), base).Warn("Unsupported request content type",
+					zap.String("content_type", contentType))
+				writeError(w, http.StatusUnsupportedMediaType, models.CodeUnsupportedMediaType, "Content-Type must be "+jsonMediaType)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// hasBody определяет, передано ли в запросе тело, которое будет читать обработчик
+func hasBody(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	// ContentLength равен -1 для тела неизвестной длины (chunked)
+	return r.ContentLength != 0
+}
+
 // Logger логирует информацию о запросе
-func Logger(log *zap.Logger) Middleware {
+func Logger(base *zap.Logger) Middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			start := time.Now()
+			log := logger.FromContext(r.Context(), base)
 
 			// Создаем ResponseWriter, который отслеживает статус ответа
 			rw := newResponseWriter(w)
@@ -88,6 +227,7 @@ func Logger(log *zap.Logger) Middleware {
 			log.Info("Request started",
 				zap.String("method", r.Method),
 				zap.String("path", r.URL.Path),
+				zap.String("client_ip", clientIP(r)),
 				zap.String("remote_addr", r.RemoteAddr),
 				zap.String("user_agent", r.UserAgent()))
 
@@ -106,19 +246,28 @@ func Logger(log *zap.Logger) Middleware {
 	}
 }
 
-// Recover обрабатывает панику в обработчиках
-func Recover(log *zap.Logger) Middleware {
+// Recover обрабатывает панику в обработчиках: логирует значение паники, его тип и стек вызовов
+// и отвечает 500 Internal Server Error в формате models.ErrorResponse
+func Recover(base *zap.Logger) Middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			defer func() {
 				if err := recover(); err != nil {
+					if err == http.ErrAbortHandler {
+						// Обработчик намеренно прервал ответ, сервер завершит соединение сам
+						panic(err)
+					}
+
+					log := logger.FromContext(r.Context(), base)
 					log.Error("Panic recovered in HTTP handler",
 						zap.Any("error", err),
+						zap.String("error_type", fmt.Sprintf("%T", err)),
+						zap.ByteString("stack", debug.Stack()),
 						zap.String("path", r.URL.Path),
 						zap.String("method", r.Method),
-						zap.String("remote_addr", r.RemoteAddr))
+						zap.String("client_ip", clientIP(r)))
 
-					http.Error(w, "Internal server error", http.StatusInternalServerError)
+					writeError(w, http.StatusInternalServerError, models.CodeInternal, "Internal server error")
 				}
 			}()
 
@@ -151,3 +300,15 @@ func (rw *responseWriter) Write(b []byte) (int, error) {
 	rw.size += size
 	return size, err
 }
+
+// Flush отправляет клиенту буферизованные данные, если это поддерживает исходный ResponseWriter
+func (rw *responseWriter) Flush() {
+	if flusher, ok := rw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap возвращает исходный ResponseWriter для http.ResponseController
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
@@ -0,0 +1,609 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/jwt"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/logger"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusOK)
+})
+
+// assertErrorCode проверяет статус ответа и код ошибки в теле JSON
+func assertErrorCode(t *testing.T, rec *httptest.ResponseRecorder, status int, code string) {
+	t.Helper()
+
+	if rec.Code != status {
+		t.Fatalf("status = %d, want %d", rec.Code, status)
+	}
+	if contentType := rec.Header().Get("Content-Type"); contentType != "application/json" {
+		t.Fatalf("Content-Type = %q, want application/json", contentType)
+	}
+	var resp models.ErrorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode error response: %v", err)
+	}
+	if resp.Code != code || resp.Error == "" {
+		t.Errorf("response = %+v, want code %s with a message", resp, code)
+	}
+}
+
+// mustParseTrustedProxies разбирает список доверенных прокси для теста
+func mustParseTrustedProxies(t *testing.T, values ...string) []*net.IPNet {
+	t.Helper()
+
+	trusted, err := ParseTrustedProxies(values)
+	if err != nil {
+		t.Fatalf("ParseTrustedProxies(%v) error = %v", values, err)
+	}
+	return trusted
+}
+
+func TestParseTrustedProxiesInvalid(t *testing.T) {
+	for _, value := range []string{"not-an-ip", "10.0.0.0/33", ""} {
+		if _, err := ParseTrustedProxies([]string{value}); err == nil {
+			t.Errorf("ParseTrustedProxies(%q) error = nil, want error", value)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	trusted := mustParseTrustedProxies(t, "10.0.0.0/8", "192.168.1.1")
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		want       string
+	}{
+		{
+			name:       "no proxy",
+			remoteAddr: "203.0.113.7:1234",
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "spoofed header from untrusted peer is ignored",
+			remoteAddr: "203.0.113.7:1234",
+			forwarded:  []string{"198.51.100.1"},
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "trusted proxy",
+			remoteAddr: "10.0.0.1:1234",
+			forwarded:  []string{"198.51.100.1"},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "rightmost untrusted hop wins over spoofed prefix",
+			remoteAddr: "10.0.0.1:1234",
+			forwarded:  []string{"1.1.1.1, 198.51.100.1, 10.0.0.2"},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "multiple headers",
+			remoteAddr: "192.168.1.1:1234",
+			forwarded:  []string{"1.1.1.1", "198.51.100.1"},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "only trusted hops",
+			remoteAddr: "10.0.0.1:1234",
+			forwarded:  []string{"10.0.0.3, 10.0.0.2"},
+			want:       "10.0.0.3",
+		},
+		{
+			name:       "malformed hop stops the walk",
+			remoteAddr: "10.0.0.1:1234",
+			forwarded:  []string{"198.51.100.1, garbage"},
+			want:       "10.0.0.1",
+		},
+		{
+			name:       "trusted proxy without header",
+			remoteAddr: "10.0.0.1:1234",
+			want:       "10.0.0.1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwarded {
+				req.Header.Add(ForwardedForHeader, value)
+			}
+
+			var got string
+			ClientIP(trusted)(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				got = ClientIPFromContext(r.Context())
+			})).ServeHTTP(httptest.NewRecorder(), req)
+
+			if got != tt.want {
+				t.Errorf("ClientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimitKeyedOnClientIP(t *testing.T) {
+	trusted := mustParseTrustedProxies(t, "10.0.0.0/8")
+	handler := Chain(okHandler, RateLimit(1, time.Minute, zap.NewNop()), ClientIP(trusted))
+
+	request := func(remoteAddr, forwarded string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = remoteAddr
+		if forwarded != "" {
+			req.Header.Set(ForwardedForHeader, forwarded)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := request("10.0.0.1:1000", "198.51.100.1"); rec.Code != http.StatusOK {
+		t.Fatalf("first request status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	rec := request("10.0.0.2:2000", "198.51.100.1")
+	assertErrorCode(t, rec, http.StatusTooManyRequests, models.CodeRateLimited)
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("Retry-After header is not set")
+	}
+
+	// Клиенты за одним прокси не делят общий лимит
+	if rec := request("10.0.0.1:1000", "198.51.100.2"); rec.Code != http.StatusOK {
+		t.Errorf("another client behind the proxy status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	// Подмена X-Forwarded-For напрямую не сбрасывает лимит
+	if rec := request("203.0.113.7:1000", "198.51.100.3"); rec.Code != http.StatusOK {
+		t.Fatalf("direct client status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if rec := request("203.0.113.7:1001", "198.51.100.4"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("direct client with spoofed header status = %d, want %d", rec.Code, http.StatusTooManyRequests)
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		generate bool
+	}{
+		{name: "propagated", header: "client-request-1"},
+		{name: "generated when missing", generate: true},
+		{name: "generated when too long", header: strings.Repeat("x", maxRequestIDLength+1), generate: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set(RequestIDHeader, tt.header)
+			}
+
+			var fromContext string
+			rec := httptest.NewRecorder()
+			RequestID(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				fromContext = logger.RequestIDFromContext(r.Context())
+			})).ServeHTTP(rec, req)
+
+			got := rec.Header().Get(RequestIDHeader)
+			if got != fromContext {
+				t.Errorf("response header %q differs from context value %q", got, fromContext)
+			}
+			if tt.generate {
+				if _, err := uuid.Parse(got); err != nil {
+					t.Errorf("%s = %q, want a generated UUID", RequestIDHeader, got)
+				}
+			} else if got != tt.header {
+				t.Errorf("%s = %q, want %q", RequestIDHeader, got, tt.header)
+			}
+		})
+	}
+}
+
+func TestLoggerIncludesRequestID(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	handler := Chain(okHandler, Logger(zap.New(core)), RequestID)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(RequestIDHeader, "client-request-1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	entries := logs.All()
+	if len(entries) == 0 {
+		t.Fatal("no log entries written")
+	}
+	for _, entry := range entries {
+		if got := entry.ContextMap()["request_id"]; got != "client-request-1" {
+			t.Errorf("entry %q request_id = %v, want %q", entry.Message, got, "client-request-1")
+		}
+	}
+}
+
+func TestLogsIncludeClientIP(t *testing.T) {
+	jwtService, err := jwt.NewService(jwt.Options{SecretKey: "test-secret", TokenDuration: time.Hour}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("jwt.NewService() error = %v", err)
+	}
+	panicHandler := http.HandlerFunc(func(http.ResponseWriter, *http.Request) { panic("boom") })
+
+	tests := []struct {
+		name    string
+		handler func(log *zap.Logger) http.Handler
+		// wantMessage запись лога, которая должна содержать client_ip
+		wantMessage string
+	}{
+		{
+			name:        "request started",
+			handler:     func(log *zap.Logger) http.Handler { return Logger(log)(okHandler) },
+			wantMessage: "Request started",
+		},
+		{
+			name:        "missing token",
+			handler:     func(log *zap.Logger) http.Handler { return JWTAuth(jwtService, log)(okHandler) },
+			wantMessage: "Missing Authorization header",
+		},
+		{
+			name:        "panic",
+			handler:     func(log *zap.Logger) http.Handler { return Recover(log)(panicHandler) },
+			wantMessage: "Panic recovered in HTTP handler",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			core, logs := observer.New(zapcore.InfoLevel)
+			// Запрос приходит от доверенного прокси, адрес клиента берется из X-Forwarded-For
+			handler := ClientIP(mustParseTrustedProxies(t, "10.0.0.0/8"))(tt.handler(zap.New(core)))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = "10.0.0.5:1234"
+			req.Header.Set(ForwardedForHeader, "203.0.113.7")
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			entries := logs.FilterMessage(tt.wantMessage).All()
+			if len(entries) != 1 {
+				t.Fatalf("%q entries = %d, want 1 (all: %v)", tt.wantMessage, len(entries), logs.All())
+			}
+			if got := entries[0].ContextMap()["client_ip"]; got != "203.0.113.7" {
+				t.Errorf("client_ip = %v, want 203.0.113.7", got)
+			}
+		})
+	}
+}
+
+func TestRequireJSON(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		body        string
+		wantCode    int
+	}{
+		{name: "json", method: http.MethodPost, contentType: "application/json", body: `{}`, wantCode: http.StatusOK},
+		{name: "json with charset", method: http.MethodPost, contentType: "application/json; charset=utf-8", body: `{}`, wantCode: http.StatusOK},
+		{name: "json in upper case", method: http.MethodPut, contentType: "Application/JSON", body: `{}`, wantCode: http.StatusOK},
+		{name: "ndjson", method: http.MethodPost, contentType: "application/x-ndjson", body: "{}\n{}\n", wantCode: http.StatusOK},
+		{name: "plain text", method: http.MethodPost, contentType: "text/plain", body: `{}`, wantCode: http.StatusUnsupportedMediaType},
+		{name: "form", method: http.MethodPatch, contentType: "application/x-www-form-urlencoded", body: "a=1", wantCode: http.StatusUnsupportedMediaType},
+		{name: "missing content type", method: http.MethodPost, body: `{}`, wantCode: http.StatusUnsupportedMediaType},
+		{name: "malformed content type", method: http.MethodPost, contentType: "application/json; charset", body: `{}`, wantCode: http.StatusUnsupportedMediaType},
+		{name: "empty body", method: http.MethodPost, contentType: "text/plain", wantCode: http.StatusOK},
+		{name: "get is not checked", method: http.MethodGet, contentType: "text/plain", body: "ignored", wantCode: http.StatusOK},
+	}
+
+	handler := Chain(okHandler, RequireJSON(zap.NewNop()))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+			if tt.wantCode != http.StatusUnsupportedMediaType {
+				return
+			}
+			var got models.ErrorResponse
+			if err := json.NewDecoder(rec.Body).Decode(&got); err != nil || got.Error == "" || got.Code != models.CodeUnsupportedMediaType {
+				t.Errorf("response = %+v, %v, want a JSON error with code %s", got, err, models.CodeUnsupportedMediaType)
+			}
+		})
+	}
+}
+
+func TestLoggerSupportsFlush(t *testing.T) {
+	handler := Logger(zap.NewNop())(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			t.Fatal("ResponseWriter wrapped by Logger does not implement http.Flusher")
+		}
+		_, _ = w.Write([]byte("partial"))
+		flusher.Flush()
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !rec.Flushed {
+		t.Error("Flush() was not passed to the underlying ResponseWriter")
+	}
+}
+
+func TestRecover(t *testing.T) {
+	core, logs := observer.New(zapcore.ErrorLevel)
+	handler := Recover(zap.New(core))(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic(errors.New("boom"))
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+	var body models.ErrorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil || body.Error != "Internal server error" || body.Code != models.CodeInternal {
+		t.Errorf("body = %+v (%v), want the JSON error response", body, err)
+	}
+
+	entries := logs.All()
+	if len(entries) != 1 {
+		t.Fatalf("log entries = %d, want 1", len(entries))
+	}
+	fields := entries[0].ContextMap()
+	if fields["error_type"] != "*errors.errorString" {
+		t.Errorf("error_type = %v, want *errors.errorString", fields["error_type"])
+	}
+	if stack, _ := fields["stack"].(string); !strings.Contains(stack, "TestRecover") {
+		t.Errorf("stack does not contain the panicking test function: %q", stack)
+	}
+}
+
+func TestRecoverRepanicsAbortHandler(t *testing.T) {
+	handler := Recover(zap.NewNop())(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	defer func() {
+		if got := recover(); got != http.ErrAbortHandler {
+			t.Errorf("recovered %v, want %v", got, http.ErrAbortHandler)
+		}
+	}()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	t.Error("ServeHTTP() returned, want http.ErrAbortHandler to propagate")
+}
+
+func TestJWTAuthStoresUser(t *testing.T) {
+	jwtService, err := jwt.NewService(jwt.Options{SecretKey: "test-secret", TokenDuration: time.Hour}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("jwt.NewService() error = %v", err)
+	}
+	userID := uuid.New()
+	token, err := jwtService.GenerateToken(userID.String(), "admin")
+	if err != nil {
+		t.Fatalf("GenerateToken() error = %v", err)
+	}
+
+	var got *AuthUser
+	handler := JWTAuth(jwtService, zap.NewNop())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = UserFromContext(r.Context())
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", token)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if got == nil {
+		t.Fatal("UserFromContext() = nil, want the authenticated user")
+	}
+	if got.UserID != userID || got.Role != "admin" || got.TokenID == "" || got.Claims == nil || got.TokenID != got.Claims.ID {
+		t.Errorf("AuthUser = %+v, want %s with role admin and the token ID", got, userID)
+	}
+}
+
+func TestJWTAuthRejectsNonUUIDSubject(t *testing.T) {
+	jwtService, err := jwt.NewService(jwt.Options{SecretKey: "test-secret", TokenDuration: time.Hour}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("jwt.NewService() error = %v", err)
+	}
+	token, err := jwtService.GenerateToken("not-a-uuid", "user")
+	if err != nil {
+		t.Fatalf("GenerateToken() error = %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", token)
+	rec := httptest.NewRecorder()
+	JWTAuth(jwtService, zap.NewNop())(okHandler).ServeHTTP(rec, req)
+
+	assertErrorCode(t, rec, http.StatusUnauthorized, models.CodeUnauthorized)
+	if got, want := rec.Header().Get(jwt.ChallengeHeader), jwt.Challenge(jwt.ErrInvalidClaims); got != want {
+		t.Errorf("%s = %q, want %q", jwt.ChallengeHeader, got, want)
+	}
+}
+
+func TestUserFromContextUnauthenticated(t *testing.T) {
+	if user, ok := UserFromContext(httptest.NewRequest(http.MethodGet, "/", nil).Context()); ok || user != nil {
+		t.Errorf("UserFromContext() = %v, %t, want nil, false", user, ok)
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	jwtService, err := jwt.NewService(jwt.Options{SecretKey: "test-secret", TokenDuration: time.Hour}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("jwt.NewService() error = %v", err)
+	}
+	handler := Chain(okHandler, RequireRole("admin", zap.NewNop()), JWTAuth(jwtService, zap.NewNop()))
+
+	for _, tt := range []struct {
+		role     string
+		wantCode int
+	}{
+		{role: "admin", wantCode: http.StatusOK},
+		{role: "user", wantCode: http.StatusForbidden},
+		{role: "", wantCode: http.StatusForbidden},
+	} {
+		token, err := jwtService.GenerateToken(uuid.NewString(), tt.role)
+		if err != nil {
+			t.Fatalf("GenerateToken() error = %v", err)
+		}
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != tt.wantCode {
+			t.Errorf("role %q status = %d, want %d", tt.role, rec.Code, tt.wantCode)
+		}
+		if tt.wantCode == http.StatusForbidden {
+			assertErrorCode(t, rec, http.StatusForbidden, models.CodeForbidden)
+		}
+	}
+}
+
+func TestJWTAuthMissingToken(t *testing.T) {
+	jwtService, err := jwt.NewService(jwt.Options{SecretKey: "test-secret", TokenDuration: time.Hour}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("jwt.NewService() error = %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	JWTAuth(jwtService, zap.NewNop())(okHandler).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assertErrorCode(t, rec, http.StatusUnauthorized, models.CodeUnauthorized)
+	if rec.Header().Get(jwt.ChallengeHeader) == "" {
+		t.Errorf("%s header is not set", jwt.ChallengeHeader)
+	}
+}
+
+// bodyLogOptions настройки логирования тел, совпадающие со значениями по умолчанию конфигурации
+var bodyLogOptions = BodyLogOptions{
+	Enabled:       true,
+	MaxBytes:      4096,
+	RedactFields:  []string{"password", "current_password", "new_password", "token"},
+	RedactHeaders: []string{"Authorization", "Cookie", "Set-Cookie"},
+}
+
+// logEntry возвращает единственную запись лога с сообщением message
+func logEntry(t *testing.T, logs *observer.ObservedLogs, message string) map[string]any {
+	t.Helper()
+
+	entries := logs.FilterMessage(message).All()
+	if len(entries) != 1 {
+		t.Fatalf("%q entries = %d, want 1", message, len(entries))
+	}
+	return entries[0].ContextMap()
+}
+
+func TestBodyLoggerRedacts(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	var gotBody string
+	handler := BodyLogger(bodyLogOptions, zap.New(core))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "session=secret")
+		_, _ = w.Write([]byte(`{"token":"jwt-secret","user":{"username":"alice"}}`))
+	}))
+
+	body := `{"username":"alice","password":"hunter2","nested":{"New_Password":"hunter3"},"items":[{"token":"t1"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer jwt-secret")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if gotBody != body {
+		t.Errorf("handler read body %q, want %q", gotBody, body)
+	}
+
+	request := logEntry(t, logs, "Request body")
+	for _, secret := range []string{"hunter2", "hunter3", "t1"} {
+		if strings.Contains(request["body"].(string), secret) {
+			t.Errorf("request body %q contains %q", request["body"], secret)
+		}
+	}
+	if !strings.Contains(request["body"].(string), `"username":"alice"`) {
+		t.Errorf("request body %q, want username kept", request["body"])
+	}
+	if got := request["headers"].(map[string]string)["Authorization"]; got != redactedValue {
+		t.Errorf("Authorization header logged as %q, want %q", got, redactedValue)
+	}
+
+	response := logEntry(t, logs, "Response body")
+	if got := response["body"].(string); strings.Contains(got, "jwt-secret") || !strings.Contains(got, "alice") {
+		t.Errorf("response body %q, want token redacted and user kept", got)
+	}
+	if got := response["headers"].(map[string]string)["Set-Cookie"]; got != redactedValue {
+		t.Errorf("Set-Cookie header logged as %q, want %q", got, redactedValue)
+	}
+}
+
+func TestBodyLoggerOmitsUnparsableJSON(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	handler := BodyLogger(bodyLogOptions, zap.New(core))(okHandler)
+
+	req := httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(`{"password":"hunter2"`))
+	req.Header.Set("Content-Type", "application/json")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if got := logEntry(t, logs, "Request body")["body"].(string); strings.Contains(got, "hunter2") {
+		t.Errorf("request body %q, want unparsable JSON omitted", got)
+	}
+}
+
+func TestBodyLoggerTruncates(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	opts := bodyLogOptions
+	opts.MaxBytes = 8
+	handler := BodyLogger(opts, zap.New(core))(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("0123456789"))
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Body.String() != "0123456789" {
+		t.Errorf("client received %q, want full body", rec.Body.String())
+	}
+	response := logEntry(t, logs, "Response body")
+	if response["body"] != "01234567" || response["body_truncated"] != true {
+		t.Errorf("logged body = %q truncated %v, want 01234567 truncated", response["body"], response["body_truncated"])
+	}
+}
+
+func TestBodyLoggerSkippedAboveDebug(t *testing.T) {
+	for _, opts := range []BodyLogOptions{bodyLogOptions, {}} {
+		level := zapcore.InfoLevel
+		if !opts.Enabled {
+			level = zapcore.DebugLevel
+		}
+		core, logs := observer.New(level)
+		BodyLogger(opts, zap.New(core))(okHandler).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if logs.Len() != 0 {
+			t.Errorf("enabled = %v at %s: %d entries written, want none", opts.Enabled, level, logs.Len())
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// rateWindow хранит счетчик запросов клиента в текущем окне
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+// rateLimiter ограничивает количество запросов с одного адреса за окно времени
+type rateLimiter struct {
+	mu      sync.Mutex
+	limit   int
+	window  time.Duration
+	clients map[string]*rateWindow
+}
+
+// allow регистрирует запрос клиента и возвращает время до сброса окна, если лимит исчерпан
+func (l *rateLimiter) allow(key string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Удаление устаревших окон, чтобы карта не росла бесконечно
+	if len(l.clients) > 10000 {
+		for k, w := range l.clients {
+			if now.Sub(w.start) >= l.window {
+				delete(l.clients, k)
+			}
+		}
+	}
+
+	w, ok := l.clients[key]
+	if !ok || now.Sub(w.start) >= l.window {
+		l.clients[key] = &rateWindow{start: now, count: 1}
+		return true, 0
+	}
+
+	if w.count >= l.limit {
+		return false, w.start.Add(l.window).Sub(now)
+	}
+
+	w.count++
+	return true, 0
+}
+
+// RateLimit ограничивает количество запросов с одного IP адреса за окно времени.
+// Адрес клиента берется из контекста (см. ClientIP).
+func RateLimit(limit int, window time.Duration, base *zap.Logger) Middleware {
+	limiter := &rateLimiter{
+		limit:   limit,
+		window:  window,
+		clients: make(map[string]*rateWindow),
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ok, retryAfter := limiter.allow(clientIP(r), time.Now())
+			if !ok {
+				log := logger.FromContext(r.Context(), base)
+				log.Warn("Rate limit exceeded",
+					zap.String("path", r.URL.Path),
+					zap.String("client_ip", clientIP(r)))
+				w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+				writeError(w, http.StatusTooManyRequests, models.CodeRateLimited, "Too many requests")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"context"
+
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/jwt"
+	"github.com/google/uuid"
+)
+
+// AuthUser представляет пользователя, аутентифицированного по JWT токену
+type AuthUser struct {
+	UserID uuid.UUID
+	Role   string
+	// TokenID идентификатор токена (jti); пустой, если токен выдан без него
+	TokenID string
+	// Claims проверенные claims токена, например для его отзыва
+	Claims *jwt.Claims
+}
+
+// authUserKey ключ контекста для аутентифицированного пользователя
+type authUserKey struct{}
+
+// WithUser сохраняет аутентифицированного пользователя в контексте
+func WithUser(ctx context.Context, user *AuthUser) context.Context {
+	return context.WithValue(ctx, authUserKey{}, user)
+}
+
+// UserFromContext возвращает пользователя, сохраненного JWTAuth, и false, если запрос не аутентифицирован
+func UserFromContext(ctx context.Context) (*AuthUser, bool) {
+	user, ok := ctx.Value(authUserKey{}).(*AuthUser)
+	return user, ok && user != nil
+}
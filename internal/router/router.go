@@ -1,63 +1,278 @@
 package router
 
 import (
+	"net"
 	"net/http"
+	"strings"
+	"time"
 
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
 	"github.com/DblMOKRQ/DeNet_test_task/internal/router/handlers"
 	"github.com/DblMOKRQ/DeNet_test_task/internal/router/middleware"
 	"github.com/DblMOKRQ/DeNet_test_task/pkg/jwt"
 	"go.uber.org/zap"
 )
 
+// Options содержит настраиваемые параметры маршрутизации
+type Options struct {
+	// AuthRateLimit максимальное число запросов к публичным эндпоинтам /auth с одного адреса за окно
+	AuthRateLimit int
+	// AuthRateLimitWindow длительность окна ограничения запросов
+	AuthRateLimitWindow time.Duration
+	// TrustedProxies сети прокси, которым разрешено передавать адрес клиента в X-Forwarded-For
+	TrustedProxies []*net.IPNet
+	// MaxBodyBytes максимальный размер тела запроса в байтах
+	MaxBodyBytes int64
+	// Drain признак остановки сервера; после его включения новые запросы отклоняются с 503
+	Drain *middleware.Drain
+	// RequireJSON отклонять запросы с телом не в формате application/json или application/x-ndjson
+	RequireJSON bool
+	// BasePath общий префикс версионированных маршрутов API, например "/api"
+	BasePath string
+	// LegacyRedirect перенаправлять пути без префикса версии на v1
+	LegacyRedirect bool
+	// BodyLog настройки отладочного логирования тел запросов и ответов
+	BodyLog middleware.BodyLogOptions
+}
+
+// V1 префикс маршрутов первой версии API относительно Options.BasePath
+const V1 = "/v1"
+
 // Router обрабатывает HTTP запросы
 type Router struct {
-	jwtService  *jwt.Service
-	userHandler *handlers.UserHandler
-	log         *zap.Logger
+	jwtService    *jwt.Service
+	userHandler   *handlers.UserHandler
+	configHandler *handlers.ConfigHandler
+	adminHandler  *handlers.AdminHandler
+	healthHandler *handlers.HealthHandler
+	opts          Options
+	log           *zap.Logger
+}
+
+// route описывает путь, его обработчик и допустимые методы
+type route struct {
+	path    string
+	handler http.Handler
+	methods []string
 }
 
 // NewRouter создает новый экземпляр Router
-func NewRouter(jwtService *jwt.Service, userHandler *handlers.UserHandler, log *zap.Logger) *Router {
+func NewRouter(jwtService *jwt.Service, userHandler *handlers.UserHandler, configHandler *handlers.ConfigHandler, adminHandler *handlers.AdminHandler, healthHandler *handlers.HealthHandler, opts Options, log *zap.Logger) *Router {
+	if opts.Drain == nil {
+		opts.Drain = middleware.NewDrain(0)
+	}
 	return &Router{
-		jwtService:  jwtService,
-		userHandler: userHandler,
-		log:         log.Named("router"),
+		jwtService:    jwtService,
+		userHandler:   userHandler,
+		configHandler: configHandler,
+		adminHandler:  adminHandler,
+		healthHandler: healthHandler,
+		opts:          opts,
+		log:           log.Named("router"),
 	}
 }
 
-// Setup настраивает маршруты и middleware
+// Setup настраивает маршруты и middleware.
+// Маршруты API регистрируются под префиксом версии (BasePath + V1), чтобы следующая версия
+// могла работать параллельно под своим префиксом. Проверки состояния не версионируются.
+// Маршруты регистрируются с методом, поэтому для существующего пути с неверным методом
+// ServeMux отвечает 405 с заголовком Allow, а для неизвестного пути - 404.
 func (r *Router) Setup() http.Handler {
 	// Создание маршрутизатора
 	mux := http.NewServeMux()
 
-	// Регистрация обработчиков
-	mux.Handle("/users/register",
-		middleware.Chain(
-			http.HandlerFunc(r.userHandler.LoginUser),
-			middleware.Recover(r.log),
-			middleware.Logger(r.log),
-			middleware.ContentTypeJSON,
-		),
-	)
+	// Проверки состояния не отклоняются при остановке и не логируются,
+	// чтобы частые запросы балансировщика не засоряли лог
+	mux.Handle("GET /healthz", r.probe(r.healthHandler.Healthz))
+	mux.Handle("GET /readyz", r.probe(r.healthHandler.Readyz))
 
-	// Для всех остальных маршрутов применяем JWT middleware
-	protected := http.NewServeMux()
-	protected.HandleFunc("/users/leaderboard", r.userHandler.GetLeaderboard)
-	protected.HandleFunc("/users/status", r.userHandler.GetUserStatus)
-	protected.HandleFunc("/users/task/complete", r.userHandler.CompleteTask)
-	protected.HandleFunc("/users/referrer", r.userHandler.AddReferrer)
+	v1 := r.v1Routes()
+	r.mount(mux, r.opts.BasePath+V1, v1)
+	if r.opts.LegacyRedirect {
+		r.redirectLegacy(mux, r.opts.BasePath+V1, v1)
+	}
 
-	// Применение middleware к защищенным маршрутам
-	protectedHandler := middleware.Chain(
-		protected,
-		middleware.Recover(r.log),
+	return mux
+}
+
+// v1Routes возвращает маршруты версии v1 с примененными middleware
+func (r *Router) v1Routes() []route {
+	// Регистрация публичных обработчиков
+	routes := []route{
+		{"/users/register", r.public(r.userHandler.LoginUser), []string{http.MethodPost}},
+		{"/auth/login", r.public(r.userHandler.Login,
+			middleware.RateLimit(r.opts.AuthRateLimit, r.opts.AuthRateLimitWindow, r.log)), []string{http.MethodPost}},
+		{"/auth/username-available", r.public(r.userHandler.CheckUsernameAvailable,
+			middleware.RateLimit(r.opts.AuthRateLimit, r.opts.AuthRateLimitWindow, r.log)), []string{http.MethodGet}},
+		{"/auth/password-reset/request", r.public(r.userHandler.RequestPasswordReset,
+			middleware.RateLimit(r.opts.AuthRateLimit, r.opts.AuthRateLimitWindow, r.log)), []string{http.MethodPost}},
+		{"/auth/password-reset/confirm", r.public(r.userHandler.ConfirmPasswordReset,
+			middleware.RateLimit(r.opts.AuthRateLimit, r.opts.AuthRateLimitWindow, r.log)), []string{http.MethodPost}},
+		{"/users/verify", r.public(r.userHandler.VerifyEmail,
+			middleware.RateLimit(r.opts.AuthRateLimit, r.opts.AuthRateLimitWindow, r.log)), []string{http.MethodGet}},
+		{"/config/public", r.public(r.configHandler.GetPublicConfig), []string{http.MethodGet}},
+		{"/version", r.public(r.configHandler.GetVersion), []string{http.MethodGet}},
+	}
+
+	// Для всех остальных маршрутов применяем JWT middleware.
+	// Обработчики берут пользователя из контекста и не проверяют токен повторно,
+	// поэтому маршрут, использующий пользователя, должен быть зарегистрирован здесь.
+	protected := []route{
+		{"/users/leaderboard", http.HandlerFunc(r.userHandler.GetLeaderboard), []string{http.MethodGet}},
+		{"/users/leaderboard/stream", http.HandlerFunc(r.userHandler.StreamLeaderboard), []string{http.MethodGet}},
+		{"/users/status", http.HandlerFunc(r.userHandler.GetUserStatus), []string{http.MethodGet}},
+		{"/users/task/complete", http.HandlerFunc(r.userHandler.CompleteTask), []string{http.MethodPost}},
+		{"/users/referrer", http.HandlerFunc(r.userHandler.AddReferrer), []string{http.MethodPost}},
+		{"/users/lookup", http.HandlerFunc(r.userHandler.LookupUser), []string{http.MethodGet}},
+
+		// Маршруты, определяющие пользователя по токену
+		{"/users/me", http.HandlerFunc(r.userHandler.Me), []string{http.MethodGet, http.MethodPatch, http.MethodDelete}},
+		{"/users/me/task/complete", http.HandlerFunc(r.userHandler.CompleteTask), []string{http.MethodPost}},
+		{"/users/me/referrer", http.HandlerFunc(r.userHandler.MeReferrer), []string{http.MethodPost, http.MethodDelete}},
+		{"/users/me/password", http.HandlerFunc(r.userHandler.ChangePassword), []string{http.MethodPost}},
+		{"/users/me/transfer", http.HandlerFunc(r.userHandler.TransferPoints), []string{http.MethodPost}},
+		{"/users/me/tasks", http.HandlerFunc(r.userHandler.GetUserTasks), []string{http.MethodGet}},
+		{"/users/me/summary", http.HandlerFunc(r.userHandler.GetUserSummary), []string{http.MethodGet}},
+		{"/users/me/referrer-chain", http.HandlerFunc(r.userHandler.GetReferrerChain), []string{http.MethodGet}},
+		{"/users/me/referral-code/rotate", http.HandlerFunc(r.userHandler.RotateReferralCode), []string{http.MethodPost}},
+		{"/users/me/email/verify/request", http.HandlerFunc(r.userHandler.RequestEmailVerification), []string{http.MethodPost}},
+		{"/auth/logout", http.HandlerFunc(r.userHandler.Logout), []string{http.MethodPost}},
+
+		// Административные маршруты
+		{"/admin/stats", r.admin(r.adminHandler.GetGlobalStats), []string{http.MethodGet}},
+		{"/admin/tasks/stats", r.admin(r.adminHandler.GetTaskStats), []string{http.MethodGet}},
+		{"/admin/tasks/{id}/revert", r.admin(r.adminHandler.RevertTask), []string{http.MethodPost}},
+		{"/admin/tasks/import", r.admin(r.adminHandler.ImportTasks), []string{http.MethodPost}},
+		{"/admin/users", r.admin(r.adminHandler.ListUsers), []string{http.MethodGet}},
+		{"/admin/users/import", r.admin(r.adminHandler.ImportUsers), []string{http.MethodPost}},
+		{"/admin/users/{id}/points", r.admin(r.adminHandler.AdjustPoints), []string{http.MethodPost}},
+		{"/admin/audit", r.admin(r.adminHandler.ListAudit), []string{http.MethodGet}},
+	}
+	for _, rt := range protected {
+		rt.handler = r.protected(rt.handler)
+		routes = append(routes, rt)
+	}
+
+	return routes
+}
+
+// mount регистрирует маршруты под префиксом prefix
+func (r *Router) mount(mux *http.ServeMux, prefix string, routes []route) {
+	for _, rt := range routes {
+		rt.path = prefix + rt.path
+		r.handle(mux, rt)
+	}
+}
+
+// redirectLegacy перенаправляет запросы к путям без префикса версии на prefix с кодом
+// 308 Permanent Redirect, сохраняющим метод и тело запроса. Оставлено на переходный период
+// для клиентов, использующих пути без версии.
+func (r *Router) redirectLegacy(mux *http.ServeMux, prefix string, routes []route) {
+	redirect := r.probe(func(w http.ResponseWriter, req *http.Request) {
+		target := prefix + req.URL.EscapedPath()
+		if req.URL.RawQuery != "" {
+			target += "?" + req.URL.RawQuery
+		}
+		w.Header().Set("Deprecation", "true")
+		http.Redirect(w, req, target, http.StatusPermanentRedirect)
+	})
+	for _, rt := range routes {
+		for _, method := range rt.methods {
+			mux.Handle(method+" "+rt.path, redirect)
+		}
+	}
+}
+
+// public оборачивает обработчик публичного маршрута стандартным набором middleware;
+// extra применяются после проверки остановки сервера и уже видят адрес клиента из ClientIP
+func (r *Router) public(h http.HandlerFunc, extra ...middleware.Middleware) http.Handler {
+	ms := append(r.bodyChecks(), extra...)
+	ms = append(ms,
+		r.opts.Drain.Reject,
+		middleware.BodyLogger(r.opts.BodyLog, r.log),
 		middleware.Logger(r.log),
+		middleware.Recover(r.log),
+		middleware.ContentTypeJSON,
+		middleware.MaxBodySize(r.opts.MaxBodyBytes),
+		middleware.RequestID,
+		middleware.ClientIP(r.opts.TrustedProxies),
+	)
+	return middleware.Chain(h, ms...)
+}
+
+// protected оборачивает обработчик защищенного маршрута проверкой JWT и стандартным набором middleware.
+// Снаружи внутрь: Recover, Logger, проверка остановки сервера и JWTAuth, поэтому при остановке
+// запрос без токена получает 503, а отклоненные токены логируются.
+func (r *Router) protected(h http.Handler) http.Handler {
+	return middleware.Chain(h, append(r.bodyChecks(),
 		middleware.JWTAuth(r.jwtService, r.log),
+		r.opts.Drain.Reject,
+		middleware.BodyLogger(r.opts.BodyLog, r.log),
+		middleware.Logger(r.log),
+		middleware.Recover(r.log),
 		middleware.ContentTypeJSON,
+		middleware.MaxBodySize(r.opts.MaxBodyBytes),
+		middleware.RequestID,
+		middleware.ClientIP(r.opts.TrustedProxies),
+	)...)
+}
+
+// bodyChecks возвращает middleware, выполняемые непосредственно перед обработчиком:
+// проверку типа тела запроса, если она включена. Проверка типа выполняется после
+// аутентификации, поэтому запрос без токена получает 401, а не 415.
+func (r *Router) bodyChecks() []middleware.Middleware {
+	var ms []middleware.Middleware
+	if r.opts.RequireJSON {
+		ms = append(ms, middleware.RequireJSON(r.log))
+	}
+	return ms
+}
+
+// probe оборачивает обработчик проверки состояния минимальным набором middleware
+func (r *Router) probe(h http.HandlerFunc) http.Handler {
+	return middleware.Chain(
+		h,
+		middleware.Recover(r.log),
+		middleware.RequestID,
 	)
+}
 
-	// Объединяем защищенные и публичные маршруты
-	mux.Handle("/", protectedHandler)
+// admin оборачивает обработчик проверкой роли администратора
+func (r *Router) admin(h http.HandlerFunc) http.Handler {
+	return middleware.RequireRole(models.RoleAdmin, r.log)(h)
+}
 
-	return mux
+// handle регистрирует обработчик маршрута для каждого допустимого метода,
+// а также ответ на OPTIONS со списком допустимых методов (без проверки токена)
+func (r *Router) handle(mux *http.ServeMux, rt route) {
+	for _, method := range rt.methods {
+		mux.Handle(method+" "+rt.path, rt.handler)
+	}
+
+	mux.Handle(http.MethodOptions+" "+rt.path,
+		middleware.Chain(
+			allowMethods(rt.methods),
+			middleware.Recover(r.log),
+			middleware.Logger(r.log),
+			middleware.RequestID,
+			middleware.ClientIP(r.opts.TrustedProxies),
+		),
+	)
+}
+
+// allowMethods отвечает 204 No Content с заголовком Allow
+func allowMethods(methods []string) http.Handler {
+	allowed := append([]string{}, methods...)
+	for _, method := range methods {
+		if method == http.MethodGet {
+			allowed = append(allowed, http.MethodHead)
+		}
+	}
+	allowed = append(allowed, http.MethodOptions)
+	allow := strings.Join(allowed, ", ")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Allow", allow)
+		w.WriteHeader(http.StatusNoContent)
+	})
 }
@@ -0,0 +1,3254 @@
+package router
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"github.com/DblMOKRQ/DeNet_test_task/internal/router/handlers"
+	"github.com/DblMOKRQ/DeNet_test_task/internal/router/middleware"
+	"github.com/DblMOKRQ/DeNet_test_task/internal/service"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/buildinfo"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/jwt"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const testSecret = "test-secret"
+
+// testMaxLeaderboardLimit максимальный размер страницы таблицы лидеров в тестовом API
+const testMaxLeaderboardLimit = 100
+
+// testMaxReferrerChainDepth максимальная глубина цепочки рефереров в тестовом API
+const testMaxReferrerChainDepth = 3
+
+// testBasePath префикс версионированных маршрутов тестового API
+const testBasePath = "/api"
+
+// fakeRepository реализует только методы хранилища, используемые в тестах маршрутизации
+type fakeRepository struct {
+	service.UserRepository
+
+	usernames map[string]bool
+	// emails занятые адреса электронной почты
+	emails map[string]bool
+	// resetTokens пользователи по хешам выданных токенов сброса пароля
+	resetTokens map[string]uuid.UUID
+	// leaderboard все пользователи таблицы лидеров в порядке убывания баланса
+	leaderboard []*models.User
+	// leaderboardFilter условия отбора последнего вызова GetLeaderboard
+	leaderboardFilter models.LeaderboardFilter
+	users             map[uuid.UUID]*models.User
+	// callers пользователи, от имени которых вызывались методы изменения данных
+	callers []uuid.UUID
+	// transferErr ошибка, возвращаемая TransferPoints
+	transferErr error
+	// completeErr ошибка, возвращаемая CompleteTask
+	completeErr error
+	// tasksQuery параметры последнего вызова GetUserTasks
+	tasksQuery string
+	// deleted пользователи, удаленные через DeleteUser
+	deleted []uuid.UUID
+	// globalStats общие показатели, возвращаемые GetGlobalStats
+	globalStats *models.GlobalStats
+	// taskStats статистика, возвращаемая GetTaskStats; statsRange параметры последнего вызова
+	taskStats  []*models.TaskStats
+	statsRange string
+	// passwordHash текущий хеш пароля; updatedHash хеш, сохраненный через UpdatePassword
+	passwordHash string
+	updatedHash  string
+	// revertErr ошибка, возвращаемая RevertTask
+	revertErr error
+	// periodLeaderboard таблица лидеров, возвращаемая GetPeriodLeaderboard
+	periodLeaderboard []*models.PeriodLeaderboardEntry
+	// streakBonus бонус, начисляемый RecordLogin
+	streakBonus int
+	// imported пользователи, переданные в ImportUsers
+	imported []models.ImportUserRequest
+	// referralCodes реферальные коды пользователей
+	referralCodes map[string]uuid.UUID
+	// referrerChain полная цепочка рефереров; chainDepth глубина последнего запроса
+	referrerChain []*models.ReferrerChainEntry
+	chainDepth    int
+	// audit записи журнала аудита; auditQuery параметры последнего вызова ListAudit
+	audit      []*models.AuditEntry
+	auditQuery string
+	// rankErr ошибка, возвращаемая GetUserRank
+	rankErr error
+	// usersQuery параметры последнего вызова ListUsers
+	usersQuery string
+	// removedReferrers рефереры, удаленные пользователями через RemoveReferrer
+	removedReferrers map[uuid.UUID]uuid.UUID
+}
+
+func (f *fakeRepository) GetUserByID(_ context.Context, id uuid.UUID) (*models.User, error) {
+	user, ok := f.users[id]
+	if !ok {
+		return nil, models.ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (f *fakeRepository) CompleteTask(_ context.Context, userID uuid.UUID, taskRequest models.TaskRequest, _ models.TaskActivityLimit) (*models.Task, error) {
+	if f.completeErr != nil {
+		return nil, f.completeErr
+	}
+	f.callers = append(f.callers, userID)
+	return &models.Task{UserID: userID, TaskType: taskRequest.TaskType, Points: taskRequest.Points}, nil
+}
+
+func (f *fakeRepository) TransferPoints(_ context.Context, fromID, _ uuid.UUID, amount int) (int, error) {
+	if f.transferErr != nil {
+		return 0, f.transferErr
+	}
+	f.callers = append(f.callers, fromID)
+	return 100 - amount, nil
+}
+
+func (f *fakeRepository) AddReferrer(_ context.Context, userID, referrerID uuid.UUID) (*models.User, []models.ReferralCredit, error) {
+	f.callers = append(f.callers, userID)
+	if userID == referrerID {
+		return nil, nil, models.ErrSelfReferral
+	}
+	user := *f.users[userID]
+	if user.ReferrerID != nil {
+		if *user.ReferrerID == referrerID {
+			return nil, nil, models.ErrReferrerUnchanged
+		}
+		return nil, nil, models.ErrReferrerAlreadySet
+	}
+	if removed, ok := f.removedReferrers[userID]; ok && removed == referrerID {
+		return nil, nil, models.ErrReferrerRemoved
+	}
+	user.ReferrerID = &referrerID
+	return &user, nil, nil
+}
+
+func (f *fakeRepository) RemoveReferrer(_ context.Context, userID uuid.UUID) (uuid.UUID, []models.ReferralCredit, error) {
+	user, ok := f.users[userID]
+	if !ok {
+		return uuid.Nil, nil, models.ErrUserNotFound
+	}
+	if user.ReferrerID == nil {
+		return uuid.Nil, nil, models.ErrReferrerNotSet
+	}
+	referrerID := *user.ReferrerID
+	user.ReferrerID = nil
+	if f.removedReferrers == nil {
+		f.removedReferrers = make(map[uuid.UUID]uuid.UUID)
+	}
+	f.removedReferrers[userID] = referrerID
+	// Отменяется бонус в 10 баллов, но не больше баланса реферера
+	var reversals []models.ReferralCredit
+	if referrer, ok := f.users[referrerID]; ok {
+		delta := -min(10, referrer.Points)
+		referrer.Points += delta
+		reversals = append(reversals, models.ReferralCredit{UserID: referrerID, Level: 1, Points: delta, Balance: referrer.Points})
+	}
+	return referrerID, reversals, nil
+}
+
+func (f *fakeRepository) GetUserByReferralCode(_ context.Context, code string) (*models.User, error) {
+	id, ok := f.referralCodes[code]
+	if !ok {
+		return nil, models.ErrUserNotFound
+	}
+	return &models.User{ID: id, ReferralCode: code}, nil
+}
+
+func (f *fakeRepository) RegenerateReferralCode(_ context.Context, userID uuid.UUID) (string, error) {
+	if _, ok := f.users[userID]; !ok {
+		return "", models.ErrUserNotFound
+	}
+	for code, id := range f.referralCodes {
+		if id == userID {
+			delete(f.referralCodes, code)
+		}
+	}
+	code := fmt.Sprintf("ROTATED%d", len(f.referralCodes))
+	f.referralCodes[code] = userID
+	return code, nil
+}
+
+func (f *fakeRepository) GetReferrerChain(_ context.Context, _ uuid.UUID, maxDepth int) ([]*models.ReferrerChainEntry, error) {
+	f.chainDepth = maxDepth
+	return f.referrerChain[:min(maxDepth, len(f.referrerChain))], nil
+}
+
+func (f *fakeRepository) AdjustPoints(_ context.Context, adminID, userID uuid.UUID, delta int, reason string) (*models.PointAdjustment, error) {
+	user, ok := f.users[userID]
+	if !ok {
+		return nil, models.ErrUserNotFound
+	}
+	f.callers = append(f.callers, adminID)
+	balance := max(user.Points+delta, 0)
+	adjustment := &models.PointAdjustment{
+		ID: uuid.New(), UserID: userID, AdminID: adminID,
+		Delta: delta, AppliedDelta: balance - user.Points, Reason: reason, Balance: balance,
+	}
+	user.Points = balance
+	return adjustment, nil
+}
+
+func (f *fakeRepository) Record(_ context.Context, actorID uuid.UUID, action string, targetID uuid.UUID, metadata map[string]any) error {
+	f.audit = append(f.audit, &models.AuditEntry{ID: uuid.New(), ActorID: actorID, Action: action, TargetID: targetID, Metadata: metadata})
+	return nil
+}
+
+func (f *fakeRepository) ListAudit(_ context.Context, filter models.AuditFilter, limit, offset int) ([]*models.AuditEntry, int, error) {
+	actor := ""
+	if filter.ActorID != nil {
+		actor = filter.ActorID.String()
+	}
+	since := ""
+	if filter.Since != nil {
+		since = filter.Since.Format(time.RFC3339)
+	}
+	f.auditQuery = fmt.Sprintf("%s|%s|%s|%d|%d", actor, filter.Action, since, limit, offset)
+	page := f.audit[min(offset, len(f.audit)):]
+	return page[:min(limit, len(page))], len(f.audit), nil
+}
+
+func (f *fakeRepository) GetUserTasks(_ context.Context, userID uuid.UUID, taskType string, limit, offset int) ([]*models.Task, error) {
+	f.tasksQuery = fmt.Sprintf("user=%s type=%q limit=%d offset=%d", userID, taskType, limit, offset)
+	return nil, nil
+}
+
+func (f *fakeRepository) GetUserRank(_ context.Context, userID uuid.UUID) (int, error) {
+	if f.rankErr != nil {
+		return 0, f.rankErr
+	}
+	for i, user := range f.leaderboard {
+		if user.ID == userID {
+			return i + 1, nil
+		}
+	}
+	return 0, models.ErrUserNotFound
+}
+
+func (f *fakeRepository) GetPointsToNextRank(_ context.Context, userID uuid.UUID) (int, error) {
+	for i, user := range f.leaderboard {
+		if user.ID == userID {
+			if i == 0 {
+				return 0, nil
+			}
+			return f.leaderboard[i-1].Points - user.Points + 1, nil
+		}
+	}
+	return 0, models.ErrUserNotFound
+}
+
+func (f *fakeRepository) GetUserStanding(_ context.Context, userID uuid.UUID) (int, int, error) {
+	me, ok := f.users[userID]
+	if !ok {
+		return 0, 0, models.ErrUserNotFound
+	}
+	ahead := 0
+	for _, user := range f.users {
+		if user.Points > me.Points {
+			ahead++
+		}
+	}
+	return ahead, len(f.users), nil
+}
+
+func (f *fakeRepository) CountReferrals(_ context.Context, userID uuid.UUID) (int, error) {
+	count := 0
+	for _, user := range f.users {
+		if user.ReferrerID != nil && *user.ReferrerID == userID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (f *fakeRepository) ListUsers(_ context.Context, filter models.UserListFilter, limit, offset int) ([]*models.User, int, error) {
+	f.usersQuery = fmt.Sprintf("search=%q has_referrer=%s sort=%q asc=%t limit=%d offset=%d",
+		filter.Search, optional(filter.HasReferrer), filter.Sort, filter.Asc, limit, offset)
+	page := f.leaderboard[min(offset, len(f.leaderboard)):]
+	return page[:min(limit, len(page))], len(f.leaderboard), nil
+}
+
+func (f *fakeRepository) GetTaskStats(_ context.Context, from, to *time.Time) ([]*models.TaskStats, error) {
+	f.statsRange = fmt.Sprintf("from=%v to=%v", from, to)
+	return f.taskStats, nil
+}
+
+func (f *fakeRepository) GetGlobalStats(context.Context) (*models.GlobalStats, error) {
+	return f.globalStats, nil
+}
+
+func (f *fakeRepository) LoginUser(_ context.Context, username, _ string, email *string) (*models.User, error) {
+	if f.usernames[username] {
+		return nil, models.ErrUsernameTaken
+	}
+	if email != nil && f.emails[*email] {
+		return nil, models.ErrEmailTaken
+	}
+	return &models.User{ID: uuid.New(), Username: username, Role: models.RoleUser, Email: email}, nil
+}
+
+func (f *fakeRepository) GetUserByLogin(_ context.Context, login string) (*models.User, error) {
+	for _, user := range f.users {
+		if user.Username == login {
+			return user, nil
+		}
+	}
+	return nil, models.ErrUserNotFound
+}
+
+func (f *fakeRepository) CreatePasswordResetToken(_ context.Context, userID uuid.UUID, tokenHash string, _ time.Time) error {
+	if f.resetTokens == nil {
+		f.resetTokens = make(map[string]uuid.UUID)
+	}
+	f.resetTokens[tokenHash] = userID
+	return nil
+}
+
+func (f *fakeRepository) GetPasswordResetToken(_ context.Context, tokenHash string, _ time.Time) (uuid.UUID, error) {
+	userID, ok := f.resetTokens[tokenHash]
+	if !ok {
+		return uuid.Nil, models.ErrInvalidResetToken
+	}
+	return userID, nil
+}
+
+func (f *fakeRepository) VerifyEmail(_ context.Context, userID uuid.UUID, email string, at time.Time) error {
+	user, ok := f.users[userID]
+	if !ok || user.Email == nil || *user.Email != email {
+		return models.ErrInvalidVerificationToken
+	}
+	user.EmailVerifiedAt = &at
+	return nil
+}
+
+func (f *fakeRepository) DeleteUser(_ context.Context, userID uuid.UUID) error {
+	if _, ok := f.users[userID]; !ok {
+		return models.ErrUserNotFound
+	}
+	delete(f.users, userID)
+	f.deleted = append(f.deleted, userID)
+	return nil
+}
+
+func (f *fakeRepository) UpdateUsername(_ context.Context, userID uuid.UUID, username string, expectedVersion int) error {
+	user, ok := f.users[userID]
+	switch {
+	case !ok:
+		return models.ErrUserNotFound
+	case expectedVersion > 0 && user.Version != expectedVersion:
+		return models.ErrConflict
+	case user.Username == username:
+		return models.ErrUsernameUnchanged
+	case f.usernames[username]:
+		return models.ErrUsernameTaken
+	}
+	user.Username = username
+	user.Version++
+	return nil
+}
+
+func (f *fakeRepository) GetPasswordHash(_ context.Context, _ uuid.UUID) (string, error) {
+	return f.passwordHash, nil
+}
+
+func (f *fakeRepository) GetPasswordHistory(_ context.Context, _ uuid.UUID, _ int) ([]string, error) {
+	return nil, nil
+}
+
+func (f *fakeRepository) UpdatePassword(_ context.Context, _ uuid.UUID, newHash string, _ int) error {
+	f.updatedHash = newHash
+	return nil
+}
+
+func (f *fakeRepository) RevertTask(_ context.Context, taskID uuid.UUID) (*models.Task, error) {
+	if f.revertErr != nil {
+		return nil, f.revertErr
+	}
+	now := time.Now()
+	return &models.Task{ID: taskID, UserID: uuid.New(), TaskType: "daily", Points: 10, DeletedAt: &now}, nil
+}
+
+func (f *fakeRepository) GetPeriodLeaderboard(_ context.Context, _, _ time.Time, limit, offset int) ([]*models.PeriodLeaderboardEntry, int, error) {
+	page := f.periodLeaderboard[min(offset, len(f.periodLeaderboard)):]
+	return page[:min(limit, len(page))], len(f.periodLeaderboard), nil
+}
+
+func (f *fakeRepository) GetCredentials(_ context.Context, username string) (uuid.UUID, string, error) {
+	for id, user := range f.users {
+		if user.Username == username {
+			return id, f.passwordHash, nil
+		}
+	}
+	return uuid.Nil, "", models.ErrUserNotFound
+}
+
+func (f *fakeRepository) RecordLogin(_ context.Context, userID uuid.UUID, _ time.Time) (int, int, error) {
+	user := f.users[userID]
+	user.StreakDays++
+	user.Points += f.streakBonus
+	return user.StreakDays, f.streakBonus, nil
+}
+
+func (f *fakeRepository) ImportUsers(_ context.Context, users []models.ImportUserRequest) (int, error) {
+	inserted := 0
+	for _, user := range users {
+		if !f.usernames[user.Username] {
+			inserted++
+		}
+	}
+	f.imported = append(f.imported, users...)
+	return inserted, nil
+}
+
+func (f *fakeRepository) GetUserByUsername(_ context.Context, username string) (*models.PublicProfile, error) {
+	for _, user := range f.users {
+		if user.Username == username {
+			return &models.PublicProfile{ID: user.ID, Username: user.Username, Points: user.Points}, nil
+		}
+	}
+	return nil, models.ErrUserNotFound
+}
+
+func (f *fakeRepository) UsernameExists(_ context.Context, username string) (bool, error) {
+	return f.usernames[username], nil
+}
+
+func (f *fakeRepository) GetLeaderboard(_ context.Context, filter models.LeaderboardFilter, limit, offset int) ([]*models.User, int, error) {
+	f.leaderboardFilter = filter
+	page := f.leaderboard[min(offset, len(f.leaderboard)):]
+	return page[:min(limit, len(page))], len(f.leaderboard), nil
+}
+
+// newTestHandler собирает API поверх тестового хранилища
+func newTestHandler(t *testing.T, repo service.UserRepository, opts Options) http.Handler {
+	t.Helper()
+
+	handler, _ := newTestAPI(t, repo, opts, service.Options{})
+	return handler
+}
+
+// newTestAPI собирает тестовый API поверх сервиса с настройками serviceOpts
+// и возвращает сервис для тестов, управляющих им напрямую
+func newTestAPI(t *testing.T, repo service.UserRepository, opts Options, serviceOpts service.Options) (http.Handler, *service.UserService) {
+	t.Helper()
+
+	// Лимит тела запроса в рабочей конфигурации всегда задан значением по умолчанию
+	if opts.MaxBodyBytes == 0 {
+		opts.MaxBodyBytes = 1 << 20
+	}
+	if opts.Drain == nil {
+		opts.Drain = middleware.NewDrain(time.Second)
+	}
+	if opts.BasePath == "" {
+		opts.BasePath = testBasePath
+	}
+
+	log := zap.NewNop()
+	jwtService := newTestJWT(t)
+	serviceOpts.ReservedUsernames = []string{"admin"}
+	if audit, ok := repo.(service.AuditRepository); ok {
+		serviceOpts.Audit = audit
+	}
+	userService := service.NewUserService(repo, serviceOpts, log)
+
+	return versioned(NewRouter(jwtService,
+		handlers.NewUserHandler(userService, jwtService, handlers.Options{
+			MaxLeaderboardLimit:   testMaxLeaderboardLimit,
+			MaxReferrerChainDepth: testMaxReferrerChainDepth,
+			APIPrefix:             testBasePath + V1,
+		}, log),
+		handlers.NewConfigHandler(models.PublicConfig{
+			ReferralBonus:           10,
+			ReferralSchedule:        []int{10, 5},
+			LeaderboardDefaultLimit: handlers.DefaultLeaderboardLimit,
+			LeaderboardMaxLimit:     testMaxLeaderboardLimit,
+		}, log),
+		handlers.NewAdminHandler(userService, log),
+		handlers.NewHealthHandler(opts.Drain, log),
+		opts,
+		log,
+	).Setup()), userService
+}
+
+// versioned направляет запросы тестов к маршрутам API, записанным без префикса, на версию v1,
+// чтобы тесты обработчиков не зависели от префикса. Проверки состояния не версионируются.
+func versioned(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/healthz", "/readyz":
+		default:
+			req.URL.Path = testBasePath + V1 + req.URL.Path
+		}
+		handler.ServeHTTP(w, req)
+	})
+}
+
+// newTestJWT создает сервис токенов с тестовым секретом
+func newTestJWT(t *testing.T) *jwt.Service {
+	t.Helper()
+
+	jwtService, err := jwt.NewService(jwt.Options{SecretKey: testSecret, TokenDuration: time.Hour}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("jwt.NewService() error = %v", err)
+	}
+	return jwtService
+}
+
+// testToken выпускает токен пользователя с указанной ролью, принимаемый тестовым API
+func testToken(t *testing.T, userID uuid.UUID, role string) string {
+	t.Helper()
+
+	token, err := newTestJWT(t).GenerateToken(userID.String(), role)
+	if err != nil {
+		t.Fatalf("GenerateToken() error = %v", err)
+	}
+	return token
+}
+
+// authRequest выполняет запрос с токеном обычного пользователя
+func authRequest(t *testing.T, handler http.Handler, method, target, body string, userID uuid.UUID) *httptest.ResponseRecorder {
+	t.Helper()
+
+	return roleRequest(t, handler, method, target, body, userID, models.RoleUser)
+}
+
+// roleRequest выполняет запрос с токеном пользователя с указанной ролью
+func roleRequest(t *testing.T, handler http.Handler, method, target, body string, userID uuid.UUID, role string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Authorization", testToken(t, userID, role))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// usernameAvailable выполняет запрос проверки имени от клиента с адресом remoteAddr
+func usernameAvailable(handler http.Handler, username, remoteAddr string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/auth/username-available?username="+username, nil)
+	req.RemoteAddr = remoteAddr
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestUsernameAvailable(t *testing.T) {
+	handler := newTestHandler(t, &fakeRepository{usernames: map[string]bool{"alice": true}},
+		Options{AuthRateLimit: 100, AuthRateLimitWindow: time.Minute})
+
+	tests := []struct {
+		name     string
+		username string
+		want     models.UsernameAvailabilityResponse
+		wantCode int
+	}{
+		{name: "available", username: "bob", want: models.UsernameAvailabilityResponse{Username: "bob", Available: true}, wantCode: http.StatusOK},
+		{name: "taken", username: "alice", want: models.UsernameAvailabilityResponse{Username: "alice"}, wantCode: http.StatusOK},
+		{name: "taken in another case", username: "ALICE", want: models.UsernameAvailabilityResponse{Username: "alice"}, wantCode: http.StatusOK},
+		{name: "blocklisted", username: "Admin", want: models.UsernameAvailabilityResponse{Username: "admin"}, wantCode: http.StatusOK},
+		{name: "missing username", username: "", wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := usernameAvailable(handler, tt.username, "203.0.113.7:1234")
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			var got models.UsernameAvailabilityResponse
+			if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("response = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUsernameAvailableRateLimit(t *testing.T) {
+	handler := newTestHandler(t, &fakeRepository{}, Options{AuthRateLimit: 2, AuthRateLimitWindow: time.Minute})
+
+	for i := 0; i < 2; i++ {
+		if rec := usernameAvailable(handler, "bob", "203.0.113.7:1234"); rec.Code != http.StatusOK {
+			t.Fatalf("request %d status = %d, want %d", i+1, rec.Code, http.StatusOK)
+		}
+	}
+
+	rec := usernameAvailable(handler, "bob", "203.0.113.7:5678")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("request over limit status = %d, want %d", rec.Code, http.StatusTooManyRequests)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("Retry-After header is not set")
+	}
+
+	// Лимит считается отдельно для каждого клиента
+	if rec := usernameAvailable(handler, "bob", "198.51.100.1:1234"); rec.Code != http.StatusOK {
+		t.Errorf("another client status = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestGetLeaderboardPagination(t *testing.T) {
+	repo := &fakeRepository{}
+	for _, username := range []string{"carol", "bob", "alice"} {
+		repo.leaderboard = append(repo.leaderboard, &models.User{ID: uuid.New(), Username: username})
+	}
+	handler := newTestHandler(t, repo, Options{})
+
+	tests := []struct {
+		name     string
+		query    string
+		wantCode int
+		want     []string
+		// wantLimit ожидаемое значение заголовка X-Limit
+		wantLimit string
+	}{
+		{name: "default page", query: "", wantCode: http.StatusOK, want: []string{"carol", "bob", "alice"}, wantLimit: "10"},
+		{name: "limit and offset", query: "?limit=1&offset=1", wantCode: http.StatusOK, want: []string{"bob"}, wantLimit: "1"},
+		{name: "offset past the end", query: "?offset=10", wantCode: http.StatusOK, want: []string{}, wantLimit: "10"},
+		{name: "limit above maximum is clamped", query: "?limit=500", wantCode: http.StatusOK, want: []string{"carol", "bob", "alice"}, wantLimit: "100"},
+		{name: "zero limit", query: "?limit=0", wantCode: http.StatusBadRequest},
+		{name: "negative limit", query: "?limit=-5", wantCode: http.StatusBadRequest},
+		{name: "non-numeric limit", query: "?limit=abc", wantCode: http.StatusBadRequest},
+		{name: "negative offset", query: "?offset=-1", wantCode: http.StatusBadRequest},
+		{name: "non-numeric offset", query: "?offset=abc", wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := authRequest(t, handler, http.MethodGet, "/users/leaderboard"+tt.query, "", uuid.New())
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			if total := rec.Header().Get("X-Total-Count"); total != "3" {
+				t.Errorf("X-Total-Count = %q, want %q", total, "3")
+			}
+			if limit := rec.Header().Get(handlers.LimitHeader); limit != tt.wantLimit {
+				t.Errorf("%s = %q, want %q", handlers.LimitHeader, limit, tt.wantLimit)
+			}
+			var users []models.User
+			if err := json.NewDecoder(rec.Body).Decode(&users); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			got := []string{}
+			for _, user := range users {
+				got = append(got, user.Username)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("users = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("users = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestMeAliasesMatchTokenRoutes(t *testing.T) {
+	userID := uuid.New()
+	referrerBody := `{"referrer_id":"` + uuid.NewString() + `"}`
+
+	tests := []struct {
+		name        string
+		method      string
+		route, me   string
+		body        string
+		wantCallers int
+	}{
+		{name: "status", method: http.MethodGet, route: "/users/status", me: "/users/me"},
+		{name: "complete task", method: http.MethodPost, route: "/users/task/complete", me: "/users/me/task/complete",
+			body: `{"task_type":"daily","points":5}`, wantCallers: 1},
+		{name: "referrer", method: http.MethodPost, route: "/users/referrer", me: "/users/me/referrer",
+			body: referrerBody, wantCallers: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeRepository{users: map[uuid.UUID]*models.User{userID: {ID: userID, Username: "alice", Points: 7}}}
+			handler := newTestHandler(t, repo, Options{})
+
+			route := authRequest(t, handler, tt.method, tt.route, tt.body, userID)
+			me := authRequest(t, handler, tt.method, tt.me, tt.body, userID)
+
+			if route.Code != http.StatusOK || me.Code != route.Code {
+				t.Fatalf("status: %s = %d, %s = %d, want both %d", tt.route, route.Code, tt.me, me.Code, http.StatusOK)
+			}
+			if route.Body.String() != me.Body.String() {
+				t.Errorf("body: %s = %s, %s = %s", tt.route, route.Body, tt.me, me.Body)
+			}
+			if len(repo.callers) != 2*tt.wantCallers {
+				t.Fatalf("repository calls = %d, want %d", len(repo.callers), 2*tt.wantCallers)
+			}
+			for _, caller := range repo.callers {
+				if caller != userID {
+					t.Errorf("repository called for %s, want token user %s", caller, userID)
+				}
+			}
+		})
+	}
+}
+
+func TestMeRequiresToken(t *testing.T) {
+	handler := newTestHandler(t, &fakeRepository{}, Options{})
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users/me", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestGetLeaderboardDefaultLimit(t *testing.T) {
+	repo := &fakeRepository{}
+	for _, username := range []string{"dave", "carol", "bob", "alice"} {
+		repo.leaderboard = append(repo.leaderboard, &models.User{ID: uuid.New(), Username: username})
+	}
+
+	tests := []struct {
+		name      string
+		opts      handlers.Options
+		query     string
+		wantLimit string
+	}{
+		{name: "configured default", opts: handlers.Options{DefaultLeaderboardLimit: 2, MaxLeaderboardLimit: 3}, wantLimit: "2"},
+		{name: "explicit limit is clamped", opts: handlers.Options{DefaultLeaderboardLimit: 2, MaxLeaderboardLimit: 3}, query: "?limit=10", wantLimit: "3"},
+		{name: "default above maximum", opts: handlers.Options{DefaultLeaderboardLimit: 5, MaxLeaderboardLimit: 3}, wantLimit: "3"},
+		{name: "unset default", opts: handlers.Options{MaxLeaderboardLimit: 100}, wantLimit: strconv.Itoa(handlers.DefaultLeaderboardLimit)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := zap.NewNop()
+			jwtService := newTestJWT(t)
+			userService := service.NewUserService(repo, service.Options{}, log)
+			handler := versioned(NewRouter(jwtService,
+				handlers.NewUserHandler(userService, jwtService, tt.opts, log),
+				handlers.NewConfigHandler(models.PublicConfig{}, log),
+				handlers.NewAdminHandler(userService, log),
+				handlers.NewHealthHandler(middleware.NewDrain(time.Second), log),
+				Options{MaxBodyBytes: 1 << 20, BasePath: testBasePath},
+				log,
+			).Setup())
+
+			rec := authRequest(t, handler, http.MethodGet, "/users/leaderboard"+tt.query, "", uuid.New())
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+			}
+			if got := rec.Header().Get(handlers.LimitHeader); got != tt.wantLimit {
+				t.Errorf("%s = %q, want %q", handlers.LimitHeader, got, tt.wantLimit)
+			}
+		})
+	}
+}
+
+func TestGetLeaderboardFilters(t *testing.T) {
+	createdAfter := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		query     string
+		wantCode  int
+		wantField string
+		// want ожидаемые условия отбора в формате min|max|created_after
+		want string
+	}{
+		{name: "no filters", query: "", wantCode: http.StatusOK, want: "<nil>|<nil>|<nil>"},
+		{name: "points range", query: "?min_points=10&max_points=50", wantCode: http.StatusOK, want: "10|50|<nil>"},
+		{name: "equal bounds", query: "?min_points=10&max_points=10", wantCode: http.StatusOK, want: "10|10|<nil>"},
+		{name: "created after", query: "?created_after=2026-01-02T03:04:05Z", wantCode: http.StatusOK, want: "<nil>|<nil>|" + createdAfter.String()},
+		{name: "non-numeric min", query: "?min_points=ten", wantCode: http.StatusBadRequest, wantField: "min_points"},
+		{name: "non-numeric max", query: "?max_points=1.5", wantCode: http.StatusBadRequest, wantField: "max_points"},
+		{name: "invalid created after", query: "?created_after=yesterday", wantCode: http.StatusBadRequest, wantField: "created_after"},
+		{name: "min above max", query: "?min_points=50&max_points=10", wantCode: http.StatusBadRequest, wantField: "min_points"},
+		{name: "combined with period", query: "?period=weekly&min_points=10", wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeRepository{leaderboard: []*models.User{{ID: uuid.New(), Username: "alice"}}}
+			handler := newTestHandler(t, repo, Options{})
+
+			rec := authRequest(t, handler, http.MethodGet, "/users/leaderboard"+tt.query, "", uuid.New())
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantCode, rec.Body)
+			}
+			if tt.wantField != "" {
+				var got models.ErrorResponse
+				if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+					t.Fatalf("decode response: %v", err)
+				}
+				if got.Field != tt.wantField {
+					t.Errorf("response = %+v, want an error for field %q", got, tt.wantField)
+				}
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			filter := repo.leaderboardFilter
+			got := fmt.Sprintf("%s|%s|%s", optional(filter.MinPoints), optional(filter.MaxPoints), optional(filter.CreatedAfter))
+			if got != tt.want {
+				t.Errorf("filter = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// optional форматирует необязательное значение условия отбора
+func optional[T any](v *T) string {
+	if v == nil {
+		return "<nil>"
+	}
+	return fmt.Sprint(*v)
+}
+
+func TestGetLeaderboardEmptyIsArray(t *testing.T) {
+	handler := newTestHandler(t, &fakeRepository{}, Options{})
+
+	rec := authRequest(t, handler, http.MethodGet, "/users/leaderboard", "", uuid.New())
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if body := strings.TrimSpace(rec.Body.String()); body != "[]" {
+		t.Errorf("body = %s, want []", body)
+	}
+}
+
+func TestRequestIDReturned(t *testing.T) {
+	handler := newTestHandler(t, &fakeRepository{}, Options{AuthRateLimit: 10, AuthRateLimitWindow: time.Minute})
+
+	for _, target := range []string{"/auth/username-available?username=bob", "/users/leaderboard"} {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set(middleware.RequestIDHeader, "client-request-1")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if got := rec.Header().Get(middleware.RequestIDHeader); got != "client-request-1" {
+			t.Errorf("%s: %s = %q, want %q", target, middleware.RequestIDHeader, got, "client-request-1")
+		}
+	}
+}
+
+func TestPublicConfig(t *testing.T) {
+	handler := newTestHandler(t, &fakeRepository{}, Options{})
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/config/public", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	// Публичные параметры доступны без токена и содержат только известные поля
+	var got map[string]any
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	want := map[string]any{
+		"referral_bonus":            float64(10),
+		"referral_schedule":         []any{float64(10), float64(5)},
+		"leaderboard_default_limit": float64(handlers.DefaultLeaderboardLimit),
+		"leaderboard_max_limit":     float64(testMaxLeaderboardLimit),
+	}
+	if len(got) != len(want) {
+		t.Fatalf("public config = %v, want %v", got, want)
+	}
+	for key, value := range want {
+		if !reflect.DeepEqual(got[key], value) {
+			t.Errorf("%s = %v, want %v", key, got[key], value)
+		}
+	}
+}
+
+func TestTransferPoints(t *testing.T) {
+	userID, receiverID := uuid.New(), uuid.New()
+
+	tests := []struct {
+		name        string
+		body        string
+		transferErr error
+		wantCode    int
+	}{
+		{name: "success", body: `{"to":"` + receiverID.String() + `","amount":30}`, wantCode: http.StatusOK},
+		{name: "insufficient balance", body: `{"to":"` + receiverID.String() + `","amount":30}`,
+			transferErr: models.ErrInsufficientPoints, wantCode: http.StatusConflict},
+		{name: "unknown receiver", body: `{"to":"` + receiverID.String() + `","amount":30}`,
+			transferErr: models.ErrUserNotFound, wantCode: http.StatusNotFound},
+		{name: "receiver balance overflow", body: `{"to":"` + receiverID.String() + `","amount":30}`,
+			transferErr: models.ErrPointsOutOfRange, wantCode: http.StatusBadRequest},
+		{name: "self-transfer", body: `{"to":"` + userID.String() + `","amount":30}`, wantCode: http.StatusBadRequest},
+		{name: "non-positive amount", body: `{"to":"` + receiverID.String() + `","amount":0}`, wantCode: http.StatusBadRequest},
+		{name: "invalid receiver", body: `{"to":"bob","amount":30}`, wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeRepository{transferErr: tt.transferErr}
+			handler := newTestHandler(t, repo, Options{})
+
+			rec := authRequest(t, handler, http.MethodPost, "/users/me/transfer", tt.body, userID)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			var got models.TransferResponse
+			if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			want := models.TransferResponse{From: userID, To: receiverID, Amount: 30, Balance: 70}
+			if got != want {
+				t.Errorf("response = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestErrorCodes(t *testing.T) {
+	userID, receiverID := uuid.New(), uuid.New()
+	newRepo := func() *fakeRepository {
+		return &fakeRepository{
+			usernames:   map[string]bool{"alice": true},
+			users:       map[uuid.UUID]*models.User{userID: {ID: userID, Username: "bob"}},
+			transferErr: models.ErrInsufficientPoints,
+		}
+	}
+
+	tests := []struct {
+		name        string
+		method      string
+		target      string
+		body        string
+		contentType string
+		wantCode    int
+		wantErrCode string
+	}{
+		{name: "sentinel from service", method: http.MethodPost, target: "/users/me/transfer",
+			body: `{"to":"` + receiverID.String() + `","amount":30}`, wantCode: http.StatusConflict, wantErrCode: models.CodeInsufficientPoints},
+		{name: "taken username", method: http.MethodPost, target: "/users/register",
+			body: `{"username":"alice","password":"password123"}`, wantCode: http.StatusConflict, wantErrCode: models.CodeUsernameTaken},
+		{name: "missing referrer", method: http.MethodDelete, target: "/users/me/referrer", wantCode: http.StatusNotFound, wantErrCode: models.CodeReferrerNotSet},
+		{name: "self referral", method: http.MethodPost, target: "/users/me/referrer",
+			body: `{"referrer_id":"` + userID.String() + `"}`, wantCode: http.StatusBadRequest, wantErrCode: models.CodeReferralCycle},
+		{name: "body rules", method: http.MethodPost, target: "/users/register",
+			body: `{"username":"al","password":"password123"}`, wantCode: http.StatusUnprocessableEntity, wantErrCode: models.CodeValidationFailed},
+		{name: "points cap", method: http.MethodPost, target: "/users/me/task/complete",
+			body: `{"task_type":"daily","points":101}`, wantCode: http.StatusBadRequest, wantErrCode: models.CodeValidationFailed},
+		{name: "malformed body", method: http.MethodPost, target: "/users/me/transfer",
+			body: `{"to":`, wantCode: http.StatusBadRequest, wantErrCode: models.CodeInvalidRequestBody},
+		{name: "content type", method: http.MethodPost, target: "/users/me/transfer", contentType: "text/plain",
+			body: `{"to":"` + receiverID.String() + `","amount":30}`, wantCode: http.StatusUnsupportedMediaType, wantErrCode: models.CodeUnsupportedMediaType},
+		{name: "self transfer", method: http.MethodPost, target: "/users/me/transfer",
+			body: `{"to":"` + userID.String() + `","amount":30}`, wantCode: http.StatusBadRequest, wantErrCode: models.CodeSelfTransfer},
+		{name: "query parameter", method: http.MethodGet, target: "/users/leaderboard?limit=abc", wantCode: http.StatusBadRequest, wantErrCode: models.CodeInvalidParameter},
+		{name: "admin only", method: http.MethodGet, target: "/admin/stats", wantCode: http.StatusForbidden, wantErrCode: models.CodeForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, _ := newTestAPI(t, newRepo(), Options{RequireJSON: true}, service.Options{MaxPointsPerTask: 100})
+
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			req.Header.Set("Authorization", testToken(t, userID, models.RoleUser))
+			if tt.body != "" {
+				contentType := tt.contentType
+				if contentType == "" {
+					contentType = "application/json"
+				}
+				req.Header.Set("Content-Type", contentType)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d; body: %s", rec.Code, tt.wantCode, rec.Body.String())
+			}
+			var got models.ErrorResponse
+			if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if got.Code != tt.wantErrCode || got.Error == "" {
+				t.Errorf("response = %+v, want code %s with a message", got, tt.wantErrCode)
+			}
+		})
+	}
+}
+
+func TestCompleteTaskErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		completeErr error
+		wantCode    int
+	}{
+		{name: "user not found", completeErr: models.ErrUserNotFound, wantCode: http.StatusNotFound},
+		{name: "balance overflow", completeErr: models.ErrPointsOutOfRange, wantCode: http.StatusBadRequest},
+		{name: "suspicious activity rejected", completeErr: models.ErrTooManyTasks, wantCode: http.StatusTooManyRequests},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := newTestHandler(t, &fakeRepository{completeErr: tt.completeErr}, Options{})
+
+			rec := authRequest(t, handler, http.MethodPost, "/users/task/complete", `{"task_type":"daily","points":5}`, uuid.New())
+			if rec.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestCompleteTaskPointsCap(t *testing.T) {
+	handler, _ := newTestAPI(t, &fakeRepository{}, Options{}, service.Options{MaxPointsPerTask: 100})
+	userID := uuid.New()
+
+	if rec := authRequest(t, handler, http.MethodPost, "/users/task/complete", `{"task_type":"daily","points":100}`, userID); rec.Code != http.StatusOK {
+		t.Fatalf("within limit status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	rec := authRequest(t, handler, http.MethodPost, "/users/task/complete", `{"task_type":"daily","points":101}`, userID)
+	var got models.ErrorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("decode error response: %v", err)
+	}
+	if rec.Code != http.StatusBadRequest || got.Field != "points" {
+		t.Errorf("over limit = %d field %q, want %d field points", rec.Code, got.Field, http.StatusBadRequest)
+	}
+}
+
+func TestGetUserTasks(t *testing.T) {
+	userID := uuid.New()
+
+	tests := []struct {
+		name      string
+		query     string
+		wantCode  int
+		wantQuery string
+	}{
+		{name: "defaults", wantCode: http.StatusOK,
+			wantQuery: fmt.Sprintf(`user=%s type="" limit=%d offset=0`, userID, handlers.DefaultTasksLimit)},
+		{name: "filter and page", query: "?task_type=vk&limit=5&offset=10", wantCode: http.StatusOK,
+			wantQuery: fmt.Sprintf(`user=%s type="vk" limit=5 offset=10`, userID)},
+		{name: "zero limit", query: "?limit=0", wantCode: http.StatusBadRequest},
+		{name: "negative offset", query: "?offset=-1", wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeRepository{}
+			handler := newTestHandler(t, repo, Options{})
+
+			rec := authRequest(t, handler, http.MethodGet, "/users/me/tasks"+tt.query, "", userID)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+			if repo.tasksQuery != tt.wantQuery {
+				t.Errorf("repository called with %s, want %s", repo.tasksQuery, tt.wantQuery)
+			}
+			if tt.wantCode == http.StatusOK && strings.TrimSpace(rec.Body.String()) != "[]" {
+				t.Errorf("body = %q, want []", rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestGetUserStatusPointsToNextRank(t *testing.T) {
+	aliceID, bobID := uuid.New(), uuid.New()
+	newRepo := func() *fakeRepository {
+		alice := &models.User{ID: aliceID, Username: "alice", Points: 30}
+		bob := &models.User{ID: bobID, Username: "bob", Points: 20}
+		return &fakeRepository{
+			users:       map[uuid.UUID]*models.User{aliceID: alice, bobID: bob},
+			leaderboard: []*models.User{alice, bob},
+		}
+	}
+
+	// status возвращает поле points_to_next_rank из ответа; nil, если поля нет
+	status := func(t *testing.T, handler http.Handler, userID uuid.UUID) *int {
+		t.Helper()
+
+		rec := authRequest(t, handler, http.MethodGet, "/users/status", "", userID)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+		}
+		var got struct {
+			PointsToNextRank *int `json:"points_to_next_rank"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		return got.PointsToNextRank
+	}
+
+	handler, _ := newTestAPI(t, newRepo(), Options{}, service.Options{PointsToNextRank: true})
+	if got := status(t, handler, bobID); got == nil || *got != 11 {
+		t.Errorf("bob points_to_next_rank = %v, want 11", got)
+	}
+	if got := status(t, handler, aliceID); got == nil || *got != 0 {
+		t.Errorf("alice points_to_next_rank = %v, want 0 for the top user", got)
+	}
+
+	disabled := newTestHandler(t, newRepo(), Options{})
+	if got := status(t, disabled, bobID); got != nil {
+		t.Errorf("disabled: points_to_next_rank = %d, want the field omitted", *got)
+	}
+}
+
+func TestGetUserSummary(t *testing.T) {
+	alice := &models.User{ID: uuid.New(), Username: "alice", Points: 30}
+	bob := &models.User{ID: uuid.New(), Username: "bob", Points: 10, ReferrerID: &alice.ID}
+	newRepo := func() *fakeRepository {
+		return &fakeRepository{
+			users:       map[uuid.UUID]*models.User{alice.ID: alice, bob.ID: bob},
+			leaderboard: []*models.User{alice, bob},
+		}
+	}
+
+	repo := newRepo()
+	rec := authRequest(t, newTestHandler(t, repo, Options{}), http.MethodGet, "/users/me/summary", "", bob.ID)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(rec.Body.Bytes(), &fields); err != nil {
+		t.Fatalf("decode summary: %v", err)
+	}
+	for _, field := range []string{"user", "rank", "percentile", "referral_count", "recent_tasks"} {
+		if _, ok := fields[field]; !ok {
+			t.Errorf("summary %s missing field %s", rec.Body.String(), field)
+		}
+	}
+	var summary models.UserSummary
+	if err := json.Unmarshal(rec.Body.Bytes(), &summary); err != nil {
+		t.Fatalf("decode summary: %v", err)
+	}
+	if summary.User == nil || summary.User.ID != bob.ID || summary.Rank != 2 || summary.Percentile != 100 || summary.ReferralCount != 0 || summary.RecentTasks == nil {
+		t.Errorf("summary = %s, want bob at rank 2 in the top 100%% without referrals and an empty task list", rec.Body.String())
+	}
+	if want := fmt.Sprintf(`user=%s type="" limit=%d offset=0`, bob.ID, service.DefaultSummaryRecentTasks); repo.tasksQuery != want {
+		t.Errorf("recent tasks query = %s, want %s", repo.tasksQuery, want)
+	}
+
+	rec = authRequest(t, newTestHandler(t, newRepo(), Options{}), http.MethodGet, "/users/me/summary", "", alice.ID)
+	if err := json.Unmarshal(rec.Body.Bytes(), &summary); err != nil || summary.Rank != 1 || summary.Percentile != 50 || summary.ReferralCount != 1 {
+		t.Errorf("alice summary = %s, want rank 1 in the top 50%% with one referral", rec.Body.String())
+	}
+
+	t.Run("mid-rank percentile", func(t *testing.T) {
+		repo := &fakeRepository{users: make(map[uuid.UUID]*models.User)}
+		var tied []uuid.UUID
+		for i, points := range []int{70, 60, 50, 50, 30, 20, 10} {
+			user := &models.User{ID: uuid.New(), Username: fmt.Sprintf("user%d", i), Points: points}
+			repo.users[user.ID] = user
+			repo.leaderboard = append(repo.leaderboard, user)
+			if points == 50 {
+				tied = append(tied, user.ID)
+			}
+		}
+		handler := newTestHandler(t, repo, Options{})
+
+		// Двое опережают обоих пользователей с 50 баллами: оба в верхних 3/7
+		for _, id := range tied {
+			rec := authRequest(t, handler, http.MethodGet, "/users/me/summary", "", id)
+			var summary models.UserSummary
+			if err := json.Unmarshal(rec.Body.Bytes(), &summary); err != nil || summary.Percentile != 42.86 {
+				t.Errorf("summary = %s, want percentile 42.86", rec.Body.String())
+			}
+		}
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		rec := authRequest(t, newTestHandler(t, newRepo(), Options{}), http.MethodGet, "/users/me/summary", "", uuid.New())
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusNotFound)
+		}
+	})
+
+	t.Run("repository error", func(t *testing.T) {
+		repo := newRepo()
+		repo.rankErr = errors.New("connection refused")
+
+		rec := authRequest(t, newTestHandler(t, repo, Options{}), http.MethodGet, "/users/me/summary", "", bob.ID)
+		if rec.Code != http.StatusInternalServerError {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+		}
+		if strings.Contains(rec.Body.String(), "alice") || strings.Contains(rec.Body.String(), "bob") {
+			t.Errorf("body = %q, want no partial summary", rec.Body.String())
+		}
+	})
+}
+
+func TestTaskStatsRequiresAdmin(t *testing.T) {
+	stats := []*models.TaskStats{{TaskType: "daily", Count: 2, TotalPoints: 30}}
+	handler := newTestHandler(t, &fakeRepository{taskStats: stats}, Options{})
+
+	if rec := authRequest(t, handler, http.MethodGet, "/admin/tasks/stats", "", uuid.New()); rec.Code != http.StatusForbidden {
+		t.Errorf("user status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+
+	rec := roleRequest(t, handler, http.MethodGet, "/admin/tasks/stats", "", uuid.New(), models.RoleAdmin)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("admin status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	var got []models.TaskStats
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(got) != 1 || got[0] != *stats[0] {
+		t.Errorf("stats = %+v, want %+v", got, *stats[0])
+	}
+}
+
+func TestGlobalStatsRequiresAdmin(t *testing.T) {
+	stats := &models.GlobalStats{TotalUsers: 3, TotalPoints: 45, TotalTasks: 4, UsersWithReferrer: 1}
+	handler := newTestHandler(t, &fakeRepository{globalStats: stats}, Options{})
+
+	if rec := authRequest(t, handler, http.MethodGet, "/admin/stats", "", uuid.New()); rec.Code != http.StatusForbidden {
+		t.Errorf("user status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+
+	rec := roleRequest(t, handler, http.MethodGet, "/admin/stats", "", uuid.New(), models.RoleAdmin)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("admin status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	var got models.GlobalStats
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if got != *stats {
+		t.Errorf("stats = %+v, want %+v", got, *stats)
+	}
+}
+
+func TestListUsers(t *testing.T) {
+	users := []*models.User{{ID: uuid.New(), Username: "alice", Password: "hashed-password"}, {ID: uuid.New(), Username: "bob"}}
+
+	t.Run("requires admin", func(t *testing.T) {
+		handler := newTestHandler(t, &fakeRepository{leaderboard: users}, Options{})
+		if rec := authRequest(t, handler, http.MethodGet, "/admin/users", "", uuid.New()); rec.Code != http.StatusForbidden {
+			t.Errorf("user status = %d, want %d", rec.Code, http.StatusForbidden)
+		}
+	})
+
+	tests := []struct {
+		name      string
+		query     string
+		wantCode  int
+		wantQuery string
+	}{
+		{name: "defaults", wantCode: http.StatusOK,
+			wantQuery: fmt.Sprintf(`search="" has_referrer=<nil> sort="" asc=false limit=%d offset=0`, handlers.DefaultUsersLimit)},
+		{name: "search and sort", query: "?search=Ali&sort=points&order=asc&has_referrer=false&limit=5", wantCode: http.StatusOK,
+			wantQuery: `search="Ali" has_referrer=false sort="points" asc=true limit=5 offset=0`},
+		{name: "limit capped", query: "?limit=1000", wantCode: http.StatusOK,
+			wantQuery: fmt.Sprintf(`search="" has_referrer=<nil> sort="" asc=false limit=%d offset=0`, handlers.MaxUsersLimit)},
+		{name: "search too long", query: "?search=" + strings.Repeat("a", handlers.MaxUserSearchLength+1), wantCode: http.StatusBadRequest},
+		{name: "unknown sort", query: "?sort=username", wantCode: http.StatusBadRequest},
+		{name: "unknown order", query: "?order=up", wantCode: http.StatusBadRequest},
+		{name: "invalid has_referrer", query: "?has_referrer=maybe", wantCode: http.StatusBadRequest},
+		{name: "zero limit", query: "?limit=0", wantCode: http.StatusBadRequest},
+		{name: "negative offset", query: "?offset=-1", wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeRepository{leaderboard: users}
+			handler := newTestHandler(t, repo, Options{})
+
+			rec := roleRequest(t, handler, http.MethodGet, "/admin/users"+tt.query, "", uuid.New(), models.RoleAdmin)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+			if repo.usersQuery != tt.wantQuery {
+				t.Errorf("repository called with %s, want %s", repo.usersQuery, tt.wantQuery)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			var page models.PagedResponse[*models.User]
+			if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if page.Total != len(users) || len(page.Items) != len(users) {
+				t.Errorf("page = %+v, want %d users", page, len(users))
+			}
+			if strings.Contains(rec.Body.String(), "hashed-password") {
+				t.Errorf("body = %s, want no password hash", rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestTaskStatsRange(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+
+	tests := []struct {
+		name      string
+		query     string
+		wantCode  int
+		wantRange string
+	}{
+		{name: "no range", wantCode: http.StatusOK, wantRange: "from=<nil> to=<nil>"},
+		{name: "both bounds", query: "?from=2024-01-01T00:00:00Z&to=2024-02-01T00:00:00Z", wantCode: http.StatusOK,
+			wantRange: fmt.Sprintf("from=%v to=%v", &from, &to)},
+		{name: "invalid from", query: "?from=yesterday", wantCode: http.StatusBadRequest},
+		{name: "from after to", query: "?from=2024-02-01T00:00:00Z&to=2024-01-01T00:00:00Z", wantCode: http.StatusBadRequest},
+		{name: "empty range", query: "?from=2024-01-01T00:00:00Z&to=2024-01-01T00:00:00Z", wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeRepository{}
+			handler := newTestHandler(t, repo, Options{})
+
+			rec := roleRequest(t, handler, http.MethodGet, "/admin/tasks/stats"+tt.query, "", uuid.New(), models.RoleAdmin)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+			if repo.statsRange != tt.wantRange {
+				t.Errorf("repository called with %s, want %s", repo.statsRange, tt.wantRange)
+			}
+			if tt.wantCode == http.StatusOK && strings.TrimSpace(rec.Body.String()) != "[]" {
+				t.Errorf("body = %q, want []", rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestLogoutRevokesToken(t *testing.T) {
+	handler := newTestHandler(t, &fakeRepository{}, Options{})
+	userID := uuid.New()
+	token := testToken(t, userID, models.RoleUser)
+
+	request := func(method, target, token string) int {
+		req := httptest.NewRequest(method, target, nil)
+		req.Header.Set("Authorization", token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := request(http.MethodPost, "/auth/logout", token); code != http.StatusNoContent {
+		t.Fatalf("logout status = %d, want %d", code, http.StatusNoContent)
+	}
+	if code := request(http.MethodGet, "/users/me/tasks", token); code != http.StatusUnauthorized {
+		t.Errorf("revoked token status = %d, want %d", code, http.StatusUnauthorized)
+	}
+	if code := request(http.MethodGet, "/users/me/tasks", testToken(t, userID, models.RoleUser)); code != http.StatusOK {
+		t.Errorf("new token status = %d, want %d", code, http.StatusOK)
+	}
+}
+
+func TestGetLeaderboardETag(t *testing.T) {
+	repo := &fakeRepository{leaderboard: []*models.User{{ID: uuid.New(), Username: "alice"}}}
+	handler := newTestHandler(t, repo, Options{})
+
+	request := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/users/leaderboard", nil)
+		req.Header.Set("Authorization", testToken(t, uuid.New(), models.RoleUser))
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := request("")
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" {
+		t.Fatalf("status = %d, ETag = %q, want 200 with an ETag", first.Code, etag)
+	}
+
+	for _, ifNoneMatch := range []string{etag, "W/" + etag, `"other", ` + etag, "*"} {
+		rec := request(ifNoneMatch)
+		if rec.Code != http.StatusNotModified {
+			t.Errorf("If-None-Match %s status = %d, want %d", ifNoneMatch, rec.Code, http.StatusNotModified)
+		}
+		if rec.Body.Len() != 0 {
+			t.Errorf("If-None-Match %s body = %q, want empty", ifNoneMatch, rec.Body.String())
+		}
+	}
+
+	if rec := request(`"stale"`); rec.Code != http.StatusOK {
+		t.Errorf("stale If-None-Match status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	// После изменения данных ETag меняется и старое значение не дает 304
+	repo.leaderboard = append(repo.leaderboard, &models.User{ID: uuid.New(), Username: "bob"})
+	rec := request(etag)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status after change = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if rec.Header().Get("ETag") == etag {
+		t.Error("ETag did not change with the leaderboard")
+	}
+}
+
+func TestRegisterValidation(t *testing.T) {
+	handler := newTestHandler(t, &fakeRepository{}, Options{})
+
+	tests := []struct {
+		name      string
+		body      string
+		wantCode  int
+		wantField string
+	}{
+		{name: "valid", body: `{"username":"alice","password":"password123"}`, wantCode: http.StatusCreated},
+		{name: "short username", body: `{"username":"al","password":"password123"}`, wantCode: http.StatusUnprocessableEntity, wantField: "username"},
+		{name: "short password", body: `{"username":"alice","password":"1234567"}`, wantCode: http.StatusUnprocessableEntity, wantField: "password"},
+		{name: "missing password", body: `{"username":"alice"}`, wantCode: http.StatusUnprocessableEntity, wantField: "password"},
+		{name: "with email", body: `{"username":"alice","password":"password123","email":"alice@example.com"}`, wantCode: http.StatusCreated},
+		{name: "invalid email", body: `{"username":"alice","password":"password123","email":"alice"}`, wantCode: http.StatusUnprocessableEntity, wantField: "email"},
+		// Зарезервированные имена сравниваются без учета регистра, но целиком
+		{name: "reserved username", body: `{"username":"ADMIN","password":"password123"}`, wantCode: http.StatusBadRequest, wantField: "username"},
+		{name: "name containing reserved", body: `{"username":"administrator","password":"password123"}`, wantCode: http.StatusCreated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/users/register", strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+			if tt.wantField == "" {
+				return
+			}
+			var got models.ErrorResponse
+			if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if got.Field != tt.wantField || got.Error == "" {
+				t.Errorf("response = %+v, want an error for field %q", got, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestRequestBodyValidation(t *testing.T) {
+	handler := newTestHandler(t, &fakeRepository{}, Options{})
+
+	tests := []struct {
+		name        string
+		method      string
+		target      string
+		body        string
+		wantCode    int
+		wantField   string
+		wantMessage string
+	}{
+		{name: "task without type", method: http.MethodPost, target: "/users/me/task/complete",
+			body: `{"points":5}`, wantCode: http.StatusUnprocessableEntity, wantField: "task_type", wantMessage: "is required"},
+		{name: "task with zero points", method: http.MethodPost, target: "/users/me/task/complete",
+			body: `{"task_type":"daily","points":0}`, wantCode: http.StatusUnprocessableEntity, wantField: "points", wantMessage: "must be at least 1"},
+		{name: "invalid referrer", method: http.MethodPost, target: "/users/me/referrer",
+			body: `{"referrer_id":"bob"}`, wantCode: http.StatusUnprocessableEntity, wantField: "referrer_id", wantMessage: "must be a valid UUID"},
+		{name: "transfer without amount", method: http.MethodPost, target: "/users/me/transfer",
+			body: `{"to":"` + uuid.NewString() + `"}`, wantCode: http.StatusBadRequest, wantField: "amount", wantMessage: "must be at least 1"},
+		{name: "password without current", method: http.MethodPost, target: "/users/me/password",
+			body: `{"new_password":"password123"}`, wantCode: http.StatusBadRequest, wantField: "current_password", wantMessage: "is required"},
+		{name: "profile without username", method: http.MethodPatch, target: "/users/me",
+			body: `{"username":""}`, wantCode: http.StatusBadRequest, wantField: "username", wantMessage: "is required"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := authRequest(t, handler, tt.method, tt.target, tt.body, uuid.New())
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+
+			var got models.ErrorResponse
+			if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if got.Field != tt.wantField || got.Error != tt.wantMessage {
+				t.Errorf("response = %+v, want field %q with error %q", got, tt.wantField, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestValidationReportsAllFields(t *testing.T) {
+	tests := []struct {
+		name       string
+		target     string
+		body       string
+		wantField  string
+		wantFields map[string]string
+	}{
+		{
+			name:      "register",
+			target:    "/users/register",
+			body:      `{"username":"al","password":"1234567","email":"alice"}`,
+			wantField: "username",
+			wantFields: map[string]string{
+				"username": "must be between 3 and 32 characters",
+				"password": "must be at least 8 characters",
+				"email":    "must be a valid email address",
+			},
+		},
+		{
+			name:      "complete task",
+			target:    "/users/me/task/complete",
+			body:      `{"points":0}`,
+			wantField: "task_type",
+			wantFields: map[string]string{
+				"task_type": "is required",
+				"points":    "must be at least 1",
+			},
+		},
+		{
+			name:      "referrer",
+			target:    "/users/me/referrer",
+			body:      `{"referrer_id":"bob","referral_code":"MFRGGZDFX"}`,
+			wantField: "referrer_id",
+			wantFields: map[string]string{
+				"referrer_id":   "must be a valid UUID",
+				"referral_code": "must be at most 8 characters",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeRepository{}
+			handler := newTestHandler(t, repo, Options{})
+
+			rec := authRequest(t, handler, http.MethodPost, tt.target, tt.body, uuid.New())
+			if rec.Code != http.StatusUnprocessableEntity {
+				t.Fatalf("status = %d, want %d", rec.Code, http.StatusUnprocessableEntity)
+			}
+			var got models.ErrorResponse
+			if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if got.Field != tt.wantField || got.Error != tt.wantFields[tt.wantField] {
+				t.Errorf("field = %q, error = %q, want the first violation of %q", got.Field, got.Error, tt.wantField)
+			}
+			if !maps.Equal(got.Fields, tt.wantFields) {
+				t.Errorf("fields = %v, want %v", got.Fields, tt.wantFields)
+			}
+			if len(repo.callers) != 0 {
+				t.Errorf("repository called for %v, want no calls for an invalid request", repo.callers)
+			}
+		})
+	}
+}
+
+func TestRequestBodyLimits(t *testing.T) {
+	handler := newTestHandler(t, &fakeRepository{}, Options{MaxBodyBytes: 64})
+	userID := uuid.New()
+
+	tests := []struct {
+		name     string
+		target   string
+		body     string
+		wantCode int
+	}{
+		{name: "within limit", target: "/users/task/complete", body: `{"task_type":"daily","points":5}`, wantCode: http.StatusOK},
+		{name: "too large", target: "/users/task/complete",
+			body: `{"task_type":"` + strings.Repeat("x", 64) + `","points":5}`, wantCode: http.StatusRequestEntityTooLarge},
+		{name: "unknown field", target: "/users/task/complete", body: `{"task_type":"daily","points":5,"bonus":100}`, wantCode: http.StatusBadRequest},
+		{name: "too large public route", target: "/users/register",
+			body: `{"username":"alice","password":"` + strings.Repeat("x", 64) + `"}`, wantCode: http.StatusRequestEntityTooLarge},
+		{name: "unknown field public route", target: "/users/register",
+			body: `{"username":"alice","password":"password123","admin":true}`, wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := authRequest(t, handler, http.MethodPost, tt.target, tt.body, userID)
+			if rec.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestDeleteMe(t *testing.T) {
+	userID := uuid.New()
+	repo := &fakeRepository{users: map[uuid.UUID]*models.User{userID: {ID: userID, Username: "alice"}}}
+	handler := newTestHandler(t, repo, Options{})
+	token := testToken(t, userID, models.RoleUser)
+
+	request := func(method string) int {
+		req := httptest.NewRequest(method, "/users/me", nil)
+		req.Header.Set("Authorization", token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := request(http.MethodPut); code != http.StatusMethodNotAllowed {
+		t.Errorf("PUT status = %d, want %d", code, http.StatusMethodNotAllowed)
+	}
+	if code := request(http.MethodDelete); code != http.StatusNoContent {
+		t.Fatalf("DELETE status = %d, want %d", code, http.StatusNoContent)
+	}
+	if len(repo.deleted) != 1 || repo.deleted[0] != userID {
+		t.Errorf("deleted users = %v, want [%s]", repo.deleted, userID)
+	}
+	// Токен удаленного пользователя отозван
+	if code := request(http.MethodGet); code != http.StatusUnauthorized {
+		t.Errorf("GET with the old token status = %d, want %d", code, http.StatusUnauthorized)
+	}
+
+	other := uuid.New()
+	if rec := authRequest(t, handler, http.MethodDelete, "/users/me", "", other); rec.Code != http.StatusNotFound {
+		t.Errorf("DELETE of unknown user status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestUpdateProfile(t *testing.T) {
+	userID := uuid.New()
+
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+	}{
+		{name: "success", body: `{"username":"carol"}`, wantCode: http.StatusOK},
+		{name: "current version", body: `{"username":"carol","version":2}`, wantCode: http.StatusOK},
+		{name: "stale version", body: `{"username":"carol","version":1}`, wantCode: http.StatusConflict},
+		{name: "negative version", body: `{"username":"carol","version":-1}`, wantCode: http.StatusBadRequest},
+		{name: "unchanged", body: `{"username":"alice"}`, wantCode: http.StatusBadRequest},
+		{name: "taken", body: `{"username":"bob"}`, wantCode: http.StatusConflict},
+		{name: "invalid username", body: `{"username":"a"}`, wantCode: http.StatusBadRequest},
+		{name: "reserved username", body: `{"username":"Admin"}`, wantCode: http.StatusBadRequest},
+		{name: "invalid body", body: `{"username":`, wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeRepository{
+				usernames: map[string]bool{"alice": true, "bob": true},
+				users:     map[uuid.UUID]*models.User{userID: {ID: userID, Username: "alice", Version: 2}},
+			}
+			handler := newTestHandler(t, repo, Options{})
+
+			rec := authRequest(t, handler, http.MethodPatch, "/users/me", tt.body, userID)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			var got models.User
+			if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if got.Username != "carol" || got.Version != 3 {
+				t.Errorf("user = %q version %d, want carol version 3", got.Username, got.Version)
+			}
+		})
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	userID := uuid.New()
+	hash, err := bcrypt.GenerateFromPassword([]byte("current-pass"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("GenerateFromPassword() error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		body      string
+		wantCode  int
+		wantField string
+	}{
+		{name: "success", body: `{"current_password":"current-pass","new_password":"brand-new-pass"}`, wantCode: http.StatusNoContent},
+		{name: "weak new password", body: `{"current_password":"current-pass","new_password":"short"}`, wantCode: http.StatusBadRequest, wantField: "new_password"},
+		{name: "wrong current password", body: `{"current_password":"wrong-pass","new_password":"brand-new-pass"}`, wantCode: http.StatusForbidden},
+		{name: "reused current password", body: `{"current_password":"current-pass","new_password":"current-pass"}`, wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeRepository{passwordHash: string(hash)}
+			handler := newTestHandler(t, repo, Options{})
+
+			rec := authRequest(t, handler, http.MethodPost, "/users/me/password", tt.body, userID)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+			if (tt.wantCode == http.StatusNoContent) != (repo.updatedHash != "") {
+				t.Errorf("password updated = %t, want %t", repo.updatedHash != "", tt.wantCode == http.StatusNoContent)
+			}
+			if tt.wantField == "" {
+				return
+			}
+			var got models.ErrorResponse
+			if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if got.Field != tt.wantField {
+				t.Errorf("response = %+v, want an error for field %q", got, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestRegister(t *testing.T) {
+	handler := newTestHandler(t, &fakeRepository{usernames: map[string]bool{"bob": true}, emails: map[string]bool{"bob@example.com": true}}, Options{})
+
+	register := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/users/register", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := register(`{"username":"alice","password":"password123"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusCreated)
+	}
+	var got models.RegisterResponse
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if got.User == nil || got.User.Username != "alice" {
+		t.Fatalf("user = %+v, want alice", got.User)
+	}
+	if want := "/api/v1/users/" + got.User.ID.String(); rec.Header().Get("Location") != want {
+		t.Errorf("Location = %q, want %q", rec.Header().Get("Location"), want)
+	}
+	if got.Token == "" || got.Token != rec.Header().Get("Authorization") {
+		t.Errorf("token = %q, want it to match the Authorization header %q", got.Token, rec.Header().Get("Authorization"))
+	}
+
+	if rec := register(`{"username":"bob","password":"password123"}`); rec.Code != http.StatusConflict {
+		t.Errorf("taken username status = %d, want %d", rec.Code, http.StatusConflict)
+	}
+	if rec := register(`{"username":"carol","password":"password123","email":"bob@example.com"}`); rec.Code != http.StatusConflict {
+		t.Errorf("taken email status = %d, want %d", rec.Code, http.StatusConflict)
+	}
+	if rec := register(`{"username":`); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid body status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestRevertTask(t *testing.T) {
+	taskID := uuid.New()
+	target := "/admin/tasks/" + taskID.String() + "/revert"
+
+	if rec := authRequest(t, newTestHandler(t, &fakeRepository{}, Options{}), http.MethodPost, target, "", uuid.New()); rec.Code != http.StatusForbidden {
+		t.Errorf("user status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+
+	tests := []struct {
+		name      string
+		method    string
+		target    string
+		revertErr error
+		wantCode  int
+	}{
+		{name: "success", method: http.MethodPost, target: target, wantCode: http.StatusOK},
+		{name: "not found", method: http.MethodPost, target: target, revertErr: models.ErrTaskNotFound, wantCode: http.StatusNotFound},
+		{name: "already reverted", method: http.MethodPost, target: target, revertErr: models.ErrTaskAlreadyReverted, wantCode: http.StatusConflict},
+		{name: "invalid id", method: http.MethodPost, target: "/admin/tasks/not-a-uuid/revert", wantCode: http.StatusBadRequest},
+		{name: "wrong method", method: http.MethodGet, target: target, wantCode: http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeRepository{revertErr: tt.revertErr}
+			handler := newTestHandler(t, repo, Options{})
+			adminID := uuid.New()
+
+			rec := roleRequest(t, handler, tt.method, tt.target, "", adminID, models.RoleAdmin)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+			if tt.wantCode != http.StatusOK {
+				if len(repo.audit) != 0 {
+					t.Errorf("audit entries = %d, want none for a failed revert", len(repo.audit))
+				}
+				return
+			}
+			var got models.Task
+			if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if got.ID != taskID || got.DeletedAt == nil {
+				t.Errorf("task = %+v, want %s marked deleted", got, taskID)
+			}
+			if len(repo.audit) != 1 || repo.audit[0].ActorID != adminID || repo.audit[0].Action != models.AuditTaskReverted {
+				t.Errorf("audit = %+v, want one %s entry by %s", repo.audit, models.AuditTaskReverted, adminID)
+			}
+		})
+	}
+}
+
+func TestGetPeriodLeaderboard(t *testing.T) {
+	entries := []*models.PeriodLeaderboardEntry{
+		{UserID: uuid.New(), Username: "alice", Points: 30, Rank: 1},
+		{UserID: uuid.New(), Username: "bob", Points: 30, Rank: 1},
+		{UserID: uuid.New(), Username: "carol", Points: 10, Rank: 3},
+	}
+	handler := newTestHandler(t, &fakeRepository{periodLeaderboard: entries}, Options{})
+
+	for _, period := range []string{models.PeriodWeekly, models.PeriodMonthly} {
+		rec := authRequest(t, handler, http.MethodGet, "/users/leaderboard?period="+period+"&limit=2&offset=1", "", uuid.New())
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s status = %d, want %d", period, rec.Code, http.StatusOK)
+		}
+		if got := rec.Header().Get("X-Total-Count"); got != "3" {
+			t.Errorf("%s X-Total-Count = %q, want %q", period, got, "3")
+		}
+		var got []models.PeriodLeaderboardEntry
+		if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		if len(got) != 2 || got[0].Username != "bob" || got[1].Rank != 3 {
+			t.Errorf("%s leaderboard = %+v, want bob and carol", period, got)
+		}
+	}
+
+	if rec := authRequest(t, handler, http.MethodGet, "/users/leaderboard?period=yearly", "", uuid.New()); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid period status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestGetUserStatusUnknownUser(t *testing.T) {
+	handler := newTestHandler(t, &fakeRepository{}, Options{})
+
+	if rec := authRequest(t, handler, http.MethodGet, "/users/me", "", uuid.New()); rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	handler := newTestHandler(t, &fakeRepository{}, Options{})
+
+	tests := []struct {
+		name      string
+		method    string
+		target    string
+		wantAllow string
+	}{
+		{name: "public route", method: http.MethodGet, target: "/users/register", wantAllow: "POST"},
+		{name: "protected route", method: http.MethodPost, target: "/users/leaderboard", wantAllow: "GET, HEAD"},
+		{name: "several methods", method: http.MethodPut, target: "/users/me", wantAllow: "DELETE, GET, HEAD, PATCH"},
+		{name: "path with wildcard", method: http.MethodGet, target: "/admin/tasks/" + uuid.NewString() + "/revert", wantAllow: "POST"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, nil))
+
+			if rec.Code != http.StatusMethodNotAllowed {
+				t.Fatalf("status = %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+			}
+			// Allow содержит и OPTIONS; порядок методов задает ServeMux
+			allow := strings.Split(rec.Header().Get("Allow"), ", ")
+			for _, method := range strings.Split(tt.wantAllow, ", ") {
+				if !slices.Contains(allow, method) {
+					t.Errorf("Allow = %q, want it to contain %s", rec.Header().Get("Allow"), method)
+				}
+			}
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users/unknown", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unknown path status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestOptionsListsAllowedMethods(t *testing.T) {
+	handler := newTestHandler(t, &fakeRepository{}, Options{})
+
+	// OPTIONS отвечает без токена, в том числе для защищенных маршрутов
+	for target, want := range map[string]string{
+		"/users/register":    "POST, OPTIONS",
+		"/users/leaderboard": "GET, HEAD, OPTIONS",
+		"/users/me":          "GET, PATCH, DELETE, HEAD, OPTIONS",
+	} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodOptions, target, nil))
+
+		if rec.Code != http.StatusNoContent {
+			t.Errorf("%s status = %d, want %d", target, rec.Code, http.StatusNoContent)
+		}
+		if got := rec.Header().Get("Allow"); got != want {
+			t.Errorf("%s Allow = %q, want %q", target, got, want)
+		}
+	}
+}
+
+func TestLogin(t *testing.T) {
+	userID := uuid.New()
+	hash, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("GenerateFromPassword() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+	}{
+		{name: "success", body: `{"username":"alice","password":"password123"}`, wantCode: http.StatusOK},
+		{name: "wrong password", body: `{"username":"alice","password":"wrong-password"}`, wantCode: http.StatusUnauthorized},
+		{name: "unknown user", body: `{"username":"bob","password":"password123"}`, wantCode: http.StatusUnauthorized},
+		{name: "missing password", body: `{"username":"alice"}`, wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeRepository{
+				users:        map[uuid.UUID]*models.User{userID: {ID: userID, Username: "alice", StreakDays: 1, Points: 100}},
+				passwordHash: string(hash),
+				streakBonus:  5,
+			}
+			handler := newTestHandler(t, repo, Options{})
+
+			req := httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			var got models.LoginResponse
+			if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if got.StreakDays != 2 || got.StreakBonus != 5 || got.User.Points != 105 {
+				t.Errorf("response = %+v, want streak 2, bonus 5 and 105 points", got)
+			}
+			if got.Token == "" || got.Token != rec.Header().Get("Authorization") {
+				t.Errorf("token = %q, want it to match the Authorization header", got.Token)
+			}
+			// Выданный токен принимается защищенными маршрутами
+			req = httptest.NewRequest(http.MethodGet, "/users/me", nil)
+			req.Header.Set("Authorization", got.Token)
+			rec = httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Errorf("GET /users/me with login token status = %d, want %d", rec.Code, http.StatusOK)
+			}
+		})
+	}
+}
+
+func TestImportUsers(t *testing.T) {
+	body := `[{"username":"alice","password":"password123","points":5},{"username":"bob","password":"password456"}]`
+
+	if rec := authRequest(t, newTestHandler(t, &fakeRepository{}, Options{}), http.MethodPost, "/admin/users/import", body, uuid.New()); rec.Code != http.StatusForbidden {
+		t.Errorf("user status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+
+	tests := []struct {
+		name      string
+		body      string
+		wantCode  int
+		want      models.ImportUsersResponse
+		wantField string
+	}{
+		{name: "taken username is skipped", body: body, wantCode: http.StatusOK, want: models.ImportUsersResponse{Inserted: 1, Skipped: 1}},
+		{name: "empty batch", body: `[]`, wantCode: http.StatusBadRequest},
+		{name: "not an array", body: `{"username":"alice"}`, wantCode: http.StatusBadRequest},
+		{
+			name:      "invalid user",
+			body:      `[{"username":"carol","password":"password123"},{"username":"x","password":"password123"}]`,
+			wantCode:  http.StatusBadRequest,
+			wantField: "[1].username",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := newTestHandler(t, &fakeRepository{usernames: map[string]bool{"alice": true}}, Options{})
+
+			rec := roleRequest(t, handler, http.MethodPost, "/admin/users/import", tt.body, uuid.New(), models.RoleAdmin)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+			switch {
+			case tt.wantCode == http.StatusOK:
+				var got models.ImportUsersResponse
+				if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+					t.Fatalf("decode response: %v", err)
+				}
+				if got != tt.want {
+					t.Errorf("response = %+v, want %+v", got, tt.want)
+				}
+			case tt.wantField != "":
+				var got models.ErrorResponse
+				if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+					t.Fatalf("decode response: %v", err)
+				}
+				if got.Field != tt.wantField {
+					t.Errorf("response = %+v, want an error for field %q", got, tt.wantField)
+				}
+			}
+		})
+	}
+}
+
+func TestImportTasks(t *testing.T) {
+	userID := uuid.NewString()
+	body := strings.Join([]string{
+		`{"user_id":"` + userID + `","task_type":"daily","points":10}`,
+		`{"user_id":"` + userID + `","task_type":"daily","points":"ten"}`,
+		`{"user_id":"` + userID + `","task_type":"daily","points":5,"bonus":true}`,
+		`{"task_type":"daily","points":5}`,
+		`{"user_id":"` + userID + `","task_type":"weekly","points":20}`,
+	}, "\n") + "\n"
+
+	if rec := authRequest(t, newTestHandler(t, &fakeRepository{}, Options{}), http.MethodPost, "/admin/tasks/import", body, uuid.New()); rec.Code != http.StatusForbidden {
+		t.Errorf("user status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+
+	// importLines отправляет body от администратора и разбирает строки ответа: результаты записей и итог
+	importLines := func(t *testing.T, body string) ([]models.ImportTaskResult, models.ImportTasksSummary) {
+		t.Helper()
+
+		handler := newTestHandler(t, &fakeRepository{}, Options{RequireJSON: true})
+		req := httptest.NewRequest(http.MethodPost, "/admin/tasks/import", strings.NewReader(body))
+		req.Header.Set("Authorization", testToken(t, uuid.New(), models.RoleAdmin))
+		req.Header.Set("Content-Type", "application/x-ndjson")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d; body: %s", rec.Code, http.StatusOK, rec.Body.String())
+		}
+		if got := rec.Header().Get("Content-Type"); got != "application/x-ndjson" {
+			t.Errorf("Content-Type = %q, want application/x-ndjson", got)
+		}
+
+		lines := strings.Split(strings.TrimSuffix(rec.Body.String(), "\n"), "\n")
+		results := make([]models.ImportTaskResult, len(lines)-1)
+		for i, line := range lines[:len(lines)-1] {
+			if err := json.Unmarshal([]byte(line), &results[i]); err != nil {
+				t.Fatalf("decode result %q: %v", line, err)
+			}
+		}
+		var summary models.ImportTasksSummary
+		if err := json.Unmarshal([]byte(lines[len(lines)-1]), &summary); err != nil {
+			t.Fatalf("decode summary %q: %v", lines[len(lines)-1], err)
+		}
+		return results, summary
+	}
+
+	t.Run("mixed records", func(t *testing.T) {
+		results, summary := importLines(t, body)
+		if summary != (models.ImportTasksSummary{Imported: 2, Failed: 3}) {
+			t.Errorf("summary = %+v, want 2 imported and 3 failed", summary)
+		}
+		wantFailed := map[int]string{2: "points", 3: "bonus", 4: "user_id"}
+		if len(results) != 5 {
+			t.Fatalf("results = %+v, want one per line", results)
+		}
+		for i, result := range results {
+			field, failed := wantFailed[result.Line]
+			if result.Line != i+1 {
+				t.Errorf("result %d line = %d, want %d", i, result.Line, i+1)
+			}
+			if !failed {
+				if result.Status != models.ImportStatusImported || result.TaskID == nil {
+					t.Errorf("line %d = %+v, want imported with a task id", result.Line, result)
+				}
+				continue
+			}
+			if result.Status != models.ImportStatusFailed || result.Fields[field] == "" {
+				t.Errorf("line %d = %+v, want failed on field %q", result.Line, result, field)
+			}
+		}
+	})
+
+	t.Run("malformed line stops the import", func(t *testing.T) {
+		results, summary := importLines(t, `{"user_id":"`+userID+`","task_type":"daily","points":10}`+"\nnot json\n"+`{"user_id":"`+userID+`","task_type":"daily","points":10}`+"\n")
+		if summary != (models.ImportTasksSummary{Imported: 1, Failed: 1}) || len(results) != 2 {
+			t.Fatalf("results = %+v, summary = %+v, want the import stopped at line 2", results, summary)
+		}
+		if results[1].Status != models.ImportStatusFailed || results[1].Error == "" {
+			t.Errorf("line 2 = %+v, want failed with an error", results[1])
+		}
+	})
+}
+
+func TestLookupUser(t *testing.T) {
+	alice := &models.User{ID: uuid.New(), Username: "alice", Password: "hash", Points: 42}
+	handler := newTestHandler(t, &fakeRepository{users: map[uuid.UUID]*models.User{alice.ID: alice}}, Options{})
+
+	rec := authRequest(t, handler, http.MethodGet, "/users/lookup?username=alice", "", uuid.New())
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	var got map[string]any
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	want := map[string]any{"id": alice.ID.String(), "username": "alice", "points": float64(42)}
+	if !maps.Equal(got, want) {
+		t.Errorf("response = %v, want %v", got, want)
+	}
+
+	for _, tt := range []struct {
+		target   string
+		wantCode int
+	}{
+		{target: "/users/lookup?username=bob", wantCode: http.StatusNotFound},
+		{target: "/users/lookup", wantCode: http.StatusBadRequest},
+	} {
+		if rec := authRequest(t, handler, http.MethodGet, tt.target, "", uuid.New()); rec.Code != tt.wantCode {
+			t.Errorf("GET %s status = %d, want %d", tt.target, rec.Code, tt.wantCode)
+		}
+	}
+}
+
+func TestAddReferrerByCode(t *testing.T) {
+	userID, referrerID := uuid.New(), uuid.New()
+
+	tests := []struct {
+		name         string
+		body         string
+		wantCode     int
+		wantReferrer uuid.UUID
+		wantField    string
+	}{
+		{name: "referral code", body: `{"referral_code":"MFRGGZDF"}`, wantCode: http.StatusOK, wantReferrer: referrerID},
+		{name: "referrer id", body: `{"referrer_id":"` + referrerID.String() + `"}`, wantCode: http.StatusOK, wantReferrer: referrerID},
+		{name: "unknown code", body: `{"referral_code":"ZZZZZZZZ"}`, wantCode: http.StatusNotFound},
+		{name: "code and id", body: `{"referrer_id":"` + referrerID.String() + `","referral_code":"MFRGGZDF"}`,
+			wantCode: http.StatusUnprocessableEntity, wantField: "referral_code"},
+		{name: "neither", body: `{}`, wantCode: http.StatusUnprocessableEntity, wantField: "referrer_id"},
+		{name: "code too long", body: `{"referral_code":"MFRGGZDFX"}`, wantCode: http.StatusUnprocessableEntity, wantField: "referral_code"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeRepository{
+				users:         map[uuid.UUID]*models.User{userID: {ID: userID, Username: "bob"}},
+				referralCodes: map[string]uuid.UUID{"MFRGGZDF": referrerID},
+			}
+			handler := newTestHandler(t, repo, Options{})
+
+			rec := authRequest(t, handler, http.MethodPost, "/users/me/referrer", tt.body, userID)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+
+			switch {
+			case tt.wantCode == http.StatusOK:
+				var got models.User
+				if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+					t.Fatalf("decode response: %v", err)
+				}
+				if got.ReferrerID == nil || *got.ReferrerID != tt.wantReferrer {
+					t.Errorf("referrer_id = %v, want %s", got.ReferrerID, tt.wantReferrer)
+				}
+			case tt.wantField != "":
+				var got models.ErrorResponse
+				if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+					t.Fatalf("decode response: %v", err)
+				}
+				if got.Field != tt.wantField {
+					t.Errorf("response = %+v, want an error for field %q", got, tt.wantField)
+				}
+			default:
+				if len(repo.callers) != 0 {
+					t.Errorf("AddReferrer called for %v, want no call for an unknown code", repo.callers)
+				}
+			}
+		})
+	}
+}
+
+func TestRotateReferralCode(t *testing.T) {
+	aliceID, bobID := uuid.New(), uuid.New()
+	repo := &fakeRepository{
+		users: map[uuid.UUID]*models.User{
+			aliceID: {ID: aliceID, Username: "alice"},
+			bobID:   {ID: bobID, Username: "bob"},
+		},
+		referralCodes: map[string]uuid.UUID{"MFRGGZDF": aliceID},
+	}
+	handler := newTestHandler(t, repo, Options{})
+
+	rec := authRequest(t, handler, http.MethodPost, "/users/me/referral-code/rotate", "", aliceID)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("rotate status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	var got models.ReferralCodeResponse
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if got.ReferralCode == "" || got.ReferralCode == "MFRGGZDF" {
+		t.Fatalf("referral_code = %q, want a new code", got.ReferralCode)
+	}
+
+	// Прежний код больше не разрешается, новый указывает на alice
+	if rec := authRequest(t, handler, http.MethodPost, "/users/me/referrer", `{"referral_code":"MFRGGZDF"}`, bobID); rec.Code != http.StatusNotFound {
+		t.Errorf("old code: status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+	rec = authRequest(t, handler, http.MethodPost, "/users/me/referrer", `{"referral_code":"`+got.ReferralCode+`"}`, bobID)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("new code: status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	var bob models.User
+	if err := json.NewDecoder(rec.Body).Decode(&bob); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if bob.ReferrerID == nil || *bob.ReferrerID != aliceID {
+		t.Errorf("new code: referrer_id = %v, want %s", bob.ReferrerID, aliceID)
+	}
+
+	if rec := authRequest(t, handler, http.MethodPost, "/users/me/referral-code/rotate", "", uuid.New()); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown user: status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+	if rec := authRequest(t, handler, http.MethodGet, "/users/me/referral-code/rotate", "", aliceID); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET status = %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestAddReferrerRepeat(t *testing.T) {
+	userID, referrerID := uuid.New(), uuid.New()
+	repo := &fakeRepository{users: map[uuid.UUID]*models.User{
+		userID: {ID: userID, Username: "bob", ReferrerID: &referrerID},
+	}}
+	handler := newTestHandler(t, repo, Options{})
+
+	rec := authRequest(t, handler, http.MethodPost, "/users/me/referrer", `{"referrer_id":"`+referrerID.String()+`"}`, userID)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("same referrer: status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	var got models.User
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if got.ID != userID || got.ReferrerID == nil || *got.ReferrerID != referrerID {
+		t.Errorf("same referrer: response = %+v, want the current user with referrer %s", got, referrerID)
+	}
+
+	rec = authRequest(t, handler, http.MethodPost, "/users/me/referrer", `{"referrer_id":"`+uuid.NewString()+`"}`, userID)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("other referrer: status = %d, want %d", rec.Code, http.StatusConflict)
+	}
+}
+
+func TestRemoveReferrer(t *testing.T) {
+	userID, referrerID := uuid.New(), uuid.New()
+	repo := &fakeRepository{users: map[uuid.UUID]*models.User{
+		userID:     {ID: userID, Username: "carol", ReferrerID: &referrerID},
+		referrerID: {ID: referrerID, Username: "bob", Points: 4},
+	}}
+	handler := newTestHandler(t, repo, Options{})
+
+	rec := authRequest(t, handler, http.MethodDelete, "/users/me/referrer", "", userID)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d; body: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	var got models.User
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if got.ID != userID || got.ReferrerID != nil {
+		t.Errorf("response = %+v, want the current user without a referrer", got)
+	}
+	if points := repo.users[referrerID].Points; points != 0 {
+		t.Errorf("referrer points = %d, want 0 after the bonus is reversed", points)
+	}
+
+	rec = authRequest(t, handler, http.MethodDelete, "/users/me/referrer", "", userID)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("no referrer: status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+
+	// Удаленного реферера нельзя указать снова
+	rec = authRequest(t, handler, http.MethodPost, "/users/me/referrer", `{"referrer_id":"`+referrerID.String()+`"}`, userID)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("relink: status = %d, want %d; body: %s", rec.Code, http.StatusConflict, rec.Body.String())
+	}
+	var errResp models.ErrorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&errResp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if errResp.Code != models.CodeReferrerRemoved || errResp.Field != "referrer_id" {
+		t.Errorf("relink response = %+v, want %s for field referrer_id", errResp, models.CodeReferrerRemoved)
+	}
+	rec = authRequest(t, handler, http.MethodPut, "/users/me/referrer", "", userID)
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("PUT: status = %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+// fakeLeaderboardView материализованное представление с фиксированным содержимым
+type fakeLeaderboardView struct {
+	users []*models.User
+}
+
+func (v *fakeLeaderboardView) GetLeaderboardView(context.Context, models.LeaderboardFilter, int, int) ([]*models.User, int, error) {
+	return v.users, len(v.users), nil
+}
+
+func (v *fakeLeaderboardView) RefreshLeaderboardView(context.Context) error {
+	return nil
+}
+
+func TestGetLeaderboardView(t *testing.T) {
+	repo := &fakeRepository{leaderboard: []*models.User{{ID: uuid.New(), Username: "live"}}}
+	view := &fakeLeaderboardView{users: []*models.User{{ID: uuid.New(), Username: "view"}}}
+
+	// get возвращает имена пользователей страницы и заголовок времени пересчета
+	get := func(t *testing.T, handler http.Handler) (string, string) {
+		t.Helper()
+
+		rec := authRequest(t, handler, http.MethodGet, "/users/leaderboard", "", uuid.New())
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+		}
+		var users []models.User
+		if err := json.NewDecoder(rec.Body).Decode(&users); err != nil || len(users) != 1 {
+			t.Fatalf("decode response: %d users, %v", len(users), err)
+		}
+		return users[0].Username, rec.Header().Get(handlers.LeaderboardRefreshedAtHeader)
+	}
+	// refresh выполняет первый пересчет представления и ждет его завершения
+	refresh := func(t *testing.T, userService *service.UserService) {
+		t.Helper()
+
+		updates, unsubscribe, err := userService.SubscribeLeaderboard()
+		if err != nil {
+			t.Fatalf("SubscribeLeaderboard() error = %v", err)
+		}
+		defer unsubscribe()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			userService.RunLeaderboardViewRefresh(ctx)
+		}()
+		t.Cleanup(func() {
+			cancel()
+			<-done
+		})
+		select {
+		case <-updates:
+		case <-time.After(time.Second):
+			t.Fatal("leaderboard view was not refreshed")
+		}
+	}
+
+	t.Run("without view", func(t *testing.T) {
+		handler := newTestHandler(t, repo, Options{})
+
+		if source, header := get(t, handler); source != "live" || header != "" {
+			t.Errorf("leaderboard = %s with %s %q, want live without the header", source, handlers.LeaderboardRefreshedAtHeader, header)
+		}
+	})
+
+	t.Run("fresh view", func(t *testing.T) {
+		handler, userService := newTestAPI(t, repo, Options{}, service.Options{LeaderboardView: view})
+		if source, header := get(t, handler); source != "live" || header != "" {
+			t.Errorf("before refresh: leaderboard = %s with header %q, want live without the header", source, header)
+		}
+
+		before := time.Now().Truncate(time.Millisecond)
+		refresh(t, userService)
+		source, header := get(t, handler)
+		if source != "view" {
+			t.Errorf("leaderboard = %s, want the view", source)
+		}
+		refreshedAt, err := time.Parse(models.TimeLayout, header)
+		if err != nil || refreshedAt.Before(before) || refreshedAt.After(time.Now()) || !strings.HasSuffix(header, "Z") {
+			t.Errorf("%s = %q (%v), want the UTC refresh time after %s", handlers.LeaderboardRefreshedAtHeader, header, err, before)
+		}
+	})
+
+	t.Run("stale view", func(t *testing.T) {
+		handler, userService := newTestAPI(t, repo, Options{}, service.Options{
+			LeaderboardView:                view,
+			LeaderboardViewRefreshInterval: time.Hour,
+			LeaderboardViewMaxStaleness:    time.Millisecond,
+		})
+
+		refresh(t, userService)
+		time.Sleep(5 * time.Millisecond)
+		if source, header := get(t, handler); source != "live" || header != "" {
+			t.Errorf("leaderboard = %s with header %q, want live without the header", source, header)
+		}
+	})
+}
+
+func TestGetLeaderboardEnvelope(t *testing.T) {
+	repo := &fakeRepository{}
+	for _, username := range []string{"eve", "dave", "carol", "bob", "alice"} {
+		repo.leaderboard = append(repo.leaderboard, &models.User{ID: uuid.New(), Username: username})
+	}
+	handler := newTestHandler(t, repo, Options{})
+
+	tests := []struct {
+		query string
+		want  []string
+		// wantPage ожидаемые метаданные страницы; Items не сравнивается
+		wantPage models.PagedResponse[models.User]
+	}{
+		{query: "?envelope=true&limit=2", want: []string{"eve", "dave"},
+			wantPage: models.PagedResponse[models.User]{Total: 5, Limit: 2, Offset: 0, HasMore: true}},
+		{query: "?envelope=1&limit=2&offset=2", want: []string{"carol", "bob"},
+			wantPage: models.PagedResponse[models.User]{Total: 5, Limit: 2, Offset: 2, HasMore: true}},
+		{query: "?envelope=true&limit=2&offset=4", want: []string{"alice"},
+			wantPage: models.PagedResponse[models.User]{Total: 5, Limit: 2, Offset: 4, HasMore: false}},
+		{query: "?envelope=true&offset=10", want: []string{},
+			wantPage: models.PagedResponse[models.User]{Total: 5, Limit: 10, Offset: 10, HasMore: false}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			rec := authRequest(t, handler, http.MethodGet, "/users/leaderboard"+tt.query, "", uuid.New())
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+			}
+
+			var page models.PagedResponse[models.User]
+			if err := json.NewDecoder(rec.Body).Decode(&page); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			got := []string{}
+			for _, user := range page.Items {
+				got = append(got, user.Username)
+			}
+			if !slices.Equal(got, tt.want) || page.Items == nil {
+				t.Errorf("items = %v, want %v", got, tt.want)
+			}
+			page.Items = nil
+			if !reflect.DeepEqual(page, tt.wantPage) {
+				t.Errorf("page = %+v, want %+v", page, tt.wantPage)
+			}
+		})
+	}
+
+	// Без параметра и с envelope=false список возвращается массивом
+	for _, query := range []string{"", "?envelope=false"} {
+		rec := authRequest(t, handler, http.MethodGet, "/users/leaderboard"+query, "", uuid.New())
+		var users []models.User
+		if err := json.NewDecoder(rec.Body).Decode(&users); err != nil || len(users) != 5 {
+			t.Errorf("GET /users/leaderboard%s = %d users (%v), want a bare array of 5", query, len(users), err)
+		}
+	}
+
+	if rec := authRequest(t, handler, http.MethodGet, "/users/leaderboard?envelope=maybe", "", uuid.New()); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid envelope status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestGetPeriodLeaderboardEnvelope(t *testing.T) {
+	repo := &fakeRepository{periodLeaderboard: []*models.PeriodLeaderboardEntry{
+		{Username: "alice", Points: 30, Rank: 1},
+		{Username: "bob", Points: 20, Rank: 2},
+	}}
+	handler := newTestHandler(t, repo, Options{})
+
+	rec := authRequest(t, handler, http.MethodGet, "/users/leaderboard?period=weekly&envelope=true&limit=1", "", uuid.New())
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	var page models.PagedResponse[models.PeriodLeaderboardEntry]
+	if err := json.NewDecoder(rec.Body).Decode(&page); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(page.Items) != 1 || page.Items[0].Username != "alice" || page.Total != 2 || page.Limit != 1 || !page.HasMore {
+		t.Errorf("page = %+v, want alice of 2 with has_more", page)
+	}
+}
+
+func TestGetReferrerChain(t *testing.T) {
+	chain := []*models.ReferrerChainEntry{
+		{ID: uuid.New(), Username: "carol", Level: 1},
+		{ID: uuid.New(), Username: "bob", Level: 2},
+		{ID: uuid.New(), Username: "alice", Level: 3},
+		{ID: uuid.New(), Username: "root", Level: 4},
+	}
+
+	tests := []struct {
+		name      string
+		chain     []*models.ReferrerChainEntry
+		query     string
+		wantCode  int
+		wantDepth int
+		want      []string
+	}{
+		{name: "default depth is the maximum", chain: chain, wantCode: http.StatusOK,
+			wantDepth: testMaxReferrerChainDepth, want: []string{"carol", "bob", "alice"}},
+		{name: "explicit depth", chain: chain, query: "?depth=2", wantCode: http.StatusOK,
+			wantDepth: 2, want: []string{"carol", "bob"}},
+		{name: "depth above maximum is clamped", chain: chain, query: "?depth=10", wantCode: http.StatusOK,
+			wantDepth: testMaxReferrerChainDepth, want: []string{"carol", "bob", "alice"}},
+		{name: "no referrer", wantCode: http.StatusOK, wantDepth: testMaxReferrerChainDepth, want: []string{}},
+		{name: "zero depth", chain: chain, query: "?depth=0", wantCode: http.StatusBadRequest},
+		{name: "non-numeric depth", chain: chain, query: "?depth=abc", wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeRepository{referrerChain: tt.chain}
+			handler := newTestHandler(t, repo, Options{})
+
+			rec := authRequest(t, handler, http.MethodGet, "/users/me/referrer-chain"+tt.query, "", uuid.New())
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			if repo.chainDepth != tt.wantDepth {
+				t.Errorf("repository depth = %d, want %d", repo.chainDepth, tt.wantDepth)
+			}
+
+			var entries []models.ReferrerChainEntry
+			if err := json.NewDecoder(rec.Body).Decode(&entries); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			got := []string{}
+			for _, entry := range entries {
+				got = append(got, entry.Username)
+			}
+			if entries == nil || !slices.Equal(got, tt.want) {
+				t.Errorf("chain = %v (nil: %t), want %v", got, entries == nil, tt.want)
+			}
+		})
+	}
+}
+
+func TestVersion(t *testing.T) {
+	handler := newTestHandler(t, &fakeRepository{}, Options{})
+
+	getVersion := func() buildinfo.Info {
+		t.Helper()
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/version", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+		}
+		var got buildinfo.Info
+		if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		return got
+	}
+
+	// Сборка без -ldflags
+	if got, want := getVersion(), (buildinfo.Info{Version: "dev", Commit: "dev", BuildDate: "dev"}); got != want {
+		t.Errorf("default version = %+v, want %+v", got, want)
+	}
+
+	// Значения, заданные через -X, меняют переменные пакета buildinfo
+	version, commit, buildDate := buildinfo.Version, buildinfo.Commit, buildinfo.BuildDate
+	t.Cleanup(func() { buildinfo.Version, buildinfo.Commit, buildinfo.BuildDate = version, commit, buildDate })
+	buildinfo.Version, buildinfo.Commit, buildinfo.BuildDate = "1.2.0", "ed5a1f7", "2026-10-14T12:00:00Z"
+
+	if got, want := getVersion(), (buildinfo.Info{Version: "1.2.0", Commit: "ed5a1f7", BuildDate: "2026-10-14T12:00:00Z"}); got != want {
+		t.Errorf("injected version = %+v, want %+v", got, want)
+	}
+}
+
+func TestAdjustPoints(t *testing.T) {
+	adminID, userID := uuid.New(), uuid.New()
+	target := "/admin/users/" + userID.String() + "/points"
+
+	if rec := authRequest(t, newTestHandler(t, &fakeRepository{}, Options{}), http.MethodPost, target, `{"delta":5,"reason":"bonus"}`, uuid.New()); rec.Code != http.StatusForbidden {
+		t.Errorf("user status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+
+	tests := []struct {
+		name      string
+		target    string
+		body      string
+		wantCode  int
+		wantField string
+		// want ожидаемая корректировка; сравниваются изменение, фактическое изменение и баланс
+		want models.PointAdjustment
+	}{
+		{name: "grant", target: target, body: `{"delta":50,"reason":"contest prize"}`, wantCode: http.StatusOK,
+			want: models.PointAdjustment{Delta: 50, AppliedDelta: 50, Balance: 80}},
+		{name: "deduct", target: target, body: `{"delta":-20,"reason":"duplicate task"}`, wantCode: http.StatusOK,
+			want: models.PointAdjustment{Delta: -20, AppliedDelta: -20, Balance: 10}},
+		{name: "deduct below zero is floored", target: target, body: `{"delta":-50,"reason":"fraud"}`, wantCode: http.StatusOK,
+			want: models.PointAdjustment{Delta: -50, AppliedDelta: -30, Balance: 0}},
+		{name: "zero delta", target: target, body: `{"delta":0,"reason":"noop"}`, wantCode: http.StatusBadRequest, wantField: "delta"},
+		{name: "missing reason", target: target, body: `{"delta":5}`, wantCode: http.StatusBadRequest, wantField: "reason"},
+		{name: "unknown user", target: "/admin/users/" + uuid.NewString() + "/points", body: `{"delta":5,"reason":"bonus"}`, wantCode: http.StatusNotFound},
+		{name: "invalid id", target: "/admin/users/bob/points", body: `{"delta":5,"reason":"bonus"}`, wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeRepository{users: map[uuid.UUID]*models.User{userID: {ID: userID, Username: "alice", Points: 30}}}
+			handler := newTestHandler(t, repo, Options{})
+
+			rec := roleRequest(t, handler, http.MethodPost, tt.target, tt.body, adminID, models.RoleAdmin)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+
+			switch {
+			case tt.wantCode == http.StatusOK:
+				var got models.PointAdjustment
+				if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+					t.Fatalf("decode response: %v", err)
+				}
+				if got.Delta != tt.want.Delta || got.AppliedDelta != tt.want.AppliedDelta || got.Balance != tt.want.Balance {
+					t.Errorf("adjustment = %+v, want delta %d, applied %d, balance %d", got, tt.want.Delta, tt.want.AppliedDelta, tt.want.Balance)
+				}
+				if got.AdminID != adminID || got.UserID != userID {
+					t.Errorf("adjustment admin %s, user %s, want %s and %s", got.AdminID, got.UserID, adminID, userID)
+				}
+			case tt.wantField != "":
+				var got models.ErrorResponse
+				if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+					t.Fatalf("decode response: %v", err)
+				}
+				if got.Field != tt.wantField {
+					t.Errorf("response = %+v, want an error for field %q", got, tt.wantField)
+				}
+			}
+		})
+	}
+}
+
+func TestListAudit(t *testing.T) {
+	repo := &fakeRepository{}
+	for range 3 {
+		repo.audit = append(repo.audit, &models.AuditEntry{ID: uuid.New(), ActorID: uuid.New(), Action: models.AuditPointsAdjusted})
+	}
+	handler := newTestHandler(t, repo, Options{})
+	actor := uuid.New()
+
+	if rec := authRequest(t, handler, http.MethodGet, "/admin/audit", "", uuid.New()); rec.Code != http.StatusForbidden {
+		t.Errorf("user status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+
+	tests := []struct {
+		name      string
+		query     string
+		wantCode  int
+		wantQuery string
+		wantItems int
+	}{
+		{name: "defaults", query: "", wantCode: http.StatusOK, wantQuery: "|||50|0", wantItems: 3},
+		{name: "filters", query: "?actor=" + actor.String() + "&action=task.reverted&since=2024-01-01T00:00:00Z&limit=2&offset=1",
+			wantCode: http.StatusOK, wantQuery: actor.String() + "|task.reverted|2024-01-01T00:00:00Z|2|1", wantItems: 2},
+		{name: "limit clamped", query: "?limit=1000", wantCode: http.StatusOK, wantQuery: "|||200|0", wantItems: 3},
+		{name: "invalid actor", query: "?actor=bob", wantCode: http.StatusBadRequest},
+		{name: "invalid since", query: "?since=yesterday", wantCode: http.StatusBadRequest},
+		{name: "invalid limit", query: "?limit=0", wantCode: http.StatusBadRequest},
+		{name: "invalid offset", query: "?offset=-1", wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo.auditQuery = ""
+
+			rec := roleRequest(t, handler, http.MethodGet, "/admin/audit"+tt.query, "", uuid.New(), models.RoleAdmin)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			if repo.auditQuery != tt.wantQuery {
+				t.Errorf("repository query = %q, want %q", repo.auditQuery, tt.wantQuery)
+			}
+
+			// Журнал всегда возвращается в конверте, даже без параметра envelope
+			var page models.PagedResponse[models.AuditEntry]
+			if err := json.NewDecoder(rec.Body).Decode(&page); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if len(page.Items) != tt.wantItems || page.Total != 3 {
+				t.Errorf("page = %d items of %d, want %d of 3", len(page.Items), page.Total, tt.wantItems)
+			}
+		})
+	}
+}
+
+func TestMalformedJSONBody(t *testing.T) {
+	userID := uuid.New()
+	handler := newTestHandler(t, &fakeRepository{users: map[uuid.UUID]*models.User{userID: {ID: userID}}}, Options{})
+
+	tests := []struct {
+		name        string
+		body        string
+		wantMessage string
+		wantField   string
+	}{
+		{name: "empty body", body: "", wantMessage: "Request body is empty"},
+		{name: "truncated", body: `{"task_type": "daily"`, wantMessage: "Request body contains incomplete JSON"},
+		{name: "syntax error", body: `{"task_type": daily}`, wantMessage: "Request body contains malformed JSON at position 15"},
+		{name: "not an object", body: `[1, 2]`, wantMessage: "Request body must be a JSON object, got array"},
+		{name: "wrong type", body: `{"task_type": "daily", "points": "ten"}`, wantMessage: `Field "points" must be of type int, got string`, wantField: "points"},
+		{name: "unknown field", body: `{"task_type": "daily", "points": 5, "bonus": 1}`, wantMessage: `Unknown field "bonus"`, wantField: "bonus"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := authRequest(t, handler, http.MethodPost, "/users/me/task/complete", tt.body, userID)
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+			}
+			var got models.ErrorResponse
+			if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if got.Error != tt.wantMessage || got.Field != tt.wantField {
+				t.Errorf("response = %+v, want error %q for field %q", got, tt.wantMessage, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestUnauthorizedChallenge(t *testing.T) {
+	userID := uuid.New()
+	handler := newTestHandler(t, &fakeRepository{users: map[uuid.UUID]*models.User{userID: {ID: userID, Username: "alice"}}}, Options{})
+	token := testToken(t, userID, models.RoleUser)
+
+	tests := []struct {
+		name          string
+		target        string
+		authorization string
+		wantCode      int
+		wantChallenge string
+	}{
+		{name: "missing token", target: "/users/status", wantCode: http.StatusUnauthorized, wantChallenge: "Bearer"},
+		{name: "malformed token", target: "/users/status", authorization: "not-a-token", wantCode: http.StatusUnauthorized,
+			wantChallenge: `Bearer error="invalid_token", error_description="invalid token"`},
+		{name: "raw token", target: "/users/status", authorization: token, wantCode: http.StatusOK},
+		{name: "bearer prefix", target: "/users/status", authorization: "Bearer " + token, wantCode: http.StatusOK},
+		{name: "lowercase bearer prefix", target: "/users/status", authorization: "bearer " + token, wantCode: http.StatusOK},
+		{name: "bearer without token", target: "/users/status", authorization: "Bearer ", wantCode: http.StatusUnauthorized, wantChallenge: "Bearer"},
+		{name: "insufficient role", target: "/admin/audit", authorization: "Bearer " + token, wantCode: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantCode, rec.Body)
+			}
+			if got := rec.Header().Get(jwt.ChallengeHeader); got != tt.wantChallenge {
+				t.Errorf("%s = %q, want %q", jwt.ChallengeHeader, got, tt.wantChallenge)
+			}
+		})
+	}
+}
+
+func TestHealthProbes(t *testing.T) {
+	drain := middleware.NewDrain(3 * time.Second)
+	handler := newTestHandler(t, &fakeRepository{}, Options{Drain: drain})
+
+	get := func(target string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		maps.Copy(req.Header, header)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	status := func(t *testing.T, rec *httptest.ResponseRecorder) string {
+		t.Helper()
+		var got models.HealthResponse
+		if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		return got.Status
+	}
+
+	for _, target := range []string{"/healthz", "/readyz"} {
+		rec := get(target, nil)
+		if rec.Code != http.StatusOK || status(t, rec) != models.HealthStatusOK {
+			t.Errorf("GET %s before shutdown = %d, want 200 ok", target, rec.Code)
+		}
+	}
+	if rec := get("/version", nil); rec.Code != http.StatusOK {
+		t.Errorf("GET /version before shutdown = %d, want 200", rec.Code)
+	}
+	if rec := get("/users/leaderboard", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("GET /users/leaderboard without a token before shutdown = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+
+	drain.Start()
+
+	if rec := get("/healthz", nil); rec.Code != http.StatusOK || status(t, rec) != models.HealthStatusOK {
+		t.Errorf("GET /healthz while draining = %d, want 200 ok", rec.Code)
+	}
+	if rec := get("/readyz", nil); rec.Code != http.StatusServiceUnavailable || status(t, rec) != models.HealthStatusDraining {
+		t.Errorf("GET /readyz while draining = %d, want 503 draining", rec.Code)
+	}
+
+	token := http.Header{"Authorization": {testToken(t, uuid.New(), models.RoleUser)}}
+	for _, tt := range []struct {
+		target string
+		header http.Header
+	}{
+		{target: "/version"},
+		{target: "/users/leaderboard", header: token},
+		// Остановка проверяется до токена, поэтому запрос без токена тоже получает 503
+		{target: "/users/leaderboard"},
+	} {
+		rec := get(tt.target, tt.header)
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("GET %s while draining = %d, want %d", tt.target, rec.Code, http.StatusServiceUnavailable)
+			continue
+		}
+		if got := rec.Header().Get("Retry-After"); got != "3" {
+			t.Errorf("GET %s Retry-After = %q, want 3", tt.target, got)
+		}
+		if got := rec.Header().Get("Connection"); got != "close" {
+			t.Errorf("GET %s Connection = %q, want close", tt.target, got)
+		}
+		var got models.ErrorResponse
+		if err := json.NewDecoder(rec.Body).Decode(&got); err != nil || got.Code != models.CodeShuttingDown {
+			t.Errorf("GET %s body = %+v (%v), want code %s", tt.target, got, err, models.CodeShuttingDown)
+		}
+	}
+}
+
+func TestGetLeaderboardCSV(t *testing.T) {
+	alice, bob, carol := uuid.New(), uuid.New(), uuid.New()
+	repo := &fakeRepository{
+		leaderboard: []*models.User{
+			{ID: alice, Username: "alice", Points: 30},
+			{ID: bob, Username: "bob, jr", Points: 20},
+			{ID: carol, Username: "carol", Points: 10},
+		},
+		periodLeaderboard: []*models.PeriodLeaderboardEntry{
+			{UserID: alice, Username: "alice", Points: 5, Rank: 1},
+			{UserID: carol, Username: "carol", Points: 5, Rank: 1},
+		},
+	}
+	handler := newTestHandler(t, repo, Options{})
+
+	tests := []struct {
+		name         string
+		query        string
+		accept       string
+		wantCode     int
+		wantFilename string
+		wantBody     string
+	}{
+		{
+			name:         "format parameter",
+			query:        "?format=csv&limit=2&offset=1",
+			wantCode:     http.StatusOK,
+			wantFilename: "leaderboard.csv",
+			wantBody:     "rank,id,username,points\n2," + bob.String() + ",\"bob, jr\",20\n3," + carol.String() + ",carol,10\n",
+		},
+		{
+			name:         "accept header",
+			query:        "?limit=1",
+			accept:       "application/json;q=0.5, text/csv",
+			wantCode:     http.StatusOK,
+			wantFilename: "leaderboard.csv",
+			wantBody:     "rank,id,username,points\n1," + alice.String() + ",alice,30\n",
+		},
+		{
+			name:         "period",
+			query:        "?period=weekly&format=csv",
+			wantCode:     http.StatusOK,
+			wantFilename: "leaderboard-weekly.csv",
+			wantBody:     "rank,id,username,points\n1," + alice.String() + ",alice,5\n1," + carol.String() + ",carol,5\n",
+		},
+		{name: "format overrides accept", query: "?format=json", accept: "text/csv", wantCode: http.StatusOK},
+		{name: "unknown format", query: "?format=xml", wantCode: http.StatusBadRequest},
+		{name: "envelope", query: "?format=csv&envelope=true", wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/users/leaderboard"+tt.query, nil)
+			req.Header.Set("Authorization", testToken(t, uuid.New(), models.RoleUser))
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantCode, rec.Body)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			if tt.wantFilename == "" {
+				if got := rec.Header().Get("Content-Type"); got != "application/json" {
+					t.Errorf("Content-Type = %q, want application/json", got)
+				}
+				return
+			}
+
+			if got := rec.Header().Get("Content-Type"); got != "text/csv; charset=utf-8" {
+				t.Errorf("Content-Type = %q, want text/csv; charset=utf-8", got)
+			}
+			if got, want := rec.Header().Get("Content-Disposition"), `attachment; filename="`+tt.wantFilename+`"`; got != want {
+				t.Errorf("Content-Disposition = %q, want %q", got, want)
+			}
+			if rec.Header().Get("X-Total-Count") == "" {
+				t.Error("X-Total-Count is missing from the CSV response")
+			}
+			if got := rec.Body.String(); got != tt.wantBody {
+				t.Errorf("body = %q, want %q", got, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestEmailVerification(t *testing.T) {
+	email := "alice@example.com"
+	alice := &models.User{ID: uuid.New(), Username: "alice", Email: &email}
+	noEmail := &models.User{ID: uuid.New(), Username: "bob"}
+	repo := &fakeRepository{users: map[uuid.UUID]*models.User{alice.ID: alice, noEmail.ID: noEmail}}
+
+	newHandler := func(returnToken bool) http.Handler {
+		log := zap.NewNop()
+		jwtService := newTestJWT(t)
+		userService := service.NewUserService(repo, service.Options{}, log)
+		return versioned(NewRouter(jwtService,
+			handlers.NewUserHandler(userService, jwtService, handlers.Options{ReturnVerificationToken: returnToken}, log),
+			handlers.NewConfigHandler(models.PublicConfig{}, log),
+			handlers.NewAdminHandler(userService, log),
+			handlers.NewHealthHandler(middleware.NewDrain(time.Second), log),
+			Options{MaxBodyBytes: 1 << 20, AuthRateLimit: 100, AuthRateLimitWindow: time.Minute, BasePath: testBasePath},
+			log,
+		).Setup())
+	}
+	verify := func(handler http.Handler, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/users/verify?token="+token, nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// По умолчанию токен доставляется только уведомлением
+	rec := authRequest(t, newHandler(false), http.MethodPost, "/users/me/email/verify/request", "", alice.ID)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("request status = %d, want %d", rec.Code, http.StatusAccepted)
+	}
+	var hidden models.EmailVerificationResponse
+	if err := json.NewDecoder(rec.Body).Decode(&hidden); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if hidden.Email != email || hidden.Token != "" || hidden.ExpiresAt.IsZero() {
+		t.Errorf("response = %+v, want the address and expiry without the token", hidden)
+	}
+
+	handler := newHandler(true)
+	if rec := authRequest(t, handler, http.MethodPost, "/users/me/email/verify/request", "", noEmail.ID); rec.Code != http.StatusConflict {
+		t.Errorf("request without email status = %d, want %d", rec.Code, http.StatusConflict)
+	}
+
+	rec = authRequest(t, handler, http.MethodPost, "/users/me/email/verify/request", "", alice.ID)
+	var got models.EmailVerificationResponse
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if got.Token == "" {
+		t.Fatal("token is empty, want it returned when configured")
+	}
+
+	if rec := verify(handler, ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("missing token status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	if rec := verify(handler, got.Token+"x"); rec.Code != http.StatusBadRequest {
+		t.Errorf("tampered token status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	if rec := verify(handler, got.Token); rec.Code != http.StatusNoContent {
+		t.Fatalf("verify status = %d, want %d", rec.Code, http.StatusNoContent)
+	}
+	if alice.EmailVerifiedAt == nil {
+		t.Error("email is not verified after a valid token")
+	}
+	if rec := authRequest(t, handler, http.MethodPost, "/users/me/email/verify/request", "", alice.ID); rec.Code != http.StatusConflict {
+		t.Errorf("request for verified email status = %d, want %d", rec.Code, http.StatusConflict)
+	}
+}
+
+func TestPasswordReset(t *testing.T) {
+	alice := &models.User{ID: uuid.New(), Username: "alice"}
+	repo := &fakeRepository{users: map[uuid.UUID]*models.User{alice.ID: alice}}
+	handler := newTestHandler(t, repo, Options{AuthRateLimit: 100, AuthRateLimitWindow: time.Minute})
+
+	post := func(target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Ответ не раскрывает, существует ли учетная запись
+	known := post("/auth/password-reset/request", `{"login":"alice"}`)
+	unknown := post("/auth/password-reset/request", `{"login":"mallory"}`)
+	if known.Code != http.StatusOK || unknown.Code != http.StatusOK {
+		t.Fatalf("request status = %d known, %d unknown, want %d", known.Code, unknown.Code, http.StatusOK)
+	}
+	if known.Body.String() != unknown.Body.String() {
+		t.Errorf("response for a known login %q differs from an unknown one %q", known.Body.String(), unknown.Body.String())
+	}
+	if len(repo.resetTokens) != 1 {
+		t.Errorf("issued tokens = %d, want one for the known login", len(repo.resetTokens))
+	}
+	if rec := post("/auth/password-reset/request", `{}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("missing login status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+
+	tests := []struct {
+		name      string
+		body      string
+		wantField string
+	}{
+		{name: "unknown token", body: `{"token":"unknown","new_password":"brand-new-password"}`, wantField: "token"},
+		{name: "weak password", body: `{"token":"unknown","new_password":"short"}`, wantField: "new_password"},
+		{name: "missing token", body: `{"new_password":"brand-new-password"}`, wantField: "token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := post("/auth/password-reset/confirm", tt.body)
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+			}
+			var got models.ErrorResponse
+			if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if got.Field != tt.wantField {
+				t.Errorf("response = %+v, want an error for field %q", got, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestRequireJSON(t *testing.T) {
+	userID := uuid.New()
+	request := func(handler http.Handler, target, contentType string, authorized bool) int {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(`{"task_type":"daily","points":10}`))
+		req.Header.Set("Content-Type", contentType)
+		if authorized {
+			req.Header.Set("Authorization", testToken(t, userID, models.RoleUser))
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	handler := newTestHandler(t, &fakeRepository{}, Options{RequireJSON: true})
+	if code := request(handler, "/users/me/task/complete", "text/plain", true); code != http.StatusUnsupportedMediaType {
+		t.Errorf("text/plain status = %d, want %d", code, http.StatusUnsupportedMediaType)
+	}
+	if code := request(handler, "/users/me/task/complete", "application/json; charset=utf-8", true); code != http.StatusOK {
+		t.Errorf("JSON with charset status = %d, want %d", code, http.StatusOK)
+	}
+	if code := request(handler, "/users/me/task/complete", "application/x-ndjson", true); code != http.StatusOK {
+		t.Errorf("NDJSON status = %d, want %d", code, http.StatusOK)
+	}
+	if code := request(handler, "/users/register", "text/plain", false); code != http.StatusUnsupportedMediaType {
+		t.Errorf("public text/plain status = %d, want %d", code, http.StatusUnsupportedMediaType)
+	}
+	// Аутентификация проверяется раньше типа тела
+	if code := request(handler, "/users/me/task/complete", "text/plain", false); code != http.StatusUnauthorized {
+		t.Errorf("text/plain without token status = %d, want %d", code, http.StatusUnauthorized)
+	}
+
+	disabled := newTestHandler(t, &fakeRepository{}, Options{})
+	if code := request(disabled, "/users/me/task/complete", "text/plain", true); code == http.StatusUnsupportedMediaType {
+		t.Errorf("status = %d with the check disabled, want the request handled", code)
+	}
+}
+
+func TestVersionedRoutes(t *testing.T) {
+	newHandler := func(legacyRedirect bool) http.Handler {
+		log := zap.NewNop()
+		jwtService := newTestJWT(t)
+		userService := service.NewUserService(&fakeRepository{}, service.Options{}, log)
+		return NewRouter(jwtService,
+			handlers.NewUserHandler(userService, jwtService, handlers.Options{MaxLeaderboardLimit: testMaxLeaderboardLimit}, log),
+			handlers.NewConfigHandler(models.PublicConfig{}, log),
+			handlers.NewAdminHandler(userService, log),
+			handlers.NewHealthHandler(middleware.NewDrain(time.Second), log),
+			Options{MaxBodyBytes: 1 << 20, BasePath: "/svc", LegacyRedirect: legacyRedirect},
+			log,
+		).Setup()
+	}
+	serve := func(handler http.Handler, method, target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	handler := newHandler(true)
+	if rec := serve(handler, http.MethodGet, "/svc/v1/version"); rec.Code != http.StatusOK {
+		t.Errorf("versioned route status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if rec := serve(handler, http.MethodGet, "/healthz"); rec.Code != http.StatusOK {
+		t.Errorf("/healthz status = %d, want %d without a version prefix", rec.Code, http.StatusOK)
+	}
+
+	for _, tt := range []struct {
+		method, target, wantLocation string
+	}{
+		{method: http.MethodGet, target: "/users/leaderboard?limit=5", wantLocation: "/svc/v1/users/leaderboard?limit=5"},
+		{method: http.MethodPost, target: "/users/register", wantLocation: "/svc/v1/users/register"},
+	} {
+		rec := serve(handler, tt.method, tt.target)
+		if rec.Code != http.StatusPermanentRedirect || rec.Header().Get("Location") != tt.wantLocation {
+			t.Errorf("%s %s = %d to %q, want %d to %q", tt.method, tt.target, rec.Code, rec.Header().Get("Location"), http.StatusPermanentRedirect, tt.wantLocation)
+		}
+		if rec.Header().Get("Deprecation") != "true" {
+			t.Errorf("%s %s Deprecation = %q, want true", tt.method, tt.target, rec.Header().Get("Deprecation"))
+		}
+	}
+	if rec := serve(handler, http.MethodGet, "/unknown"); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown legacy path status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+
+	if rec := serve(newHandler(false), http.MethodGet, "/users/leaderboard"); rec.Code != http.StatusNotFound {
+		t.Errorf("legacy path with redirects disabled status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+// readEvent читает из потока server-sent events кадр до пустой строки
+func readEvent(t *testing.T, r *bufio.Reader) string {
+	t.Helper()
+
+	var frame strings.Builder
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read event: %v (got %q)", err, frame.String())
+		}
+		if line == "\n" {
+			return frame.String()
+		}
+		frame.WriteString(line)
+	}
+}
+
+func TestStreamLeaderboard(t *testing.T) {
+	alice := &models.User{ID: uuid.New(), Username: "alice", Points: 10}
+	bob := &models.User{ID: uuid.New(), Username: "bob", Points: 20}
+	repo := &fakeRepository{leaderboard: []*models.User{alice}}
+	handler, userService := newTestAPI(t, repo, Options{}, service.Options{LeaderboardStreamMaxSubscribers: 1})
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	stream := func(t *testing.T, query string) *http.Response {
+		t.Helper()
+
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/users/leaderboard/stream"+query, nil)
+		if err != nil {
+			t.Fatalf("NewRequest() error = %v", err)
+		}
+		req.Header.Set("Authorization", testToken(t, uuid.New(), models.RoleUser))
+		resp, err := server.Client().Do(req)
+		if err != nil {
+			t.Fatalf("stream request: %v", err)
+		}
+		return resp
+	}
+
+	resp := stream(t, "?limit=10")
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if got := resp.Header.Get("Content-Type"); got != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", got)
+	}
+	events := bufio.NewReader(resp.Body)
+
+	decode := func(t *testing.T, frame string) models.PagedResponse[*models.User] {
+		t.Helper()
+
+		data, ok := strings.CutPrefix(frame, "event: leaderboard\ndata: ")
+		if !ok {
+			t.Fatalf("frame = %q, want leaderboard event", frame)
+		}
+		var page models.PagedResponse[*models.User]
+		if err := json.Unmarshal([]byte(data), &page); err != nil {
+			t.Fatalf("decode event data %q: %v", data, err)
+		}
+		return page
+	}
+
+	if page := decode(t, readEvent(t, events)); page.Total != 1 || page.Items[0].Username != "alice" {
+		t.Errorf("first event = %+v, want alice only", page)
+	}
+
+	t.Run("subscriber limit", func(t *testing.T) {
+		resp := stream(t, "")
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusServiceUnavailable)
+		}
+		if got := resp.Header.Get("Retry-After"); got != "5" {
+			t.Errorf("Retry-After = %q, want 5", got)
+		}
+	})
+
+	t.Run("period rejected", func(t *testing.T) {
+		resp := stream(t, "?period=week")
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusBadRequest)
+		}
+	})
+
+	repo.leaderboard = []*models.User{bob, alice}
+	if rec := authRequest(t, handler, http.MethodPost, "/users/task/complete", `{"task_type":"daily","points":10}`, bob.ID); rec.Code != http.StatusOK {
+		t.Fatalf("complete task status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if page := decode(t, readEvent(t, events)); page.Total != 2 || page.Items[0].Username != "bob" {
+		t.Errorf("event after points changed = %+v, want bob first", page)
+	}
+
+	userService.CloseLeaderboardStreams()
+	if line, err := events.ReadString('\n'); !errors.Is(err, io.EOF) {
+		t.Errorf("after shutdown read = %q, %v, want EOF", line, err)
+	}
+}
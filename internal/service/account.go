@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/logger"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/validate"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// DeleteUser удаляет учетную запись пользователя
+func (s *UserService) DeleteUser(ctx context.Context, userID uuid.UUID) error {
+	log := logger.FromContext(ctx, s.log)
+	log.Info("Deleting user", zap.String("user_id", userID.String()))
+
+	if err := s.repo.DeleteUser(ctx, userID); err != nil {
+		log.Error("Failed to delete user",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return err
+	}
+	s.leaderboardChanged()
+	s.recordAudit(ctx, userID, models.AuditUserDeleted, userID, nil)
+
+	log.Info("User deleted successfully", zap.String("user_id", userID.String()))
+	return nil
+}
+
+// UpdateUsername изменяет имя пользователя с теми же правилами валидации, что и при регистрации.
+// При expectedVersion > 0 имя изменяется, только если профиль не менялся с этой версии,
+// иначе возвращается models.ErrConflict.
+func (s *UserService) UpdateUsername(ctx context.Context, userID uuid.UUID, newUsername string, expectedVersion int) (*models.User, error) {
+	log := logger.FromContext(ctx, s.log)
+	log.Info("Updating username",
+		zap.String("user_id", userID.String()),
+		zap.String("username", newUsername))
+
+	if err := validate.Username(newUsername); err != nil {
+		log.Warn("Invalid username", zap.String("username", newUsername), zap.Error(err))
+		return nil, err
+	}
+	if s.IsReservedUsername(newUsername) {
+		log.Warn("Username is reserved", zap.String("username", newUsername))
+		return nil, models.ErrUsernameReserved
+	}
+	newUsername = s.canonicalUsername(newUsername)
+
+	if err := s.repo.UpdateUsername(ctx, userID, newUsername, expectedVersion); err != nil {
+		log.Error("Failed to update username",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, err
+	}
+	s.leaderboardChanged()
+
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		log.Error("Failed to get updated user",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, err
+	}
+
+	log.Info("Username updated successfully", zap.String("user_id", userID.String()))
+	return user, nil
+}
+
+// GetUserByUsername возвращает публичный профиль пользователя по имени
+func (s *UserService) GetUserByUsername(ctx context.Context, username string) (*models.PublicProfile, error) {
+	log := logger.FromContext(ctx, s.log)
+	log.Info("Looking up user", zap.String("username", username))
+
+	profile, err := s.repo.GetUserByUsername(ctx, strings.TrimSpace(username))
+	if err != nil {
+		if !errors.Is(err, models.ErrUserNotFound) {
+			log.Error("Failed to look up user",
+				zap.String("username", username),
+				zap.Error(err))
+		}
+		return nil, err
+	}
+
+	return profile, nil
+}
+
+// RegenerateReferralCode выдает пользователю новый реферальный код взамен утекшего
+// или используемого злонамеренно; прежний код сразу перестает разрешаться
+func (s *UserService) RegenerateReferralCode(ctx context.Context, userID uuid.UUID) (string, error) {
+	log := logger.FromContext(ctx, s.log)
+	log.Info("Regenerating referral code", zap.String("user_id", userID.String()))
+
+	code, err := s.repo.RegenerateReferralCode(ctx, userID)
+	if err != nil {
+		log.Error("Failed to regenerate referral code",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return "", err
+	}
+	s.recordAudit(ctx, userID, models.AuditReferralRotated, userID, nil)
+
+	log.Info("Referral code regenerated", zap.String("user_id", userID.String()))
+	return code, nil
+}
+
+// ResolveReferralCode возвращает ID пользователя, которому принадлежит реферальный код,
+// или models.ErrReferrerNotFound для неизвестного кода
+func (s *UserService) ResolveReferralCode(ctx context.Context, code string) (uuid.UUID, error) {
+	log := logger.FromContext(ctx, s.log)
+	log.Info("Resolving referral code", zap.String("referral_code", code))
+
+	user, err := s.repo.GetUserByReferralCode(ctx, code)
+	if err != nil {
+		if errors.Is(err, models.ErrUserNotFound) {
+			log.Warn("Referral code not found", zap.String("referral_code", code))
+			return uuid.Nil, models.ErrReferrerNotFound
+		}
+		log.Error("Failed to resolve referral code",
+			zap.String("referral_code", code),
+			zap.Error(err))
+		return uuid.Nil, err
+	}
+
+	return user.ID, nil
+}
@@ -0,0 +1,208 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/logger"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/validate"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// statsCache хранит последние общие показатели в течение ttl.
+// При нулевом ttl кеширование отключено.
+type statsCache struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	stats     *models.GlobalStats
+	expiresAt time.Time
+}
+
+// GetGlobalStats возвращает общие показатели сервиса. Подсчет дорогой, поэтому результат
+// кешируется на Options.StatsCacheTTL; одновременные запросы при устаревшем кеше
+// ожидают один подсчет, а не выполняют его каждый.
+func (s *UserService) GetGlobalStats(ctx context.Context) (*models.GlobalStats, error) {
+	log := logger.FromContext(ctx, s.log)
+	log.Info("Getting global stats")
+
+	s.stats.mu.Lock()
+	defer s.stats.mu.Unlock()
+
+	if s.stats.stats != nil && time.Now().Before(s.stats.expiresAt) {
+		log.Debug("Global stats served from cache")
+		return s.stats.stats, nil
+	}
+
+	stats, err := s.repo.GetGlobalStats(ctx)
+	if err != nil {
+		log.Error("Failed to get global stats", zap.Error(err))
+		return nil, err
+	}
+	if s.stats.ttl > 0 {
+		s.stats.stats = stats
+		s.stats.expiresAt = time.Now().Add(s.stats.ttl)
+	}
+
+	return stats, nil
+}
+
+// ListUsers возвращает страницу пользователей для администратора и общее количество подходящих
+func (s *UserService) ListUsers(ctx context.Context, filter models.UserListFilter, limit, offset int) ([]*models.User, int, error) {
+	log := logger.FromContext(ctx, s.log)
+	log.Info("Listing users", zap.Int("limit", limit), zap.Int("offset", offset))
+
+	users, total, err := s.repo.ListUsers(ctx, filter, limit, offset)
+	if err != nil {
+		log.Error("Failed to list users", zap.Error(err))
+		return nil, 0, err
+	}
+
+	log.Debug("Users listed successfully", zap.Int("users_count", len(users)), zap.Int("total", total))
+	return users, total, nil
+}
+
+// GetTaskStats возвращает агрегированную статистику по типам заданий
+func (s *UserService) GetTaskStats(ctx context.Context, from, to *time.Time) ([]*models.TaskStats, error) {
+	log := logger.FromContext(ctx, s.log)
+	log.Info("Getting task stats")
+
+	stats, err := s.repo.GetTaskStats(ctx, from, to)
+	if err != nil {
+		log.Error("Failed to get task stats", zap.Error(err))
+		return nil, err
+	}
+
+	log.Debug("Task stats retrieved successfully", zap.Int("task_types", len(stats)))
+	return stats, nil
+}
+
+// RevertTask отменяет ошибочно засчитанное задание по решению администратора adminID
+func (s *UserService) RevertTask(ctx context.Context, adminID, taskID uuid.UUID) (*models.Task, error) {
+	log := logger.FromContext(ctx, s.log)
+	log.Info("Reverting task",
+		zap.String("admin_id", adminID.String()),
+		zap.String("task_id", taskID.String()))
+
+	task, err := s.repo.RevertTask(ctx, taskID)
+	if err != nil {
+		log.Error("Failed to revert task",
+			zap.String("task_id", taskID.String()),
+			zap.Error(err))
+		return nil, err
+	}
+	s.leaderboardChanged()
+	s.recordAudit(ctx, adminID, models.AuditTaskReverted, taskID, map[string]any{
+		"user_id": task.UserID.String(),
+		"points":  task.Points,
+	})
+
+	log.Info("Task reverted successfully",
+		zap.String("task_id", taskID.String()),
+		zap.String("user_id", task.UserID.String()),
+		zap.Int("points", task.Points))
+	return task, nil
+}
+
+// ImportUsers проверяет и создает пользователей пакетом, хешируя пароли.
+// Пользователи с занятыми именами пропускаются.
+func (s *UserService) ImportUsers(ctx context.Context, users []models.ImportUserRequest) (*models.ImportUsersResponse, error) {
+	log := logger.FromContext(ctx, s.log)
+	log.Info("Importing users", zap.Int("count", len(users)))
+
+	if s.opts.ImportMaxBatch > 0 && len(users) > s.opts.ImportMaxBatch {
+		log.Warn("Import batch too large",
+			zap.Int("count", len(users)),
+			zap.Int("max", s.opts.ImportMaxBatch))
+		return nil, models.ErrBatchTooLarge
+	}
+
+	prepared := make([]models.ImportUserRequest, 0, len(users))
+	for i, user := range users {
+		if err := validate.Username(user.Username); err != nil {
+			return nil, indexedFieldError(err, i)
+		}
+		if err := validate.Password("password", user.Password, s.opts.MinPasswordLength); err != nil {
+			return nil, indexedFieldError(err, i)
+		}
+		if user.Points < 0 {
+			return nil, &validate.FieldError{Field: fmt.Sprintf("[%d].points", i), Message: "must not be negative"}
+		}
+
+		hash, err := hashPassword(user.Password)
+		if err != nil {
+			log.Error("Failed to hash password", zap.String("username", user.Username), zap.Error(err))
+			return nil, err
+		}
+		prepared = append(prepared, models.ImportUserRequest{
+			Username: s.canonicalUsername(user.Username),
+			Password: hash,
+			Points:   user.Points,
+		})
+	}
+
+	inserted, err := s.repo.ImportUsers(ctx, prepared)
+	if err != nil {
+		log.Error("Failed to import users", zap.Error(err))
+		return nil, err
+	}
+	if inserted > 0 {
+		s.leaderboardChanged()
+	}
+
+	result := &models.ImportUsersResponse{
+		Inserted: inserted,
+		Skipped:  len(users) - inserted,
+	}
+	log.Info("Users imported successfully",
+		zap.Int("inserted", result.Inserted),
+		zap.Int("skipped", result.Skipped))
+	return result, nil
+}
+
+// indexedFieldError добавляет к полю ошибки валидации индекс элемента пакета
+func indexedFieldError(err error, index int) error {
+	var fieldErr *validate.FieldError
+	if errors.As(err, &fieldErr) {
+		return &validate.FieldError{
+			Field:   fmt.Sprintf("[%d].%s", index, fieldErr.Field),
+			Message: fieldErr.Message,
+		}
+	}
+	return err
+}
+
+// AdjustPoints вручную начисляет (delta > 0) или списывает (delta < 0) баллы пользователя
+func (s *UserService) AdjustPoints(ctx context.Context, adminID, userID uuid.UUID, delta int, reason string) (*models.PointAdjustment, error) {
+	log := logger.FromContext(ctx, s.log)
+	log.Info("Adjusting points",
+		zap.String("admin_id", adminID.String()),
+		zap.String("user_id", userID.String()),
+		zap.Int("delta", delta))
+
+	adjustment, err := s.repo.AdjustPoints(ctx, adminID, userID, delta, reason)
+	if err != nil {
+		log.Error("Failed to adjust points",
+			zap.String("user_id", userID.String()),
+			zap.Int("delta", delta),
+			zap.Error(err))
+		return nil, err
+	}
+	s.leaderboardChanged()
+	s.recordAudit(ctx, adminID, models.AuditPointsAdjusted, userID, map[string]any{
+		"adjustment_id": adjustment.ID.String(),
+		"delta":         delta,
+		"applied_delta": adjustment.AppliedDelta,
+		"reason":        reason,
+	})
+
+	log.Info("Points adjusted successfully",
+		zap.String("adjustment_id", adjustment.ID.String()),
+		zap.String("user_id", userID.String()),
+		zap.Int("applied_delta", adjustment.AppliedDelta))
+	return adjustment, nil
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/logger"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/validate"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// checkTaskPoints проверяет ограничение баллов за одно задание перед начислением
+func (s *UserService) checkTaskPoints(ctx context.Context, userID uuid.UUID, taskRequest models.TaskRequest) error {
+	if s.opts.MaxPointsPerTask <= 0 || taskRequest.Points <= s.opts.MaxPointsPerTask {
+		return nil
+	}
+
+	logger.FromContext(ctx, s.log).Warn("Task points exceed the cap",
+		zap.String("user_id", userID.String()),
+		zap.Int("points", taskRequest.Points),
+		zap.Int("max_points", s.opts.MaxPointsPerTask))
+	return &validate.FieldError{
+		Field:   "points",
+		Message: fmt.Sprintf("must be at most %d", s.opts.MaxPointsPerTask),
+	}
+}
+
+// taskActivityLimit возвращает проверку количества заданий пользователя за окно AntiCheatWindow.
+// Превышение лимита заданий записывается в лог как suspicious_activity и при AntiCheatReject
+// отклоняется с models.ErrTooManyTasks. Без AntiCheatMaxTasks проверка отключена.
+func (s *UserService) taskActivityLimit(ctx context.Context, userID uuid.UUID, taskRequest models.TaskRequest) models.TaskActivityLimit {
+	if s.opts.AntiCheatMaxTasks <= 0 {
+		return models.TaskActivityLimit{}
+	}
+
+	return models.TaskActivityLimit{
+		Since: time.Now().Add(-s.opts.AntiCheatWindow),
+		Check: func(count int) error {
+			// Текущее задание становится count+1-м в окне
+			if count < s.opts.AntiCheatMaxTasks {
+				return nil
+			}
+
+			logger.FromContext(ctx, s.log).Warn("suspicious_activity",
+				zap.String("user_id", userID.String()),
+				zap.String("task_type", taskRequest.TaskType),
+				zap.Int("tasks_in_window", count+1),
+				zap.Int("max_tasks", s.opts.AntiCheatMaxTasks),
+				zap.Duration("window", s.opts.AntiCheatWindow),
+				zap.Bool("rejected", s.opts.AntiCheatReject))
+
+			if s.opts.AntiCheatReject {
+				return models.ErrTooManyTasks
+			}
+			return nil
+		},
+	}
+}
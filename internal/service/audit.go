@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/logger"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// AuditRepository интерфейс журнала аудита чувствительных операций
+type AuditRepository interface {
+	Record(ctx context.Context, actorID uuid.UUID, action string, targetID uuid.UUID, metadata map[string]any) error
+	ListAudit(ctx context.Context, filter models.AuditFilter, limit, offset int) ([]*models.AuditEntry, int, error)
+}
+
+// StorageRepository полный интерфейс хранилища, которое создает приложение:
+// данные пользователей, журнал аудита и освобождение ресурсов при остановке.
+// Реализуется хранилищами PostgreSQL и в памяти.
+type StorageRepository interface {
+	UserRepository
+	AuditRepository
+	Close() error
+}
+
+// recordAudit записывает выполненную операцию в журнал аудита. Операция к этому моменту
+// уже завершена, поэтому ошибка записи только логируется и не возвращается вызывающему.
+func (s *UserService) recordAudit(ctx context.Context, actorID uuid.UUID, action string, targetID uuid.UUID, metadata map[string]any) {
+	if s.audit == nil {
+		return
+	}
+
+	log := logger.FromContext(ctx, s.log)
+	if err := s.audit.Record(ctx, actorID, action, targetID, metadata); err != nil {
+		log.Error("Failed to record audit entry",
+			zap.String("actor_id", actorID.String()),
+			zap.String("action", action),
+			zap.String("target_id", targetID.String()),
+			zap.Error(err))
+	}
+}
+
+// ListAudit возвращает страницу журнала аудита и общее количество подходящих записей
+func (s *UserService) ListAudit(ctx context.Context, filter models.AuditFilter, limit, offset int) ([]*models.AuditEntry, int, error) {
+	log := logger.FromContext(ctx, s.log)
+	log.Info("Listing audit entries",
+		zap.String("action", filter.Action),
+		zap.Int("limit", limit),
+		zap.Int("offset", offset))
+
+	if s.audit == nil {
+		return nil, 0, nil
+	}
+
+	entries, total, err := s.audit.ListAudit(ctx, filter, limit, offset)
+	if err != nil {
+		log.Error("Failed to list audit entries", zap.Error(err))
+		return nil, 0, err
+	}
+
+	return entries, total, nil
+}
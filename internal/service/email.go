@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"time"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/logger"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/signedtoken"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// DefaultEmailVerificationTTL срок действия токена подтверждения адреса, если он не задан в Options
+const DefaultEmailVerificationTTL = 24 * time.Hour
+
+// emailVerificationPurpose назначение токенов подтверждения адреса, см. signedtoken.New
+const emailVerificationPurpose = "email_verification"
+
+// NewEmailTokenSigner создает подписчик токенов подтверждения адреса электронной почты
+func NewEmailTokenSigner(key []byte) *signedtoken.Signer {
+	return signedtoken.New(key, emailVerificationPurpose)
+}
+
+// randomKey генерирует случайный ключ подписи
+func randomKey() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic("failed to generate signing key: " + err.Error())
+	}
+	return key
+}
+
+// RequestEmailVerification выдает токен подтверждения текущего адреса пользователя
+// и отправляет событие models.EventEmailVerificationRequested для доставки письма.
+// Возвращает models.ErrEmailNotSet, если адрес не указан, и models.ErrEmailAlreadyVerified,
+// если он уже подтвержден.
+func (s *UserService) RequestEmailVerification(ctx context.Context, userID uuid.UUID) (*models.EmailVerificationResponse, error) {
+	log := logger.FromContext(ctx, s.log)
+	log.Info("Requesting email verification", zap.String("user_id", userID.String()))
+
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Email == nil {
+		return nil, models.ErrEmailNotSet
+	}
+	if user.EmailVerifiedAt != nil {
+		return nil, models.ErrEmailAlreadyVerified
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(s.opts.EmailVerificationTTL)
+	token, err := s.opts.EmailTokens.Sign(userID.String(), *user.Email, expiresAt)
+	if err != nil {
+		log.Error("Failed to sign verification token", zap.String("user_id", userID.String()), zap.Error(err))
+		return nil, err
+	}
+
+	if s.notifier == nil || !s.notifier.Notify(ctx, models.EventEmailVerificationRequested, models.EmailVerificationEvent{
+		Event:      models.EventEmailVerificationRequested,
+		UserID:     userID,
+		Email:      *user.Email,
+		Token:      token,
+		ExpiresAt:  expiresAt,
+		OccurredAt: now,
+	}) {
+		log.Warn("Verification token was not queued for delivery", zap.String("user_id", userID.String()))
+	}
+
+	return &models.EmailVerificationResponse{Email: *user.Email, ExpiresAt: expiresAt, Token: token}, nil
+}
+
+// VerifyEmail проверяет токен подтверждения и отмечает адрес пользователя подтвержденным.
+// Возвращает models.ErrInvalidVerificationToken для поддельного, истекшего или устаревшего токена
+// (например, если пользователь сменил адрес после запроса).
+func (s *UserService) VerifyEmail(ctx context.Context, token string) error {
+	log := logger.FromContext(ctx, s.log)
+
+	subject, email, err := s.opts.EmailTokens.Verify(token, time.Now())
+	if err != nil {
+		log.Warn("Invalid verification token", zap.Error(err))
+		return models.ErrInvalidVerificationToken
+	}
+	userID, err := uuid.Parse(subject)
+	if err != nil {
+		log.Warn("Invalid user ID in verification token", zap.String("user_id", subject))
+		return models.ErrInvalidVerificationToken
+	}
+
+	if err := s.repo.VerifyEmail(ctx, userID, email, time.Now()); err != nil {
+		if !errors.Is(err, models.ErrInvalidVerificationToken) {
+			log.Error("Failed to verify email", zap.String("user_id", userID.String()), zap.Error(err))
+		}
+		return err
+	}
+
+	log.Info("Email verified", zap.String("user_id", userID.String()))
+	return nil
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+)
+
+// leaderboardKey ключ кеша страницы таблицы лидеров
+type leaderboardKey struct {
+	limit  int
+	offset int
+}
+
+// leaderboardEntry закешированная страница таблицы лидеров
+type leaderboardEntry struct {
+	users     []*models.User
+	total     int
+	expiresAt time.Time
+}
+
+// leaderboardCache кеширует страницы таблицы лидеров на короткое время.
+// При нулевом ttl кеширование отключено.
+type leaderboardCache struct {
+	mu      sync.RWMutex
+	ttl     time.Duration
+	entries map[leaderboardKey]leaderboardEntry
+}
+
+func newLeaderboardCache(ttl time.Duration) *leaderboardCache {
+	return &leaderboardCache{
+		ttl:     ttl,
+		entries: make(map[leaderboardKey]leaderboardEntry),
+	}
+}
+
+// get возвращает страницу из кеша, если она не устарела
+func (c *leaderboardCache) get(key leaderboardKey) ([]*models.User, int, bool) {
+	if c.ttl <= 0 {
+		return nil, 0, false
+	}
+
+	c.mu.RLock()
+	entry, ok := c.entries[key]
+	c.mu.RUnlock()
+
+	if !ok || time.Now().After(entry.expiresAt) {
+		return nil, 0, false
+	}
+	return entry.users, entry.total, true
+}
+
+// set сохраняет страницу в кеше
+func (c *leaderboardCache) set(key leaderboardKey, users []*models.User, total int) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for k, entry := range c.entries {
+		if now.After(entry.expiresAt) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = leaderboardEntry{
+		users:     users,
+		total:     total,
+		expiresAt: now.Add(c.ttl),
+	}
+}
+
+// invalidate очищает кеш после изменения баллов
+func (c *leaderboardCache) invalidate() {
+	if c.ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	clear(c.entries)
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// PeriodBounds возвращает границы [from, to) текущего периода в UTC:
+// weekly - с понедельника текущей недели, monthly - с первого числа текущего месяца
+func PeriodBounds(period string, now time.Time) (time.Time, time.Time, error) {
+	now = now.UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	switch period {
+	case models.PeriodWeekly:
+		// time.Weekday начинается с воскресенья, неделя - с понедельника
+		daysSinceMonday := (int(today.Weekday()) + 6) % 7
+		from := today.AddDate(0, 0, -daysSinceMonday)
+		return from, from.AddDate(0, 0, 7), nil
+	case models.PeriodMonthly:
+		from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return from, from.AddDate(0, 1, 0), nil
+	default:
+		return time.Time{}, time.Time{}, models.ErrInvalidPeriod
+	}
+}
+
+// GetPeriodLeaderboard возвращает таблицу лидеров по баллам, заработанным в текущем периоде
+func (s *UserService) GetPeriodLeaderboard(ctx context.Context, period string, limit, offset int) ([]*models.PeriodLeaderboardEntry, int, error) {
+	log := logger.FromContext(ctx, s.log)
+	log.Info("Getting period leaderboard",
+		zap.String("period", period),
+		zap.Int("limit", limit),
+		zap.Int("offset", offset))
+
+	from, to, err := PeriodBounds(period, time.Now())
+	if err != nil {
+		log.Warn("Invalid leaderboard period", zap.String("period", period))
+		return nil, 0, err
+	}
+
+	entries, total, err := s.repo.GetPeriodLeaderboard(ctx, from, to, limit, offset)
+	if err != nil {
+		log.Error("Failed to get period leaderboard",
+			zap.String("period", period),
+			zap.Error(err))
+		return nil, 0, err
+	}
+
+	log.Debug("Period leaderboard retrieved successfully",
+		zap.String("period", period),
+		zap.Time("from", from),
+		zap.Time("to", to),
+		zap.Int("entries_count", len(entries)),
+		zap.Int("total", total))
+	return entries, total, nil
+}
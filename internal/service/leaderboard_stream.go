@@ -0,0 +1,21 @@
+package service
+
+// leaderboardChanged сбрасывает кеш таблицы лидеров и уведомляет подписчиков потока изменений
+func (s *UserService) leaderboardChanged() {
+	s.leaderboard.invalidate()
+	s.leaderboardUpdates.Publish()
+}
+
+// SubscribeLeaderboard подписывает на сигналы об изменении таблицы лидеров.
+// Сигналы объединяются: после сигнала подписчик запрашивает актуальную страницу сам.
+// Возвращает broadcast.ErrTooManySubscribers при превышении Options.LeaderboardStreamMaxSubscribers
+// и broadcast.ErrClosed после CloseLeaderboardStreams.
+func (s *UserService) SubscribeLeaderboard() (<-chan struct{}, func(), error) {
+	return s.leaderboardUpdates.Subscribe()
+}
+
+// CloseLeaderboardStreams закрывает все подписки на изменения таблицы лидеров,
+// чтобы долгоживущие потоки завершились при остановке сервера
+func (s *UserService) CloseLeaderboardStreams() {
+	s.leaderboardUpdates.Close()
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"go.uber.org/zap"
+)
+
+// DefaultLeaderboardViewRefreshInterval период пересчета материализованного представления
+// таблицы лидеров по умолчанию
+const DefaultLeaderboardViewRefreshInterval = time.Minute
+
+// DefaultLeaderboardViewMaxStaleness возраст представления по умолчанию, после которого
+// таблица лидеров читается из таблицы пользователей
+const DefaultLeaderboardViewMaxStaleness = 5 * time.Minute
+
+// LeaderboardViewRepository материализованное представление таблицы лидеров:
+// заранее отсортированный снимок баланса пользователей, который пересчитывается периодически.
+// Реализуется хранилищем PostgreSQL.
+type LeaderboardViewRepository interface {
+	GetLeaderboardView(ctx context.Context, filter models.LeaderboardFilter, limit, offset int) ([]*models.User, int, error)
+	RefreshLeaderboardView(ctx context.Context) error
+}
+
+// RunLeaderboardViewRefresh пересчитывает представление таблицы лидеров сразу и затем
+// каждые Options.LeaderboardViewRefreshInterval до отмены ctx. Без Options.LeaderboardView
+// возвращается сразу. Ошибка пересчета только логируется: пока представление не обновлено,
+// таблица лидеров по истечении Options.LeaderboardViewMaxStaleness читается из таблицы пользователей.
+func (s *UserService) RunLeaderboardViewRefresh(ctx context.Context) {
+	if s.opts.LeaderboardView == nil {
+		return
+	}
+
+	ticker := time.NewTicker(s.opts.LeaderboardViewRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		s.refreshLeaderboardView(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refreshLeaderboardView пересчитывает представление и запоминает время пересчета
+func (s *UserService) refreshLeaderboardView(ctx context.Context) {
+	// Пересчет не должен пересекаться со следующим, поэтому ограничен периодом обновления
+	refreshCtx, cancel := context.WithTimeout(ctx, s.opts.LeaderboardViewRefreshInterval)
+	defer cancel()
+
+	start := time.Now()
+	if err := s.opts.LeaderboardView.RefreshLeaderboardView(refreshCtx); err != nil {
+		if ctx.Err() == nil {
+			s.log.Error("Failed to refresh leaderboard view", zap.Error(err))
+		}
+		return
+	}
+	s.leaderboardViewRefreshedAt.Store(start.UnixNano())
+	// Страницы, закешированные до пересчета, и подписчики потока должны увидеть новый снимок
+	s.leaderboardChanged()
+
+	s.log.Debug("Leaderboard view refreshed", zap.Duration("duration", time.Since(start)))
+}
+
+// LeaderboardViewRefreshedAt возвращает время начала последнего успешного пересчета
+// представления и true, если таблица лидеров сейчас читается из него, то есть представление
+// пересчитано не раньше Options.LeaderboardViewMaxStaleness назад
+func (s *UserService) LeaderboardViewRefreshedAt() (time.Time, bool) {
+	if s.opts.LeaderboardView == nil {
+		return time.Time{}, false
+	}
+	nanos := s.leaderboardViewRefreshedAt.Load()
+	if nanos == 0 {
+		return time.Time{}, false
+	}
+	refreshedAt := time.Unix(0, nanos)
+	return refreshedAt, time.Since(refreshedAt) <= s.opts.LeaderboardViewMaxStaleness
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// AuthenticateUser проверяет имя и пароль, фиксирует вход и обновляет серию ежедневных входов.
+// Возвращает пользователя и начисленный за серию бонус; при неверных данных - models.ErrInvalidCredentials.
+func (s *UserService) AuthenticateUser(ctx context.Context, username, password string) (*models.User, int, error) {
+	log := logger.FromContext(ctx, s.log)
+	log.Info("Authenticating user", zap.String("username", username))
+
+	userID, hash, err := s.repo.GetCredentials(ctx, username)
+	if err != nil {
+		if errors.Is(err, models.ErrUserNotFound) {
+			log.Warn("Unknown username", zap.String("username", username))
+			return nil, 0, models.ErrInvalidCredentials
+		}
+		log.Error("Failed to get credentials", zap.String("username", username), zap.Error(err))
+		return nil, 0, err
+	}
+
+	if !checkPassword(hash, password) {
+		log.Warn("Password mismatch", zap.String("user_id", userID.String()))
+		return nil, 0, models.ErrInvalidCredentials
+	}
+
+	streak, bonus, err := s.repo.RecordLogin(ctx, userID, time.Now())
+	if err != nil {
+		log.Error("Failed to record login", zap.String("user_id", userID.String()), zap.Error(err))
+		return nil, 0, err
+	}
+	if bonus > 0 {
+		s.leaderboardChanged()
+	}
+
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		log.Error("Failed to get user", zap.String("user_id", userID.String()), zap.Error(err))
+		return nil, 0, err
+	}
+
+	log.Info("User authenticated successfully",
+		zap.String("user_id", userID.String()),
+		zap.Int("streak_days", streak),
+		zap.Int("streak_bonus", bonus))
+	return user, bonus, nil
+}
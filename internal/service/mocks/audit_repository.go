@@ -0,0 +1,57 @@
+package mocks
+
+import (
+	"context"
+	"sync"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"github.com/DblMOKRQ/DeNet_test_task/internal/service"
+	"github.com/google/uuid"
+)
+
+// Проверка соответствия интерфейсу на этапе компиляции
+var _ service.AuditRepository = (*AuditRepository)(nil)
+
+// AuditRecord аргументы вызова AuditRepository.Record
+type AuditRecord struct {
+	ActorID  uuid.UUID
+	Action   string
+	TargetID uuid.UUID
+	Metadata map[string]any
+}
+
+// AuditRepository ручная реализация service.AuditRepository для модульных тестов.
+// Record по умолчанию сохраняет записи в Records; поведение можно переопределить полем RecordFunc.
+type AuditRepository struct {
+	RecordFunc    func(ctx context.Context, actorID uuid.UUID, action string, targetID uuid.UUID, metadata map[string]any) error
+	ListAuditFunc func(ctx context.Context, filter models.AuditFilter, limit, offset int) ([]*models.AuditEntry, int, error)
+
+	mu      sync.Mutex
+	records []AuditRecord
+}
+
+// Records возвращает записи, переданные в Record
+func (m *AuditRepository) Records() []AuditRecord {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]AuditRecord(nil), m.records...)
+}
+
+func (m *AuditRepository) Record(ctx context.Context, actorID uuid.UUID, action string, targetID uuid.UUID, metadata map[string]any) error {
+	m.mu.Lock()
+	m.records = append(m.records, AuditRecord{ActorID: actorID, Action: action, TargetID: targetID, Metadata: metadata})
+	m.mu.Unlock()
+
+	if m.RecordFunc == nil {
+		return nil
+	}
+	return m.RecordFunc(ctx, actorID, action, targetID, metadata)
+}
+
+func (m *AuditRepository) ListAudit(ctx context.Context, filter models.AuditFilter, limit, offset int) ([]*models.AuditEntry, int, error) {
+	if m.ListAuditFunc == nil {
+		return nil, 0, ErrNotConfigured
+	}
+	return m.ListAuditFunc(ctx, filter, limit, offset)
+}
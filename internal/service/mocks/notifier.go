@@ -0,0 +1,40 @@
+package mocks
+
+import (
+	"context"
+	"sync"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/service"
+)
+
+// Проверка соответствия интерфейсу на этапе компиляции
+var _ service.Notifier = (*Notifier)(nil)
+
+// Notification аргументы вызова Notifier.Notify
+type Notification struct {
+	Event   string
+	Payload any
+}
+
+// Notifier ручная реализация service.Notifier для модульных тестов:
+// сохраняет все переданные события и принимает их
+type Notifier struct {
+	mu            sync.Mutex
+	notifications []Notification
+}
+
+// Notifications возвращает события, переданные в Notify
+func (m *Notifier) Notifications() []Notification {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]Notification(nil), m.notifications...)
+}
+
+func (m *Notifier) Notify(_ context.Context, event string, payload any) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.notifications = append(m.notifications, Notification{Event: event, Payload: payload})
+	return true
+}
@@ -0,0 +1,379 @@
+package mocks
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"github.com/DblMOKRQ/DeNet_test_task/internal/service"
+	"github.com/google/uuid"
+)
+
+// ErrNotConfigured возвращается методом мока, для которого не задана функция
+var ErrNotConfigured = errors.New("mock method not configured")
+
+// Проверка соответствия интерфейсу на этапе компиляции
+var _ service.UserRepository = (*UserRepository)(nil)
+
+// UserRepository ручная реализация service.UserRepository для модульных тестов.
+// Поведение каждого метода задается соответствующим полем *Func; количество вызовов
+// учитывается в Calls. Контракт интерфейса: для отсутствующего пользователя
+// методы возвращают models.ErrUserNotFound.
+type UserRepository struct {
+	GetUserByIDFunc              func(ctx context.Context, id uuid.UUID) (*models.User, error)
+	GetUsersByIDsFunc            func(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]*models.User, error)
+	GetLeaderboardFunc           func(ctx context.Context, filter models.LeaderboardFilter, limit, offset int) ([]*models.User, int, error)
+	CompleteTaskFunc             func(ctx context.Context, userID uuid.UUID, taskRequest models.TaskRequest, limit models.TaskActivityLimit) (*models.Task, error)
+	AddReferrerFunc              func(ctx context.Context, userID, referrerID uuid.UUID) (*models.User, []models.ReferralCredit, error)
+	RemoveReferrerFunc           func(ctx context.Context, userID uuid.UUID) (uuid.UUID, []models.ReferralCredit, error)
+	LoginUserFunc                func(ctx context.Context, username string, password string, email *string) (*models.User, error)
+	VerifyEmailFunc              func(ctx context.Context, userID uuid.UUID, email string, at time.Time) error
+	UsernameExistsFunc           func(ctx context.Context, username string) (bool, error)
+	GetPasswordHashFunc          func(ctx context.Context, userID uuid.UUID) (string, error)
+	GetPasswordHistoryFunc       func(ctx context.Context, userID uuid.UUID, limit int) ([]string, error)
+	UpdatePasswordFunc           func(ctx context.Context, userID uuid.UUID, newHash string, historyLimit int) error
+	GetUserByLoginFunc           func(ctx context.Context, login string) (*models.User, error)
+	CreatePasswordResetTokenFunc func(ctx context.Context, userID uuid.UUID, tokenHash string, expiresAt time.Time) error
+	GetPasswordResetTokenFunc    func(ctx context.Context, tokenHash string, now time.Time) (uuid.UUID, error)
+	ResetPasswordFunc            func(ctx context.Context, tokenHash, newHash string, historyLimit int, now time.Time) (uuid.UUID, error)
+	TransferPointsFunc           func(ctx context.Context, fromID, toID uuid.UUID, amount int) (int, error)
+	GetUserTasksFunc             func(ctx context.Context, userID uuid.UUID, taskType string, limit, offset int) ([]*models.Task, error)
+	GetTaskStatsFunc             func(ctx context.Context, from, to *time.Time) ([]*models.TaskStats, error)
+	GetGlobalStatsFunc           func(ctx context.Context) (*models.GlobalStats, error)
+	DeleteUserFunc               func(ctx context.Context, userID uuid.UUID) error
+	UpdateUsernameFunc           func(ctx context.Context, userID uuid.UUID, newUsername string, expectedVersion int) error
+	RevertTaskFunc               func(ctx context.Context, taskID uuid.UUID) (*models.Task, error)
+	GetPeriodLeaderboardFunc     func(ctx context.Context, from, to time.Time, limit, offset int) ([]*models.PeriodLeaderboardEntry, int, error)
+	GetCredentialsFunc           func(ctx context.Context, username string) (uuid.UUID, string, error)
+	RecordLoginFunc              func(ctx context.Context, userID uuid.UUID, now time.Time) (int, int, error)
+	ImportUsersFunc              func(ctx context.Context, users []models.ImportUserRequest) (int, error)
+	GetUserByUsernameFunc        func(ctx context.Context, username string) (*models.PublicProfile, error)
+	GetUserByReferralCodeFunc    func(ctx context.Context, code string) (*models.User, error)
+	GetReferrerChainFunc         func(ctx context.Context, userID uuid.UUID, maxDepth int) ([]*models.ReferrerChainEntry, error)
+	AdjustPointsFunc             func(ctx context.Context, adminID, userID uuid.UUID, delta int, reason string) (*models.PointAdjustment, error)
+	GetUserRankFunc              func(ctx context.Context, userID uuid.UUID) (int, error)
+	GetPointsToNextRankFunc      func(ctx context.Context, userID uuid.UUID) (int, error)
+	GetUserStandingFunc          func(ctx context.Context, userID uuid.UUID) (int, int, error)
+	CountReferralsFunc           func(ctx context.Context, userID uuid.UUID) (int, error)
+	ListUsersFunc                func(ctx context.Context, filter models.UserListFilter, limit, offset int) ([]*models.User, int, error)
+	RegenerateReferralCodeFunc   func(ctx context.Context, userID uuid.UUID) (string, error)
+
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+// Calls возвращает количество вызовов метода с указанным именем
+func (m *UserRepository) Calls(method string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.calls[method]
+}
+
+func (m *UserRepository) record(method string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.calls == nil {
+		m.calls = make(map[string]int)
+	}
+	m.calls[method]++
+}
+
+func (m *UserRepository) GetUserByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	m.record("GetUserByID")
+	if m.GetUserByIDFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.GetUserByIDFunc(ctx, id)
+}
+
+func (m *UserRepository) GetUsersByIDs(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]*models.User, error) {
+	m.record("GetUsersByIDs")
+	if m.GetUsersByIDsFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.GetUsersByIDsFunc(ctx, ids)
+}
+
+func (m *UserRepository) GetLeaderboard(ctx context.Context, filter models.LeaderboardFilter, limit, offset int) ([]*models.User, int, error) {
+	m.record("GetLeaderboard")
+	if m.GetLeaderboardFunc == nil {
+		return nil, 0, ErrNotConfigured
+	}
+	return m.GetLeaderboardFunc(ctx, filter, limit, offset)
+}
+
+func (m *UserRepository) CompleteTask(ctx context.Context, userID uuid.UUID, taskRequest models.TaskRequest, limit models.TaskActivityLimit) (*models.Task, error) {
+	m.record("CompleteTask")
+	if m.CompleteTaskFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.CompleteTaskFunc(ctx, userID, taskRequest, limit)
+}
+
+func (m *UserRepository) AddReferrer(ctx context.Context, userID, referrerID uuid.UUID) (*models.User, []models.ReferralCredit, error) {
+	m.record("AddReferrer")
+	if m.AddReferrerFunc == nil {
+		return nil, nil, ErrNotConfigured
+	}
+	return m.AddReferrerFunc(ctx, userID, referrerID)
+}
+
+func (m *UserRepository) RemoveReferrer(ctx context.Context, userID uuid.UUID) (uuid.UUID, []models.ReferralCredit, error) {
+	m.record("RemoveReferrer")
+	if m.RemoveReferrerFunc == nil {
+		return uuid.Nil, nil, ErrNotConfigured
+	}
+	return m.RemoveReferrerFunc(ctx, userID)
+}
+
+func (m *UserRepository) LoginUser(ctx context.Context, username string, password string, email *string) (*models.User, error) {
+	m.record("LoginUser")
+	if m.LoginUserFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.LoginUserFunc(ctx, username, password, email)
+}
+
+func (m *UserRepository) VerifyEmail(ctx context.Context, userID uuid.UUID, email string, at time.Time) error {
+	m.record("VerifyEmail")
+	if m.VerifyEmailFunc == nil {
+		return ErrNotConfigured
+	}
+	return m.VerifyEmailFunc(ctx, userID, email, at)
+}
+
+func (m *UserRepository) UsernameExists(ctx context.Context, username string) (bool, error) {
+	m.record("UsernameExists")
+	if m.UsernameExistsFunc == nil {
+		return false, ErrNotConfigured
+	}
+	return m.UsernameExistsFunc(ctx, username)
+}
+
+func (m *UserRepository) GetPasswordHash(ctx context.Context, userID uuid.UUID) (string, error) {
+	m.record("GetPasswordHash")
+	if m.GetPasswordHashFunc == nil {
+		return "", ErrNotConfigured
+	}
+	return m.GetPasswordHashFunc(ctx, userID)
+}
+
+func (m *UserRepository) GetPasswordHistory(ctx context.Context, userID uuid.UUID, limit int) ([]string, error) {
+	m.record("GetPasswordHistory")
+	if m.GetPasswordHistoryFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.GetPasswordHistoryFunc(ctx, userID, limit)
+}
+
+func (m *UserRepository) UpdatePassword(ctx context.Context, userID uuid.UUID, newHash string, historyLimit int) error {
+	m.record("UpdatePassword")
+	if m.UpdatePasswordFunc == nil {
+		return ErrNotConfigured
+	}
+	return m.UpdatePasswordFunc(ctx, userID, newHash, historyLimit)
+}
+
+func (m *UserRepository) GetUserByLogin(ctx context.Context, login string) (*models.User, error) {
+	m.record("GetUserByLogin")
+	if m.GetUserByLoginFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.GetUserByLoginFunc(ctx, login)
+}
+
+func (m *UserRepository) CreatePasswordResetToken(ctx context.Context, userID uuid.UUID, tokenHash string, expiresAt time.Time) error {
+	m.record("CreatePasswordResetToken")
+	if m.CreatePasswordResetTokenFunc == nil {
+		return ErrNotConfigured
+	}
+	return m.CreatePasswordResetTokenFunc(ctx, userID, tokenHash, expiresAt)
+}
+
+func (m *UserRepository) GetPasswordResetToken(ctx context.Context, tokenHash string, now time.Time) (uuid.UUID, error) {
+	m.record("GetPasswordResetToken")
+	if m.GetPasswordResetTokenFunc == nil {
+		return uuid.Nil, ErrNotConfigured
+	}
+	return m.GetPasswordResetTokenFunc(ctx, tokenHash, now)
+}
+
+func (m *UserRepository) ResetPassword(ctx context.Context, tokenHash, newHash string, historyLimit int, now time.Time) (uuid.UUID, error) {
+	m.record("ResetPassword")
+	if m.ResetPasswordFunc == nil {
+		return uuid.Nil, ErrNotConfigured
+	}
+	return m.ResetPasswordFunc(ctx, tokenHash, newHash, historyLimit, now)
+}
+
+func (m *UserRepository) TransferPoints(ctx context.Context, fromID, toID uuid.UUID, amount int) (int, error) {
+	m.record("TransferPoints")
+	if m.TransferPointsFunc == nil {
+		return 0, ErrNotConfigured
+	}
+	return m.TransferPointsFunc(ctx, fromID, toID, amount)
+}
+
+func (m *UserRepository) GetUserTasks(ctx context.Context, userID uuid.UUID, taskType string, limit, offset int) ([]*models.Task, error) {
+	m.record("GetUserTasks")
+	if m.GetUserTasksFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.GetUserTasksFunc(ctx, userID, taskType, limit, offset)
+}
+
+func (m *UserRepository) GetTaskStats(ctx context.Context, from, to *time.Time) ([]*models.TaskStats, error) {
+	m.record("GetTaskStats")
+	if m.GetTaskStatsFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.GetTaskStatsFunc(ctx, from, to)
+}
+
+func (m *UserRepository) GetGlobalStats(ctx context.Context) (*models.GlobalStats, error) {
+	m.record("GetGlobalStats")
+	if m.GetGlobalStatsFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.GetGlobalStatsFunc(ctx)
+}
+
+func (m *UserRepository) DeleteUser(ctx context.Context, userID uuid.UUID) error {
+	m.record("DeleteUser")
+	if m.DeleteUserFunc == nil {
+		return ErrNotConfigured
+	}
+	return m.DeleteUserFunc(ctx, userID)
+}
+
+func (m *UserRepository) UpdateUsername(ctx context.Context, userID uuid.UUID, newUsername string, expectedVersion int) error {
+	m.record("UpdateUsername")
+	if m.UpdateUsernameFunc == nil {
+		return ErrNotConfigured
+	}
+	return m.UpdateUsernameFunc(ctx, userID, newUsername, expectedVersion)
+}
+
+func (m *UserRepository) RevertTask(ctx context.Context, taskID uuid.UUID) (*models.Task, error) {
+	m.record("RevertTask")
+	if m.RevertTaskFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.RevertTaskFunc(ctx, taskID)
+}
+
+func (m *UserRepository) GetPeriodLeaderboard(ctx context.Context, from, to time.Time, limit, offset int) ([]*models.PeriodLeaderboardEntry, int, error) {
+	m.record("GetPeriodLeaderboard")
+	if m.GetPeriodLeaderboardFunc == nil {
+		return nil, 0, ErrNotConfigured
+	}
+	return m.GetPeriodLeaderboardFunc(ctx, from, to, limit, offset)
+}
+
+func (m *UserRepository) GetCredentials(ctx context.Context, username string) (uuid.UUID, string, error) {
+	m.record("GetCredentials")
+	if m.GetCredentialsFunc == nil {
+		return uuid.Nil, "", ErrNotConfigured
+	}
+	return m.GetCredentialsFunc(ctx, username)
+}
+
+func (m *UserRepository) RecordLogin(ctx context.Context, userID uuid.UUID, now time.Time) (int, int, error) {
+	m.record("RecordLogin")
+	if m.RecordLoginFunc == nil {
+		return 0, 0, ErrNotConfigured
+	}
+	return m.RecordLoginFunc(ctx, userID, now)
+}
+
+func (m *UserRepository) ImportUsers(ctx context.Context, users []models.ImportUserRequest) (int, error) {
+	m.record("ImportUsers")
+	if m.ImportUsersFunc == nil {
+		return 0, ErrNotConfigured
+	}
+	return m.ImportUsersFunc(ctx, users)
+}
+
+func (m *UserRepository) GetUserByUsername(ctx context.Context, username string) (*models.PublicProfile, error) {
+	m.record("GetUserByUsername")
+	if m.GetUserByUsernameFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.GetUserByUsernameFunc(ctx, username)
+}
+
+func (m *UserRepository) GetUserByReferralCode(ctx context.Context, code string) (*models.User, error) {
+	m.record("GetUserByReferralCode")
+	if m.GetUserByReferralCodeFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.GetUserByReferralCodeFunc(ctx, code)
+}
+
+func (m *UserRepository) GetReferrerChain(ctx context.Context, userID uuid.UUID, maxDepth int) ([]*models.ReferrerChainEntry, error) {
+	m.record("GetReferrerChain")
+	if m.GetReferrerChainFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.GetReferrerChainFunc(ctx, userID, maxDepth)
+}
+
+func (m *UserRepository) AdjustPoints(ctx context.Context, adminID, userID uuid.UUID, delta int, reason string) (*models.PointAdjustment, error) {
+	m.record("AdjustPoints")
+	if m.AdjustPointsFunc == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.AdjustPointsFunc(ctx, adminID, userID, delta, reason)
+}
+
+func (m *UserRepository) GetUserRank(ctx context.Context, userID uuid.UUID) (int, error) {
+	m.record("GetUserRank")
+	if m.GetUserRankFunc == nil {
+		return 0, ErrNotConfigured
+	}
+	return m.GetUserRankFunc(ctx, userID)
+}
+
+func (m *UserRepository) GetPointsToNextRank(ctx context.Context, userID uuid.UUID) (int, error) {
+	m.record("GetPointsToNextRank")
+	if m.GetPointsToNextRankFunc == nil {
+		return 0, ErrNotConfigured
+	}
+	return m.GetPointsToNextRankFunc(ctx, userID)
+}
+
+func (m *UserRepository) GetUserStanding(ctx context.Context, userID uuid.UUID) (int, int, error) {
+	m.record("GetUserStanding")
+	if m.GetUserStandingFunc == nil {
+		return 0, 0, ErrNotConfigured
+	}
+	return m.GetUserStandingFunc(ctx, userID)
+}
+
+func (m *UserRepository) CountReferrals(ctx context.Context, userID uuid.UUID) (int, error) {
+	m.record("CountReferrals")
+	if m.CountReferralsFunc == nil {
+		return 0, ErrNotConfigured
+	}
+	return m.CountReferralsFunc(ctx, userID)
+}
+
+func (m *UserRepository) ListUsers(ctx context.Context, filter models.UserListFilter, limit, offset int) ([]*models.User, int, error) {
+	m.record("ListUsers")
+	if m.ListUsersFunc == nil {
+		return nil, 0, ErrNotConfigured
+	}
+	return m.ListUsersFunc(ctx, filter, limit, offset)
+}
+
+func (m *UserRepository) RegenerateReferralCode(ctx context.Context, userID uuid.UUID) (string, error) {
+	m.record("RegenerateReferralCode")
+	if m.RegenerateReferralCodeFunc == nil {
+		return "", ErrNotConfigured
+	}
+	return m.RegenerateReferralCodeFunc(ctx, userID)
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/logger"
+	"github.com/google/uuid"
+)
+
+// Notifier отправляет уведомления о событиях внешним системам.
+// Notify не должен блокировать вызывающего: доставка выполняется асинхронно.
+type Notifier interface {
+	Notify(ctx context.Context, event string, payload any) bool
+}
+
+// notifyTaskCompleted уведомляет о баллах, начисленных за задание
+func (s *UserService) notifyTaskCompleted(ctx context.Context, task *models.Task) {
+	if s.notifier == nil {
+		return
+	}
+
+	s.notifier.Notify(ctx, models.EventTaskCompleted, models.PointsEvent{
+		Event:         models.EventTaskCompleted,
+		UserID:        task.UserID,
+		TaskID:        &task.ID,
+		TaskType:      task.TaskType,
+		PointsDelta:   task.Points,
+		Balance:       task.Balance,
+		OccurredAt:    task.CompletedAt,
+		CorrelationID: s.correlationID(ctx),
+	})
+}
+
+// notifyReferralCredited уведомляет каждого реферера цепочки о начисленном бонусе
+func (s *UserService) notifyReferralCredited(ctx context.Context, referredID uuid.UUID, credits []models.ReferralCredit) {
+	if s.notifier == nil {
+		return
+	}
+
+	now := time.Now()
+	for _, credit := range credits {
+		s.notifier.Notify(ctx, models.EventReferralCredited, models.PointsEvent{
+			Event:          models.EventReferralCredited,
+			UserID:         credit.UserID,
+			ReferredUserID: &referredID,
+			Level:          credit.Level,
+			PointsDelta:    credit.Points,
+			Balance:        credit.Balance,
+			OccurredAt:     now,
+			CorrelationID:  s.correlationID(ctx),
+		})
+	}
+}
+
+// correlationID возвращает идентификатор исходного запроса для событий, если его передача включена
+func (s *UserService) correlationID(ctx context.Context) string {
+	if !s.opts.CorrelationID {
+		return ""
+	}
+	return logger.RequestIDFromContext(ctx)
+}
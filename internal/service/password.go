@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/logger"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/validate"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// hashPassword возвращает bcrypt хеш пароля
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return string(hash), nil
+}
+
+// checkPassword сравнивает пароль с bcrypt хешем
+func checkPassword(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// ChangePassword меняет пароль пользователя после проверки текущего пароля и истории паролей
+func (s *UserService) ChangePassword(ctx context.Context, userID uuid.UUID, currentPassword, newPassword string) error {
+	log := logger.FromContext(ctx, s.log)
+	log.Info("Changing password", zap.String("user_id", userID.String()))
+
+	if err := validate.Password("new_password", newPassword, s.opts.MinPasswordLength); err != nil {
+		log.Warn("Weak new password", zap.String("user_id", userID.String()), zap.Error(err))
+		return err
+	}
+
+	currentHash, err := s.repo.GetPasswordHash(ctx, userID)
+	if err != nil {
+		log.Error("Failed to get password hash",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return err
+	}
+
+	if !checkPassword(currentHash, currentPassword) {
+		log.Warn("Current password mismatch", zap.String("user_id", userID.String()))
+		return models.ErrInvalidPassword
+	}
+
+	if err := s.checkPasswordReuse(ctx, userID, currentHash, newPassword); err != nil {
+		return err
+	}
+
+	newHash, err := hashPassword(newPassword)
+	if err != nil {
+		log.Error("Failed to hash password", zap.String("user_id", userID.String()), zap.Error(err))
+		return err
+	}
+
+	if err := s.repo.UpdatePassword(ctx, userID, newHash, s.opts.PasswordHistory); err != nil {
+		if !errors.Is(err, models.ErrUserNotFound) {
+			log.Error("Failed to update password",
+				zap.String("user_id", userID.String()),
+				zap.Error(err))
+		}
+		return err
+	}
+
+	log.Info("Password changed successfully", zap.String("user_id", userID.String()))
+	return nil
+}
+
+// checkPasswordReuse проверяет, что новый пароль не совпадает с текущим и недавними.
+// Возвращает models.ErrPasswordReused при совпадении.
+func (s *UserService) checkPasswordReuse(ctx context.Context, userID uuid.UUID, currentHash, newPassword string) error {
+	log := logger.FromContext(ctx, s.log)
+
+	recent := []string{currentHash}
+	if s.opts.PasswordHistory > 0 {
+		history, err := s.repo.GetPasswordHistory(ctx, userID, s.opts.PasswordHistory)
+		if err != nil {
+			log.Error("Failed to get password history",
+				zap.String("user_id", userID.String()),
+				zap.Error(err))
+			return err
+		}
+		recent = append(recent, history...)
+	}
+
+	for _, hash := range recent {
+		if checkPassword(hash, newPassword) {
+			log.Warn("Password reuse rejected", zap.String("user_id", userID.String()))
+			return models.ErrPasswordReused
+		}
+	}
+	return nil
+}
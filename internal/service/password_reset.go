@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/logger"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/validate"
+	"go.uber.org/zap"
+)
+
+// DefaultPasswordResetTTL срок действия токена сброса пароля, если он не задан в Options
+const DefaultPasswordResetTTL = time.Hour
+
+// resetTokenBytes количество случайных байт токена сброса пароля
+const resetTokenBytes = 32
+
+// newResetToken генерирует случайный токен сброса пароля
+func newResetToken() (string, error) {
+	raw := make([]byte, resetTokenBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("failed to generate reset token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// hashResetToken возвращает хеш токена, под которым он хранится в репозитории.
+// Сам токен не сохраняется, поэтому утечка таблицы не позволяет сбросить пароль.
+func hashResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// RequestPasswordReset выдает одноразовый токен сброса пароля пользователю с указанным
+// именем или адресом электронной почты и отправляет событие models.EventPasswordResetRequested
+// для доставки токена. Отсутствие пользователя не считается ошибкой, чтобы ответ
+// не раскрывал существование учетных записей.
+func (s *UserService) RequestPasswordReset(ctx context.Context, login string) error {
+	log := logger.FromContext(ctx, s.log)
+	log.Info("Requesting password reset")
+
+	login = strings.TrimSpace(login)
+	user, err := s.repo.GetUserByLogin(ctx, login)
+	if err != nil {
+		if errors.Is(err, models.ErrUserNotFound) {
+			log.Info("Password reset requested for unknown login")
+			return nil
+		}
+		log.Error("Failed to find user for password reset", zap.Error(err))
+		return err
+	}
+
+	token, err := newResetToken()
+	if err != nil {
+		log.Error("Failed to generate reset token", zap.Error(err))
+		return err
+	}
+	now := time.Now()
+	expiresAt := now.Add(s.opts.PasswordResetTTL)
+	if err := s.repo.CreatePasswordResetToken(ctx, user.ID, hashResetToken(token), expiresAt); err != nil {
+		return err
+	}
+
+	if s.notifier == nil || !s.notifier.Notify(ctx, models.EventPasswordResetRequested, models.PasswordResetEvent{
+		Event:      models.EventPasswordResetRequested,
+		UserID:     user.ID,
+		Username:   user.Username,
+		Email:      user.Email,
+		Token:      token,
+		ExpiresAt:  expiresAt,
+		OccurredAt: now,
+	}) {
+		log.Warn("Password reset token was not queued for delivery", zap.String("user_id", user.ID.String()))
+	}
+
+	log.Info("Password reset token issued", zap.String("user_id", user.ID.String()))
+	return nil
+}
+
+// ConfirmPasswordReset устанавливает новый пароль по токену сброса. Токен действует один раз:
+// после успешного сброса он и остальные выданные пользователю токены аннулируются.
+// Возвращает models.ErrInvalidResetToken для неизвестного, истекшего или использованного токена.
+func (s *UserService) ConfirmPasswordReset(ctx context.Context, token, newPassword string) error {
+	log := logger.FromContext(ctx, s.log)
+	log.Info("Confirming password reset")
+
+	if err := validate.Password("new_password", newPassword, s.opts.MinPasswordLength); err != nil {
+		log.Warn("Weak new password", zap.Error(err))
+		return err
+	}
+
+	tokenHash := hashResetToken(token)
+	userID, err := s.repo.GetPasswordResetToken(ctx, tokenHash, time.Now())
+	if err != nil {
+		if !errors.Is(err, models.ErrInvalidResetToken) {
+			log.Error("Failed to get password reset token", zap.Error(err))
+		}
+		return err
+	}
+
+	currentHash, err := s.repo.GetPasswordHash(ctx, userID)
+	if err != nil {
+		if errors.Is(err, models.ErrUserNotFound) {
+			return models.ErrInvalidResetToken
+		}
+		log.Error("Failed to get password hash", zap.String("user_id", userID.String()), zap.Error(err))
+		return err
+	}
+	if err := s.checkPasswordReuse(ctx, userID, currentHash, newPassword); err != nil {
+		return err
+	}
+
+	newHash, err := hashPassword(newPassword)
+	if err != nil {
+		log.Error("Failed to hash password", zap.String("user_id", userID.String()), zap.Error(err))
+		return err
+	}
+
+	// Токен повторно проверяется при использовании: за время проверки его мог использовать другой запрос
+	userID, err = s.repo.ResetPassword(ctx, tokenHash, newHash, s.opts.PasswordHistory, time.Now())
+	if err != nil {
+		if errors.Is(err, models.ErrUserNotFound) {
+			return models.ErrInvalidResetToken
+		}
+		return err
+	}
+	s.recordAudit(ctx, userID, models.AuditPasswordReset, userID, nil)
+
+	log.Info("Password reset successfully", zap.String("user_id", userID.String()))
+	return nil
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// DefaultPointsExpiryInterval период списания сгоревших баллов по умолчанию
+const DefaultPointsExpiryInterval = time.Minute
+
+// PointsExpiryRepository журнал начислений со сроком действия: баллы за задания и реферальные
+// бонусы сгорают через заданный срок, если не были потрачены раньше. Реализуется обоими хранилищами.
+type PointsExpiryRepository interface {
+	// ExpirePoints списывает с балансов непотраченные остатки начислений, срок которых истек,
+	// и возвращает количество пользователей, чей баланс уменьшился
+	ExpirePoints(ctx context.Context) (int, error)
+}
+
+// RunPointsExpiry списывает сгоревшие баллы сразу и затем каждые Options.PointsExpiryInterval
+// до отмены ctx, чтобы баланс пользователей и таблица лидеров не учитывали истекшие начисления.
+// Без Options.PointsExpiry возвращается сразу. Ошибка списания только логируется: сгоревшие
+// баллы будут списаны при следующем запуске.
+func (s *UserService) RunPointsExpiry(ctx context.Context) {
+	if s.opts.PointsExpiry == nil {
+		return
+	}
+
+	ticker := time.NewTicker(s.opts.PointsExpiryInterval)
+	defer ticker.Stop()
+
+	for {
+		s.expirePoints(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// expirePoints выполняет одно списание сгоревших баллов
+func (s *UserService) expirePoints(ctx context.Context) {
+	expireCtx, cancel := context.WithTimeout(ctx, s.opts.PointsExpiryInterval)
+	defer cancel()
+
+	start := time.Now()
+	users, err := s.opts.PointsExpiry.ExpirePoints(expireCtx)
+	if err != nil {
+		if ctx.Err() == nil {
+			s.log.Error("Failed to expire points", zap.Error(err))
+		}
+		return
+	}
+	if users == 0 {
+		return
+	}
+	s.leaderboardChanged()
+
+	s.log.Info("Expired points written off",
+		zap.Int("users", users),
+		zap.Duration("duration", time.Since(start)))
+}
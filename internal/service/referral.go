@@ -0,0 +1,31 @@
+package service
+
+import (
+	"context"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/logger"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// GetReferrerChain возвращает цепочку рефереров пользователя глубиной не более depth уровней
+func (s *UserService) GetReferrerChain(ctx context.Context, userID uuid.UUID, depth int) ([]*models.ReferrerChainEntry, error) {
+	log := logger.FromContext(ctx, s.log)
+	log.Info("Getting referrer chain",
+		zap.String("user_id", userID.String()),
+		zap.Int("depth", depth))
+
+	chain, err := s.repo.GetReferrerChain(ctx, userID, depth)
+	if err != nil {
+		log.Error("Failed to get referrer chain",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, err
+	}
+
+	log.Debug("Referrer chain retrieved successfully",
+		zap.String("user_id", userID.String()),
+		zap.Int("chain_length", len(chain)))
+	return chain, nil
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/logger"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// DefaultSummaryRecentTasks количество последних заданий в сводке, если Options.SummaryRecentTasks не задан
+const DefaultSummaryRecentTasks = 5
+
+// GetUserSummary собирает сводку профиля: пользователя, место и перцентиль в таблице лидеров, количество
+// рефералов и последние Options.SummaryRecentTasks заданий. Части запрашиваются параллельно;
+// ошибка любой из них отменяет остальные и возвращается целиком, без частичной сводки.
+func (s *UserService) GetUserSummary(ctx context.Context, userID uuid.UUID) (*models.UserSummary, error) {
+	log := logger.FromContext(ctx, s.log)
+	log.Info("Getting user summary", zap.String("user_id", userID.String()))
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		summary  models.UserSummary
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	// Каждая часть записывает свое поле summary, поэтому общая блокировка не нужна
+	run := func(part string, fn func() error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := fn(); err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+				if !errors.Is(err, context.Canceled) {
+					log.Debug("User summary part failed", zap.String("part", part), zap.Error(err))
+				}
+			}
+		}()
+	}
+
+	run("user", func() (err error) {
+		summary.User, err = s.repo.GetUserByID(ctx, userID)
+		return err
+	})
+	run("rank", func() (err error) {
+		summary.Rank, err = s.repo.GetUserRank(ctx, userID)
+		return err
+	})
+	run("percentile", func() error {
+		ahead, total, err := s.repo.GetUserStanding(ctx, userID)
+		if err != nil {
+			return err
+		}
+		summary.Percentile = percentile(ahead, total)
+		return nil
+	})
+	run("referrals", func() (err error) {
+		summary.ReferralCount, err = s.repo.CountReferrals(ctx, userID)
+		return err
+	})
+	run("recent_tasks", func() (err error) {
+		summary.RecentTasks, err = s.repo.GetUserTasks(ctx, userID, "", s.opts.SummaryRecentTasks, 0)
+		return err
+	})
+	wg.Wait()
+
+	if firstErr != nil {
+		if errors.Is(firstErr, models.ErrUserNotFound) {
+			log.Warn("User not found", zap.String("user_id", userID.String()))
+			return nil, firstErr
+		}
+		log.Error("Failed to get user summary",
+			zap.String("user_id", userID.String()),
+			zap.Error(firstErr))
+		return nil, firstErr
+	}
+	if summary.RecentTasks == nil {
+		summary.RecentTasks = []*models.Task{}
+	}
+
+	log.Debug("User summary retrieved successfully",
+		zap.String("user_id", userID.String()),
+		zap.Int("rank", summary.Rank),
+		zap.Float64("percentile", summary.Percentile))
+	return &summary, nil
+}
+
+// percentile возвращает, в какой верхней доле таблицы лидеров (в процентах, с точностью до
+// сотых) находится пользователь, которого по балансу опережают ahead из total пользователей.
+// Место считается с учетом равенства баланса: равные пользователи делят лучшее из своих мест.
+func percentile(ahead, total int) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return math.Round(float64(ahead+1)/float64(total)*10000) / 100
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/logger"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/validate"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// ImportTasks импортирует выполненные задания по одной записи, не накапливая ввод.
+// next заполняет очередную запись и возвращает io.EOF в конце ввода; ошибка валидации
+// из next относится только к этой записи, любая другая ошибка ввода завершает импорт.
+// emit получает результат каждой записи сразу после ее обработки.
+//
+// Каждое задание создается в отдельной транзакции, поэтому отклоненная запись не отменяет
+// уже импортированные. Баллы начисляются без проверок антифрода и уведомлений, но не больше
+// Options.MaxPointsPerTask за задание. Записи сверх Options.TaskImportMax отклоняются, и ввод
+// дальше не читается. Ошибка возвращается вместе с итогом, если импорт прерван ошибкой
+// хранилища или emit.
+func (s *UserService) ImportTasks(ctx context.Context, adminID uuid.UUID, next func(*models.ImportTaskRequest) error, emit func(*models.ImportTaskResult) error) (*models.ImportTasksSummary, error) {
+	log := logger.FromContext(ctx, s.log)
+	log.Info("Importing tasks", zap.String("admin_id", adminID.String()))
+
+	summary := &models.ImportTasksSummary{}
+	defer func() {
+		if summary.Imported > 0 {
+			s.leaderboardChanged()
+		}
+		s.recordAudit(ctx, adminID, models.AuditTasksImported, adminID, map[string]any{
+			"imported": summary.Imported,
+			"failed":   summary.Failed,
+		})
+	}()
+
+	for line := 1; ; line++ {
+		var record models.ImportTaskRequest
+		readErr := next(&record)
+		if errors.Is(readErr, io.EOF) {
+			break
+		}
+
+		result := &models.ImportTaskResult{Line: line, Status: models.ImportStatusFailed}
+		var stop bool
+		var storeErr error
+		if _, invalid := validate.As(readErr); readErr != nil && !invalid {
+			// Границы следующих записей после нераспознанной неизвестны
+			result.Error = readErr.Error()
+			stop = true
+		} else if s.opts.TaskImportMax > 0 && line > s.opts.TaskImportMax {
+			result.Error = fmt.Sprintf("import is limited to %d records", s.opts.TaskImportMax)
+			stop = true
+		} else {
+			storeErr = s.importTask(ctx, &record, readErr, result)
+			stop = storeErr != nil
+		}
+
+		if result.Status == models.ImportStatusImported {
+			summary.Imported++
+		} else {
+			summary.Failed++
+		}
+		if err := emit(result); err != nil {
+			log.Warn("Failed to send task import result", zap.Int("line", line), zap.Error(err))
+			return summary, err
+		}
+		if storeErr != nil {
+			log.Error("Task import aborted", zap.Int("line", line), zap.Error(storeErr))
+			return summary, storeErr
+		}
+		if stop {
+			log.Warn("Task import stopped", zap.Int("line", line), zap.String("reason", result.Error))
+			break
+		}
+	}
+
+	log.Info("Tasks imported",
+		zap.Int("imported", summary.Imported),
+		zap.Int("failed", summary.Failed))
+	return summary, nil
+}
+
+// importTask проверяет запись импорта и создает задание, заполняя result. decodeErr -
+// ошибка валидации, полученная при разборе записи. Ошибки записи (валидация, неизвестный
+// пользователь, выход баланса за границы) отражаются только в result; возвращается ошибка
+// хранилища, после которой импорт не продолжается.
+func (s *UserService) importTask(ctx context.Context, record *models.ImportTaskRequest, decodeErr error, result *models.ImportTaskResult) error {
+	var limitErr error
+	if s.opts.MaxPointsPerTask > 0 && record.Points > s.opts.MaxPointsPerTask {
+		limitErr = &validate.FieldError{Field: "points", Message: fmt.Sprintf("must be at most %d", s.opts.MaxPointsPerTask)}
+	}
+	if err := validate.Join(decodeErr, validate.StructAll(record), limitErr); err != nil {
+		fieldErrs, _ := validate.As(err)
+		result.Error = fieldErrs[0].Message
+		for _, fieldErr := range fieldErrs {
+			if fieldErr.Field == "" {
+				continue
+			}
+			if result.Fields == nil {
+				result.Fields = make(map[string]string, len(fieldErrs))
+			}
+			result.Fields[fieldErr.Field] = fieldErr.Message
+		}
+		return nil
+	}
+
+	userID := uuid.MustParse(record.UserID)
+	task, err := s.repo.CompleteTask(ctx, userID, models.TaskRequest{TaskType: record.TaskType, Points: record.Points}, models.TaskActivityLimit{})
+	switch {
+	case errors.Is(err, models.ErrUserNotFound):
+		result.Error = "user not found"
+		result.Fields = map[string]string{"user_id": "user not found"}
+		return nil
+	case errors.Is(err, models.ErrPointsOutOfRange):
+		result.Error = "points out of range"
+		result.Fields = map[string]string{"points": "points out of range"}
+		return nil
+	case err != nil:
+		result.Error = "failed to import task"
+		return err
+	}
+
+	result.Status = models.ImportStatusImported
+	result.TaskID = &task.ID
+	return nil
+}
@@ -2,8 +2,17 @@ package service
 
 import (
 	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/broadcast"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/inflight"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/logger"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/signedtoken"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/validate"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
 )
@@ -11,98 +20,400 @@ import (
 // UserRepository интерфейс для доступа к данным пользователей
 type UserRepository interface {
 	GetUserByID(ctx context.Context, id uuid.UUID) (*models.User, error)
-	GetLeaderboard(ctx context.Context, limit int) ([]*models.User, error)
-	CompleteTask(ctx context.Context, userID uuid.UUID, taskRequest models.TaskRequest) (*models.Task, error)
-	AddReferrer(ctx context.Context, userID, referrerID uuid.UUID) (*models.User, error)
-	LoginUser(ctx context.Context, username string, password string) (*models.User, error)
+	GetUsersByIDs(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]*models.User, error)
+	GetLeaderboard(ctx context.Context, filter models.LeaderboardFilter, limit, offset int) ([]*models.User, int, error)
+	CompleteTask(ctx context.Context, userID uuid.UUID, taskRequest models.TaskRequest, limit models.TaskActivityLimit) (*models.Task, error)
+	AddReferrer(ctx context.Context, userID, referrerID uuid.UUID) (*models.User, []models.ReferralCredit, error)
+	RemoveReferrer(ctx context.Context, userID uuid.UUID) (uuid.UUID, []models.ReferralCredit, error)
+	LoginUser(ctx context.Context, username string, password string, email *string) (*models.User, error)
+	VerifyEmail(ctx context.Context, userID uuid.UUID, email string, at time.Time) error
+	UsernameExists(ctx context.Context, username string) (bool, error)
+	GetPasswordHash(ctx context.Context, userID uuid.UUID) (string, error)
+	GetPasswordHistory(ctx context.Context, userID uuid.UUID, limit int) ([]string, error)
+	UpdatePassword(ctx context.Context, userID uuid.UUID, newHash string, historyLimit int) error
+	GetUserByLogin(ctx context.Context, login string) (*models.User, error)
+	CreatePasswordResetToken(ctx context.Context, userID uuid.UUID, tokenHash string, expiresAt time.Time) error
+	GetPasswordResetToken(ctx context.Context, tokenHash string, now time.Time) (uuid.UUID, error)
+	ResetPassword(ctx context.Context, tokenHash, newHash string, historyLimit int, now time.Time) (uuid.UUID, error)
+	TransferPoints(ctx context.Context, fromID, toID uuid.UUID, amount int) (int, error)
+	GetUserTasks(ctx context.Context, userID uuid.UUID, taskType string, limit, offset int) ([]*models.Task, error)
+	GetTaskStats(ctx context.Context, from, to *time.Time) ([]*models.TaskStats, error)
+	GetGlobalStats(ctx context.Context) (*models.GlobalStats, error)
+	DeleteUser(ctx context.Context, userID uuid.UUID) error
+	UpdateUsername(ctx context.Context, userID uuid.UUID, newUsername string, expectedVersion int) error
+	RevertTask(ctx context.Context, taskID uuid.UUID) (*models.Task, error)
+	GetPeriodLeaderboard(ctx context.Context, from, to time.Time, limit, offset int) ([]*models.PeriodLeaderboardEntry, int, error)
+	GetCredentials(ctx context.Context, username string) (uuid.UUID, string, error)
+	RecordLogin(ctx context.Context, userID uuid.UUID, now time.Time) (int, int, error)
+	ImportUsers(ctx context.Context, users []models.ImportUserRequest) (int, error)
+	GetUserByUsername(ctx context.Context, username string) (*models.PublicProfile, error)
+	GetUserByReferralCode(ctx context.Context, code string) (*models.User, error)
+	RegenerateReferralCode(ctx context.Context, userID uuid.UUID) (string, error)
+	GetReferrerChain(ctx context.Context, userID uuid.UUID, maxDepth int) ([]*models.ReferrerChainEntry, error)
+	AdjustPoints(ctx context.Context, adminID, userID uuid.UUID, delta int, reason string) (*models.PointAdjustment, error)
+	GetUserRank(ctx context.Context, userID uuid.UUID) (int, error)
+	GetPointsToNextRank(ctx context.Context, userID uuid.UUID) (int, error)
+	GetUserStanding(ctx context.Context, userID uuid.UUID) (int, int, error)
+	CountReferrals(ctx context.Context, userID uuid.UUID) (int, error)
+	ListUsers(ctx context.Context, filter models.UserListFilter, limit, offset int) ([]*models.User, int, error)
+}
+
+// Options содержит настраиваемые параметры UserService
+type Options struct {
+	// ReservedUsernames список имен, недоступных для регистрации
+	ReservedUsernames []string
+	// PasswordHistory количество предыдущих паролей, которые нельзя использовать повторно
+	PasswordHistory int
+	// MinPasswordLength минимальная длина пароля
+	MinPasswordLength int
+	// UsernameCase форма сохраняемого имени: models.UsernameCasePreserve (по умолчанию)
+	// или models.UsernameCaseLower
+	UsernameCase string
+	// LeaderboardCacheTTL время жизни кеша таблицы лидеров (0 - без кеширования)
+	LeaderboardCacheTTL time.Duration
+	// StatsCacheTTL время жизни кеша общих показателей (0 - без кеширования)
+	StatsCacheTTL time.Duration
+	// LeaderboardStreamMaxSubscribers максимальное число одновременных подписчиков
+	// на изменения таблицы лидеров (0 - без ограничения)
+	LeaderboardStreamMaxSubscribers int
+	// LeaderboardView материализованное представление, из которого читается таблица лидеров,
+	// пока оно не устарело (nil - всегда читается таблица пользователей)
+	LeaderboardView LeaderboardViewRepository
+	// LeaderboardViewRefreshInterval период пересчета представления
+	LeaderboardViewRefreshInterval time.Duration
+	// LeaderboardViewMaxStaleness возраст представления, после которого таблица лидеров
+	// читается из таблицы пользователей
+	LeaderboardViewMaxStaleness time.Duration
+	// PointsExpiry журнал начислений со сроком действия, из которого периодически списываются
+	// сгоревшие баллы (nil - баллы не сгорают)
+	PointsExpiry PointsExpiryRepository
+	// PointsExpiryInterval период списания сгоревших баллов
+	PointsExpiryInterval time.Duration
+	// ImportMaxBatch максимальное количество пользователей в одном запросе импорта
+	ImportMaxBatch int
+	// TaskImportMax максимальное количество записей в одном потоковом импорте заданий (0 - без ограничения)
+	TaskImportMax int
+	// MaxPointsPerTask максимальное количество баллов за одно задание (0 - без ограничения)
+	MaxPointsPerTask int
+	// AntiCheatMaxTasks количество заданий за окно AntiCheatWindow, после которого
+	// активность пользователя считается подозрительной (0 - проверка отключена)
+	AntiCheatMaxTasks int
+	// AntiCheatWindow окно подсчета выполненных заданий
+	AntiCheatWindow time.Duration
+	// AntiCheatReject отклонять задания сверх лимита, а не только записывать их в лог
+	AntiCheatReject bool
+	// Background учитывает фоновые операции сервиса для ожидания при остановке
+	Background *inflight.Tracker
+	// Audit журнал аудита чувствительных операций (nil - аудит не ведется)
+	Audit AuditRepository
+	// Notifier получает события о начислении баллов (nil - уведомления отключены)
+	Notifier Notifier
+	// CorrelationID заполнять в событиях о начислении баллов идентификатор исходного запроса
+	CorrelationID bool
+	// EmailTokens подписывает токены подтверждения адреса электронной почты
+	// (nil - случайный ключ, токены перестают действовать после перезапуска)
+	EmailTokens *signedtoken.Signer
+	// EmailVerificationTTL срок действия токена подтверждения адреса
+	EmailVerificationTTL time.Duration
+	// PasswordResetTTL срок действия токена сброса пароля
+	PasswordResetTTL time.Duration
+	// SummaryRecentTasks количество последних заданий в сводке профиля
+	SummaryRecentTasks int
+	// PointsToNextRank заполнять в статусе пользователя количество баллов до места выше
+	PointsToNextRank bool
 }
 
 // UserService предоставляет методы для работы с пользователями
 type UserService struct {
-	repo UserRepository
-	log  *zap.Logger
+	repo        UserRepository
+	opts        Options
+	reserved    map[string]struct{}
+	leaderboard *leaderboardCache
+	stats       *statsCache
+	// leaderboardUpdates сигналы об изменении таблицы лидеров для потоковых подписчиков
+	leaderboardUpdates *broadcast.Broadcaster
+	// leaderboardViewRefreshedAt время начала последнего пересчета представления в наносекундах Unix
+	leaderboardViewRefreshedAt atomic.Int64
+	background                 *inflight.Tracker
+	audit                      AuditRepository
+	notifier                   Notifier
+	log                        *zap.Logger
 }
 
 // NewUserService создает новый экземпляр UserService
-func NewUserService(repo UserRepository, log *zap.Logger) *UserService {
+func NewUserService(repo UserRepository, opts Options, log *zap.Logger) *UserService {
+	reserved := make(map[string]struct{}, len(opts.ReservedUsernames))
+	for _, name := range opts.ReservedUsernames {
+		reserved[NormalizeUsername(name)] = struct{}{}
+	}
+
+	background := opts.Background
+	if background == nil {
+		background = inflight.New()
+	}
+	if opts.EmailTokens == nil {
+		opts.EmailTokens = signedtoken.New(randomKey(), emailVerificationPurpose)
+	}
+	if opts.EmailVerificationTTL <= 0 {
+		opts.EmailVerificationTTL = DefaultEmailVerificationTTL
+	}
+	if opts.PasswordResetTTL <= 0 {
+		opts.PasswordResetTTL = DefaultPasswordResetTTL
+	}
+	if opts.SummaryRecentTasks <= 0 {
+		opts.SummaryRecentTasks = DefaultSummaryRecentTasks
+	}
+	if opts.LeaderboardViewRefreshInterval <= 0 {
+		opts.LeaderboardViewRefreshInterval = DefaultLeaderboardViewRefreshInterval
+	}
+	if opts.LeaderboardViewMaxStaleness <= 0 {
+		opts.LeaderboardViewMaxStaleness = DefaultLeaderboardViewMaxStaleness
+	}
+	if opts.PointsExpiryInterval <= 0 {
+		opts.PointsExpiryInterval = DefaultPointsExpiryInterval
+	}
+
 	return &UserService{
-		repo: repo,
-		log:  log.Named("user_service"),
+		repo:               repo,
+		opts:               opts,
+		reserved:           reserved,
+		leaderboard:        newLeaderboardCache(opts.LeaderboardCacheTTL),
+		stats:              &statsCache{ttl: opts.StatsCacheTTL},
+		leaderboardUpdates: broadcast.New(opts.LeaderboardStreamMaxSubscribers),
+		background:         background,
+		audit:              opts.Audit,
+		notifier:           opts.Notifier,
+		log:                log.Named("user_service"),
+	}
+}
+
+// NormalizeUsername приводит имя пользователя к каноничному виду
+func NormalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+// canonicalUsername возвращает имя в форме, в которой оно сохраняется, согласно Options.UsernameCase
+func (s *UserService) canonicalUsername(username string) string {
+	if s.opts.UsernameCase == models.UsernameCaseLower {
+		return strings.ToLower(username)
+	}
+	return username
+}
+
+// IsReservedUsername проверяет, входит ли имя в список зарезервированных (без учета регистра)
+func (s *UserService) IsReservedUsername(username string) bool {
+	_, ok := s.reserved[NormalizeUsername(username)]
+	return ok
+}
+
+// IsUsernameAvailable проверяет, свободно ли имя пользователя для регистрации
+func (s *UserService) IsUsernameAvailable(ctx context.Context, username string) (bool, error) {
+	log := logger.FromContext(ctx, s.log)
+	normalized := NormalizeUsername(username)
+	log.Info("Checking username availability", zap.String("username", normalized))
+
+	if normalized == "" || s.IsReservedUsername(normalized) {
+		return false, nil
+	}
+
+	exists, err := s.repo.UsernameExists(ctx, normalized)
+	if err != nil {
+		log.Error("Failed to check username availability",
+			zap.String("username", normalized),
+			zap.Error(err))
+		return false, err
 	}
+
+	return !exists, nil
 }
 
-// LoginUser регистрирует пользователя
-func (s *UserService) LoginUser(context context.Context, username string, password string) (*models.User, error) {
-	s.log.Info("Logging in user", zap.String("username", username))
-	user, err := s.repo.LoginUser(context, username, password)
+// LoginUser регистрирует пользователя; пустой email означает, что адрес не указан.
+// Нарушения правил для имени, пароля и адреса возвращаются вместе как validate.FieldErrors.
+// Возвращает models.ErrUsernameTaken, если имя занято без учета регистра.
+func (s *UserService) LoginUser(ctx context.Context, username string, password string, email string) (*models.User, error) {
+	log := logger.FromContext(ctx, s.log)
+	log.Info("Logging in user", zap.String("username", username))
+
+	// Проверяются все поля, чтобы клиент получил все нарушения одним ответом
+	var emailErr error
+	if email = strings.TrimSpace(email); email != "" {
+		emailErr = validate.Email(email)
+	}
+	if err := validate.Join(
+		validate.Username(username),
+		validate.Password("password", password, s.opts.MinPasswordLength),
+		emailErr,
+	); err != nil {
+		log.Warn("Invalid registration data", zap.String("username", username), zap.Error(err))
+		return nil, err
+	}
+	if s.IsReservedUsername(username) {
+		log.Warn("Username is reserved", zap.String("username", username))
+		return nil, models.ErrUsernameReserved
+	}
+	username = s.canonicalUsername(username)
+	var emailPtr *string
+	if email != "" {
+		emailPtr = &email
+	}
+
+	hash, err := hashPassword(password)
 	if err != nil {
-		s.log.Error("Failed to login user", zap.String("username", username), zap.Error(err))
+		log.Error("Failed to hash password", zap.String("username", username), zap.Error(err))
+		return nil, err
+	}
+
+	user, err := s.repo.LoginUser(ctx, username, hash, emailPtr)
+	if err != nil {
+		if errors.Is(err, models.ErrEmailTaken) {
+			log.Warn("Email already taken", zap.String("username", username))
+			return nil, err
+		}
+		if errors.Is(err, models.ErrUsernameTaken) {
+			log.Warn("Username already taken", zap.String("username", username))
+			return nil, err
+		}
+		log.Error("Failed to login user", zap.String("username", username), zap.Error(err))
 		return nil, err
 	}
 
 	return user, nil
 }
 
-// GetUserByID возвращает пользователя по ID
+// GetUserByID возвращает пользователя по ID или models.ErrUserNotFound, если его нет
 func (s *UserService) GetUserByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
-	s.log.Info("Getting user by ID", zap.String("user_id", id.String()))
+	log := logger.FromContext(ctx, s.log)
+	log.Info("Getting user by ID", zap.String("user_id", id.String()))
 
 	user, err := s.repo.GetUserByID(ctx, id)
+	if err == nil && user == nil {
+		// Отсутствие пользователя без ошибки не передается вызывающему как (nil, nil)
+		err = models.ErrUserNotFound
+	}
 	if err != nil {
-		s.log.Error("Failed to get user by ID",
+		if errors.Is(err, models.ErrUserNotFound) {
+			log.Warn("User not found", zap.String("user_id", id.String()))
+			return nil, err
+		}
+		log.Error("Failed to get user by ID",
 			zap.String("user_id", id.String()),
 			zap.Error(err))
 		return nil, err
 	}
 
-	if user == nil {
-		s.log.Warn("User not found", zap.String("user_id", id.String()))
-		return nil, nil
-	}
-
-	s.log.Debug("User retrieved successfully",
+	log.Debug("User retrieved successfully",
 		zap.String("user_id", id.String()),
 		zap.String("username", user.Username),
 		zap.Int("points", user.Points))
 	return user, nil
 }
 
-// GetLeaderboard возвращает список пользователей с наибольшим балансом
-func (s *UserService) GetLeaderboard(ctx context.Context, limit int) ([]*models.User, error) {
-	s.log.Info("Getting leaderboard", zap.Int("limit", limit))
+// GetUserStatus возвращает пользователя по ID для статуса; при Options.PointsToNextRank
+// в нем заполняется количество баллов до места выше
+func (s *UserService) GetUserStatus(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	user, err := s.GetUserByID(ctx, id)
+	if err != nil || !s.opts.PointsToNextRank {
+		return user, err
+	}
+
+	log := logger.FromContext(ctx, s.log)
+	gap, err := s.repo.GetPointsToNextRank(ctx, id)
+	if err != nil {
+		if !errors.Is(err, models.ErrUserNotFound) {
+			log.Error("Failed to get points to next rank",
+				zap.String("user_id", id.String()),
+				zap.Error(err))
+		}
+		return nil, err
+	}
+	user.PointsToNextRank = &gap
+
+	log.Debug("Points to next rank retrieved",
+		zap.String("user_id", id.String()),
+		zap.Int("points_to_next_rank", gap))
+	return user, nil
+}
+
+// GetLeaderboard возвращает страницу пользователей с наибольшим балансом, подходящих под filter,
+// и общее количество таких пользователей. Кешируются только страницы без фильтров.
+func (s *UserService) GetLeaderboard(ctx context.Context, filter models.LeaderboardFilter, limit, offset int) ([]*models.User, int, error) {
+	log := logger.FromContext(ctx, s.log)
+	log.Info("Getting leaderboard", zap.Int("limit", limit), zap.Int("offset", offset))
+
+	if filter.MinPoints != nil && filter.MaxPoints != nil && *filter.MinPoints > *filter.MaxPoints {
+		log.Warn("Invalid points range",
+			zap.Int("min_points", *filter.MinPoints),
+			zap.Int("max_points", *filter.MaxPoints))
+		return nil, 0, &validate.FieldError{Field: "min_points", Message: "must not be greater than max_points"}
+	}
+
+	cacheable := filter == models.LeaderboardFilter{}
+	key := leaderboardKey{limit: limit, offset: offset}
+	if cacheable {
+		if users, total, ok := s.leaderboard.get(key); ok {
+			log.Debug("Leaderboard served from cache",
+				zap.Int("limit", limit),
+				zap.Int("offset", offset))
+			return users, total, nil
+		}
+	}
 
-	users, err := s.repo.GetLeaderboard(ctx, limit)
+	// Представление используется, пока оно не устарело; иначе страница строится по таблице пользователей
+	getLeaderboard := s.repo.GetLeaderboard
+	if _, fresh := s.LeaderboardViewRefreshedAt(); fresh {
+		getLeaderboard = s.opts.LeaderboardView.GetLeaderboardView
+	} else if s.opts.LeaderboardView != nil {
+		log.Debug("Leaderboard view is stale, reading users table")
+	}
+
+	users, total, err := getLeaderboard(ctx, filter, limit, offset)
 	if err != nil {
-		s.log.Error("Failed to get leaderboard",
+		log.Error("Failed to get leaderboard",
 			zap.Int("limit", limit),
+			zap.Int("offset", offset),
 			zap.Error(err))
-		return nil, err
+		return nil, 0, err
+	}
+	if cacheable {
+		s.leaderboard.set(key, users, total)
 	}
 
-	s.log.Debug("Leaderboard retrieved successfully",
+	log.Debug("Leaderboard retrieved successfully",
 		zap.Int("limit", limit),
-		zap.Int("users_count", len(users)))
-	return users, nil
+		zap.Int("offset", offset),
+		zap.Int("users_count", len(users)),
+		zap.Int("total", total))
+	return users, total, nil
 }
 
 // CompleteTask отмечает задание как выполненное и начисляет баллы
 func (s *UserService) CompleteTask(ctx context.Context, userID uuid.UUID, taskRequest models.TaskRequest) (*models.Task, error) {
-	s.log.Info("Completing task",
+	log := logger.FromContext(ctx, s.log)
+	log.Info("Completing task",
 		zap.String("user_id", userID.String()),
 		zap.String("task_type", taskRequest.TaskType),
 		zap.Int("points", taskRequest.Points))
 
-	task, err := s.repo.CompleteTask(ctx, userID, taskRequest)
+	if err := s.checkTaskPoints(ctx, userID, taskRequest); err != nil {
+		return nil, err
+	}
+
+	task, err := s.repo.CompleteTask(ctx, userID, taskRequest, s.taskActivityLimit(ctx, userID, taskRequest))
+	if errors.Is(err, models.ErrTooManyTasks) {
+		return nil, err
+	}
 	if err != nil {
-		s.log.Error("Failed to complete task",
+		log.Error("Failed to complete task",
 			zap.String("user_id", userID.String()),
 			zap.String("task_type", taskRequest.TaskType),
 			zap.Int("points", taskRequest.Points),
 			zap.Error(err))
 		return nil, err
 	}
+	s.leaderboardChanged()
+	s.notifyTaskCompleted(ctx, task)
 
-	s.log.Info("Task completed successfully",
+	log.Info("Task completed successfully",
 		zap.String("user_id", userID.String()),
 		zap.String("task_id", task.ID.String()),
 		zap.String("task_type", task.TaskType),
@@ -110,24 +421,127 @@ func (s *UserService) CompleteTask(ctx context.Context, userID uuid.UUID, taskRe
 	return task, nil
 }
 
-// AddReferrer добавляет реферальный код
+// AddReferrer добавляет реферальный код. Повторный запрос с тем же реферером (например,
+// повтор клиента после обрыва соединения) не считается ошибкой: бонусы повторно не
+// начисляются, и возвращается текущий пользователь. Другой реферер отклоняется с
+// models.ErrReferrerAlreadySet.
 func (s *UserService) AddReferrer(ctx context.Context, userID, referrerID uuid.UUID) (*models.User, error) {
-	s.log.Info("Adding referrer",
+	log := logger.FromContext(ctx, s.log)
+	log.Info("Adding referrer",
 		zap.String("user_id", userID.String()),
 		zap.String("referrer_id", referrerID.String()))
 
-	user, err := s.repo.AddReferrer(ctx, userID, referrerID)
+	user, credits, err := s.repo.AddReferrer(ctx, userID, referrerID)
+	if errors.Is(err, models.ErrReferrerUnchanged) {
+		log.Info("Referrer is already set, returning current user",
+			zap.String("user_id", userID.String()),
+			zap.String("referrer_id", referrerID.String()))
+		return s.repo.GetUserByID(ctx, userID)
+	}
 	if err != nil {
-		s.log.Error("Failed to add referrer",
+		log.Error("Failed to add referrer",
 			zap.String("user_id", userID.String()),
 			zap.String("referrer_id", referrerID.String()),
 			zap.Error(err))
 		return nil, err
 	}
+	s.leaderboardChanged()
+	s.recordAudit(ctx, userID, models.AuditReferrerAdded, userID, map[string]any{
+		"referrer_id": referrerID.String(),
+	})
+	s.notifyReferralCredited(ctx, userID, credits)
 
-	s.log.Info("Referrer added successfully",
+	log.Info("Referrer added successfully",
 		zap.String("user_id", userID.String()),
 		zap.String("referrer_id", referrerID.String()),
 		zap.Int("user_points", user.Points))
 	return user, nil
 }
+
+// RemoveReferrer сбрасывает реферера пользователя, отменяя начисленные за приглашение бонусы,
+// и возвращает обновленного пользователя. Отмена записывается в журнал аудита.
+func (s *UserService) RemoveReferrer(ctx context.Context, userID uuid.UUID) (*models.User, error) {
+	log := logger.FromContext(ctx, s.log)
+	log.Info("Removing referrer", zap.String("user_id", userID.String()))
+
+	referrerID, reversals, err := s.repo.RemoveReferrer(ctx, userID)
+	if err != nil {
+		if errors.Is(err, models.ErrReferrerNotSet) {
+			log.Warn("User has no referrer", zap.String("user_id", userID.String()))
+			return nil, err
+		}
+		log.Error("Failed to remove referrer",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, err
+	}
+	s.leaderboardChanged()
+
+	reversed := make([]map[string]any, 0, len(reversals))
+	for _, reversal := range reversals {
+		reversed = append(reversed, map[string]any{
+			"user_id":      reversal.UserID.String(),
+			"level":        reversal.Level,
+			"points_delta": reversal.Points,
+		})
+	}
+	s.recordAudit(ctx, userID, models.AuditReferrerRemoved, userID, map[string]any{
+		"referrer_id": referrerID.String(),
+		"reversed":    reversed,
+	})
+
+	log.Info("Referrer removed successfully",
+		zap.String("user_id", userID.String()),
+		zap.String("referrer_id", referrerID.String()),
+		zap.Int("reversed_credits", len(reversals)))
+	return s.repo.GetUserByID(ctx, userID)
+}
+
+// TransferPoints переводит баллы другому пользователю и возвращает новый баланс отправителя
+func (s *UserService) TransferPoints(ctx context.Context, fromID, toID uuid.UUID, amount int) (int, error) {
+	log := logger.FromContext(ctx, s.log)
+	log.Info("Transferring points",
+		zap.String("from_id", fromID.String()),
+		zap.String("to_id", toID.String()),
+		zap.Int("amount", amount))
+
+	balance, err := s.repo.TransferPoints(ctx, fromID, toID, amount)
+	if err != nil {
+		log.Error("Failed to transfer points",
+			zap.String("from_id", fromID.String()),
+			zap.String("to_id", toID.String()),
+			zap.Int("amount", amount),
+			zap.Error(err))
+		return 0, err
+	}
+	s.leaderboardChanged()
+
+	log.Info("Points transferred successfully",
+		zap.String("from_id", fromID.String()),
+		zap.String("to_id", toID.String()),
+		zap.Int("balance", balance))
+	return balance, nil
+}
+
+// GetUserTasks возвращает историю выполненных пользователем заданий
+func (s *UserService) GetUserTasks(ctx context.Context, userID uuid.UUID, taskType string, limit, offset int) ([]*models.Task, error) {
+	log := logger.FromContext(ctx, s.log)
+	log.Info("Getting user tasks",
+		zap.String("user_id", userID.String()),
+		zap.String("task_type", taskType),
+		zap.Int("limit", limit),
+		zap.Int("offset", offset))
+
+	tasks, err := s.repo.GetUserTasks(ctx, userID, taskType, limit, offset)
+	if err != nil {
+		log.Error("Failed to get user tasks",
+			zap.String("user_id", userID.String()),
+			zap.Error(err))
+		return nil, err
+	}
+
+	log.Debug("User tasks retrieved successfully",
+		zap.String("user_id", userID.String()),
+		zap.Int("tasks_count", len(tasks)))
+	return tasks, nil
+}
@@ -0,0 +1,2833 @@
+package service_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DblMOKRQ/DeNet_test_task/internal/models"
+	"github.com/DblMOKRQ/DeNet_test_task/internal/service"
+	"github.com/DblMOKRQ/DeNet_test_task/internal/service/mocks"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/broadcast"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/logger"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/signedtoken"
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/validate"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var errDatabase = errors.New("connection refused")
+
+func newMockService(repo *mocks.UserRepository, opts service.Options) *service.UserService {
+	return service.NewUserService(repo, opts, zap.NewNop())
+}
+
+// testHash возвращает bcrypt хеш пароля с минимальной стоимостью
+func testHash(t *testing.T, password string) string {
+	t.Helper()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("hash password: %v", err)
+	}
+	return string(hash)
+}
+
+func TestIsUsernameAvailable(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		want     bool
+		// wantChecked имя, переданное в хранилище; пусто, если хранилище не вызывается
+		wantChecked string
+	}{
+		{name: "available", username: "bob", want: true, wantChecked: "bob"},
+		{name: "taken", username: "alice", want: false, wantChecked: "alice"},
+		{name: "taken after normalization", username: "  Alice ", want: false, wantChecked: "alice"},
+		{name: "reserved", username: "admin", want: false},
+		{name: "reserved in another case", username: "ROOT", want: false},
+		{name: "empty", username: "   ", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var checked string
+			repo := &mocks.UserRepository{
+				UsernameExistsFunc: func(_ context.Context, username string) (bool, error) {
+					checked = username
+					return username == "alice", nil
+				},
+			}
+			s := newMockService(repo, service.Options{ReservedUsernames: []string{"Admin", "root"}})
+
+			got, err := s.IsUsernameAvailable(context.Background(), tt.username)
+			if err != nil {
+				t.Fatalf("IsUsernameAvailable() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("IsUsernameAvailable(%q) = %t, want %t", tt.username, got, tt.want)
+			}
+			if checked != tt.wantChecked {
+				t.Errorf("repository checked %q, want %q", checked, tt.wantChecked)
+			}
+		})
+	}
+}
+
+func TestIsUsernameAvailableRepositoryError(t *testing.T) {
+	repo := &mocks.UserRepository{
+		UsernameExistsFunc: func(context.Context, string) (bool, error) {
+			return false, errDatabase
+		},
+	}
+
+	if _, err := newMockService(repo, service.Options{}).IsUsernameAvailable(context.Background(), "bob"); !errors.Is(err, errDatabase) {
+		t.Errorf("IsUsernameAvailable() error = %v, want %v", err, errDatabase)
+	}
+}
+
+func TestLoginUser(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("success", func(t *testing.T) {
+		var gotHash string
+		repo := &mocks.UserRepository{
+			LoginUserFunc: func(_ context.Context, username, hash string, _ *string) (*models.User, error) {
+				gotHash = hash
+				return &models.User{ID: uuid.New(), Username: username}, nil
+			},
+		}
+
+		user, err := newMockService(repo, service.Options{}).LoginUser(ctx, "alice", "password123", "")
+		if err != nil {
+			t.Fatalf("LoginUser() error = %v", err)
+		}
+		if user.Username != "alice" {
+			t.Errorf("username = %q, want %q", user.Username, "alice")
+		}
+		if gotHash == "password123" {
+			t.Fatal("repository got the plain password")
+		}
+		if err := bcrypt.CompareHashAndPassword([]byte(gotHash), []byte("password123")); err != nil {
+			t.Errorf("repository got hash %q that does not match the password: %v", gotHash, err)
+		}
+	})
+
+	invalid := []struct {
+		name      string
+		username  string
+		password  string
+		email     string
+		wantField string
+	}{
+		{name: "short username", username: "ab", password: "password123", wantField: "username"},
+		{name: "invalid characters", username: "bob!", password: "password123", wantField: "username"},
+		{name: "short password", username: "alice", password: "12345", wantField: "password"},
+		{name: "invalid email", username: "alice", password: "password123", email: "alice@", wantField: "email"},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &mocks.UserRepository{}
+
+			_, err := newMockService(repo, service.Options{MinPasswordLength: 6}).LoginUser(ctx, tt.username, tt.password, tt.email)
+			var fieldErr *validate.FieldError
+			if !errors.As(err, &fieldErr) || fieldErr.Field != tt.wantField {
+				t.Fatalf("LoginUser() error = %v, want a %s field error", err, tt.wantField)
+			}
+			if n := repo.Calls("LoginUser"); n != 0 {
+				t.Errorf("repository called %d times, want 0", n)
+			}
+		})
+	}
+
+	t.Run("reserved username", func(t *testing.T) {
+		repo := &mocks.UserRepository{
+			LoginUserFunc: func(_ context.Context, username, _ string, _ *string) (*models.User, error) {
+				return &models.User{ID: uuid.New(), Username: username}, nil
+			},
+		}
+		s := newMockService(repo, service.Options{ReservedUsernames: []string{"admin", "Root"}})
+
+		for _, username := range []string{"admin", "ADMIN", "root"} {
+			if _, err := s.LoginUser(ctx, username, "password123", ""); !errors.Is(err, models.ErrUsernameReserved) {
+				t.Errorf("LoginUser(%q) error = %v, want %v", username, err, models.ErrUsernameReserved)
+			}
+		}
+		if n := repo.Calls("LoginUser"); n != 0 {
+			t.Errorf("repository called %d times for reserved names, want 0", n)
+		}
+
+		user, err := s.LoginUser(ctx, "administrator", "password123", "")
+		if err != nil || user.Username != "administrator" {
+			t.Errorf("LoginUser(administrator) = %+v, %v, want the user registered", user, err)
+		}
+	})
+
+	t.Run("all invalid fields", func(t *testing.T) {
+		repo := &mocks.UserRepository{}
+
+		_, err := newMockService(repo, service.Options{MinPasswordLength: 8}).LoginUser(ctx, "a!", "1234567", "alice@")
+		var fieldErrs validate.FieldErrors
+		if !errors.As(err, &fieldErrs) {
+			t.Fatalf("LoginUser() error = %v, want validate.FieldErrors", err)
+		}
+		var fields []string
+		for _, fieldErr := range fieldErrs {
+			fields = append(fields, fieldErr.Field)
+		}
+		if strings.Join(fields, ",") != "username,password,email" {
+			t.Errorf("LoginUser() reported fields %v, want username, password and email", fields)
+		}
+		if n := repo.Calls("LoginUser"); n != 0 {
+			t.Errorf("repository called %d times, want 0", n)
+		}
+	})
+
+	t.Run("repository error", func(t *testing.T) {
+		repo := &mocks.UserRepository{
+			LoginUserFunc: func(context.Context, string, string, *string) (*models.User, error) {
+				return nil, errDatabase
+			},
+		}
+
+		user, err := newMockService(repo, service.Options{}).LoginUser(ctx, "alice", "password123", "")
+		if !errors.Is(err, errDatabase) || user != nil {
+			t.Errorf("LoginUser() = %v, %v, want nil, %v", user, err, errDatabase)
+		}
+	})
+
+	t.Run("email", func(t *testing.T) {
+		var gotEmail *string
+		repo := &mocks.UserRepository{
+			LoginUserFunc: func(_ context.Context, username, _ string, email *string) (*models.User, error) {
+				gotEmail = email
+				return &models.User{ID: uuid.New(), Username: username, Email: email}, nil
+			},
+		}
+		s := newMockService(repo, service.Options{})
+
+		if _, err := s.LoginUser(ctx, "alice", "password123", "  alice@example.com "); err != nil {
+			t.Fatalf("LoginUser() error = %v", err)
+		}
+		if gotEmail == nil || *gotEmail != "alice@example.com" {
+			t.Errorf("repository got email %v, want the trimmed address", gotEmail)
+		}
+
+		if _, err := s.LoginUser(ctx, "bob", "password123", "   "); err != nil {
+			t.Fatalf("LoginUser(blank email) error = %v", err)
+		}
+		if gotEmail != nil {
+			t.Errorf("repository got email %q for a blank address, want nil", *gotEmail)
+		}
+	})
+
+	t.Run("email taken", func(t *testing.T) {
+		repo := &mocks.UserRepository{
+			LoginUserFunc: func(context.Context, string, string, *string) (*models.User, error) {
+				return nil, models.ErrEmailTaken
+			},
+		}
+
+		if _, err := newMockService(repo, service.Options{}).LoginUser(ctx, "alice", "password123", "alice@example.com"); !errors.Is(err, models.ErrEmailTaken) {
+			t.Errorf("LoginUser() error = %v, want %v", err, models.ErrEmailTaken)
+		}
+	})
+
+	for _, tt := range []struct {
+		usernameCase string
+		wantStored   string
+	}{
+		{usernameCase: "", wantStored: "Alice"},
+		{usernameCase: models.UsernameCasePreserve, wantStored: "Alice"},
+		{usernameCase: models.UsernameCaseLower, wantStored: "alice"},
+	} {
+		t.Run("username case "+tt.usernameCase, func(t *testing.T) {
+			// Хранилище с уникальностью имени без учета регистра, как индекс по lower(username)
+			var stored []string
+			repo := &mocks.UserRepository{
+				LoginUserFunc: func(_ context.Context, username, _ string, _ *string) (*models.User, error) {
+					for _, name := range stored {
+						if strings.EqualFold(name, username) {
+							return nil, models.ErrUsernameTaken
+						}
+					}
+					stored = append(stored, username)
+					return &models.User{ID: uuid.New(), Username: username}, nil
+				},
+			}
+			s := newMockService(repo, service.Options{UsernameCase: tt.usernameCase})
+
+			user, err := s.LoginUser(ctx, "Alice", "password123", "")
+			if err != nil {
+				t.Fatalf("LoginUser(Alice) error = %v", err)
+			}
+			if user.Username != tt.wantStored {
+				t.Errorf("LoginUser(Alice) username = %q, want %q", user.Username, tt.wantStored)
+			}
+			if _, err := s.LoginUser(ctx, "alice", "password123", ""); !errors.Is(err, models.ErrUsernameTaken) {
+				t.Errorf("LoginUser(alice) after Alice error = %v, want %v", err, models.ErrUsernameTaken)
+			}
+		})
+	}
+}
+
+func TestRequestEmailVerification(t *testing.T) {
+	ctx := context.Background()
+	email := "alice@example.com"
+	verifiedAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	users := map[string]*models.User{
+		"unverified": {ID: uuid.New(), Email: &email},
+		"no email":   {ID: uuid.New()},
+		"verified":   {ID: uuid.New(), Email: &email, EmailVerifiedAt: &verifiedAt},
+	}
+
+	repo := &mocks.UserRepository{
+		GetUserByIDFunc: func(_ context.Context, id uuid.UUID) (*models.User, error) {
+			for _, user := range users {
+				if user.ID == id {
+					return user, nil
+				}
+			}
+			return nil, models.ErrUserNotFound
+		},
+	}
+	signer := service.NewEmailTokenSigner([]byte("test-key"))
+	notifier := &mocks.Notifier{}
+	s := newMockService(repo, service.Options{EmailTokens: signer, EmailVerificationTTL: time.Hour, Notifier: notifier})
+
+	for _, tt := range []struct {
+		user string
+		want error
+	}{
+		{user: "no email", want: models.ErrEmailNotSet},
+		{user: "verified", want: models.ErrEmailAlreadyVerified},
+	} {
+		if _, err := s.RequestEmailVerification(ctx, users[tt.user].ID); !errors.Is(err, tt.want) {
+			t.Errorf("RequestEmailVerification(%s) error = %v, want %v", tt.user, err, tt.want)
+		}
+	}
+	if _, err := s.RequestEmailVerification(ctx, uuid.New()); !errors.Is(err, models.ErrUserNotFound) {
+		t.Errorf("RequestEmailVerification(unknown) error = %v, want %v", err, models.ErrUserNotFound)
+	}
+	if n := len(notifier.Notifications()); n != 0 {
+		t.Errorf("notifications = %d, want none for rejected requests", n)
+	}
+
+	user := users["unverified"]
+	start := time.Now()
+	got, err := s.RequestEmailVerification(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("RequestEmailVerification() error = %v", err)
+	}
+	if got.Email != email || got.ExpiresAt.Before(start.Add(time.Hour)) || got.ExpiresAt.After(time.Now().Add(time.Hour)) {
+		t.Errorf("response = %+v, want %s expiring in an hour", got, email)
+	}
+	subject, value, err := signer.Verify(got.Token, time.Now())
+	if err != nil || subject != user.ID.String() || value != email {
+		t.Errorf("token = %q, %q, %v, want it signed for %s and %s", subject, value, err, user.ID, email)
+	}
+
+	notifications := notifier.Notifications()
+	if len(notifications) != 1 || notifications[0].Event != models.EventEmailVerificationRequested {
+		t.Fatalf("notifications = %+v, want one %s", notifications, models.EventEmailVerificationRequested)
+	}
+	event := notifications[0].Payload.(models.EmailVerificationEvent)
+	if event.UserID != user.ID || event.Email != email || event.Token != got.Token || !event.ExpiresAt.Equal(got.ExpiresAt) {
+		t.Errorf("event = %+v, want the issued token for %s", event, email)
+	}
+}
+
+func TestVerifyEmail(t *testing.T) {
+	ctx := context.Background()
+	userID := uuid.New()
+	signer := service.NewEmailTokenSigner([]byte("test-key"))
+	sign := func(t *testing.T, signer *signedtoken.Signer, subject string, expiresAt time.Time) string {
+		t.Helper()
+
+		token, err := signer.Sign(subject, "alice@example.com", expiresAt)
+		if err != nil {
+			t.Fatalf("Sign() error = %v", err)
+		}
+		return token
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		// repoErr ошибка хранилища; wantVerified адрес передается в хранилище
+		repoErr      error
+		wantVerified bool
+		wantErr      error
+	}{
+		{name: "valid", token: sign(t, signer, userID.String(), time.Now().Add(time.Hour)), wantVerified: true},
+		{name: "expired", token: sign(t, signer, userID.String(), time.Now().Add(-time.Minute)), wantErr: models.ErrInvalidVerificationToken},
+		{name: "other key", token: sign(t, service.NewEmailTokenSigner([]byte("other-key")), userID.String(), time.Now().Add(time.Hour)), wantErr: models.ErrInvalidVerificationToken},
+		{name: "invalid subject", token: sign(t, signer, "alice", time.Now().Add(time.Hour)), wantErr: models.ErrInvalidVerificationToken},
+		{name: "malformed", token: "not-a-token", wantErr: models.ErrInvalidVerificationToken},
+		{name: "address changed", token: sign(t, signer, userID.String(), time.Now().Add(time.Hour)),
+			repoErr: models.ErrInvalidVerificationToken, wantVerified: true, wantErr: models.ErrInvalidVerificationToken},
+		{name: "repository error", token: sign(t, signer, userID.String(), time.Now().Add(time.Hour)),
+			repoErr: errDatabase, wantVerified: true, wantErr: errDatabase},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotID uuid.UUID
+			var gotEmail string
+			repo := &mocks.UserRepository{
+				VerifyEmailFunc: func(_ context.Context, id uuid.UUID, email string, _ time.Time) error {
+					gotID, gotEmail = id, email
+					return tt.repoErr
+				},
+			}
+
+			err := newMockService(repo, service.Options{EmailTokens: signer}).VerifyEmail(ctx, tt.token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("VerifyEmail() error = %v, want %v", err, tt.wantErr)
+			}
+			if n := repo.Calls("VerifyEmail"); (n == 1) != tt.wantVerified {
+				t.Fatalf("repository called %d times, want verified %t", n, tt.wantVerified)
+			}
+			if tt.wantVerified && (gotID != userID || gotEmail != "alice@example.com") {
+				t.Errorf("repository verified %s for %s, want alice@example.com for %s", gotEmail, gotID, userID)
+			}
+		})
+	}
+}
+
+func TestGetUserByID(t *testing.T) {
+	ctx := context.Background()
+	id := uuid.New()
+
+	tests := []struct {
+		name    string
+		user    *models.User
+		repoErr error
+		wantErr error
+	}{
+		{name: "found", user: &models.User{ID: id, Username: "alice"}},
+		{name: "not found", repoErr: models.ErrUserNotFound, wantErr: models.ErrUserNotFound},
+		// Репозиторий, нарушивший контракт, не приводит к ответу (nil, nil)
+		{name: "nil user without error", wantErr: models.ErrUserNotFound},
+		{name: "repository error", repoErr: errDatabase, wantErr: errDatabase},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &mocks.UserRepository{
+				GetUserByIDFunc: func(_ context.Context, gotID uuid.UUID) (*models.User, error) {
+					if gotID != id {
+						t.Errorf("repository got id %s, want %s", gotID, id)
+					}
+					return tt.user, tt.repoErr
+				},
+			}
+
+			user, err := newMockService(repo, service.Options{}).GetUserByID(ctx, id)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) || user != nil {
+					t.Errorf("GetUserByID() = %v, %v, want nil, %v", user, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetUserByID() error = %v", err)
+			}
+			if user != tt.user {
+				t.Errorf("GetUserByID() = %+v, want %+v", user, tt.user)
+			}
+		})
+	}
+}
+
+// summaryRepository возвращает хранилище, отвечающее на все запросы сводки профиля
+func summaryRepository(userID uuid.UUID) *mocks.UserRepository {
+	return &mocks.UserRepository{
+		GetUserByIDFunc: func(context.Context, uuid.UUID) (*models.User, error) {
+			return &models.User{ID: userID, Username: "alice", Points: 30}, nil
+		},
+		GetUserRankFunc: func(context.Context, uuid.UUID) (int, error) {
+			return 2, nil
+		},
+		GetUserStandingFunc: func(context.Context, uuid.UUID) (int, int, error) {
+			return 2, 7, nil
+		},
+		CountReferralsFunc: func(context.Context, uuid.UUID) (int, error) {
+			return 4, nil
+		},
+		GetUserTasksFunc: func(_ context.Context, _ uuid.UUID, _ string, limit, _ int) ([]*models.Task, error) {
+			tasks := make([]*models.Task, limit)
+			for i := range tasks {
+				tasks[i] = &models.Task{ID: uuid.New(), UserID: userID, TaskType: "daily", Points: 10}
+			}
+			return tasks, nil
+		},
+	}
+}
+
+func TestGetUserStatus(t *testing.T) {
+	ctx := context.Background()
+	userID := uuid.New()
+	newRepo := func(gap int, gapErr error) *mocks.UserRepository {
+		return &mocks.UserRepository{
+			GetUserByIDFunc: func(_ context.Context, id uuid.UUID) (*models.User, error) {
+				return &models.User{ID: id, Username: "alice", Points: 20}, nil
+			},
+			GetPointsToNextRankFunc: func(_ context.Context, id uuid.UUID) (int, error) {
+				if id != userID {
+					t.Errorf("GetPointsToNextRank(%s), want %s", id, userID)
+				}
+				return gap, gapErr
+			},
+		}
+	}
+
+	for _, tt := range []struct {
+		name string
+		gap  int
+	}{
+		{name: "mid-pack user", gap: 11},
+		{name: "top user", gap: 0},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := newMockService(newRepo(tt.gap, nil), service.Options{PointsToNextRank: true}).GetUserStatus(ctx, userID)
+			if err != nil {
+				t.Fatalf("GetUserStatus() error = %v", err)
+			}
+			if user.PointsToNextRank == nil || *user.PointsToNextRank != tt.gap {
+				t.Errorf("PointsToNextRank = %v, want %d", user.PointsToNextRank, tt.gap)
+			}
+		})
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		repo := newRepo(11, nil)
+
+		user, err := newMockService(repo, service.Options{}).GetUserStatus(ctx, userID)
+		if err != nil {
+			t.Fatalf("GetUserStatus() error = %v", err)
+		}
+		if user.PointsToNextRank != nil || repo.Calls("GetPointsToNextRank") != 0 {
+			t.Errorf("PointsToNextRank = %v after %d queries, want nil without a query",
+				user.PointsToNextRank, repo.Calls("GetPointsToNextRank"))
+		}
+	})
+
+	t.Run("repository error", func(t *testing.T) {
+		user, err := newMockService(newRepo(0, errDatabase), service.Options{PointsToNextRank: true}).GetUserStatus(ctx, userID)
+		if !errors.Is(err, errDatabase) || user != nil {
+			t.Errorf("GetUserStatus() = %v, %v, want nil, %v", user, err, errDatabase)
+		}
+	})
+}
+
+func TestGetUserSummary(t *testing.T) {
+	ctx := context.Background()
+	userID := uuid.New()
+
+	summary, err := newMockService(summaryRepository(userID), service.Options{SummaryRecentTasks: 3}).GetUserSummary(ctx, userID)
+	if err != nil {
+		t.Fatalf("GetUserSummary() error = %v", err)
+	}
+	if summary.User == nil || summary.User.ID != userID || summary.Rank != 2 || summary.ReferralCount != 4 || len(summary.RecentTasks) != 3 {
+		t.Errorf("GetUserSummary() = %+v, want user, rank 2, 4 referrals and 3 recent tasks", summary)
+	}
+	// Двое из семи пользователей опережают: 3/7 с точностью до сотых
+	if summary.Percentile != 42.86 {
+		t.Errorf("percentile = %v, want 42.86", summary.Percentile)
+	}
+
+	t.Run("percentile", func(t *testing.T) {
+		tests := []struct {
+			name         string
+			ahead, total int
+			want         float64
+		}{
+			{name: "single user", ahead: 0, total: 1, want: 100},
+			{name: "top of many", ahead: 0, total: 20, want: 5},
+			{name: "tied for first", ahead: 0, total: 4, want: 25},
+			{name: "last", ahead: 3, total: 4, want: 100},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				repo := summaryRepository(userID)
+				repo.GetUserStandingFunc = func(context.Context, uuid.UUID) (int, int, error) {
+					return tt.ahead, tt.total, nil
+				}
+
+				summary, err := newMockService(repo, service.Options{}).GetUserSummary(ctx, userID)
+				if err != nil {
+					t.Fatalf("GetUserSummary() error = %v", err)
+				}
+				if summary.Percentile != tt.want {
+					t.Errorf("percentile = %v, want %v", summary.Percentile, tt.want)
+				}
+			})
+		}
+	})
+
+	t.Run("default recent tasks", func(t *testing.T) {
+		summary, err := newMockService(summaryRepository(userID), service.Options{}).GetUserSummary(ctx, userID)
+		if err != nil {
+			t.Fatalf("GetUserSummary() error = %v", err)
+		}
+		if len(summary.RecentTasks) != service.DefaultSummaryRecentTasks {
+			t.Errorf("recent tasks = %d, want %d", len(summary.RecentTasks), service.DefaultSummaryRecentTasks)
+		}
+	})
+
+	t.Run("no tasks is empty list", func(t *testing.T) {
+		repo := summaryRepository(userID)
+		repo.GetUserTasksFunc = func(context.Context, uuid.UUID, string, int, int) ([]*models.Task, error) {
+			return nil, nil
+		}
+
+		summary, err := newMockService(repo, service.Options{}).GetUserSummary(ctx, userID)
+		if err != nil {
+			t.Fatalf("GetUserSummary() error = %v", err)
+		}
+		if summary.RecentTasks == nil {
+			t.Error("RecentTasks = nil, want empty list")
+		}
+	})
+
+	failures := map[string]func(repo *mocks.UserRepository){
+		"user not found": func(repo *mocks.UserRepository) {
+			repo.GetUserByIDFunc = func(context.Context, uuid.UUID) (*models.User, error) { return nil, models.ErrUserNotFound }
+		},
+		"rank": func(repo *mocks.UserRepository) {
+			repo.GetUserRankFunc = func(context.Context, uuid.UUID) (int, error) { return 0, errDatabase }
+		},
+		"percentile": func(repo *mocks.UserRepository) {
+			repo.GetUserStandingFunc = func(context.Context, uuid.UUID) (int, int, error) { return 0, 0, errDatabase }
+		},
+		"referrals": func(repo *mocks.UserRepository) {
+			repo.CountReferralsFunc = func(context.Context, uuid.UUID) (int, error) { return 0, errDatabase }
+		},
+		"recent tasks": func(repo *mocks.UserRepository) {
+			repo.GetUserTasksFunc = func(context.Context, uuid.UUID, string, int, int) ([]*models.Task, error) { return nil, errDatabase }
+		},
+	}
+	for name, fail := range failures {
+		t.Run(name+" fails", func(t *testing.T) {
+			repo := summaryRepository(userID)
+			fail(repo)
+			wantErr := errDatabase
+			if name == "user not found" {
+				wantErr = models.ErrUserNotFound
+			}
+
+			summary, err := newMockService(repo, service.Options{}).GetUserSummary(ctx, userID)
+			if !errors.Is(err, wantErr) || summary != nil {
+				t.Errorf("GetUserSummary() = %+v, %v, want nil, %v", summary, err, wantErr)
+			}
+		})
+	}
+
+	t.Run("failure cancels other parts", func(t *testing.T) {
+		repo := summaryRepository(userID)
+		repo.GetUserRankFunc = func(context.Context, uuid.UUID) (int, error) { return 0, errDatabase }
+		// Запрос заданий завершается только после отмены контекста
+		repo.GetUserTasksFunc = func(ctx context.Context, _ uuid.UUID, _ string, _, _ int) ([]*models.Task, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+
+		if _, err := newMockService(repo, service.Options{}).GetUserSummary(ctx, userID); !errors.Is(err, errDatabase) {
+			t.Errorf("GetUserSummary() error = %v, want %v", err, errDatabase)
+		}
+	})
+}
+
+func TestGetLeaderboard(t *testing.T) {
+	ctx := context.Background()
+	users := []*models.User{{ID: uuid.New(), Username: "alice", Points: 20}, {ID: uuid.New(), Username: "bob", Points: 10}}
+
+	t.Run("success", func(t *testing.T) {
+		repo := &mocks.UserRepository{
+			GetLeaderboardFunc: func(_ context.Context, _ models.LeaderboardFilter, limit, offset int) ([]*models.User, int, error) {
+				if limit != 2 || offset != 1 {
+					t.Errorf("repository got limit %d offset %d, want 2 1", limit, offset)
+				}
+				return users, 3, nil
+			},
+		}
+
+		got, total, err := newMockService(repo, service.Options{}).GetLeaderboard(ctx, models.LeaderboardFilter{}, 2, 1)
+		if err != nil {
+			t.Fatalf("GetLeaderboard() error = %v", err)
+		}
+		if len(got) != len(users) || total != 3 {
+			t.Errorf("GetLeaderboard() = %d users, total %d, want %d users, total 3", len(got), total, len(users))
+		}
+	})
+
+	t.Run("repository error", func(t *testing.T) {
+		repo := &mocks.UserRepository{
+			GetLeaderboardFunc: func(context.Context, models.LeaderboardFilter, int, int) ([]*models.User, int, error) {
+				return nil, 0, errDatabase
+			},
+		}
+
+		if _, _, err := newMockService(repo, service.Options{}).GetLeaderboard(ctx, models.LeaderboardFilter{}, 10, 0); !errors.Is(err, errDatabase) {
+			t.Errorf("GetLeaderboard() error = %v, want %v", err, errDatabase)
+		}
+	})
+}
+
+func TestGetLeaderboardCache(t *testing.T) {
+	ctx := context.Background()
+	users := []*models.User{{ID: uuid.New(), Username: "alice"}}
+
+	tests := []struct {
+		name string
+		ttl  time.Duration
+		// wantCalls число обращений к хранилищу после двух одинаковых запросов
+		wantCalls int
+	}{
+		{name: "cache hit within ttl", ttl: time.Minute, wantCalls: 1},
+		{name: "cache disabled", ttl: 0, wantCalls: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &mocks.UserRepository{
+				GetLeaderboardFunc: func(context.Context, models.LeaderboardFilter, int, int) ([]*models.User, int, error) {
+					return users, len(users), nil
+				},
+			}
+			s := newMockService(repo, service.Options{LeaderboardCacheTTL: tt.ttl})
+
+			for range 2 {
+				got, total, err := s.GetLeaderboard(ctx, models.LeaderboardFilter{}, 10, 0)
+				if err != nil {
+					t.Fatalf("GetLeaderboard() error = %v", err)
+				}
+				if len(got) != 1 || total != 1 {
+					t.Fatalf("GetLeaderboard() = %d users, total %d, want 1 and 1", len(got), total)
+				}
+			}
+			if n := repo.Calls("GetLeaderboard"); n != tt.wantCalls {
+				t.Errorf("repository calls = %d, want %d", n, tt.wantCalls)
+			}
+		})
+	}
+}
+
+// leaderboardView представление таблицы лидеров для тестов; refreshErr возвращается при пересчете
+type leaderboardView struct {
+	mu         sync.Mutex
+	refreshes  int
+	reads      int
+	refreshErr error
+}
+
+func (v *leaderboardView) GetLeaderboardView(context.Context, models.LeaderboardFilter, int, int) ([]*models.User, int, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.reads++
+	return []*models.User{{ID: uuid.New(), Username: "view"}}, 1, nil
+}
+
+func (v *leaderboardView) RefreshLeaderboardView(context.Context) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.refreshes++
+	return v.refreshErr
+}
+
+// runLeaderboardViewRefresh запускает пересчет представления и ждет завершения первой попытки:
+// успешный пересчет сообщается подписчикам таблицы лидеров после сброса кеша
+func runLeaderboardViewRefresh(t *testing.T, s *service.UserService, view *leaderboardView) {
+	t.Helper()
+
+	updates, unsubscribe, err := s.SubscribeLeaderboard()
+	if err != nil {
+		t.Fatalf("SubscribeLeaderboard() error = %v", err)
+	}
+	defer unsubscribe()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.RunLeaderboardViewRefresh(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	if view.refreshErr == nil {
+		select {
+		case <-updates:
+		case <-time.After(time.Second):
+			t.Fatal("leaderboard view was not refreshed")
+		}
+		return
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		view.mu.Lock()
+		refreshes := view.refreshes
+		view.mu.Unlock()
+		if refreshes > 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("leaderboard view was not refreshed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestGetLeaderboardView(t *testing.T) {
+	ctx := context.Background()
+	newRepo := func() *mocks.UserRepository {
+		return &mocks.UserRepository{
+			GetLeaderboardFunc: func(context.Context, models.LeaderboardFilter, int, int) ([]*models.User, int, error) {
+				return []*models.User{{ID: uuid.New(), Username: "live"}}, 1, nil
+			},
+		}
+	}
+	source := func(t *testing.T, s *service.UserService) string {
+		t.Helper()
+
+		users, _, err := s.GetLeaderboard(ctx, models.LeaderboardFilter{}, 10, 0)
+		if err != nil {
+			t.Fatalf("GetLeaderboard() error = %v", err)
+		}
+		return users[0].Username
+	}
+
+	t.Run("not refreshed yet", func(t *testing.T) {
+		view := &leaderboardView{}
+		s := newMockService(newRepo(), service.Options{LeaderboardView: view})
+
+		if got := source(t, s); got != "live" {
+			t.Errorf("GetLeaderboard() read %s, want the users table before the first refresh", got)
+		}
+		if _, ok := s.LeaderboardViewRefreshedAt(); ok {
+			t.Error("LeaderboardViewRefreshedAt() ok = true before the first refresh")
+		}
+	})
+
+	t.Run("fresh view", func(t *testing.T) {
+		view := &leaderboardView{}
+		repo := newRepo()
+		s := newMockService(repo, service.Options{LeaderboardView: view, LeaderboardCacheTTL: time.Minute})
+		// Страница из таблицы пользователей, закешированная до пересчета, сбрасывается им
+		if got := source(t, s); got != "live" {
+			t.Fatalf("GetLeaderboard() read %s before refresh, want live", got)
+		}
+
+		before := time.Now()
+		runLeaderboardViewRefresh(t, s, view)
+		if got := source(t, s); got != "view" {
+			t.Errorf("GetLeaderboard() read %s, want the view", got)
+		}
+		refreshedAt, ok := s.LeaderboardViewRefreshedAt()
+		if !ok || refreshedAt.Before(before) {
+			t.Errorf("LeaderboardViewRefreshedAt() = %s, %t, want a fresh time after %s", refreshedAt, ok, before)
+		}
+		if n := repo.Calls("GetLeaderboard"); n != 1 {
+			t.Errorf("users table read %d times, want 1", n)
+		}
+	})
+
+	t.Run("stale view", func(t *testing.T) {
+		view := &leaderboardView{}
+		s := newMockService(newRepo(), service.Options{
+			LeaderboardView:                view,
+			LeaderboardViewRefreshInterval: time.Hour,
+			LeaderboardViewMaxStaleness:    time.Millisecond,
+		})
+
+		runLeaderboardViewRefresh(t, s, view)
+		time.Sleep(5 * time.Millisecond)
+		if got := source(t, s); got != "live" {
+			t.Errorf("GetLeaderboard() read %s, want the users table for a stale view", got)
+		}
+		if _, ok := s.LeaderboardViewRefreshedAt(); ok {
+			t.Error("LeaderboardViewRefreshedAt() ok = true for a stale view")
+		}
+	})
+
+	t.Run("refresh error", func(t *testing.T) {
+		view := &leaderboardView{refreshErr: errDatabase}
+		s := newMockService(newRepo(), service.Options{LeaderboardView: view})
+
+		runLeaderboardViewRefresh(t, s, view)
+		if got := source(t, s); got != "live" {
+			t.Errorf("GetLeaderboard() read %s, want the users table after a failed refresh", got)
+		}
+	})
+
+	t.Run("without view", func(t *testing.T) {
+		s := newMockService(newRepo(), service.Options{})
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			s.RunLeaderboardViewRefresh(context.Background())
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("RunLeaderboardViewRefresh() without a view did not return")
+		}
+	})
+}
+
+// pointsExpiry журнал начислений для тестов: каждый вызов ExpirePoints возвращает affected и err
+// и отправляется в calls
+type pointsExpiry struct {
+	affected int
+	err      error
+	calls    chan struct{}
+}
+
+func (p *pointsExpiry) ExpirePoints(context.Context) (int, error) {
+	select {
+	case p.calls <- struct{}{}:
+	default:
+	}
+	return p.affected, p.err
+}
+
+func TestRunPointsExpiry(t *testing.T) {
+	ctx := context.Background()
+	newRepo := func() *mocks.UserRepository {
+		return &mocks.UserRepository{
+			GetLeaderboardFunc: func(context.Context, models.LeaderboardFilter, int, int) ([]*models.User, int, error) {
+				return []*models.User{{ID: uuid.New(), Username: "alice"}}, 1, nil
+			},
+		}
+	}
+	// run запускает списание и ждет wait вызовов журнала
+	run := func(t *testing.T, s *service.UserService, expiry *pointsExpiry, wait int) {
+		t.Helper()
+
+		runCtx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			s.RunPointsExpiry(runCtx)
+		}()
+		t.Cleanup(func() {
+			cancel()
+			<-done
+		})
+		for range wait {
+			select {
+			case <-expiry.calls:
+			case <-time.After(time.Second):
+				t.Fatal("expired points were not written off")
+			}
+		}
+	}
+
+	t.Run("expired points reset the leaderboard cache", func(t *testing.T) {
+		repo := newRepo()
+		expiry := &pointsExpiry{affected: 2, calls: make(chan struct{})}
+		s := newMockService(repo, service.Options{
+			PointsExpiry:         expiry,
+			PointsExpiryInterval: 10 * time.Millisecond,
+			LeaderboardCacheTTL:  time.Minute,
+		})
+		if _, _, err := s.GetLeaderboard(ctx, models.LeaderboardFilter{}, 10, 0); err != nil {
+			t.Fatalf("GetLeaderboard() error = %v", err)
+		}
+		updates, unsubscribe, err := s.SubscribeLeaderboard()
+		if err != nil {
+			t.Fatalf("SubscribeLeaderboard() error = %v", err)
+		}
+		defer unsubscribe()
+
+		// Первое списание выполняется сразу, следующие - по таймеру
+		run(t, s, expiry, 2)
+		select {
+		case <-updates:
+		case <-time.After(time.Second):
+			t.Fatal("leaderboard subscribers were not notified")
+		}
+		if _, _, err := s.GetLeaderboard(ctx, models.LeaderboardFilter{}, 10, 0); err != nil {
+			t.Fatalf("GetLeaderboard() error = %v", err)
+		}
+		if n := repo.Calls("GetLeaderboard"); n != 2 {
+			t.Errorf("leaderboard repository calls = %d, want 2 after points expired", n)
+		}
+	})
+
+	for name, expiry := range map[string]*pointsExpiry{
+		"nothing expired": {calls: make(chan struct{})},
+		// Ошибка только логируется, списание повторяется на следующем тике
+		"storage error": {err: errDatabase, calls: make(chan struct{})},
+	} {
+		t.Run(name, func(t *testing.T) {
+			repo := newRepo()
+			s := newMockService(repo, service.Options{
+				PointsExpiry:         expiry,
+				PointsExpiryInterval: 10 * time.Millisecond,
+				LeaderboardCacheTTL:  time.Minute,
+			})
+			if _, _, err := s.GetLeaderboard(ctx, models.LeaderboardFilter{}, 10, 0); err != nil {
+				t.Fatalf("GetLeaderboard() error = %v", err)
+			}
+
+			run(t, s, expiry, 2)
+			if _, _, err := s.GetLeaderboard(ctx, models.LeaderboardFilter{}, 10, 0); err != nil {
+				t.Fatalf("GetLeaderboard() error = %v", err)
+			}
+			if n := repo.Calls("GetLeaderboard"); n != 1 {
+				t.Errorf("leaderboard repository calls = %d, want 1 while the cache is kept", n)
+			}
+		})
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		s := newMockService(newRepo(), service.Options{})
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			s.RunPointsExpiry(ctx)
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("RunPointsExpiry() without a ledger did not return")
+		}
+	})
+}
+
+func TestGetGlobalStats(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		ttl       time.Duration
+		wantCalls int
+	}{
+		{name: "cache hit within ttl", ttl: time.Minute, wantCalls: 1},
+		{name: "cache disabled", ttl: 0, wantCalls: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &mocks.UserRepository{
+				GetGlobalStatsFunc: func(context.Context) (*models.GlobalStats, error) {
+					return &models.GlobalStats{TotalUsers: 3, CalculatedAt: time.Now()}, nil
+				},
+			}
+			s := newMockService(repo, service.Options{StatsCacheTTL: tt.ttl})
+
+			for range 2 {
+				stats, err := s.GetGlobalStats(ctx)
+				if err != nil {
+					t.Fatalf("GetGlobalStats() error = %v", err)
+				}
+				if stats.TotalUsers != 3 {
+					t.Fatalf("TotalUsers = %d, want 3", stats.TotalUsers)
+				}
+			}
+			if n := repo.Calls("GetGlobalStats"); n != tt.wantCalls {
+				t.Errorf("repository calls = %d, want %d", n, tt.wantCalls)
+			}
+		})
+	}
+
+	t.Run("error is not cached", func(t *testing.T) {
+		fail := true
+		repo := &mocks.UserRepository{
+			GetGlobalStatsFunc: func(context.Context) (*models.GlobalStats, error) {
+				if fail {
+					return nil, errDatabase
+				}
+				return &models.GlobalStats{TotalUsers: 3}, nil
+			},
+		}
+		s := newMockService(repo, service.Options{StatsCacheTTL: time.Minute})
+
+		if _, err := s.GetGlobalStats(ctx); !errors.Is(err, errDatabase) {
+			t.Fatalf("GetGlobalStats() error = %v, want %v", err, errDatabase)
+		}
+		fail = false
+		if stats, err := s.GetGlobalStats(ctx); err != nil || stats.TotalUsers != 3 {
+			t.Errorf("GetGlobalStats() after failure = %+v, %v, want fresh stats", stats, err)
+		}
+	})
+}
+
+func TestGetLeaderboardFilter(t *testing.T) {
+	ctx := context.Background()
+	minPoints, maxPoints := 10, 50
+
+	t.Run("filtered pages bypass the cache", func(t *testing.T) {
+		filter := models.LeaderboardFilter{MinPoints: &minPoints, MaxPoints: &maxPoints}
+		repo := &mocks.UserRepository{
+			GetLeaderboardFunc: func(_ context.Context, got models.LeaderboardFilter, _, _ int) ([]*models.User, int, error) {
+				if got != filter {
+					t.Errorf("repository got filter %+v, want %+v", got, filter)
+				}
+				return []*models.User{{ID: uuid.New(), Username: "alice", Points: 20}}, 1, nil
+			},
+		}
+		s := newMockService(repo, service.Options{LeaderboardCacheTTL: time.Minute})
+
+		for range 2 {
+			if _, _, err := s.GetLeaderboard(ctx, filter, 10, 0); err != nil {
+				t.Fatalf("GetLeaderboard() error = %v", err)
+			}
+		}
+		if n := repo.Calls("GetLeaderboard"); n != 2 {
+			t.Errorf("repository calls = %d, want 2 for filtered pages", n)
+		}
+	})
+
+	t.Run("min above max", func(t *testing.T) {
+		repo := &mocks.UserRepository{}
+		filter := models.LeaderboardFilter{MinPoints: &maxPoints, MaxPoints: &minPoints}
+
+		_, _, err := newMockService(repo, service.Options{}).GetLeaderboard(ctx, filter, 10, 0)
+		var fieldErr *validate.FieldError
+		if !errors.As(err, &fieldErr) || fieldErr.Field != "min_points" {
+			t.Errorf("GetLeaderboard() error = %v, want a min_points field error", err)
+		}
+		if n := repo.Calls("GetLeaderboard"); n != 0 {
+			t.Errorf("repository calls = %d, want 0 for an invalid range", n)
+		}
+	})
+}
+
+func TestGetLeaderboardCacheInvalidatedByPoints(t *testing.T) {
+	ctx := context.Background()
+	repo := &mocks.UserRepository{
+		GetLeaderboardFunc: func(context.Context, models.LeaderboardFilter, int, int) ([]*models.User, int, error) {
+			return []*models.User{{ID: uuid.New(), Username: "alice"}}, 1, nil
+		},
+		CompleteTaskFunc: func(_ context.Context, userID uuid.UUID, taskRequest models.TaskRequest, _ models.TaskActivityLimit) (*models.Task, error) {
+			return &models.Task{ID: uuid.New(), UserID: userID, TaskType: taskRequest.TaskType, Points: taskRequest.Points}, nil
+		},
+	}
+	s := newMockService(repo, service.Options{LeaderboardCacheTTL: time.Minute})
+
+	if _, _, err := s.GetLeaderboard(ctx, models.LeaderboardFilter{}, 10, 0); err != nil {
+		t.Fatalf("GetLeaderboard() error = %v", err)
+	}
+	if _, err := s.CompleteTask(ctx, uuid.New(), models.TaskRequest{TaskType: "daily", Points: 5}); err != nil {
+		t.Fatalf("CompleteTask() error = %v", err)
+	}
+	if _, _, err := s.GetLeaderboard(ctx, models.LeaderboardFilter{}, 10, 0); err != nil {
+		t.Fatalf("GetLeaderboard() error = %v", err)
+	}
+
+	if n := repo.Calls("GetLeaderboard"); n != 2 {
+		t.Errorf("repository calls = %d, want 2 after points changed", n)
+	}
+}
+
+func TestSubscribeLeaderboard(t *testing.T) {
+	ctx := context.Background()
+	repo := &mocks.UserRepository{
+		CompleteTaskFunc: func(_ context.Context, userID uuid.UUID, taskRequest models.TaskRequest, _ models.TaskActivityLimit) (*models.Task, error) {
+			return &models.Task{ID: uuid.New(), UserID: userID, TaskType: taskRequest.TaskType, Points: taskRequest.Points}, nil
+		},
+	}
+	s := newMockService(repo, service.Options{LeaderboardStreamMaxSubscribers: 1})
+
+	updates, unsubscribe, err := s.SubscribeLeaderboard()
+	if err != nil {
+		t.Fatalf("SubscribeLeaderboard() error = %v", err)
+	}
+	defer unsubscribe()
+	if _, _, err := s.SubscribeLeaderboard(); !errors.Is(err, broadcast.ErrTooManySubscribers) {
+		t.Errorf("SubscribeLeaderboard() over limit error = %v, want %v", err, broadcast.ErrTooManySubscribers)
+	}
+
+	if _, err := s.CompleteTask(ctx, uuid.New(), models.TaskRequest{TaskType: "daily", Points: 5}); err != nil {
+		t.Fatalf("CompleteTask() error = %v", err)
+	}
+	select {
+	case <-updates:
+	default:
+		t.Error("no signal after points changed")
+	}
+
+	s.CloseLeaderboardStreams()
+	if _, ok := <-updates; ok {
+		t.Error("signal received after CloseLeaderboardStreams, want channel closed")
+	}
+	if _, _, err := s.SubscribeLeaderboard(); !errors.Is(err, broadcast.ErrClosed) {
+		t.Errorf("SubscribeLeaderboard() after close error = %v, want %v", err, broadcast.ErrClosed)
+	}
+}
+
+func TestCompleteTask(t *testing.T) {
+	ctx := context.Background()
+	userID := uuid.New()
+	request := models.TaskRequest{TaskType: "daily", Points: 10}
+
+	t.Run("success", func(t *testing.T) {
+		repo := &mocks.UserRepository{
+			CompleteTaskFunc: func(_ context.Context, gotID uuid.UUID, taskRequest models.TaskRequest, _ models.TaskActivityLimit) (*models.Task, error) {
+				if gotID != userID || taskRequest != request {
+					t.Errorf("repository got %s %+v, want %s %+v", gotID, taskRequest, userID, request)
+				}
+				return &models.Task{ID: uuid.New(), UserID: gotID, TaskType: taskRequest.TaskType, Points: taskRequest.Points}, nil
+			},
+		}
+
+		task, err := newMockService(repo, service.Options{}).CompleteTask(ctx, userID, request)
+		if err != nil {
+			t.Fatalf("CompleteTask() error = %v", err)
+		}
+		if task.Points != 10 {
+			t.Errorf("points = %d, want 10", task.Points)
+		}
+	})
+
+	for _, repoErr := range []error{models.ErrUserNotFound, models.ErrPointsOutOfRange, errDatabase} {
+		t.Run(repoErr.Error(), func(t *testing.T) {
+			repo := &mocks.UserRepository{
+				CompleteTaskFunc: func(context.Context, uuid.UUID, models.TaskRequest, models.TaskActivityLimit) (*models.Task, error) {
+					return nil, repoErr
+				},
+			}
+
+			task, err := newMockService(repo, service.Options{}).CompleteTask(ctx, userID, request)
+			if !errors.Is(err, repoErr) || task != nil {
+				t.Errorf("CompleteTask() = %v, %v, want nil, %v", task, err, repoErr)
+			}
+		})
+	}
+}
+
+func TestCompleteTaskAntiCheat(t *testing.T) {
+	ctx := context.Background()
+	userID := uuid.New()
+	request := models.TaskRequest{TaskType: "daily", Points: 5}
+
+	tests := []struct {
+		name string
+		opts service.Options
+		// recent количество заданий пользователя в окне до текущего
+		recent int
+		// wantErr ожидаемая ошибка; wantFlagged - запись suspicious_activity в логе
+		wantErr     error
+		wantFlagged bool
+	}{
+		{name: "within the limit", opts: service.Options{AntiCheatMaxTasks: 3, AntiCheatWindow: time.Hour, AntiCheatReject: true}, recent: 2},
+		{name: "limit exceeded and rejected", opts: service.Options{AntiCheatMaxTasks: 3, AntiCheatWindow: time.Hour, AntiCheatReject: true},
+			recent: 3, wantErr: models.ErrTooManyTasks, wantFlagged: true},
+		{name: "limit exceeded and logged", opts: service.Options{AntiCheatMaxTasks: 3, AntiCheatWindow: time.Hour},
+			recent: 3, wantFlagged: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var since time.Time
+			repo := &mocks.UserRepository{
+				// Хранилище считает задания в своей транзакции и передает количество в проверку
+				CompleteTaskFunc: func(_ context.Context, gotID uuid.UUID, taskRequest models.TaskRequest, limit models.TaskActivityLimit) (*models.Task, error) {
+					if gotID != userID {
+						t.Errorf("CompleteTask() got id %s, want %s", gotID, userID)
+					}
+					if limit.Check == nil {
+						t.Fatal("CompleteTask() got no activity check")
+					}
+					since = limit.Since
+					if err := limit.Check(tt.recent); err != nil {
+						return nil, err
+					}
+					return &models.Task{ID: uuid.New(), UserID: gotID, TaskType: taskRequest.TaskType, Points: taskRequest.Points}, nil
+				},
+			}
+			core, logs := observer.New(zapcore.WarnLevel)
+			s := service.NewUserService(repo, tt.opts, zap.New(core))
+
+			before := time.Now()
+			_, err := s.CompleteTask(ctx, userID, request)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CompleteTask() error = %v, want %v", err, tt.wantErr)
+			}
+			if since.Before(before.Add(-time.Hour)) || since.After(time.Now().Add(-time.Hour)) {
+				t.Errorf("activity limit since = %s, want the start of the one-hour window", since)
+			}
+			if n := repo.Calls("CompleteTask"); n != 1 {
+				t.Errorf("CompleteTask repository calls = %d, want 1", n)
+			}
+			if tt.wantErr != nil && len(logs.FilterLevelExact(zapcore.ErrorLevel).All()) != 0 {
+				t.Error("rejected task logged as an error")
+			}
+
+			flagged := logs.FilterMessage("suspicious_activity").All()
+			if !tt.wantFlagged {
+				if len(flagged) != 0 {
+					t.Errorf("suspicious_activity logged %d times, want none", len(flagged))
+				}
+				return
+			}
+			if len(flagged) != 1 {
+				t.Fatalf("suspicious_activity logged %d times, want 1", len(flagged))
+			}
+			fields := flagged[0].ContextMap()
+			if fields["user_id"] != userID.String() || fields["tasks_in_window"] != int64(tt.recent+1) || fields["max_tasks"] != int64(3) {
+				t.Errorf("suspicious_activity fields = %v, want user_id, tasks_in_window %d and max_tasks 3", fields, tt.recent+1)
+			}
+		})
+	}
+}
+
+func TestCompleteTaskAntiCheatDisabled(t *testing.T) {
+	var limit models.TaskActivityLimit
+	repo := &mocks.UserRepository{
+		CompleteTaskFunc: func(_ context.Context, gotID uuid.UUID, taskRequest models.TaskRequest, gotLimit models.TaskActivityLimit) (*models.Task, error) {
+			limit = gotLimit
+			return &models.Task{ID: uuid.New(), UserID: gotID, TaskType: taskRequest.TaskType, Points: taskRequest.Points}, nil
+		},
+	}
+
+	if _, err := newMockService(repo, service.Options{}).CompleteTask(context.Background(), uuid.New(), models.TaskRequest{TaskType: "daily", Points: 5}); err != nil {
+		t.Fatalf("CompleteTask() error = %v", err)
+	}
+	if limit.Check != nil {
+		t.Error("CompleteTask() passed an activity check, want none when the check is disabled")
+	}
+}
+
+func TestCompleteTaskPointsCap(t *testing.T) {
+	ctx := context.Background()
+	repo := &mocks.UserRepository{
+		CompleteTaskFunc: func(_ context.Context, gotID uuid.UUID, taskRequest models.TaskRequest, _ models.TaskActivityLimit) (*models.Task, error) {
+			return &models.Task{ID: uuid.New(), UserID: gotID, TaskType: taskRequest.TaskType, Points: taskRequest.Points}, nil
+		},
+	}
+	s := newMockService(repo, service.Options{MaxPointsPerTask: 10})
+
+	if _, err := s.CompleteTask(ctx, uuid.New(), models.TaskRequest{TaskType: "daily", Points: 10}); err != nil {
+		t.Fatalf("CompleteTask() at the cap error = %v", err)
+	}
+
+	_, err := s.CompleteTask(ctx, uuid.New(), models.TaskRequest{TaskType: "daily", Points: 11})
+	var fieldErr *validate.FieldError
+	if !errors.As(err, &fieldErr) || fieldErr.Field != "points" || fieldErr.Message != "must be at most 10" {
+		t.Fatalf("CompleteTask() above the cap error = %v, want a points field error", err)
+	}
+	if n := repo.Calls("CompleteTask"); n != 1 {
+		t.Errorf("CompleteTask repository calls = %d, want 1: a capped task must not reach the repository", n)
+	}
+}
+
+func TestAddReferrer(t *testing.T) {
+	ctx := context.Background()
+	userID, referrerID := uuid.New(), uuid.New()
+	user := &models.User{ID: userID, Username: "alice", ReferrerID: &referrerID}
+
+	t.Run("success", func(t *testing.T) {
+		repo := &mocks.UserRepository{
+			AddReferrerFunc: func(_ context.Context, gotUserID, gotReferrerID uuid.UUID) (*models.User, []models.ReferralCredit, error) {
+				if gotUserID != userID || gotReferrerID != referrerID {
+					t.Errorf("repository got %s %s, want %s %s", gotUserID, gotReferrerID, userID, referrerID)
+				}
+				return user, nil, nil
+			},
+		}
+
+		got, err := newMockService(repo, service.Options{}).AddReferrer(ctx, userID, referrerID)
+		if err != nil {
+			t.Fatalf("AddReferrer() error = %v", err)
+		}
+		if got != user {
+			t.Errorf("AddReferrer() = %+v, want %+v", got, user)
+		}
+	})
+
+	t.Run("same referrer", func(t *testing.T) {
+		repo := &mocks.UserRepository{
+			AddReferrerFunc: func(context.Context, uuid.UUID, uuid.UUID) (*models.User, []models.ReferralCredit, error) {
+				return nil, nil, models.ErrReferrerUnchanged
+			},
+			GetUserByIDFunc: func(_ context.Context, id uuid.UUID) (*models.User, error) {
+				if id != userID {
+					t.Errorf("GetUserByID(%s), want %s", id, userID)
+				}
+				return user, nil
+			},
+		}
+
+		got, err := newMockService(repo, service.Options{}).AddReferrer(ctx, userID, referrerID)
+		if err != nil {
+			t.Fatalf("AddReferrer() error = %v", err)
+		}
+		if got != user {
+			t.Errorf("AddReferrer() = %+v, want the current user %+v", got, user)
+		}
+	})
+
+	for _, repoErr := range []error{models.ErrUserNotFound, models.ErrReferrerAlreadySet, models.ErrPointsOutOfRange, errDatabase} {
+		t.Run(repoErr.Error(), func(t *testing.T) {
+			repo := &mocks.UserRepository{
+				AddReferrerFunc: func(context.Context, uuid.UUID, uuid.UUID) (*models.User, []models.ReferralCredit, error) {
+					return nil, nil, repoErr
+				},
+			}
+
+			got, err := newMockService(repo, service.Options{}).AddReferrer(ctx, userID, referrerID)
+			if !errors.Is(err, repoErr) || got != nil {
+				t.Errorf("AddReferrer() = %v, %v, want nil, %v", got, err, repoErr)
+			}
+		})
+	}
+}
+
+func TestRemoveReferrer(t *testing.T) {
+	ctx := context.Background()
+	userID, referrerID, grandReferrerID := uuid.New(), uuid.New(), uuid.New()
+	user := &models.User{ID: userID, Username: "carol"}
+
+	t.Run("success", func(t *testing.T) {
+		repo := &mocks.UserRepository{
+			RemoveReferrerFunc: func(_ context.Context, id uuid.UUID) (uuid.UUID, []models.ReferralCredit, error) {
+				if id != userID {
+					t.Errorf("repository got user %s, want %s", id, userID)
+				}
+				return referrerID, []models.ReferralCredit{
+					{UserID: referrerID, Level: 1, Points: -3, Balance: 0},
+					{UserID: grandReferrerID, Level: 2, Points: -5, Balance: 10},
+				}, nil
+			},
+			GetUserByIDFunc: func(context.Context, uuid.UUID) (*models.User, error) { return user, nil },
+			GetLeaderboardFunc: func(context.Context, models.LeaderboardFilter, int, int) ([]*models.User, int, error) {
+				return []*models.User{{ID: referrerID, Username: "bob"}}, 1, nil
+			},
+		}
+		audit := &mocks.AuditRepository{}
+		s := newMockService(repo, service.Options{Audit: audit, LeaderboardCacheTTL: time.Minute})
+
+		if _, _, err := s.GetLeaderboard(ctx, models.LeaderboardFilter{}, 10, 0); err != nil {
+			t.Fatalf("GetLeaderboard() error = %v", err)
+		}
+		got, err := s.RemoveReferrer(ctx, userID)
+		if err != nil {
+			t.Fatalf("RemoveReferrer() error = %v", err)
+		}
+		if got != user {
+			t.Errorf("RemoveReferrer() = %+v, want %+v", got, user)
+		}
+
+		// Отмена бонусов меняет балансы, поэтому кэш таблицы лидеров сбрасывается
+		if _, _, err := s.GetLeaderboard(ctx, models.LeaderboardFilter{}, 10, 0); err != nil {
+			t.Fatalf("GetLeaderboard() error = %v", err)
+		}
+		if n := repo.Calls("GetLeaderboard"); n != 2 {
+			t.Errorf("leaderboard repository calls = %d, want 2 after the referrer is removed", n)
+		}
+
+		records := audit.Records()
+		if len(records) != 1 {
+			t.Fatalf("audit records = %d, want 1", len(records))
+		}
+		record := records[0]
+		if record.ActorID != userID || record.Action != models.AuditReferrerRemoved || record.TargetID != userID {
+			t.Errorf("audit record = %s %s %s, want %s %s %s", record.ActorID, record.Action, record.TargetID, userID, models.AuditReferrerRemoved, userID)
+		}
+		if record.Metadata["referrer_id"] != referrerID.String() {
+			t.Errorf("metadata[referrer_id] = %v, want %s", record.Metadata["referrer_id"], referrerID)
+		}
+		wantReversed := []map[string]any{
+			{"user_id": referrerID.String(), "level": 1, "points_delta": -3},
+			{"user_id": grandReferrerID.String(), "level": 2, "points_delta": -5},
+		}
+		if !reflect.DeepEqual(record.Metadata["reversed"], wantReversed) {
+			t.Errorf("metadata[reversed] = %v, want %v", record.Metadata["reversed"], wantReversed)
+		}
+	})
+
+	for _, repoErr := range []error{models.ErrReferrerNotSet, models.ErrUserNotFound, errDatabase} {
+		t.Run(repoErr.Error(), func(t *testing.T) {
+			repo := &mocks.UserRepository{
+				RemoveReferrerFunc: func(context.Context, uuid.UUID) (uuid.UUID, []models.ReferralCredit, error) {
+					return uuid.Nil, nil, repoErr
+				},
+			}
+			audit := &mocks.AuditRepository{}
+
+			got, err := newMockService(repo, service.Options{Audit: audit}).RemoveReferrer(ctx, userID)
+			if !errors.Is(err, repoErr) || got != nil {
+				t.Errorf("RemoveReferrer() = %v, %v, want nil, %v", got, err, repoErr)
+			}
+			if n := len(audit.Records()); n != 0 {
+				t.Errorf("audit records = %d, want none for a failed removal", n)
+			}
+		})
+	}
+}
+
+func TestTransferPoints(t *testing.T) {
+	ctx := context.Background()
+	fromID, toID := uuid.New(), uuid.New()
+
+	t.Run("success", func(t *testing.T) {
+		repo := &mocks.UserRepository{
+			TransferPointsFunc: func(_ context.Context, gotFrom, gotTo uuid.UUID, amount int) (int, error) {
+				if gotFrom != fromID || gotTo != toID || amount != 30 {
+					t.Errorf("repository got %s %s %d, want %s %s 30", gotFrom, gotTo, amount, fromID, toID)
+				}
+				return 70, nil
+			},
+		}
+
+		balance, err := newMockService(repo, service.Options{}).TransferPoints(ctx, fromID, toID, 30)
+		if err != nil {
+			t.Fatalf("TransferPoints() error = %v", err)
+		}
+		if balance != 70 {
+			t.Errorf("balance = %d, want 70", balance)
+		}
+	})
+
+	for _, repoErr := range []error{models.ErrInsufficientPoints, models.ErrUserNotFound, models.ErrPointsOutOfRange} {
+		t.Run(repoErr.Error(), func(t *testing.T) {
+			repo := &mocks.UserRepository{
+				TransferPointsFunc: func(context.Context, uuid.UUID, uuid.UUID, int) (int, error) {
+					return 0, repoErr
+				},
+			}
+
+			if _, err := newMockService(repo, service.Options{}).TransferPoints(ctx, fromID, toID, 30); !errors.Is(err, repoErr) {
+				t.Errorf("TransferPoints() error = %v, want %v", err, repoErr)
+			}
+		})
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+	ctx := context.Background()
+	userID := uuid.New()
+
+	t.Run("success invalidates leaderboard cache", func(t *testing.T) {
+		repo := &mocks.UserRepository{
+			GetLeaderboardFunc: func(context.Context, models.LeaderboardFilter, int, int) ([]*models.User, int, error) {
+				return []*models.User{{ID: userID, Username: "alice"}}, 1, nil
+			},
+			DeleteUserFunc: func(_ context.Context, gotID uuid.UUID) error {
+				if gotID != userID {
+					t.Errorf("repository got id %s, want %s", gotID, userID)
+				}
+				return nil
+			},
+		}
+		s := newMockService(repo, service.Options{LeaderboardCacheTTL: time.Minute})
+
+		if _, _, err := s.GetLeaderboard(ctx, models.LeaderboardFilter{}, 10, 0); err != nil {
+			t.Fatalf("GetLeaderboard() error = %v", err)
+		}
+		if err := s.DeleteUser(ctx, userID); err != nil {
+			t.Fatalf("DeleteUser() error = %v", err)
+		}
+		if _, _, err := s.GetLeaderboard(ctx, models.LeaderboardFilter{}, 10, 0); err != nil {
+			t.Fatalf("GetLeaderboard() error = %v", err)
+		}
+		if n := repo.Calls("GetLeaderboard"); n != 2 {
+			t.Errorf("repository calls = %d, want 2: deleted user must leave the cached leaderboard", n)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		repo := &mocks.UserRepository{
+			DeleteUserFunc: func(context.Context, uuid.UUID) error {
+				return models.ErrUserNotFound
+			},
+		}
+
+		if err := newMockService(repo, service.Options{}).DeleteUser(ctx, userID); !errors.Is(err, models.ErrUserNotFound) {
+			t.Errorf("DeleteUser() error = %v, want %v", err, models.ErrUserNotFound)
+		}
+	})
+}
+
+func TestUpdateUsername(t *testing.T) {
+	ctx := context.Background()
+	userID := uuid.New()
+
+	t.Run("success", func(t *testing.T) {
+		var gotUsername string
+		var gotVersion int
+		repo := &mocks.UserRepository{
+			UpdateUsernameFunc: func(_ context.Context, _ uuid.UUID, username string, expectedVersion int) error {
+				gotUsername, gotVersion = username, expectedVersion
+				return nil
+			},
+			GetUserByIDFunc: func(_ context.Context, id uuid.UUID) (*models.User, error) {
+				return &models.User{ID: id, Username: gotUsername}, nil
+			},
+		}
+
+		user, err := newMockService(repo, service.Options{}).UpdateUsername(ctx, userID, "carol", 3)
+		if err != nil {
+			t.Fatalf("UpdateUsername() error = %v", err)
+		}
+		if user.Username != "carol" {
+			t.Errorf("username = %q, want %q", user.Username, "carol")
+		}
+		if gotVersion != 3 {
+			t.Errorf("repository got version %d, want 3", gotVersion)
+		}
+	})
+
+	t.Run("reserved username", func(t *testing.T) {
+		repo := &mocks.UserRepository{}
+
+		_, err := newMockService(repo, service.Options{ReservedUsernames: []string{"support"}}).UpdateUsername(ctx, userID, "Support", 3)
+		if !errors.Is(err, models.ErrUsernameReserved) {
+			t.Errorf("UpdateUsername() error = %v, want %v", err, models.ErrUsernameReserved)
+		}
+		if n := repo.Calls("UpdateUsername"); n != 0 {
+			t.Errorf("repository called %d times, want 0", n)
+		}
+	})
+
+	t.Run("lower case", func(t *testing.T) {
+		var gotUsername string
+		repo := &mocks.UserRepository{
+			UpdateUsernameFunc: func(_ context.Context, _ uuid.UUID, username string, _ int) error {
+				gotUsername = username
+				return nil
+			},
+			GetUserByIDFunc: func(_ context.Context, id uuid.UUID) (*models.User, error) {
+				return &models.User{ID: id, Username: gotUsername}, nil
+			},
+		}
+
+		if _, err := newMockService(repo, service.Options{UsernameCase: models.UsernameCaseLower}).UpdateUsername(ctx, userID, "Carol", 0); err != nil {
+			t.Fatalf("UpdateUsername() error = %v", err)
+		}
+		if gotUsername != "carol" {
+			t.Errorf("repository got username %q, want %q", gotUsername, "carol")
+		}
+	})
+
+	t.Run("invalid username", func(t *testing.T) {
+		repo := &mocks.UserRepository{}
+
+		_, err := newMockService(repo, service.Options{}).UpdateUsername(ctx, userID, "a b", 0)
+		var fieldErr *validate.FieldError
+		if !errors.As(err, &fieldErr) || fieldErr.Field != "username" {
+			t.Errorf("UpdateUsername() error = %v, want username field error", err)
+		}
+		if n := repo.Calls("UpdateUsername"); n != 0 {
+			t.Errorf("repository called %d times, want 0", n)
+		}
+	})
+
+	for _, repoErr := range []error{models.ErrUsernameTaken, models.ErrUsernameUnchanged, models.ErrUserNotFound, models.ErrConflict} {
+		t.Run(repoErr.Error(), func(t *testing.T) {
+			repo := &mocks.UserRepository{
+				UpdateUsernameFunc: func(context.Context, uuid.UUID, string, int) error {
+					return repoErr
+				},
+			}
+
+			user, err := newMockService(repo, service.Options{}).UpdateUsername(ctx, userID, "carol", 1)
+			if !errors.Is(err, repoErr) || user != nil {
+				t.Errorf("UpdateUsername() = %v, %v, want nil, %v", user, err, repoErr)
+			}
+		})
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	ctx := context.Background()
+	userID := uuid.New()
+
+	tests := []struct {
+		name            string
+		history         int
+		currentPassword string
+		newPassword     string
+		wantErr         error
+		// wantField поле, для которого ожидается ошибка валидации
+		wantField string
+	}{
+		{name: "success", history: 2, currentPassword: "current-pass", newPassword: "brand-new"},
+		{name: "wrong current password", history: 2, currentPassword: "wrong", newPassword: "brand-new", wantErr: models.ErrInvalidPassword},
+		{name: "reuse of current password", history: 2, currentPassword: "current-pass", newPassword: "current-pass", wantErr: models.ErrPasswordReused},
+		{name: "weak new password", history: 2, currentPassword: "current-pass", newPassword: "short", wantField: "new_password"},
+		{name: "reuse of recent password", history: 2, currentPassword: "current-pass", newPassword: "previous", wantErr: models.ErrPasswordReused},
+		{name: "password older than history", history: 1, currentPassword: "current-pass", newPassword: "oldest-password"},
+		{name: "history disabled allows old password", history: 0, currentPassword: "current-pass", newPassword: "previous"},
+		{name: "history disabled still rejects current", history: 0, currentPassword: "current-pass", newPassword: "current-pass", wantErr: models.ErrPasswordReused},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			history := []string{testHash(t, "previous"), testHash(t, "oldest-password")}
+			currentHash := testHash(t, "current-pass")
+			var updatedHash string
+			var historyLimit int
+			repo := &mocks.UserRepository{
+				GetPasswordHashFunc: func(context.Context, uuid.UUID) (string, error) {
+					return currentHash, nil
+				},
+				GetPasswordHistoryFunc: func(_ context.Context, _ uuid.UUID, limit int) ([]string, error) {
+					return history[:min(limit, len(history))], nil
+				},
+				UpdatePasswordFunc: func(_ context.Context, _ uuid.UUID, newHash string, limit int) error {
+					updatedHash, historyLimit = newHash, limit
+					return nil
+				},
+			}
+			s := newMockService(repo, service.Options{PasswordHistory: tt.history})
+
+			err := s.ChangePassword(ctx, userID, tt.currentPassword, tt.newPassword)
+			var fieldErr *validate.FieldError
+			switch {
+			case tt.wantField != "":
+				if !errors.As(err, &fieldErr) || fieldErr.Field != tt.wantField {
+					t.Fatalf("ChangePassword() error = %v, want %s field error", err, tt.wantField)
+				}
+			case !errors.Is(err, tt.wantErr):
+				t.Fatalf("ChangePassword() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if n := repo.Calls("UpdatePassword"); n != 0 {
+					t.Errorf("password was updated %d times despite the error", n)
+				}
+				return
+			}
+			if err := bcrypt.CompareHashAndPassword([]byte(updatedHash), []byte(tt.newPassword)); err != nil {
+				t.Errorf("stored hash does not match the new password: %v", err)
+			}
+			if historyLimit != tt.history {
+				t.Errorf("history limit = %d, want %d", historyLimit, tt.history)
+			}
+		})
+	}
+}
+
+func TestRequestPasswordReset(t *testing.T) {
+	ctx := context.Background()
+	email := "alice@example.com"
+	alice := &models.User{ID: uuid.New(), Username: "alice", Email: &email}
+
+	var storedHash, gotLogin string
+	var storedExpiry time.Time
+	repo := &mocks.UserRepository{
+		GetUserByLoginFunc: func(_ context.Context, login string) (*models.User, error) {
+			gotLogin = login
+			if login != "alice" {
+				return nil, models.ErrUserNotFound
+			}
+			return alice, nil
+		},
+		CreatePasswordResetTokenFunc: func(_ context.Context, userID uuid.UUID, tokenHash string, expiresAt time.Time) error {
+			if userID != alice.ID {
+				t.Errorf("token issued for %s, want %s", userID, alice.ID)
+			}
+			storedHash, storedExpiry = tokenHash, expiresAt
+			return nil
+		},
+	}
+	notifier := &mocks.Notifier{}
+	s := newMockService(repo, service.Options{Notifier: notifier, PasswordResetTTL: 30 * time.Minute})
+
+	// Неизвестный логин не раскрывается ошибкой
+	if err := s.RequestPasswordReset(ctx, "mallory"); err != nil {
+		t.Fatalf("RequestPasswordReset(unknown) error = %v, want nil", err)
+	}
+	if n := repo.Calls("CreatePasswordResetToken"); n != 0 || len(notifier.Notifications()) != 0 {
+		t.Errorf("unknown login issued %d tokens and %d notifications, want none", n, len(notifier.Notifications()))
+	}
+
+	start := time.Now()
+	if err := s.RequestPasswordReset(ctx, "  alice "); err != nil {
+		t.Fatalf("RequestPasswordReset() error = %v", err)
+	}
+	if gotLogin != "alice" {
+		t.Errorf("repository looked up %q, want the trimmed login", gotLogin)
+	}
+	if storedExpiry.Before(start.Add(30*time.Minute)) || storedExpiry.After(time.Now().Add(30*time.Minute)) {
+		t.Errorf("token expires at %s, want in 30 minutes", storedExpiry)
+	}
+
+	notifications := notifier.Notifications()
+	if len(notifications) != 1 || notifications[0].Event != models.EventPasswordResetRequested {
+		t.Fatalf("notifications = %+v, want one %s", notifications, models.EventPasswordResetRequested)
+	}
+	event := notifications[0].Payload.(models.PasswordResetEvent)
+	if event.UserID != alice.ID || event.Username != "alice" || event.Email == nil || *event.Email != email || !event.ExpiresAt.Equal(storedExpiry) {
+		t.Errorf("event = %+v, want alice's reset token expiring at %s", event, storedExpiry)
+	}
+	// В хранилище попадает только хеш токена
+	if event.Token == "" || event.Token == storedHash {
+		t.Errorf("token %q was stored as is, want only its hash", event.Token)
+	}
+	sum := sha256.Sum256([]byte(event.Token))
+	if storedHash != hex.EncodeToString(sum[:]) {
+		t.Errorf("stored hash = %q, want SHA-256 of the issued token", storedHash)
+	}
+
+	repo.GetUserByLoginFunc = func(context.Context, string) (*models.User, error) { return nil, errDatabase }
+	if err := s.RequestPasswordReset(ctx, "alice"); !errors.Is(err, errDatabase) {
+		t.Errorf("RequestPasswordReset() with repository error = %v, want %v", err, errDatabase)
+	}
+}
+
+func TestConfirmPasswordReset(t *testing.T) {
+	ctx := context.Background()
+	userID := uuid.New()
+	token := "reset-token"
+	sum := sha256.Sum256([]byte(token))
+	tokenHash := hex.EncodeToString(sum[:])
+
+	tests := []struct {
+		name        string
+		newPassword string
+		// tokenErr ошибка проверки токена; resetErr ошибка его использования
+		tokenErr  error
+		resetErr  error
+		wantErr   error
+		wantField string
+	}{
+		{name: "success", newPassword: "brand-new-password"},
+		{name: "weak password", newPassword: "short", wantField: "new_password"},
+		{name: "invalid token", newPassword: "brand-new-password", tokenErr: models.ErrInvalidResetToken, wantErr: models.ErrInvalidResetToken},
+		{name: "reuse of current password", newPassword: "current-pass", wantErr: models.ErrPasswordReused},
+		{name: "reuse of recent password", newPassword: "previous", wantErr: models.ErrPasswordReused},
+		{name: "token used concurrently", newPassword: "brand-new-password", resetErr: models.ErrInvalidResetToken, wantErr: models.ErrInvalidResetToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotHash, newHash string
+			var historyLimit int
+			repo := &mocks.UserRepository{
+				GetPasswordResetTokenFunc: func(_ context.Context, hash string, _ time.Time) (uuid.UUID, error) {
+					gotHash = hash
+					if tt.tokenErr != nil {
+						return uuid.Nil, tt.tokenErr
+					}
+					return userID, nil
+				},
+				GetPasswordHashFunc: func(context.Context, uuid.UUID) (string, error) {
+					return testHash(t, "current-pass"), nil
+				},
+				GetPasswordHistoryFunc: func(context.Context, uuid.UUID, int) ([]string, error) {
+					return []string{testHash(t, "previous")}, nil
+				},
+				ResetPasswordFunc: func(_ context.Context, hash, gotNewHash string, limit int, _ time.Time) (uuid.UUID, error) {
+					if hash != tokenHash {
+						t.Errorf("ResetPassword() got token hash %q, want %q", hash, tokenHash)
+					}
+					newHash, historyLimit = gotNewHash, limit
+					return userID, tt.resetErr
+				},
+			}
+			audit := &mocks.AuditRepository{}
+			s := newMockService(repo, service.Options{PasswordHistory: 3, Audit: audit})
+
+			err := s.ConfirmPasswordReset(ctx, token, tt.newPassword)
+			var fieldErr *validate.FieldError
+			switch {
+			case tt.wantField != "":
+				if !errors.As(err, &fieldErr) || fieldErr.Field != tt.wantField {
+					t.Fatalf("ConfirmPasswordReset() error = %v, want %s field error", err, tt.wantField)
+				}
+				if n := repo.Calls("GetPasswordResetToken"); n != 0 {
+					t.Errorf("token was checked %d times for a weak password, want 0", n)
+				}
+				return
+			case !errors.Is(err, tt.wantErr):
+				t.Fatalf("ConfirmPasswordReset() error = %v, want %v", err, tt.wantErr)
+			}
+			if gotHash != tokenHash {
+				t.Errorf("token looked up by %q, want its hash %q", gotHash, tokenHash)
+			}
+			if err != nil {
+				if tt.resetErr == nil && repo.Calls("ResetPassword") != 0 {
+					t.Error("password was reset despite the error")
+				}
+				if len(audit.Records()) != 0 {
+					t.Errorf("audit records = %d, want none for a failed reset", len(audit.Records()))
+				}
+				return
+			}
+
+			if err := bcrypt.CompareHashAndPassword([]byte(newHash), []byte(tt.newPassword)); err != nil {
+				t.Errorf("stored hash does not match the new password: %v", err)
+			}
+			if historyLimit != 3 {
+				t.Errorf("history limit = %d, want 3", historyLimit)
+			}
+			if records := audit.Records(); len(records) != 1 || records[0].Action != models.AuditPasswordReset || records[0].TargetID != userID {
+				t.Errorf("audit records = %+v, want one %s for %s", records, models.AuditPasswordReset, userID)
+			}
+		})
+	}
+}
+
+func TestChangePasswordUnknownUser(t *testing.T) {
+	repo := &mocks.UserRepository{
+		GetPasswordHashFunc: func(context.Context, uuid.UUID) (string, error) {
+			return "", models.ErrUserNotFound
+		},
+	}
+
+	if err := newMockService(repo, service.Options{}).ChangePassword(context.Background(), uuid.New(), "current-pass", "brand-new"); !errors.Is(err, models.ErrUserNotFound) {
+		t.Errorf("ChangePassword() error = %v, want %v", err, models.ErrUserNotFound)
+	}
+}
+
+func TestRevertTask(t *testing.T) {
+	ctx := context.Background()
+	taskID := uuid.New()
+
+	t.Run("success invalidates leaderboard cache", func(t *testing.T) {
+		repo := &mocks.UserRepository{
+			GetLeaderboardFunc: func(context.Context, models.LeaderboardFilter, int, int) ([]*models.User, int, error) {
+				return []*models.User{{ID: uuid.New(), Username: "alice"}}, 1, nil
+			},
+			RevertTaskFunc: func(_ context.Context, gotID uuid.UUID) (*models.Task, error) {
+				return &models.Task{ID: gotID, UserID: uuid.New(), Points: 30}, nil
+			},
+		}
+		s := newMockService(repo, service.Options{LeaderboardCacheTTL: time.Minute})
+
+		if _, _, err := s.GetLeaderboard(ctx, models.LeaderboardFilter{}, 10, 0); err != nil {
+			t.Fatalf("GetLeaderboard() error = %v", err)
+		}
+		task, err := s.RevertTask(ctx, uuid.New(), taskID)
+		if err != nil {
+			t.Fatalf("RevertTask() error = %v", err)
+		}
+		if task.ID != taskID {
+			t.Errorf("task id = %s, want %s", task.ID, taskID)
+		}
+		if _, _, err := s.GetLeaderboard(ctx, models.LeaderboardFilter{}, 10, 0); err != nil {
+			t.Fatalf("GetLeaderboard() error = %v", err)
+		}
+		if n := repo.Calls("GetLeaderboard"); n != 2 {
+			t.Errorf("repository calls = %d, want 2: reverted points must leave the cached leaderboard", n)
+		}
+	})
+
+	for _, repoErr := range []error{models.ErrTaskNotFound, models.ErrTaskAlreadyReverted} {
+		t.Run(repoErr.Error(), func(t *testing.T) {
+			repo := &mocks.UserRepository{
+				RevertTaskFunc: func(context.Context, uuid.UUID) (*models.Task, error) {
+					return nil, repoErr
+				},
+			}
+
+			if _, err := newMockService(repo, service.Options{}).RevertTask(ctx, uuid.New(), taskID); !errors.Is(err, repoErr) {
+				t.Errorf("RevertTask() error = %v, want %v", err, repoErr)
+			}
+		})
+	}
+}
+
+func TestPeriodBounds(t *testing.T) {
+	tests := []struct {
+		name     string
+		period   string
+		now      time.Time
+		from, to time.Time
+	}{
+		{
+			name:   "weekly from wednesday",
+			period: models.PeriodWeekly,
+			now:    time.Date(2024, 1, 10, 15, 30, 0, 0, time.UTC),
+			from:   time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC),
+			to:     time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:   "weekly on sunday belongs to the week started on monday",
+			period: models.PeriodWeekly,
+			now:    time.Date(2024, 1, 14, 23, 59, 0, 0, time.UTC),
+			from:   time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC),
+			to:     time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:   "weekly is computed in UTC",
+			period: models.PeriodWeekly,
+			now:    time.Date(2024, 1, 15, 1, 0, 0, 0, time.FixedZone("UTC+3", 3*3600)),
+			from:   time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC),
+			to:     time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:   "monthly across year end",
+			period: models.PeriodMonthly,
+			now:    time.Date(2023, 12, 31, 12, 0, 0, 0, time.UTC),
+			from:   time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC),
+			to:     time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, to, err := service.PeriodBounds(tt.period, tt.now)
+			if err != nil {
+				t.Fatalf("PeriodBounds() error = %v", err)
+			}
+			if !from.Equal(tt.from) || !to.Equal(tt.to) {
+				t.Errorf("PeriodBounds() = [%s, %s), want [%s, %s)", from, to, tt.from, tt.to)
+			}
+		})
+	}
+
+	if _, _, err := service.PeriodBounds("daily", time.Now()); !errors.Is(err, models.ErrInvalidPeriod) {
+		t.Errorf("PeriodBounds(daily) error = %v, want %v", err, models.ErrInvalidPeriod)
+	}
+}
+
+func TestGetPeriodLeaderboard(t *testing.T) {
+	ctx := context.Background()
+
+	repo := &mocks.UserRepository{
+		GetPeriodLeaderboardFunc: func(_ context.Context, from, to time.Time, limit, offset int) ([]*models.PeriodLeaderboardEntry, int, error) {
+			if want := from.AddDate(0, 1, 0); !to.Equal(want) || from.Day() != 1 {
+				t.Errorf("repository got [%s, %s), want the current calendar month", from, to)
+			}
+			if limit != 10 || offset != 5 {
+				t.Errorf("repository got limit %d offset %d, want 10 and 5", limit, offset)
+			}
+			return []*models.PeriodLeaderboardEntry{{Username: "alice", Points: 30, Rank: 1}}, 1, nil
+		},
+	}
+	s := newMockService(repo, service.Options{})
+
+	entries, total, err := s.GetPeriodLeaderboard(ctx, models.PeriodMonthly, 10, 5)
+	if err != nil {
+		t.Fatalf("GetPeriodLeaderboard() error = %v", err)
+	}
+	if len(entries) != 1 || total != 1 {
+		t.Errorf("GetPeriodLeaderboard() = %d entries, total %d, want 1 and 1", len(entries), total)
+	}
+
+	if _, _, err := s.GetPeriodLeaderboard(ctx, "yearly", 10, 0); !errors.Is(err, models.ErrInvalidPeriod) {
+		t.Errorf("GetPeriodLeaderboard(yearly) error = %v, want %v", err, models.ErrInvalidPeriod)
+	}
+	if n := repo.Calls("GetPeriodLeaderboard"); n != 1 {
+		t.Errorf("repository calls = %d, want 1: an invalid period must not reach the repository", n)
+	}
+}
+
+func TestAuthenticateUser(t *testing.T) {
+	ctx := context.Background()
+	userID := uuid.New()
+	hash := testHash(t, "password123")
+
+	newRepo := func(bonus int) *mocks.UserRepository {
+		return &mocks.UserRepository{
+			GetCredentialsFunc: func(_ context.Context, username string) (uuid.UUID, string, error) {
+				if username != "alice" {
+					return uuid.Nil, "", models.ErrUserNotFound
+				}
+				return userID, hash, nil
+			},
+			RecordLoginFunc: func(_ context.Context, gotID uuid.UUID, _ time.Time) (int, int, error) {
+				if gotID != userID {
+					t.Errorf("RecordLogin() got id %s, want %s", gotID, userID)
+				}
+				return 2, bonus, nil
+			},
+			GetUserByIDFunc: func(_ context.Context, id uuid.UUID) (*models.User, error) {
+				return &models.User{ID: id, Username: "alice", StreakDays: 2}, nil
+			},
+			GetLeaderboardFunc: func(context.Context, models.LeaderboardFilter, int, int) ([]*models.User, int, error) {
+				return []*models.User{{ID: userID, Username: "alice"}}, 1, nil
+			},
+		}
+	}
+
+	t.Run("bonus invalidates leaderboard cache", func(t *testing.T) {
+		repo := newRepo(5)
+		s := newMockService(repo, service.Options{LeaderboardCacheTTL: time.Minute})
+
+		if _, _, err := s.GetLeaderboard(ctx, models.LeaderboardFilter{}, 10, 0); err != nil {
+			t.Fatalf("GetLeaderboard() error = %v", err)
+		}
+		user, bonus, err := s.AuthenticateUser(ctx, "alice", "password123")
+		if err != nil {
+			t.Fatalf("AuthenticateUser() error = %v", err)
+		}
+		if user.ID != userID || user.StreakDays != 2 || bonus != 5 {
+			t.Errorf("AuthenticateUser() = %+v, %d, want streak 2 and bonus 5", user, bonus)
+		}
+		if _, _, err := s.GetLeaderboard(ctx, models.LeaderboardFilter{}, 10, 0); err != nil {
+			t.Fatalf("GetLeaderboard() error = %v", err)
+		}
+		if n := repo.Calls("GetLeaderboard"); n != 2 {
+			t.Errorf("repository calls = %d, want 2: streak bonus must leave the cached leaderboard", n)
+		}
+	})
+
+	t.Run("no bonus keeps leaderboard cache", func(t *testing.T) {
+		repo := newRepo(0)
+		s := newMockService(repo, service.Options{LeaderboardCacheTTL: time.Minute})
+
+		if _, _, err := s.GetLeaderboard(ctx, models.LeaderboardFilter{}, 10, 0); err != nil {
+			t.Fatalf("GetLeaderboard() error = %v", err)
+		}
+		if _, _, err := s.AuthenticateUser(ctx, "alice", "password123"); err != nil {
+			t.Fatalf("AuthenticateUser() error = %v", err)
+		}
+		if _, _, err := s.GetLeaderboard(ctx, models.LeaderboardFilter{}, 10, 0); err != nil {
+			t.Fatalf("GetLeaderboard() error = %v", err)
+		}
+		if n := repo.Calls("GetLeaderboard"); n != 1 {
+			t.Errorf("repository calls = %d, want 1", n)
+		}
+	})
+
+	for _, tt := range []struct {
+		name, username, password string
+	}{
+		{name: "unknown username", username: "bob", password: "password123"},
+		{name: "wrong password", username: "alice", password: "wrong-password"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newRepo(5)
+
+			_, _, err := newMockService(repo, service.Options{}).AuthenticateUser(ctx, tt.username, tt.password)
+			if !errors.Is(err, models.ErrInvalidCredentials) {
+				t.Errorf("AuthenticateUser() error = %v, want %v", err, models.ErrInvalidCredentials)
+			}
+			if n := repo.Calls("RecordLogin"); n != 0 {
+				t.Errorf("RecordLogin calls = %d, want 0 for rejected credentials", n)
+			}
+		})
+	}
+}
+
+func TestImportUsers(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("hashes passwords and invalidates leaderboard cache", func(t *testing.T) {
+		var imported []models.ImportUserRequest
+		repo := &mocks.UserRepository{
+			GetLeaderboardFunc: func(context.Context, models.LeaderboardFilter, int, int) ([]*models.User, int, error) {
+				return []*models.User{{ID: uuid.New(), Username: "alice"}}, 1, nil
+			},
+			ImportUsersFunc: func(_ context.Context, users []models.ImportUserRequest) (int, error) {
+				imported = users
+				return 1, nil
+			},
+		}
+		s := newMockService(repo, service.Options{LeaderboardCacheTTL: time.Minute})
+
+		if _, _, err := s.GetLeaderboard(ctx, models.LeaderboardFilter{}, 10, 0); err != nil {
+			t.Fatalf("GetLeaderboard() error = %v", err)
+		}
+		result, err := s.ImportUsers(ctx, []models.ImportUserRequest{
+			{Username: "alice", Password: "password123", Points: 10},
+			{Username: "bob", Password: "password456"},
+		})
+		if err != nil {
+			t.Fatalf("ImportUsers() error = %v", err)
+		}
+		if *result != (models.ImportUsersResponse{Inserted: 1, Skipped: 1}) {
+			t.Errorf("ImportUsers() = %+v, want 1 inserted and 1 skipped", *result)
+		}
+		if len(imported) != 2 || imported[0].Points != 10 {
+			t.Fatalf("repository got %+v, want both users", imported)
+		}
+		if bcrypt.CompareHashAndPassword([]byte(imported[0].Password), []byte("password123")) != nil {
+			t.Error("repository got a password that is not a bcrypt hash of the input")
+		}
+		if _, _, err := s.GetLeaderboard(ctx, models.LeaderboardFilter{}, 10, 0); err != nil {
+			t.Fatalf("GetLeaderboard() error = %v", err)
+		}
+		if n := repo.Calls("GetLeaderboard"); n != 2 {
+			t.Errorf("repository calls = %d, want 2: imported users must leave the cached leaderboard", n)
+		}
+	})
+
+	tests := []struct {
+		name      string
+		users     []models.ImportUserRequest
+		wantErr   error
+		wantField string
+	}{
+		{
+			name:    "batch too large",
+			users:   make([]models.ImportUserRequest, 3),
+			wantErr: models.ErrBatchTooLarge,
+		},
+		{
+			name:      "invalid username reports index",
+			users:     []models.ImportUserRequest{{Username: "alice", Password: "password123"}, {Username: "b!", Password: "password123"}},
+			wantField: "[1].username",
+		},
+		{
+			name:      "weak password reports index",
+			users:     []models.ImportUserRequest{{Username: "alice", Password: "short"}},
+			wantField: "[0].password",
+		},
+		{
+			name:      "negative points",
+			users:     []models.ImportUserRequest{{Username: "alice", Password: "password123", Points: -1}},
+			wantField: "[0].points",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &mocks.UserRepository{}
+
+			_, err := newMockService(repo, service.Options{ImportMaxBatch: 2}).ImportUsers(ctx, tt.users)
+			var fieldErr *validate.FieldError
+			switch {
+			case tt.wantField != "":
+				if !errors.As(err, &fieldErr) || fieldErr.Field != tt.wantField {
+					t.Errorf("ImportUsers() error = %v, want %s field error", err, tt.wantField)
+				}
+			case !errors.Is(err, tt.wantErr):
+				t.Errorf("ImportUsers() error = %v, want %v", err, tt.wantErr)
+			}
+			if n := repo.Calls("ImportUsers"); n != 0 {
+				t.Errorf("repository calls = %d, want 0 for a rejected batch", n)
+			}
+		})
+	}
+}
+
+// importDecodeError запись импорта, разобранная с ошибкой: декодер заполняет остальные поля
+type importDecodeError struct {
+	record models.ImportTaskRequest
+	err    error
+}
+
+// importInput возвращает функцию чтения записей импорта заданий и счетчик ее вызовов: элемент
+// records - запись, importDecodeError или ошибка ввода; после записей возвращается io.EOF
+func importInput(records ...any) (func(*models.ImportTaskRequest) error, *int) {
+	reads := 0
+	return func(record *models.ImportTaskRequest) error {
+		reads++
+		if reads > len(records) {
+			return io.EOF
+		}
+		switch r := records[reads-1].(type) {
+		case error:
+			return r
+		case importDecodeError:
+			*record = r.record
+			return r.err
+		case models.ImportTaskRequest:
+			*record = r
+		}
+		return nil
+	}, &reads
+}
+
+func TestImportTasks(t *testing.T) {
+	ctx := context.Background()
+	adminID, knownID := uuid.New(), uuid.New()
+	newRepo := func() *mocks.UserRepository {
+		return &mocks.UserRepository{
+			CompleteTaskFunc: func(_ context.Context, userID uuid.UUID, taskRequest models.TaskRequest, _ models.TaskActivityLimit) (*models.Task, error) {
+				if userID != knownID {
+					return nil, models.ErrUserNotFound
+				}
+				return &models.Task{ID: uuid.New(), UserID: userID, TaskType: taskRequest.TaskType, Points: taskRequest.Points}, nil
+			},
+		}
+	}
+	valid := models.ImportTaskRequest{UserID: knownID.String(), TaskType: "daily", Points: 10}
+
+	t.Run("mixed records", func(t *testing.T) {
+		repo := newRepo()
+		audit := &mocks.AuditRepository{}
+		s := newMockService(repo, service.Options{Audit: audit, MaxPointsPerTask: 100})
+		next, _ := importInput(
+			valid,
+			importDecodeError{
+				record: models.ImportTaskRequest{UserID: knownID.String(), TaskType: "daily"},
+				err:    &validate.FieldError{Field: "points", Message: "must be a number"},
+			},
+			models.ImportTaskRequest{TaskType: "daily"},
+			models.ImportTaskRequest{UserID: uuid.NewString(), TaskType: "daily", Points: 5},
+			models.ImportTaskRequest{UserID: knownID.String(), TaskType: "daily", Points: 101},
+			valid,
+		)
+		var results []*models.ImportTaskResult
+		emit := func(result *models.ImportTaskResult) error {
+			results = append(results, result)
+			return nil
+		}
+
+		summary, err := s.ImportTasks(ctx, adminID, next, emit)
+		if err != nil {
+			t.Fatalf("ImportTasks() error = %v", err)
+		}
+		if *summary != (models.ImportTasksSummary{Imported: 2, Failed: 4}) {
+			t.Errorf("summary = %+v, want 2 imported and 4 failed", summary)
+		}
+		if len(results) != 6 {
+			t.Fatalf("results = %d, want one per record", len(results))
+		}
+
+		wantFields := [][]string{nil, {"points"}, {"points", "user_id"}, {"user_id"}, {"points"}, nil}
+		for i, result := range results {
+			wantStatus := models.ImportStatusFailed
+			if wantFields[i] == nil {
+				wantStatus = models.ImportStatusImported
+			}
+			if result.Line != i+1 || result.Status != wantStatus {
+				t.Errorf("result %d = line %d %s, want line %d %s", i, result.Line, result.Status, i+1, wantStatus)
+			}
+			if (result.TaskID != nil) != (wantStatus == models.ImportStatusImported) {
+				t.Errorf("result %d task_id = %v, want it only for imported records", i, result.TaskID)
+			}
+			if wantStatus == models.ImportStatusFailed && result.Error == "" {
+				t.Errorf("result %d has no error message", i)
+			}
+			fields := slices.Sorted(maps.Keys(result.Fields))
+			if !slices.Equal(fields, wantFields[i]) {
+				t.Errorf("result %d fields = %v, want %v", i, result.Fields, wantFields[i])
+			}
+		}
+		// Отклоненные при проверке записи не доходят до хранилища
+		if n := repo.Calls("CompleteTask"); n != 3 {
+			t.Errorf("CompleteTask calls = %d, want 3", n)
+		}
+
+		records := audit.Records()
+		if len(records) != 1 || records[0].Action != models.AuditTasksImported || records[0].ActorID != adminID {
+			t.Fatalf("audit records = %+v, want one tasks.imported by the admin", records)
+		}
+		if records[0].Metadata["imported"] != 2 || records[0].Metadata["failed"] != 4 {
+			t.Errorf("audit metadata = %v, want imported 2 and failed 4", records[0].Metadata)
+		}
+	})
+
+	t.Run("limit stops reading", func(t *testing.T) {
+		next, reads := importInput(valid, valid, valid, valid)
+		var results []*models.ImportTaskResult
+		emit := func(result *models.ImportTaskResult) error {
+			results = append(results, result)
+			return nil
+		}
+
+		summary, err := newMockService(newRepo(), service.Options{TaskImportMax: 2}).ImportTasks(ctx, adminID, next, emit)
+		if err != nil {
+			t.Fatalf("ImportTasks() error = %v", err)
+		}
+		if *summary != (models.ImportTasksSummary{Imported: 2, Failed: 1}) || *reads != 3 {
+			t.Errorf("summary = %+v after %d reads, want 2 imported, 1 failed after 3 reads", summary, *reads)
+		}
+		if last := results[len(results)-1]; last.Status != models.ImportStatusFailed || last.Line != 3 {
+			t.Errorf("last result = %+v, want line 3 rejected by the limit", last)
+		}
+	})
+
+	t.Run("malformed input stops reading", func(t *testing.T) {
+		next, reads := importInput(valid, errors.New("invalid character 'x' looking for beginning of value"), valid)
+		emit := func(*models.ImportTaskResult) error { return nil }
+
+		summary, err := newMockService(newRepo(), service.Options{}).ImportTasks(ctx, adminID, next, emit)
+		if err != nil {
+			t.Fatalf("ImportTasks() error = %v", err)
+		}
+		if *summary != (models.ImportTasksSummary{Imported: 1, Failed: 1}) || *reads != 2 {
+			t.Errorf("summary = %+v after %d reads, want 1 imported, 1 failed after 2 reads", summary, *reads)
+		}
+	})
+
+	t.Run("storage error aborts", func(t *testing.T) {
+		repo := &mocks.UserRepository{
+			CompleteTaskFunc: func(context.Context, uuid.UUID, models.TaskRequest, models.TaskActivityLimit) (*models.Task, error) {
+				return nil, errDatabase
+			},
+		}
+		next, reads := importInput(valid, valid)
+		emitted := 0
+		emit := func(*models.ImportTaskResult) error {
+			emitted++
+			return nil
+		}
+
+		summary, err := newMockService(repo, service.Options{}).ImportTasks(ctx, adminID, next, emit)
+		if !errors.Is(err, errDatabase) {
+			t.Fatalf("ImportTasks() error = %v, want %v", err, errDatabase)
+		}
+		if *summary != (models.ImportTasksSummary{Failed: 1}) || *reads != 1 || emitted != 1 {
+			t.Errorf("summary = %+v after %d reads and %d results, want 1 failed after one record", summary, *reads, emitted)
+		}
+	})
+
+	t.Run("emit error aborts", func(t *testing.T) {
+		repo := newRepo()
+		next, reads := importInput(valid, valid)
+		errClosed := errors.New("connection closed")
+		emit := func(*models.ImportTaskResult) error { return errClosed }
+
+		summary, err := newMockService(repo, service.Options{}).ImportTasks(ctx, adminID, next, emit)
+		if !errors.Is(err, errClosed) || *reads != 1 {
+			t.Errorf("ImportTasks() error = %v after %d reads, want %v after one record", err, *reads, errClosed)
+		}
+		if summary.Imported != 1 {
+			t.Errorf("summary = %+v, want the first record counted as imported", summary)
+		}
+	})
+}
+
+func TestGetUserByUsername(t *testing.T) {
+	ctx := context.Background()
+	alice := &models.PublicProfile{ID: uuid.New(), Username: "alice", Points: 10}
+
+	var looked []string
+	repo := &mocks.UserRepository{
+		GetUserByUsernameFunc: func(_ context.Context, username string) (*models.PublicProfile, error) {
+			looked = append(looked, username)
+			switch username {
+			case "alice":
+				return alice, nil
+			case "broken":
+				return nil, errDatabase
+			}
+			return nil, models.ErrUserNotFound
+		},
+	}
+	s := newMockService(repo, service.Options{})
+
+	profile, err := s.GetUserByUsername(ctx, "  alice ")
+	if err != nil || profile != alice {
+		t.Errorf("GetUserByUsername(alice) = %+v, %v, want %+v", profile, err, alice)
+	}
+	if _, err := s.GetUserByUsername(ctx, "bob"); !errors.Is(err, models.ErrUserNotFound) {
+		t.Errorf("GetUserByUsername(bob) error = %v, want %v", err, models.ErrUserNotFound)
+	}
+	if _, err := s.GetUserByUsername(ctx, "broken"); !errors.Is(err, errDatabase) {
+		t.Errorf("GetUserByUsername(broken) error = %v, want %v", err, errDatabase)
+	}
+	if looked[0] != "alice" {
+		t.Errorf("repository got %q, want the trimmed username", looked[0])
+	}
+}
+
+func TestResolveReferralCode(t *testing.T) {
+	ctx := context.Background()
+	referrerID := uuid.New()
+
+	repo := &mocks.UserRepository{
+		GetUserByReferralCodeFunc: func(_ context.Context, code string) (*models.User, error) {
+			switch code {
+			case "MFRGGZDF":
+				return &models.User{ID: referrerID, ReferralCode: code}, nil
+			case "BROKEN00":
+				return nil, errDatabase
+			}
+			return nil, models.ErrUserNotFound
+		},
+	}
+	s := newMockService(repo, service.Options{})
+
+	if id, err := s.ResolveReferralCode(ctx, "MFRGGZDF"); err != nil || id != referrerID {
+		t.Errorf("ResolveReferralCode(known) = %s, %v, want %s", id, err, referrerID)
+	}
+	if _, err := s.ResolveReferralCode(ctx, "ZZZZZZZZ"); !errors.Is(err, models.ErrReferrerNotFound) {
+		t.Errorf("ResolveReferralCode(unknown) error = %v, want %v", err, models.ErrReferrerNotFound)
+	}
+	if _, err := s.ResolveReferralCode(ctx, "BROKEN00"); !errors.Is(err, errDatabase) {
+		t.Errorf("ResolveReferralCode(broken) error = %v, want %v", err, errDatabase)
+	}
+}
+
+func TestAdjustPoints(t *testing.T) {
+	ctx := context.Background()
+	adminID, userID := uuid.New(), uuid.New()
+
+	t.Run("success invalidates leaderboard cache", func(t *testing.T) {
+		repo := &mocks.UserRepository{
+			GetLeaderboardFunc: func(context.Context, models.LeaderboardFilter, int, int) ([]*models.User, int, error) {
+				return []*models.User{{ID: userID, Username: "alice"}}, 1, nil
+			},
+			AdjustPointsFunc: func(_ context.Context, gotAdmin, gotUser uuid.UUID, delta int, reason string) (*models.PointAdjustment, error) {
+				if gotAdmin != adminID || gotUser != userID || delta != -5 || reason != "fraud" {
+					t.Errorf("repository got %s %s %d %q, want %s %s -5 fraud", gotAdmin, gotUser, delta, reason, adminID, userID)
+				}
+				return &models.PointAdjustment{ID: uuid.New(), UserID: gotUser, AdminID: gotAdmin, Delta: delta, AppliedDelta: delta}, nil
+			},
+		}
+		s := newMockService(repo, service.Options{LeaderboardCacheTTL: time.Minute})
+
+		if _, _, err := s.GetLeaderboard(ctx, models.LeaderboardFilter{}, 10, 0); err != nil {
+			t.Fatalf("GetLeaderboard() error = %v", err)
+		}
+		if _, err := s.AdjustPoints(ctx, adminID, userID, -5, "fraud"); err != nil {
+			t.Fatalf("AdjustPoints() error = %v", err)
+		}
+		if _, _, err := s.GetLeaderboard(ctx, models.LeaderboardFilter{}, 10, 0); err != nil {
+			t.Fatalf("GetLeaderboard() error = %v", err)
+		}
+		if n := repo.Calls("GetLeaderboard"); n != 2 {
+			t.Errorf("repository calls = %d, want 2: adjusted points must leave the cached leaderboard", n)
+		}
+	})
+
+	for _, repoErr := range []error{models.ErrUserNotFound, models.ErrPointsOutOfRange} {
+		t.Run(repoErr.Error(), func(t *testing.T) {
+			repo := &mocks.UserRepository{
+				AdjustPointsFunc: func(context.Context, uuid.UUID, uuid.UUID, int, string) (*models.PointAdjustment, error) {
+					return nil, repoErr
+				},
+			}
+
+			if _, err := newMockService(repo, service.Options{}).AdjustPoints(ctx, adminID, userID, 5, "bonus"); !errors.Is(err, repoErr) {
+				t.Errorf("AdjustPoints() error = %v, want %v", err, repoErr)
+			}
+		})
+	}
+}
+
+func TestRegenerateReferralCode(t *testing.T) {
+	ctx := context.Background()
+	userID := uuid.New()
+
+	t.Run("success", func(t *testing.T) {
+		repo := &mocks.UserRepository{
+			RegenerateReferralCodeFunc: func(_ context.Context, id uuid.UUID) (string, error) {
+				if id != userID {
+					t.Errorf("repository got user %s, want %s", id, userID)
+				}
+				return "NEWCODE2", nil
+			},
+		}
+
+		code, err := newMockService(repo, service.Options{}).RegenerateReferralCode(ctx, userID)
+		if err != nil || code != "NEWCODE2" {
+			t.Errorf("RegenerateReferralCode() = %q, %v, want NEWCODE2", code, err)
+		}
+	})
+
+	for _, repoErr := range []error{models.ErrUserNotFound, errDatabase} {
+		t.Run(repoErr.Error(), func(t *testing.T) {
+			repo := &mocks.UserRepository{
+				RegenerateReferralCodeFunc: func(context.Context, uuid.UUID) (string, error) {
+					return "", repoErr
+				},
+			}
+			audit := &mocks.AuditRepository{}
+
+			code, err := newMockService(repo, service.Options{Audit: audit}).RegenerateReferralCode(ctx, userID)
+			if !errors.Is(err, repoErr) || code != "" {
+				t.Errorf("RegenerateReferralCode() = %q, %v, want empty code and %v", code, err, repoErr)
+			}
+			if n := len(audit.Records()); n != 0 {
+				t.Errorf("audit records = %d, want none for a failed rotation", n)
+			}
+		})
+	}
+}
+
+func TestAuditRecorded(t *testing.T) {
+	ctx := context.Background()
+	actorID, targetID := uuid.New(), uuid.New()
+	repo := &mocks.UserRepository{
+		AdjustPointsFunc: func(_ context.Context, adminID, userID uuid.UUID, delta int, reason string) (*models.PointAdjustment, error) {
+			return &models.PointAdjustment{ID: uuid.New(), UserID: userID, AdminID: adminID, Delta: delta, AppliedDelta: -30}, nil
+		},
+		RevertTaskFunc: func(_ context.Context, taskID uuid.UUID) (*models.Task, error) {
+			return &models.Task{ID: taskID, UserID: targetID, Points: 40}, nil
+		},
+		AddReferrerFunc: func(_ context.Context, userID, _ uuid.UUID) (*models.User, []models.ReferralCredit, error) {
+			return &models.User{ID: userID}, nil, nil
+		},
+		DeleteUserFunc: func(context.Context, uuid.UUID) error { return nil },
+		RegenerateReferralCodeFunc: func(context.Context, uuid.UUID) (string, error) {
+			return "NEWCODE2", nil
+		},
+	}
+
+	tests := []struct {
+		name       string
+		call       func(s *service.UserService) error
+		wantActor  uuid.UUID
+		wantAction string
+		wantTarget uuid.UUID
+		// wantMetadata ожидаемые метаданные записи; nil - метаданные не передаются
+		wantMetadata map[string]any
+	}{
+		{
+			name: "points adjusted",
+			call: func(s *service.UserService) error {
+				_, err := s.AdjustPoints(ctx, actorID, targetID, -50, "fraud")
+				return err
+			},
+			wantActor: actorID, wantAction: models.AuditPointsAdjusted, wantTarget: targetID,
+			wantMetadata: map[string]any{"delta": -50, "applied_delta": -30, "reason": "fraud"},
+		},
+		{
+			name: "task reverted",
+			call: func(s *service.UserService) error {
+				_, err := s.RevertTask(ctx, actorID, targetID)
+				return err
+			},
+			wantActor: actorID, wantAction: models.AuditTaskReverted, wantTarget: targetID,
+			wantMetadata: map[string]any{"user_id": targetID.String(), "points": 40},
+		},
+		{
+			name: "referrer added",
+			call: func(s *service.UserService) error {
+				_, err := s.AddReferrer(ctx, actorID, targetID)
+				return err
+			},
+			wantActor: actorID, wantAction: models.AuditReferrerAdded, wantTarget: actorID,
+			wantMetadata: map[string]any{"referrer_id": targetID.String()},
+		},
+		{
+			name:      "user deleted",
+			call:      func(s *service.UserService) error { return s.DeleteUser(ctx, actorID) },
+			wantActor: actorID, wantAction: models.AuditUserDeleted, wantTarget: actorID,
+		},
+		{
+			name: "referral code rotated",
+			call: func(s *service.UserService) error {
+				_, err := s.RegenerateReferralCode(ctx, actorID)
+				return err
+			},
+			wantActor: actorID, wantAction: models.AuditReferralRotated, wantTarget: actorID,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			audit := &mocks.AuditRepository{}
+			if err := tt.call(newMockService(repo, service.Options{Audit: audit})); err != nil {
+				t.Fatalf("operation error = %v", err)
+			}
+
+			records := audit.Records()
+			if len(records) != 1 {
+				t.Fatalf("audit records = %d, want 1", len(records))
+			}
+			got := records[0]
+			if got.ActorID != tt.wantActor || got.Action != tt.wantAction || got.TargetID != tt.wantTarget {
+				t.Errorf("audit record = %s %s %s, want %s %s %s", got.ActorID, got.Action, got.TargetID, tt.wantActor, tt.wantAction, tt.wantTarget)
+			}
+			for key, want := range tt.wantMetadata {
+				if got.Metadata[key] != want {
+					t.Errorf("metadata[%q] = %v, want %v", key, got.Metadata[key], want)
+				}
+			}
+			if tt.wantMetadata == nil && got.Metadata != nil {
+				t.Errorf("metadata = %v, want nil", got.Metadata)
+			}
+		})
+	}
+}
+
+func TestAuditFailureDoesNotFailOperation(t *testing.T) {
+	core, logs := observer.New(zapcore.ErrorLevel)
+	repo := &mocks.UserRepository{
+		DeleteUserFunc: func(context.Context, uuid.UUID) error { return nil },
+	}
+	audit := &mocks.AuditRepository{
+		RecordFunc: func(context.Context, uuid.UUID, string, uuid.UUID, map[string]any) error { return errDatabase },
+	}
+	s := service.NewUserService(repo, service.Options{Audit: audit}, zap.New(core))
+
+	if err := s.DeleteUser(context.Background(), uuid.New()); err != nil {
+		t.Fatalf("DeleteUser() error = %v, want nil despite the audit failure", err)
+	}
+	if n := logs.FilterMessage("Failed to record audit entry").Len(); n != 1 {
+		t.Errorf("audit failure log entries = %d, want 1", n)
+	}
+}
+
+func TestListUsers(t *testing.T) {
+	ctx := context.Background()
+	hasReferrer := true
+	filter := models.UserListFilter{Search: "bob", HasReferrer: &hasReferrer, Sort: models.UserSortPoints, Asc: true}
+
+	repo := &mocks.UserRepository{
+		ListUsersFunc: func(_ context.Context, got models.UserListFilter, limit, offset int) ([]*models.User, int, error) {
+			if got != filter || limit != 20 || offset != 40 {
+				t.Errorf("repository got %+v, %d, %d, want %+v, 20, 40", got, limit, offset, filter)
+			}
+			return []*models.User{{ID: uuid.New(), Username: "bob"}}, 41, nil
+		},
+	}
+	users, total, err := newMockService(repo, service.Options{}).ListUsers(ctx, filter, 20, 40)
+	if err != nil {
+		t.Fatalf("ListUsers() error = %v", err)
+	}
+	if len(users) != 1 || total != 41 {
+		t.Errorf("ListUsers() = %d users of %d, want 1 of 41", len(users), total)
+	}
+
+	repo.ListUsersFunc = func(context.Context, models.UserListFilter, int, int) ([]*models.User, int, error) {
+		return nil, 0, errDatabase
+	}
+	if _, _, err := newMockService(repo, service.Options{}).ListUsers(ctx, filter, 20, 0); !errors.Is(err, errDatabase) {
+		t.Errorf("ListUsers() error = %v, want %v", err, errDatabase)
+	}
+}
+
+func TestListAudit(t *testing.T) {
+	ctx := context.Background()
+	actorID := uuid.New()
+	filter := models.AuditFilter{ActorID: &actorID, Action: models.AuditPointsAdjusted}
+
+	audit := &mocks.AuditRepository{
+		ListAuditFunc: func(_ context.Context, got models.AuditFilter, limit, offset int) ([]*models.AuditEntry, int, error) {
+			if got.ActorID != filter.ActorID || got.Action != filter.Action || limit != 20 || offset != 40 {
+				t.Errorf("repository got %+v, %d, %d, want %+v, 20, 40", got, limit, offset, filter)
+			}
+			return []*models.AuditEntry{{ID: uuid.New(), ActorID: actorID}}, 41, nil
+		},
+	}
+	entries, total, err := newMockService(&mocks.UserRepository{}, service.Options{Audit: audit}).ListAudit(ctx, filter, 20, 40)
+	if err != nil {
+		t.Fatalf("ListAudit() error = %v", err)
+	}
+	if len(entries) != 1 || total != 41 {
+		t.Errorf("ListAudit() = %d entries of %d, want 1 of 41", len(entries), total)
+	}
+
+	// Без журнала аудита возвращается пустая страница
+	entries, total, err = newMockService(&mocks.UserRepository{}, service.Options{}).ListAudit(ctx, filter, 20, 0)
+	if err != nil || len(entries) != 0 || total != 0 {
+		t.Errorf("ListAudit() without audit = %v, %d, %v, want an empty page", entries, total, err)
+	}
+
+	audit = &mocks.AuditRepository{
+		ListAuditFunc: func(context.Context, models.AuditFilter, int, int) ([]*models.AuditEntry, int, error) {
+			return nil, 0, errDatabase
+		},
+	}
+	if _, _, err := newMockService(&mocks.UserRepository{}, service.Options{Audit: audit}).ListAudit(ctx, filter, 20, 0); !errors.Is(err, errDatabase) {
+		t.Errorf("ListAudit() error = %v, want %v", err, errDatabase)
+	}
+}
+
+func TestNotifyPointsEvents(t *testing.T) {
+	ctx := context.Background()
+	userID, referrerID, grandReferrerID := uuid.New(), uuid.New(), uuid.New()
+	completedAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	taskID := uuid.New()
+
+	repo := &mocks.UserRepository{
+		CompleteTaskFunc: func(_ context.Context, gotID uuid.UUID, taskRequest models.TaskRequest, _ models.TaskActivityLimit) (*models.Task, error) {
+			return &models.Task{ID: taskID, UserID: gotID, TaskType: taskRequest.TaskType, Points: taskRequest.Points, CompletedAt: completedAt, Balance: 110}, nil
+		},
+		AddReferrerFunc: func(_ context.Context, gotID, gotReferrerID uuid.UUID) (*models.User, []models.ReferralCredit, error) {
+			return &models.User{ID: gotID, ReferrerID: &gotReferrerID}, []models.ReferralCredit{
+				{UserID: referrerID, Level: 1, Points: 10, Balance: 60},
+				{UserID: grandReferrerID, Level: 2, Points: 5, Balance: 5},
+			}, nil
+		},
+	}
+	notifier := &mocks.Notifier{}
+	s := newMockService(repo, service.Options{Notifier: notifier})
+
+	if _, err := s.CompleteTask(ctx, userID, models.TaskRequest{TaskType: "daily", Points: 10}); err != nil {
+		t.Fatalf("CompleteTask() error = %v", err)
+	}
+	if _, err := s.AddReferrer(ctx, userID, referrerID); err != nil {
+		t.Fatalf("AddReferrer() error = %v", err)
+	}
+
+	got := notifier.Notifications()
+	if len(got) != 3 {
+		t.Fatalf("notifications = %d, want 3: one task and one per credited referrer", len(got))
+	}
+
+	task, ok := got[0].Payload.(models.PointsEvent)
+	if !ok || got[0].Event != models.EventTaskCompleted {
+		t.Fatalf("first notification = %+v, want %s", got[0], models.EventTaskCompleted)
+	}
+	if task.UserID != userID || task.TaskID == nil || *task.TaskID != taskID || task.TaskType != "daily" ||
+		task.PointsDelta != 10 || task.Balance != 110 || !task.OccurredAt.Equal(completedAt) {
+		t.Errorf("task event = %+v, want daily task %s of %s for 10 points, balance 110", task, taskID, userID)
+	}
+
+	for i, want := range []struct {
+		userID                 uuid.UUID
+		level, points, balance int
+	}{
+		{userID: referrerID, level: 1, points: 10, balance: 60},
+		{userID: grandReferrerID, level: 2, points: 5, balance: 5},
+	} {
+		event, ok := got[i+1].Payload.(models.PointsEvent)
+		if !ok || got[i+1].Event != models.EventReferralCredited {
+			t.Fatalf("notification %d = %+v, want %s", i+1, got[i+1], models.EventReferralCredited)
+		}
+		if event.UserID != want.userID || event.Level != want.level || event.PointsDelta != want.points ||
+			event.Balance != want.balance || event.ReferredUserID == nil || *event.ReferredUserID != userID {
+			t.Errorf("referral event %d = %+v, want level %d credit of %d to %s", i+1, event, want.level, want.points, want.userID)
+		}
+	}
+}
+
+func TestNotifyCorrelationID(t *testing.T) {
+	repo := &mocks.UserRepository{
+		CompleteTaskFunc: func(_ context.Context, gotID uuid.UUID, taskRequest models.TaskRequest, _ models.TaskActivityLimit) (*models.Task, error) {
+			return &models.Task{ID: uuid.New(), UserID: gotID, Points: taskRequest.Points}, nil
+		},
+	}
+	ctx := logger.WithRequestID(context.Background(), "request-1")
+
+	for _, tt := range []struct {
+		correlationID bool
+		want          string
+	}{
+		{correlationID: true, want: "request-1"},
+		{correlationID: false, want: ""},
+	} {
+		notifier := &mocks.Notifier{}
+		s := newMockService(repo, service.Options{Notifier: notifier, CorrelationID: tt.correlationID})
+		if _, err := s.CompleteTask(ctx, uuid.New(), models.TaskRequest{TaskType: "daily", Points: 10}); err != nil {
+			t.Fatalf("CompleteTask() error = %v", err)
+		}
+
+		got := notifier.Notifications()
+		if len(got) != 1 {
+			t.Fatalf("notifications = %d, want 1", len(got))
+		}
+		if event := got[0].Payload.(models.PointsEvent); event.CorrelationID != tt.want {
+			t.Errorf("CorrelationID %v: event correlation_id = %q, want %q", tt.correlationID, event.CorrelationID, tt.want)
+		}
+	}
+}
+
+func TestNotifySkippedOnFailure(t *testing.T) {
+	repo := &mocks.UserRepository{
+		CompleteTaskFunc: func(context.Context, uuid.UUID, models.TaskRequest, models.TaskActivityLimit) (*models.Task, error) {
+			return nil, errDatabase
+		},
+	}
+	notifier := &mocks.Notifier{}
+
+	if _, err := newMockService(repo, service.Options{Notifier: notifier}).CompleteTask(context.Background(), uuid.New(), models.TaskRequest{TaskType: "daily", Points: 10}); err == nil {
+		t.Fatal("CompleteTask() error = nil, want error")
+	}
+	if n := len(notifier.Notifications()); n != 0 {
+		t.Errorf("notifications = %d, want none for a failed task", n)
+	}
+}
+
+func TestUnconfiguredMockMethod(t *testing.T) {
+	repo := &mocks.UserRepository{}
+
+	if _, err := newMockService(repo, service.Options{}).GetUserTasks(context.Background(), uuid.New(), "", 10, 0); !errors.Is(err, mocks.ErrNotConfigured) {
+		t.Errorf("GetUserTasks() error = %v, want %v", err, mocks.ErrNotConfigured)
+	}
+	if n := repo.Calls("GetUserTasks"); n != 1 {
+		t.Errorf("Calls(GetUserTasks) = %d, want 1", n)
+	}
+}
@@ -0,0 +1 @@
+ALTER TABLE users DROP CONSTRAINT IF EXISTS non_negative_points;
@@ -0,0 +1 @@
+ALTER TABLE users ADD CONSTRAINT non_negative_points CHECK (points >= 0);
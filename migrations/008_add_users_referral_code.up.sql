@@ -0,0 +1,27 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS referral_code VARCHAR(8) NULL;
+
+-- Ограничение создается до заполнения: NULL не нарушает уникальность,
+-- а индекс ускоряет проверку занятости кода ниже
+ALTER TABLE users ADD CONSTRAINT users_referral_code_key UNIQUE (referral_code);
+
+-- Коды для существующих пользователей: 8 символов алфавита base32.
+-- Совпавший с уже выданным код генерируется заново.
+DO $$
+DECLARE
+    target_id UUID;
+    candidate VARCHAR(8);
+BEGIN
+    FOR target_id IN SELECT id FROM users WHERE referral_code IS NULL LOOP
+        LOOP
+            SELECT string_agg(substr('ABCDEFGHIJKLMNOPQRSTUVWXYZ234567', floor(random() * 32)::int + 1, 1), '')
+            INTO candidate
+            FROM generate_series(1, 8);
+
+            EXIT WHEN NOT EXISTS (SELECT 1 FROM users WHERE referral_code = candidate);
+        END LOOP;
+
+        UPDATE users SET referral_code = candidate WHERE id = target_id;
+    END LOOP;
+END $$;
+
+ALTER TABLE users ALTER COLUMN referral_code SET NOT NULL;
@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS idx_tasks_user_id_completed_at ON tasks (user_id, completed_at);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS point_adjustments (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    admin_id UUID NULL REFERENCES users(id) ON DELETE SET NULL,
+    delta INTEGER NOT NULL,
+    applied_delta INTEGER NOT NULL,
+    reason TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_point_adjustments_user_id ON point_adjustments (user_id, created_at DESC);
@@ -0,0 +1,2 @@
+-- Порядок таблицы лидеров по умолчанию: баллы, затем дата регистрации
+CREATE INDEX IF NOT EXISTS idx_users_points_created_at ON users (points DESC, created_at, id);
@@ -0,0 +1,5 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email VARCHAR(254) NULL;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMP WITH TIME ZONE NULL;
+
+-- Адрес необязателен, но уникален без учета регистра; NULL не участвует в проверке
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_lower ON users (lower(email));
@@ -0,0 +1,4 @@
+-- Имя пользователя уникально без учета регистра: "Alice" и "alice" не могут зарегистрироваться оба.
+-- Миграция завершится ошибкой, если такие имена уже есть; их нужно переименовать заранее.
+DROP INDEX IF EXISTS idx_users_username_lower;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username_lower ON users (lower(username));
@@ -0,0 +1,9 @@
+-- Снимок таблицы лидеров, который периодически пересчитывается сервером
+-- (leaderboard.materialized_view), чтобы не сортировать users при каждом запросе
+CREATE MATERIALIZED VIEW IF NOT EXISTS leaderboard_mv AS
+SELECT id, username, points, referrer_id, created_at, updated_at
+FROM users;
+
+-- Уникальный индекс необходим для REFRESH MATERIALIZED VIEW CONCURRENTLY
+CREATE UNIQUE INDEX IF NOT EXISTS idx_leaderboard_mv_id ON leaderboard_mv (id);
+CREATE INDEX IF NOT EXISTS idx_leaderboard_mv_points ON leaderboard_mv (points DESC, created_at, id);
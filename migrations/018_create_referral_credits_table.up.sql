@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS referral_credits (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    referrer_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    level INTEGER NOT NULL,
+    points INTEGER NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, level)
+);
+
+CREATE INDEX IF NOT EXISTS idx_referral_credits_referrer_id ON referral_credits (referrer_id);
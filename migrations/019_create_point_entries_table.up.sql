@@ -0,0 +1,14 @@
+-- Начисления баллов со сроком действия (points_expiry.ttl): remaining - часть начисления,
+-- которая еще не потрачена и будет списана с баланса после expires_at
+CREATE TABLE IF NOT EXISTS point_entries (
+    id BIGSERIAL PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    source VARCHAR(16) NOT NULL,
+    points INTEGER NOT NULL,
+    remaining INTEGER NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_point_entries_user_id_expires_at ON point_entries (user_id, expires_at) WHERE remaining > 0;
+CREATE INDEX IF NOT EXISTS idx_point_entries_expires_at ON point_entries (expires_at) WHERE remaining > 0;
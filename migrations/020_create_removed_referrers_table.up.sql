@@ -0,0 +1,8 @@
+-- Рефереры, удаленные пользователями: повторно указать удаленного реферера нельзя, иначе
+-- бонусы, не отмененные при удалении из-за нехватки баланса, начислялись бы снова
+CREATE TABLE IF NOT EXISTS removed_referrers (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    referrer_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    removed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, referrer_id)
+);
@@ -0,0 +1,97 @@
+// Package broadcast рассылает сигналы об изменениях подписчикам внутри процесса.
+// Сигналы не несут данных и объединяются: медленный подписчик получает один сигнал
+// о нескольких изменениях и сам запрашивает актуальное состояние.
+package broadcast
+
+import (
+	"errors"
+	"sync"
+)
+
+// Ошибки подписки
+var (
+	ErrTooManySubscribers = errors.New("too many subscribers")
+	ErrClosed             = errors.New("broadcaster closed")
+)
+
+// Broadcaster рассылает сигналы ограниченному числу подписчиков
+type Broadcaster struct {
+	mu             sync.Mutex
+	subscribers    map[chan struct{}]struct{}
+	maxSubscribers int
+	closed         bool
+}
+
+// New создает Broadcaster; maxSubscribers <= 0 означает отсутствие ограничения
+func New(maxSubscribers int) *Broadcaster {
+	return &Broadcaster{
+		subscribers:    make(map[chan struct{}]struct{}),
+		maxSubscribers: maxSubscribers,
+	}
+}
+
+// Subscribe регистрирует подписчика и возвращает канал сигналов и функцию отписки.
+// Канал закрывается после отписки или Close.
+func (b *Broadcaster) Subscribe() (<-chan struct{}, func(), error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, nil, ErrClosed
+	}
+	if b.maxSubscribers > 0 && len(b.subscribers) >= b.maxSubscribers {
+		return nil, nil, ErrTooManySubscribers
+	}
+
+	// Буфер на один сигнал: последующие сигналы до чтения объединяются с ним
+	ch := make(chan struct{}, 1)
+	b.subscribers[ch] = struct{}{}
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			if _, ok := b.subscribers[ch]; ok {
+				delete(b.subscribers, ch)
+				close(ch)
+			}
+		})
+	}, nil
+}
+
+// Publish отправляет сигнал всем подписчикам, не блокируясь на медленных
+func (b *Broadcaster) Publish() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Subscribers возвращает количество активных подписчиков
+func (b *Broadcaster) Subscribers() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return len(b.subscribers)
+}
+
+// Close закрывает каналы всех подписчиков и запрещает новые подписки
+func (b *Broadcaster) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+	b.closed = true
+	for ch := range b.subscribers {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+}
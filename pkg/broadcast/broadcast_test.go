@@ -0,0 +1,87 @@
+package broadcast
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPublishCoalesces(t *testing.T) {
+	b := New(0)
+	updates, unsubscribe, err := b.Subscribe()
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	defer unsubscribe()
+
+	b.Publish()
+	b.Publish()
+
+	if _, ok := <-updates; !ok {
+		t.Fatal("channel closed, want signal")
+	}
+	select {
+	case <-updates:
+		t.Error("second signal received, want signals coalesced")
+	default:
+	}
+}
+
+func TestSubscribeLimit(t *testing.T) {
+	b := New(1)
+	_, unsubscribe, err := b.Subscribe()
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+
+	if _, _, err := b.Subscribe(); !errors.Is(err, ErrTooManySubscribers) {
+		t.Errorf("Subscribe() over limit error = %v, want %v", err, ErrTooManySubscribers)
+	}
+
+	unsubscribe()
+	if b.Subscribers() != 0 {
+		t.Errorf("Subscribers() = %d after unsubscribe, want 0", b.Subscribers())
+	}
+	if _, _, err := b.Subscribe(); err != nil {
+		t.Errorf("Subscribe() after unsubscribe error = %v", err)
+	}
+}
+
+func TestUnsubscribeClosesChannel(t *testing.T) {
+	b := New(0)
+	updates, unsubscribe, err := b.Subscribe()
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+
+	unsubscribe()
+	unsubscribe()
+	if _, ok := <-updates; ok {
+		t.Error("signal received, want channel closed")
+	}
+}
+
+func TestClose(t *testing.T) {
+	b := New(0)
+	first, unsubscribe, err := b.Subscribe()
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	second, _, err := b.Subscribe()
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+
+	b.Close()
+	b.Close()
+	unsubscribe()
+
+	for _, updates := range []<-chan struct{}{first, second} {
+		if _, ok := <-updates; ok {
+			t.Error("signal received, want channel closed")
+		}
+	}
+	if _, _, err := b.Subscribe(); !errors.Is(err, ErrClosed) {
+		t.Errorf("Subscribe() after Close error = %v, want %v", err, ErrClosed)
+	}
+	b.Publish()
+}
@@ -0,0 +1,29 @@
+// Package buildinfo содержит сведения о сборке, задаваемые при компиляции:
+//
+//	go build -ldflags "-X github.com/DblMOKRQ/DeNet_test_task/pkg/buildinfo.Version=1.2.0 \
+//		-X github.com/DblMOKRQ/DeNet_test_task/pkg/buildinfo.Commit=$(git rev-parse --short HEAD) \
+//		-X github.com/DblMOKRQ/DeNet_test_task/pkg/buildinfo.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)" ./cmd
+package buildinfo
+
+// Значения по умолчанию используются при сборке без -ldflags
+var (
+	Version   = "dev"
+	Commit    = "dev"
+	BuildDate = "dev"
+)
+
+// Info представляет сведения о сборке
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+}
+
+// Get возвращает сведения о текущей сборке
+func Get() Info {
+	return Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildDate: BuildDate,
+	}
+}
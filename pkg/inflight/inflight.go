@@ -0,0 +1,89 @@
+package inflight
+
+import (
+	"context"
+	"sync"
+)
+
+// Tracker учитывает выполняющиеся фоновые операции, чтобы дождаться их при остановке приложения
+type Tracker struct {
+	mu     sync.Mutex
+	wg     sync.WaitGroup
+	active int
+	closed bool
+}
+
+// New создает новый экземпляр Tracker
+func New() *Tracker {
+	return &Tracker{}
+}
+
+// Start регистрирует операцию и возвращает функцию ее завершения.
+// После начала остановки новые операции не принимаются и ok равно false.
+func (t *Tracker) Start() (done func(), ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return func() {}, false
+	}
+
+	t.active++
+	t.wg.Add(1)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			t.mu.Lock()
+			t.active--
+			t.mu.Unlock()
+			t.wg.Done()
+		})
+	}, true
+}
+
+// Go выполняет fn в отдельной горутине как учитываемую операцию.
+// Возвращает false, если остановка уже началась и fn не запущена.
+func (t *Tracker) Go(fn func()) bool {
+	done, ok := t.Start()
+	if !ok {
+		return false
+	}
+
+	go func() {
+		defer done()
+		fn()
+	}()
+	return true
+}
+
+// Active возвращает количество выполняющихся операций
+func (t *Tracker) Active() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.active
+}
+
+// Wait запрещает новые операции и ждет завершения текущих, но не дольше ctx.
+// Возвращает количество операций, завершившихся за время ожидания, и ошибку контекста,
+// если дождаться всех не удалось.
+func (t *Tracker) Wait(ctx context.Context) (int, error) {
+	t.mu.Lock()
+	t.closed = true
+	pending := t.active
+	t.mu.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		return pending, nil
+	case <-ctx.Done():
+		return pending - t.Active(), ctx.Err()
+	}
+}
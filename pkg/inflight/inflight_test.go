@@ -0,0 +1,96 @@
+package inflight
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWaitBlocksUntilOperationCompletes(t *testing.T) {
+	tracker := New()
+	release := make(chan struct{})
+	finished := make(chan struct{})
+	if !tracker.Go(func() {
+		<-release
+		close(finished)
+	}) {
+		t.Fatal("Go() = false before shutdown")
+	}
+
+	waited := make(chan error, 1)
+	go func() {
+		drained, err := tracker.Wait(context.Background())
+		if err == nil && drained < 1 {
+			err = errors.New("no operations drained")
+		}
+		waited <- err
+	}()
+
+	select {
+	case <-waited:
+		t.Fatal("Wait() returned while the operation was still running")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Новые операции после начала остановки не принимаются, текущая продолжает работу
+	deadline := time.Now().Add(time.Second)
+	for {
+		done, ok := tracker.Start()
+		if !ok {
+			break
+		}
+		done()
+		if time.Now().After(deadline) {
+			t.Fatal("Start() still accepts operations after Wait() began")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if tracker.Active() != 1 {
+		t.Errorf("Active() = %d, want 1 while the operation runs", tracker.Active())
+	}
+
+	close(release)
+	if err := <-waited; err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+	select {
+	case <-finished:
+	default:
+		t.Error("Wait() returned before the operation finished")
+	}
+}
+
+func TestWaitStopsAtContextDeadline(t *testing.T) {
+	tracker := New()
+	done, ok := tracker.Start()
+	if !ok {
+		t.Fatal("Start() ok = false before shutdown")
+	}
+	defer done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	drained, err := tracker.Wait(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Wait() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if drained != 0 || tracker.Active() != 1 {
+		t.Errorf("drained = %d, active = %d, want 0 and 1", drained, tracker.Active())
+	}
+}
+
+func TestDoneIsIdempotent(t *testing.T) {
+	tracker := New()
+	done, _ := tracker.Start()
+	done()
+	done()
+
+	if active := tracker.Active(); active != 0 {
+		t.Errorf("Active() = %d, want 0", active)
+	}
+	if _, err := tracker.Wait(context.Background()); err != nil {
+		t.Errorf("Wait() error = %v", err)
+	}
+}
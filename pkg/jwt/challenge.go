@@ -0,0 +1,40 @@
+package jwt
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ChallengeHeader заголовок ответа 401 с описанием требуемой схемы аутентификации
+const ChallengeHeader = "WWW-Authenticate"
+
+// bearerPrefix схема аутентификации в заголовке Authorization (RFC 6750)
+const bearerPrefix = "Bearer "
+
+// BearerToken возвращает токен из значения заголовка Authorization. Префикс схемы
+// "Bearer " необязателен и сравнивается без учета регистра.
+func BearerToken(header string) string {
+	if len(header) >= len(bearerPrefix) && strings.EqualFold(header[:len(bearerPrefix)], bearerPrefix) {
+		return strings.TrimSpace(header[len(bearerPrefix):])
+	}
+	return header
+}
+
+// Challenge возвращает значение заголовка WWW-Authenticate по RFC 6750.
+// При отсутствии токена (err == nil) указывается только схема Bearer, иначе -
+// код ошибки invalid_token с описанием причины отказа.
+func Challenge(err error) string {
+	if err == nil {
+		return "Bearer"
+	}
+
+	description := ErrInvalidToken.Error()
+	for _, known := range []error{ErrExpiredToken, ErrRevokedToken, ErrInvalidClaims} {
+		if errors.Is(err, known) {
+			description = known.Error()
+			break
+		}
+	}
+	return fmt.Sprintf(`Bearer error="invalid_token", error_description=%q`, description)
+}
@@ -4,7 +4,8 @@ import (
 	"errors"
 	"time"
 
-	"github.com/golang-jwt/jwt/v4"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"go.uber.org/zap"
 )
 
@@ -13,50 +14,93 @@ var (
 	ErrInvalidToken  = errors.New("invalid token")
 	ErrExpiredToken  = errors.New("token expired")
 	ErrInvalidClaims = errors.New("invalid token claims")
+	ErrRevokedToken  = errors.New("token revoked")
 )
 
 // Claims представляет данные, хранящиеся в JWT токене
 type Claims struct {
 	UserID string `json:"user_id"`
+	Role   string `json:"role,omitempty"`
 	jwt.RegisteredClaims
 }
 
 // Service предоставляет методы для работы с JWT
 type Service struct {
-	secretKey     string
+	method        jwt.SigningMethod
+	signKey       interface{}
+	verifyKey     interface{}
 	tokenDuration time.Duration
+	leeway        time.Duration
+	parserOpts    []jwt.ParserOption
+	issuer        string
+	audience      string
+	revoked       *revocationList
 	log           *zap.Logger
 }
 
 // NewService создает новый экземпляр JWT сервиса
-func NewService(secretKey string, tokenDuration time.Duration, log *zap.Logger) *Service {
+func NewService(opts Options, log *zap.Logger) (*Service, error) {
+	method, signKey, verifyKey, err := loadKeys(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Допуск расхождения часов применяется к exp, nbf и iat
+	parserOpts := []jwt.ParserOption{
+		jwt.WithLeeway(opts.Leeway),
+		jwt.WithIssuedAt(),
+	}
+	if opts.Issuer != "" {
+		parserOpts = append(parserOpts, jwt.WithIssuer(opts.Issuer))
+	}
+	if opts.Audience != "" {
+		parserOpts = append(parserOpts, jwt.WithAudience(opts.Audience))
+	}
 
 	return &Service{
-		secretKey:     secretKey,
-		tokenDuration: tokenDuration,
+		method:        method,
+		signKey:       signKey,
+		verifyKey:     verifyKey,
+		tokenDuration: opts.TokenDuration,
+		leeway:        opts.Leeway,
+		parserOpts:    parserOpts,
+		issuer:        opts.Issuer,
+		audience:      opts.Audience,
+		revoked:       newRevocationList(),
 		log:           log.Named("jwt_service"),
-	}
+	}, nil
 }
 
 // GenerateToken создает новый JWT токен для пользователя
-func (s *Service) GenerateToken(userID string) (string, error) {
-	s.log.Debug("Generating token", zap.String("user_id", userID))
+func (s *Service) GenerateToken(userID string, role string) (string, error) {
+	s.log.Debug("Generating token", zap.String("user_id", userID), zap.String("role", role))
+
+	if s.signKey == nil {
+		s.log.Error("Token signing is not configured", zap.String("algorithm", s.method.Alg()))
+		return "", ErrSigningUnavailable
+	}
 
 	now := time.Now()
 	expiresAt := now.Add(s.tokenDuration)
 
 	claims := &Claims{
 		UserID: userID,
+		Role:   role,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Issuer:    s.issuer,
 			ExpiresAt: jwt.NewNumericDate(expiresAt),
 			IssuedAt:  jwt.NewNumericDate(now),
 			NotBefore: jwt.NewNumericDate(now),
 		},
 	}
+	if s.audience != "" {
+		claims.Audience = jwt.ClaimStrings{s.audience}
+	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token := jwt.NewWithClaims(s.method, claims)
 
-	tokenString, err := token.SignedString([]byte(s.secretKey))
+	tokenString, err := token.SignedString(s.signKey)
 	if err != nil {
 		s.log.Error("Failed to sign token",
 			zap.String("user_id", userID),
@@ -78,14 +122,16 @@ func (s *Service) ValidateToken(tokenString string) (*Claims, error) {
 		tokenString,
 		&Claims{},
 		func(token *jwt.Token) (interface{}, error) {
-			// Проверка алгоритма подписи
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			// Проверка алгоритма подписи: принимается только настроенный алгоритм
+			if token.Method.Alg() != s.method.Alg() {
 				s.log.Warn("Unexpected signing method",
-					zap.String("method", token.Method.Alg()))
+					zap.String("method", token.Method.Alg()),
+					zap.String("expected", s.method.Alg()))
 				return nil, ErrInvalidToken
 			}
-			return []byte(s.secretKey), nil
+			return s.verifyKey, nil
 		},
+		s.parserOpts...,
 	)
 
 	if err != nil {
@@ -103,6 +149,32 @@ func (s *Service) ValidateToken(tokenString string) (*Claims, error) {
 		return nil, ErrInvalidClaims
 	}
 
+	if claims.ID != "" && s.revoked.contains(claims.ID) {
+		s.log.Warn("Token revoked", zap.String("jti", claims.ID))
+		return nil, ErrRevokedToken
+	}
+
 	s.log.Debug("Token validated successfully", zap.String("user_id", claims.UserID))
 	return claims, nil
 }
+
+// RevokeToken отзывает токен: до истечения срока действия с учетом допуска расхождения часов,
+// пока парсер еще принимает токен, он будет отклоняться ValidateToken
+func (s *Service) RevokeToken(claims *Claims) error {
+	if claims.ID == "" {
+		s.log.Warn("Token without jti cannot be revoked", zap.String("user_id", claims.UserID))
+		return ErrInvalidClaims
+	}
+
+	expiresAt := time.Now().Add(s.tokenDuration)
+	if claims.ExpiresAt != nil {
+		expiresAt = claims.ExpiresAt.Time
+	}
+	expiresAt = expiresAt.Add(s.leeway)
+
+	s.revoked.add(claims.ID, expiresAt)
+	s.log.Info("Token revoked",
+		zap.String("user_id", claims.UserID),
+		zap.String("jti", claims.ID))
+	return nil
+}
@@ -0,0 +1,347 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+)
+
+// newHS256Service создает сервис с подписью HS256
+func newHS256Service(t *testing.T) *Service {
+	t.Helper()
+
+	s, err := NewService(Options{SecretKey: "test-secret", TokenDuration: time.Hour}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewService() error = %v", err)
+	}
+	return s
+}
+
+// writeKeyPair сохраняет ключи в PEM файлы и возвращает пути к закрытому и открытому ключам
+func writeKeyPair(t *testing.T, private crypto.Signer) (string, string) {
+	t.Helper()
+
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatalf("marshal private key: %v", err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(private.Public())
+	if err != nil {
+		t.Fatalf("marshal public key: %v", err)
+	}
+
+	dir := t.TempDir()
+	write := func(name, blockType string, der []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+		return path
+	}
+	return write("private.pem", "PRIVATE KEY", privateDER), write("public.pem", "PUBLIC KEY", publicDER)
+}
+
+// mustIssue выпускает токен и возвращает его вместе с разобранными claims
+func mustIssue(t *testing.T, s *Service, userID string) (string, *Claims) {
+	t.Helper()
+
+	token, err := s.GenerateToken(userID, "user")
+	if err != nil {
+		t.Fatalf("GenerateToken() error = %v", err)
+	}
+	claims, err := s.ValidateToken(token)
+	if err != nil {
+		t.Fatalf("ValidateToken() error = %v", err)
+	}
+	return token, claims
+}
+
+func TestRevokeToken(t *testing.T) {
+	s := newHS256Service(t)
+	revoked, claims := mustIssue(t, s, "user-1")
+	other, otherClaims := mustIssue(t, s, "user-1")
+
+	if claims.ID == "" || claims.ID == otherClaims.ID {
+		t.Fatalf("jti = %q and %q, want unique non-empty identifiers", claims.ID, otherClaims.ID)
+	}
+
+	if err := s.RevokeToken(claims); err != nil {
+		t.Fatalf("RevokeToken() error = %v", err)
+	}
+	if _, err := s.ValidateToken(revoked); !errors.Is(err, ErrRevokedToken) {
+		t.Errorf("ValidateToken(revoked) error = %v, want %v", err, ErrRevokedToken)
+	}
+	if _, err := s.ValidateToken(other); err != nil {
+		t.Errorf("ValidateToken(other token of the same user) error = %v", err)
+	}
+}
+
+func TestRevokeTokenWithoutID(t *testing.T) {
+	s := newHS256Service(t)
+
+	if err := s.RevokeToken(&Claims{UserID: "user-1"}); !errors.Is(err, ErrInvalidClaims) {
+		t.Errorf("RevokeToken() error = %v, want %v", err, ErrInvalidClaims)
+	}
+}
+
+func TestRevokeTokenWithinLeeway(t *testing.T) {
+	// Токен истек 2 секунды назад, но еще принимается благодаря допуску расхождения часов
+	s, err := NewService(Options{SecretKey: "test-secret", TokenDuration: -2 * time.Second, Leeway: 30 * time.Second}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewService() error = %v", err)
+	}
+	revoked, claims := mustIssue(t, s, "user-1")
+
+	if err := s.RevokeToken(claims); err != nil {
+		t.Fatalf("RevokeToken() error = %v", err)
+	}
+	if _, err := s.ValidateToken(revoked); !errors.Is(err, ErrRevokedToken) {
+		t.Errorf("ValidateToken(revoked within leeway) error = %v, want %v", err, ErrRevokedToken)
+	}
+}
+
+func TestRevocationListEvictsExpired(t *testing.T) {
+	l := newRevocationList()
+	l.add("expired", time.Now().Add(-time.Minute))
+	l.add("active", time.Now().Add(time.Minute))
+
+	if l.contains("expired") {
+		t.Error("expired entry is still listed")
+	}
+	if !l.contains("active") {
+		t.Error("active entry is not listed")
+	}
+	if _, ok := l.revoked["expired"]; ok {
+		t.Error("expired entry was not evicted")
+	}
+}
+
+func TestAsymmetricAlgorithms(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate RSA key: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate EC key: %v", err)
+	}
+
+	for _, tt := range []struct {
+		algorithm string
+		key       crypto.Signer
+	}{
+		{algorithm: AlgorithmRS256, key: rsaKey},
+		{algorithm: AlgorithmES256, key: ecKey},
+	} {
+		t.Run(tt.algorithm, func(t *testing.T) {
+			privatePath, publicPath := writeKeyPair(t, tt.key)
+			signer, err := NewService(Options{
+				Algorithm:      tt.algorithm,
+				PrivateKeyPath: privatePath,
+				PublicKeyPath:  publicPath,
+				TokenDuration:  time.Hour,
+			}, zap.NewNop())
+			if err != nil {
+				t.Fatalf("NewService() error = %v", err)
+			}
+			verifier, err := NewService(Options{Algorithm: tt.algorithm, PublicKeyPath: publicPath, TokenDuration: time.Hour}, zap.NewNop())
+			if err != nil {
+				t.Fatalf("NewService(verify only) error = %v", err)
+			}
+
+			token, err := signer.GenerateToken("user-1", "admin")
+			if err != nil {
+				t.Fatalf("GenerateToken() error = %v", err)
+			}
+			claims, err := verifier.ValidateToken(token)
+			if err != nil {
+				t.Fatalf("ValidateToken() error = %v", err)
+			}
+			if claims.UserID != "user-1" || claims.Role != "admin" {
+				t.Errorf("claims = %+v, want user-1 with role admin", claims)
+			}
+
+			if _, err := verifier.GenerateToken("user-1", "user"); !errors.Is(err, ErrSigningUnavailable) {
+				t.Errorf("verify-only GenerateToken() error = %v, want %v", err, ErrSigningUnavailable)
+			}
+
+			// Токен с другим алгоритмом отклоняется, даже если подпись корректна для своего ключа
+			hsToken, err := newHS256Service(t).GenerateToken("user-1", "admin")
+			if err != nil {
+				t.Fatalf("GenerateToken(HS256) error = %v", err)
+			}
+			if _, err := verifier.ValidateToken(hsToken); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("ValidateToken(HS256 token) error = %v, want %v", err, ErrInvalidToken)
+			}
+		})
+	}
+}
+
+func TestNewServiceInvalidOptions(t *testing.T) {
+	for name, opts := range map[string]Options{
+		"HS256 without secret":  {Algorithm: AlgorithmHS256},
+		"RS256 without key":     {Algorithm: AlgorithmRS256},
+		"RS256 missing key":     {Algorithm: AlgorithmRS256, PublicKeyPath: filepath.Join(t.TempDir(), "missing.pem")},
+		"unsupported algorithm": {Algorithm: "none", SecretKey: "test-secret"},
+	} {
+		if _, err := NewService(opts, zap.NewNop()); err == nil {
+			t.Errorf("%s: NewService() error = nil, want error", name)
+		}
+	}
+}
+
+func TestIssuerAudience(t *testing.T) {
+	newService := func(issuer, audience string) *Service {
+		t.Helper()
+
+		s, err := NewService(Options{SecretKey: "test-secret", TokenDuration: time.Hour, Issuer: issuer, Audience: audience}, zap.NewNop())
+		if err != nil {
+			t.Fatalf("NewService() error = %v", err)
+		}
+		return s
+	}
+
+	verifier := newService("user-points", "user-points-api")
+	_, claims := mustIssue(t, verifier, "user-1")
+	if claims.Issuer != "user-points" || len(claims.Audience) != 1 || claims.Audience[0] != "user-points-api" {
+		t.Errorf("claims iss = %q, aud = %v, want user-points and [user-points-api]", claims.Issuer, claims.Audience)
+	}
+
+	tests := []struct {
+		name     string
+		issuer   *Service
+		verifier *Service
+		wantErr  error
+	}{
+		{name: "matching", issuer: verifier, verifier: verifier},
+		{name: "other issuer", issuer: newService("billing", "user-points-api"), verifier: verifier, wantErr: ErrInvalidToken},
+		{name: "other audience", issuer: newService("user-points", "billing-api"), verifier: verifier, wantErr: ErrInvalidToken},
+		{name: "token without claims", issuer: newService("", ""), verifier: verifier, wantErr: ErrInvalidToken},
+		{name: "checks disabled", issuer: newService("billing", "billing-api"), verifier: newService("", "")},
+		{name: "only audience checked", issuer: newService("billing", "user-points-api"), verifier: newService("", "user-points-api")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := tt.issuer.GenerateToken("user-1", "user")
+			if err != nil {
+				t.Fatalf("GenerateToken() error = %v", err)
+			}
+
+			_, err = tt.verifier.ValidateToken(token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("ValidateToken() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLeeway(t *testing.T) {
+	s, err := NewService(Options{SecretKey: "test-secret", TokenDuration: time.Hour, Leeway: 30 * time.Second}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewService() error = %v", err)
+	}
+	strict, err := NewService(Options{SecretKey: "test-secret", TokenDuration: time.Hour}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewService() error = %v", err)
+	}
+
+	// sign подписывает токен с временными claims, смещенными относительно текущего момента
+	sign := func(issuedAt, expiresAt time.Duration) string {
+		t.Helper()
+
+		now := time.Now()
+		token, err := jwt.NewWithClaims(s.method, Claims{
+			UserID: "user-1",
+			Role:   "user",
+			RegisteredClaims: jwt.RegisteredClaims{
+				IssuedAt:  jwt.NewNumericDate(now.Add(issuedAt)),
+				NotBefore: jwt.NewNumericDate(now.Add(issuedAt)),
+				ExpiresAt: jwt.NewNumericDate(now.Add(expiresAt)),
+			},
+		}).SignedString(s.signKey)
+		if err != nil {
+			t.Fatalf("sign token: %v", err)
+		}
+		return token
+	}
+
+	tests := []struct {
+		name       string
+		token      string
+		wantErr    error
+		wantStrict error
+	}{
+		{name: "issued slightly in the future", token: sign(10*time.Second, time.Hour), wantStrict: ErrInvalidToken},
+		{name: "issued beyond leeway", token: sign(time.Minute, time.Hour), wantErr: ErrInvalidToken, wantStrict: ErrInvalidToken},
+		{name: "expired within leeway", token: sign(-time.Hour, -10*time.Second), wantStrict: ErrExpiredToken},
+		{name: "expired beyond leeway", token: sign(-time.Hour, -time.Minute), wantErr: ErrExpiredToken, wantStrict: ErrExpiredToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := s.ValidateToken(tt.token); !errors.Is(err, tt.wantErr) {
+				t.Errorf("ValidateToken() error = %v, want %v", err, tt.wantErr)
+			}
+			if _, err := strict.ValidateToken(tt.token); !errors.Is(err, tt.wantStrict) {
+				t.Errorf("ValidateToken() without leeway error = %v, want %v", err, tt.wantStrict)
+			}
+		})
+	}
+}
+
+func TestBearerToken(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{header: "token-value", want: "token-value"},
+		{header: "Bearer token-value", want: "token-value"},
+		{header: "bearer token-value", want: "token-value"},
+		{header: "BEARER  token-value ", want: "token-value"},
+		{header: "Bearer ", want: ""},
+		{header: "", want: ""},
+		{header: "Basic dXNlcjpwYXNz", want: "Basic dXNlcjpwYXNz"},
+	}
+
+	for _, tt := range tests {
+		if got := BearerToken(tt.header); got != tt.want {
+			t.Errorf("BearerToken(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestChallenge(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "no token", err: nil, want: "Bearer"},
+		{name: "expired", err: ErrExpiredToken, want: `Bearer error="invalid_token", error_description="token expired"`},
+		{name: "revoked", err: ErrRevokedToken, want: `Bearer error="invalid_token", error_description="` + ErrRevokedToken.Error() + `"`},
+		{name: "wrapped claims error", err: fmt.Errorf("parse: %w", ErrInvalidClaims), want: `Bearer error="invalid_token", error_description="invalid token claims"`},
+		{name: "unknown error", err: errors.New("malformed"), want: `Bearer error="invalid_token", error_description="` + ErrInvalidToken.Error() + `"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Challenge(tt.err); got != tt.want {
+				t.Errorf("Challenge() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package jwt
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Поддерживаемые алгоритмы подписи
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmES256 = "ES256"
+)
+
+// ErrSigningUnavailable возвращается, если сервис настроен только на проверку токенов
+var ErrSigningUnavailable = errors.New("token signing key is not configured")
+
+// Options содержит параметры подписи и проверки токенов
+type Options struct {
+	// Algorithm алгоритм подписи: HS256 (по умолчанию), RS256 или ES256
+	Algorithm string
+	// SecretKey общий секрет для HS256
+	SecretKey string
+	// PrivateKeyPath путь к PEM файлу закрытого ключа для RS256/ES256.
+	// Может отсутствовать у сервисов, которые только проверяют токены.
+	PrivateKeyPath string
+	// PublicKeyPath путь к PEM файлу открытого ключа для RS256/ES256
+	PublicKeyPath string
+	// TokenDuration срок действия выдаваемых токенов
+	TokenDuration time.Duration
+	// Issuer и Audience записываются в claims iss и aud выдаваемых токенов и проверяются
+	// при валидации. Пустое значение отключает проверку (токены, выданные до их настройки, принимаются).
+	Issuer   string
+	Audience string
+	// Leeway допустимое расхождение часов при проверке exp, nbf и iat. Токен принимается
+	// на Leeway дольше срока действия, поэтому значение должно быть небольшим.
+	Leeway time.Duration
+}
+
+// loadKeys возвращает метод подписи и ключи для подписи и проверки токенов
+func loadKeys(opts Options) (jwt.SigningMethod, interface{}, interface{}, error) {
+	switch opts.Algorithm {
+	case "", AlgorithmHS256:
+		if opts.SecretKey == "" {
+			return nil, nil, nil, errors.New("secret key is required for HS256")
+		}
+		key := []byte(opts.SecretKey)
+		return jwt.SigningMethodHS256, key, key, nil
+
+	case AlgorithmRS256:
+		verifyKey, err := readPEM(opts.PublicKeyPath, jwt.ParseRSAPublicKeyFromPEM)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to load RSA public key: %w", err)
+		}
+		var signKey interface{}
+		if opts.PrivateKeyPath != "" {
+			if signKey, err = readPEM(opts.PrivateKeyPath, jwt.ParseRSAPrivateKeyFromPEM); err != nil {
+				return nil, nil, nil, fmt.Errorf("failed to load RSA private key: %w", err)
+			}
+		}
+		return jwt.SigningMethodRS256, signKey, verifyKey, nil
+
+	case AlgorithmES256:
+		verifyKey, err := readPEM(opts.PublicKeyPath, jwt.ParseECPublicKeyFromPEM)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to load EC public key: %w", err)
+		}
+		var signKey interface{}
+		if opts.PrivateKeyPath != "" {
+			if signKey, err = readPEM(opts.PrivateKeyPath, jwt.ParseECPrivateKeyFromPEM); err != nil {
+				return nil, nil, nil, fmt.Errorf("failed to load EC private key: %w", err)
+			}
+		}
+		return jwt.SigningMethodES256, signKey, verifyKey, nil
+
+	default:
+		return nil, nil, nil, fmt.Errorf("unsupported signing algorithm %q", opts.Algorithm)
+	}
+}
+
+// readPEM читает PEM файл и разбирает ключ указанной функцией
+func readPEM[K any](path string, parse func([]byte) (K, error)) (K, error) {
+	var zero K
+	if path == "" {
+		return zero, errors.New("key path is required")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return zero, err
+	}
+	return parse(data)
+}
@@ -0,0 +1,53 @@
+package jwt
+
+import (
+	"sync"
+	"time"
+)
+
+// revocationList хранит идентификаторы отозванных токенов до истечения их срока действия
+type revocationList struct {
+	mu      sync.Mutex
+	revoked map[string]time.Time
+}
+
+func newRevocationList() *revocationList {
+	return &revocationList{
+		revoked: make(map[string]time.Time),
+	}
+}
+
+// add отзывает токен с идентификатором jti до момента expiresAt
+func (l *revocationList) add(jti string, expiresAt time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.evictExpired(time.Now())
+	l.revoked[jti] = expiresAt
+}
+
+// contains проверяет, отозван ли токен с идентификатором jti
+func (l *revocationList) contains(jti string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	expiresAt, ok := l.revoked[jti]
+	if !ok {
+		return false
+	}
+	if time.Now().After(expiresAt) {
+		// Истекший токен отклоняется и без списка отзыва
+		delete(l.revoked, jti)
+		return false
+	}
+	return true
+}
+
+// evictExpired удаляет записи о токенах, срок действия которых уже истек
+func (l *revocationList) evictExpired(now time.Time) {
+	for jti, expiresAt := range l.revoked {
+		if now.After(expiresAt) {
+			delete(l.revoked, jti)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package logger
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+// requestIDKey ключ контекста для идентификатора запроса
+type requestIDKey struct{}
+
+// WithRequestID сохраняет идентификатор запроса в контексте
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext возвращает идентификатор запроса из контекста
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// FromContext возвращает логгер, дополненный идентификатором запроса из контекста
+func FromContext(ctx context.Context, log *zap.Logger) *zap.Logger {
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		return log.With(zap.String("request_id", requestID))
+	}
+	return log
+}
@@ -0,0 +1,48 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// resolveLevel возвращает уровень логирования: переменная окружения LOG_LEVEL
+// имеет приоритет над значением из конфигурации
+func resolveLevel(configured string) string {
+	if level := os.Getenv("LOG_LEVEL"); level != "" {
+		return level
+	}
+	return configured
+}
+
+// parseLevel разбирает название уровня логирования
+func parseLevel(name string) (zapcore.Level, error) {
+	switch name {
+	case "debug":
+		return zapcore.DebugLevel, nil
+	case "info":
+		return zapcore.InfoLevel, nil
+	case "warn":
+		return zapcore.WarnLevel, nil
+	case "error":
+		return zapcore.ErrorLevel, nil
+	default:
+		return zapcore.InfoLevel, fmt.Errorf("unknown log level %q", name)
+	}
+}
+
+// ReloadLevel меняет уровень работающего логгера на LOG_LEVEL или configured
+// и возвращает предыдущий и новый уровни. При неизвестном названии уровень не меняется.
+func ReloadLevel(level zap.AtomicLevel, configured string) (zapcore.Level, zapcore.Level, error) {
+	previous := level.Level()
+
+	next, err := parseLevel(resolveLevel(configured))
+	if err != nil {
+		return previous, previous, err
+	}
+
+	level.SetLevel(next)
+	return previous, next, nil
+}
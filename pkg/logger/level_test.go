@@ -0,0 +1,57 @@
+package logger
+
+import (
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestReloadLevel(t *testing.T) {
+	tests := []struct {
+		name       string
+		env        string
+		configured string
+		want       zapcore.Level
+		wantErr    bool
+	}{
+		{name: "configured level", configured: "debug", want: zapcore.DebugLevel},
+		{name: "environment overrides config", env: "error", configured: "debug", want: zapcore.ErrorLevel},
+		{name: "unknown level keeps current", configured: "verbose", want: zapcore.InfoLevel, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("LOG_LEVEL", tt.env)
+			level := zap.NewAtomicLevelAt(zapcore.InfoLevel)
+
+			previous, current, err := ReloadLevel(level, tt.configured)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReloadLevel() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if previous != zapcore.InfoLevel || current != tt.want || level.Level() != tt.want {
+				t.Errorf("ReloadLevel() = %s -> %s, level %s, want info -> %s", previous, current, level.Level(), tt.want)
+			}
+		})
+	}
+}
+
+func TestNewLoggerLevelIsReloadable(t *testing.T) {
+	t.Setenv("LOG_LEVEL", "")
+	t.Setenv("LOG_OUTPUT", "")
+
+	log, level, err := NewLogger(Options{Level: "info", Output: "stderr"})
+	if err != nil {
+		t.Fatalf("NewLogger() error = %v", err)
+	}
+	if log.Core().Enabled(zapcore.DebugLevel) {
+		t.Fatal("debug enabled at info level")
+	}
+
+	if _, _, err := ReloadLevel(level, "debug"); err != nil {
+		t.Fatalf("ReloadLevel() error = %v", err)
+	}
+	if !log.Core().Enabled(zapcore.DebugLevel) {
+		t.Error("debug not enabled after reloading the level")
+	}
+}
@@ -7,34 +7,85 @@ import (
 	"go.uber.org/zap/zapcore"
 )
 
-// NewLogger создает и настраивает новый экземпляр логгера
-func NewLogger() (*zap.Logger, error) {
-	// Определение уровня логирования из переменной окружения или по умолчанию
-	logLevel := os.Getenv("LOG_LEVEL")
-	var level zapcore.Level
+// Options содержит параметры вывода логов
+type Options struct {
+	// Level уровень логирования: debug, info, warn или error (переменная окружения LOG_LEVEL имеет приоритет)
+	Level string
+	// Output назначение вывода: stdout, stderr или путь к файлу (переменная окружения LOG_OUTPUT имеет приоритет)
+	Output string
+	// MaxSizeMB размер файла в мегабайтах, после которого выполняется ротация
+	MaxSizeMB int
+	// MaxBackups количество хранимых ротированных файлов
+	MaxBackups int
+	// MaxAgeDays срок хранения ротированных файлов в днях
+	MaxAgeDays int
+	// Development включает режим разработки, в котором по умолчанию сэмплирование отключено
+	Development bool
+	// Sampling включает сэмплирование; nil - по умолчанию для режима (включено вне режима разработки).
+	// Переменная окружения LOG_SAMPLING (on/off) имеет приоритет.
+	Sampling *bool
+	// SamplingInitial количество одинаковых записей в секунду, записываемых без сэмплирования
+	SamplingInitial int
+	// SamplingThereafter после SamplingInitial записывается каждая SamplingThereafter-я запись
+	SamplingThereafter int
+}
+
+// samplingConfig возвращает настройки сэмплирования или nil, если оно отключено.
+// Сэмплирование ограничивает объем логов при высокой нагрузке, но отбрасывает часть
+// повторяющихся записей ниже уровня Error (см. sampleBelowError).
+func samplingConfig(opts Options) *zap.SamplingConfig {
+	enabled := !opts.Development
+	if opts.Sampling != nil {
+		enabled = *opts.Sampling
+	}
+	switch os.Getenv("LOG_SAMPLING") {
+	case "on", "true", "1":
+		enabled = true
+	case "off", "false", "0":
+		enabled = false
+	}
+	if !enabled {
+		return nil
+	}
 
-	switch logLevel {
-	case "debug":
-		level = zapcore.DebugLevel
-	case "info":
-		level = zapcore.InfoLevel
-	case "warn":
-		level = zapcore.WarnLevel
-	case "error":
-		level = zapcore.ErrorLevel
-	default:
-		level = zapcore.InfoLevel // По умолчанию уровень Info
+	initial, thereafter := opts.SamplingInitial, opts.SamplingThereafter
+	if initial <= 0 {
+		initial = 100
+	}
+	if thereafter <= 0 {
+		thereafter = 100
+	}
+	return &zap.SamplingConfig{
+		Initial:    initial,
+		Thereafter: thereafter,
+	}
+}
+
+// NewLogger создает и настраивает новый экземпляр логгера.
+// Возвращаемый AtomicLevel позволяет менять уровень логирования без перезапуска (см. ReloadLevel).
+func NewLogger(opts Options) (*zap.Logger, zap.AtomicLevel, error) {
+	// Определение уровня логирования из переменной окружения или конфигурации;
+	// по умолчанию уровень Info
+	level, _ := parseLevel(resolveLevel(opts.Level))
+	atomicLevel := zap.NewAtomicLevelAt(level)
+
+	// Определение назначения вывода
+	if output := os.Getenv("LOG_OUTPUT"); output != "" {
+		opts.Output = output
+	}
+	output, err := outputPath(opts)
+	if err != nil {
+		return nil, atomicLevel, err
 	}
 
 	// Настройка конфигурации логгера
+	// Сэмплирование подключается через WrapCore, а не zap.Config.Sampling,
+	// чтобы не затрагивать записи уровня Error и выше
+	sampling := samplingConfig(opts)
 	config := zap.Config{
-		Level:       zap.NewAtomicLevelAt(level),
-		Development: false,
-		Sampling: &zap.SamplingConfig{
-			Initial:    100,
-			Thereafter: 100,
-		},
-		Encoding: "json",
+		Level:       atomicLevel,
+		Development: opts.Development,
+		Encoding:    "json",
 		EncoderConfig: zapcore.EncoderConfig{
 			TimeKey:        "ts",
 			LevelKey:       "level",
@@ -49,19 +100,27 @@ func NewLogger() (*zap.Logger, error) {
 			EncodeDuration: zapcore.SecondsDurationEncoder,
 			EncodeCaller:   zapcore.ShortCallerEncoder,
 		},
-		OutputPaths:      []string{"stdout"},
+		OutputPaths:      []string{output},
 		ErrorOutputPaths: []string{"stderr"},
 	}
 
 	// Создание логгера
-	logger, err := config.Build(zap.AddCallerSkip(1))
+	buildOpts := []zap.Option{zap.AddCallerSkip(1)}
+	if sampling != nil {
+		buildOpts = append(buildOpts, zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			return sampleBelowError(core, sampling)
+		}))
+	}
+	logger, err := config.Build(buildOpts...)
 	if err != nil {
-		return nil, err
+		return nil, atomicLevel, err
 	}
 
 	logger.Info("Logger initialized",
 		zap.String("level", level.String()),
-		zap.String("encoding", config.Encoding))
+		zap.String("encoding", config.Encoding),
+		zap.String("output", opts.Output),
+		zap.Bool("sampling", sampling != nil))
 
-	return logger, nil
+	return logger, atomicLevel, nil
 }
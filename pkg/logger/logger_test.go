@@ -0,0 +1,44 @@
+package logger
+
+import "testing"
+
+func TestSamplingConfig(t *testing.T) {
+	enabled, disabled := true, false
+
+	tests := []struct {
+		name        string
+		opts        Options
+		env         string
+		wantEnabled bool
+	}{
+		{name: "nil sampling defaults to enabled", opts: Options{}, wantEnabled: true},
+		{name: "nil sampling in development defaults to disabled", opts: Options{Development: true}},
+		{name: "explicitly disabled", opts: Options{Sampling: &disabled}},
+		{name: "explicitly enabled in development", opts: Options{Development: true, Sampling: &enabled}, wantEnabled: true},
+		{name: "environment overrides config", opts: Options{Sampling: &enabled}, env: "off"},
+		{name: "environment enables in development", opts: Options{Development: true}, env: "on", wantEnabled: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("LOG_SAMPLING", tt.env)
+
+			got := samplingConfig(tt.opts)
+			if (got != nil) != tt.wantEnabled {
+				t.Fatalf("samplingConfig() = %+v, want enabled %t", got, tt.wantEnabled)
+			}
+			if got != nil && (got.Initial != 100 || got.Thereafter != 100) {
+				t.Errorf("samplingConfig() = %+v, want default initial and thereafter of 100", got)
+			}
+		})
+	}
+}
+
+func TestSamplingConfigCustomRates(t *testing.T) {
+	t.Setenv("LOG_SAMPLING", "")
+
+	got := samplingConfig(Options{SamplingInitial: 10, SamplingThereafter: 5})
+	if got == nil || got.Initial != 10 || got.Thereafter != 5 {
+		t.Errorf("samplingConfig() = %+v, want initial 10 and thereafter 5", got)
+	}
+}
@@ -0,0 +1,80 @@
+package logger
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"go.uber.org/zap"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// rotatingScheme схема URL для вывода в файл с ротацией через lumberjack
+const rotatingScheme = "lumberjack"
+
+var registerSinkOnce sync.Once
+
+// rotatingSink адаптирует lumberjack.Logger к интерфейсу zap.Sink
+type rotatingSink struct {
+	*lumberjack.Logger
+}
+
+// Sync ничего не делает: lumberjack пишет в файл без буферизации
+func (rotatingSink) Sync() error {
+	return nil
+}
+
+// registerRotatingSink регистрирует в zap фабрику файлового вывода с ротацией
+func registerRotatingSink() error {
+	var err error
+	registerSinkOnce.Do(func() {
+		err = zap.RegisterSink(rotatingScheme, func(u *url.URL) (zap.Sink, error) {
+			query := u.Query()
+			maxSize, _ := strconv.Atoi(query.Get("max_size"))
+			maxBackups, _ := strconv.Atoi(query.Get("max_backups"))
+			maxAge, _ := strconv.Atoi(query.Get("max_age"))
+
+			return rotatingSink{&lumberjack.Logger{
+				Filename:   u.Path,
+				MaxSize:    maxSize,
+				MaxBackups: maxBackups,
+				MaxAge:     maxAge,
+			}}, nil
+		})
+	})
+	return err
+}
+
+// outputPath преобразует назначение вывода в путь, понятный zap:
+// stdout и stderr используются как есть, остальное считается путем к файлу с ротацией
+func outputPath(opts Options) (string, error) {
+	switch opts.Output {
+	case "", "stdout":
+		return "stdout", nil
+	case "stderr":
+		return "stderr", nil
+	}
+
+	if err := registerRotatingSink(); err != nil {
+		return "", fmt.Errorf("failed to register rotating sink: %w", err)
+	}
+
+	path, err := filepath.Abs(opts.Output)
+	if err != nil {
+		return "", fmt.Errorf("invalid log file path %q: %w", opts.Output, err)
+	}
+
+	query := url.Values{}
+	query.Set("max_size", strconv.Itoa(opts.MaxSizeMB))
+	query.Set("max_backups", strconv.Itoa(opts.MaxBackups))
+	query.Set("max_age", strconv.Itoa(opts.MaxAgeDays))
+
+	u := url.URL{
+		Scheme:   rotatingScheme,
+		Path:     filepath.ToSlash(path),
+		RawQuery: query.Encode(),
+	}
+	return u.String(), nil
+}
@@ -0,0 +1,66 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestOutputPathStandardStreams(t *testing.T) {
+	for output, want := range map[string]string{"": "stdout", "stdout": "stdout", "stderr": "stderr"} {
+		got, err := outputPath(Options{Output: output})
+		if err != nil {
+			t.Fatalf("outputPath(%q) error = %v", output, err)
+		}
+		if got != want {
+			t.Errorf("outputPath(%q) = %q, want %q", output, got, want)
+		}
+	}
+}
+
+func TestNewLoggerRotatesFile(t *testing.T) {
+	t.Setenv("LOG_OUTPUT", "")
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+
+	log, _, err := NewLogger(Options{Level: "info", Output: path, MaxSizeMB: 1, MaxBackups: 2})
+	if err != nil {
+		t.Fatalf("NewLogger() error = %v", err)
+	}
+
+	// Записи суммарно превышают MaxSizeMB и вызывают хотя бы одну ротацию;
+	// сообщения различаются, чтобы сэмплирование не отбрасывало повторы
+	payload := strings.Repeat("x", 1024)
+	for i := range 2048 {
+		log.Info(fmt.Sprintf("entry %d", i), zap.String("payload", payload))
+	}
+	if err := log.Sync(); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir() error = %v", err)
+	}
+	var backups int
+	for _, entry := range entries {
+		if entry.Name() != "app.log" && strings.HasPrefix(entry.Name(), "app-") {
+			backups++
+		}
+	}
+	if backups == 0 {
+		t.Fatalf("files = %v, want at least one rotated backup", entries)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat(%s) error = %v", path, err)
+	}
+	if info.Size() > 1<<20 {
+		t.Errorf("current log size = %d, want at most %d", info.Size(), 1<<20)
+	}
+}
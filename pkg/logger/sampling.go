@@ -0,0 +1,43 @@
+package logger
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// sampleBelowError применяет сэмплирование только к записям ниже уровня Error:
+// при всплеске нагрузки отбрасываются повторяющиеся debug, info и warn, а записи
+// уровня Error и выше пишутся всегда, так как именно они нужны при разборе инцидента
+func sampleBelowError(core zapcore.Core, cfg *zap.SamplingConfig) zapcore.Core {
+	sampled := zapcore.NewSamplerWithOptions(&belowLevelCore{Core: core, level: zapcore.ErrorLevel},
+		time.Second, cfg.Initial, cfg.Thereafter)
+	unsampled, err := zapcore.NewIncreaseLevelCore(core, zapcore.ErrorLevel)
+	if err != nil {
+		// Недостижимо: ErrorLevel не ниже уровня любого ядра, кроме отключенного
+		return sampled
+	}
+	return zapcore.NewTee(sampled, unsampled)
+}
+
+// belowLevelCore пропускает только записи с уровнем ниже level
+type belowLevelCore struct {
+	zapcore.Core
+	level zapcore.Level
+}
+
+func (c *belowLevelCore) Enabled(level zapcore.Level) bool {
+	return level < c.level && c.Core.Enabled(level)
+}
+
+func (c *belowLevelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &belowLevelCore{Core: c.Core.With(fields), level: c.level}
+}
+
+func (c *belowLevelCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
@@ -0,0 +1,116 @@
+package logger
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestSampleBelowError(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	log := zap.New(sampleBelowError(core, &zap.SamplingConfig{Initial: 10, Thereafter: 1000})).
+		With(zap.String("request_id", "req-1"))
+
+	const burst = 500
+	for range burst {
+		log.Debug("burst")
+		log.Info("burst")
+		log.Warn("burst")
+		log.Error("burst")
+		log.DPanic("burst")
+	}
+
+	counts := make(map[zapcore.Level]int)
+	for _, entry := range logs.All() {
+		counts[entry.Level]++
+		if entry.ContextMap()["request_id"] != "req-1" {
+			t.Fatalf("entry %+v lost the request_id field", entry)
+		}
+	}
+	for _, level := range []zapcore.Level{zapcore.ErrorLevel, zapcore.DPanicLevel} {
+		if counts[level] != burst {
+			t.Errorf("%s entries = %d, want all %d", level, counts[level], burst)
+		}
+	}
+	// Окна сэмплирования выровнены по секундам, поэтому всплеск может попасть в два окна
+	for _, level := range []zapcore.Level{zapcore.DebugLevel, zapcore.InfoLevel, zapcore.WarnLevel} {
+		if counts[level] < 10 || counts[level] > 20 {
+			t.Errorf("%s entries = %d, want the first 10 of %d per second", level, counts[level], burst)
+		}
+	}
+}
+
+func TestSampleBelowErrorKeepsCoreLevel(t *testing.T) {
+	core, logs := observer.New(zapcore.WarnLevel)
+	log := zap.New(sampleBelowError(core, &zap.SamplingConfig{Initial: 10, Thereafter: 10}))
+
+	log.Debug("debug")
+	log.Info("info")
+	log.Warn("warn")
+	log.Error("error")
+
+	var got []string
+	for _, entry := range logs.All() {
+		got = append(got, entry.Message)
+	}
+	if len(got) != 2 || got[0] != "warn" || got[1] != "error" {
+		t.Errorf("entries = %v, want warn and error only", got)
+	}
+}
+
+func TestNewLoggerNeverSamplesErrors(t *testing.T) {
+	t.Setenv("LOG_OUTPUT", "")
+	t.Setenv("LOG_SAMPLING", "")
+	path := filepath.Join(t.TempDir(), "app.log")
+
+	log, _, err := NewLogger(Options{Level: "info", Output: path, SamplingInitial: 5, SamplingThereafter: 1000})
+	if err != nil {
+		t.Fatalf("NewLogger() error = %v", err)
+	}
+
+	const burst = 1000
+	for range burst {
+		log.Info("burst")
+		log.Error("burst")
+	}
+	if err := log.Sync(); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Open(%s) error = %v", path, err)
+	}
+	defer file.Close()
+
+	counts := make(map[string]int)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry struct {
+			Level string `json:"level"`
+			Msg   string `json:"msg"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("decode log line %q: %v", scanner.Text(), err)
+		}
+		if entry.Msg == "burst" {
+			counts[entry.Level]++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("read log: %v", err)
+	}
+
+	if counts["error"] != burst {
+		t.Errorf("error entries = %d, want all %d", counts["error"], burst)
+	}
+	if counts["info"] < 5 || counts["info"] > 10 {
+		t.Errorf("info entries = %d, want the first 5 of %d per second", counts["info"], burst)
+	}
+}
@@ -0,0 +1,68 @@
+package signedtoken
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignVerify(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	s := New([]byte("test-key"), "email_verification")
+
+	token, err := s.Sign("user-1", "alice@example.com", now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+
+	subject, value, err := s.Verify(token, now)
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if subject != "user-1" || value != "alice@example.com" {
+		t.Errorf("Verify() = %q, %q, want user-1, alice@example.com", subject, value)
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	s := New([]byte("test-key"), "email_verification")
+
+	sign := func(t *testing.T, signer *Signer, expiresAt time.Time) string {
+		t.Helper()
+
+		token, err := signer.Sign("user-1", "alice@example.com", expiresAt)
+		if err != nil {
+			t.Fatalf("Sign() error = %v", err)
+		}
+		return token
+	}
+	valid := sign(t, s, now.Add(time.Hour))
+	encoded, signature, _ := strings.Cut(valid, ".")
+	other := sign(t, New([]byte("test-key"), "password_reset"), now.Add(time.Hour))
+	otherEncoded, _, _ := strings.Cut(other, ".")
+
+	tests := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{name: "expired", token: sign(t, s, now.Add(-time.Second)), want: ErrExpiredToken},
+		{name: "expires now", token: sign(t, s, now), want: ErrExpiredToken},
+		{name: "other key", token: sign(t, New([]byte("other-key"), "email_verification"), now.Add(time.Hour)), want: ErrInvalidToken},
+		{name: "other purpose", token: other, want: ErrInvalidToken},
+		{name: "payload swapped", token: otherEncoded + "." + signature, want: ErrInvalidToken},
+		{name: "truncated signature", token: encoded + "." + signature[:len(signature)-2], want: ErrInvalidToken},
+		{name: "no separator", token: encoded, want: ErrInvalidToken},
+		{name: "empty", token: "", want: ErrInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := s.Verify(tt.token, now); !errors.Is(err, tt.want) {
+				t.Errorf("Verify() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
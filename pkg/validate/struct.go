@@ -0,0 +1,130 @@
+package validate
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// Struct проверяет поля структуры по правилам из тега validate и возвращает
+// *FieldError для первого нарушенного правила. Имя поля берется из тега json.
+//
+// Поддерживаемые правила:
+//   - required - строка не пустая, число не равно нулю;
+//   - min=N, max=N - длина строки в символах или значение числа;
+//   - uuid - строка является UUID (пустая строка допускается, если нет required).
+func Struct(v interface{}) error {
+	if errs := checkStruct(v, true); len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// StructAll проверяет поля структуры по тем же правилам, что и Struct, и возвращает
+// FieldErrors со всеми полями, нарушившими правила (для каждого поля - первое нарушенное
+// правило), или nil, если нарушений нет.
+func StructAll(v interface{}) error {
+	if errs := checkStruct(v, false); len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// checkStruct проверяет поля структуры; при firstOnly проверка прекращается на первом нарушении
+func checkStruct(v interface{}, firstOnly bool) FieldErrors {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var errs FieldErrors
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		tag := field.Tag.Get("validate")
+		if tag == "" || !field.IsExported() {
+			continue
+		}
+
+		name := jsonName(field)
+		for _, rule := range strings.Split(tag, ",") {
+			if msg := checkRule(rv.Field(i), rule); msg != "" {
+				errs = append(errs, &FieldError{Field: name, Message: msg})
+				break
+			}
+		}
+		if firstOnly && len(errs) > 0 {
+			break
+		}
+	}
+	return errs
+}
+
+// jsonName возвращает имя поля в JSON
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
+
+// checkRule проверяет значение по одному правилу и возвращает описание нарушения
+func checkRule(value reflect.Value, rule string) string {
+	name, arg, _ := strings.Cut(rule, "=")
+
+	switch name {
+	case "required":
+		if value.IsZero() {
+			return "is required"
+		}
+	case "min", "max":
+		limit, err := strconv.Atoi(arg)
+		if err != nil {
+			panic(fmt.Sprintf("validate: invalid %s argument %q", name, arg))
+		}
+		return checkBound(value, name, limit)
+	case "uuid":
+		if value.Kind() == reflect.String && value.String() != "" {
+			if _, err := uuid.Parse(value.String()); err != nil {
+				return "must be a valid UUID"
+			}
+		}
+	default:
+		panic(fmt.Sprintf("validate: unknown rule %q", rule))
+	}
+	return ""
+}
+
+// checkBound проверяет нижнюю или верхнюю границу длины строки или значения числа
+func checkBound(value reflect.Value, name string, limit int) string {
+	switch value.Kind() {
+	case reflect.String:
+		length := utf8.RuneCountInString(value.String())
+		if name == "min" && length < limit {
+			return fmt.Sprintf("must be at least %d characters", limit)
+		}
+		if name == "max" && length > limit {
+			return fmt.Sprintf("must be at most %d characters", limit)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n := value.Int()
+		if name == "min" && n < int64(limit) {
+			return fmt.Sprintf("must be at least %d", limit)
+		}
+		if name == "max" && n > int64(limit) {
+			return fmt.Sprintf("must be at most %d", limit)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,131 @@
+package validate
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+type structSample struct {
+	Name     string `json:"name" validate:"required,min=3,max=5"`
+	Count    int    `json:"count,omitempty" validate:"min=1,max=10"`
+	ID       string `json:"id" validate:"uuid"`
+	Untagged string
+	hidden   string `validate:"required"`
+}
+
+func TestStruct(t *testing.T) {
+	valid := func() structSample {
+		return structSample{Name: "abc", Count: 1}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(s *structSample)
+		// wantField и wantMessage описывают ожидаемую ошибку; пусто, если структура допустима
+		wantField   string
+		wantMessage string
+	}{
+		{name: "valid", modify: func(*structSample) {}},
+		{name: "maximum values", modify: func(s *structSample) { s.Name = "abcde"; s.Count = 10 }},
+		{name: "length counted in characters", modify: func(s *structSample) { s.Name = "юзер" }},
+		{name: "valid uuid", modify: func(s *structSample) { s.ID = "8f2c7a4e-1b3d-4c5e-9f6a-7b8c9d0e1f2a" }},
+		{name: "missing required", modify: func(s *structSample) { s.Name = "" }, wantField: "name", wantMessage: "is required"},
+		{name: "string too short", modify: func(s *structSample) { s.Name = "ab" }, wantField: "name", wantMessage: "must be at least 3 characters"},
+		{name: "string too long", modify: func(s *structSample) { s.Name = "abcdef" }, wantField: "name", wantMessage: "must be at most 5 characters"},
+		{name: "number too small", modify: func(s *structSample) { s.Count = 0 }, wantField: "count", wantMessage: "must be at least 1"},
+		{name: "number too large", modify: func(s *structSample) { s.Count = 11 }, wantField: "count", wantMessage: "must be at most 10"},
+		{name: "invalid uuid", modify: func(s *structSample) { s.ID = "not-a-uuid" }, wantField: "id", wantMessage: "must be a valid UUID"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := valid()
+			tt.modify(&s)
+			assertFieldError(t, Struct(&s), tt.wantField, tt.wantMessage)
+		})
+	}
+}
+
+func TestStructReportsFirstViolatedField(t *testing.T) {
+	s := structSample{Name: "", Count: 0}
+	assertFieldError(t, Struct(s), "name", "is required")
+}
+
+func TestStructAllReportsEveryField(t *testing.T) {
+	s := structSample{Name: "ab", Count: 11, ID: "not-a-uuid"}
+
+	var errs FieldErrors
+	if err := StructAll(&s); !errors.As(err, &errs) {
+		t.Fatalf("StructAll() error = %v, want FieldErrors", err)
+	}
+	want := FieldErrors{
+		{Field: "name", Message: "must be at least 3 characters"},
+		{Field: "count", Message: "must be at most 10"},
+		{Field: "id", Message: "must be a valid UUID"},
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("StructAll() = %v, want %v", errs, want)
+	}
+	for i := range want {
+		if *errs[i] != *want[i] {
+			t.Errorf("StructAll()[%d] = %+v, want %+v", i, errs[i], want[i])
+		}
+	}
+
+	if err := StructAll(&structSample{Name: "abc", Count: 1}); err != nil {
+		t.Errorf("StructAll(valid) error = %v, want nil", err)
+	}
+}
+
+func TestStructFieldWithoutJSONTag(t *testing.T) {
+	type sample struct {
+		Value string `validate:"required"`
+	}
+	assertFieldError(t, Struct(sample{}), "Value", "is required")
+}
+
+func TestStructNonStruct(t *testing.T) {
+	var nilSample *structSample
+	for _, v := range []interface{}{nil, nilSample, 42, []structSample{{}}} {
+		if err := Struct(v); err != nil {
+			t.Errorf("Struct(%#v) error = %v, want nil", v, err)
+		}
+	}
+}
+
+func TestStructPanicsOnInvalidRule(t *testing.T) {
+	tests := []struct {
+		name string
+		v    interface{}
+		want string
+	}{
+		{
+			name: "unknown rule",
+			v: struct {
+				Value string `validate:"email"`
+			}{},
+			want: `unknown rule "email"`,
+		},
+		{
+			name: "non-numeric bound",
+			v: struct {
+				Value string `validate:"min=abc"`
+			}{},
+			want: `invalid min argument "abc"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				r := recover()
+				msg, _ := r.(string)
+				if !strings.Contains(msg, tt.want) {
+					t.Errorf("panic = %v, want it to contain %q", r, tt.want)
+				}
+			}()
+			_ = Struct(tt.v)
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Ограничения на учетные данные
+const (
+	MinUsernameLength        = 3
+	MaxUsernameLength        = 32
+	DefaultMinPasswordLength = 8
+	MaxEmailLength           = 254
+)
+
+var usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9_]+$`)
+
+// FieldError описывает нарушение правила валидации конкретного поля
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// FieldErrors нарушения правил валидации нескольких полей в порядке проверки
+type FieldErrors []*FieldError
+
+func (e FieldErrors) Error() string {
+	parts := make([]string, len(e))
+	for i, fieldErr := range e {
+		parts[i] = fieldErr.Error()
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Unwrap позволяет errors.As найти первое нарушение как *FieldError
+func (e FieldErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, fieldErr := range e {
+		errs[i] = fieldErr
+	}
+	return errs
+}
+
+// As извлекает из err нарушения правил валидации: FieldErrors или одиночную FieldError.
+// Возвращает false, если err не является ошибкой валидации.
+func As(err error) (FieldErrors, bool) {
+	var fieldErrs FieldErrors
+	if errors.As(err, &fieldErrs) {
+		return fieldErrs, true
+	}
+	var fieldErr *FieldError
+	if errors.As(err, &fieldErr) {
+		return FieldErrors{fieldErr}, true
+	}
+	return nil, false
+}
+
+// Join объединяет результаты нескольких проверок в FieldErrors, пропуская nil;
+// для поля, встретившегося несколько раз, сохраняется первое нарушение. Возвращает nil,
+// если нарушений нет. Ошибка, не относящаяся к валидации, возвращается без изменений.
+func Join(errs ...error) error {
+	var joined FieldErrors
+	seen := make(map[string]struct{})
+	add := func(fieldErr *FieldError) {
+		if _, ok := seen[fieldErr.Field]; ok {
+			return
+		}
+		seen[fieldErr.Field] = struct{}{}
+		joined = append(joined, fieldErr)
+	}
+
+	for _, err := range errs {
+		var fieldErr *FieldError
+		var fieldErrs FieldErrors
+		switch {
+		case err == nil:
+		case errors.As(err, &fieldErrs):
+			for _, fieldErr := range fieldErrs {
+				add(fieldErr)
+			}
+		case errors.As(err, &fieldErr):
+			add(fieldErr)
+		default:
+			return err
+		}
+	}
+	if len(joined) == 0 {
+		return nil
+	}
+	return joined
+}
+
+// Username проверяет длину имени пользователя и допустимые символы
+func Username(username string) error {
+	length := utf8.RuneCountInString(username)
+	switch {
+	case length == 0:
+		return &FieldError{Field: "username", Message: "is required"}
+	case length < MinUsernameLength || length > MaxUsernameLength:
+		return &FieldError{
+			Field:   "username",
+			Message: fmt.Sprintf("must be between %d and %d characters", MinUsernameLength, MaxUsernameLength),
+		}
+	case !usernamePattern.MatchString(username):
+		return &FieldError{Field: "username", Message: "may contain only letters, digits and underscores"}
+	}
+	return nil
+}
+
+// Password проверяет минимальную длину пароля; при minLength <= 0 используется DefaultMinPasswordLength
+func Password(field, password string, minLength int) error {
+	if minLength <= 0 {
+		minLength = DefaultMinPasswordLength
+	}
+
+	length := utf8.RuneCountInString(password)
+	switch {
+	case length == 0:
+		return &FieldError{Field: field, Message: "is required"}
+	case length < minLength:
+		return &FieldError{Field: field, Message: fmt.Sprintf("must be at least %d characters", minLength)}
+	}
+	return nil
+}
+
+// Email проверяет, что адрес электронной почты имеет вид local@domain без имени и угловых скобок
+func Email(email string) error {
+	if len(email) > MaxEmailLength {
+		return &FieldError{Field: "email", Message: fmt.Sprintf("must be at most %d characters", MaxEmailLength)}
+	}
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || !strings.Contains(email[strings.LastIndex(email, "@")+1:], ".") {
+		return &FieldError{Field: "email", Message: "must be a valid email address"}
+	}
+	return nil
+}
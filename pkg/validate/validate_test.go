@@ -0,0 +1,141 @@
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestUsername(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		// wantMessage текст ошибки; пусто, если имя допустимо
+		wantMessage string
+	}{
+		{name: "minimum length", username: "abc"},
+		{name: "maximum length", username: strings.Repeat("a", MaxUsernameLength)},
+		{name: "underscores and digits", username: "user_42"},
+		{name: "empty", username: "", wantMessage: "is required"},
+		{name: "too short", username: "ab", wantMessage: "must be between 3 and 32 characters"},
+		{name: "too long", username: strings.Repeat("a", MaxUsernameLength+1), wantMessage: "must be between 3 and 32 characters"},
+		{name: "space", username: "bob smith", wantMessage: "may contain only letters, digits and underscores"},
+		{name: "non-ASCII letters", username: "юзер", wantMessage: "may contain only letters, digits and underscores"},
+		// Длина считается в символах, а не в байтах
+		{name: "short non-ASCII", username: "юз", wantMessage: "must be between 3 and 32 characters"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertFieldError(t, Username(tt.username), "username", tt.wantMessage)
+		})
+	}
+}
+
+func TestPassword(t *testing.T) {
+	tests := []struct {
+		name        string
+		password    string
+		minLength   int
+		wantMessage string
+	}{
+		{name: "exactly minimum", password: "12345678", minLength: 8},
+		{name: "one below minimum", password: "1234567", minLength: 8, wantMessage: "must be at least 8 characters"},
+		{name: "default minimum", password: "1234567", minLength: 0, wantMessage: "must be at least 8 characters"},
+		{name: "custom minimum", password: "12345", minLength: 5},
+		{name: "counted in characters", password: "пароль12", minLength: 8},
+		{name: "empty", password: "", minLength: 8, wantMessage: "is required"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertFieldError(t, Password("new_password", tt.password, tt.minLength), "new_password", tt.wantMessage)
+		})
+	}
+}
+
+func TestEmail(t *testing.T) {
+	tests := []struct {
+		name        string
+		email       string
+		wantMessage string
+	}{
+		{name: "valid", email: "alice@example.com"},
+		{name: "plus and subdomain", email: "alice+tag@mail.example.com"},
+		{name: "maximum length", email: strings.Repeat("a", MaxEmailLength-len("@example.com")) + "@example.com"},
+		{name: "too long", email: strings.Repeat("a", MaxEmailLength-len("@example.com")+1) + "@example.com", wantMessage: "must be at most 254 characters"},
+		{name: "missing at", email: "alice.example.com", wantMessage: "must be a valid email address"},
+		{name: "domain without dot", email: "alice@localhost", wantMessage: "must be a valid email address"},
+		{name: "display name", email: "Alice <alice@example.com>", wantMessage: "must be a valid email address"},
+		{name: "surrounding spaces", email: " alice@example.com", wantMessage: "must be a valid email address"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertFieldError(t, Email(tt.email), "email", tt.wantMessage)
+		})
+	}
+}
+
+func TestJoin(t *testing.T) {
+	username := &FieldError{Field: "username", Message: "is required"}
+	password := &FieldError{Field: "password", Message: "must be at least 8 characters"}
+	other := errors.New("connection refused")
+
+	if err := Join(nil, nil); err != nil {
+		t.Errorf("Join(nil, nil) = %v, want nil", err)
+	}
+	if err := Join(username, other); err != other {
+		t.Errorf("Join(field error, other) = %v, want %v", err, other)
+	}
+
+	err := Join(FieldErrors{username}, nil, password, &FieldError{Field: "username", Message: "is too short"})
+	var errs FieldErrors
+	if !errors.As(err, &errs) || len(errs) != 2 || errs[0] != username || errs[1] != password {
+		t.Fatalf("Join() = %v, want username then password", err)
+	}
+	// Первое нарушение доступно как *FieldError
+	assertFieldError(t, err, "username", "is required")
+	if got := err.Error(); got != "username: is required; password: must be at least 8 characters" {
+		t.Errorf("Error() = %q", got)
+	}
+}
+
+func TestAs(t *testing.T) {
+	username := &FieldError{Field: "username", Message: "is required"}
+	password := &FieldError{Field: "password", Message: "must be at least 8 characters"}
+
+	if errs, ok := As(errors.New("connection refused")); ok || errs != nil {
+		t.Errorf("As(other) = %v, %t, want nil, false", errs, ok)
+	}
+	if errs, ok := As(nil); ok || errs != nil {
+		t.Errorf("As(nil) = %v, %t, want nil, false", errs, ok)
+	}
+	if errs, ok := As(fmt.Errorf("decode: %w", username)); !ok || len(errs) != 1 || errs[0] != username {
+		t.Errorf("As(wrapped field error) = %v, %t, want [username]", errs, ok)
+	}
+	if errs, ok := As(Join(username, password)); !ok || len(errs) != 2 || errs[0] != username || errs[1] != password {
+		t.Errorf("As(joined) = %v, %t, want username then password", errs, ok)
+	}
+}
+
+// assertFieldError проверяет, что err является FieldError с указанным полем и текстом
+func assertFieldError(t *testing.T, err error, wantField, wantMessage string) {
+	t.Helper()
+
+	if wantMessage == "" {
+		if err != nil {
+			t.Errorf("error = %v, want nil", err)
+		}
+		return
+	}
+
+	var fieldErr *FieldError
+	if !errors.As(err, &fieldErr) {
+		t.Fatalf("error = %v, want *FieldError", err)
+	}
+	if fieldErr.Field != wantField || fieldErr.Message != wantMessage {
+		t.Errorf("error = %+v, want field %q with message %q", fieldErr, wantField, wantMessage)
+	}
+}
@@ -0,0 +1,243 @@
+// Package webhook асинхронно отправляет подписанные JSON уведомления на внешний URL.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// Заголовки исходящих запросов
+const (
+	// SignatureHeader содержит подпись тела запроса: "sha256=" + hex(HMAC-SHA256(secret, body))
+	SignatureHeader = "X-Webhook-Signature"
+	// EventHeader содержит тип события
+	EventHeader = "X-Webhook-Event"
+	// CorrelationHeader содержит идентификатор HTTP запроса, вызвавшего событие
+	CorrelationHeader = "X-Correlation-ID"
+)
+
+// Options содержит параметры отправки уведомлений
+type Options struct {
+	// URL адрес, на который отправляются уведомления
+	URL string
+	// Secret ключ подписи тела запроса
+	Secret string
+	// Workers количество параллельных отправителей
+	Workers int
+	// QueueSize размер очереди; при заполненной очереди новые события отбрасываются
+	QueueSize int
+	// MaxAttempts максимальное количество попыток доставки одного события
+	MaxAttempts int
+	// Backoff задержка перед второй попыткой; каждая следующая задержка вдвое больше
+	Backoff time.Duration
+	// Timeout время ожидания ответа на одну попытку
+	Timeout time.Duration
+	// CorrelationID передавать идентификатор исходного запроса в заголовке CorrelationHeader
+	CorrelationID bool
+}
+
+// delivery событие в очереди отправки
+type delivery struct {
+	event     string
+	body      []byte
+	requestID string
+}
+
+// Dispatcher отправляет уведомления пулом воркеров с повторными попытками.
+// Ошибки доставки только логируются и не влияют на вызывающего.
+type Dispatcher struct {
+	opts   Options
+	client *http.Client
+	queue  chan delivery
+
+	mu     sync.RWMutex
+	closed bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	log    *zap.Logger
+}
+
+// New создает Dispatcher и запускает воркеры
+func New(opts Options, log *zap.Logger) *Dispatcher {
+	if opts.Workers <= 0 {
+		opts.Workers = 1
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 100
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 1
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &Dispatcher{
+		opts:   opts,
+		client: &http.Client{Timeout: opts.Timeout},
+		queue:  make(chan delivery, opts.QueueSize),
+		ctx:    ctx,
+		cancel: cancel,
+		log:    log.Named("webhook"),
+	}
+
+	d.wg.Add(opts.Workers)
+	for i := 0; i < opts.Workers; i++ {
+		go d.worker()
+	}
+	return d
+}
+
+// Notify ставит событие в очередь отправки без ожидания доставки.
+// Возвращает false, если событие отброшено (очередь заполнена или Dispatcher остановлен).
+func (d *Dispatcher) Notify(ctx context.Context, event string, payload any) bool {
+	log := logger.FromContext(ctx, d.log)
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Error("Failed to encode webhook payload", zap.String("event", event), zap.Error(err))
+		return false
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.closed {
+		log.Warn("Webhook dispatcher closed, event dropped", zap.String("event", event))
+		return false
+	}
+
+	select {
+	case d.queue <- delivery{event: event, body: body, requestID: logger.RequestIDFromContext(ctx)}:
+		return true
+	default:
+		log.Warn("Webhook queue full, event dropped", zap.String("event", event))
+		return false
+	}
+}
+
+// Close прекращает прием событий и ожидает отправки уже поставленных в очередь.
+// По истечении ctx незавершенные доставки прерываются.
+func (d *Dispatcher) Close(ctx context.Context) error {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.queue)
+	}
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		d.cancel()
+		return nil
+	case <-ctx.Done():
+		d.cancel()
+		return ctx.Err()
+	}
+}
+
+func (d *Dispatcher) worker() {
+	defer d.wg.Done()
+	for item := range d.queue {
+		d.deliver(item)
+	}
+}
+
+// deliver отправляет событие, повторяя попытки с экспоненциальной задержкой
+func (d *Dispatcher) deliver(item delivery) {
+	log := logger.FromContext(logger.WithRequestID(d.ctx, item.requestID), d.log)
+
+	backoff := d.opts.Backoff
+	for attempt := 1; ; attempt++ {
+		err := d.send(item)
+		if err == nil {
+			log.Debug("Webhook delivered", zap.String("event", item.event), zap.Int("attempt", attempt))
+			return
+		}
+
+		var permanent *permanentError
+		if errors.As(err, &permanent) || attempt >= d.opts.MaxAttempts {
+			log.Error("Webhook delivery failed",
+				zap.String("event", item.event),
+				zap.Int("attempts", attempt),
+				zap.Error(err))
+			return
+		}
+
+		log.Warn("Webhook delivery attempt failed, retrying",
+			zap.String("event", item.event),
+			zap.Int("attempt", attempt),
+			zap.Duration("backoff", backoff),
+			zap.Error(err))
+
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-d.ctx.Done():
+			log.Warn("Webhook delivery aborted", zap.String("event", item.event))
+			return
+		}
+	}
+}
+
+// permanentError ошибка доставки, при которой повторная попытка бессмысленна
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+// send выполняет одну попытку доставки
+func (d *Dispatcher) send(item delivery) error {
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodPost, d.opts.URL, bytes.NewReader(item.body))
+	if err != nil {
+		return &permanentError{err: err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, item.event)
+	req.Header.Set(SignatureHeader, Sign(d.opts.Secret, item.body))
+	if d.opts.CorrelationID && item.requestID != "" {
+		req.Header.Set(CorrelationHeader, item.requestID)
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	default:
+		return &permanentError{err: fmt.Errorf("webhook rejected with status %d", resp.StatusCode)}
+	}
+}
+
+// Sign возвращает значение заголовка SignatureHeader для тела запроса
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
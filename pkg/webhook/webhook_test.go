@@ -0,0 +1,234 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DblMOKRQ/DeNet_test_task/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// request полученный тестовым получателем запрос
+type request struct {
+	header http.Header
+	body   []byte
+}
+
+// newReceiver запускает получателя уведомлений, отвечающего статусами statuses по очереди
+// (после их окончания - 200), и возвращает канал полученных запросов
+func newReceiver(t *testing.T, statuses ...int) (*httptest.Server, <-chan request) {
+	t.Helper()
+
+	requests := make(chan request, 16)
+	var calls atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- request{header: r.Header, body: body}
+
+		if n := int(calls.Add(1)); n <= len(statuses) {
+			w.WriteHeader(statuses[n-1])
+		}
+	}))
+	t.Cleanup(receiver.Close)
+	return receiver, requests
+}
+
+// receive ожидает следующий запрос получателя
+func receive(t *testing.T, requests <-chan request) request {
+	t.Helper()
+
+	select {
+	case req := <-requests:
+		return req
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook was not delivered")
+		return request{}
+	}
+}
+
+func TestDeliverySigned(t *testing.T) {
+	receiver, requests := newReceiver(t)
+	d := New(Options{URL: receiver.URL, Secret: "test-secret", CorrelationID: true}, zap.NewNop())
+	defer d.Close(context.Background())
+
+	ctx := logger.WithRequestID(context.Background(), "request-1")
+	if !d.Notify(ctx, "task.completed", map[string]int{"points": 10}) {
+		t.Fatal("Notify() = false, want true")
+	}
+
+	req := receive(t, requests)
+	if string(req.body) != `{"points":10}` {
+		t.Errorf("body = %s, want the JSON payload", req.body)
+	}
+
+	// Получатель проверяет подпись своим экземпляром секрета
+	mac := hmac.New(sha256.New, []byte("test-secret"))
+	mac.Write(req.body)
+	if got, want := req.header.Get(SignatureHeader), "sha256="+hex.EncodeToString(mac.Sum(nil)); got != want {
+		t.Errorf("%s = %q, want %q", SignatureHeader, got, want)
+	}
+	if got := req.header.Get(EventHeader); got != "task.completed" {
+		t.Errorf("%s = %q, want task.completed", EventHeader, got)
+	}
+	if got := req.header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+	if got := req.header.Get(CorrelationHeader); got != "request-1" {
+		t.Errorf("%s = %q, want request-1", CorrelationHeader, got)
+	}
+}
+
+func TestCorrelationHeader(t *testing.T) {
+	tests := []struct {
+		name          string
+		correlationID bool
+		requestID     string
+		want          string
+	}{
+		{name: "enabled", correlationID: true, requestID: "request-1", want: "request-1"},
+		{name: "disabled", correlationID: false, requestID: "request-1", want: ""},
+		{name: "no request id", correlationID: true, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receiver, requests := newReceiver(t)
+			d := New(Options{URL: receiver.URL, Secret: "secret", CorrelationID: tt.correlationID}, zap.NewNop())
+			defer d.Close(context.Background())
+
+			ctx := context.Background()
+			if tt.requestID != "" {
+				ctx = logger.WithRequestID(ctx, tt.requestID)
+			}
+			if !d.Notify(ctx, "task.completed", map[string]int{"points": 10}) {
+				t.Fatal("Notify() = false, want true")
+			}
+
+			req := receive(t, requests)
+			if req.header.Get(CorrelationHeader) != tt.want {
+				t.Errorf("%s = %q, want %q", CorrelationHeader, req.header.Get(CorrelationHeader), tt.want)
+			}
+		})
+	}
+}
+
+func TestSignDependsOnSecret(t *testing.T) {
+	body := []byte(`{"points":10}`)
+	if Sign("secret-a", body) == Sign("secret-b", body) {
+		t.Error("Sign() is equal for different secrets")
+	}
+	if Sign("secret-a", body) == Sign("secret-a", []byte(`{"points":11}`)) {
+		t.Error("Sign() is equal for different bodies")
+	}
+}
+
+func TestDeliveryRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		maxAttempts  int
+		wantAttempts int
+	}{
+		{name: "retried until success", statuses: []int{http.StatusInternalServerError, http.StatusTooManyRequests}, maxAttempts: 5, wantAttempts: 3},
+		{name: "stops at max attempts", statuses: []int{500, 502, 503, 504}, maxAttempts: 2, wantAttempts: 2},
+		{name: "client error is not retried", statuses: []int{http.StatusBadRequest}, maxAttempts: 5, wantAttempts: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receiver, requests := newReceiver(t, tt.statuses...)
+			d := New(Options{URL: receiver.URL, Secret: "secret", MaxAttempts: tt.maxAttempts, Backoff: time.Millisecond}, zap.NewNop())
+
+			if !d.Notify(context.Background(), "task.completed", map[string]int{"points": 10}) {
+				t.Fatal("Notify() = false, want true")
+			}
+			if err := d.Close(context.Background()); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+
+			if got := len(requests); got != tt.wantAttempts {
+				t.Errorf("delivery attempts = %d, want %d", got, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestNotifyDoesNotBlock(t *testing.T) {
+	release := make(chan struct{})
+	receiver := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		<-release
+	}))
+	defer receiver.Close()
+	defer close(release)
+
+	d := New(Options{URL: receiver.URL, Secret: "secret", Workers: 1, QueueSize: 1}, zap.NewNop())
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_ = d.Close(ctx)
+	}()
+
+	// Единственный воркер занят первым событием, второе ждет в очереди,
+	// остальные отбрасываются без ожидания получателя
+	start := time.Now()
+	accepted := 0
+	for range 10 {
+		if d.Notify(context.Background(), "task.completed", map[string]int{"points": 10}) {
+			accepted++
+		}
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Notify() took %s with a hung receiver, want it not to block", elapsed)
+	}
+	if accepted < 1 || accepted > 2 {
+		t.Errorf("accepted events = %d, want the queue capacity plus at most one in flight", accepted)
+	}
+}
+
+func TestCloseFlushesQueue(t *testing.T) {
+	receiver, requests := newReceiver(t)
+	d := New(Options{URL: receiver.URL, Secret: "secret", Workers: 1, QueueSize: 10}, zap.NewNop())
+
+	for range 5 {
+		if !d.Notify(context.Background(), "task.completed", map[string]int{"points": 10}) {
+			t.Fatal("Notify() = false, want true")
+		}
+	}
+	if err := d.Close(context.Background()); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if got := len(requests); got != 5 {
+		t.Errorf("delivered events = %d, want 5 before Close returns", got)
+	}
+
+	if d.Notify(context.Background(), "task.completed", map[string]int{"points": 10}) {
+		t.Error("Notify() after Close = true, want the event dropped")
+	}
+}
+
+func TestCloseTimeout(t *testing.T) {
+	release := make(chan struct{})
+	receiver := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		<-release
+	}))
+	defer receiver.Close()
+	defer close(release)
+
+	d := New(Options{URL: receiver.URL, Secret: "secret"}, zap.NewNop())
+	d.Notify(context.Background(), "task.completed", map[string]int{"points": 10})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := d.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Close() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}